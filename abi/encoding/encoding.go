@@ -0,0 +1,162 @@
+// Package encoding implements the binary encoding of lists the host and guest
+// exchange in one call, such as headers via handler.FuncReadRequestHeaders,
+// query parameters via handler.FuncReadQueryParams and properties via
+// handler.FuncReadProperties. This has no dependencies, so that hosts, tests
+// and guest SDKs, such as those compiled with TinyGo, can share it.
+//
+// # Version 1
+//
+// The encoding is a sequence of entries, without a header or count. An entry
+// is a name and a value, each a field:
+//
+//   - len: little-endian uint32 length of the data in bytes.
+//   - data: the bytes of the name or value. Ex. "Content-Type"
+//
+// For example, the entry "a" "bc" is encoded as
+// "\x01\x00\x00\x00a\x02\x00\x00\x00bc". An empty list is zero bytes.
+//
+// A name with multiple values has an entry for each, in order. Entries
+// encoded from a map, such as headers, are in order of their name, so that
+// the encoding is deterministic.
+//
+// Incompatible changes will be made in a new Version, via new host functions,
+// so that guests built against this version continue to work.
+package encoding
+
+import (
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+// Version is the version of the encoding documented on this package.
+const Version = 1
+
+// ErrTruncated is returned when decoding a field whose length exceeds the
+// remaining bytes, or an entry without a value.
+var ErrTruncated = errors.New("truncated entries")
+
+// Entry is a name and value. Ex. {Name: "Content-Type", Value: "text/plain"}
+type Entry struct {
+	Name, Value string
+}
+
+// AppendField appends the field to b, returning the result.
+func AppendField(b []byte, s string) []byte {
+	var l [4]byte
+	binary.LittleEndian.PutUint32(l[:], uint32(len(s)))
+	return append(append(b, l[:]...), s...)
+}
+
+// AppendEntry appends an entry of the name and value to b, returning the
+// result.
+func AppendEntry(b []byte, name, value string) []byte {
+	return AppendField(AppendField(b, name), value)
+}
+
+// NextField returns the data of the next field, and the remaining bytes. The
+// data isn't a copy of b.
+func NextField(b []byte) (data, remaining []byte, err error) {
+	if len(b) < 4 {
+		return nil, nil, ErrTruncated
+	}
+	l := binary.LittleEndian.Uint32(b)
+	if uint64(len(b)-4) < uint64(l) {
+		return nil, nil, ErrTruncated
+	}
+	return b[4 : 4+l], b[4+l:], nil
+}
+
+// NextEntry returns the name and value of the next entry, and the remaining
+// bytes. The name and value aren't copies of b.
+func NextEntry(b []byte) (name, value, remaining []byte, err error) {
+	if name, b, err = NextField(b); err != nil {
+		return
+	}
+	if value, b, err = NextField(b); err != nil {
+		return
+	}
+	return name, value, b, nil
+}
+
+// EncodeEntries encodes the entries in order.
+func EncodeEntries(entries []Entry) []byte {
+	size := 0
+	for _, e := range entries {
+		size += 8 + len(e.Name) + len(e.Value)
+	}
+	b := make([]byte, 0, size)
+	for _, e := range entries {
+		b = AppendEntry(b, e.Name, e.Value)
+	}
+	return b
+}
+
+// EncodeMultiMap encodes an entry for each value, in order of the name, such
+// as for http.Header or url.Values.
+func EncodeMultiMap(m map[string][]string) []byte {
+	names := make([]string, 0, len(m))
+	size := 0
+	for name, values := range m {
+		names = append(names, name)
+		for _, v := range values {
+			size += 8 + len(name) + len(v)
+		}
+	}
+	sort.Strings(names)
+
+	b := make([]byte, 0, size)
+	for _, name := range names {
+		for _, v := range m[name] {
+			b = AppendEntry(b, name, v)
+		}
+	}
+	return b
+}
+
+// EncodeMap encodes an entry for each name, in order of the name.
+func EncodeMap(m map[string]string) []byte {
+	names := make([]string, 0, len(m))
+	size := 0
+	for name, v := range m {
+		names = append(names, name)
+		size += 8 + len(name) + len(v)
+	}
+	sort.Strings(names)
+
+	b := make([]byte, 0, size)
+	for _, name := range names {
+		b = AppendEntry(b, name, m[name])
+	}
+	return b
+}
+
+// DecodeEntries decodes all entries in b, in order.
+func DecodeEntries(b []byte) ([]Entry, error) {
+	var entries []Entry
+	for len(b) > 0 {
+		name, value, remaining, err := NextEntry(b)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, Entry{Name: string(name), Value: string(value)})
+		b = remaining
+	}
+	return entries, nil
+}
+
+// DecodeMultiMap decodes all entries in b, appending values of the same name
+// in order.
+func DecodeMultiMap(b []byte) (map[string][]string, error) {
+	m := map[string][]string{}
+	for len(b) > 0 {
+		name, value, remaining, err := NextEntry(b)
+		if err != nil {
+			return nil, err
+		}
+		n := string(name)
+		m[n] = append(m[n], string(value))
+		b = remaining
+	}
+	return m, nil
+}
@@ -0,0 +1,108 @@
+package encoding
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEncodeEntries(t *testing.T) {
+	tests := []struct {
+		name     string
+		entries  []Entry
+		expected string
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:     "one",
+			entries:  []Entry{{Name: "a", Value: "bc"}},
+			expected: "\x01\x00\x00\x00a\x02\x00\x00\x00bc",
+		},
+		{
+			name:     "empty value",
+			entries:  []Entry{{Name: "a"}},
+			expected: "\x01\x00\x00\x00a\x00\x00\x00\x00",
+		},
+		{
+			name:     "order is preserved",
+			entries:  []Entry{{Name: "b", Value: "1"}, {Name: "a", Value: "2"}},
+			expected: "\x01\x00\x00\x00b\x01\x00\x00\x001\x01\x00\x00\x00a\x01\x00\x00\x002",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			b := EncodeEntries(tc.entries)
+			if string(b) != tc.expected {
+				t.Fatalf("expected %q, have %q", tc.expected, b)
+			}
+			entries, err := DecodeEntries(b)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(entries, tc.entries) {
+				t.Errorf("expected %v, have %v", tc.entries, entries)
+			}
+		})
+	}
+}
+
+func TestEncodeMultiMap(t *testing.T) {
+	m := map[string][]string{"b": {"2", "1"}, "a": {"3"}}
+	b := EncodeMultiMap(m)
+
+	// Names are sorted, and values are in order.
+	entries, err := DecodeEntries(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Entry{{Name: "a", Value: "3"}, {Name: "b", Value: "2"}, {Name: "b", Value: "1"}}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected %v, have %v", expected, entries)
+	}
+
+	decoded, err := DecodeMultiMap(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, m) {
+		t.Errorf("expected %v, have %v", m, decoded)
+	}
+}
+
+func TestEncodeMap(t *testing.T) {
+	b := EncodeMap(map[string]string{"b": "2", "a": "1"})
+	entries, err := DecodeEntries(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Entry{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("expected %v, have %v", expected, entries)
+	}
+}
+
+func TestDecodeEntries_Truncated(t *testing.T) {
+	tests := []struct {
+		name, encoded string
+	}{
+		{name: "short length", encoded: "\x01\x00"},
+		{name: "short data", encoded: "\x02\x00\x00\x00a"},
+		{name: "missing value", encoded: "\x01\x00\x00\x00a"},
+		{name: "huge length", encoded: "\xff\xff\xff\xffa"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := DecodeEntries([]byte(tc.encoded)); err != ErrTruncated {
+				t.Errorf("expected ErrTruncated, have %v", err)
+			}
+			if _, err := DecodeMultiMap([]byte(tc.encoded)); err != ErrTruncated {
+				t.Errorf("expected ErrTruncated, have %v", err)
+			}
+		})
+	}
+}
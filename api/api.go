@@ -1,12 +1,314 @@
 package api
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+)
 
 // LogFunc writes a message to the host console.
 type LogFunc func(ctx context.Context, msg string)
 
+// LogDroppedFunc is notified each time a message the guest logged is dropped,
+// such as to count them in metrics. reason is "request" when the guest
+// exceeded httpwasm.MaxLogsPerRequest, or "rate" when it exceeded
+// httpwasm.LogRateLimit.
+type LogDroppedFunc func(ctx context.Context, reason string)
+
+// ReloadFunc is notified after a guest loaded from a file is reloaded. The
+// error is nil on success. Otherwise, the previous guest remains in use.
+type ReloadFunc func(ctx context.Context, path string, err error)
+
+// LatencyBudgetFunc is notified when the latency a guest adds to requests
+// exceeds the budget configured with httpwasm.LatencyBudget, and again when
+// it is back within budget. p99 is the 99th percentile latency that caused
+// the change, and ctx is of the request which completed the measurement.
+type LatencyBudgetFunc func(ctx context.Context, p99 time.Duration, exceeded bool)
+
+// AuditFunc is notified after each call from the guest to a host function,
+// such as handler.FuncReadRequestHeader. args summarizes the arguments, with
+// strings the guest passed read from its memory, and d is the duration of
+// the call. Ex. `name="Authorization" buf=1024 buf_limit=64`
+type AuditFunc func(ctx context.Context, function, args string, d time.Duration)
+
+// AccessLogger receives access log entries guests emit via
+// handler.FuncEmitAccessLog, such as to write them to a file, syslog or
+// OpenTelemetry. Implementations must be safe for concurrent use.
+type AccessLogger interface {
+	// LogAccess logs the entry, which is a valid JSON value. The entry may
+	// be guest memory, so must be copied if retained.
+	LogAccess(ctx context.Context, entry []byte)
+}
+
+// AccessLoggerFunc adapts a function to an AccessLogger.
+type AccessLoggerFunc func(ctx context.Context, entry []byte)
+
+// LogAccess implements AccessLogger.
+func (f AccessLoggerFunc) LogAccess(ctx context.Context, entry []byte) {
+	f(ctx, entry)
+}
+
+// Event is a notification a guest emitted via handler.FuncEmitEvent, such as
+// that it blocked a user.
+type Event struct {
+	// Module is the name of the guest module which emitted the event.
+	Module string
+	// Topic is the kind of event, defined by the guest. Ex. "user.blocked"
+	Topic string
+	// Payload is data about the event, defined by the guest, such as a JSON
+	// object. This is a copy, so can be retained.
+	Payload []byte
+}
+
+// EventFunc receives events guests emit via handler.FuncEmitEvent, such as
+// to trigger business logic outside the request path. Events are delivered
+// in order, on a goroutine, after the guest continued, so the context isn't
+// of the request.
+type EventFunc func(ctx context.Context, event Event)
+
+// Mutation is a change a guest in shadow mode would have made to the request
+// or response, had it been enforcing. See httpwasm.ShadowMode.
+type Mutation struct {
+	// Method is the name of the method of handler.Host, or one of its
+	// optional interfaces, the guest called.
+	// Ex. "SetResponseHeader"
+	Method string
+	// Args are the arguments after the context, formatted as strings.
+	// Ex. []string{"X-Frame-Options", "DENY"}
+	Args []string
+}
+
+// String returns the mutation in Go syntax.
+// Ex. `SetResponseHeader("X-Frame-Options", "DENY")`
+func (m Mutation) String() string {
+	s := m.Method + "("
+	for i, arg := range m.Args {
+		if i > 0 {
+			s += ", "
+		}
+		s += fmt.Sprintf("%q", arg)
+	}
+	return s + ")"
+}
+
+// ShadowFunc is notified after a guest in shadow mode handled a request, with
+// the mutations it would have made, in order. The mutations are empty when
+// the guest wouldn't have changed anything, such as when it allowed the
+// request as-is.
+type ShadowFunc func(ctx context.Context, mutations []Mutation)
+
 type Closer interface {
 	// Close releases resources such as any Wasm modules, compiled code, and
 	// the runtime.
 	Close(context.Context) error
 }
+
+// SharedStore is a key/value store shared across requests and guests, which
+// implements handler.FuncGetShared, handler.FuncSetShared and
+// handler.FuncCasShared. Implementations must be safe for concurrent use.
+//
+// The default is an in-memory store in package sharedstore. Hosts with more
+// than one process can implement this with a remote store such as Redis.
+type SharedStore interface {
+	// Get returns the value of the key, or false if it doesn't exist or
+	// expired.
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+
+	// Set sets the value of the key. A positive ttl expires the key after
+	// that duration. Otherwise, it doesn't expire. The value may be guest
+	// memory, so must be copied if retained.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// CompareAndSwap is like Set, except it only sets the value if the
+	// current value equals old, returning true if it did. A key that doesn't
+	// exist compares equal to an empty value.
+	CompareAndSwap(ctx context.Context, key string, old, new []byte, ttl time.Duration) (swapped bool, err error)
+}
+
+// StateStore is a durable key/value store, which implements
+// handler.FuncReadState and handler.FuncWriteState. Unlike SharedStore,
+// values must survive restarts of the host. Implementations must be safe for
+// concurrent use.
+//
+// Package statestore includes an implementation which stores each key in a
+// file. Others can use an embedded database such as bbolt or Badger.
+type StateStore interface {
+	// Load returns the value of the key, or false if it doesn't exist.
+	Load(ctx context.Context, key string) (value []byte, ok bool, err error)
+
+	// Store durably sets the value of the key. The value may be guest memory,
+	// so must be copied if retained.
+	Store(ctx context.Context, key string, value []byte) error
+}
+
+// CachedResponse is a response stored in a ResponseCache.
+type CachedResponse struct {
+	// StatusCode is the status of the response. Ex. 200
+	StatusCode uint32
+	// Header is the header of the response, excluding "Set-Cookie".
+	Header map[string][]string
+	// Body is the body of the response.
+	Body []byte
+}
+
+// ResponseCache stores responses for handler.FuncCacheLookup and
+// handler.FuncCacheStore, by keys guests compute, such as from the method,
+// path and "Accept" header. Implementations must be safe for concurrent use.
+//
+// Package responsecache includes an in-memory implementation. Hosts with
+// more than one process can implement this with a remote store such as
+// Redis or memcached.
+type ResponseCache interface {
+	// Get returns the response of the key, or false if it doesn't exist or
+	// expired.
+	Get(ctx context.Context, key string) (resp *CachedResponse, ok bool, err error)
+
+	// Set stores the response of the key. A positive ttl expires the
+	// response after that duration. Otherwise, it doesn't expire, though may
+	// be evicted. The response may refer to guest memory, so must be copied
+	// if retained.
+	Set(ctx context.Context, key string, resp *CachedResponse, ttl time.Duration) error
+}
+
+// RateLimiter backs handler.FuncRateLimitCheck, which guests use to
+// implement rate policies per key, such as per client address or API key.
+// Implementations must be safe for concurrent use.
+//
+// The default is an in-memory token bucket per key in package ratelimit.
+// Hosts with more than one process can implement this with a remote store
+// such as Redis, so that limits apply across them.
+type RateLimiter interface {
+	// Take takes tokens from the budget of the key, returning true if it had
+	// enough. Otherwise, no tokens are taken, so the request should be
+	// limited.
+	Take(ctx context.Context, key string, tokens uint32) (allowed bool, err error)
+}
+
+// Snapshotter is implemented by a SharedStore or RateLimiter whose contents
+// are in memory, such as those in packages sharedstore and ratelimit, so that
+// they can be saved on shutdown and restored on startup. See
+// httpwasm.PersistStores.
+type Snapshotter interface {
+	// Snapshot writes the contents to w, in a format read by Restore.
+	Snapshot(w io.Writer) error
+
+	// Restore reads contents written by Snapshot from r, replacing any with
+	// the same key.
+	Restore(r io.Reader) error
+}
+
+// MetricKind is the kind of a metric guests define via
+// handler.FuncDefineMetric.
+type MetricKind uint32
+
+const (
+	// MetricCounter is a value which only increases, such as the count of
+	// blocked requests.
+	MetricCounter MetricKind = iota
+	// MetricGauge is a value which increases and decreases, such as the
+	// count of requests in flight.
+	MetricGauge
+	// MetricHistogram is a distribution of recorded values, such as the
+	// size of request bodies.
+	MetricHistogram
+)
+
+// String returns the name of the kind. Ex. "counter"
+func (k MetricKind) String() string {
+	switch k {
+	case MetricCounter:
+		return "counter"
+	case MetricGauge:
+		return "gauge"
+	case MetricHistogram:
+		return "histogram"
+	}
+	return fmt.Sprintf("MetricKind(%d)", uint32(k))
+}
+
+// Metrics is the backend of metrics guests publish via
+// handler.FuncDefineMetric, such as a Prometheus registry. Implementations
+// must be safe for concurrent use.
+type Metrics interface {
+	// Define returns the metric of the kind and name, such as to register it
+	// with a Prometheus registry. The host calls this once per name, and
+	// validates the name is in the format "[a-zA-Z_:][a-zA-Z0-9_:]*".
+	Define(kind MetricKind, name string) (Metric, error)
+}
+
+// Metric is a metric returned by Metrics.Define.
+type Metric interface {
+	// Add adds the delta to a counter or gauge. The delta of a counter is
+	// never negative.
+	Add(ctx context.Context, delta int64)
+
+	// Record sets the value of a gauge, or records a value of a histogram.
+	Record(ctx context.Context, value int64)
+}
+
+// Clock is the source of time for handler.FuncGetTimeNanos and
+// handler.FuncGetMonotonicNanos. A fake implementation allows deterministic
+// tests of guests that depend on time, such as rate limiters.
+type Clock interface {
+	// Now returns the current wall clock time.
+	Now() time.Time
+
+	// Nanotime returns nanoseconds elapsed since an arbitrary point, which
+	// never decreases.
+	Nanotime() int64
+}
+
+// Modes of CompileReport.
+const (
+	// CompileModeCompiler compiles guests ahead-of-time to machine code.
+	CompileModeCompiler = "compiler"
+	// CompileModeInterpreter interprets guests, which starts faster, but
+	// runs slower.
+	CompileModeInterpreter = "interpreter"
+)
+
+// CompileReport describes compiling a guest, so that operators can find the
+// cause of slow starts.
+type CompileReport struct {
+	// Duration is how long it took to compile the guest.
+	Duration time.Duration
+
+	// Mode is CompileModeCompiler or CompileModeInterpreter, or empty if
+	// unknown, such as when using a custom httpwasm.Runtime.
+	Mode string
+
+	// CacheHit is true when the guest was loaded from the directory
+	// configured by httpwasm.CompilationCache, instead of compiled.
+	CacheHit bool
+}
+
+// FailurePolicy is what the host does when a guest can't handle a request,
+// such as when it failed to instantiate or trapped.
+type FailurePolicy uint32
+
+const (
+	// FailClosed rejects the request, favoring security over availability.
+	// This is the default.
+	FailClosed FailurePolicy = iota
+
+	// FailOpen bypasses the guest, invoking the next handler instead. This
+	// favors availability, so is only appropriate for guests that aren't
+	// security controls.
+	FailOpen
+)
+
+// ShardStrategy is how guests are assigned to the runtimes configured with
+// httpwasm.Shards.
+type ShardStrategy uint32
+
+const (
+	// ShardRoundRobin assigns each guest instantiated to the next runtime in
+	// turn, spreading load evenly. This is the default.
+	ShardRoundRobin ShardStrategy = iota
+
+	// ShardPerTenant assigns the guests of each tenant or pattern of a
+	// Registry or router to the same runtime, chosen by a hash of its name, so
+	// that tenants on different runtimes don't share compiled code or locks.
+	ShardPerTenant
+)
@@ -0,0 +1,146 @@
+package handler
+
+// Capability is a group of host functions, which can be restricted for
+// untrusted guests via httpwasm.AllowedCapabilities.
+//
+// Functions without side effects outside the guest, such as "log",
+// FuncGetConfig, FuncNext and FuncReadScratch, don't require a capability.
+type Capability string
+
+const (
+	// CapabilityRequestRead allows reading request metadata, such as via
+	// FuncReadRequestHeader, FuncGetQueryValue, FuncGetCookie and
+	// FuncGetTLSPeerCert.
+	CapabilityRequestRead Capability = "request_read"
+
+	// CapabilityRequestWrite allows changing the request passed to the next
+	// handler, such as via FuncSetQueryValue, FuncSetUpstream and
+	// FuncSetTimeoutMs.
+	CapabilityRequestWrite Capability = "request_write"
+
+	// CapabilityRequestBody allows reading the request body, such as via
+	// FuncReadRequestBody, FuncReadMultipartPart and FuncGetFormValue.
+	CapabilityRequestBody Capability = "request_body"
+
+	// CapabilityResponseRead allows reading the response status, via
+	// FuncGetStatusCode, FuncIsResponseCommitted and FuncGetResponseBodySize.
+	CapabilityResponseRead Capability = "response_read"
+
+	// CapabilityResponseWrite allows changing response metadata, such as via
+	// FuncSetResponseHeader, FuncSetStatusCode and FuncSetCookie.
+	CapabilityResponseWrite Capability = "response_write"
+
+	// CapabilityResponseBody allows reading and writing the response body,
+	// such as via FuncSendResponse and FuncReadResponseBody.
+	CapabilityResponseBody Capability = "response_body"
+
+	// CapabilityNetwork allows outbound network access, via FuncHTTPCall,
+	// FuncResolve and FuncMirrorRequest.
+	CapabilityNetwork Capability = "network"
+
+	// CapabilitySharedStore allows access to state shared across requests,
+	// via FuncGetShared, FuncSetShared, FuncCasShared, FuncRateLimitCheck,
+	// FuncReadState, FuncWriteState, FuncCacheLookup and FuncCacheStore.
+	CapabilitySharedStore Capability = "shared_store"
+
+	// CapabilityCrypto allows using keys the host manages, via FuncHMAC and
+	// FuncVerifySignature.
+	CapabilityCrypto Capability = "crypto"
+
+	// CapabilityMetrics allows publishing metrics to the host, via
+	// FuncDefineMetric, FuncIncrementMetric and FuncRecordMetric.
+	CapabilityMetrics Capability = "metrics"
+)
+
+// capabilities are the capabilities required by host functions. Functions
+// not present don't require one.
+var capabilities = map[string]Capability{
+	FuncReadRequestHeader:      CapabilityRequestRead,
+	FuncReadRequestHeaderAlloc: CapabilityRequestRead,
+	FuncReadRequestHeaders:     CapabilityRequestRead,
+	FuncReadRawRequestHeaders:  CapabilityRequestRead,
+	FuncReadQueryParams:        CapabilityRequestRead,
+	FuncNegotiateContentType:   CapabilityRequestRead,
+	FuncGetRequestOrigin:       CapabilityRequestRead,
+	FuncGetQueryValue:          CapabilityRequestRead,
+	FuncGetCookie:              CapabilityRequestRead,
+	FuncGetSourceAddr:          CapabilityRequestRead,
+	FuncGetListenerAddr:        CapabilityRequestRead,
+	FuncIsUnixSocket:           CapabilityRequestRead,
+	FuncGetProxySourceAddr:     CapabilityRequestRead,
+	FuncGetTLSVersion:          CapabilityRequestRead,
+	FuncGetTLSPeerCert:         CapabilityRequestRead,
+	FuncGetProtocolVersion:     CapabilityRequestRead,
+	FuncGetRequestTrailer:      CapabilityRequestRead,
+	FuncExtract:                CapabilityRequestRead,
+	FuncGetUpgrade:             CapabilityRequestRead,
+	FuncGetRPCService:          CapabilityRequestRead,
+	FuncGetRPCMethod:           CapabilityRequestRead,
+	FuncGetRoute:               CapabilityRequestRead,
+	FuncGetRequestID:           CapabilityRequestRead,
+	FuncGetHostValue:           CapabilityRequestRead,
+
+	FuncSetQueryValue:  CapabilityRequestWrite,
+	FuncSetUpstream:    CapabilityRequestWrite,
+	FuncSetTimeoutMs:   CapabilityRequestWrite,
+	FuncSetRetryPolicy: CapabilityRequestWrite,
+	FuncSetHostValue:   CapabilityRequestWrite,
+
+	FuncReadRequestBody:         CapabilityRequestBody,
+	FuncEnableRequestBodyChunks: CapabilityRequestBody,
+	FuncReadMultipartPart:       CapabilityRequestBody,
+	FuncGetFormValue:            CapabilityRequestBody,
+	FuncGetUploadedFileInfo:     CapabilityRequestBody,
+
+	FuncGetStatusCode:       CapabilityResponseRead,
+	FuncIsResponseCommitted: CapabilityResponseRead,
+	FuncGetResponseBodySize: CapabilityResponseRead,
+	FuncGetRequestBodySize:  CapabilityRequestRead,
+	FuncIsRequestChunked:    CapabilityRequestRead,
+
+	FuncSetResponseHeader:       CapabilityResponseWrite,
+	FuncWriteResponseHeaders:    CapabilityResponseWrite,
+	FuncSetRawResponseHeader:    CapabilityResponseWrite,
+	FuncSetCORSHeaders:          CapabilityResponseWrite,
+	FuncSetStatusCode:           CapabilityResponseWrite,
+	FuncSetCookie:               CapabilityResponseWrite,
+	FuncSetResponseTrailer:      CapabilityResponseWrite,
+	FuncSuppressInjectedHeaders: CapabilityResponseWrite,
+	FuncSetError:                CapabilityResponseWrite,
+
+	FuncSendResponse:          CapabilityResponseBody,
+	FuncSendProblem:           CapabilityResponseBody,
+	FuncSendRedirect:          CapabilityResponseBody,
+	FuncSendLocalizedResponse: CapabilityResponseBody,
+	FuncReadResponseBody:      CapabilityResponseBody,
+	FuncReadResponseBodyAlloc: CapabilityResponseBody,
+
+	FuncHTTPCall:      CapabilityNetwork,
+	FuncResolve:       CapabilityNetwork,
+	FuncMirrorRequest: CapabilityNetwork,
+
+	FuncGetShared: CapabilitySharedStore,
+	FuncSetShared: CapabilitySharedStore,
+	FuncCasShared: CapabilitySharedStore,
+
+	FuncRateLimitCheck: CapabilitySharedStore,
+
+	FuncReadState:   CapabilitySharedStore,
+	FuncWriteState:  CapabilitySharedStore,
+	FuncCacheLookup: CapabilitySharedStore,
+	FuncCacheStore:  CapabilitySharedStore,
+
+	FuncHMAC:            CapabilityCrypto,
+	FuncVerifySignature: CapabilityCrypto,
+
+	FuncDefineMetric:    CapabilityMetrics,
+	FuncIncrementMetric: CapabilityMetrics,
+	FuncRecordMetric:    CapabilityMetrics,
+}
+
+// CapabilityOf returns the capability required by the host function, or
+// false if it doesn't require one.
+func CapabilityOf(function string) (Capability, bool) {
+	c, ok := capabilities[function]
+	return c, ok
+}
@@ -2,6 +2,10 @@ package handler
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
 
 	"github.com/http-wasm/http-wasm-host-go/api"
 )
@@ -23,6 +27,287 @@ type Middleware[H any, N api.Closer] interface {
 	api.Closer
 }
 
+// The following are optional interfaces of a Middleware, which callers check
+// with a type assertion, as not every implementation supports them. For
+// example, a middleware which runs guests in other processes can't read
+// their custom sections.
+
+// CustomSectionReader is a Middleware which reads custom sections of its
+// guest.
+type CustomSectionReader interface {
+	// CustomSection returns the data of the first custom section in the guest
+	// with the given name, or false if there is none. This doesn't execute
+	// the guest, so is safe to use for displaying provenance, such as the
+	// ABI version in CustomSectionABI.
+	CustomSection(name string) ([]byte, bool)
+}
+
+// CompileReporter is a Middleware which compiles its guest in this process.
+type CompileReporter interface {
+	// CompileReport returns how the guest was compiled, such as how long it
+	// took, for diagnosing slow starts.
+	CompileReport() api.CompileReport
+}
+
+// GuestMetadataReader is a Middleware which describes its guest.
+type GuestMetadataReader interface {
+	// GuestMetadata describes the guest from its custom sections, such as to
+	// inventory deployed guests. Like CustomSection, this doesn't execute
+	// the guest.
+	GuestMetadata() GuestMetadata
+}
+
+// CanaryRollout is a Middleware which can gradually replace its guest.
+type CanaryRollout interface {
+	// SetCanary gradually rolls out a new version of the guest, handling the
+	// percent of requests with it, and the rest with the current guest. A
+	// percent of zero removes the canary, and 100 or more uses it for all
+	// requests. The guest compiles with the same options as the current one.
+	//
+	// Requests are assigned by a hash of their ID, if any, so that retries
+	// of a request with the same "X-Request-Id" use the same guest. See
+	// httpwasm.RequestID. Use CanaryStats, or the Canary field of GuestInfo
+	// in logs, to compare the guests before promoting the canary.
+	SetCanary(ctx context.Context, guest []byte, percent int) error
+
+	// CanaryStats returns counts of requests handled by each guest since the
+	// last call to SetCanary.
+	CanaryStats() CanaryStats
+}
+
+// ConfigUpdater is a Middleware which can replace the configuration of its
+// guest while running.
+type ConfigUpdater interface {
+	// UpdateConfig replaces the configuration guests read via FuncGetConfig,
+	// such as to update a blocklist, without re-instantiating them or
+	// disrupting requests. Each guest calls FuncOnConfigUpdate, if exported,
+	// before the next request it handles. The config is validated the same
+	// way as httpwasm.GuestConfigBytes, and on error, the current config remains.
+	//
+	// The update also applies to guests reloaded or rolled out with
+	// SetCanary later, but not to httpwasm.GuestConfigCanary.
+	UpdateConfig(config []byte) error
+}
+
+// Pinger is a Middleware which can check it is ready to handle requests.
+type Pinger interface {
+	// Ping returns an error unless a guest can be instantiated, and it
+	// doesn't trap in FuncPing, if exported. This is intended for readiness
+	// probes.
+	Ping(ctx context.Context) error
+}
+
+// GracefulCloser is a Middleware which can wait for requests in flight
+// before closing.
+type GracefulCloser interface {
+	// CloseGracefully is like Close, except it first waits up to timeout, or
+	// until the context is done, for requests in flight to finish. Requests
+	// still in flight are then interrupted, and handled according to
+	// httpwasm.FailurePolicy.
+	CloseGracefully(ctx context.Context, timeout time.Duration) error
+}
+
+// Handler is an instance of a guest, which handles one request at a time.
+// Hosts that manage instances themselves, instead of using those returned by
+// Middleware.NewHandler, can share one between goroutines, as Handle returns
+// ErrGuestInUse instead of corrupting the memory of the guest.
+type Handler interface {
+	// Handle handles the current request, which is in the context, invoking
+	// the next handler unless the guest obviated it. When the guest traps,
+	// the host responds according to its configuration, then this returns a
+	// GuestError.
+	Handle(ctx context.Context) error
+
+	// Info identifies the guest, which is valid until it is closed.
+	Info() *GuestInfo
+
+	api.Closer
+}
+
+// ErrQuotaExceeded is the cause of a GuestError when the guest produced more
+// than a quota allows, such as httpwasm.MaxHeaderMutations, unless it imports
+// FuncGetLastError to handle an Errno for the quota, such as
+// ErrnoHeaderMutationsExceeded, itself.
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// ErrInvalidHeader is the cause of a GuestError when the guest set a response
+// header or trailer which isn't valid HTTP, such as a name with spaces or a
+// value with a line break, or is longer than allowed by
+// httpwasm.MaxHeaderNameBytes or httpwasm.MaxHeaderValueBytes.
+//
+// The host functions which set headers, such as FuncSetResponseHeader and
+// FuncWriteResponseHeaders, have no result, so they trap by default and the
+// host responds according to httpwasm.FailurePolicy. A guest which imports
+// FuncGetLastError instead reads an Errno, such as ErrnoInvalidHeaderName,
+// and the header isn't set.
+var ErrInvalidHeader = errors.New("invalid header")
+
+// ErrBodyTooLarge is the cause of a GuestError when the guest read a request
+// body larger than allowed by httpwasm.MaxBodyBuffer, in which case the host
+// rejected the request with 413 Content Too Large. Likewise, when a response
+// body decoded for FeatureDecodeResponse is larger, the host responds with
+// 502 Bad Gateway.
+var ErrBodyTooLarge = errors.New("body too large")
+
+// ErrGuestInUse is returned by Handler.Handle when the guest is already
+// handling a request on another goroutine. The request isn't handled, so the
+// caller should respond, such as with 503 Service Unavailable, or retry with
+// another guest.
+var ErrGuestInUse = errors.New("wasm: guest is already handling a request")
+
+// GuestError is returned when the guest traps, such as executing an
+// "unreachable" instruction or calling a host function with invalid memory,
+// or when FuncHandle returns an error code which isn't a status code.
+// When returned by a guest handler, the host already responded according to
+// its configuration, such as with 500 Internal Server Error.
+type GuestError struct {
+	// Func is the function exported by the guest which trapped. Ex.
+	// FuncHandle
+	Func string
+	// Err is the cause of the trap.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *GuestError) Error() string {
+	return fmt.Sprintf("wasm: guest trapped in %s: %v", e.Func, e.Err)
+}
+
+// Unwrap returns the cause of the trap.
+func (e *GuestError) Unwrap() error {
+	return e.Err
+}
+
+// ErrMissingHandleExport is the cause of an InvalidGuestError when the guest
+// exports neither FuncHandle nor FuncHandleRequest.
+var ErrMissingHandleExport = errors.New("missing handle export")
+
+// ErrBadHandleSignature is the cause of an InvalidGuestError when the guest
+// exports a function the host calls with the wrong signature, such as
+// FuncHandle, or exports both FuncHandle and FuncHandleRequest.
+var ErrBadHandleSignature = errors.New("bad handle signature")
+
+// ErrMissingMemory is the cause of an InvalidGuestError when the guest
+// neither exports its memory, conventionally named api.Memory, nor imports
+// one the host can provide.
+var ErrMissingMemory = errors.New("missing memory export")
+
+// ErrInvalidImports is the cause of an InvalidGuestError when the guest
+// imports functions the host doesn't implement, imports them with the wrong
+// signature, or imports functions whose capability isn't allowed by
+// httpwasm.AllowedCapabilities.
+var ErrInvalidImports = errors.New("invalid imports")
+
+// InvalidGuestError is returned when creating middleware with a guest which
+// doesn't implement the ABI, as opposed to failures of the host, such as
+// running out of memory. Use errors.Is to check for a specific cause, such as
+// ErrMissingMemory.
+type InvalidGuestError struct {
+	// Err is the cause. Ex. ErrMissingHandleExport
+	Err error
+	// Detail describes the problem. Ex. "guest doesn't export memory[memory]"
+	Detail string
+}
+
+// Error implements the error interface.
+func (e *InvalidGuestError) Error() string {
+	return "wasm: " + e.Detail
+}
+
+// Unwrap returns the cause.
+func (e *InvalidGuestError) Unwrap() error {
+	return e.Err
+}
+
+// RetryPolicy is how a proxy host retries the request when the upstream
+// fails, set via FuncSetRetryPolicy.
+type RetryPolicy struct {
+	// Attempts is the maximum attempts, including the first.
+	Attempts uint32
+	// Backoff is the time to wait between attempts.
+	Backoff time.Duration
+}
+
+// HeaderField is a header as received or sent, such as returned by
+// RawHeaders.GetRawRequestHeaders. Unlike http.Header, the name isn't
+// canonicalized. Ex. {Name: "x-amz-date", Value: "20130524T000000Z"}
+type HeaderField struct {
+	Name, Value string
+}
+
+// FileInfo describes a file uploaded in a multipart request body, returned by
+// Forms.GetUploadedFileInfo.
+type FileInfo struct {
+	// Filename is the filename the client sent, which isn't sanitized.
+	// Ex. "photo.jpg"
+	Filename string
+	// ContentType is the content type the client sent for the file, or empty
+	// if none. Ex. "image/jpeg"
+	ContentType string
+	// Size is the length of the file content in bytes.
+	Size uint64
+}
+
+// GuestInfo identifies the guest handling a request, so that its behavior can
+// be attributed to a specific build, such as in logs.
+type GuestInfo struct {
+	// Module is the name of the guest module, from its name section, or
+	// empty if it has none.
+	Module string
+	// Instance distinguishes instances of the same guest, numbered from one
+	// in the order they were instantiated.
+	Instance uint64
+	// Digest is the SHA-256 digest of the guest binary, in the format
+	// "sha256:" followed by lowercase hex.
+	Digest string
+	// Canary is true when the guest is the canary set via
+	// Middleware.SetCanary.
+	Canary bool
+}
+
+// CanaryStats compares the guests of a rollout started by
+// Middleware.SetCanary.
+type CanaryStats struct {
+	// Stable is the stats of the current guest.
+	Stable VariantStats
+	// Canary is the stats of the canary.
+	Canary VariantStats
+}
+
+// VariantStats are the stats of one guest in CanaryStats.
+type VariantStats struct {
+	// Requests counts requests handled by the guest.
+	Requests uint64
+	// Errors counts requests where the guest trapped. See GuestError.
+	Errors uint64
+}
+
+// GuestMetadata describes a guest from its custom sections, so that operators
+// can inventory deployed guests, or reject them by policy. Fields are empty
+// when the guest doesn't define them.
+type GuestMetadata struct {
+	// Module is the name of the guest module, from its name section.
+	Module string
+	// Producers are the tools which produced the guest, from its producers
+	// section, keyed by field. Ex. "language", "processed-by" or "sdk"
+	Producers map[string][]Producer
+	// Version is the version of the guest, from CustomSectionMeta.
+	// Ex. "1.2.0"
+	Version string
+	// Author is the author of the guest, from CustomSectionMeta.
+	Author string
+	// RequiredFeatures are the features the guest requires, from
+	// CustomSectionMeta.
+	RequiredFeatures Features
+}
+
+// Producer is a tool which produced a guest. Ex. Name "TinyGo" and Version
+// "0.26.0" in the "processed-by" field.
+type Producer struct {
+	Name    string
+	Version string
+}
+
 // Host implements the host side of the WebAssembly module named HostModule.
 // These callbacks are used by the guest function export FuncHandle.
 type Host interface {
@@ -30,6 +315,11 @@ type Host interface {
 	// FuncReadRequestHeader. This returns false if the value doesn't exist.
 	GetRequestHeader(ctx context.Context, name string) (string, bool)
 
+	// GetRequestHeaders supports the WebAssembly function export
+	// FuncReadRequestHeaders, returning all values of all request headers by
+	// name. The result must not be modified.
+	GetRequestHeaders(ctx context.Context) map[string][]string
+
 	// SetResponseHeader implements the WebAssembly function export
 	// FuncSetResponseHeader.
 	SetResponseHeader(ctx context.Context, name, value string)
@@ -42,4 +332,261 @@ type Host interface {
 	// which sends the current response with the given status code and optional
 	// body.
 	SendResponse(ctx context.Context, statusCode uint32, body []byte)
+
+	// GetMethod returns the method of the request, such as to detect CORS
+	// preflight requests for FuncSetCORSHeaders. Ex. "OPTIONS"
+	GetMethod(ctx context.Context) string
+
+	// GetQueryParams supports the WebAssembly function export
+	// FuncReadQueryParams, returning all decoded query parameters, or nil if
+	// there are none.
+	GetQueryParams(ctx context.Context) map[string][]string
+
+	// SetQueryValue implements the WebAssembly function export
+	// FuncSetQueryValue.
+	SetQueryValue(ctx context.Context, name, value string)
+
+	// GetRequestBodySize implements the WebAssembly function export
+	// FuncGetRequestBodySize. This returns -1 if the size is unknown.
+	GetRequestBodySize(ctx context.Context) int64
+
+	// GetRequestBody supports the WebAssembly function export FuncExtract,
+	// returning the request body. The body must remain readable by the next
+	// handler, so may need to be buffered.
+	GetRequestBody(ctx context.Context) []byte
+
+	// ReadRequestBody supports the WebAssembly function export
+	// FuncReadRequestBody. This calls alloc with the length of the body, and
+	// reads the body into the result, which is guest memory. The result is
+	// the length of the body, in which case alloc isn't called if zero.
+	//
+	// The next handler may read the body from the guest memory.
+	ReadRequestBody(ctx context.Context, alloc func(size uint32) []byte) uint32
+
+	// GetStatusCode implements the WebAssembly function export
+	// FuncGetStatusCode.
+	GetStatusCode(ctx context.Context) uint32
+
+	// SetStatusCode implements the WebAssembly function export
+	// FuncSetStatusCode, which does nothing if the response was committed.
+	SetStatusCode(ctx context.Context, statusCode uint32)
+
+	// IsResponseCommitted implements the WebAssembly function export
+	// FuncIsResponseCommitted.
+	IsResponseCommitted(ctx context.Context) bool
+
+	// GetResponseHeaders supports the WebAssembly function export
+	// FuncCacheStore, returning the response headers set so far.
+	GetResponseHeaders(ctx context.Context) map[string][]string
+
+	// GetResponseBodySize implements the WebAssembly function export
+	// FuncGetResponseBodySize.
+	GetResponseBodySize(ctx context.Context) uint64
+
+	// GetResponseBody supports the WebAssembly function export
+	// FuncReadResponseBody, returning the response body buffered due to
+	// FeatureBufferResponse, or nil if not buffered. This panics with
+	// ErrBodyTooLarge if the body is too large to decode for
+	// FeatureDecodeResponse.
+	GetResponseBody(ctx context.Context) []byte
+
+	// BeforeCommit registers a function to call once, immediately before the
+	// current response is committed. If nothing committed the response by
+	// the time the guest returns, the host commits it then.
+	BeforeCommit(ctx context.Context, fn func())
+
+	// EnableFeatures supports the WebAssembly function export
+	// FuncEnableFeatures, enabling features for the current request. This
+	// returns all features enabled, excluding any the host doesn't support.
+	EnableFeatures(ctx context.Context, features Features) Features
+
+	// Capabilities supports the WebAssembly function export
+	// FuncCapabilities, returning the features this host supports. Features
+	// implemented by the runtime, such as FeatureSharedStore, are excluded.
+	Capabilities(ctx context.Context) Features
+}
+
+// The following are optional interfaces of a Host, which the runtime checks
+// with a type assertion, so that a host only implements what it supports. If
+// a host doesn't implement one, the functions of the ABI it supports behave
+// as if the value is unknown, such as reading an empty string, and ignore
+// changes, unless documented otherwise. Functions which read a single value,
+// such as FuncGetCookie and FuncGetQueryValue, are implemented by the runtime
+// with the methods above.
+
+// ConnectionInfo is a Host which describes the connection the request was
+// received on.
+type ConnectionInfo interface {
+	// GetSourceAddr supports the WebAssembly function export
+	// FuncGetSourceAddr, returning the network address of the client.
+	GetSourceAddr(ctx context.Context) string
+
+	// GetListenerAddr supports the WebAssembly function export
+	// FuncGetListenerAddr, returning the local address of the listener, or
+	// empty if unknown.
+	GetListenerAddr(ctx context.Context) string
+
+	// IsUnixSocket supports the WebAssembly function export
+	// FuncIsUnixSocket.
+	IsUnixSocket(ctx context.Context) bool
+
+	// GetProxySourceAddr supports the WebAssembly function export
+	// FuncGetProxySourceAddr. This returns false if the connection had no
+	// PROXY protocol source.
+	GetProxySourceAddr(ctx context.Context) (string, bool)
+
+	// GetTLSVersion implements the WebAssembly function export
+	// FuncGetTLSVersion. This returns zero if the request wasn't received
+	// over TLS.
+	GetTLSVersion(ctx context.Context) uint32
+
+	// GetTLSPeerCert supports the WebAssembly function export
+	// FuncGetTLSPeerCert, returning the DER encoding of the client
+	// certificate, or nil if there is none.
+	GetTLSPeerCert(ctx context.Context) []byte
+
+	// GetProtocolVersion supports the WebAssembly function export
+	// FuncGetProtocolVersion, returning the protocol of the request.
+	// Ex. "HTTP/2.0"
+	GetProtocolVersion(ctx context.Context) string
+
+	// IsRequestChunked implements the WebAssembly function export
+	// FuncIsRequestChunked.
+	IsRequestChunked(ctx context.Context) bool
+}
+
+// RequestInfo is a Host which identifies and routes requests.
+type RequestInfo interface {
+	// GetRPC supports the WebAssembly function exports FuncGetRPCService and
+	// FuncGetRPCMethod, returning the service and method of a gRPC,
+	// gRPC-Web or Connect request, or empty strings if the request isn't
+	// one. Ex. "acme.v1.Greeter", "SayHello"
+	GetRPC(ctx context.Context) (service, method string)
+
+	// GetRoute supports the WebAssembly function export FuncGetRoute,
+	// returning the route pattern the request matched, or empty if unknown.
+	// Ex. "GET /users/{id}"
+	GetRoute(ctx context.Context) string
+
+	// GetRequestID supports the WebAssembly function export
+	// FuncGetRequestID, returning the ID the host assigned the request, or
+	// empty if none.
+	GetRequestID(ctx context.Context) string
+}
+
+// Proxy is a Host whose next handler forwards requests upstream, such as a
+// reverse proxy.
+type Proxy interface {
+	// SetUpstream implements the WebAssembly function export
+	// FuncSetUpstream. The upstream is a valid host and optional port.
+	SetUpstream(ctx context.Context, upstream string)
+
+	// SetTimeout implements the WebAssembly function export FuncSetTimeoutMs.
+	// A zero timeout removes any previously set.
+	SetTimeout(ctx context.Context, timeout time.Duration)
+
+	// SetRetryPolicy implements the WebAssembly function export
+	// FuncSetRetryPolicy.
+	SetRetryPolicy(ctx context.Context, policy RetryPolicy)
+
+	// MirrorRequest supports the WebAssembly function export
+	// FuncMirrorRequest, which sends a copy of the current request, including
+	// its body, to the URL without waiting for a response. The path and query
+	// of the current request are appended to the URL. This is best effort, so
+	// the host may drop the copy, such as when too many are in flight.
+	MirrorRequest(ctx context.Context, url string)
+}
+
+// CookieSetter is a Host which can add cookies to the response.
+type CookieSetter interface {
+	// SetCookie implements the WebAssembly function export FuncSetCookie. The
+	// attributes are in "Set-Cookie" format. Ex. "Path=/; HttpOnly"
+	SetCookie(ctx context.Context, name, value, attrs string)
+}
+
+// RawHeaders is a Host which preserves headers as received. Without it,
+// SetRawResponseHeader falls back to SetResponseHeader.
+type RawHeaders interface {
+	// GetRawRequestHeaders supports the WebAssembly function export
+	// FuncReadRawRequestHeaders, returning the request headers as received,
+	// in order and without canonicalizing names, or nil if the host didn't
+	// preserve them.
+	GetRawRequestHeaders(ctx context.Context) []HeaderField
+
+	// SetRawResponseHeader supports the WebAssembly function export
+	// FuncSetRawResponseHeader. This is like SetResponseHeader, except the
+	// name is sent as-is, instead of canonicalized.
+	SetRawResponseHeader(ctx context.Context, name, value string)
+}
+
+// Forms is a Host which parses form request bodies.
+type Forms interface {
+	// GetMultipartPart supports the WebAssembly function export
+	// FuncReadMultipartPart. This returns false if the part doesn't exist.
+	GetMultipartPart(ctx context.Context, name string) ([]byte, bool)
+
+	// GetFormValue supports the WebAssembly function export
+	// FuncGetFormValue. This returns false if the field doesn't exist.
+	GetFormValue(ctx context.Context, name string) (string, bool)
+
+	// GetUploadedFileInfo supports the WebAssembly function export
+	// FuncGetUploadedFileInfo. This returns false if there's no file with
+	// the form name.
+	GetUploadedFileInfo(ctx context.Context, name string) (FileInfo, bool)
+}
+
+// Trailers is a Host which reads request trailers and sends response
+// trailers.
+type Trailers interface {
+	// GetRequestTrailer implements the WebAssembly function export
+	// FuncGetRequestTrailer. This returns false if the value doesn't exist.
+	GetRequestTrailer(ctx context.Context, name string) (string, bool)
+
+	// SetResponseTrailer implements the WebAssembly function export
+	// FuncSetResponseTrailer.
+	SetResponseTrailer(ctx context.Context, name, value string)
+}
+
+// Properties is a Host which keeps properties of the request.
+type Properties interface {
+	// GetProperties supports the WebAssembly function export
+	// FuncReadProperties, returning all properties of the request, or nil if
+	// there are none.
+	GetProperties(ctx context.Context) map[string]string
+
+	// SetProperty implements the WebAssembly function export
+	// FuncSetProperty.
+	SetProperty(ctx context.Context, name, value string)
+}
+
+// Scratch is a Host which keeps a scratch area of the request.
+type Scratch interface {
+	// ReadScratch supports the WebAssembly function export FuncReadScratch,
+	// returning the scratch area of the current request.
+	ReadScratch(ctx context.Context) []byte
+
+	// WriteScratch supports the WebAssembly function export
+	// FuncWriteScratch, replacing the scratch area of the current request.
+	// The data is only valid during this call, so must be copied.
+	WriteScratch(ctx context.Context, data []byte)
+}
+
+// RequestBodyStreamer is a Host which can stream the request body through
+// the guest. Without it, FuncEnableRequestBodyChunks traps.
+type RequestBodyStreamer interface {
+	// StreamRequestBody supports the WebAssembly function export
+	// FuncEnableRequestBodyChunks. This replaces the request body with one
+	// that passes each chunk, not larger than chunkLimit bytes, through
+	// onChunk as the next handler reads it. The last call has eos true. The
+	// result of onChunk is only valid until the next call.
+	StreamRequestBody(ctx context.Context, chunkLimit uint32, onChunk func(chunk []byte, eos bool) []byte)
+}
+
+// RequestBodyReaderAt is an optional interface of a Host which can read the
+// request body without holding it all in memory, such as when it was spilled
+// to a file due to httpwasm.SpillRequestBodies.
+type RequestBodyReaderAt interface {
+	// GetRequestBodyReaderAt is like Host.GetRequestBody, except it returns
+	// a reader of the body and its size in bytes.
+	GetRequestBodyReaderAt(ctx context.Context) (io.ReaderAt, int64)
 }
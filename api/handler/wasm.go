@@ -15,10 +15,245 @@ const (
 	//
 	// # Result
 	//
-	// There is no result from this function. A guest who fails to handle the
-	// request will trap ("unreachable" instruction).
+	// There is either no result from this function, or a result of type i32,
+	// which is zero on success, or otherwise an error code:
+	//
+	//   - 400-599: the guest aborted. Unless the response was committed, the
+	//     host responds with this status code and no body. FuncHandleResponse
+	//     isn't called.
+	//   - other: the guest failed. The host handles this like a trap,
+	//     responding according to its configuration, such as with 500
+	//     Internal Server Error.
+	//
+	// This allows guests to choose the response to a failure, such as 401
+	// Unauthorized, in one step. A guest who fails to handle the request may
+	// also trap ("unreachable" instruction).
 	FuncHandle = "handle"
 
+	// FuncHandleRequest is an alternative to FuncHandle the guest exports to
+	// handle only the request. After it returns, the host invokes the next
+	// handler unless the guest stopped, so guests needn't call FuncNext or
+	// keep state across it. The guest handles the response, if at all, via
+	// FuncHandleResponse. A guest exports either this or FuncHandle.
+	//
+	// # Parameters
+	//
+	// There are no parameters
+	//
+	// # Result
+	//
+	// The result is of type i32: non-zero to continue to the next handler, or
+	// zero to stop, such as after FuncSendResponse. The host doesn't invoke
+	// the next handler twice if the guest called FuncNext. A guest who fails
+	// to handle the request will trap ("unreachable" instruction).
+	FuncHandleRequest = "handle_request"
+
+	// FuncHandleResponse is an optional function the guest exports to
+	// intercept the response, called after FuncHandle or FuncHandleRequest
+	// returns. Hosts detect whether the guest exports this when compiling it,
+	// and skip this phase when it doesn't.
+	//
+	// # Parameters
+	//
+	// There are no parameters
+	//
+	// # Result
+	//
+	// There is no result from this function. A guest who fails to handle the
+	// response will trap ("unreachable" instruction).
+	FuncHandleResponse = "handle_response"
+
+	// FuncInit is an optional function the guest exports to initialize
+	// itself once per instance, such as to parse its configuration with
+	// FuncGetConfig. Hosts call this after instantiating the guest, which
+	// includes running "_start", and before the first FuncHandle.
+	//
+	// # Parameters
+	//
+	// There are no parameters
+	//
+	// # Result
+	//
+	// There is no result from this function. A guest who fails to initialize
+	// will trap ("unreachable" instruction), in which case the host fails to
+	// create the handler.
+	//
+	// # Notes
+	//
+	// There is no current request, so the guest must not call functions that
+	// read or write it, such as FuncReadRequestHeader.
+	FuncInit = "init"
+
+	// FuncShutdown is an optional function the guest exports to flush state,
+	// such as buffered metrics, before the host discards the instance. Hosts
+	// call this once per instance, when its handler or middleware is closed,
+	// including when the guest is reloaded.
+	//
+	// # Parameters
+	//
+	// There are no parameters
+	//
+	// # Result
+	//
+	// There is no result from this function. A trap is logged, but otherwise
+	// ignored.
+	//
+	// # Notes
+	//
+	// Like FuncInit, there is no current request.
+	FuncShutdown = "shutdown"
+
+	// FuncPing is an optional function the guest exports to check its
+	// health, such as for a readiness probe. Hosts call this on
+	// Middleware.Ping, after FuncInit.
+	//
+	// # Parameters
+	//
+	// There are no parameters
+	//
+	// # Result
+	//
+	// There is no result from this function. A guest who is unhealthy will
+	// trap ("unreachable" instruction).
+	//
+	// # Notes
+	//
+	// Like FuncInit, there is no current request.
+	FuncPing = "ping"
+
+	// FuncOnConfigUpdate is an optional function the guest exports to apply
+	// configuration replaced by ConfigUpdater.UpdateConfig, such as an updated
+	// blocklist, without being re-instantiated. Hosts call this once per
+	// instance after each update, before the next FuncHandle, so the guest
+	// can parse the new configuration with FuncGetConfig.
+	//
+	// # Parameters
+	//
+	// There are no parameters
+	//
+	// # Result
+	//
+	// There is no result from this function. A guest who fails to apply the
+	// configuration will trap ("unreachable" instruction), in which case the
+	// host handles the request as if FuncHandle trapped.
+	//
+	// # Notes
+	//
+	// Like FuncInit, there is no current request.
+	FuncOnConfigUpdate = "on_config_update"
+
+	// FuncOnTick is an optional function the guest exports to do periodic
+	// work in the background, such as refreshing a cached token or
+	// blocklist, instead of lazily when handling requests. Hosts call this
+	// on a timer scheduled with FuncScheduleTick, on an instance dedicated to
+	// ticking, so the guest should store what it refreshes where all
+	// instances can read it, such as with FuncSetShared.
+	//
+	// # Parameters
+	//
+	// There are no parameters
+	//
+	// # Result
+	//
+	// There is no result from this function. A trap is logged, and the host
+	// replaces the instance before the next tick.
+	//
+	// # Notes
+	//
+	// Like FuncInit, there is no current request.
+	FuncOnTick = "on_tick"
+
+	// FuncHandleRequestBodyChunk is an optional function the guest exports to
+	// scan or transform the request body in chunks, as the next handler reads
+	// it. The host only calls this after the guest calls
+	// FuncEnableRequestBodyChunks, which designates the memory the host
+	// writes each chunk to. This allows a guest to process large bodies with
+	// bounded memory.
+	//
+	// # Parameters
+	//
+	// Both parameters are of type i32.
+	//
+	//   - chunk_len: possibly zero length in bytes of the chunk the host
+	//     wrote to memory. This is not larger than `buf_limit`.
+	//   - end_of_stream: one if this is the last chunk or zero if not.
+	//
+	// # Result
+	//
+	// The result is `chunk_len` of type i32: the length in bytes of the chunk
+	// to pass to the next handler, read from the same memory. This is the
+	// parameter `chunk_len` when scanning or a different length, not larger
+	// than `buf_limit`, when transforming. A guest who fails to handle the
+	// chunk will trap ("unreachable" instruction).
+	FuncHandleRequestBodyChunk = "handle_request_body_chunk"
+
+	// FuncMalloc is an optional function the guest exports to allocate
+	// memory the host writes to, such as by FuncReadRequestBody. This allows
+	// the host to write large values without the guest guessing a buffer
+	// size.
+	//
+	// # Parameters
+	//
+	// The only parameter is `size` of type i32: the length in bytes to
+	// allocate.
+	//
+	// # Result
+	//
+	// The result is `ptr` of type i32: the memory offset of the allocation.
+	// A guest who fails to allocate memory will trap ("unreachable"
+	// instruction).
+	FuncMalloc = "malloc"
+
+	// FuncFree is an optional function the guest exports to release memory
+	// allocated with FuncMalloc. Memory the host returns to the guest, such
+	// as by FuncReadRequestBody, is owned by the guest, which frees it when
+	// done. The host only calls this to release an allocation it couldn't
+	// return, such as when reading the request body failed.
+	//
+	// # Parameters
+	//
+	// The only parameter is `ptr` of type i32: the memory offset returned by
+	// FuncMalloc.
+	//
+	// # Result
+	//
+	// There is no result from this function.
+	FuncFree = "free"
+
+	// FuncGetConfig writes configuration from the host to memory if it isn't
+	// larger than the buffer size limit. The result is the length of the
+	// config in bytes.
+	//
+	// Config is opaque to the host. However, a guest can publish a schema for
+	// it in the custom section named CustomSectionConfigSchema.
+	//
+	// Hosts may choose between config variants per request, for example to
+	// canary a config change. The config is the same for each call during a
+	// request, so a guest can read it in parts.
+	//
+	// # Parameters
+	//
+	// All parameters are of type i32. They describe a buffer to write the
+	// config.
+	//
+	//   - buf: memory offset to write the config, if not larger than
+	//     `buf_limit` bytes.
+	//   - buf_limit: possibly zero maximum length in bytes to write. If the
+	//     result `config_len` is larger, nothing is written to memory.
+	//
+	// # Result
+	//
+	// The result is `config_len` of type i32: the possibly zero length in
+	// bytes of the config. A host who fails to write the config will trap
+	// ("unreachable" instruction).
+	//
+	// # Example
+	//
+	// For example, if the config is `{"realm":"test"}`, the result is 16. If
+	// the `buf_limit` parameter was 8, nothing would be written to memory. The
+	// caller would decide whether to retry the request with a higher limit.
+	FuncGetConfig = "get_config"
+
 	// FuncReadRequestHeader writes a header value to memory if it exists and
 	// isn't larger than the buffer size limit. The result is `1<<32|value_len`
 	// or zero if the header doesn't exist.
@@ -112,6 +347,15 @@ const (
 	// There is no result from this function. A host who fails to set a value
 	// will trap ("unreachable" instruction).
 	//
+	// An invalid header is such a failure: a name which isn't an HTTP token,
+	// a value with control characters, such as a line break, or either longer
+	// than the host allows. The host traps, so that a guest can't ignore it
+	// and send a response with a header missing, such as
+	// "Content-Security-Policy", unless the guest imports FuncGetLastError to
+	// check for an Errno instead. Guests which set headers from untrusted
+	// input, such as the request, must validate it or check the error. See
+	// ErrInvalidHeader.
+	//
 	// # Example
 	//
 	// For example, if parameters are name=1, name_len=4, value=8, value_len=1,
@@ -128,6 +372,10 @@ const (
 	// FuncNext is an alternative to FuncSendResponse that dispatches control
 	// to the next HTTP handler.
 	//
+	// Note: This does nothing when the response was already committed, for
+	// example by FuncSendResponse. Hence, a guest that sends a response
+	// short-circuits the next handler. See FuncIsResponseCommitted.
+	//
 	// # Parameters
 	//
 	// There are no parameters
@@ -138,6 +386,24 @@ const (
 	// the next handler will trap ("unreachable" instruction).
 	FuncNext = "next"
 
+	// FuncIsClientGone returns one if the client disconnected, or the request
+	// was otherwise canceled, such as by a timeout. Guests doing expensive
+	// work, such as scanning a large request body, can call this periodically
+	// to abort early, as there is no one to respond to.
+	//
+	// # Parameters
+	//
+	// There are no parameters
+	//
+	// # Result
+	//
+	// The result is one if the client is gone, or zero if not.
+	//
+	// Note: When the host interrupts guests on disconnect, guests trap on
+	// their next call to any other host function. This function never traps,
+	// so guests can check it to clean up first.
+	FuncIsClientGone = "is_client_gone"
+
 	// FuncSendResponse is an alternative to FuncHandle that sends the HTTP
 	// response with a given status code and optional body.
 	//
@@ -165,4 +431,1652 @@ const (
 	// this function would send the HTTP status code 401 with no body or
 	// "Content-Length" header.
 	FuncSendResponse = "send_response"
-)
+
+	// FuncMirrorRequest sends a copy of the current request, including its
+	// body, to a destination the host configured with the given name. This
+	// doesn't wait for a response, so doesn't delay the current request.
+	//
+	// # Parameters
+	//
+	// All parameters are of type i32. They contain the UTF-8 name of the
+	// destination.
+	//
+	//   - name: memory offset to read the destination name.
+	//   - name_len: length of the destination name in bytes.
+	//
+	// # Result
+	//
+	// The result is of type i32: one if the request was mirrored or zero if
+	// the host has no destination with that name. A host who fails to copy
+	// the request will trap ("unreachable" instruction).
+	//
+	// # Example
+	//
+	// For example, if the host configured the destination "shadow" as
+	// "http://shadow:8080", a request to "/users?id=1" would be copied to
+	// "http://shadow:8080/users?id=1".
+	FuncMirrorRequest = "mirror_request"
+
+	// FuncGetStatusCode returns the status code of the current response. This
+	// is the status code committed, if the response was, otherwise the one
+	// set by FuncSetStatusCode, or 200 if neither.
+	//
+	// # Parameters
+	//
+	// There are no parameters
+	//
+	// # Result
+	//
+	// The result is the `status_code` of type i32. Ex. 200
+	FuncGetStatusCode = "get_status_code"
+
+	// FuncSetStatusCode overrides the status code of the current response,
+	// unless it was already committed. The status code is sent when the
+	// response is committed, unless the next handler chooses its own.
+	//
+	// # Parameters
+	//
+	// The only parameter is `status_code` of type i32. Ex. 404
+	//
+	// # Result
+	//
+	// There is no result from this function. This does nothing if the
+	// response was already committed. See FuncIsResponseCommitted.
+	FuncSetStatusCode = "set_status_code"
+
+	// FuncIsResponseCommitted returns whether the status code and headers of
+	// the current response were sent, for example by FuncSendResponse or the
+	// next handler. Once committed, they can no longer change.
+	//
+	// # Parameters
+	//
+	// There are no parameters
+	//
+	// # Result
+	//
+	// The result is of type i32: one if the response was committed or zero if
+	// not.
+	FuncIsResponseCommitted = "is_response_committed"
+
+	// FuncGetQueryValue writes the first value of a query parameter to memory
+	// if it exists and isn't larger than the buffer size limit. The result is
+	// `1<<32|value_len` or zero if the parameter doesn't exist.
+	//
+	// This has the same signature and semantics as FuncReadRequestHeader,
+	// except the name is a query parameter and the value is decoded. Names
+	// are compared case-sensitively.
+	//
+	// # Example
+	//
+	// For example, if the request URI is "/users?page=2", the name "page"
+	// results in i64(1<<32 | 1) and writes "2" to memory.
+	FuncGetQueryValue = "get_query_value"
+
+	// FuncSetQueryValue sets a query parameter of the request from a name and
+	// value read from memory, replacing any existing values. The next handler
+	// sees the updated query.
+	//
+	// This has the same signature and semantics as FuncSetResponseHeader,
+	// except the name is a query parameter and the value is encoded by the
+	// host.
+	//
+	// Note: The host may re-encode the entire query, for example sorting it by
+	// name.
+	FuncSetQueryValue = "set_query_value"
+
+	// FuncGetCookie writes the value of a request cookie to memory if it
+	// exists and isn't larger than the buffer size limit. The result is
+	// `1<<32|value_len` or zero if the cookie doesn't exist.
+	//
+	// This has the same signature and semantics as FuncReadRequestHeader,
+	// except the name is a cookie parsed from the "Cookie" header by the
+	// host. Names are compared case-sensitively.
+	//
+	// # Example
+	//
+	// For example, if the request has the header "Cookie: a=1; session=abc",
+	// the name "session" results in i64(1<<32 | 3) and writes "abc" to
+	// memory.
+	FuncGetCookie = "get_cookie"
+
+	// FuncSetCookie adds a "Set-Cookie" header to the response, formatted by
+	// the host.
+	//
+	// # Parameters
+	//
+	// All parameters are of type i32. They contain the UTF-8 name, value and
+	// attributes of the cookie.
+	//
+	//   - name: memory offset to read the cookie name.
+	//   - name_len: length of the cookie name in bytes.
+	//   - value: memory offset to read the cookie value.
+	//   - value_len: possibly zero length of the cookie value in bytes.
+	//   - attrs: memory offset to read the cookie attributes.
+	//   - attrs_len: possibly zero length of the cookie attributes in bytes.
+	//
+	// Attributes are in the same format as in a "Set-Cookie" header, without
+	// the name and value. Ex. "Path=/; Max-Age=60; HttpOnly"
+	//
+	// # Result
+	//
+	// There is no result from this function. A host who fails to parse the
+	// cookie, such as due to an invalid name, will trap ("unreachable"
+	// instruction).
+	FuncSetCookie = "set_cookie"
+
+	// FuncWriteScratch replaces the scratch area of the current request with
+	// bytes read from memory. The scratch area is managed by the host and
+	// shared by all guests handling the request, such as those in a chain.
+	// This allows a guest to pass data to the next one, for example a decoded
+	// body, without encoding it into headers.
+	//
+	// # Parameters
+	//
+	// All parameters are of type i32.
+	//
+	//   - buf: memory offset to read the data.
+	//   - buf_len: possibly zero length of the data in bytes.
+	//
+	// # Result
+	//
+	// There is no result from this function.
+	//
+	// Note: The host copies the data once into a buffer it reuses for the
+	// request, and FuncReadScratch copies it once into the reading guest.
+	FuncWriteScratch = "write_scratch"
+
+	// FuncReadScratch writes the scratch area of the current request to
+	// memory if it isn't larger than the buffer size limit. The result is the
+	// length of the scratch area in bytes, which is zero until a guest calls
+	// FuncWriteScratch.
+	//
+	// This has the same signature and semantics as FuncGetConfig.
+	FuncReadScratch = "read_scratch"
+
+	// FuncGetSourceAddr writes the network address of the client that sent
+	// the request to memory if it isn't larger than the buffer size limit.
+	// The result is the length of the address in bytes. Ex. "192.0.2.1:1234"
+	//
+	// This has the same signature and semantics as FuncGetConfig.
+	//
+	// Note: This is the address of the peer connected to the host, which may
+	// be a proxy. It doesn't consider headers such as "X-Forwarded-For".
+	FuncGetSourceAddr = "get_source_addr"
+
+	// FuncGetListenerAddr writes the local address of the listener the
+	// request was received on to memory if it isn't larger than the buffer
+	// size limit. The result is the length of the address in bytes, which is
+	// zero if unknown. Ex. "10.0.0.1:8443" or "/run/gateway.sock"
+	//
+	// This allows guests to apply different policies by listener, such as
+	// when a gateway serves public and internal ports.
+	//
+	// This has the same signature and semantics as FuncGetConfig.
+	FuncGetListenerAddr = "get_listener_addr"
+
+	// FuncIsUnixSocket returns one if the request was received on a unix
+	// domain socket, such as from a sidecar on the same host.
+	//
+	// # Parameters
+	//
+	// There are no parameters
+	//
+	// # Result
+	//
+	// The result is one if the listener is a unix domain socket, or zero if
+	// not.
+	FuncIsUnixSocket = "is_unix_socket"
+
+	// FuncGetProxySourceAddr writes the source address a load balancer sent
+	// in the PROXY protocol header of the connection to memory if it exists
+	// and isn't larger than the buffer size limit. This is the address of the
+	// client that connected to the load balancer, whereas FuncGetSourceAddr
+	// is the address of the load balancer. The result is `1<<32|value_len`
+	// or zero if the connection had no source, such as when the host doesn't
+	// read the PROXY protocol. Ex. "192.0.2.1:1234"
+	//
+	// This has the same parameters and semantics as FuncReadRequestHeader,
+	// except without a name.
+	FuncGetProxySourceAddr = "get_proxy_source_addr"
+
+	// FuncGetTLSVersion returns the TLS version of the connection the request
+	// was received on, as defined in crypto/tls. Ex. 0x0304 for TLS 1.3
+	//
+	// # Parameters
+	//
+	// There are no parameters
+	//
+	// # Result
+	//
+	// The result is of type i32: the TLS version, or zero if the request was
+	// not received over TLS.
+	FuncGetTLSVersion = "get_tls_version"
+
+	// FuncGetTLSPeerCert writes the DER encoding of the certificate presented
+	// by the client to memory if it isn't larger than the buffer size limit.
+	// The result is the length of the certificate in bytes, which is zero if
+	// the client didn't present one.
+	//
+	// This has the same signature and semantics as FuncGetConfig. It supports
+	// authorization based on mutual TLS.
+	FuncGetTLSPeerCert = "get_tls_peer_cert"
+
+	// FuncGetProtocolVersion writes the protocol version of the request to
+	// memory if it isn't larger than the buffer size limit. The result is the
+	// length of the version in bytes. Ex. "HTTP/1.1", "HTTP/2.0" or
+	// "HTTP/3.0"
+	//
+	// This allows guests to vary behavior by protocol. For example, HTTP/2
+	// prohibits connection-specific headers, such as "Connection" and
+	// "Keep-Alive", so a guest shouldn't set them.
+	//
+	// This has the same signature and semantics as FuncGetConfig.
+	FuncGetProtocolVersion = "get_protocol_version"
+
+	// FuncGetRPCService writes the fully-qualified service of a gRPC,
+	// gRPC-Web or Connect request to memory if it isn't larger than the
+	// buffer size limit. The result is the length of the service in bytes,
+	// or zero if the request isn't an RPC. Ex. "acme.v1.Greeter"
+	//
+	// The host detects RPCs by their content type, such as
+	// "application/grpc-web+proto", or Connect headers, and parses the
+	// service and method from the path. This allows guests to authorize
+	// requests per method without parsing the path themselves.
+	//
+	// This has the same signature and semantics as FuncGetConfig.
+	FuncGetRPCService = "get_rpc_service"
+
+	// FuncGetRPCMethod is like FuncGetRPCService, except it writes the
+	// method of the RPC. Ex. "SayHello"
+	FuncGetRPCMethod = "get_rpc_method"
+
+	// FuncGetRoute writes the route pattern the request matched to memory if
+	// it isn't larger than the buffer size limit. The result is the length of
+	// the pattern in bytes, or zero if unknown. Ex. "GET /users/{id}"
+	//
+	// Guests recording metrics or authorizing requests can key on the route
+	// instead of the path, which has unbounded cardinality. For example,
+	// "/users/123" and "/users/456" both match "GET /users/{id}".
+	//
+	// When the host routes requests after the guest, the route is unknown
+	// until FuncNext returns. For example, hosts written in Go know the
+	// pattern matched by an http.ServeMux, since Go 1.23.
+	//
+	// This has the same signature and semantics as FuncGetConfig.
+	FuncGetRoute = "get_route"
+
+	// FuncGetRequestID writes the ID the host assigned the current request to
+	// memory if it isn't larger than the buffer size limit. The result is the
+	// length of the ID in bytes, or zero if the host doesn't assign IDs.
+	// Ex. "4bf92f3577b34da6a3ce929d0e0e4736"
+	//
+	// Hosts propagate the ID from the client or a tracing system, when
+	// present, and include it in their logs and metrics. Guests can include
+	// it in theirs, such as in entries emitted via FuncEmitAccessLog, to
+	// correlate them.
+	//
+	// This has the same signature and semantics as FuncGetConfig.
+	FuncGetRequestID = "get_request_id"
+
+	// FuncReadMultipartPart writes the content of a part of a multipart
+	// request body to memory if it exists and isn't larger than the buffer
+	// size limit. The result is `1<<32|part_len` or zero if the part doesn't
+	// exist, or the request body isn't multipart.
+	//
+	// This has the same signature and semantics as FuncReadRequestHeader,
+	// except the name is the form name of the part, from its
+	// "Content-Disposition" header.
+	//
+	// # Buffering
+	//
+	// The host only reads the body up to the end of the named part, so that
+	// the remaining parts stream through to the next handler untouched. For
+	// example, an upload scanner can inspect a small metadata part without
+	// buffering a large file after it. Parts before the named part are
+	// buffered, as the next handler needs to read them, too.
+	//
+	// Hence, guests should read parts in the order they appear. Once a part
+	// was read, reading it again doesn't read the body.
+	FuncReadMultipartPart = "read_multipart_part"
+
+	// FuncGetRequestTrailer writes a request trailer value to memory if it
+	// exists and isn't larger than the buffer size limit. The result is
+	// `1<<32|value_len` or zero if the trailer doesn't exist.
+	//
+	// This has the same signature and semantics as FuncReadRequestHeader,
+	// except the name is a trailer, which follows the request body.
+	//
+	// Note: Trailers are only known after the request body was read. Hence,
+	// the host may need to buffer the body, so that the next handler can
+	// read it.
+	FuncGetRequestTrailer = "get_request_trailer"
+
+	// FuncSetResponseTrailer sets a response trailer from a name and value
+	// read from memory. Trailers are sent after the response body, for
+	// example a checksum of it or a gRPC status.
+	//
+	// This has the same signature and semantics as FuncSetResponseHeader,
+	// including trapping if the trailer is invalid, except the name is a
+	// trailer. Unlike headers, trailers can be set after the response was
+	// committed.
+	FuncSetResponseTrailer = "set_response_trailer"
+
+	// FuncExtract evaluates an expression the host configured with the given
+	// name against the current request, and writes the value to memory if it
+	// exists and isn't larger than the buffer size limit. The result is
+	// `1<<32|value_len` or zero if there is no value, or no expression with
+	// that name.
+	//
+	// This has the same signature and semantics as FuncReadRequestHeader,
+	// except the name is of an expression. This allows a guest to read values
+	// a policy needs, including fields of a JSON body, without parsing them
+	// itself or making multiple host calls.
+	//
+	// # Expressions
+	//
+	// Expressions are in the format "<source>:<argument>", where the source is
+	// one of the following:
+	//
+	//   - header: the first value of a request header. Ex. "header:X-User"
+	//   - query: the first value of a query parameter. Ex. "query:page"
+	//   - cookie: the value of a request cookie. Ex. "cookie:session"
+	//   - json: the value at a path of a JSON request body, which supports
+	//     object keys and array indexes. Ex. "json:$.items[0].id"
+	//
+	// JSON strings are written unquoted, null is treated as no value and
+	// other values are written as JSON.
+	FuncExtract = "extract"
+
+	// FuncEnableRequestBodyChunks streams the request body through the guest
+	// function export FuncHandleRequestBodyChunk, as the next handler reads
+	// it. This must be called before FuncNext.
+	//
+	// # Parameters
+	//
+	// All parameters are of type i32. They describe a buffer the host writes
+	// each chunk to, and the guest may overwrite with a transformed chunk.
+	//
+	//   - buf: memory offset to write each chunk.
+	//   - buf_limit: maximum length in bytes of a chunk. Ex. 65536
+	//
+	// Note: As the next handler sees the transformed body, the host removes
+	// the "Content-Length" header of the request.
+	//
+	// # Result
+	//
+	// There is no result from this function. A host will trap ("unreachable"
+	// instruction) if the guest doesn't export FuncHandleRequestBodyChunk.
+	FuncEnableRequestBodyChunks = "enable_request_body_chunks"
+
+	// FuncSendProblem is an alternative to FuncSendResponse that sends a
+	// denial in the "application/problem+json" format of RFC 9457. This
+	// allows all guests in an organization to share the same error shape,
+	// without encoding JSON themselves.
+	//
+	// # Parameters
+	//
+	// All parameters are of type i32.
+	//
+	//   - status_code: HTTP status code. Ex. 403
+	//   - code: memory offset to read the UTF-8 problem code. Ex. "ip-denied"
+	//   - code_len: possibly zero length of the problem code in bytes.
+	//   - detail: memory offset to read the UTF-8 detail for the client.
+	//   - detail_len: possibly zero length of the detail in bytes.
+	//
+	// The host sets the "type" member to the code, appended to a base URI it
+	// may configure, or "about:blank" when the code is empty. The "title"
+	// member is the standard text of the status code.
+	//
+	// # Result
+	//
+	// There is no result from this function. A host who fails to send the
+	// response will trap ("unreachable" instruction).
+	//
+	// # Example
+	//
+	// For example, if the base URI is "https://errors.example.com/" and the
+	// parameters are status_code=403, code="ip-denied" and
+	// detail="192.0.2.1 is not allowed", the body would be:
+	//
+	//	{"type":"https://errors.example.com/ip-denied","title":"Forbidden","status":403,"detail":"192.0.2.1 is not allowed"}
+	FuncSendProblem = "send_problem"
+
+	// FuncReadRequestBody reads the entire request body into memory the host
+	// allocates with the guest function export FuncMalloc. The result is
+	// `ptr<<32|body_len` or zero if the body is empty, in which case nothing
+	// is allocated.
+	//
+	// When the length of the body is known, the host reads it directly into
+	// the allocation, avoiding an intermediate copy. This is faster for large
+	// bodies than reading into a fixed buffer and retrying with a larger one.
+	//
+	// Note: The next handler may read the body from the allocation, so the
+	// guest must not modify it until FuncNext returns.
+	//
+	// # Parameters
+	//
+	// There are no parameters
+	//
+	// # Result
+	//
+	// The result is of type i64, packing two i32 values: `ptr`, the memory
+	// offset returned by FuncMalloc, in the upper 32-bits and `body_len` in
+	// the lower. A host will trap ("unreachable" instruction) if the guest
+	// doesn't export FuncMalloc.
+	FuncReadRequestBody = "read_request_body"
+
+	// FuncSendLocalizedResponse is an alternative to FuncSendResponse that
+	// sends a message from a catalog the host configured, in the language the
+	// client prefers. This allows user-facing denials to be localized without
+	// embedding translations in the guest.
+	//
+	// # Parameters
+	//
+	// All parameters are of type i32.
+	//
+	//   - status_code: HTTP status code. Ex. 403
+	//   - key: memory offset to read the UTF-8 message key. Ex. "denied"
+	//   - key_len: length of the message key in bytes.
+	//
+	// The host chooses the language from the "Accept-Language" request
+	// header, falling back to its default language. The response has the
+	// "Content-Language" header set to the chosen language.
+	//
+	// # Result
+	//
+	// There is no result from this function. A host will trap ("unreachable"
+	// instruction) if there is no message with the key in the default
+	// language.
+	FuncSendLocalizedResponse = "send_localized_response"
+
+	// FuncGetProperty writes the value of a property of the current request
+	// to memory if it exists and isn't larger than the buffer size limit. The
+	// result is `1<<32|value_len` or zero if the property doesn't exist.
+	//
+	// Properties are values computed while handling a request, such as an
+	// authenticated user ID. They are visible to all guests handling the
+	// request, such as those in a chain, as well as to the host.
+	//
+	// This has the same signature and semantics as FuncReadRequestHeader,
+	// except the name is a property. Names are compared case-sensitively.
+	FuncGetProperty = "get_property"
+
+	// FuncSetProperty sets a property of the current request from a name and
+	// value read from memory. See FuncGetProperty for more details.
+	//
+	// This has the same signature and semantics as FuncSetResponseHeader,
+	// except the name is a property.
+	FuncSetProperty = "set_property"
+
+	// FuncGetHostValue writes a value passed by the host to memory if it
+	// exists and isn't larger than the buffer size limit. The result is
+	// `1<<32|value_len` or zero if the value doesn't exist.
+	//
+	// Host values are opaque bytes, which allow code of the host handling the
+	// request before the guest, such as a session lookup, to pass data to
+	// it. A value set via FuncSetHostValue during the request takes
+	// precedence over one passed by the host. Keys are compared
+	// case-sensitively.
+	//
+	// This has the same signature and semantics as FuncReadRequestHeader,
+	// except the name is the key of a host value. Ex. "session"
+	FuncGetHostValue = "get_host_value"
+
+	// FuncSetHostValue sets a host value from a key and value read from
+	// memory, which code of the host handling the request after the guest can
+	// read, such as the next handler. See FuncGetHostValue for more details.
+	//
+	// # Parameters
+	//
+	// All parameters are of type i32.
+	//
+	//   - key: memory offset to read the key.
+	//   - key_len: length of the key in bytes.
+	//   - value: memory offset to read the value.
+	//   - value_len: length of the value in bytes.
+	//
+	// # Result
+	//
+	// There is no result from this function.
+	FuncSetHostValue = "set_host_value"
+
+	// FuncSuppressInjectedHeaders prevents the host from injecting response
+	// headers it configured for all guests, such as a server token, into the
+	// current response.
+	//
+	// # Parameters
+	//
+	// There are no parameters
+	//
+	// # Result
+	//
+	// There is no result from this function.
+	FuncSuppressInjectedHeaders = "suppress_injected_headers"
+
+	// FuncGetShared writes the value of a key in the store shared across
+	// requests to memory if it exists and isn't larger than the buffer size
+	// limit. The result is `1<<32|value_len` or zero if the key doesn't exist
+	// or expired.
+	//
+	// The shared store allows guests to implement features such as rate
+	// limiting, de-duplication or caching. Keys are compared
+	// case-sensitively. Since the store may be shared by unrelated guests,
+	// keys should be prefixed, such as with the guest name.
+	//
+	// This has the same signature and semantics as FuncReadRequestHeader,
+	// except the name is a key in the shared store.
+	FuncGetShared = "get_shared"
+
+	// FuncSetShared sets the value of a key in the store shared across
+	// requests. See FuncGetShared for more details.
+	//
+	// # Parameters
+	//
+	//   - key: memory offset to read the key.
+	//   - key_len: length of the key in bytes.
+	//   - value: memory offset to read the value.
+	//   - value_len: length of the value in bytes.
+	//   - ttl_millis: milliseconds until the key expires, or zero if it
+	//     doesn't.
+	//
+	// # Result
+	//
+	// There is no result from this function.
+	FuncSetShared = "set_shared"
+
+	// FuncCasShared is like FuncSetShared, except it only sets the value if
+	// the current value equals the expected one. A key that doesn't exist
+	// compares equal to an empty value. See FuncGetShared for more details.
+	//
+	// For example, a guest can implement a counter by reading the value with
+	// FuncGetShared, then retrying this with the incremented value until the
+	// result is one.
+	//
+	// # Parameters
+	//
+	//   - key: memory offset to read the key.
+	//   - key_len: length of the key in bytes.
+	//   - old: memory offset to read the expected value.
+	//   - old_len: length of the expected value in bytes.
+	//   - value: memory offset to read the value.
+	//   - value_len: length of the value in bytes.
+	//   - ttl_millis: milliseconds until the key expires, or zero if it
+	//     doesn't.
+	//
+	// # Result
+	//
+	// The result is one if the value was set, or zero if the current value
+	// didn't match.
+	FuncCasShared = "cas_shared"
+
+	// FuncReadState writes the value of a key in the persistent state store
+	// to memory if it exists and isn't larger than the buffer size limit.
+	// The result is `1<<32|value_len` or zero if the key doesn't exist.
+	//
+	// Unlike FuncGetShared, the state store is durable: values survive
+	// reloading the guest and restarting the host. This allows guests to
+	// implement features such as sticky A/B assignments or counters. As
+	// writes may be slow, guests shouldn't write state on each request.
+	// Since the store may be shared by unrelated guests, keys should be
+	// prefixed, such as with the guest name.
+	//
+	// This has the same signature and semantics as FuncReadRequestHeader,
+	// except the name is a key in the state store.
+	//
+	// Note: This requires FeatureStateStore. Otherwise, no key exists.
+	FuncReadState = "read_state"
+
+	// FuncWriteState durably sets the value of a key in the persistent state
+	// store. See FuncReadState for more details.
+	//
+	// # Parameters
+	//
+	//   - key: memory offset to read the key.
+	//   - key_len: length of the key in bytes.
+	//   - value: memory offset to read the value.
+	//   - value_len: length of the value in bytes.
+	//
+	// # Result
+	//
+	// There is no result from this function.
+	//
+	// Note: This traps unless FeatureStateStore is enabled.
+	FuncWriteState = "write_state"
+
+	// FuncCacheLookup looks up a response in the host response cache by a
+	// key the guest computed, such as from the method, path and "Accept"
+	// header. On a hit, the host sends the cached response, so the guest
+	// should return without invoking the next handler. Otherwise, the guest
+	// continues, usually calling FuncCacheStore to cache the response of the
+	// next handler.
+	//
+	// # Parameters
+	//
+	// All parameters are of type i32.
+	//
+	//   - key: memory offset to read the key. Ex. "GET /products?page=1"
+	//   - key_len: length of the key in bytes.
+	//
+	// # Result
+	//
+	// The result is one if the cached response was sent, or zero on a miss,
+	// including when the host has no response cache.
+	FuncCacheLookup = "cache_lookup"
+
+	// FuncCacheStore stores the response of the current request in the host
+	// response cache, after the guest handled it, so that later requests of
+	// the key are served with FuncCacheLookup. The guest decides what is
+	// cacheable: for example, it can call this in FuncHandleResponse only
+	// when the status code is 200. Calling this again replaces the key and
+	// ttl, and calling this after a trap has no effect.
+	//
+	// When this is called before FuncNext, the host buffers the response of
+	// the next handler, as if FeatureBufferResponse were enabled. When called
+	// after, the response is only stored if it was buffered.
+	//
+	// # Parameters
+	//
+	// All parameters are of type i32.
+	//
+	//   - key: memory offset to read the key.
+	//   - key_len: length of the key in bytes.
+	//   - ttl_secs: seconds until the response expires, or zero if it
+	//     doesn't, though the host may evict it.
+	//
+	// # Result
+	//
+	// There is no result from this function. The "Set-Cookie" header isn't
+	// stored, so that responses of one client aren't sent to others.
+	FuncCacheStore = "cache_store"
+
+	// FuncRateLimitCheck takes tokens from the rate limit of a key, which is
+	// shared across requests, and by hosts with more than one process. This
+	// allows guests to implement rate policies per key, such as per client
+	// address, without coordinating state themselves.
+	//
+	// The rate is configured by the host, so a guest varies limits by the
+	// cost of each request, in tokens. For example, a guest could take one
+	// token for a read, and ten for a write.
+	//
+	// # Parameters
+	//
+	// All parameters are of type i32.
+	//
+	//   - key: memory offset to read the key. Ex. "client:192.0.2.1"
+	//   - key_len: length of the key in bytes.
+	//   - tokens: the cost of the request, usually one.
+	//
+	// # Result
+	//
+	// The result is one if the request is allowed, or zero if it should be
+	// limited, such as with 429 Too Many Requests. No tokens are taken when
+	// the result is zero. A host who fails to check the limit, such as when
+	// a remote store is unavailable, will trap ("unreachable" instruction).
+	FuncRateLimitCheck = "rate_limit_check"
+
+	// FuncDefineMetric defines a metric, which the host publishes to its
+	// metrics backend, such as Prometheus. This allows guests to publish
+	// business metrics, such as blocked requests by rule, without the host
+	// parsing logs.
+	//
+	// Metrics are shared by all instances of the guest, so defining the same
+	// name again returns the same ID. Since the backend may be shared by
+	// unrelated guests, names should be prefixed, such as with the guest name.
+	//
+	// # Parameters
+	//
+	// All parameters are of type i32.
+	//
+	//   - kind: zero for a counter, one for a gauge or two for a histogram.
+	//   - name: memory offset to read the name, in the format
+	//     "[a-zA-Z_:][a-zA-Z0-9_:]*". Ex. "waf_blocked_total"
+	//   - name_len: length of the name in bytes.
+	//
+	// # Result
+	//
+	// The result is `metric_id` of type i32, which identifies the metric to
+	// FuncIncrementMetric and FuncRecordMetric. A host will trap
+	// ("unreachable" instruction) if the kind or name is invalid, the name
+	// was defined with a different kind, or the guest defined too many
+	// metrics.
+	FuncDefineMetric = "define_metric"
+
+	// FuncIncrementMetric adds to the value of a counter or gauge defined via
+	// FuncDefineMetric.
+	//
+	// # Parameters
+	//
+	//   - metric_id: i32 result of FuncDefineMetric.
+	//   - delta: i64 value to add, which is negative to decrease a gauge.
+	//
+	// # Result
+	//
+	// There is no result from this function. A host will trap
+	// ("unreachable" instruction) if the metric doesn't exist, is a
+	// histogram, or is a counter and the delta is negative.
+	FuncIncrementMetric = "increment_metric"
+
+	// FuncRecordMetric sets the value of a gauge, or records a value of a
+	// histogram, defined via FuncDefineMetric.
+	//
+	// # Parameters
+	//
+	//   - metric_id: i32 result of FuncDefineMetric.
+	//   - value: i64 value to record.
+	//
+	// # Result
+	//
+	// There is no result from this function. A host will trap
+	// ("unreachable" instruction) if the metric doesn't exist or is a
+	// counter.
+	FuncRecordMetric = "record_metric"
+
+	// FuncGetTimeNanos returns the current wall clock time in nanoseconds
+	// since the Unix epoch. This allows guests without WASI to read the time,
+	// such as to check the expiration of a token.
+	//
+	// # Parameters
+	//
+	// There are no parameters
+	//
+	// # Result
+	//
+	// The result is nanoseconds since 1970-01-01T00:00:00Z. This can go
+	// backwards, such as when the host clock is adjusted, so use
+	// FuncGetMonotonicNanos to measure elapsed time.
+	FuncGetTimeNanos = "get_time_nanos"
+
+	// FuncGetMonotonicNanos returns nanoseconds elapsed since an arbitrary
+	// point in time, which is only meaningful when compared with another
+	// result of this function.
+	//
+	// # Parameters
+	//
+	// There are no parameters
+	//
+	// # Result
+	//
+	// The result is nanoseconds, which never decreases.
+	FuncGetMonotonicNanos = "get_monotonic_nanos"
+
+	// FuncGetRandom fills memory with cryptographically secure random bytes.
+	// This allows guests without WASI to generate values such as nonces or
+	// request IDs.
+	//
+	// # Parameters
+	//
+	//   - buf: memory offset to write the random bytes.
+	//   - buf_len: count of random bytes to write.
+	//
+	// # Result
+	//
+	// There is no result from this function.
+	FuncGetRandom = "get_random"
+
+	// FuncSetError is an alternative to FuncSendResponse that sends a
+	// response for an error code declared by the guest, such as
+	// "token-expired". The host maps codes to a status code and body, so
+	// that the shape of failures is configured in one place instead of in
+	// each guest.
+	//
+	// # Parameters
+	//
+	//   - code: memory offset to read the UTF-8 error code.
+	//   - code_len: length of the error code in bytes.
+	//
+	// The host responds with 500 Internal Server Error and no body if the
+	// code isn't mapped.
+	//
+	// # Result
+	//
+	// There is no result from this function. A host who fails to send the
+	// response will trap ("unreachable" instruction).
+	FuncSetError = "set_error"
+
+	// FuncGetLastError returns the error of the last host function which
+	// failed to set a response header, cookie or trailer or to send a
+	// response body, then clears it.
+	//
+	// Importing this function changes how those host functions fail: the
+	// host skips what it couldn't set or send and records an Errno, instead
+	// of trapping. A guest which imports it must check the result after each
+	// such call, or it may send a response missing a header it intended,
+	// such as "Content-Security-Policy". Guests which don't import it trap,
+	// as documented on each function.
+	//
+	// # Result
+	//
+	// The result is an Errno, which is ErrnoSuccess if no function failed
+	// since the last call.
+	//
+	// # Example
+	//
+	// For example, if the guest calls FuncSetResponseHeader with a name
+	// containing a space, the host doesn't set the header and the next call
+	// to this function returns ErrnoInvalidHeaderName.
+	FuncGetLastError = "get_last_error"
+
+	// FuncHTTPCall sends an HTTP request and waits for its response. This
+	// allows guests to call external services, such as to introspect an
+	// authorization token. The host only allows calls to hosts it
+	// configured, including redirects, and may limit their concurrency and
+	// timeout.
+	//
+	// # Parameters
+	//
+	// All parameters are of type i32.
+	//
+	//   - method: memory offset to read the method. Ex. "POST"
+	//   - method_len: length of the method in bytes, or zero for "GET".
+	//   - url: memory offset to read the absolute URL.
+	//   - url_len: length of the URL in bytes.
+	//   - headers: memory offset to read the request headers, each in the
+	//     format "Name: value\r\n". Ex. "Accept: application/json\r\n"
+	//   - headers_len: possibly zero length of the headers in bytes.
+	//   - body: memory offset to read the request body.
+	//   - body_len: possibly zero length of the request body in bytes.
+	//   - timeout_millis: milliseconds to wait for the response, or zero for
+	//     the host default. The host may enforce a lower limit.
+	//   - buf: memory offset to write the response body.
+	//   - buf_limit: possibly zero maximum bytes of the response body to
+	//     write.
+	//
+	// The host writes at most buf_limit bytes of the response body, so the
+	// body is truncated if its length is larger. To read the whole body, call
+	// again with the same arguments and a buffer of at least body_len. The
+	// host then writes the response it kept instead of sending the request
+	// again, as it may not be idempotent, such as a POST.
+	//
+	// # Result
+	//
+	// The result is `status_code<<32|body_len`, where body_len is the length
+	// of the whole response body. The result is zero if the call failed, such
+	// as when the host isn't allowed or the call timed out.
+	FuncHTTPCall = "http_call"
+
+	// FuncResolve resolves a host name to its IP addresses, and writes them
+	// to memory if they aren't larger than the buffer size limit. This allows
+	// guests to validate host names, such as to prevent server-side request
+	// forgery, without a resolver of their own.
+	//
+	// This has the same signature as FuncReadRequestHeader, except the name
+	// is a host name. Ex. "example.com"
+	//
+	// # Result
+	//
+	// The result is `1<<32|value_len`, where the value is the addresses in
+	// textual form, each terminated by NUL. Ex. "192.0.2.1\x002001:db8::1\x00"
+	// The result is zero if the name couldn't be resolved.
+	FuncResolve = "resolve"
+
+	// FuncEnableFeatures enables features of the host for the current
+	// request, which are disabled by default as they have a cost, such as
+	// buffering the response. This must be called from FuncHandle before
+	// FuncNext to affect it.
+	//
+	// # Parameters
+	//
+	// The only parameter is of type i64.
+	//
+	//   - features: the Features the guest needs.
+	//
+	// # Result
+	//
+	// The result is the i64 Features now enabled, which may exclude features
+	// the host doesn't support, so the guest can degrade gracefully.
+	FuncEnableFeatures = "enable_features"
+
+	// FuncReadResponseBody writes the response body buffered from the next
+	// handler to memory if it isn't larger than the buffer size limit. The
+	// result is the length of the body in bytes, which is zero unless
+	// FeatureBufferResponse is enabled.
+	//
+	// Hosts enable FeatureBufferResponse when a guest importing this calls
+	// FuncNext, so guests needn't enable it themselves. Guests not importing
+	// this don't pay the cost of buffering, unless they enable it.
+	//
+	// This has the same signature and semantics as FuncGetConfig.
+	FuncReadResponseBody = "read_response_body"
+
+	// FuncCapabilities returns the Features the host supports, without
+	// enabling any. Portable guests can check this before using optional
+	// features, to degrade gracefully on hosts without them.
+	//
+	// # Parameters
+	//
+	// There are no parameters.
+	//
+	// # Result
+	//
+	// The result is the i64 Features the host supports.
+	FuncCapabilities = "capabilities"
+
+	// FuncGetBufferLimit returns the size of buffers the host recommends
+	// guests pass to functions with a `buf_limit` parameter, such as
+	// FuncReadRequestHeader, configured with httpwasm.DefaultBufferLimit.
+	// This allows operators to tune how often guests need to retry with a
+	// larger buffer, without rebuilding them.
+	//
+	// # Parameters
+	//
+	// There are no parameters.
+	//
+	// # Result
+	//
+	// The result is the i32 buffer size in bytes, which is never zero.
+	FuncGetBufferLimit = "get_buffer_limit"
+
+	// FuncEmitAccessLog passes an access log entry, read from memory, to the
+	// host, which routes it to its access log pipeline, such as a file or
+	// OpenTelemetry. Unlike "log", which is for debugging, this allows guests
+	// to implement custom access log formats.
+	//
+	// The entry should be a JSON object, such as
+	// `{"method":"GET","path":"/","status":200}`. Hosts drop entries which
+	// aren't valid JSON, and entries when they have no access logger.
+	//
+	// # Parameters
+	//
+	// All parameters are of type i32.
+	//
+	//   - entry: memory offset to read the entry.
+	//   - entry_len: length of the entry in bytes.
+	//
+	// There is no result.
+	FuncEmitAccessLog = "emit_access_log"
+
+	// FuncEmitEvent notifies host code of an event, such as "user.blocked"
+	// or "quota.exceeded", so that it can trigger business logic outside the
+	// request path. The host delivers events asynchronously, so the guest
+	// doesn't wait for them to be handled. Hosts drop events when they have
+	// no receiver, or are too far behind delivering them.
+	//
+	// # Parameters
+	//
+	// All parameters are of type i32.
+	//
+	//   - topic: memory offset to read the topic, which must not be empty.
+	//   - topic_len: length of the topic in bytes.
+	//   - payload: memory offset to read the payload, such as a JSON object.
+	//   - payload_len: length of the payload in bytes, which may be zero.
+	//
+	// There is no result.
+	FuncEmitEvent = "emit_event"
+
+	// FuncScheduleTick schedules the host to call FuncOnTick periodically,
+	// such as from FuncInit. There is one timer per middleware, not per
+	// instance or request, so calling this again with the same period, as
+	// each instance does from FuncInit, has no effect. Calling this with a
+	// different period replaces the timer.
+	//
+	// # Parameters
+	//
+	// All parameters are of type i32.
+	//
+	//   - period_ms: milliseconds between ticks, which must be at least 100,
+	//     or zero to stop ticking.
+	//
+	// There is no result. A guest who doesn't export FuncOnTick will trap
+	// ("unreachable" instruction).
+	FuncScheduleTick = "schedule_tick"
+
+	// FuncGetUpgrade writes the protocol of a pending upgrade, such as to
+	// WebSocket, to memory if it isn't larger than the buffer size limit. The
+	// result is the length of the protocol in bytes, or zero if the request
+	// isn't an upgrade. Ex. "websocket"
+	//
+	// A request is an upgrade when its "Connection" header includes the
+	// token "upgrade", and its "Upgrade" header is set. Guests can deny an
+	// upgrade with FuncSendResponse, or allow it with FuncNext, in which case
+	// the next handler may take over the connection.
+	//
+	// This has the same signature and semantics as FuncGetConfig.
+	FuncGetUpgrade = "get_upgrade"
+
+	// FuncEnableStreamingResponse prevents the host from buffering the
+	// response of the next handler for the current request, so that
+	// responses such as Server-Sent Events are flushed to the client
+	// incrementally. This must be called from FuncHandle before FuncNext to
+	// affect it.
+	//
+	// Hosts buffer the response when a guest importing FuncReadResponseBody
+	// calls FuncNext. After this, the guest reads an empty response body
+	// instead. This has no effect if the guest enabled
+	// FeatureBufferResponse via FuncEnableFeatures.
+	//
+	// There are no parameters or result.
+	FuncEnableStreamingResponse = "enable_streaming_response"
+
+	// FuncReadRequestHeaderAlloc writes a request header value to memory the
+	// host allocates with the guest function export FuncMalloc. The result is
+	// `ptr<<32|value_len` or zero if the header doesn't exist or its value is
+	// empty, in which case nothing is allocated.
+	//
+	// This is an alternative to FuncReadRequestHeader, which avoids retrying
+	// with a larger buffer when the value is larger than expected, such as
+	// for a large cookie or token. Use FuncReadRequestHeader to distinguish
+	// a missing header from an empty one.
+	//
+	// # Parameters
+	//
+	// All parameters are of type i32.
+	//
+	//   - name: memory offset to read the header name.
+	//   - name_len: length of the header name in bytes.
+	//
+	// # Result
+	//
+	// The result is of type i64, packing two i32 values: `ptr`, the memory
+	// offset returned by FuncMalloc, in the upper 32-bits and `value_len` in
+	// the lower. A host will trap ("unreachable" instruction) if the guest
+	// doesn't export FuncMalloc.
+	FuncReadRequestHeaderAlloc = "read_request_header_alloc"
+
+	// FuncReadResponseBodyAlloc is like FuncReadResponseBody, except it
+	// writes the buffered response body to memory the host allocates with
+	// the guest function export FuncMalloc. The result is `ptr<<32|body_len`
+	// or zero if the body is empty, in which case nothing is allocated.
+	//
+	// Hosts buffer the response for guests importing this the same as for
+	// FuncReadResponseBody.
+	//
+	// This has the same signature and semantics as FuncReadRequestBody.
+	FuncReadResponseBodyAlloc = "read_response_body_alloc"
+
+	// FuncReadRequestHeaders writes all request headers to memory if they
+	// aren't larger than the buffer size limit. The result is the length of
+	// the encoded headers in bytes, or zero if there are none.
+	//
+	// This reads headers in one call, which is faster than calling
+	// FuncReadRequestHeader for each, when a guest needs many of them, such
+	// as to sign or forward the request.
+	//
+	// This has the same signature and semantics as FuncGetConfig.
+	//
+	// # Encoding
+	//
+	// Each header value is encoded as an entry, in order of the header name.
+	// A header with multiple values has an entry for each. An entry is:
+	//
+	//   - name_len: little-endian uint32 length of the name in bytes.
+	//   - name: the header name. Ex. "Content-Type"
+	//   - value_len: little-endian uint32 length of the value in bytes.
+	//   - value: the header value. Ex. "text/plain"
+	//
+	// This is version 1 of the encoding shared by all functions that
+	// exchange lists, implemented by package abi/encoding.
+	FuncReadRequestHeaders = "read_request_headers"
+
+	// FuncWriteResponseHeaders sets response headers from entries read from
+	// memory, in the encoding documented on FuncReadRequestHeaders. Each
+	// entry is set the same as FuncSetResponseHeader, so a later entry
+	// replaces an earlier one with the same name.
+	//
+	// # Parameters
+	//
+	// All parameters are of type i32.
+	//
+	//   - buf: memory offset to read the entries.
+	//   - buf_len: length of the entries in bytes.
+	//
+	// # Result
+	//
+	// There is no result from this function. A host will trap ("unreachable"
+	// instruction) if an entry is truncated, or the same as for
+	// FuncSetResponseHeader, such as if a name is invalid. Entries before the
+	// invalid one remain set, but the response fails, as the guest traps.
+	FuncWriteResponseHeaders = "write_response_headers"
+
+	// FuncReadRawRequestHeaders is like FuncReadRequestHeaders, except the
+	// entries are the request headers as the client sent them: in order, and
+	// with the case of names preserved. This is needed by guests that verify
+	// signatures over raw headers, such as AWS Signature Version 4 or HTTP
+	// Message Signatures. The "Host" header is included.
+	//
+	// The result is zero when the host didn't preserve raw headers, for
+	// example, as they weren't received over HTTP/1.x, or the host wasn't
+	// configured to. In HTTP/2 and HTTP/3, names are always lowercase, so
+	// guests can fall back to FuncReadRequestHeaders.
+	//
+	// This has the same signature and semantics as FuncGetConfig.
+	FuncReadRawRequestHeaders = "read_raw_request_headers"
+
+	// FuncSetRawResponseHeader is like FuncSetResponseHeader, except the
+	// name is sent as-is, instead of canonicalized. For example, "x-amz-date"
+	// is sent as "x-amz-date", not "X-Amz-Date". This replaces any header of
+	// the same name in a different case. Like FuncSetResponseHeader, the host
+	// traps if the header is invalid.
+	//
+	// Note: Names are always lowercase in HTTP/2 and HTTP/3.
+	FuncSetRawResponseHeader = "set_raw_response_header"
+
+	// FuncReadQueryParams writes all query parameters of the request to
+	// memory if they aren't larger than the buffer size limit. The result is
+	// the length of the encoded parameters in bytes, or zero if there are
+	// none.
+	//
+	// Parameters are encoded the same as FuncReadRequestHeaders: an entry for
+	// each value, in order of the parameter name. Names and values are
+	// decoded, so "?q=a%20b" is the entry "q" "a b".
+	//
+	// This has the same signature and semantics as FuncGetConfig.
+	FuncReadQueryParams = "read_query_params"
+
+	// FuncReadProperties writes all properties of the request to memory if
+	// they aren't larger than the buffer size limit. The result is the length
+	// of the encoded properties in bytes, or zero if there are none. See
+	// FuncGetProperty for more details.
+	//
+	// Properties are encoded the same as FuncReadRequestHeaders: an entry for
+	// each property, in order of its name.
+	//
+	// This has the same signature and semantics as FuncGetConfig.
+	FuncReadProperties = "read_properties"
+
+	// FuncGetRequestOrigin writes the "Origin" header of the request to
+	// memory if it exists and isn't larger than the buffer size limit, so
+	// that CORS guests can check it against the origins they allow. The
+	// result is `1<<32|value_len` or zero if the header doesn't exist, such
+	// as for requests which aren't cross-origin.
+	//
+	// This has the same parameters and semantics as FuncReadRequestHeader,
+	// except without the header name. Ex. "https://example.com"
+	FuncGetRequestOrigin = "get_request_origin"
+
+	// FuncSetCORSHeaders sets the "Access-Control-Allow-*" response headers
+	// for the request, which is simpler than setting each with
+	// FuncSetResponseHeader. A guest answering a CORS preflight request calls
+	// this then sends the response, typically with status 204, without
+	// invoking the next handler. Otherwise, it calls this before or after
+	// FuncNext.
+	//
+	// # Parameters
+	//
+	// All parameters are of type i32. Strings are UTF-8, and empty strings
+	// aren't sent.
+	//
+	//   - origin: memory offset to read the allowed origin, usually the
+	//     result of FuncGetRequestOrigin. Ex. "https://example.com" or "*"
+	//   - origin_len: length of the origin in bytes.
+	//   - methods: memory offset to read the comma-separated methods allowed
+	//     in preflight responses. Ex. "GET, POST"
+	//   - methods_len: length of the methods in bytes.
+	//   - headers: memory offset to read comma-separated header names,
+	//     allowed in preflight responses, or exposed to scripts otherwise.
+	//     Ex. "Content-Type, Authorization"
+	//   - headers_len: length of the headers in bytes.
+	//   - max_age_secs: seconds the client may cache a preflight response,
+	//     or zero to not send it.
+	//   - credentials: one to allow credentials, such as cookies, or zero.
+	//
+	// A request is a preflight when its method is "OPTIONS" and it has the
+	// "Origin" and "Access-Control-Request-Method" headers. In preflight
+	// responses, this sets "Access-Control-Allow-Methods",
+	// "Access-Control-Allow-Headers" and "Access-Control-Max-Age".
+	// Otherwise, this sets "Access-Control-Expose-Headers", and methods and
+	// max_age_secs are ignored. "Vary: Origin" is set unless the origin is
+	// "*", so that caches don't reuse the response for other origins.
+	//
+	// # Result
+	//
+	// There is no result from this function. A host will trap ("unreachable"
+	// instruction) if the origin is empty, or credentials are allowed for
+	// the origin "*", which clients reject.
+	FuncSetCORSHeaders = "set_cors_headers"
+
+	// FuncSendRedirect is an alternative to FuncSendResponse that redirects
+	// the client to a location, such as to a login page or the canonical
+	// host. This avoids setting the status code and "Location" header, then
+	// sending an empty body, in separate calls.
+	//
+	// # Parameters
+	//
+	// All parameters are of type i32.
+	//
+	//   - status_code: HTTP redirect status code: 301, 302, 303, 307 or 308.
+	//   - location: memory offset to read the UTF-8 location. Ex. "/login"
+	//   - location_len: length of the location in bytes.
+	//
+	// The location may be relative to the request URL, and is sent as-is.
+	//
+	// # Result
+	//
+	// There is no result from this function. A host will trap ("unreachable"
+	// instruction) if the status code isn't a redirect, the location is empty
+	// or an invalid header value.
+	FuncSendRedirect = "send_redirect"
+
+	// FuncGetResponseBodySize returns the count of bytes of the response body
+	// written so far, such as by the next handler, so that guests can record
+	// response sizes without buffering them. Use FuncIsResponseCommitted to
+	// tell whether the next handler wrote a response at all, as its body may
+	// be empty.
+	//
+	// # Parameters
+	//
+	// There are no parameters
+	//
+	// # Result
+	//
+	// The result is the size in bytes, of type i64. When the response is
+	// buffered via FeatureBufferResponse, this is the size of the buffered
+	// body, so changes when the guest replaces it.
+	FuncGetResponseBodySize = "get_response_body_size"
+
+	// FuncGetRequestBodySize returns the size of the request body declared
+	// by the client via the "Content-Length" header, so that guests can
+	// reject large uploads before the host reads or buffers the body.
+	//
+	// # Parameters
+	//
+	// There are no parameters
+	//
+	// # Result
+	//
+	// The result is the size in bytes, of type i64, or -1 if unknown, such as
+	// when the body is chunked. See FuncIsRequestChunked.
+	FuncGetRequestBodySize = "get_request_body_size"
+
+	// FuncIsRequestChunked returns whether the request body uses the chunked
+	// transfer encoding, so its size is only known after reading it.
+	//
+	// # Parameters
+	//
+	// There are no parameters
+	//
+	// # Result
+	//
+	// The result is one if the request body is chunked or zero if not, of
+	// type i32.
+	//
+	// Note: HTTP/2 and HTTP/3 don't use transfer encodings, so this is zero
+	// even when their size is unknown.
+	FuncIsRequestChunked = "is_request_chunked"
+
+	// FuncSetUpstream sets where the host sends the current request after
+	// FuncNext, such as to route it to a canary backend. The semantics are
+	// defined by the host: a reverse proxy changes the backend, while a host
+	// without upstreams may ignore it.
+	//
+	// # Parameters
+	//
+	// All parameters are of type i32.
+	//
+	//   - upstream: memory offset to read the upstream, as "host" or
+	//     "host:port". Ex. "canary.internal:8080"
+	//   - upstream_len: length of the upstream in bytes.
+	//
+	// # Result
+	//
+	// There is no result from this function. A host will trap ("unreachable"
+	// instruction) if the upstream is empty or not a valid host and optional
+	// port.
+	FuncSetUpstream = "set_upstream"
+
+	// FuncSetTimeoutMs limits how long the host waits for the next handler,
+	// such as the response of a backend. The semantics are defined by the
+	// host: a Go host applies a deadline to the context of the request passed
+	// to the next handler.
+	//
+	// # Parameters
+	//
+	// The only parameter is `timeout_ms` of type i32, the timeout in
+	// milliseconds, or zero to remove a timeout previously set.
+	//
+	// # Result
+	//
+	// There is no result from this function. This must be called before
+	// FuncNext to have any effect.
+	FuncSetTimeoutMs = "set_timeout_ms"
+
+	// FuncSetRetryPolicy sets how a proxy host retries the request when the
+	// upstream fails, such as with a connection error or 503 Service
+	// Unavailable. Hosts which don't proxy requests ignore this.
+	//
+	// # Parameters
+	//
+	// All parameters are of type i32.
+	//
+	//   - attempts: maximum attempts, including the first. One or zero
+	//     disables retries.
+	//   - backoff_ms: milliseconds to wait between attempts.
+	//
+	// Hosts only retry requests which are safe to repeat, such as a GET
+	// without a body.
+	//
+	// # Result
+	//
+	// There is no result from this function. This must be called before
+	// FuncNext to have any effect.
+	FuncSetRetryPolicy = "set_retry_policy"
+
+	// FuncGetFormValue writes the first value of a field of a form request
+	// body to memory if it exists and isn't larger than the buffer size
+	// limit. The result is `1<<32|value_len` or zero if the field doesn't
+	// exist, or the request body isn't a form.
+	//
+	// This has the same signature and semantics as FuncReadRequestHeader,
+	// except the name is the name of the form field. Ex. "username"
+	//
+	// The body is a form when its content type is
+	// "application/x-www-form-urlencoded" or "multipart/form-data". Parts of
+	// a multipart form which are files aren't fields, so use
+	// FuncGetUploadedFileInfo or FuncReadMultipartPart for them. Query
+	// parameters aren't fields either, so use FuncGetQueryValue for them.
+	//
+	// # Buffering
+	//
+	// The host buffers a "application/x-www-form-urlencoded" body, so that
+	// the next handler can read it. A multipart body is buffered the same as
+	// FuncReadMultipartPart, so guests should read fields in the order they
+	// appear.
+	FuncGetFormValue = "get_form_value"
+
+	// FuncGetUploadedFileInfo writes information about a file uploaded in a
+	// multipart request body to memory if it exists and isn't larger than
+	// the buffer size limit. This allows guests to enforce upload policies,
+	// such as maximum size, allowed content types or safe filenames, without
+	// parsing the body themselves.
+	//
+	// This has the same signature as FuncReadRequestHeader, except the name
+	// is the form name of the part, from its "Content-Disposition" header. A
+	// part is a file when that header has a "filename" parameter.
+	//
+	// # Result
+	//
+	// The result is `1<<32|info_len` or zero if there's no file with the form
+	// name, or the request body isn't multipart. The info is encoded as:
+	//
+	//   - size: little-endian uint64 length of the file content in bytes.
+	//   - filename_len: little-endian uint32 length of the filename in bytes.
+	//   - filename: the filename the client sent, which isn't sanitized.
+	//     Ex. "../photo.jpg"
+	//   - content_type_len: little-endian uint32 length of the content type
+	//     in bytes.
+	//   - content_type: the "Content-Type" header of the part, or empty if it
+	//     has none. Ex. "image/jpeg"
+	//
+	// # Buffering
+	//
+	// The host reads the file to measure its size, buffering it so that the
+	// next handler can read it. Otherwise, buffering is the same as
+	// FuncReadMultipartPart.
+	FuncGetUploadedFileInfo = "get_uploaded_file_info"
+
+	// FuncHMAC writes the MAC of data read from memory, using a secret the
+	// host manages by key ID, if it isn't larger than the buffer size limit.
+	// This allows guests to sign values, such as URLs, without the secret
+	// being in guest memory or bundling a crypto library.
+	//
+	// # Parameters
+	//
+	// All parameters are of type i32.
+	//
+	//   - alg: memory offset of the algorithm, named as in JSON Web
+	//     Algorithms (RFC 7518). One of "HS256", "HS384" or "HS512".
+	//   - alg_len: length of the algorithm in bytes.
+	//   - key_id: memory offset of the ID of the secret. Ex. "url-signer"
+	//   - key_id_len: length of the key ID in bytes.
+	//   - data: memory offset of the data to sign.
+	//   - data_len: length of the data in bytes.
+	//   - buf: memory offset to write the MAC, if not larger than
+	//     `buf_limit` bytes.
+	//   - buf_limit: possibly zero maximum length in bytes to write.
+	//
+	// # Result
+	//
+	// The result is `mac_len` of type i32, or zero if the key doesn't exist,
+	// isn't a secret, or the algorithm isn't supported. If `mac_len` is
+	// larger than `buf_limit`, nothing is written to memory.
+	FuncHMAC = "hmac"
+
+	// FuncVerifySignature verifies a signature of data read from memory,
+	// using a key the host manages by key ID. This allows guests to validate
+	// JSON Web Tokens or signed URLs without bundling a crypto library.
+	//
+	// # Parameters
+	//
+	// All parameters are of type i32.
+	//
+	//   - alg: memory offset of the algorithm, named as in JSON Web
+	//     Algorithms (RFC 7518). Ex. "RS256" or "EdDSA"
+	//   - alg_len: length of the algorithm in bytes.
+	//   - key_id: memory offset of the ID of the key. Ex. the "kid" of a JWT
+	//   - key_id_len: length of the key ID in bytes.
+	//   - data: memory offset of the signed data. Ex. "header.payload"
+	//   - data_len: length of the data in bytes.
+	//   - sig: memory offset of the signature, decoded from base64url if in a
+	//     JWT. An ECDSA signature is the concatenation of R and S, as in JSON
+	//     Web Signature.
+	//   - sig_len: length of the signature in bytes.
+	//
+	// Hosts support "HS256", "HS384", "HS512", "RS256", "RS384", "RS512",
+	// "PS256", "PS384", "PS512", "ES256", "ES384", "ES512" and "EdDSA".
+	//
+	// # Result
+	//
+	// The result is one of type i32 if the signature is valid. Otherwise, it
+	// is zero, including when the key doesn't exist, the algorithm isn't
+	// supported or doesn't match the key. Hence, a guest needn't check an
+	// untrusted algorithm, such as the "alg" of a JWT, before calling this.
+	FuncVerifySignature = "verify_signature"
+
+	// FuncSniffContentType writes the content type of data read from memory,
+	// such as the start of an uploaded file, to memory if it isn't larger
+	// than the buffer size limit. This allows guests to validate uploads
+	// without reimplementing the algorithm of the WHATWG MIME Sniffing
+	// standard, the same as Go's http.DetectContentType.
+	//
+	// # Parameters
+	//
+	// All parameters are of type i32.
+	//
+	//   - data: memory offset of the start of the content. Only the first
+	//     512 bytes are considered.
+	//   - data_len: length of the data in bytes.
+	//   - buf: memory offset to write the content type, if not larger than
+	//     `buf_limit` bytes.
+	//   - buf_limit: possibly zero maximum length in bytes to write.
+	//
+	// # Result
+	//
+	// The result is `type_len` of type i32. If `type_len` is larger than
+	// `buf_limit`, nothing is written to memory. The content type includes a
+	// charset when it is text, and is "application/octet-stream" when
+	// unknown. Ex. "text/html; charset=utf-8" or "image/png"
+	FuncSniffContentType = "sniff_content_type"
+
+	// FuncNegotiateContentType writes the offered content type the client
+	// prefers, according to the "Accept" header of the request, to memory if
+	// it isn't larger than the buffer size limit. This allows guests to
+	// choose a response format without reimplementing the parsing of media
+	// ranges and quality values (RFC 9110 section 12.5.1).
+	//
+	// # Parameters
+	//
+	// All parameters are of type i32.
+	//
+	//   - offers: memory offset of the content types the guest can respond
+	//     with, separated by commas, in order of the preference of the guest.
+	//     Ex. "application/json,text/html"
+	//   - offers_len: length of the offers in bytes.
+	//   - buf: memory offset to write the content type, if not larger than
+	//     `buf_limit` bytes.
+	//   - buf_limit: possibly zero maximum length in bytes to write.
+	//
+	// # Result
+	//
+	// The result is `1<<32|type_len` of type i64, or zero if the client
+	// accepts none of the offers, in which case the guest should usually
+	// respond 406 Not Acceptable. If `type_len` is larger than `buf_limit`,
+	// nothing is written to memory.
+	//
+	// The content type is one of the offers, with whitespace trimmed. Offers
+	// with a higher quality value in the "Accept" header are preferred, and
+	// ties are broken by the order of the offers. The quality value of an
+	// offer is that of the most specific media range it matches, so
+	// "text/html" is preferred to "text/*". When the request has no "Accept"
+	// header, the first offer is chosen.
+	FuncNegotiateContentType = "negotiate_content_type"
+)
+
+// Errno is the result of FuncGetLastError, identifying why the host skipped
+// setting a response header, cookie or trailer, or sending a response body.
+type Errno uint32
+
+const (
+	// ErrnoSuccess means no host function failed.
+	ErrnoSuccess Errno = iota
+
+	// ErrnoInvalidHeaderName means a header or trailer name isn't an HTTP
+	// token, such as one with a space.
+	ErrnoInvalidHeaderName
+
+	// ErrnoInvalidHeaderValue means a header or trailer value has control
+	// characters, such as a line break.
+	ErrnoInvalidHeaderValue
+
+	// ErrnoHeaderNameTooLong means a header or trailer name is longer than
+	// allowed by httpwasm.MaxHeaderNameBytes.
+	ErrnoHeaderNameTooLong
+
+	// ErrnoHeaderValueTooLong means a header or trailer value is longer than
+	// allowed by httpwasm.MaxHeaderValueBytes.
+	ErrnoHeaderValueTooLong
+
+	// ErrnoHeaderMutationsExceeded means the guest set more response
+	// headers, cookies or trailers than allowed by
+	// httpwasm.MaxHeaderMutations.
+	ErrnoHeaderMutationsExceeded
+
+	// ErrnoHeaderBytesExceeded means the guest set response headers, cookies
+	// or trailers longer in total than allowed by httpwasm.MaxHeaderBytes.
+	ErrnoHeaderBytesExceeded
+
+	// ErrnoHeaderNamesExceeded means the guest set more distinct response
+	// header or trailer names than allowed by httpwasm.MaxHeaderCount.
+	ErrnoHeaderNamesExceeded
+
+	// ErrnoResponseBodyBytesExceeded means the guest sent a response body
+	// longer than allowed by httpwasm.MaxResponseBodyBytes.
+	ErrnoResponseBodyBytesExceeded
+)
+
+// Features is a bit set of features enabled via FuncEnableFeatures.
+type Features uint64
+
+const (
+	// FeatureBufferRequest reads the whole request body into memory before
+	// FuncHandle, instead of when the guest first reads it, so that the next
+	// handler never reads it from the network.
+	FeatureBufferRequest Features = 1 << iota
+
+	// FeatureBufferResponse buffers the response of the next handler until
+	// the guest returns, instead of sending it. This allows the guest to read
+	// it via FuncReadResponseBody, change its status code and headers, or
+	// replace it via FuncSendResponse, from FuncHandleResponse or after
+	// FuncNext.
+	FeatureBufferResponse
+
+	// FeatureTrailers is enabled when the host supports request and response
+	// trailers, via FuncGetRequestTrailer and FuncSetResponseTrailer.
+	FeatureTrailers
+
+	// FeatureSharedStore is enabled when the host has a store shared by
+	// guests, via FuncGetShared, FuncSetShared and FuncCasShared.
+	FeatureSharedStore
+
+	// FeatureHTTPCall is enabled when the host allows FuncHTTPCall to call
+	// at least one host.
+	FeatureHTTPCall
+
+	// FeatureDecodeResponse decodes a buffered response body encoded with
+	// "Content-Encoding: gzip" or "deflate", so that guests read it, such as
+	// via FuncReadResponseBody, as the actual payload instead of compressed
+	// bytes. A body the guest sends via FuncSendResponse is also plain.
+	//
+	// The host encodes the body again when committing the response, unless
+	// the guest changed the "Content-Encoding" header, and corrects the
+	// "Content-Length" header. Other encodings, such as "br", are unchanged.
+	//
+	// Note: This has no effect unless FeatureBufferResponse is enabled.
+	FeatureDecodeResponse
+
+	// FeatureGrowBuffers changes functions whose result is
+	// `1<<32|value_len`, such as FuncReadRequestHeader, when the value is
+	// larger than `buf_limit`. Instead of the guest retrying with a larger
+	// buffer, the host allocates memory for the value with FuncMalloc, and
+	// the result is `ptr<<32|value_len`, where ptr is the memory offset of
+	// the allocation, owned by the guest. Guests distinguish this by
+	// `value_len` being larger than `buf_limit`.
+	//
+	// Note: This is only supported when enabled by httpwasm.GrowBuffers and
+	// the guest exports FuncMalloc. Functions whose result is only a length,
+	// such as FuncReadResponseBody, are unchanged.
+	FeatureGrowBuffers
+
+	// FeatureStateStore is enabled when the host has a persistent state
+	// store, configured with httpwasm.StateStore, via FuncReadState and
+	// FuncWriteState.
+	FeatureStateStore
+)
+
+// CustomSectionConfigSchema is the name of a custom section a guest may
+// define to publish a JSON schema for the config it reads via FuncGetConfig.
+// When present, the host validates the config before compiling the guest and
+// fails with an error describing each invalid value.
+//
+// The schema is a subset of JSON Schema, supporting the keywords "type",
+// "enum", "properties", "required", "additionalProperties", "items",
+// "minItems", "maxItems", "minLength", "maxLength", "pattern", "minimum" and
+// "maximum". An empty config is validated as null.
+//
+// For example, this schema requires the config to be an object with a
+// non-empty "realm" property:
+//
+//	{"type":"object","properties":{"realm":{"type":"string","minLength":1}},"required":["realm"]}
+const CustomSectionConfigSchema = "http-wasm-config-schema"
+
+// CustomSectionABI is the name of a custom section a guest may define to
+// declare the version of this ABI it targets, as UTF-8 text. Ex. "0.1"
+//
+// The host doesn't interpret this, but it is useful to operators inventorying
+// deployed guests. See Middleware.CustomSection.
+const CustomSectionABI = "http-wasm-abi"
+
+// CustomSectionRoutes is the name of a custom section a guest may define to
+// declare which requests it supports, as a JSON object. Hosts may handle
+// other requests natively instead of invoking the guest.
+//
+// The object has two optional properties, each of which matches any request
+// when missing or empty:
+//
+//   - "methods": HTTP methods the guest supports. Ex. ["GET","HEAD"]
+//   - "path_prefixes": prefixes of the URL path the guest supports.
+//     Ex. ["/api/"]
+//
+// A request is supported when it matches both properties. Hosts clean the
+// path before matching, so that "//api/users" matches "/api/".
+//
+// For example, this declares the guest only supports reads under "/api/":
+//
+//	{"methods":["GET","HEAD"],"path_prefixes":["/api/"]}
+const CustomSectionRoutes = "http-wasm-routes"
+
+// CustomSectionMeta is the name of a custom section a guest may define to
+// describe itself, as a JSON object. The host doesn't interpret this, except
+// to report it via Middleware.GuestMetadata, and fails to compile guests when
+// it is invalid.
+//
+// The object has the following optional properties:
+//
+//   - "version": the version of the guest. Ex. "1.2.0"
+//   - "author": the author of the guest. Ex. "ACME Security"
+//   - "required_features": names of Features the guest requires, which are
+//     "buffer_request", "buffer_response", "trailers", "shared_store",
+//     "http_call", "decode_response", "grow_buffers" and "state_store".
+//
+// For example, this declares a guest which requires buffering the request:
+//
+//	{"version":"1.2.0","author":"ACME Security","required_features":["buffer_request"]}
+const CustomSectionMeta = "http-wasm-meta"
@@ -1,3 +1,6 @@
 package api
 
+// Memory is the conventional name of the memory guests export. Guests may
+// export their memory under another name, or import it, in which case the
+// host provides it.
 const Memory = "memory"
@@ -0,0 +1,214 @@
+// Package bench measures the overhead guests add to requests, to catch
+// regressions in the ABI layer, and to help size pools of handlers.
+//
+// Scenarios compares a guest that only invokes the next handler, one that
+// inspects and sets headers, and one that rewrites the body. Use Benchmark
+// in a Go benchmark, or Run as a load generator outside of tests.
+package bench
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	wasm "github.com/http-wasm/http-wasm-host-go/handler/nethttp"
+)
+
+// NoopWasm only invokes the next handler.
+//
+//go:embed testdata/noop.wasm
+var NoopWasm []byte
+
+// HeaderWasm copies the request header "X-Request-ID" to the response, then
+// invokes the next handler.
+//
+//go:embed testdata/header.wasm
+var HeaderWasm []byte
+
+// RewriteWasm responds with the request body in upper case, instead of
+// invoking the next handler.
+//
+//go:embed testdata/rewrite.wasm
+var RewriteWasm []byte
+
+// Scenario is a guest and the requests it handles.
+type Scenario struct {
+	// Name identifies the scenario in results. Ex. "noop"
+	Name string
+	// Guest is the guest binary.
+	Guest []byte
+	// Options are passed to wasm.NewMiddleware.
+	Options []httpwasm.Option
+	// Body is the body of each request, or nil for a GET request.
+	Body []byte
+}
+
+// Scenarios returns scenarios for NoopWasm, HeaderWasm and RewriteWasm, the
+// latter with a 1 KiB body.
+func Scenarios() []Scenario {
+	return []Scenario{
+		{Name: "noop", Guest: NoopWasm},
+		{Name: "header", Guest: HeaderWasm},
+		{Name: "rewrite", Guest: RewriteWasm, Body: bytes.Repeat([]byte("hello "), 1024/6)},
+	}
+}
+
+// request returns a new request of the scenario.
+func (s *Scenario) request() *http.Request {
+	if s.Body == nil {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Request-ID", "1234")
+		return req
+	}
+	req := httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(s.Body))
+	req.Header.Set("X-Request-ID", "1234")
+	return req
+}
+
+// next is the next handler of all scenarios, which writes a small body.
+var next = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+	w.Write([]byte("hello")) // nolint
+})
+
+// BenchmarkResult is the result of Run.
+type BenchmarkResult struct {
+	// Name is the name of the scenario.
+	Name string
+	// Concurrency is the count of handlers serving requests in parallel.
+	Concurrency int
+	// Requests is the count of requests served.
+	Requests int
+	// Duration is the time to serve all requests, excluding instantiation.
+	Duration time.Duration
+	// Instantiation is the average time to create a handler, which is the
+	// latency a request waits for when there's no idle handler in a pool.
+	Instantiation time.Duration
+	// AllocsPerOp and BytesPerOp are the heap allocations per request.
+	AllocsPerOp, BytesPerOp uint64
+	// Errors is the count of requests which didn't respond 200 OK.
+	Errors uint64
+}
+
+// NsPerOp is the average duration of a request, including time spent
+// waiting for a handler.
+func (r *BenchmarkResult) NsPerOp() int64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	return r.Duration.Nanoseconds() / int64(r.Requests)
+}
+
+// String returns the result in a format similar to "go test -bench".
+func (r *BenchmarkResult) String() string {
+	return fmt.Sprintf("%s-%d\t%d\t%d ns/op\t%d B/op\t%d allocs/op\t%d instantiate-ns\t%d errors",
+		r.Name, r.Concurrency, r.Requests, r.NsPerOp(), r.BytesPerOp, r.AllocsPerOp,
+		r.Instantiation.Nanoseconds(), r.Errors)
+}
+
+// Run serves the given count of requests of the scenario with concurrency
+// handlers in parallel, as a load generator.
+func Run(ctx context.Context, s Scenario, concurrency, requests int) (*BenchmarkResult, error) {
+	if concurrency < 1 {
+		return nil, fmt.Errorf("bench: concurrency must be at least one")
+	}
+	mw, err := wasm.NewMiddleware(ctx, s.Guest, s.Options...)
+	if err != nil {
+		return nil, err
+	}
+	defer mw.Close(ctx)
+
+	result := &BenchmarkResult{Name: s.Name, Concurrency: concurrency, Requests: requests}
+
+	handlers := make([]wasm.Handler, concurrency)
+	start := time.Now()
+	for i := range handlers {
+		if handlers[i], err = mw.NewHandler(ctx, next); err != nil {
+			return nil, err
+		}
+		defer handlers[i].Close(ctx)
+	}
+	result.Instantiation = time.Since(start) / time.Duration(concurrency)
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+
+	var remaining int64 = int64(requests)
+	var wg sync.WaitGroup
+	start = time.Now()
+	for _, h := range handlers {
+		wg.Add(1)
+		go func(h wasm.Handler) {
+			defer wg.Done()
+			for atomic.AddInt64(&remaining, -1) >= 0 {
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, s.request())
+				if w.Code != http.StatusOK {
+					atomic.AddUint64(&result.Errors, 1)
+				}
+			}
+		}(h)
+	}
+	wg.Wait()
+	result.Duration = time.Since(start)
+
+	runtime.ReadMemStats(&after)
+	if requests > 0 {
+		result.AllocsPerOp = (after.Mallocs - before.Mallocs) / uint64(requests)
+		result.BytesPerOp = (after.TotalAlloc - before.TotalAlloc) / uint64(requests)
+	}
+	return result, nil
+}
+
+// Benchmark benchmarks the scenario, serving requests in parallel with a
+// handler per goroutine, and reporting allocations and the nanoseconds to
+// create a handler as "instantiate-ns".
+func Benchmark(b *testing.B, s Scenario) {
+	ctx := context.Background()
+	mw, err := wasm.NewMiddleware(ctx, s.Guest, s.Options...)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer mw.Close(ctx)
+
+	var instantiations, instantiationNs int64
+	b.ReportAllocs()
+	if s.Body != nil {
+		b.SetBytes(int64(len(s.Body)))
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		// Handlers aren't safe for concurrent use, so each goroutine needs
+		// its own.
+		start := time.Now()
+		h, err := mw.NewHandler(ctx, next)
+		if err != nil {
+			b.Error(err)
+			return
+		}
+		atomic.AddInt64(&instantiationNs, time.Since(start).Nanoseconds())
+		atomic.AddInt64(&instantiations, 1)
+		defer h.Close(ctx)
+
+		for pb.Next() {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, s.request())
+			if w.Code != http.StatusOK {
+				b.Errorf("unexpected status %d", w.Code)
+				return
+			}
+		}
+	})
+	if instantiations > 0 {
+		b.ReportMetric(float64(instantiationNs/instantiations), "instantiate-ns")
+	}
+}
@@ -0,0 +1,77 @@
+package bench
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	wasm "github.com/http-wasm/http-wasm-host-go/handler/nethttp"
+)
+
+var testCtx = context.Background()
+
+// BenchmarkScenarios compares the overhead of guests by what they do. For
+// example, with "go test -run='^$' -bench=. ./bench":
+//
+//	BenchmarkScenarios/noop     9595 ns/op  128826 instantiate-ns  10437 B/op  23 allocs/op
+//	BenchmarkScenarios/header  15787 ns/op  110508 instantiate-ns  11672 B/op  50 allocs/op
+//	BenchmarkScenarios/rewrite 23662 ns/op  43.11 MB/s  93637 instantiate-ns  15856 B/op  42 allocs/op
+func BenchmarkScenarios(b *testing.B) {
+	for _, s := range Scenarios() {
+		s := s
+		b.Run(s.Name, func(b *testing.B) {
+			Benchmark(b, s)
+		})
+	}
+}
+
+func TestScenarios(t *testing.T) {
+	expected := map[string]struct{ header, body string }{
+		"noop":    {body: "hello"},
+		"header":  {header: "1234", body: "hello"},
+		"rewrite": {body: strings.Repeat("HELLO ", 1024/6)},
+	}
+
+	for _, tt := range Scenarios() {
+		s := tt
+		t.Run(s.Name, func(t *testing.T) {
+			mw, err := wasm.NewMiddleware(testCtx, s.Guest, s.Options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mw.Close(testCtx)
+			h, err := mw.NewHandler(testCtx, next)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer h.Close(testCtx)
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, s.request())
+			if have := w.Header().Get("X-Request-ID"); have != expected[s.Name].header {
+				t.Fatalf("expected header %q, have %q", expected[s.Name].header, have)
+			}
+			if have := w.Body.String(); have != expected[s.Name].body {
+				t.Fatalf("expected body %q, have %q", expected[s.Name].body, have)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	r, err := Run(testCtx, Scenarios()[1], 4, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Errors != 0 || r.Requests != 100 || r.Instantiation <= 0 || r.AllocsPerOp == 0 {
+		t.Fatalf("unexpected result: %s", r)
+	}
+	if !strings.HasPrefix(r.String(), "header-4\t100\t") {
+		t.Fatalf("unexpected format: %s", r)
+	}
+
+	if _, err = Run(testCtx, Scenarios()[0], 0, 1); err == nil {
+		t.Fatal("expected an error without concurrency")
+	}
+}
@@ -0,0 +1,56 @@
+;; This module copies the request header "X-Request-ID" to the response, if
+;; present, before invoking the next handler. This is typical of guests that
+;; only inspect and set headers.
+(module $header
+
+  ;; read_request_header writes a header value to memory if it exists and
+  ;; isn't larger than the buffer size limit. The result is `1<<32|value_len`
+  ;; or zero if the header doesn't exist.
+  (import "http-handler" "read_request_header"
+    (func $read_request_header
+      (param $name i32) (param $name_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; 0 or 1 << 32| value_len ;) i64)))
+
+  ;; set_response_header sets a response header from a name and value read
+  ;; from memory
+  (import "http-handler" "set_response_header"
+    (func $set_response_header
+      (param $name i32) (param $name_len i32)
+      (param $value i32) (param $value_len i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "read_request_header" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (global $name i32 (i32.const 0))
+  (data (i32.const 0) "X-Request-ID")
+  (global $name_len i32 (i32.const 12))
+
+  ;; buf is an arbitrary area to write data
+  (global $buf i32 (i32.const 1024))
+  (global $buf_limit i32 (i32.const 1024))
+
+  (func $handle (export "handle")
+    (local $result i64)
+    (local $value_len i32)
+
+    (local.set $result
+      (call $read_request_header
+        (global.get $name) (global.get $name_len)
+        (global.get $buf) (global.get $buf_limit)))
+
+    (if (i64.ne (local.get $result) (i64.const 0))
+      (then
+        (local.set $value_len (i32.wrap_i64 (local.get $result)))
+        (if (i32.le_u (local.get $value_len) (global.get $buf_limit))
+          (then
+            (call $set_response_header
+              (global.get $name) (global.get $name_len)
+              (global.get $buf) (local.get $value_len))))))
+
+    (call $next))
+)
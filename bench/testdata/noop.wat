@@ -0,0 +1,13 @@
+;; This module is the baseline of package bench: it only invokes the next
+;; handler, so measures the overhead of the host alone.
+(module $noop
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory".
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (func $handle (export "handle")
+    (call $next))
+)
@@ -0,0 +1,245 @@
+// Command httpwasm validates and dry-runs http-wasm guests, so that guest
+// authors can find problems before deploying them.
+//
+// To check a guest implements the ABI, and print the capabilities it requires:
+//
+//	httpwasm validate guest.wasm
+//
+// To handle a sample request with the guest, and print the response:
+//
+//	httpwasm run -X POST -H "Content-Type: application/json" -d '{}' guest.wasm
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	wasm "github.com/http-wasm/http-wasm-host-go/handler/nethttp"
+)
+
+const usage = `usage: httpwasm <command> [flags] <guest.wasm>
+
+commands:
+  validate  checks the guest implements the ABI and prints the capabilities it
+            requires
+  run       handles a sample request with the guest and prints the response
+
+Run "httpwasm <command> -h" for the flags of a command.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command in args, returning the exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "validate":
+		err = validate(args[1:], stdout, stderr)
+	case "run":
+		err = dryRun(args[1:], stdin, stdout, stderr)
+	case "-h", "-help", "--help", "help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	} else if errors.Is(err, errUsage) {
+		return 2
+	} else if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// errUsage is returned when flags are invalid, after printing the usage.
+var errUsage = errors.New("invalid usage")
+
+// parseFlags parses the flags of the command, returning the guest read from
+// the only argument.
+func parseFlags(fs *flag.FlagSet, args []string) ([]byte, error) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil, err
+		}
+		return nil, errUsage
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintf(fs.Output(), "expected one guest, have %d\n", fs.NArg())
+		fs.Usage()
+		return nil, errUsage
+	}
+	return os.ReadFile(fs.Arg(0))
+}
+
+// validate instantiates the guest, which fails unless it implements the ABI,
+// and prints the capabilities it requires.
+func validate(args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	config := fs.String("config", "", "guest config, as passed to httpwasm.GuestConfigBytes")
+	guest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	capabilities, err := requiredCapabilities(ctx, guest)
+	if err != nil {
+		return err
+	}
+
+	mw, err := wasm.NewMiddleware(ctx, guest, httpwasm.GuestConfigBytes([]byte(*config)))
+	if err != nil {
+		return err
+	}
+	defer mw.Close(ctx)
+
+	// Ping instantiates the guest, which fails if it imports functions the
+	// host doesn't implement, or traps in handler.FuncInit.
+	if err = mw.(handler.Pinger).Ping(ctx); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(stdout, "%s: ok\n", fs.Arg(0))
+	if len(capabilities) == 0 {
+		fmt.Fprintln(stdout, "capabilities: none")
+	} else {
+		fmt.Fprintf(stdout, "capabilities: %s\n", strings.Join(capabilities, ", "))
+	}
+	return nil
+}
+
+// requiredCapabilities returns the sorted names of capabilities of the host
+// functions the guest imports.
+func requiredCapabilities(ctx context.Context, guest []byte) ([]string, error) {
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+
+	compiled, err := r.CompileModule(ctx, guest)
+	if err != nil {
+		return nil, fmt.Errorf("wasm: error compiling guest: %w", err)
+	}
+
+	seen := map[handler.Capability]bool{}
+	var capabilities []string
+	for _, f := range compiled.ImportedFunctions() {
+		module, name, _ := f.Import()
+		if module != handler.HostModule {
+			continue
+		}
+		if c, ok := handler.CapabilityOf(name); ok && !seen[c] {
+			seen[c] = true
+			capabilities = append(capabilities, string(c))
+		}
+	}
+	sort.Strings(capabilities)
+	return capabilities, nil
+}
+
+// headerFlags are repeated "Name: value" flags.
+type headerFlags []string
+
+// String implements flag.Value.
+func (h *headerFlags) String() string {
+	return strings.Join(*h, ", ")
+}
+
+// Set implements flag.Value.
+func (h *headerFlags) Set(value string) error {
+	if !strings.Contains(value, ":") {
+		return fmt.Errorf("expected \"Name: value\", have %q", value)
+	}
+	*h = append(*h, value)
+	return nil
+}
+
+// dryRun handles a sample request with the guest, printing the response in
+// HTTP/1.1 wire format. The next handler responds with the configured status
+// and body.
+func dryRun(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	method := fs.String("X", http.MethodGet, "request method")
+	url := fs.String("url", "http://localhost/", "request URL")
+	var headers headerFlags
+	fs.Var(&headers, "H", "request header in the format \"Name: value\", which may be repeated")
+	data := fs.String("d", "", "request body")
+	dataFile := fs.String("data-file", "", "file to read the request body from, or \"-\" for stdin")
+	config := fs.String("config", "", "guest config, as passed to httpwasm.GuestConfigBytes")
+	nextStatus := fs.Int("next-status", http.StatusOK, "status code of the next handler")
+	nextBody := fs.String("next-body", "", "response body of the next handler")
+	guest, err := parseFlags(fs, args)
+	if err != nil {
+		return err
+	}
+
+	body := []byte(*data)
+	switch *dataFile {
+	case "":
+	case "-":
+		if body, err = io.ReadAll(stdin); err != nil {
+			return err
+		}
+	default:
+		if body, err = os.ReadFile(*dataFile); err != nil {
+			return err
+		}
+	}
+
+	req := httptest.NewRequest(*method, *url, bytes.NewReader(body))
+	for _, h := range headers {
+		name, value, _ := strings.Cut(h, ":")
+		req.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+
+	ctx := context.Background()
+	mw, err := wasm.NewMiddleware(ctx, guest,
+		httpwasm.GuestConfigBytes([]byte(*config)),
+		httpwasm.Logger(func(_ context.Context, msg string) {
+			fmt.Fprintf(stderr, "guest: %s\n", msg)
+		}))
+	if err != nil {
+		return err
+	}
+	defer mw.Close(ctx)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprintln(stderr, "next handler invoked")
+		w.WriteHeader(*nextStatus)
+		w.Write([]byte(*nextBody)) // nolint
+	})
+	h, err := mw.NewHandler(ctx, next)
+	if err != nil {
+		return err
+	}
+	defer h.Close(ctx)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w.Result().Write(stdout)
+}
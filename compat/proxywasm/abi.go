@@ -0,0 +1,517 @@
+package proxywasm
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// Functions exported by the guest.
+const (
+	funcInitialize        = "_initialize"
+	funcMalloc            = "malloc"
+	funcOnMemoryAllocate  = "proxy_on_memory_allocate"
+	funcOnContextCreate   = "proxy_on_context_create"
+	funcOnVMStart         = "proxy_on_vm_start"
+	funcOnConfigure       = "proxy_on_configure"
+	funcOnRequestHeaders  = "proxy_on_request_headers"
+	funcOnRequestBody     = "proxy_on_request_body"
+	funcOnResponseHeaders = "proxy_on_response_headers"
+	funcOnResponseBody    = "proxy_on_response_body"
+	funcOnLog             = "proxy_on_log"
+	funcOnDone            = "proxy_on_done"
+	funcOnDelete          = "proxy_on_delete"
+)
+
+// status is the proxy-wasm WasmResult returned by host functions.
+type status = uint32
+
+const (
+	statusOK                  status = 0
+	statusNotFound            status = 1
+	statusBadArgument         status = 2
+	statusInvalidMemoryAccess status = 6
+	statusInternalFailure     status = 10
+	statusUnimplemented       status = 12
+)
+
+// Values of the proxy-wasm MapType, of headers and trailers.
+const (
+	mapRequestHeaders   = 0
+	mapRequestTrailers  = 1
+	mapResponseHeaders  = 2
+	mapResponseTrailers = 3
+)
+
+// Values of the proxy-wasm BufferType.
+const (
+	bufferRequestBody  = 0
+	bufferResponseBody = 1
+	bufferVMConfig     = 6
+	bufferPluginConfig = 7
+)
+
+const (
+	// logLevelTrace is the most verbose log level, so guests log everything.
+	logLevelTrace = 0
+
+	// maxSerializedLength limits the size of serialized header pairs.
+	maxSerializedLength = 1 << 24
+)
+
+// compileHost compiles HostModule, which includes functions of the proxy-wasm
+// ABI that guests commonly import, even if unimplemented.
+func (r *Runtime) compileHost(ctx context.Context) (wazero.CompiledModule, error) {
+	return r.runtime.NewHostModuleBuilder(HostModule).
+		ExportFunction("proxy_log", r.log,
+			"proxy_log", "level", "message", "message_size").
+		ExportFunction("proxy_get_log_level", r.getLogLevel,
+			"proxy_get_log_level", "return_level").
+		ExportFunction("proxy_get_current_time_nanoseconds", r.getCurrentTimeNanoseconds,
+			"proxy_get_current_time_nanoseconds", "return_time").
+		ExportFunction("proxy_get_header_map_value", r.getHeaderMapValue,
+			"proxy_get_header_map_value", "map_type", "key", "key_size", "return_value", "return_value_size").
+		ExportFunction("proxy_add_header_map_value", r.replaceHeaderMapValue,
+			"proxy_add_header_map_value", "map_type", "key", "key_size", "value", "value_size").
+		ExportFunction("proxy_replace_header_map_value", r.replaceHeaderMapValue,
+			"proxy_replace_header_map_value", "map_type", "key", "key_size", "value", "value_size").
+		ExportFunction("proxy_remove_header_map_value", unimplemented3,
+			"proxy_remove_header_map_value", "map_type", "key", "key_size").
+		ExportFunction("proxy_get_header_map_pairs", unimplemented3,
+			"proxy_get_header_map_pairs", "map_type", "return_map", "return_map_size").
+		ExportFunction("proxy_set_header_map_pairs", r.setHeaderMapPairs,
+			"proxy_set_header_map_pairs", "map_type", "map", "map_size").
+		ExportFunction("proxy_get_header_map_size", unimplemented2,
+			"proxy_get_header_map_size", "map_type", "return_size").
+		ExportFunction("proxy_get_buffer_bytes", r.getBufferBytes,
+			"proxy_get_buffer_bytes", "buffer_type", "start", "max_size", "return_buffer", "return_buffer_size").
+		ExportFunction("proxy_get_buffer_status", r.getBufferStatus,
+			"proxy_get_buffer_status", "buffer_type", "return_length", "return_flags").
+		ExportFunction("proxy_set_buffer_bytes", r.setBufferBytes,
+			"proxy_set_buffer_bytes", "buffer_type", "start", "size", "buffer", "buffer_size").
+		ExportFunction("proxy_get_configuration", r.getConfiguration,
+			"proxy_get_configuration", "return_buffer", "return_buffer_size").
+		ExportFunction("proxy_send_local_response", r.sendLocalResponse,
+			"proxy_send_local_response", "status_code", "status_code_details", "status_code_details_size",
+			"body", "body_size", "headers", "headers_size", "grpc_status").
+		ExportFunction("proxy_get_property", r.getProperty,
+			"proxy_get_property", "path", "path_size", "return_value", "return_value_size").
+		ExportFunction("proxy_set_property", r.setProperty,
+			"proxy_set_property", "path", "path_size", "value", "value_size").
+		ExportFunction("proxy_set_effective_context", ok1,
+			"proxy_set_effective_context", "context_id").
+		ExportFunction("proxy_continue_stream", ok1,
+			"proxy_continue_stream", "stream_type").
+		ExportFunction("proxy_close_stream", ok1,
+			"proxy_close_stream", "stream_type").
+		ExportFunction("proxy_continue_request", ok0,
+			"proxy_continue_request").
+		ExportFunction("proxy_continue_response", ok0,
+			"proxy_continue_response").
+		ExportFunction("proxy_done", ok0,
+			"proxy_done").
+		ExportFunction("proxy_set_tick_period_milliseconds", unimplemented1,
+			"proxy_set_tick_period_milliseconds", "period").
+		ExportFunction("proxy_http_call", unimplemented10,
+			"proxy_http_call", "upstream", "upstream_size", "headers", "headers_size", "body", "body_size",
+			"trailers", "trailers_size", "timeout_ms", "return_callout_id").
+		ExportFunction("proxy_get_shared_data", unimplemented5,
+			"proxy_get_shared_data", "key", "key_size", "return_value", "return_value_size", "return_cas").
+		ExportFunction("proxy_set_shared_data", unimplemented5,
+			"proxy_set_shared_data", "key", "key_size", "value", "value_size", "cas").
+		ExportFunction("proxy_register_shared_queue", unimplemented3,
+			"proxy_register_shared_queue", "name", "name_size", "return_queue_id").
+		ExportFunction("proxy_resolve_shared_queue", unimplemented5,
+			"proxy_resolve_shared_queue", "vm_id", "vm_id_size", "name", "name_size", "return_queue_id").
+		ExportFunction("proxy_enqueue_shared_queue", unimplemented3,
+			"proxy_enqueue_shared_queue", "queue_id", "value", "value_size").
+		ExportFunction("proxy_dequeue_shared_queue", unimplemented3,
+			"proxy_dequeue_shared_queue", "queue_id", "return_value", "return_value_size").
+		ExportFunction("proxy_define_metric", unimplemented4,
+			"proxy_define_metric", "metric_type", "name", "name_size", "return_metric_id").
+		ExportFunction("proxy_increment_metric", unimplementedMetric,
+			"proxy_increment_metric", "metric_id", "offset").
+		ExportFunction("proxy_record_metric", unimplementedMetric,
+			"proxy_record_metric", "metric_id", "value").
+		ExportFunction("proxy_get_metric", unimplemented2,
+			"proxy_get_metric", "metric_id", "return_value").
+		ExportFunction("proxy_call_foreign_function", unimplemented6,
+			"proxy_call_foreign_function", "name", "name_size", "args", "args_size", "return_results", "return_results_size").
+		Compile(ctx)
+}
+
+// log implements the host function "proxy_log". All levels are logged.
+func (r *Runtime) log(ctx context.Context, mod wazeroapi.Module, _, message, messageSize uint32) status {
+	msg, ok := mod.Memory().Read(ctx, message, messageSize)
+	if !ok {
+		return statusInvalidMemoryAccess
+	}
+	r.logFn(ctx, string(msg))
+	return statusOK
+}
+
+// getLogLevel implements the host function "proxy_get_log_level".
+func (r *Runtime) getLogLevel(ctx context.Context, mod wazeroapi.Module, returnLevel uint32) status {
+	if !mod.Memory().WriteUint32Le(ctx, returnLevel, logLevelTrace) {
+		return statusInvalidMemoryAccess
+	}
+	return statusOK
+}
+
+// getCurrentTimeNanoseconds implements the host function
+// "proxy_get_current_time_nanoseconds".
+func (r *Runtime) getCurrentTimeNanoseconds(ctx context.Context, mod wazeroapi.Module, returnTime uint32) status {
+	if !mod.Memory().WriteUint64Le(ctx, returnTime, uint64(r.clock.Now().UnixNano())) {
+		return statusInvalidMemoryAccess
+	}
+	return statusOK
+}
+
+// getHeaderMapValue implements the host function
+// "proxy_get_header_map_value". Only request headers and trailers are
+// readable.
+func (r *Runtime) getHeaderMapValue(ctx context.Context, mod wazeroapi.Module, mapType, key, keySize, returnValue, returnValueSize uint32) status {
+	name, ok := mod.Memory().Read(ctx, key, keySize)
+	if !ok {
+		return statusInvalidMemoryAccess
+	}
+
+	var value string
+	switch mapType {
+	case mapRequestHeaders:
+		value, ok = r.host.GetRequestHeader(ctx, string(name))
+	case mapRequestTrailers:
+		t, supported := r.host.(handler.Trailers)
+		if !supported {
+			return statusNotFound
+		}
+		value, ok = t.GetRequestTrailer(ctx, string(name))
+	case mapResponseHeaders, mapResponseTrailers:
+		return statusUnimplemented
+	default:
+		return statusBadArgument
+	}
+	if !ok {
+		return statusNotFound
+	}
+	return returnBytes(ctx, mod, []byte(value), returnValue, returnValueSize)
+}
+
+// replaceHeaderMapValue implements the host functions
+// "proxy_add_header_map_value" and "proxy_replace_header_map_value". Only
+// response headers and trailers are writable, and adding a value replaces
+// any existing one.
+func (r *Runtime) replaceHeaderMapValue(ctx context.Context, mod wazeroapi.Module, mapType, key, keySize, value, valueSize uint32) status {
+	name, ok := mod.Memory().Read(ctx, key, keySize)
+	if !ok {
+		return statusInvalidMemoryAccess
+	}
+	v, ok := mod.Memory().Read(ctx, value, valueSize)
+	if !ok {
+		return statusInvalidMemoryAccess
+	}
+	return r.setHeader(ctx, mapType, string(name), string(v))
+}
+
+func (r *Runtime) setHeader(ctx context.Context, mapType uint32, name, value string) status {
+	switch mapType {
+	case mapResponseHeaders:
+		r.host.SetResponseHeader(ctx, name, value)
+	case mapResponseTrailers:
+		t, ok := r.host.(handler.Trailers)
+		if !ok {
+			return statusUnimplemented
+		}
+		t.SetResponseTrailer(ctx, name, value)
+	case mapRequestHeaders, mapRequestTrailers:
+		return statusUnimplemented
+	default:
+		return statusBadArgument
+	}
+	return statusOK
+}
+
+// setHeaderMapPairs implements the host function "proxy_set_header_map_pairs".
+// Like replaceHeaderMapValue, only response headers and trailers are writable.
+func (r *Runtime) setHeaderMapPairs(ctx context.Context, mod wazeroapi.Module, mapType, pairs, pairsSize uint32) status {
+	buf, ok := mod.Memory().Read(ctx, pairs, pairsSize)
+	if !ok {
+		return statusInvalidMemoryAccess
+	}
+	m, ok := decodePairs(buf)
+	if !ok {
+		return statusBadArgument
+	}
+	for _, kv := range m {
+		if s := r.setHeader(ctx, mapType, kv[0], kv[1]); s != statusOK {
+			return s
+		}
+	}
+	return statusOK
+}
+
+// getBufferBytes implements the host function "proxy_get_buffer_bytes".
+func (r *Runtime) getBufferBytes(ctx context.Context, mod wazeroapi.Module, bufferType, start, maxSize, returnBuffer, returnBufferSize uint32) status {
+	if bufferType == bufferRequestBody {
+		buf, s := readRequestBody(ctx, start, maxSize)
+		if s != statusOK {
+			return s
+		}
+		return returnBytes(ctx, mod, buf, returnBuffer, returnBufferSize)
+	}
+	buf, s := r.buffer(ctx, bufferType)
+	if s != statusOK {
+		return s
+	}
+	if start > uint32(len(buf)) {
+		return statusBadArgument
+	}
+	buf = buf[start:]
+	if uint32(len(buf)) > maxSize {
+		buf = buf[:maxSize]
+	}
+	return returnBytes(ctx, mod, buf, returnBuffer, returnBufferSize)
+}
+
+// getBufferStatus implements the host function "proxy_get_buffer_status". The
+// flags are always zero, as bodies are always complete.
+func (r *Runtime) getBufferStatus(ctx context.Context, mod wazeroapi.Module, bufferType, returnLength, returnFlags uint32) status {
+	var length int64
+	if bufferType == bufferRequestBody {
+		length = stateFromContext(ctx).requestBodySize
+	} else {
+		buf, s := r.buffer(ctx, bufferType)
+		if s != statusOK {
+			return s
+		}
+		length = int64(len(buf))
+	}
+	if length > math.MaxUint32 {
+		return statusBadArgument
+	}
+	if !mod.Memory().WriteUint32Le(ctx, returnLength, uint32(length)) ||
+		!mod.Memory().WriteUint32Le(ctx, returnFlags, 0) {
+		return statusInvalidMemoryAccess
+	}
+	return statusOK
+}
+
+// readRequestBody reads up to maxSize bytes of the request body, starting at
+// start, so that only what the guest requested is in memory.
+func readRequestBody(ctx context.Context, start, maxSize uint32) ([]byte, status) {
+	s := stateFromContext(ctx)
+	if int64(start) > s.requestBodySize {
+		return nil, statusBadArgument
+	}
+	size := s.requestBodySize - int64(start)
+	if size > int64(maxSize) {
+		size = int64(maxSize)
+	}
+	buf := make([]byte, size)
+	if _, err := s.requestBody.ReadAt(buf, int64(start)); err != nil && err != io.EOF {
+		return nil, statusInternalFailure
+	}
+	return buf, statusOK
+}
+
+// buffer returns the contents of the buffer type, other than the request
+// body, which is read by readRequestBody.
+func (r *Runtime) buffer(ctx context.Context, bufferType uint32) ([]byte, status) {
+	switch bufferType {
+	case bufferResponseBody:
+		return stateFromContext(ctx).responseBody, statusOK
+	case bufferVMConfig:
+		return nil, statusOK
+	case bufferPluginConfig:
+		return r.pluginConfig, statusOK
+	default:
+		return nil, statusUnimplemented
+	}
+}
+
+// setBufferBytes implements the host function "proxy_set_buffer_bytes". Only
+// the response body can be replaced, and only entirely.
+func (r *Runtime) setBufferBytes(ctx context.Context, mod wazeroapi.Module, bufferType, start, size, buffer, bufferSize uint32) status {
+	if bufferType != bufferResponseBody {
+		return statusUnimplemented
+	}
+	s := stateFromContext(ctx)
+	if start != 0 || size < uint32(len(s.responseBody)) {
+		return statusUnimplemented
+	}
+	body, ok := mod.Memory().Read(ctx, buffer, bufferSize)
+	if !ok {
+		return statusInvalidMemoryAccess
+	}
+	s.responseBody = append(s.responseBody[:0], body...)
+	r.host.SendResponse(ctx, r.host.GetStatusCode(ctx), s.responseBody)
+	return statusOK
+}
+
+// getConfiguration implements the host function "proxy_get_configuration" of
+// ABI v0.1.0, returning the plugin configuration.
+func (r *Runtime) getConfiguration(ctx context.Context, mod wazeroapi.Module, returnBuffer, returnBufferSize uint32) status {
+	return returnBytes(ctx, mod, r.pluginConfig, returnBuffer, returnBufferSize)
+}
+
+// sendLocalResponse implements the host function "proxy_send_local_response",
+// which responds instead of the next handler. The details and gRPC status
+// are ignored.
+func (r *Runtime) sendLocalResponse(ctx context.Context, mod wazeroapi.Module, statusCode, _, _, body, bodySize, headers, headersSize, _ uint32) status {
+	b, ok := mod.Memory().Read(ctx, body, bodySize)
+	if !ok {
+		return statusInvalidMemoryAccess
+	}
+	buf, ok := mod.Memory().Read(ctx, headers, headersSize)
+	if !ok {
+		return statusInvalidMemoryAccess
+	}
+	pairs, ok := decodePairs(buf)
+	if !ok {
+		return statusBadArgument
+	}
+	for _, kv := range pairs {
+		r.host.SetResponseHeader(ctx, kv[0], kv[1])
+	}
+	r.host.SendResponse(ctx, statusCode, b)
+	stateFromContext(ctx).localResponse = true
+	return statusOK
+}
+
+// getProperty implements the host function "proxy_get_property", reading
+// handler.Properties. The path segments are joined with dots, so the
+// path "request\0id" reads the property "request.id".
+func (r *Runtime) getProperty(ctx context.Context, mod wazeroapi.Module, path, pathSize, returnValue, returnValueSize uint32) status {
+	p, ok := mod.Memory().Read(ctx, path, pathSize)
+	if !ok {
+		return statusInvalidMemoryAccess
+	}
+	props, ok := r.host.(handler.Properties)
+	if !ok {
+		return statusNotFound
+	}
+	value, ok := props.GetProperties(ctx)[propertyName(p)]
+	if !ok {
+		return statusNotFound
+	}
+	return returnBytes(ctx, mod, []byte(value), returnValue, returnValueSize)
+}
+
+// setProperty implements the host function "proxy_set_property", writing
+// handler.Properties named the same as getProperty.
+func (r *Runtime) setProperty(ctx context.Context, mod wazeroapi.Module, path, pathSize, value, valueSize uint32) status {
+	p, ok := mod.Memory().Read(ctx, path, pathSize)
+	if !ok {
+		return statusInvalidMemoryAccess
+	}
+	v, ok := mod.Memory().Read(ctx, value, valueSize)
+	if !ok {
+		return statusInvalidMemoryAccess
+	}
+	props, ok := r.host.(handler.Properties)
+	if !ok {
+		return statusUnimplemented
+	}
+	props.SetProperty(ctx, propertyName(p), string(v))
+	return statusOK
+}
+
+func propertyName(path []byte) string {
+	return strings.ReplaceAll(strings.TrimRight(string(path), "\x00"), "\x00", ".")
+}
+
+// returnBytes copies the value into memory allocated by the guest, writing
+// its pointer and size to the return parameters.
+func returnBytes(ctx context.Context, mod wazeroapi.Module, value []byte, returnPtr, returnSize uint32) status {
+	var ptr uint32
+	if len(value) > 0 {
+		alloc := mod.ExportedFunction(funcOnMemoryAllocate)
+		if alloc == nil {
+			alloc = mod.ExportedFunction(funcMalloc)
+		}
+		results, err := alloc.Call(ctx, uint64(len(value)))
+		if err != nil {
+			panic(err)
+		}
+		ptr = uint32(results[0])
+		if !mod.Memory().Write(ctx, ptr, value) {
+			return statusInvalidMemoryAccess
+		}
+	}
+	if !mod.Memory().WriteUint32Le(ctx, returnPtr, ptr) ||
+		!mod.Memory().WriteUint32Le(ctx, returnSize, uint32(len(value))) {
+		return statusInvalidMemoryAccess
+	}
+	return statusOK
+}
+
+// decodePairs decodes serialized header pairs, which are the count of pairs,
+// followed by the sizes of each key and value, followed by each key and value
+// terminated by NUL, all sizes being little-endian uint32. The result is
+// false if the encoding is invalid.
+func decodePairs(buf []byte) ([][2]string, bool) {
+	if len(buf) == 0 {
+		return nil, true
+	}
+	if len(buf) < 4 || len(buf) > maxSerializedLength {
+		return nil, false
+	}
+	count := binary.LittleEndian.Uint32(buf)
+	sizes := buf[4:]
+	if uint64(len(sizes)) < uint64(count)*8 {
+		return nil, false
+	}
+	data := sizes[count*8:]
+
+	pairs := make([][2]string, 0, count)
+	for i := uint32(0); i < count; i++ {
+		var pair [2]string
+		for j := range pair {
+			size := binary.LittleEndian.Uint32(sizes[i*8+uint32(j)*4:])
+			if uint64(len(data)) < uint64(size)+1 {
+				return nil, false
+			}
+			pair[j], data = string(data[:size]), data[size+1:]
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs, true
+}
+
+// Host functions of the proxy-wasm ABI which aren't implemented, by
+// signature.
+
+func ok0() status { return statusOK }
+
+func ok1(uint32) status { return statusOK }
+
+func unimplemented1(uint32) status { return statusUnimplemented }
+
+func unimplemented2(_, _ uint32) status { return statusUnimplemented }
+
+func unimplemented3(_, _, _ uint32) status { return statusUnimplemented }
+
+func unimplementedMetric(uint32, int64) status { return statusUnimplemented }
+
+func unimplemented4(_, _, _, _ uint32) status {
+	return statusUnimplemented
+}
+
+func unimplemented5(_, _, _, _, _ uint32) status {
+	return statusUnimplemented
+}
+
+func unimplemented6(_, _, _, _, _, _ uint32) status {
+	return statusUnimplemented
+}
+
+func unimplemented10(_, _, _, _, _, _, _, _, _, _ uint32) status {
+	return statusUnimplemented
+}
@@ -0,0 +1,472 @@
+// Package proxywasm runs guests written for the proxy-wasm ABI, such as Envoy
+// filters, by mapping its callbacks onto handler.Host. This allows existing
+// filters to run on any host of this module, such as net/http.
+//
+// See https://github.com/proxy-wasm/spec
+//
+// # Notes
+//
+// The following are limitations of mapping onto handler.Host:
+//   - Request headers and trailers are read-only, and can only be read by
+//     name, so header counts passed to the guest are zero.
+//   - Response headers and trailers are write-only.
+//   - Pausing isn't supported, as there's nothing to resume: a guest which
+//     pauses without sending a local response continues instead.
+//   - HTTP calls, gRPC, metrics, shared data, shared queues and timers are
+//     unimplemented, returning the status "Unimplemented" to the guest.
+package proxywasm
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/api"
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	"github.com/http-wasm/http-wasm-host-go/internal"
+	"github.com/http-wasm/http-wasm-host-go/internal/wasm"
+)
+
+// HostModule is the name of the module which implements the host side of
+// the proxy-wasm ABI.
+const HostModule = "env"
+
+// abiVersions are the function exports which declare the proxy-wasm ABI
+// version of a guest. At least one must be exported.
+var abiVersions = []string{
+	"proxy_abi_version_0_2_1",
+	"proxy_abi_version_0_2_0",
+	"proxy_abi_version_0_1_0",
+}
+
+// rootContextID is the ID of the root context of each guest, which is the
+// parent of the contexts of requests.
+const rootContextID = 1
+
+// Runtime compiles a proxy-wasm guest, instantiating it with NewGuest.
+type Runtime struct {
+	host                    handler.Host
+	runtime                 wazero.Runtime
+	hostModule, guestModule wazero.CompiledModule
+	config                  wazero.ModuleConfig
+	pluginConfig            []byte
+	logFn                   api.LogFunc
+	clock                   api.Clock
+	customSections          []wasm.CustomSection
+	metadata                handler.GuestMetadata
+	compileReport           api.CompileReport
+
+	guestErrorStatus uint32
+	failurePolicy    api.FailurePolicy
+	bodyLimits       internal.BodyLimits
+
+	// shared is true when the runtime is internal.WazeroOptions
+	// SharedRuntime, so isn't closed with this.
+	shared bool
+}
+
+// NewRuntime compiles the proxy-wasm guest, which reads httpwasm.GuestConfigBytes
+// as its plugin configuration. Options unrelated to the proxy-wasm ABI, such
+// as httpwasm.MirrorDestination, are ignored.
+func NewRuntime(ctx context.Context, guest []byte, host handler.Host, options ...httpwasm.Option) (*Runtime, error) {
+	o := &internal.WazeroOptions{
+		NewRuntime:   internal.DefaultRuntime,
+		RuntimeMode:  internal.DefaultRuntimeMode(),
+		ModuleConfig: wazero.NewModuleConfig(),
+		Logger:       func(context.Context, string) {},
+	}
+	for _, option := range options {
+		option(o)
+	}
+
+	if o.GuestVerifier != nil {
+		if err := o.GuestVerifier(guest); err != nil {
+			return nil, fmt.Errorf("wasm: error verifying guest: %w", err)
+		}
+	}
+
+	wr, err := o.CreateRuntime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Runtime{
+		host:             host,
+		runtime:          wr,
+		config:           o.ModuleConfig,
+		pluginConfig:     o.GuestConfig,
+		logFn:            o.Logger,
+		clock:            o.Clock,
+		guestErrorStatus: uint32(o.GuestErrorStatus),
+		failurePolicy:    o.FailurePolicy,
+		bodyLimits:       o.BodyLimits,
+		shared:           o.SharedRuntime != nil,
+	}
+	if r.clock == nil {
+		r.clock = systemClock{}
+	}
+	if r.guestErrorStatus == 0 {
+		r.guestErrorStatus = http.StatusInternalServerError
+	}
+
+	if r.hostModule, err = r.compileHost(ctx); err != nil {
+		_ = r.Close(ctx)
+		return nil, err
+	}
+	if r.customSections, err = wasm.CustomSections(guest); err != nil {
+		_ = r.Close(ctx)
+		return nil, fmt.Errorf("wasm: error reading guest: %w", err)
+	}
+	if r.metadata, err = wasm.Metadata(r.customSections); err != nil {
+		_ = r.Close(ctx)
+		return nil, fmt.Errorf("wasm: error reading guest: %w", err)
+	}
+	if r.guestModule, r.compileReport, err = o.CompileGuest(ctx, wr, guest); err != nil {
+		_ = r.Close(ctx)
+		return nil, err
+	}
+	if err = checkExports(r.guestModule); err != nil {
+		_ = r.Close(ctx)
+		return nil, err
+	}
+	return r, nil
+}
+
+// checkExports returns an error unless the guest exports what's required to
+// handle requests.
+func checkExports(guest wazero.CompiledModule) error {
+	exports := guest.ExportedFunctions()
+
+	var version bool
+	for _, name := range abiVersions {
+		if _, version = exports[name]; version {
+			break
+		}
+	}
+	if !version {
+		return fmt.Errorf("wasm: guest doesn't export func[%s]", abiVersions[0])
+	}
+
+	if _, ok := guest.ExportedMemories()[api.Memory]; !ok {
+		return &handler.InvalidGuestError{
+			Err:    handler.ErrMissingMemory,
+			Detail: fmt.Sprintf("guest doesn't export memory[%s]", api.Memory),
+		}
+	}
+	for _, name := range []string{funcOnContextCreate, funcOnMemoryAllocate} {
+		if _, ok := exports[name]; !ok {
+			if name == funcOnMemoryAllocate {
+				if _, ok = exports[funcMalloc]; ok {
+					continue
+				}
+			}
+			return fmt.Errorf("wasm: guest doesn't export func[%s]", name)
+		}
+	}
+	return nil
+}
+
+// CustomSection returns the data of the first custom section in the guest
+// with the given name, or false if there is none.
+func (r *Runtime) CustomSection(name string) ([]byte, bool) {
+	for _, s := range r.customSections {
+		if s.Name == name {
+			return s.Data, true
+		}
+	}
+	return nil, false
+}
+
+// GuestMetadata returns metadata of the guest from its custom sections.
+func (r *Runtime) GuestMetadata() handler.GuestMetadata {
+	return r.metadata
+}
+
+// CompileReport returns how the guest was compiled.
+func (r *Runtime) CompileReport() api.CompileReport {
+	return r.compileReport
+}
+
+// BodyLimits returns the limits of buffering request and response bodies,
+// which are zero unless configured.
+func (r *Runtime) BodyLimits() internal.BodyLimits {
+	return r.bodyLimits
+}
+
+// Close implements api.Closer
+func (r *Runtime) Close(ctx context.Context) error {
+	if !r.shared {
+		return r.runtime.Close(ctx)
+	}
+	for _, m := range []wazero.CompiledModule{r.hostModule, r.guestModule} {
+		if m != nil {
+			_ = m.Close(ctx)
+		}
+	}
+	return nil
+}
+
+// Guest is an instance of a proxy-wasm guest, which handles requests in
+// the order of its contexts. It isn't safe for concurrent use.
+type Guest struct {
+	r     *Runtime
+	ns    wazero.Namespace
+	guest wazeroapi.Module
+
+	// nextContextID is the ID of the context of the next request.
+	nextContextID uint64
+
+	// exports are the optional functions exported by the guest, or nil.
+	onRequestHeaders, onRequestBody    wazeroapi.Function
+	onResponseHeaders, onResponseBody  wazeroapi.Function
+	onLog, onDone, onDelete            wazeroapi.Function
+	requestHeadersV1, responseHeaderV1 bool
+}
+
+// NewGuest instantiates the guest and configures its root context, returning
+// an error if the guest rejected its configuration.
+func (r *Runtime) NewGuest(ctx context.Context) (*Guest, error) {
+	ns := r.runtime.NewNamespace(ctx)
+
+	// Note: host modules don't use configuration
+	if _, err := ns.InstantiateModule(ctx, r.hostModule, wazero.NewModuleConfig()); err != nil {
+		_ = ns.Close(ctx)
+		return nil, fmt.Errorf("wasm: error instantiating host: %w", err)
+	}
+
+	guest, err := ns.InstantiateModule(ctx, r.guestModule, r.config)
+	if err != nil {
+		_ = ns.Close(ctx)
+		return nil, fmt.Errorf("wasm: error instantiating guest: %w", err)
+	}
+
+	g := &Guest{
+		r:                 r,
+		ns:                ns,
+		guest:             guest,
+		nextContextID:     rootContextID + 1,
+		onRequestHeaders:  guest.ExportedFunction(funcOnRequestHeaders),
+		onRequestBody:     guest.ExportedFunction(funcOnRequestBody),
+		onResponseHeaders: guest.ExportedFunction(funcOnResponseHeaders),
+		onResponseBody:    guest.ExportedFunction(funcOnResponseBody),
+		onLog:             guest.ExportedFunction(funcOnLog),
+		onDone:            guest.ExportedFunction(funcOnDone),
+		onDelete:          guest.ExportedFunction(funcOnDelete),
+	}
+	// ABI v0.1.0 doesn't pass end_of_stream to header callbacks.
+	g.requestHeadersV1 = g.onRequestHeaders != nil && len(g.onRequestHeaders.Definition().ParamTypes()) == 2
+	g.responseHeaderV1 = g.onResponseHeaders != nil && len(g.onResponseHeaders.Definition().ParamTypes()) == 2
+
+	if err = g.start(ctx); err != nil {
+		_ = ns.Close(ctx)
+		return nil, err
+	}
+	return g, nil
+}
+
+// start initializes the guest and creates its root context.
+func (g *Guest) start(ctx context.Context) error {
+	ctx = withState(ctx, &state{})
+	if _, err := g.call(ctx, funcInitialize); err != nil {
+		return err
+	}
+	if _, err := g.call(ctx, funcOnContextCreate, rootContextID, 0); err != nil {
+		return err
+	}
+	if ok, err := g.call(ctx, funcOnVMStart, rootContextID, 0); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("wasm: guest failed to start")
+	}
+	if ok, err := g.call(ctx, funcOnConfigure, rootContextID, uint64(len(g.r.pluginConfig))); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("wasm: guest rejected its configuration")
+	}
+	return nil
+}
+
+// call calls the function exported by the guest with the name, if exported,
+// returning true if the guest returned non-zero, or didn't return anything.
+func (g *Guest) call(ctx context.Context, name string, params ...uint64) (bool, error) {
+	return callFunction(ctx, name, g.guest.ExportedFunction(name), params...)
+}
+
+func callFunction(ctx context.Context, name string, fn wazeroapi.Function, params ...uint64) (ok bool, err error) {
+	if fn == nil {
+		return true, nil // optional export
+	}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = &handler.GuestError{Func: name, Err: fmt.Errorf("%v", recovered)}
+		}
+	}()
+
+	results, err := fn.Call(ctx, params...)
+	if err != nil {
+		return false, &handler.GuestError{Func: name, Err: err}
+	}
+	return len(results) == 0 || results[0] != 0, nil
+}
+
+// Handle handles the current request in a new context of the guest, invoking
+// the next handler unless the guest sent a local response.
+//
+// When the guest traps, this responds according to configuration, then
+// returns a handler.GuestError.
+func (g *Guest) Handle(ctx context.Context) error {
+	s := &state{}
+	ctx = withState(ctx, s)
+
+	id := g.nextContextID
+	g.nextContextID++
+
+	err := g.handle(ctx, s, id)
+	if guestErr, ok := err.(*handler.GuestError); ok {
+		g.r.handleGuestError(ctx, guestErr, s.nextCalled)
+	}
+
+	// Always end the context, so that the guest can release its state.
+	if _, e := callFunction(ctx, funcOnLog, g.onLog, id); err == nil {
+		err = e
+	}
+	if _, e := callFunction(ctx, funcOnDone, g.onDone, id); err == nil {
+		err = e
+	}
+	if _, e := callFunction(ctx, funcOnDelete, g.onDelete, id); err == nil {
+		err = e
+	}
+	return err
+}
+
+func (g *Guest) handle(ctx context.Context, s *state, id uint64) (err error) {
+	if _, err = g.call(ctx, funcOnContextCreate, id, rootContextID); err != nil {
+		return
+	}
+
+	if g.requestHeadersV1 {
+		_, err = callFunction(ctx, funcOnRequestHeaders, g.onRequestHeaders, id, 0)
+	} else {
+		_, err = callFunction(ctx, funcOnRequestHeaders, g.onRequestHeaders, id, 0, boolToEOS(g.onRequestBody == nil))
+	}
+	if err != nil || s.localResponse {
+		return
+	}
+
+	if g.onRequestBody != nil {
+		s.requestBody, s.requestBodySize = requestBodyReaderAt(ctx, g.r.host)
+		if _, err = callFunction(ctx, funcOnRequestBody, g.onRequestBody, id, uint64(s.requestBodySize), 1); err != nil || s.localResponse {
+			return
+		}
+	}
+
+	// Buffer the response, so that the guest can change it after the next
+	// handler.
+	buffered := g.onResponseHeaders != nil || g.onResponseBody != nil
+	if buffered {
+		g.r.host.EnableFeatures(ctx, handler.FeatureBufferResponse)
+	}
+	s.nextCalled = true
+	g.r.host.Next(ctx)
+	if !buffered {
+		return
+	}
+
+	if g.responseHeaderV1 {
+		_, err = callFunction(ctx, funcOnResponseHeaders, g.onResponseHeaders, id, 0)
+	} else {
+		_, err = callFunction(ctx, funcOnResponseHeaders, g.onResponseHeaders, id, 0, boolToEOS(g.onResponseBody == nil))
+	}
+	if err != nil || s.localResponse || g.onResponseBody == nil {
+		return
+	}
+
+	s.responseBody = g.r.host.GetResponseBody(ctx)
+	_, err = callFunction(ctx, funcOnResponseBody, g.onResponseBody, id, uint64(len(s.responseBody)), 1)
+	return
+}
+
+// requestBodyReaderAt returns the request body and its size, without reading
+// it into memory if the host implements handler.RequestBodyReaderAt.
+func requestBodyReaderAt(ctx context.Context, host handler.Host) (io.ReaderAt, int64) {
+	if h, ok := host.(handler.RequestBodyReaderAt); ok {
+		return h.GetRequestBodyReaderAt(ctx)
+	}
+	body := host.GetRequestBody(ctx)
+	return bytes.NewReader(body), int64(len(body))
+}
+
+func boolToEOS(eos bool) uint64 {
+	if eos {
+		return 1
+	}
+	return 0
+}
+
+// handleGuestError logs the error, and invokes the next handler or responds,
+// according to configuration.
+func (r *Runtime) handleGuestError(ctx context.Context, err *handler.GuestError, nextCalled bool) {
+	r.logFn(ctx, err.Error())
+
+	if r.host.IsResponseCommitted(ctx) {
+		return // too late to change the response.
+	}
+	if r.failurePolicy == api.FailOpen {
+		if !nextCalled {
+			r.host.Next(ctx)
+		}
+		return
+	}
+	r.host.SendResponse(ctx, r.guestErrorStatus, nil)
+}
+
+// Close implements api.Closer
+func (g *Guest) Close(ctx context.Context) error {
+	return g.ns.Close(ctx)
+}
+
+// stateKey is a context.Context Value associated with a state pointer to the
+// current request, or configuration of the root context.
+type stateKey struct{}
+
+// state is what host functions need about the current request.
+type state struct {
+	// requestBody and responseBody are set before their guest callbacks. The
+	// request body is read at the offsets the guest requests, so that one
+	// spilled to a file isn't read into memory.
+	requestBody     io.ReaderAt
+	requestBodySize int64
+	responseBody    []byte
+	nextCalled      bool
+	// localResponse is true when the guest sent a local response.
+	localResponse bool
+}
+
+func withState(ctx context.Context, s *state) context.Context {
+	return context.WithValue(ctx, stateKey{}, s)
+}
+
+func stateFromContext(ctx context.Context) *state {
+	return ctx.Value(stateKey{}).(*state)
+}
+
+type systemClock struct{}
+
+// Now implements the same method as documented on api.Clock.
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Nanotime implements the same method as documented on api.Clock.
+func (systemClock) Nanotime() int64 {
+	return time.Since(start).Nanoseconds()
+}
+
+var start = time.Now()
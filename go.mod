@@ -2,4 +2,10 @@ module github.com/http-wasm/http-wasm-host-go
 
 go 1.18
 
-require github.com/tetratelabs/wazero v1.0.0-pre.2
+require (
+	github.com/fsnotify/fsnotify v1.6.0
+	github.com/tetratelabs/wazero v1.0.0-pre.2
+	golang.org/x/crypto v0.1.0
+)
+
+require golang.org/x/sys v0.1.0 // indirect
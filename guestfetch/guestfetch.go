@@ -0,0 +1,198 @@
+// Package guestfetch fetches guest binaries for NewMiddleware from HTTP URLs
+// and OCI registries.
+//
+// Downloads can be pinned to a content digest, in which case a mismatch is an
+// error. This allows gateways to pull wasm from the same registries they use
+// for container images, without trusting the transport alone.
+package guestfetch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DefaultMaxSize is the default limit of the size of a fetched guest.
+const DefaultMaxSize = 64 << 20 // 64 MiB
+
+// ErrDigestMismatch is returned when the content doesn't match the pinned
+// digest. See WithDigest.
+var ErrDigestMismatch = errors.New("guestfetch: digest mismatch")
+
+// Option is configuration for FetchHTTP and FetchOCI.
+type Option func(*options)
+
+type options struct {
+	client           *http.Client
+	digest           string
+	username, secret string
+	authHosts        []string
+	token            string
+	plainHTTP        bool
+	maxSize          int64
+}
+
+// WithClient sets the HTTP client used for requests. Defaults to
+// http.DefaultClient.
+func WithClient(client *http.Client) Option {
+	return func(o *options) {
+		o.client = client
+	}
+}
+
+// WithDigest pins the content to a digest in the format "sha256:<hex>". If
+// the fetched content doesn't match, ErrDigestMismatch is returned.
+func WithDigest(digest string) Option {
+	return func(o *options) {
+		o.digest = digest
+	}
+}
+
+// WithBasicAuth sets credentials sent with requests. For OCI registries,
+// these are also used to obtain a bearer token when challenged, if the token
+// realm is on the registry host or one allowed by WithAuthHosts.
+func WithBasicAuth(username, password string) Option {
+	return func(o *options) {
+		o.username, o.secret = username, password
+	}
+}
+
+// WithAuthHosts allows sending WithBasicAuth credentials to token realms on
+// these hosts, such as "auth.example.com", when an OCI registry delegates
+// authentication to another host. Realms on other hosts are sent no
+// credentials, so that a registry can't direct them elsewhere. The realm of
+// Docker Hub, "auth.docker.io", is always allowed for its registry.
+func WithAuthHosts(hosts ...string) Option {
+	return func(o *options) {
+		o.authHosts = append(o.authHosts, hosts...)
+	}
+}
+
+// WithBearerToken sets a token sent in the Authorization header.
+func WithBearerToken(token string) Option {
+	return func(o *options) {
+		o.token = token
+	}
+}
+
+// WithPlainHTTP uses "http" instead of "https" to connect to OCI registries.
+// This is only intended for local registries.
+func WithPlainHTTP() Option {
+	return func(o *options) {
+		o.plainHTTP = true
+	}
+}
+
+// WithMaxSize sets the maximum size in bytes of fetched content. Defaults to
+// DefaultMaxSize.
+func WithMaxSize(maxSize int64) Option {
+	return func(o *options) {
+		o.maxSize = maxSize
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{client: http.DefaultClient, maxSize: DefaultMaxSize}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// FetchHTTP downloads a guest from the URL.
+func FetchHTTP(ctx context.Context, url string, opts ...Option) ([]byte, error) {
+	o := newOptions(opts)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("guestfetch: %w", err)
+	}
+	o.authorize(req)
+
+	wasm, err := o.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err = verifyDigest(o.digest, wasm); err != nil {
+		return nil, err
+	}
+	return wasm, nil
+}
+
+func (o *options) authorize(req *http.Request) {
+	if o.token != "" {
+		req.Header.Set("Authorization", "Bearer "+o.token)
+	} else if o.username != "" {
+		req.SetBasicAuth(o.username, o.secret)
+	}
+}
+
+// do sends the request, returning the body if the status is OK.
+func (o *options) do(req *http.Request) ([]byte, error) {
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("guestfetch: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{URL: req.URL.String(), StatusCode: resp.StatusCode}
+	}
+	return o.readBody(resp)
+}
+
+func (o *options) readBody(resp *http.Response) ([]byte, error) {
+	// Read one more than the limit to detect overflow.
+	body, err := io.ReadAll(io.LimitReader(resp.Body, o.maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("guestfetch: %w", err)
+	} else if int64(len(body)) > o.maxSize {
+		return nil, fmt.Errorf("guestfetch: %s exceeds max size %d", resp.Request.URL, o.maxSize)
+	}
+	return body, nil
+}
+
+// StatusError is returned when the server responds with an unexpected
+// status code.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+// Error implements the same method as documented on error.
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("guestfetch: GET %s: unexpected status %d", e.URL, e.StatusCode)
+}
+
+// validDigest returns true if the digest is "sha256:" followed by 64 hex
+// digits, which is the only algorithm verifyDigest supports. This is checked
+// before a digest from a reference or manifest is used in a URL path.
+func validDigest(digest string) bool {
+	algorithm, encoded, ok := strings.Cut(digest, ":")
+	if !ok || algorithm != "sha256" || len(encoded) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(encoded)
+	return err == nil
+}
+
+// verifyDigest returns ErrDigestMismatch if digest is set and doesn't match
+// the content.
+func verifyDigest(digest string, content []byte) error {
+	if digest == "" {
+		return nil
+	}
+	algorithm, expected, ok := strings.Cut(digest, ":")
+	if !ok || algorithm != "sha256" {
+		return fmt.Errorf("guestfetch: unsupported digest %q", digest)
+	}
+	sum := sha256.Sum256(content)
+	if actual := hex.EncodeToString(sum[:]); !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("%w: expected %s, have sha256:%s", ErrDigestMismatch, digest, actual)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+package guestfetch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+var testCtx = context.Background()
+
+func digestOf(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func TestFetchHTTP(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "user" || pass != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write(test.LogWasm) // nolint
+	}))
+	defer ts.Close()
+
+	auth := WithBasicAuth("user", "pass")
+
+	wasm, err := FetchHTTP(testCtx, ts.URL, auth, WithDigest(digestOf(test.LogWasm)))
+	if err != nil {
+		t.Fatal(err)
+	} else if string(wasm) != string(test.LogWasm) {
+		t.Fatal("unexpected content")
+	}
+
+	if _, err = FetchHTTP(testCtx, ts.URL, auth, WithDigest(digestOf(test.AuthWasm))); !errors.Is(err, ErrDigestMismatch) {
+		t.Fatalf("expected ErrDigestMismatch, have %v", err)
+	}
+
+	if _, err = FetchHTTP(testCtx, ts.URL, auth, WithMaxSize(10)); err == nil {
+		t.Fatal("expected max size error")
+	}
+
+	var statusErr *StatusError
+	if _, err = FetchHTTP(testCtx, ts.URL); !errors.As(err, &statusErr) {
+		t.Fatalf("expected StatusError, have %v", err)
+	} else if statusErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected status 401, have %d", statusErr.StatusCode)
+	}
+}
+
+func TestFetchOCI(t *testing.T) {
+	layerDigest := digestOf(test.LogWasm)
+	manifest := []byte(fmt.Sprintf(`{
+  "schemaVersion": 2,
+  "mediaType": "application/vnd.oci.image.manifest.v1+json",
+  "config": {"mediaType": "application/vnd.wasm.config.v1+json", "digest": "sha256:0", "size": 2},
+  "layers": [{"mediaType": "application/vnd.wasm.content.layer.v1+wasm", "digest": %q, "size": %d}]
+}`, layerDigest, len(test.LogWasm)))
+	manifestDigest := digestOf(manifest)
+
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token" {
+			if scope := r.URL.Query().Get("scope"); scope != "repository:org/guest:pull" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"token":"abc"}`)) // nolint
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer abc" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test",scope="repository:org/guest:pull"`, ts.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/org/guest/manifests/v1", "/v2/org/guest/manifests/" + manifestDigest:
+			w.Header().Set("Content-Type", "application/vnd.oci.image.manifest.v1+json")
+			w.Write(manifest) // nolint
+		case "/v2/org/guest/blobs/" + layerDigest:
+			w.Write(test.LogWasm) // nolint
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	registry := strings.TrimPrefix(ts.URL, "http://")
+
+	for _, ref := range []string{registry + "/org/guest:v1", registry + "/org/guest@" + manifestDigest} {
+		wasm, err := FetchOCI(testCtx, ref, WithPlainHTTP())
+		if err != nil {
+			t.Fatal(err)
+		} else if string(wasm) != string(test.LogWasm) {
+			t.Fatal("unexpected content")
+		}
+	}
+
+	if _, err := FetchOCI(testCtx, registry+"/org/guest:v1", WithPlainHTTP(), WithDigest(digestOf(test.AuthWasm))); !errors.Is(err, ErrDigestMismatch) {
+		t.Fatalf("expected ErrDigestMismatch, have %v", err)
+	}
+
+	var statusErr *StatusError
+	if _, err := FetchOCI(testCtx, registry+"/org/guest:v2", WithPlainHTTP()); !errors.As(err, &statusErr) {
+		t.Fatalf("expected StatusError, have %v", err)
+	} else if statusErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected status 404, have %d", statusErr.StatusCode)
+	}
+}
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		ref      string
+		expected reference
+	}{
+		{ref: "guest", expected: reference{registry: "registry-1.docker.io", repository: "library/guest", tag: "latest"}},
+		{ref: "org/guest:v1", expected: reference{registry: "registry-1.docker.io", repository: "org/guest", tag: "v1"}},
+		{ref: "ghcr.io/org/guest:v1", expected: reference{registry: "ghcr.io", repository: "org/guest", tag: "v1"}},
+		{ref: "localhost:5000/guest", expected: reference{registry: "localhost:5000", repository: "guest", tag: "latest"}},
+		{ref: "localhost/guest@" + digestOf(nil), expected: reference{registry: "localhost", repository: "guest", tag: "latest", digest: digestOf(nil)}},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.ref, func(t *testing.T) {
+			r, err := parseReference(tc.ref)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tc.expected.scheme = "https"
+			if *r != tc.expected {
+				t.Fatalf("expected %+v, have %+v", tc.expected, *r)
+			}
+		})
+	}
+}
+
+func TestParseReference_InvalidDigest(t *testing.T) {
+	for _, ref := range []string{"guest@sha256:ab", "guest@sha256:../../x", "guest@md5:" + strings.Repeat("a", 64)} {
+		if _, err := parseReference(ref); err == nil {
+			t.Errorf("%s: expected an error", ref)
+		}
+	}
+}
+
+func TestFetchOCI_InvalidLayerDigest(t *testing.T) {
+	manifest := []byte(`{"layers": [{"mediaType": "application/wasm", "digest": "sha256:../../../other/guest"}]}`)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(manifest) // nolint
+	}))
+	defer ts.Close()
+
+	registry := strings.TrimPrefix(ts.URL, "http://")
+	_, err := FetchOCI(testCtx, registry+"/org/guest:v1", WithPlainHTTP())
+	if want := `guestfetch: invalid layer digest "sha256:../../../other/guest"`; err == nil || err.Error() != want {
+		t.Fatalf("expected error %q, have %v", want, err)
+	}
+}
+
+func TestFetchOCI_TokenCredentials(t *testing.T) {
+	// authorized is the Authorization header of the last token request.
+	var authorized string
+	tokenHandler := func(w http.ResponseWriter, r *http.Request) {
+		authorized = r.Header.Get("Authorization")
+		w.Write([]byte(`{"token":"abc"}`)) // nolint
+	}
+	other := httptest.NewServer(http.HandlerFunc(tokenHandler))
+	defer other.Close()
+
+	var realm string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			tokenHandler(w, r)
+		case r.Header.Get("Authorization") != "Bearer abc":
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s"`, realm))
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	registry := strings.TrimPrefix(ts.URL, "http://")
+	otherHost := strings.TrimPrefix(other.URL, "http://")
+	basic := "Basic dXNlcjpwYXNz" // user:pass
+
+	tests := []struct {
+		name, realm        string
+		options            []Option
+		expectedAuthorized string
+	}{
+		{name: "registry host", realm: ts.URL + "/token", expectedAuthorized: basic},
+		{name: "other host", realm: other.URL + "/token"},
+		{name: "allowed host", realm: other.URL + "/token", options: []Option{WithAuthHosts(otherHost)}, expectedAuthorized: basic},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			realm, authorized = tc.realm, ""
+			options := append([]Option{WithPlainHTTP(), WithBasicAuth("user", "pass")}, tc.options...)
+			FetchOCI(testCtx, registry+"/org/guest:v1", options...) // nolint
+			if authorized != tc.expectedAuthorized {
+				t.Fatalf("expected token request authorization %q, have %q", tc.expectedAuthorized, authorized)
+			}
+		})
+	}
+}
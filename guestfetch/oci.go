@@ -0,0 +1,287 @@
+package guestfetch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// wasmLayerMediaTypes are media types used for wasm layers by tools such as
+// wasm-to-oci and oras.
+var wasmLayerMediaTypes = map[string]struct{}{
+	"application/vnd.wasm.content.layer.v1+wasm":        {},
+	"application/vnd.module.wasm.content.layer.v1+wasm": {},
+	"application/wasm": {},
+}
+
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// FetchOCI downloads a guest from an OCI registry, given a reference such as
+// "ghcr.io/org/guest:v1" or "ghcr.io/org/guest@sha256:<hex>".
+//
+// The manifest must have a layer with a wasm media type, or a single layer.
+// When the reference includes a digest, the manifest is verified against it.
+// Layer content is always verified against the digest in the manifest.
+// WithDigest additionally pins the wasm layer content.
+func FetchOCI(ctx context.Context, ref string, opts ...Option) ([]byte, error) {
+	o := newOptions(opts)
+	r, err := parseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+	if o.plainHTTP {
+		r.scheme = "http"
+	}
+	c := &registryClient{options: o, ref: r}
+
+	manifest, err := c.get(ctx, "manifests/"+r.manifest(), strings.Join(manifestMediaTypes, ", "))
+	if err != nil {
+		return nil, err
+	}
+	if r.digest != "" {
+		if err = verifyDigest(r.digest, manifest); err != nil {
+			return nil, err
+		}
+	}
+
+	layer, err := wasmLayer(manifest)
+	if err != nil {
+		return nil, err
+	}
+	if !validDigest(layer.Digest) {
+		return nil, fmt.Errorf("guestfetch: invalid layer digest %q", layer.Digest)
+	}
+
+	wasm, err := c.get(ctx, "blobs/"+layer.Digest, "")
+	if err != nil {
+		return nil, err
+	}
+	if err = verifyDigest(layer.Digest, wasm); err != nil {
+		return nil, err
+	}
+	if err = verifyDigest(o.digest, wasm); err != nil {
+		return nil, err
+	}
+	return wasm, nil
+}
+
+// dockerHubRegistry is the default registry, which delegates authentication
+// to dockerHubAuthHost.
+const (
+	dockerHubRegistry = "registry-1.docker.io"
+	dockerHubAuthHost = "auth.docker.io"
+)
+
+type reference struct {
+	scheme, registry, repository, tag, digest string
+}
+
+func (r *reference) manifest() string {
+	if r.digest != "" {
+		return r.digest
+	}
+	return r.tag
+}
+
+// parseReference parses an image reference, defaulting the registry to
+// Docker Hub and the tag to "latest", like container tooling does.
+func parseReference(ref string) (*reference, error) {
+	r := &reference{scheme: "https", tag: "latest"}
+	rest := ref
+	if i := strings.IndexByte(rest, '@'); i >= 0 {
+		rest, r.digest = rest[:i], rest[i+1:]
+	}
+	// A tag follows the last colon, unless it is a registry port.
+	if i := strings.LastIndexByte(rest, ':'); i > strings.LastIndexByte(rest, '/') {
+		rest, r.tag = rest[:i], rest[i+1:]
+	}
+	if i := strings.IndexByte(rest, '/'); i >= 0 && (strings.ContainsAny(rest[:i], ".:") || rest[:i] == "localhost") {
+		r.registry, r.repository = rest[:i], rest[i+1:]
+	} else {
+		r.registry, r.repository = dockerHubRegistry, rest
+		if !strings.Contains(rest, "/") {
+			r.repository = "library/" + rest
+		}
+	}
+	if r.repository == "" || r.tag == "" || (r.digest != "" && !validDigest(r.digest)) {
+		return nil, fmt.Errorf("guestfetch: invalid reference %q", ref)
+	}
+	return r, nil
+}
+
+type descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+// wasmLayer returns the layer containing the guest.
+func wasmLayer(manifest []byte) (*descriptor, error) {
+	var m struct {
+		MediaType string        `json:"mediaType"`
+		Layers    []*descriptor `json:"layers"`
+		Manifests []*descriptor `json:"manifests"`
+	}
+	if err := json.Unmarshal(manifest, &m); err != nil {
+		return nil, fmt.Errorf("guestfetch: invalid manifest: %w", err)
+	}
+	if len(m.Manifests) > 0 {
+		return nil, fmt.Errorf("guestfetch: image index is unsupported: reference a manifest digest instead")
+	}
+	for _, l := range m.Layers {
+		if _, ok := wasmLayerMediaTypes[l.MediaType]; ok {
+			return l, nil
+		}
+	}
+	if len(m.Layers) == 1 {
+		return m.Layers[0], nil
+	}
+	return nil, fmt.Errorf("guestfetch: manifest has %d layers, none of which are wasm", len(m.Layers))
+}
+
+// registryClient implements the subset of the OCI distribution API needed to
+// pull an artifact, including the bearer token challenge most registries use,
+// even for anonymous pulls.
+type registryClient struct {
+	*options
+	ref *reference
+}
+
+func (c *registryClient) get(ctx context.Context, path, accept string) ([]byte, error) {
+	u := fmt.Sprintf("%s://%s/v2/%s/%s", c.ref.scheme, c.ref.registry, c.ref.repository, path)
+	newRequest := func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, fmt.Errorf("guestfetch: %w", err)
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		c.authorize(req)
+		return req, nil
+	}
+
+	req, err := newRequest()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("guestfetch: %w", err)
+	}
+	if resp.StatusCode == http.StatusUnauthorized && c.token == "" {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		if c.token, err = c.fetchToken(ctx, challenge); err != nil {
+			return nil, err
+		}
+		if req, err = newRequest(); err != nil {
+			return nil, err
+		}
+		if resp, err = c.client.Do(req); err != nil {
+			return nil, fmt.Errorf("guestfetch: %w", err)
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{URL: u, StatusCode: resp.StatusCode}
+	}
+	return c.readBody(resp)
+}
+
+// fetchToken obtains a bearer token in response to a challenge like:
+//
+//	Bearer realm="https://ghcr.io/token",service="ghcr.io",scope="repository:org/guest:pull"
+func (c *registryClient) fetchToken(ctx context.Context, challenge string) (string, error) {
+	scheme, params, _ := strings.Cut(challenge, " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return "", fmt.Errorf("guestfetch: unsupported auth challenge %q", challenge)
+	}
+	p := parseChallengeParams(params)
+	realm, err := url.Parse(p["realm"])
+	if err != nil || p["realm"] == "" {
+		return "", fmt.Errorf("guestfetch: invalid auth challenge %q", challenge)
+	}
+	q := realm.Query()
+	if service := p["service"]; service != "" {
+		q.Set("service", service)
+	}
+	scope := p["scope"]
+	if scope == "" {
+		scope = "repository:" + c.ref.repository + ":pull"
+	}
+	q.Set("scope", scope)
+	realm.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("guestfetch: %w", err)
+	}
+	if c.username != "" && c.sendsCredentials(realm) {
+		req.SetBasicAuth(c.username, c.secret)
+	}
+	body, err := c.do(req)
+	if err != nil {
+		return "", err
+	}
+
+	var t struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.Unmarshal(body, &t); err != nil {
+		return "", fmt.Errorf("guestfetch: invalid token response: %w", err)
+	}
+	if t.Token != "" {
+		return t.Token, nil
+	} else if t.AccessToken != "" {
+		return t.AccessToken, nil
+	}
+	return "", fmt.Errorf("guestfetch: token response from %s has no token", realm.Host)
+}
+
+// sendsCredentials returns true if basic auth can be sent to the token realm.
+// It must use the scheme of the registry, which is https unless
+// WithPlainHTTP, and be on the registry host or one allowed by
+// WithAuthHosts. Otherwise, a challenge could direct credentials anywhere.
+func (c *registryClient) sendsCredentials(realm *url.URL) bool {
+	if realm.Scheme != c.ref.scheme {
+		return false
+	}
+	host := realm.Host
+	if strings.EqualFold(host, c.ref.registry) ||
+		(c.ref.registry == dockerHubRegistry && strings.EqualFold(host, dockerHubAuthHost)) {
+		return true
+	}
+	for _, h := range c.authHosts {
+		if strings.EqualFold(host, h) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseChallengeParams parses comma-separated key="value" pairs.
+func parseChallengeParams(s string) map[string]string {
+	params := map[string]string{}
+	for s != "" {
+		var kv string
+		// Values are quoted and may contain commas, such as in scope.
+		if i := strings.Index(s, `",`); i >= 0 {
+			kv, s = s[:i+1], s[i+2:]
+		} else {
+			kv, s = s, ""
+		}
+		if k, v, ok := strings.Cut(strings.TrimSpace(kv), "="); ok {
+			params[strings.ToLower(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return params
+}
@@ -0,0 +1,120 @@
+// Package guestverify includes implementations of httpwasm.GuestVerifier,
+// which reject guests that aren't signed by a trusted key.
+package guestverify
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+
+	"github.com/http-wasm/http-wasm-host-go/internal/wasm"
+)
+
+// CustomSectionMinisign is the name of the custom section holding a minisign
+// signature, in the same text format as a ".minisig" file. The signed message
+// is the guest binary without this section.
+//
+// For example, to sign a guest with minisign and embed the signature:
+//
+//	minisign -S -s key.sec -m guest.wasm
+//	# then append the custom section "signature.minisign" with the contents
+//	# of guest.wasm.minisig to guest.wasm, e.g. with wasm-tools or wabt.
+const CustomSectionMinisign = "signature.minisign"
+
+var (
+	// ErrUnsigned is returned when the guest has no signature.
+	ErrUnsigned = errors.New("guest is not signed")
+
+	// ErrInvalidSignature is returned when the guest signature is malformed,
+	// made by a different key, or doesn't match the guest.
+	ErrInvalidSignature = errors.New("invalid guest signature")
+)
+
+const (
+	algorithmLegacy    = "Ed" // signs the message
+	algorithmPrehashed = "ED" // signs the BLAKE2b-512 hash of the message
+)
+
+// Minisign returns a verifier for httpwasm.GuestVerifier, which requires the
+// guest to embed a minisign signature made with the given public key, in the
+// custom section named CustomSectionMinisign.
+//
+// The public key is in minisign format: either the contents of a ".pub" file
+// or only its base64 line. Ex. "RWQf6LRCGA9i53mlYecO4IzT51TGPpvWucNSCh1CBM0QTaLn73Y7GFO3"
+func Minisign(publicKey string) (func(guest []byte) error, error) {
+	key, keyID, err := parsePublicKey(publicKey)
+	if err != nil {
+		return nil, err
+	}
+	return func(guest []byte) error {
+		return verifyMinisign(key, keyID, guest)
+	}, nil
+}
+
+func parsePublicKey(publicKey string) (ed25519.PublicKey, []byte, error) {
+	lines := strings.Split(strings.TrimSpace(publicKey), "\n")
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[len(lines)-1]))
+	if err != nil || len(b) != 2+8+ed25519.PublicKeySize || string(b[:2]) != algorithmLegacy {
+		return nil, nil, errors.New("invalid minisign public key")
+	}
+	return ed25519.PublicKey(b[10:]), b[2:10], nil
+}
+
+func verifyMinisign(key ed25519.PublicKey, keyID, guest []byte) error {
+	message, signature, ok, err := wasm.RemoveCustomSection(guest, CustomSectionMinisign)
+	if err != nil {
+		return err
+	} else if !ok {
+		return ErrUnsigned
+	}
+
+	// The format is four lines: an untrusted comment, the signature, a
+	// trusted comment and a global signature of the signature and comment.
+	lines := strings.Split(strings.TrimRight(string(signature), "\n"), "\n")
+	if len(lines) < 4 {
+		return fmt.Errorf("%w: expected 4 lines", ErrInvalidSignature)
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+		return fmt.Errorf("%w: malformed signature", ErrInvalidSignature)
+	}
+	if !bytes.Equal(sig[2:10], keyID) {
+		return fmt.Errorf("%w: signed by a different key", ErrInvalidSignature)
+	}
+
+	switch string(sig[:2]) {
+	case algorithmLegacy:
+	case algorithmPrehashed:
+		sum := blake2b.Sum512(message)
+		message = sum[:]
+	default:
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidSignature, sig[:2])
+	}
+	if !ed25519.Verify(key, message, sig[10:]) {
+		return fmt.Errorf("%w: signature doesn't match guest", ErrInvalidSignature)
+	}
+
+	// The trusted comment is authenticated by the global signature.
+	trustedComment, ok := cutPrefix(lines[2], "trusted comment: ")
+	if !ok {
+		return fmt.Errorf("%w: missing trusted comment", ErrInvalidSignature)
+	}
+	globalSig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil || !ed25519.Verify(key, append(append([]byte{}, sig[10:]...), trustedComment...), globalSig) {
+		return fmt.Errorf("%w: trusted comment doesn't match", ErrInvalidSignature)
+	}
+	return nil
+}
+
+// cutPrefix is like strings.CutPrefix, which isn't available until Go 1.20.
+func cutPrefix(s, prefix string) (string, bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
@@ -0,0 +1,102 @@
+package guestverify
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	wasm "github.com/http-wasm/http-wasm-host-go/handler/nethttp"
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+var testCtx = context.Background()
+
+type testKey struct {
+	public  string
+	private ed25519.PrivateKey
+	id      []byte
+}
+
+func newTestKey(t *testing.T) *testKey {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	b := append(append([]byte(algorithmLegacy), id...), pub...)
+	return &testKey{
+		public:  "untrusted comment: minisign public key\n" + base64.StdEncoding.EncodeToString(b) + "\n",
+		private: priv,
+		id:      id,
+	}
+}
+
+// sign returns the guest with an embedded signature, like minisign would
+// produce.
+func (k *testKey) sign(guest []byte, algorithm string) []byte {
+	message := guest
+	if algorithm == algorithmPrehashed {
+		sum := blake2b.Sum512(guest)
+		message = sum[:]
+	}
+	sig := ed25519.Sign(k.private, message)
+	trustedComment := "timestamp:1665000000\tfile:guest.wasm"
+	globalSig := ed25519.Sign(k.private, append(append([]byte{}, sig...), trustedComment...))
+
+	minisig := fmt.Sprintf("untrusted comment: signature from minisign secret key\n%s\ntrusted comment: %s\n%s\n",
+		base64.StdEncoding.EncodeToString(append(append([]byte(algorithm), k.id...), sig...)),
+		trustedComment,
+		base64.StdEncoding.EncodeToString(globalSig))
+	return test.WithCustomSection(guest, CustomSectionMinisign, []byte(minisig))
+}
+
+func TestMinisign(t *testing.T) {
+	key, otherKey := newTestKey(t), newTestKey(t)
+	tampered := key.sign(test.LogWasm, algorithmPrehashed)
+	tampered[len(test.LogWasm)-1]++ // corrupt the last byte of the name section
+
+	tests := []struct {
+		name        string
+		guest       []byte
+		expectedErr error
+	}{
+		{name: "legacy", guest: key.sign(test.LogWasm, algorithmLegacy)},
+		{name: "prehashed", guest: key.sign(test.LogWasm, algorithmPrehashed)},
+		{name: "unsigned", guest: test.LogWasm, expectedErr: ErrUnsigned},
+		{name: "other key", guest: otherKey.sign(test.LogWasm, algorithmPrehashed), expectedErr: ErrInvalidSignature},
+		{name: "tampered", guest: tampered, expectedErr: ErrInvalidSignature},
+	}
+
+	verifier, err := Minisign(key.public)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			mw, err := wasm.NewMiddleware(testCtx, tc.guest, httpwasm.GuestVerifier(verifier))
+			if tc.expectedErr == nil {
+				if err != nil {
+					t.Fatal(err)
+				}
+				_ = mw.Close(testCtx)
+			} else if !errors.Is(err, tc.expectedErr) {
+				t.Fatalf("expected %v, have %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestMinisign_invalidKey(t *testing.T) {
+	if _, err := Minisign("RWQ"); err == nil {
+		t.Fatal("expected error")
+	}
+}
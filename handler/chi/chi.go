@@ -0,0 +1,75 @@
+// Package chi exposes guests as middleware of github.com/go-chi/chi, which
+// uses the net/http middleware signature. This is also usable with other
+// routers that accept it. See the echo and gin packages for middleware with
+// the native signature of those routers.
+//
+// For example:
+//
+//	mw, err := chi.NewMiddleware(ctx, guest)
+//	if err != nil {
+//		return err
+//	}
+//	defer mw.Close(ctx)
+//
+//	r := chi.NewRouter()
+//	r.Use(mw.Handler)
+package chi
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	wasm "github.com/http-wasm/http-wasm-host-go/handler/nethttp"
+	"github.com/http-wasm/http-wasm-host-go/internal/idle"
+)
+
+// Middleware is a guest as router middleware, which unlike wasm.Handler is
+// safe for concurrent use.
+type Middleware struct {
+	mw wasm.Middleware
+
+	mu sync.Mutex
+	// handlers serve requests with idle guests, as a wasm.Handler isn't
+	// safe for concurrent use.
+	handlers []*idle.Pool
+}
+
+// NewMiddleware compiles the guest with the same options as
+// wasm.NewMiddleware.
+func NewMiddleware(ctx context.Context, guest []byte, options ...httpwasm.Option) (*Middleware, error) {
+	mw, err := wasm.NewMiddleware(ctx, guest, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &Middleware{mw: mw}, nil
+}
+
+// Handler returns a handler which runs the guest before next. Its signature
+// is that of chi.Middlewares, so it can be passed to chi.Router Use.
+func (m *Middleware) Handler(next http.Handler) http.Handler {
+	h := &idle.Pool{New: func(ctx context.Context) (idle.Handler, error) {
+		return m.mw.NewHandler(ctx, next)
+	}}
+	m.mu.Lock()
+	m.handlers = append(m.handlers, h)
+	m.mu.Unlock()
+	return h
+}
+
+// Close closes all guests, after which handlers must not be used.
+func (m *Middleware) Close(ctx context.Context) (err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, h := range m.handlers {
+		if e := h.Close(ctx); e != nil {
+			err = e
+		}
+	}
+	m.handlers = nil
+	if e := m.mw.Close(ctx); e != nil {
+		err = e
+	}
+	return
+}
@@ -0,0 +1,97 @@
+// Package echo exposes guests as middleware of github.com/labstack/echo, with
+// its native echo.MiddlewareFunc signature. This is a separate module, so that
+// importing the host doesn't require echo.
+//
+// For example:
+//
+//	mw, err := echo.NewMiddleware(ctx, guest)
+//	if err != nil {
+//		return err
+//	}
+//	defer mw.Close(ctx)
+//
+//	e := echo.New()
+//	e.Use(mw.Handler)
+package echo
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	wasm "github.com/http-wasm/http-wasm-host-go/handler/nethttp"
+	"github.com/http-wasm/http-wasm-host-go/internal/idle"
+)
+
+// Middleware is a guest as echo middleware, which unlike wasm.Handler is safe
+// for concurrent use.
+type Middleware struct {
+	mw wasm.Middleware
+
+	// handlers serve requests with idle guests, as a wasm.Handler isn't
+	// safe for concurrent use. Their next handler continues the echo chain
+	// of the request, read from nextKey.
+	handlers idle.Pool
+}
+
+// nextKey is the context key of the *echoNext of a request.
+type nextKey struct{}
+
+// echoNext is the echo chain after the guest, for a request.
+type echoNext struct {
+	c    echo.Context
+	next echo.HandlerFunc
+}
+
+// NewMiddleware compiles the guest with the same options as
+// wasm.NewMiddleware.
+func NewMiddleware(ctx context.Context, guest []byte, options ...httpwasm.Option) (*Middleware, error) {
+	mw, err := wasm.NewMiddleware(ctx, guest, options...)
+	if err != nil {
+		return nil, err
+	}
+	m := &Middleware{mw: mw}
+	m.handlers.New = func(ctx context.Context) (idle.Handler, error) {
+		return mw.NewHandler(ctx, http.HandlerFunc(serveNext))
+	}
+	return m, nil
+}
+
+// Handler runs the guest before next. Its signature is that of
+// echo.MiddlewareFunc, so it can be passed to echo.Echo Use.
+func (m *Middleware) Handler(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		r := c.Request()
+		r = r.WithContext(context.WithValue(r.Context(), nextKey{}, &echoNext{c: c, next: next}))
+		m.handlers.ServeHTTP(c.Response(), r)
+		return nil
+	}
+}
+
+// serveNext continues the echo chain of the request, writing to the response
+// of the guest, so that it can read or change what handlers after it write.
+func serveNext(w http.ResponseWriter, r *http.Request) {
+	n := r.Context().Value(nextKey{}).(*echoNext)
+
+	res := n.c.Response()
+	n.c.SetRequest(r)
+	n.c.SetResponse(echo.NewResponse(w, n.c.Echo()))
+	defer n.c.SetResponse(res)
+
+	// Handle errors here, as the guest may write the response once this
+	// returns, and echo doesn't handle errors of a committed response.
+	if err := n.next(n.c); err != nil {
+		n.c.Error(err)
+	}
+}
+
+// Close closes all guests, after which handlers must not be used.
+func (m *Middleware) Close(ctx context.Context) (err error) {
+	err = m.handlers.Close(ctx)
+	if e := m.mw.Close(ctx); e != nil {
+		err = e
+	}
+	return
+}
@@ -0,0 +1,24 @@
+module github.com/http-wasm/http-wasm-host-go/handler/echo
+
+go 1.18
+
+require (
+	github.com/http-wasm/http-wasm-host-go v0.0.0
+	github.com/labstack/echo/v4 v4.9.1
+)
+
+require (
+	github.com/fsnotify/fsnotify v1.6.0 // indirect
+	github.com/labstack/gommon v0.4.0 // indirect
+	github.com/mattn/go-colorable v0.1.11 // indirect
+	github.com/mattn/go-isatty v0.0.14 // indirect
+	github.com/tetratelabs/wazero v1.0.0-pre.2 // indirect
+	github.com/valyala/bytebufferpool v1.0.0 // indirect
+	github.com/valyala/fasttemplate v1.2.1 // indirect
+	golang.org/x/crypto v0.1.0 // indirect
+	golang.org/x/net v0.1.0 // indirect
+	golang.org/x/sys v0.1.0 // indirect
+	golang.org/x/text v0.4.0 // indirect
+)
+
+replace github.com/http-wasm/http-wasm-host-go => ../..
@@ -0,0 +1,169 @@
+// Package gin exposes guests as middleware of github.com/gin-gonic/gin, with
+// its native gin.HandlerFunc signature. This is a separate module, so that
+// importing the host doesn't require gin.
+//
+// For example:
+//
+//	mw, err := gin.NewMiddleware(ctx, guest)
+//	if err != nil {
+//		return err
+//	}
+//	defer mw.Close(ctx)
+//
+//	r := gin.New()
+//	r.Use(mw.Handle)
+package gin
+
+import (
+	"context"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	wasm "github.com/http-wasm/http-wasm-host-go/handler/nethttp"
+	"github.com/http-wasm/http-wasm-host-go/internal/idle"
+)
+
+// Middleware is a guest as gin middleware, which unlike wasm.Handler is safe
+// for concurrent use.
+type Middleware struct {
+	mw wasm.Middleware
+
+	// handlers serve requests with idle guests, as a wasm.Handler isn't
+	// safe for concurrent use. Their next handler continues the gin chain
+	// of the request, read from nextKey.
+	handlers idle.Pool
+}
+
+// nextKey is the context key of the *ginNext of a request.
+type nextKey struct{}
+
+// ginNext is the gin chain after the guest, for a request.
+type ginNext struct {
+	c      *gin.Context
+	called bool
+}
+
+// NewMiddleware compiles the guest with the same options as
+// wasm.NewMiddleware.
+func NewMiddleware(ctx context.Context, guest []byte, options ...httpwasm.Option) (*Middleware, error) {
+	mw, err := wasm.NewMiddleware(ctx, guest, options...)
+	if err != nil {
+		return nil, err
+	}
+	m := &Middleware{mw: mw}
+	m.handlers.New = func(ctx context.Context) (idle.Handler, error) {
+		return mw.NewHandler(ctx, http.HandlerFunc(serveNext))
+	}
+	return m, nil
+}
+
+// Handle runs the guest before the handlers after it. Its signature is that
+// of gin.HandlerFunc, so it can be passed to gin.Engine Use.
+func (m *Middleware) Handle(c *gin.Context) {
+	n := &ginNext{c: c}
+	r := c.Request.WithContext(context.WithValue(c.Request.Context(), nextKey{}, n))
+	m.handlers.ServeHTTP(c.Writer, r)
+	if !n.called {
+		// The guest responded itself, so skip the handlers after it.
+		c.Abort()
+	}
+}
+
+// serveNext continues the gin chain of the request, writing to the response
+// of the guest, so that it can read or change what handlers after it write.
+func serveNext(w http.ResponseWriter, r *http.Request) {
+	n := r.Context().Value(nextKey{}).(*ginNext)
+	n.called = true
+
+	res := n.c.Writer
+	n.c.Request = r
+	n.c.Writer = &responseWriter{ResponseWriter: res, w: w, status: http.StatusOK, size: -1}
+	defer func() { n.c.Writer = res }()
+
+	n.c.Next()
+}
+
+// responseWriter is a gin.ResponseWriter writing to the response of the
+// guest. Other methods, such as Hijack, use the response of gin.
+type responseWriter struct {
+	gin.ResponseWriter
+	w http.ResponseWriter
+
+	// status is written with the first write, as done by gin, and size is
+	// -1 until then.
+	status, size int
+}
+
+// Header implements the same method as documented on http.ResponseWriter.
+func (w *responseWriter) Header() http.Header {
+	return w.w.Header()
+}
+
+// WriteHeader implements the same method as documented on
+// http.ResponseWriter.
+func (w *responseWriter) WriteHeader(statusCode int) {
+	if statusCode > 0 && !w.Written() {
+		w.status = statusCode
+	}
+}
+
+// WriteHeaderNow implements the same method as documented on
+// gin.ResponseWriter.
+func (w *responseWriter) WriteHeaderNow() {
+	if !w.Written() {
+		w.size = 0
+		w.w.WriteHeader(w.status)
+	}
+}
+
+// Write implements the same method as documented on http.ResponseWriter.
+func (w *responseWriter) Write(b []byte) (n int, err error) {
+	w.WriteHeaderNow()
+	n, err = w.w.Write(b)
+	w.size += n
+	return
+}
+
+// WriteString implements the same method as documented on
+// gin.ResponseWriter.
+func (w *responseWriter) WriteString(s string) (n int, err error) {
+	w.WriteHeaderNow()
+	n, err = io.WriteString(w.w, s)
+	w.size += n
+	return
+}
+
+// Status implements the same method as documented on gin.ResponseWriter.
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+// Size implements the same method as documented on gin.ResponseWriter.
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+// Written implements the same method as documented on gin.ResponseWriter.
+func (w *responseWriter) Written() bool {
+	return w.size != -1
+}
+
+// Flush implements the same method as documented on http.Flusher.
+func (w *responseWriter) Flush() {
+	w.WriteHeaderNow()
+	if f, ok := w.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close closes all guests, after which handlers must not be used.
+func (m *Middleware) Close(ctx context.Context) (err error) {
+	err = m.handlers.Close(ctx)
+	if e := m.mw.Close(ctx); e != nil {
+		err = e
+	}
+	return
+}
@@ -0,0 +1,175 @@
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math"
+	"net/http"
+)
+
+// GetRequestBody implements the same method as documented on handler.Host.
+func (h host) GetRequestBody(ctx context.Context) []byte {
+	return requestStateFromContext(ctx).requestBodyBytes()
+}
+
+// GetRequestBodySize implements the same method as documented on
+// handler.Host.
+func (h host) GetRequestBodySize(ctx context.Context) int64 {
+	return requestStateFromContext(ctx).request.ContentLength
+}
+
+// IsRequestChunked implements the same method as documented on
+// handler.ConnectionInfo.
+func (h host) IsRequestChunked(ctx context.Context) bool {
+	te := requestStateFromContext(ctx).request.TransferEncoding
+	return len(te) > 0 && te[0] == "chunked"
+}
+
+// GetRequestBodyReaderAt implements the same method as documented on
+// handler.RequestBodyReaderAt.
+func (h host) GetRequestBodyReaderAt(ctx context.Context) (io.ReaderAt, int64) {
+	s := requestStateFromContext(ctx)
+	if body := s.bufferRequestBody(); s.spill == nil {
+		return bytes.NewReader(body), int64(len(body))
+	}
+	return s.spill, s.spillSize
+}
+
+// requestBodyBytes buffers the request body and returns all of it, reading
+// it into memory even if spilled. Callers which don't need the whole body use
+// handler.RequestBodyReaderAt instead.
+func (s *requestState) requestBodyBytes() []byte {
+	if body := s.bufferRequestBody(); s.spill == nil {
+		return body
+	}
+	return s.readSpill()
+}
+
+// bufferRequestBody reads the request body into memory, so that it can be
+// read again by the next handler. Bodies larger than the memory limit are
+// spilled to a file, if configured, or else rejected. When spilled, this
+// returns nil, and the caller reads the body from the file.
+func (s *requestState) bufferRequestBody() []byte {
+	if s.requestBody != nil || s.spill != nil {
+		return s.requestBody
+	}
+	r := s.request
+	s.requestBody = []byte{}
+	if r.Body == nil || r.Body == http.NoBody {
+		return s.requestBody
+	}
+
+	var body io.Reader = r.Body
+	limit := s.bodyLimits.Memory
+	if limit > 0 {
+		body = io.LimitReader(r.Body, limit+1)
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		panic(err)
+	}
+	if limit > 0 && int64(len(b)) > limit {
+		s.requestBody = nil
+		s.spillRequestBody(b)
+		return nil
+	}
+	r.Body.Close()
+	s.requestBody = b
+	r.Body = io.NopCloser(bytes.NewReader(b))
+	return s.requestBody
+}
+
+// StreamRequestBody implements the same method as documented on
+// handler.RequestBodyStreamer.
+func (h host) StreamRequestBody(ctx context.Context, chunkLimit uint32, onChunk func(chunk []byte, eos bool) []byte) {
+	r := requestStateFromContext(ctx).request
+	if r.Body == nil {
+		r.Body = http.NoBody
+	}
+	r.Body = &chunkReader{body: r.Body, buf: make([]byte, chunkLimit), onChunk: onChunk}
+	// The length may change when the guest transforms chunks.
+	r.ContentLength = -1
+	r.Header.Del("Content-Length")
+}
+
+// chunkReader passes each chunk read from body through onChunk.
+type chunkReader struct {
+	body    io.ReadCloser
+	buf     []byte
+	onChunk func(chunk []byte, eos bool) []byte
+	// out is the last chunk and pending is the part of it not yet read.
+	out, pending []byte
+	eos          bool
+}
+
+// Read implements io.Reader
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for len(c.pending) == 0 {
+		if c.eos {
+			return 0, io.EOF
+		}
+		n, err := c.body.Read(c.buf)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		c.eos = err == io.EOF
+		if n == 0 && !c.eos {
+			continue
+		}
+		// Copy, as the chunk is in guest memory, which the next call reuses.
+		c.out = append(c.out[:0], c.onChunk(c.buf[:n], c.eos)...)
+		c.pending = c.out
+	}
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// Close implements io.Closer
+func (c *chunkReader) Close() error {
+	return c.body.Close()
+}
+
+// ReadRequestBody implements the same method as documented on handler.Host.
+func (h host) ReadRequestBody(ctx context.Context, alloc func(size uint32) []byte) uint32 {
+	s := requestStateFromContext(ctx)
+	r := s.request
+
+	// When the length is unknown, the body must be buffered to allocate.
+	// Likewise, when it is larger than the memory limit, so that it is
+	// spilled or rejected.
+	limit := s.bodyLimits.Memory
+	if s.requestBody != nil || s.spill != nil || r.ContentLength < 0 || (limit > 0 && r.ContentLength > limit) {
+		body := s.bufferRequestBody()
+		if s.spill != nil {
+			return s.readSpillInto(alloc)
+		} else if len(body) == 0 {
+			return 0
+		} else if int64(len(body)) > math.MaxUint32 {
+			s.rejectBodyTooLarge()
+		}
+		copy(alloc(uint32(len(body))), body)
+		return uint32(len(body))
+	}
+
+	if r.ContentLength == 0 || r.Body == nil {
+		return 0
+	}
+	// Guest memory can't hold a body larger than 4 GiB, and the length would
+	// wrap around.
+	if max := s.bodyLimits.Max; (max > 0 && r.ContentLength > max) || r.ContentLength > math.MaxUint32 {
+		s.rejectBodyTooLarge()
+	}
+	// Read directly into guest memory, avoiding a buffer the guest would
+	// copy from. The next handler reads a copy, as the guest may change or
+	// reuse its memory, even after it returns.
+	body := alloc(uint32(r.ContentLength))
+	if _, err := io.ReadFull(r.Body, body); err != nil {
+		panic(err)
+	}
+	r.Body.Close()
+	s.requestBody = append([]byte{}, body...)
+	r.Body = io.NopCloser(bytes.NewReader(s.requestBody))
+	return uint32(len(body))
+}
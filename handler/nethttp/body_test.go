@@ -0,0 +1,82 @@
+package wasm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestRequestBodyChunks(t *testing.T) {
+	var messages []string
+	logger := func(_ context.Context, msg string) { messages = append(messages, msg) }
+
+	mw, err := NewMiddleware(testCtx, test.BodyChunkWasm, httpwasm.Logger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body) // nolint
+	})
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello wasm world"))
+	if have := serve(t, mw, next, req); have != "HELLO WASM WORLD" {
+		t.Fatalf("expected body %q, have %q", "HELLO WASM WORLD", have)
+	}
+	if have := strings.Join(messages, ","); have != "eos" {
+		t.Fatalf("expected messages %q, have %q", "eos", have)
+	}
+}
+
+func TestReadRequestBody(t *testing.T) {
+	tests := []struct {
+		name           string
+		contentLength  int64
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "known length", contentLength: 5, expectedStatus: http.StatusOK, expectedBody: "hello"},
+		{name: "unknown length", contentLength: -1, expectedStatus: http.StatusOK, expectedBody: "hello"},
+		// The length would wrap around to 5 as a uint32.
+		{name: "larger than 4 GiB", contentLength: 1<<32 + 5, expectedStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	mw, err := NewMiddleware(testCtx, test.ReadBodyWasm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body) // nolint
+	})
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			h, err := mw.NewHandler(testCtx, next)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer h.Close(testCtx)
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello"))
+			req.ContentLength = tc.contentLength
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if w.Code != tc.expectedStatus {
+				t.Fatalf("expected status %d, have %d", tc.expectedStatus, w.Code)
+			}
+			// The guest changed its memory after reading the body.
+			if have := w.Body.String(); have != tc.expectedBody {
+				t.Fatalf("expected body %q, have %q", tc.expectedBody, have)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package wasm
+
+import (
+	"io"
+	"math"
+	"net/http"
+	"os"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	"github.com/http-wasm/http-wasm-host-go/internal"
+)
+
+// setBodyLimits sets the limits of buffering the request and response bodies.
+func (s *requestState) setBodyLimits(limits internal.BodyLimits) {
+	s.bodyLimits = limits
+	s.response.maxBody = limits.Memory
+}
+
+// spillRequestBody writes the request body to a temporary file, starting with
+// the head already read, so that the next handler reads it from the file.
+// This rejects the request if spilling isn't configured, or the body is
+// larger than allowed.
+func (s *requestState) spillRequestBody(head []byte) {
+	if s.bodyLimits.SpillDir == "" {
+		s.rejectBodyTooLarge()
+	}
+	f, err := os.CreateTemp(s.bodyLimits.SpillDir, "http-wasm-body-*")
+	if err != nil {
+		panic(err)
+	}
+	s.spill = f // removed on release
+
+	r := s.request
+	var body io.Reader = r.Body
+	if max := s.bodyLimits.Max; max > 0 {
+		body = io.LimitReader(r.Body, max-int64(len(head))+1)
+	}
+	if _, err = f.Write(head); err != nil {
+		panic(err)
+	}
+	n, err := io.Copy(f, body)
+	if err != nil {
+		panic(err)
+	}
+	size := int64(len(head)) + n
+	if max := s.bodyLimits.Max; max > 0 && size > max {
+		s.rejectBodyTooLarge()
+	}
+	s.spillSize = size
+	r.Body.Close()
+	r.Body = io.NopCloser(io.NewSectionReader(f, 0, size))
+}
+
+// readSpill reads the request body spilled to a temporary file into memory.
+// Callers which don't need the whole body use the file instead.
+func (s *requestState) readSpill() []byte {
+	b := make([]byte, s.spillSize)
+	if _, err := s.spill.ReadAt(b, 0); err != nil && err != io.EOF {
+		panic(err)
+	}
+	return b
+}
+
+// readSpillInto reads the request body spilled to a temporary file directly
+// into memory allocated by the guest, returning its size.
+func (s *requestState) readSpillInto(alloc func(size uint32) []byte) uint32 {
+	if s.spillSize == 0 {
+		return 0
+	} else if s.spillSize > math.MaxUint32 {
+		s.rejectBodyTooLarge()
+	}
+	if _, err := s.spill.ReadAt(alloc(uint32(s.spillSize)), 0); err != nil && err != io.EOF {
+		panic(err)
+	}
+	return uint32(s.spillSize)
+}
+
+// rejectBodyTooLarge responds with 413 Content Too Large, unless the
+// response was already committed, and traps the guest which read the body.
+func (s *requestState) rejectBodyTooLarge() {
+	if w := s.response; !w.committed {
+		if w.buffering {
+			w.reset()
+			w.buffering = false
+		}
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+	}
+	panic(handler.ErrBodyTooLarge)
+}
@@ -0,0 +1,178 @@
+package wasm
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+	"github.com/http-wasm/http-wasm-host-go/responsecache"
+)
+
+func TestUploadLimit(t *testing.T) {
+	tests := []struct {
+		name           string
+		req            func() *http.Request
+		expectedStatus int
+	}{
+		{
+			name: "within limit",
+			req: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/", strings.NewReader("small"))
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "over limit",
+			req: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/", strings.NewReader("much too large"))
+			},
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name: "chunked",
+			req: func() *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("small"))
+				req.ContentLength = -1
+				req.TransferEncoding = []string{"chunked"}
+				return req
+			},
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	mw, err := NewMiddleware(testCtx, test.UploadLimitWasm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	var called bool
+	h, err := mw.NewHandler(testCtx, http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		called = true
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close(testCtx)
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			called = false
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, tc.req())
+			if w.Code != tc.expectedStatus {
+				t.Errorf("expected status %d, have %d", tc.expectedStatus, w.Code)
+			}
+			if expected := tc.expectedStatus == http.StatusOK; called != expected {
+				t.Errorf("expected next handler called %v, have %v", expected, called)
+			}
+		})
+	}
+}
+
+func TestMaxBodyBuffer(t *testing.T) {
+	const large = "hello, this is larger than the limit"
+	spillDir := t.TempDir()
+
+	tests := []struct {
+		name           string
+		options        []httpwasm.Option
+		body           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "under limit",
+			body:           "hello",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "hello",
+		},
+		{
+			name:           "over limit",
+			body:           large,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "spilled",
+			options:        []httpwasm.Option{httpwasm.SpillRequestBodies(spillDir, 1024)},
+			body:           large,
+			expectedStatus: http.StatusOK,
+			expectedBody:   large,
+		},
+		{
+			name:           "over spill limit",
+			options:        []httpwasm.Option{httpwasm.SpillRequestBodies(spillDir, 20)},
+			body:           large,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body) // nolint
+	})
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			options := append([]httpwasm.Option{httpwasm.MaxBodyBuffer(16)}, tc.options...)
+			mw, err := NewMiddleware(testCtx, test.ReadBodyWasm, options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mw.Close(testCtx)
+
+			h, err := mw.NewHandler(testCtx, next)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer h.Close(testCtx)
+
+			for _, contentLength := range []int64{int64(len(tc.body)), -1} {
+				req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+				req.ContentLength = contentLength
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, req)
+				if w.Code != tc.expectedStatus || w.Body.String() != tc.expectedBody {
+					t.Errorf("content length %d: unexpected response, status %d body %q", contentLength, w.Code, w.Body)
+				}
+			}
+
+			// Spilled bodies are removed when the request completes.
+			if files, _ := os.ReadDir(spillDir); len(files) > 0 {
+				t.Errorf("expected no spilled files, have %d", len(files))
+			}
+		})
+	}
+
+	t.Run("response over limit", func(t *testing.T) {
+		var writeErr error
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, writeErr = w.Write([]byte(large))
+		})
+
+		// The guest buffers the response to cache it.
+		mw, err := NewMiddleware(testCtx, test.CacheWasm, httpwasm.MaxBodyBuffer(16),
+			httpwasm.ResponseCache(responsecache.NewMemory(1<<20)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer mw.Close(testCtx)
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Cache-Key", "a")
+		if have := serve(t, mw, next, req); have != "" {
+			t.Errorf("expected no body, have %q", have)
+		}
+		if !errors.Is(writeErr, handler.ErrBodyTooLarge) {
+			t.Errorf("expected ErrBodyTooLarge writing the response, have %v", writeErr)
+		}
+	})
+}
@@ -0,0 +1,144 @@
+package wasm
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"sync/atomic"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	"github.com/http-wasm/http-wasm-host-go/internal"
+	internalhandler "github.com/http-wasm/http-wasm-host-go/internal/handler"
+)
+
+// SetCanary implements the same method as documented on handler.CanaryRollout.
+func (w *middleware) SetCanary(ctx context.Context, guest []byte, percent int) error {
+	var canary *runtimeRef
+	if percent > 0 {
+		if guest == nil {
+			return errors.New("wasm: canary guest is nil")
+		}
+		options := append(append([]httpwasm.Option{}, w.options...), func(o *internal.WazeroOptions) {
+			o.Canary = true
+		})
+		r, err := internalhandler.NewRuntime(ctx, guest, &host{}, options...)
+		if err != nil {
+			return err
+		}
+		canary = newRuntimeRef(r)
+	}
+
+	// The previous canary is closed once requests in flight complete.
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		if canary != nil {
+			return canary.Close(ctx)
+		}
+		return nil
+	}
+	if canary != nil && w.config != nil {
+		if err := canary.UpdateConfig(w.config); err != nil {
+			_ = canary.Close(ctx)
+			return err
+		}
+	}
+	w.closeCanary(ctx)
+	w.canary, w.canaryPercent = canary, percent
+	w.canaryGeneration++
+	w.stats = &canaryStats{}
+	return nil
+}
+
+// CanaryStats implements the same method as documented on
+// handler.CanaryRollout.
+func (w *middleware) CanaryStats() handler.CanaryStats {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.stats.load()
+}
+
+// closeCanary retires the canary, if any, so that it is closed once requests
+// in flight complete. This must be called with the write lock held.
+func (w *middleware) closeCanary(ctx context.Context) {
+	if w.canary != nil {
+		w.canary.retire(ctx)
+		w.canary = nil
+	}
+}
+
+// useCanary returns true if the canary should handle the request. This must
+// be called with the read lock held.
+func (w *middleware) useCanary(r *http.Request, id string) bool {
+	if w.canary == nil {
+		return false
+	} else if w.canaryPercent >= 100 {
+		return true
+	}
+	if id == "" {
+		id = r.Header.Get(internalhandler.RequestIDHeader)
+	}
+	var n uint32
+	if id == "" {
+		n = rand.Uint32() // nolint
+	} else {
+		h := fnv.New32a()
+		h.Write([]byte(id)) // nolint
+		n = h.Sum32()
+	}
+	return int(n%100) < w.canaryPercent
+}
+
+// currentCanary is like current, except it returns a guest instantiated
+// from the canary of the middleware.
+func (w *guest) currentCanary(ctx context.Context) (*internalhandler.Guest, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.canaryGeneration == w.m.canaryGeneration && w.canary != nil {
+		return w.canary, nil
+	}
+
+	// The previous canary, if any, was closed with its runtime.
+	g, err := w.m.canary.NewGuest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	w.canary, w.canaryGeneration = g, w.m.canaryGeneration
+	return g, nil
+}
+
+// canaryStats implements handler.CanaryStats with counters safe for
+// concurrent use.
+type canaryStats struct {
+	stableRequests, stableErrors uint64
+	canaryRequests, canaryErrors uint64
+}
+
+// record counts a request handled by the stable guest or the canary.
+func (s *canaryStats) record(canary, failed bool) {
+	requests, failures := &s.stableRequests, &s.stableErrors
+	if canary {
+		requests, failures = &s.canaryRequests, &s.canaryErrors
+	}
+	atomic.AddUint64(requests, 1)
+	if failed {
+		atomic.AddUint64(failures, 1)
+	}
+}
+
+func (s *canaryStats) load() handler.CanaryStats {
+	return handler.CanaryStats{
+		Stable: handler.VariantStats{
+			Requests: atomic.LoadUint64(&s.stableRequests),
+			Errors:   atomic.LoadUint64(&s.stableErrors),
+		},
+		Canary: handler.VariantStats{
+			Requests: atomic.LoadUint64(&s.canaryRequests),
+			Errors:   atomic.LoadUint64(&s.canaryErrors),
+		},
+	}
+}
@@ -0,0 +1,133 @@
+package wasm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestGuestConfigCanary(t *testing.T) {
+	tests := []struct {
+		name     string
+		percent  int
+		expected string
+	}{
+		{name: "disabled", percent: 0, expected: "stable"},
+		{name: "always", percent: 100, expected: "canary"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			mw, err := NewMiddleware(testCtx, test.ConfigWasm,
+				httpwasm.GuestConfigBytes([]byte("stable")),
+				httpwasm.GuestConfigCanary([]byte("canary"), tc.percent))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mw.Close(testCtx)
+
+			if body := serve(t, mw, noopHandler, httptest.NewRequest(http.MethodGet, "/", nil)); body != tc.expected {
+				t.Fatalf("expected body %q, have %q", tc.expected, body)
+			}
+		})
+	}
+
+	// The canary is validated like the guest config.
+	const schema = `{"type":"object"}`
+	guest := test.WithCustomSection(test.ConfigWasm, handler.CustomSectionConfigSchema, []byte(schema))
+	_, err := NewMiddleware(testCtx, guest,
+		httpwasm.GuestConfigBytes([]byte("{}")),
+		httpwasm.GuestConfigCanary([]byte("[]"), 10))
+	if expected := "wasm: invalid guest config canary: $: expected object, got array"; err == nil || err.Error() != expected {
+		t.Fatalf("expected error %q, have %v", expected, err)
+	}
+}
+
+func TestSetCanary(t *testing.T) {
+	// The canary sets the X-Protocol header, while the stable guest doesn't
+	// set any for requests which aren't RPCs.
+	mw, err := NewMiddleware(testCtx, test.RPCWasm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	var canaryInfo bool
+	h, err := mw.NewHandler(testCtx, http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		canaryInfo = FromContext(r.Context()).Canary
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close(testCtx)
+
+	canary := func(id string) bool {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Request-Id", id)
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		isCanary := w.Header().Get("X-Protocol") != ""
+		if canaryInfo != isCanary {
+			t.Fatalf("expected GuestInfo.Canary %v, have %v", isCanary, canaryInfo)
+		}
+		return isCanary
+	}
+
+	if canary("1") {
+		t.Fatal("expected no canary before SetCanary")
+	}
+
+	if err = mw.(handler.CanaryRollout).SetCanary(testCtx, test.ProtocolWasm, 100); err != nil {
+		t.Fatal(err)
+	}
+	if !canary("1") {
+		t.Fatal("expected canary at 100 percent")
+	}
+
+	if err = mw.(handler.CanaryRollout).SetCanary(testCtx, test.ProtocolWasm, 50); err != nil {
+		t.Fatal(err)
+	}
+	var canaries int
+	for i := 0; i < 100; i++ {
+		id := strconv.Itoa(i)
+		c := canary(id)
+		if c != canary(id) {
+			t.Fatalf("expected request ID %s to use the same guest", id)
+		}
+		if c {
+			canaries++
+		}
+	}
+	if canaries == 0 || canaries == 100 {
+		t.Errorf("expected some requests to use the canary, have %d of 100", canaries)
+	}
+	expected := handler.CanaryStats{
+		Stable: handler.VariantStats{Requests: uint64(2 * (100 - canaries))},
+		Canary: handler.VariantStats{Requests: uint64(2 * canaries)},
+	}
+	if have := mw.(handler.CanaryRollout).CanaryStats(); have != expected {
+		t.Errorf("expected stats %+v, have %+v", expected, have)
+	}
+
+	if err = mw.(handler.CanaryRollout).SetCanary(testCtx, nil, 0); err != nil {
+		t.Fatal(err)
+	}
+	if canary("1") {
+		t.Fatal("expected no canary after removing it")
+	}
+
+	chain, err := NewMiddlewareChain(testCtx, [][]byte{test.RPCWasm, test.ProtocolWasm})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close(testCtx)
+	if _, ok := chain.(handler.CanaryRollout); ok {
+		t.Error("expected chain to not support canaries")
+	}
+}
@@ -0,0 +1,178 @@
+package wasm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/api"
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	internalhandler "github.com/http-wasm/http-wasm-host-go/internal/handler"
+)
+
+type chain struct {
+	middlewares []*middleware
+}
+
+// NewMiddlewareChain is like NewMiddleware, except it runs multiple guests in
+// order: handler.FuncNext in guest N invokes guest N+1, and in the last guest
+// invokes the next handler.
+//
+// The options apply to each guest, as if passed to NewMiddleware for it. For
+// example, httpwasm.Prewarm instantiates that many of each guest,
+// httpwasm.GuestConfig passes the same config to each, and quotas, such as
+// httpwasm.MaxHeaderMutations, limit each guest separately.
+//
+// This is more efficient than nesting middleware, as guests share the state
+// of the current request.
+func NewMiddlewareChain(ctx context.Context, guests [][]byte, options ...httpwasm.Option) (Middleware, error) {
+	if len(guests) == 0 {
+		return nil, errors.New("wasm: no guests to chain")
+	}
+
+	c := &chain{}
+	for _, guest := range guests {
+		mw, err := NewMiddleware(ctx, guest, options...)
+		if err != nil {
+			_ = c.Close(ctx)
+			return nil, err
+		}
+		c.middlewares = append(c.middlewares, mw.(*middleware))
+	}
+	return c, nil
+}
+
+// NewHandler implements the same method as documented on handler.Middleware.
+func (c *chain) NewHandler(ctx context.Context, next http.Handler) (Handler, error) {
+	h := &chainHandler{next: next}
+	for _, m := range c.middlewares {
+		g, err := m.NewHandler(ctx, nil)
+		if err != nil {
+			_ = h.Close(ctx)
+			return nil, err
+		}
+		h.guests = append(h.guests, g.(*guest))
+	}
+	return h, nil
+}
+
+// CustomSection implements the same method as documented on
+// handler.CustomSectionReader. This returns the first match, searching the guests in
+// order.
+func (c *chain) CustomSection(name string) ([]byte, bool) {
+	for _, m := range c.middlewares {
+		if data, ok := m.CustomSection(name); ok {
+			return data, true
+		}
+	}
+	return nil, false
+}
+
+// GuestMetadata implements the same method as documented on
+// handler.GuestMetadataReader. This is the metadata of the first guest, as each guest
+// has its own. Use the middleware of each guest to inventory all of them.
+func (c *chain) GuestMetadata() handler.GuestMetadata {
+	return c.middlewares[0].GuestMetadata()
+}
+
+// CompileReport implements the same method as documented on
+// handler.CompileReporter. The duration is the total of all guests, and the cache
+// is only hit if it was for all guests.
+func (c *chain) CompileReport() api.CompileReport {
+	report := c.middlewares[0].CompileReport()
+	for _, m := range c.middlewares[1:] {
+		r := m.CompileReport()
+		report.Duration += r.Duration
+		report.CacheHit = report.CacheHit && r.CacheHit
+		if report.Mode != r.Mode {
+			report.Mode = "" // mixed
+		}
+	}
+	return report
+}
+
+// Ping implements the same method as documented on handler.Pinger,
+// returning the first error of any guest.
+func (c *chain) Ping(ctx context.Context) error {
+	for _, m := range c.middlewares {
+		if err := m.Ping(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close implements the same method as documented on handler.Middleware.
+func (c *chain) Close(ctx context.Context) (err error) {
+	for _, m := range c.middlewares {
+		if e := m.Close(ctx); e != nil {
+			err = e
+		}
+	}
+	return
+}
+
+// CloseGracefully implements the same method as documented on
+// handler.GracefulCloser. The timeout applies to all guests.
+func (c *chain) CloseGracefully(ctx context.Context, timeout time.Duration) (err error) {
+	deadline := time.Now().Add(timeout)
+	for _, m := range c.middlewares {
+		if e := m.CloseGracefully(ctx, time.Until(deadline)); e != nil {
+			err = e
+		}
+	}
+	return
+}
+
+// compile-time check to ensure chainHandler implements Handler.
+var _ Handler = &chainHandler{}
+
+type chainHandler struct {
+	guests []*guest
+	next   http.Handler
+	// current is the guests of the current request.
+	current []*internalhandler.Guest
+}
+
+// ServeHTTP implements http.Handler
+func (c *chainHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	// Reuse the slice of guests, as handlers aren't used concurrently.
+	guests := c.current[:0]
+	var first *runtimeRef
+	for _, w := range c.guests {
+		r, g, err := w.selectCurrent(request.Context())
+		defer r.release()
+		if err != nil {
+			unavailable(r, response, request, c.next, err)
+			return
+		}
+		if first == nil {
+			first = r
+		}
+		guests = append(guests, g)
+	}
+	c.current = guests
+
+	ctx, s := withRequestState(request.Context(), response, request, c.next, guests...)
+	defer s.release()
+	s.setRequestID(first.RequestID(request.Header))
+	s.setBodyLimits(first.BodyLimits())
+	s.rawHeaders = rawRequestHeaders(request)
+	if err := guests[0].Handle(ctx); err != nil && !isGuestError(err) {
+		serveError(response, err)
+		return
+	}
+	s.response.commit()
+}
+
+// Close implements api.Closer
+func (c *chainHandler) Close(ctx context.Context) (err error) {
+	for _, g := range c.guests {
+		if e := g.Close(ctx); e != nil {
+			err = e
+		}
+	}
+	return
+}
@@ -0,0 +1,87 @@
+package wasm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestNewMiddlewareChain(t *testing.T) {
+	var messages []string
+	logger := func(_ context.Context, msg string) { messages = append(messages, msg) }
+	next := http.HandlerFunc(func(http.ResponseWriter, *http.Request) { messages = append(messages, "next") })
+
+	mw, err := NewMiddlewareChain(testCtx, [][]byte{test.LogWasm, test.HandleResponseWasm}, httpwasm.Logger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	serve(t, mw, next, httptest.NewRequest(http.MethodGet, "/", nil))
+	if have := strings.Join(messages, ","); have != "before,request,next,response,after" {
+		t.Fatalf("unexpected order: %s", have)
+	}
+
+	// The chain stops when a guest doesn't call next.
+	messages = nil
+	mw, err = NewMiddlewareChain(testCtx, [][]byte{test.LogWasm, test.AuthWasm, test.HandleResponseWasm}, httpwasm.Logger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	serve(t, mw, next, httptest.NewRequest(http.MethodGet, "/", nil))
+	if have := strings.Join(messages, ","); have != "before,after" {
+		t.Fatalf("unexpected order: %s", have)
+	}
+}
+
+func TestNewMiddlewareChain_ConcurrentHandle(t *testing.T) {
+	mw, err := NewMiddlewareChain(testCtx, [][]byte{test.HandleRequestWasm, test.HandleRequestWasm})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	// The next handler re-enters the same handler, whose guests are still
+	// handling the outer request.
+	var h Handler
+	var inner *httptest.ResponseRecorder
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inner = httptest.NewRecorder()
+		h.ServeHTTP(inner, httptest.NewRequest("GET", "/", nil))
+		w.WriteHeader(200)
+	})
+	if h, err = mw.NewHandler(testCtx, next); err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close(testCtx)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != 200 {
+		t.Errorf("expected outer status 200, have %d", w.Code)
+	}
+	// The error isn't written to the response.
+	if inner.Code != http.StatusServiceUnavailable || inner.Body.Len() != 0 {
+		t.Errorf("expected inner status 503 without a body, have %d %q", inner.Code, inner.Body)
+	}
+}
+
+func TestNewMiddlewareChain_Scratch(t *testing.T) {
+	mw, err := NewMiddlewareChain(testCtx, [][]byte{test.ScratchWasm, test.ScratchWasm})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	// The first guest writes the scratch area, which the second responds with.
+	if body := serve(t, mw, noopHandler, httptest.NewRequest(http.MethodGet, "/", nil)); body != "hello" {
+		t.Fatalf("expected body %q, have %q", "hello", body)
+	}
+}
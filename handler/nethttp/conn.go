@@ -0,0 +1,42 @@
+package wasm
+
+import (
+	"context"
+)
+
+// GetSourceAddr implements the same method as documented on
+// handler.ConnectionInfo.
+func (h host) GetSourceAddr(ctx context.Context) string {
+	return requestStateFromContext(ctx).request.RemoteAddr
+}
+
+// GetTLSVersion implements the same method as documented on
+// handler.ConnectionInfo.
+func (h host) GetTLSVersion(ctx context.Context) uint32 {
+	if r := requestStateFromContext(ctx).request; r.TLS != nil {
+		return uint32(r.TLS.Version)
+	}
+	return 0
+}
+
+// GetTLSPeerCert implements the same method as documented on
+// handler.ConnectionInfo.
+func (h host) GetTLSPeerCert(ctx context.Context) []byte {
+	r := requestStateFromContext(ctx).request
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return nil
+	}
+	// The first certificate is the client's, followed by intermediates.
+	return r.TLS.PeerCertificates[0].Raw
+}
+
+// GetMethod implements the same method as documented on handler.Host.
+func (h host) GetMethod(ctx context.Context) string {
+	return requestStateFromContext(ctx).request.Method
+}
+
+// GetProtocolVersion implements the same method as documented on
+// handler.ConnectionInfo.
+func (h host) GetProtocolVersion(ctx context.Context) string {
+	return protocolVersion(requestStateFromContext(ctx).request)
+}
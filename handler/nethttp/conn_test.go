@@ -0,0 +1,40 @@
+package wasm
+
+import (
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestTLS(t *testing.T) {
+	mw, err := NewMiddleware(testCtx, test.TLSWasm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	h, err := mw.NewHandler(testCtx, noopHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close(testCtx)
+
+	// The guest rejects requests not over TLS.
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "http://test/", nil))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, have %d", http.StatusForbidden, w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "https://test/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	req.TLS.PeerCertificates = []*x509.Certificate{{Raw: []byte("der")}}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if expected, have := "192.0.2.1:1234,der", w.Body.String(); have != expected {
+		t.Fatalf("expected body %q, have %q", expected, have)
+	}
+}
@@ -0,0 +1,34 @@
+package wasm
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// SetCookie implements the same method as documented on handler.CookieSetter.
+func (h host) SetCookie(ctx context.Context, name, value, attrs string) {
+	c := parseSetCookie(name, value, attrs)
+	if c == nil {
+		panic(fmt.Errorf("invalid cookie %q", name))
+	}
+	http.SetCookie(requestStateFromContext(ctx).response, c)
+}
+
+// parseSetCookie parses the cookie the same way as a "Set-Cookie" header, so
+// that attributes are validated. This returns nil if the cookie is invalid.
+func parseSetCookie(name, value, attrs string) *http.Cookie {
+	line := name + "=" + value
+	if attrs != "" {
+		line += "; " + attrs
+	}
+	resp := http.Response{Header: http.Header{"Set-Cookie": {line}}}
+	cookies := resp.Cookies()
+	if len(cookies) != 1 {
+		return nil
+	}
+	c := cookies[0]
+	// Retain the value as given, as the parser strips quotes.
+	c.Value = value
+	return c
+}
@@ -0,0 +1,46 @@
+package wasm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestCookie(t *testing.T) {
+	tests := []struct {
+		name, cookie, expected string
+	}{
+		{name: "new", expected: "session=new; Path=/; Max-Age=60; HttpOnly"},
+		{name: "existing", cookie: "a=1; session=abc", expected: "seen=abc"},
+	}
+
+	mw, err := NewMiddleware(testCtx, test.CookieWasm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			h, err := mw.NewHandler(testCtx, noopHandler)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer h.Close(testCtx)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.cookie != "" {
+				req.Header.Set("Cookie", tc.cookie)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if have := w.Header().Get("Set-Cookie"); have != tc.expected {
+				t.Fatalf("expected Set-Cookie %q, have %q", tc.expected, have)
+			}
+		})
+	}
+}
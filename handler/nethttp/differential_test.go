@@ -0,0 +1,218 @@
+package wasm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/api"
+	"github.com/http-wasm/http-wasm-host-go/handlertest"
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+// adapters are the ways to host a guest, which must be indistinguishable to
+// clients. Add new adapters, such as for other HTTP servers, here.
+var adapters = []struct {
+	name          string
+	newMiddleware func(ctx context.Context, guest []byte, options ...httpwasm.Option) (Middleware, error)
+}{
+	{name: "middleware", newMiddleware: NewMiddleware},
+	{name: "chain", newMiddleware: func(ctx context.Context, guest []byte, options ...httpwasm.Option) (Middleware, error) {
+		return NewMiddlewareChain(ctx, [][]byte{guest}, options...)
+	}},
+}
+
+// referenceGuests are deterministic, so that adapters can be compared.
+var referenceGuests = map[string][]byte{
+	"auth":               test.AuthWasm,
+	"body_chunk":         test.BodyChunkWasm,
+	"config":             test.ConfigWasm,
+	"cookie":             test.CookieWasm,
+	"features":           test.FeaturesWasm,
+	"handle_response":    test.HandleResponseWasm,
+	"log":                test.LogWasm,
+	"property":           test.PropertyWasm,
+	"query":              test.QueryWasm,
+	"quota":              test.QuotaWasm,
+	"read_body":          test.ReadBodyWasm,
+	"read_response_body": test.ReadResponseBodyWasm,
+	"scratch":            test.ScratchWasm,
+	"status":             test.StatusWasm,
+	"trailer":            test.TrailerWasm,
+	"trap":               test.TrapWasm,
+}
+
+// observed is everything a client or operator can observe about a request.
+type observed struct {
+	status   int
+	header   http.Header
+	body     string
+	trailer  http.Header
+	messages []string
+}
+
+// FuzzAdapters serves the same randomized requests with each reference guest
+// through every adapter, failing when their responses differ. This guards
+// against the semantics of adapters drifting apart. As adapters share most of
+// this package, each is also compared with handlertest.Host, an independent
+// in-memory host. To run beyond the seed corpus:
+//
+//	go test -run='^$' -fuzz=FuzzAdapters ./handler/nethttp
+func FuzzAdapters(f *testing.F) {
+	f.Add("GET", "/", "", "")
+	f.Add("POST", "/v1.0/hi?name=panda", "Basic aGVsbG86d29ybGQ=", "hello")
+	f.Add("PUT", "/path?a=b&a=c", "session=abc; theme=dark", "x-next")
+	f.Add("DELETE", "/%2F?q", "Bearer token", strings.Repeat("chunk", 1000))
+
+	type handler struct {
+		h        Handler
+		messages *[]string
+	}
+	handlers := map[string][]handler{}
+	for name, guest := range referenceGuests {
+		for _, a := range adapters {
+			messages := new([]string)
+			mw, err := a.newMiddleware(testCtx, guest, httpwasm.Logger(func(_ context.Context, msg string) {
+				*messages = append(*messages, msg)
+			}))
+			if err != nil {
+				f.Fatal(err)
+			}
+			defer mw.Close(testCtx)
+
+			h, err := mw.NewHandler(testCtx, http.HandlerFunc(echo))
+			if err != nil {
+				f.Fatal(err)
+			}
+			defer h.Close(testCtx)
+			handlers[name] = append(handlers[name], handler{h: h, messages: messages})
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, method, target, header, body string) {
+		// Skip inputs that aren't valid requests, which never reach
+		// middleware.
+		if _, err := http.NewRequest(method, "http://localhost"+target, nil); err != nil || !strings.HasPrefix(target, "/") {
+			t.Skip()
+		}
+
+		for name, hs := range handlers {
+			req, _ := http.NewRequest(method, "http://localhost"+target, nil)
+			req.Header.Set("Authorization", header)
+			req.Header.Set("Cookie", header)
+			if strings.HasPrefix(body, "x-next") {
+				req.Header.Set("X-Next", "1")
+			}
+			reference := serveReference(referenceGuests[name], req, body)
+
+			var results []observed
+			for _, h := range hs {
+				req, _ := http.NewRequest(method, "http://localhost"+target, strings.NewReader(body))
+				req.Header.Set("Authorization", header)
+				req.Header.Set("Cookie", header)
+				if strings.HasPrefix(body, "x-next") {
+					req.Header.Set("X-Next", "1")
+				}
+
+				*h.messages = nil
+				w := httptest.NewRecorder()
+				h.h.ServeHTTP(w, req)
+
+				resp := w.Result()
+				b, _ := io.ReadAll(resp.Body)
+				results = append(results, observed{
+					status:   resp.StatusCode,
+					header:   resp.Header,
+					body:     string(b),
+					trailer:  resp.Trailer,
+					messages: *h.messages,
+				})
+			}
+			for i := range results {
+				if have := results[i].withoutServerHeaders(); !reflect.DeepEqual(reference, have) {
+					t.Fatalf("%s: %s differs from handlertest:\n%+v\n%+v", name,
+						adapters[i].name, have, reference)
+				}
+			}
+		}
+	})
+}
+
+// echo is the next handler of FuzzAdapters, which responds with the request,
+// so that differences in what the guest passes to it are observed.
+func echo(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Method", r.Method)
+	w.Header().Set("X-URI", r.URL.RequestURI())
+	w.Header().Set("X-Authorization", r.Header.Get("Authorization"))
+	io.Copy(w, r.Body) // nolint
+	// Commit the response even without a body, as handlertest.Host does.
+	if f, ok := w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// serveReference serves the request with the guest on handlertest.Host, with a
+// next handler equivalent to echo. The guest is compiled per request, so this
+// uses the interpreter, which starts faster.
+func serveReference(guest []byte, req *http.Request, body string) observed {
+	var messages []string
+	h := &handlertest.Host{
+		Method:        req.Method,
+		RequestHeader: req.Header.Clone(),
+		Query:         req.URL.Query(),
+		RequestBody:   []byte(body),
+		NextHandler: func(_ context.Context, h *handlertest.Host) {
+			// Like the nethttp host, only re-encode the query if changed.
+			u := *req.URL
+			for _, c := range h.Calls {
+				if c.Method == "SetQueryValue" {
+					u.RawQuery = h.Query.Encode()
+				}
+			}
+			if h.ResponseHeader == nil {
+				h.ResponseHeader = http.Header{}
+			}
+			h.ResponseHeader.Set("X-Method", req.Method)
+			h.ResponseHeader.Set("X-URI", u.RequestURI())
+			h.ResponseHeader.Set("X-Authorization", h.RequestHeader.Get("Authorization"))
+			h.ResponseBody = append(h.ResponseBody, h.RequestBody...)
+		},
+	}
+	handlertest.Run(testCtx, guest, h, httpwasm.CompileMode(api.CompileModeInterpreter), // nolint
+		httpwasm.Logger(func(_ context.Context, msg string) {
+			messages = append(messages, msg)
+		}))
+
+	resp := h.Result()
+	b, _ := io.ReadAll(resp.Body)
+	return observed{
+		status:   resp.StatusCode,
+		header:   emptyAsNil(resp.Header),
+		body:     string(b),
+		trailer:  emptyAsNil(resp.Trailer),
+		messages: messages,
+	}.withoutServerHeaders()
+}
+
+// withoutServerHeaders returns the observation without headers net/http adds,
+// which handlertest.Host doesn't, and with empty headers as nil.
+func (o observed) withoutServerHeaders() observed {
+	o.header = o.header.Clone()
+	o.header.Del("Content-Type")
+	o.header.Del("Content-Length")
+	o.header = emptyAsNil(o.header)
+	o.trailer = emptyAsNil(o.trailer)
+	return o
+}
+
+func emptyAsNil(h http.Header) http.Header {
+	if len(h) == 0 {
+		return nil
+	}
+	return h
+}
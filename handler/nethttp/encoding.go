@@ -0,0 +1,103 @@
+package wasm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// decodableEncoding returns the value of the "Content-Encoding" header of
+// the response, normalized, if handler.FeatureDecodeResponse supports it.
+func decodableEncoding(header http.Header) (string, bool) {
+	switch e := strings.ToLower(strings.TrimSpace(header.Get("Content-Encoding"))); e {
+	case "gzip", "x-gzip":
+		return "gzip", true
+	case "deflate":
+		return e, true
+	}
+	return "", false
+}
+
+// decodeBody decompresses the body with the encoding from decodableEncoding.
+// This returns handler.ErrBodyTooLarge if the result is larger than max, if
+// positive, without decompressing the rest.
+func decodeBody(encoding string, body []byte, max int64) ([]byte, error) {
+	var r io.ReadCloser
+	var err error
+	if encoding == "gzip" {
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	} else {
+		r, err = zlib.NewReader(bytes.NewReader(body))
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if max <= 0 {
+		return io.ReadAll(r)
+	}
+	decoded, err := io.ReadAll(io.LimitReader(r, max+1))
+	if err == nil && int64(len(decoded)) > max {
+		return nil, handler.ErrBodyTooLarge
+	}
+	return decoded, err
+}
+
+// encodeBody compresses the body with the encoding from decodableEncoding.
+func encodeBody(encoding string, body []byte) []byte {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	if encoding == "gzip" {
+		w = gzip.NewWriter(&buf)
+	} else {
+		w = zlib.NewWriter(&buf)
+	}
+	w.Write(body) // nolint: writes to a bytes.Buffer don't fail
+	w.Close()     // nolint
+	return buf.Bytes()
+}
+
+// decodedBody returns the buffered body, decoding it first when
+// handler.FeatureDecodeResponse is enabled. The body is returned as-is when
+// it isn't encoded or fails to decode, as the guest can still read it. When
+// the decoded body is larger than httpwasm.MaxBodyBuffer, it is discarded, as
+// if the next handler wrote it decoded, and the guest traps with
+// handler.ErrBodyTooLarge, so the response fails with 502 Bad Gateway.
+func (w *responseWriter) decodedBody() []byte {
+	if !w.decode || w.decoded {
+		return w.body
+	}
+	encoding, ok := decodableEncoding(w.Header())
+	if !ok {
+		return w.body
+	}
+	body, err := decodeBody(encoding, w.body, w.maxBody)
+	if err == handler.ErrBodyTooLarge {
+		w.discardTooLarge()
+		panic(err)
+	} else if err != nil {
+		return w.body
+	}
+	w.body, w.bodySize = body, uint64(len(body))
+	w.decoded, w.encoding = true, encoding
+	return w.body
+}
+
+// encodeBody encodes the buffered body before it is committed, if it was
+// decoded and the guest didn't change its encoding.
+func (w *responseWriter) encodeBody() {
+	if !w.decoded || len(w.body) == 0 {
+		return
+	}
+	if encoding, ok := decodableEncoding(w.Header()); !ok || encoding != w.encoding {
+		return
+	}
+	w.body = encodeBody(w.encoding, w.body)
+	w.Header().Set("Content-Length", strconv.Itoa(len(w.body)))
+}
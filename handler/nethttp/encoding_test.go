@@ -0,0 +1,135 @@
+package wasm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestDecodeResponseBody(t *testing.T) {
+	var gzipped bytes.Buffer
+	zw := gzip.NewWriter(&gzipped)
+	zw.Write([]byte("hello")) // nolint
+	zw.Close()                // nolint
+
+	tests := []struct {
+		name             string
+		options          []httpwasm.Option
+		contentEncoding  string
+		expectedEncoding string
+		expectedBody     string
+	}{
+		{
+			name:             "decoded",
+			options:          []httpwasm.Option{httpwasm.DecodeResponseBody()},
+			contentEncoding:  "gzip",
+			expectedEncoding: "gzip",
+			expectedBody:     "<hello>",
+		},
+		{
+			// The guest wraps the compressed bytes, so the client can't
+			// decode them.
+			name:             "not decoded",
+			contentEncoding:  "gzip",
+			expectedEncoding: "gzip",
+			expectedBody:     "<" + gzipped.String() + ">",
+		},
+		{
+			name:            "unsupported encoding",
+			options:         []httpwasm.Option{httpwasm.DecodeResponseBody()},
+			contentEncoding: "br",
+			// The bytes aren't brotli, but they aren't decoded anyway.
+			expectedEncoding: "br",
+			expectedBody:     "<" + gzipped.String() + ">",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			mw, err := NewMiddleware(testCtx, test.ReadResponseBodyWasm, tc.options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mw.Close(testCtx)
+
+			next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Encoding", tc.contentEncoding)
+				w.Header().Set("Content-Length", strconv.Itoa(gzipped.Len()))
+				w.Write(gzipped.Bytes()) // nolint
+			})
+
+			h, err := mw.NewHandler(testCtx, next)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer h.Close(testCtx)
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			resp := w.Result()
+			if have := resp.Header.Get("Content-Encoding"); have != tc.expectedEncoding {
+				t.Fatalf("expected Content-Encoding %q, have %q", tc.expectedEncoding, have)
+			}
+			body := w.Body.Bytes()
+			if have := resp.Header.Get("Content-Length"); have != "" && have != strconv.Itoa(len(body)) {
+				t.Fatalf("expected Content-Length %d, have %s", len(body), have)
+			}
+			if tc.options != nil && tc.contentEncoding == "gzip" {
+				zr, err := gzip.NewReader(bytes.NewReader(body))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if body, err = io.ReadAll(zr); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if string(body) != tc.expectedBody {
+				t.Fatalf("expected body %q, have %q", tc.expectedBody, body)
+			}
+		})
+	}
+}
+
+func TestDecodeResponseBody_TooLarge(t *testing.T) {
+	// The body compresses well, so is small until decoded.
+	var gzipped bytes.Buffer
+	zw := gzip.NewWriter(&gzipped)
+	zw.Write(make([]byte, 1<<20)) // nolint
+	zw.Close()                    // nolint
+
+	mw, err := NewMiddleware(testCtx, test.ReadResponseBodyWasm,
+		httpwasm.DecodeResponseBody(),
+		httpwasm.MaxBodyBuffer(4096))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(gzipped.Bytes()) // nolint
+	})
+	h, err := mw.NewHandler(testCtx, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close(testCtx)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("expected status code %d, have %d", http.StatusBadGateway, w.Code)
+	}
+	if w.Body.Len() > 4096 {
+		t.Fatalf("expected the decoded body to be discarded, have %d bytes", w.Body.Len())
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 
 	httpwasm "github.com/http-wasm/http-wasm-host-go"
 	"github.com/http-wasm/http-wasm-host-go/internal/test"
@@ -120,3 +121,31 @@ func Example_log() {
 	// after
 	// {"hello": "world"}
 }
+
+func Example_random() {
+	ctx := context.Background()
+
+	// Configure and compile the WebAssembly guest binary. In this case, it
+	// responds with a random nonce. A fixed source of random bytes makes the
+	// output deterministic, which is useful in tests. Don't do this in
+	// production!
+	random := strings.NewReader("not-very-random!")
+	mw, err := NewMiddleware(ctx, test.RandomWasm, httpwasm.Random(random))
+	if err != nil {
+		log.Panicln(err)
+	}
+	defer mw.Close(ctx)
+
+	// Wrap a handler that is never invoked, as the guest responds itself.
+	wrapped, err := mw.NewHandler(ctx, http.NotFoundHandler())
+	if err != nil {
+		log.Panicln(err)
+	}
+
+	w := httptest.NewRecorder()
+	wrapped.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	fmt.Println(w.Body.String())
+
+	// Output:
+	// not-very-random!
+}
@@ -0,0 +1,71 @@
+package wasm
+
+import (
+	"context"
+	"net/http"
+)
+
+// supporter is implemented by middleware that knows which requests its
+// guests support.
+type supporter interface {
+	supports(r *http.Request) bool
+}
+
+// WithFallback returns middleware that invokes the fallback handler instead of
+// the guest for requests the guest declares it doesn't support, via the
+// custom section named handler.CustomSectionRoutes. This allows mixing native
+// and WebAssembly handlers without an additional routing layer.
+//
+// When middleware chains guests, requests any of them don't support fall
+// back.
+//
+// The result only implements handler.Middleware, so check optional
+// interfaces, such as handler.Pinger, on the middleware passed in.
+func WithFallback(mw Middleware, fallback http.Handler) Middleware {
+	return &fallbackMiddleware{Middleware: mw, fallback: fallback}
+}
+
+type fallbackMiddleware struct {
+	Middleware
+	fallback http.Handler
+}
+
+// NewHandler implements the same method as documented on handler.Middleware.
+func (m *fallbackMiddleware) NewHandler(ctx context.Context, next http.Handler) (Handler, error) {
+	h, err := m.Middleware.NewHandler(ctx, next)
+	if err != nil {
+		return nil, err
+	}
+	return &fallbackHandler{Handler: h, m: m}, nil
+}
+
+type fallbackHandler struct {
+	Handler
+	m *fallbackMiddleware
+}
+
+// ServeHTTP implements http.Handler
+func (h *fallbackHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	if s, ok := h.m.Middleware.(supporter); ok && !s.supports(request) {
+		h.m.fallback.ServeHTTP(response, request)
+		return
+	}
+	h.Handler.ServeHTTP(response, request)
+}
+
+// supports implements supporter.
+func (w *middleware) supports(r *http.Request) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.runtime.Supports(r.Method, cleanPath(r.URL.Path))
+}
+
+// supports implements supporter.
+func (c *chain) supports(r *http.Request) bool {
+	for _, m := range c.middlewares {
+		if !m.supports(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,44 @@
+package wasm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestWithFallback(t *testing.T) {
+	const routes = `{"methods":["GET"],"path_prefixes":["/api/"]}`
+	guest := test.WithCustomSection(test.ConfigWasm, handler.CustomSectionRoutes, []byte(routes))
+
+	mw, err := NewMiddleware(testCtx, guest, httpwasm.GuestConfigBytes([]byte("guest")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mw = WithFallback(mw, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("fallback")) // nolint
+	}))
+	defer mw.Close(testCtx)
+
+	tests := []struct {
+		method, target, expected string
+	}{
+		{method: http.MethodGet, target: "/api/users", expected: "guest"},
+		{method: http.MethodPost, target: "/api/users", expected: "fallback"},
+		{method: http.MethodGet, target: "/static/app.js", expected: "fallback"},
+		{method: http.MethodGet, target: "//api/users", expected: "guest"},
+		{method: http.MethodGet, target: "/static/../api/users", expected: "guest"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.method+" "+tc.target, func(t *testing.T) {
+			if have := serve(t, mw, noopHandler, httptest.NewRequest(tc.method, tc.target, nil)); have != tc.expected {
+				t.Fatalf("expected body %q, have %q", tc.expected, have)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package wasm
+
+import (
+	"context"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// supportedFeatures are the features this host can enable.
+const supportedFeatures = handler.FeatureBufferRequest |
+	handler.FeatureBufferResponse |
+	handler.FeatureTrailers |
+	handler.FeatureDecodeResponse
+
+// EnableFeatures implements the same method as documented on handler.Host.
+func (h host) EnableFeatures(ctx context.Context, features handler.Features) handler.Features {
+	s := requestStateFromContext(ctx)
+	features &= supportedFeatures
+
+	if features&handler.FeatureBufferRequest != 0 {
+		s.bufferRequestBody()
+	}
+	if features&handler.FeatureBufferResponse != 0 {
+		if s.response.committed {
+			features &^= handler.FeatureBufferResponse // too late
+		} else {
+			s.response.buffering = true
+		}
+	}
+
+	if features&handler.FeatureDecodeResponse != 0 {
+		s.response.decode = true
+	}
+
+	s.features |= features
+	return s.features
+}
+
+// Capabilities implements the same method as documented on handler.Host.
+func (h host) Capabilities(context.Context) handler.Features {
+	return supportedFeatures
+}
+
+// GetResponseBody implements the same method as documented on handler.Host.
+func (h host) GetResponseBody(ctx context.Context) []byte {
+	if w := requestStateFromContext(ctx).response; w.buffering {
+		return w.decodedBody()
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package wasm
+
+import (
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestEnableFeatures(t *testing.T) {
+	mw, err := NewMiddleware(testCtx, test.FeaturesWasm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	h, err := mw.NewHandler(testCtx, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Length", "4")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("next")) // nolint
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close(testCtx)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// The guest replaced the buffered response of the next handler.
+	if have := w.Code; have != http.StatusOK {
+		t.Fatalf("expected status %d, have %d", http.StatusOK, have)
+	}
+	if have := w.Body.String(); have != "<next>" {
+		t.Fatalf("expected body %q, have %q", "<next>", have)
+	}
+	if have := w.Header().Get("Content-Length"); have != "" {
+		t.Fatalf("expected no stale Content-Length, have %q", have)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	supported := handler.FeatureBufferRequest | handler.FeatureBufferResponse |
+		handler.FeatureTrailers | handler.FeatureSharedStore | handler.FeatureDecodeResponse
+
+	tests := []struct {
+		name     string
+		options  []httpwasm.Option
+		expected handler.Features
+	}{
+		{name: "default", expected: supported},
+		{
+			name:     "http call",
+			options:  []httpwasm.Option{httpwasm.HTTPCallHosts("example.com")},
+			expected: supported | handler.FeatureHTTPCall,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			mw, err := NewMiddleware(testCtx, test.CapabilitiesWasm, tc.options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mw.Close(testCtx)
+
+			body := serve(t, mw, noopHandler, httptest.NewRequest(http.MethodGet, "/", nil))
+			if have := handler.Features(binary.LittleEndian.Uint64([]byte(body))); have != tc.expected {
+				t.Fatalf("expected features %b, have %b", tc.expected, have)
+			}
+		})
+	}
+}
+
+func TestResponseBuffering(t *testing.T) {
+	tests := []struct {
+		name         string
+		guest        []byte
+		buffered     bool
+		expectedBody string
+	}{
+		{
+			name:         "streams when not reading the body",
+			guest:        test.LogWasm,
+			expectedBody: "next",
+		},
+		{
+			name:         "buffers when importing read_response_body",
+			guest:        test.ReadResponseBodyWasm,
+			buffered:     true,
+			expectedBody: "<next>",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			mw, err := NewMiddleware(testCtx, tc.guest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mw.Close(testCtx)
+
+			w := httptest.NewRecorder()
+			h, err := mw.NewHandler(testCtx, http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+				rw.Write([]byte("next")) // nolint
+				// Only a streamed response reaches the client before the
+				// guest returns.
+				if streamed := w.Body.Len() > 0; streamed == tc.buffered {
+					t.Errorf("expected buffered %v, but streamed %v", tc.buffered, streamed)
+				}
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer h.Close(testCtx)
+
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if have := w.Body.String(); have != tc.expectedBody {
+				t.Fatalf("expected body %q, have %q", tc.expectedBody, have)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package wasm
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/internal"
+	internalhandler "github.com/http-wasm/http-wasm-host-go/internal/handler"
+)
+
+// reloadDelay coalesces bursts of file events, such as a deployment tool
+// writing a file in multiple chunks, into a single reload.
+const reloadDelay = 100 * time.Millisecond
+
+// NewMiddlewareFromFile is like NewMiddleware, except it reads the guest from
+// a file and reloads it whenever the file changes. Requests in-flight during
+// a reload complete with the previous guest.
+//
+// Use httpwasm.OnReload to be notified of reloads. When a reload fails, for
+// example due to a guest that doesn't compile, the previous guest remains in
+// use until the file changes again.
+func NewMiddlewareFromFile(ctx context.Context, path string, options ...httpwasm.Option) (Middleware, error) {
+	guest, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	mw, err := NewMiddleware(ctx, guest, options...)
+	if err != nil {
+		return nil, err
+	}
+	m := mw.(*middleware)
+
+	// Watch the directory instead of the file, as deployment tools often
+	// replace a file by renaming another over it.
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		_ = m.Close(ctx)
+		return nil, err
+	}
+	if err = watcher.Add(filepath.Dir(path)); err != nil {
+		_ = watcher.Close()
+		_ = m.Close(ctx)
+		return nil, err
+	}
+	m.watcher = watcher
+
+	o := &internal.WazeroOptions{}
+	for _, option := range options {
+		option(o)
+	}
+	go m.watch(watcher, path, options, o.OnReload)
+	return m, nil
+}
+
+// watch reloads the guest when the file at path changes, until the watcher
+// is closed.
+func (w *middleware) watch(watcher *fsnotify.Watcher, path string, options []httpwasm.Option, onReload func(context.Context, string, error)) {
+	ctx := context.Background()
+	path = filepath.Clean(path)
+
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != path || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			if timer != nil {
+				timer.Stop()
+			}
+			timer = time.AfterFunc(reloadDelay, func() {
+				err := w.reload(ctx, path, options)
+				if onReload != nil {
+					onReload(ctx, path, err)
+				}
+			})
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			if onReload != nil {
+				onReload(ctx, path, err)
+			}
+		}
+	}
+}
+
+// reload compiles the guest from the file at path and swaps it in.
+func (w *middleware) reload(ctx context.Context, path string, options []httpwasm.Option) error {
+	guest, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	r, err := internalhandler.NewRuntime(ctx, guest, &host{}, options...)
+	if err != nil {
+		return err
+	}
+	return w.swapRuntime(ctx, r)
+}
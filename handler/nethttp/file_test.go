@@ -0,0 +1,78 @@
+package wasm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestNewMiddlewareFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "guest.wasm")
+	if err := os.WriteFile(path, test.AuthWasm, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded := make(chan error, 1)
+	onReload := func(_ context.Context, _ string, err error) { reloaded <- err }
+	mw, err := NewMiddlewareFromFile(testCtx, path, httpwasm.OnReload(onReload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	h, err := mw.NewHandler(testCtx, noopHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close(testCtx)
+
+	statusCode := func() int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+		return w.Code
+	}
+
+	// The auth guest rejects requests without an Authorization header.
+	if have := statusCode(); have != http.StatusUnauthorized {
+		t.Fatalf("expected status %d, have %d", http.StatusUnauthorized, have)
+	}
+
+	// A guest that doesn't compile is not swapped in.
+	if err = os.WriteFile(path, []byte("bad"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = awaitReload(t, reloaded); err == nil {
+		t.Fatal("expected reload error")
+	}
+	if have := statusCode(); have != http.StatusUnauthorized {
+		t.Fatalf("expected status %d, have %d", http.StatusUnauthorized, have)
+	}
+
+	// The existing handler uses the new guest after a successful reload.
+	if err = os.WriteFile(path, test.ConfigWasm, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err = awaitReload(t, reloaded); err != nil {
+		t.Fatal(err)
+	}
+	if have := statusCode(); have != http.StatusOK {
+		t.Fatalf("expected status %d, have %d", http.StatusOK, have)
+	}
+}
+
+func awaitReload(t *testing.T, reloaded <-chan error) error {
+	select {
+	case err := <-reloaded:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for reload")
+		return nil
+	}
+}
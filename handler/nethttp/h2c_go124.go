@@ -0,0 +1,25 @@
+//go:build go1.24
+
+package wasm
+
+import "net/http"
+
+// EnableH2C configures the server to accept HTTP/2 without TLS (h2c), such
+// as from a proxy that terminates TLS, in addition to the protocols it
+// already accepts. Guests see the protocol version "HTTP/2.0" via
+// handler.FuncGetProtocolVersion.
+//
+// This requires Go 1.24. Otherwise, it returns an error, so instead wrap the
+// handler with golang.org/x/net/http2/h2c:
+//
+//	err = http.ListenAndServe(":8080", h2c.NewHandler(h, &http2.Server{}))
+func EnableH2C(s *http.Server) error {
+	if s.Protocols == nil {
+		// The default protocols of a server.
+		s.Protocols = new(http.Protocols)
+		s.Protocols.SetHTTP1(true)
+		s.Protocols.SetHTTP2(true)
+	}
+	s.Protocols.SetUnencryptedHTTP2(true)
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build !go1.24
+
+package wasm
+
+import (
+	"errors"
+	"net/http"
+)
+
+// EnableH2C returns an error, as http.Server Protocols requires Go 1.24.
+// Instead, wrap the handler with golang.org/x/net/http2/h2c.
+func EnableH2C(*http.Server) error {
+	return errors.New("wasm: h2c requires Go 1.24, or golang.org/x/net/http2/h2c")
+}
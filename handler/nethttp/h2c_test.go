@@ -0,0 +1,70 @@
+//go:build go1.24
+
+package wasm
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestEnableH2C(t *testing.T) {
+	mw, err := NewMiddleware(testCtx, test.ProtocolWasm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	// The next handler uses http.ResponseController, which must reach the
+	// underlying writer through the guest.
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(time.Now().Add(time.Minute)); err != nil {
+			t.Error(err)
+		}
+		w.Write([]byte("hello")) // nolint
+		if err := rc.Flush(); err != nil {
+			t.Error(err)
+		}
+	})
+	h, err := mw.NewHandler(testCtx, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close(testCtx)
+
+	ts := httptest.NewUnstartedServer(h)
+	if err = EnableH2C(ts.Config); err != nil {
+		t.Fatal(err)
+	}
+	ts.Start()
+	defer ts.Close()
+
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: protocols}}
+	defer client.CloseIdleConnections()
+
+	resp, err := client.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ProtoMajor != 2 {
+		t.Errorf("expected HTTP/2, have %s", resp.Proto)
+	}
+	if v := resp.Header.Get("X-Protocol"); v != "HTTP/2.0" {
+		t.Errorf("expected protocol %q, have %q", "HTTP/2.0", v)
+	}
+	if string(body) != "hello" {
+		t.Errorf("expected body %q, have %q", "hello", body)
+	}
+}
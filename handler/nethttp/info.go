@@ -0,0 +1,25 @@
+package wasm
+
+import (
+	"context"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// FromContext returns the guest handling the current request, or nil if the
+// context isn't of a request handled by a guest. For a chain, this is the
+// guest currently handling it, or the last one when in the next handler.
+//
+// This can be used by the next handler, or a logger configured with
+// httpwasm.Logger, to attribute behavior to a specific build of a guest.
+func FromContext(ctx context.Context) *handler.GuestInfo {
+	s, ok := ctx.Value(requestStateKey{}).(*requestState)
+	if !ok || len(s.guests) == 0 {
+		return nil
+	}
+	i := s.position
+	if i >= len(s.guests) {
+		i = len(s.guests) - 1 // in the next handler
+	}
+	return s.guests[i].Info()
+}
@@ -0,0 +1,48 @@
+package wasm
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestFromContext(t *testing.T) {
+	digest := func(guest []byte) string {
+		return fmt.Sprintf("sha256:%x", sha256.Sum256(guest))
+	}
+
+	var logged []handler.GuestInfo
+	logger := httpwasm.Logger(func(ctx context.Context, _ string) {
+		logged = append(logged, *FromContext(ctx))
+	})
+	mw, err := NewMiddlewareChain(testCtx, [][]byte{test.LogWasm, test.PropertyWasm}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	var next *handler.GuestInfo
+	serve(t, mw, http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		next = FromContext(r.Context())
+	}), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	expected := handler.GuestInfo{Module: "log", Instance: 1, Digest: digest(test.LogWasm)}
+	if len(logged) != 2 || logged[0] != expected || logged[1] != expected {
+		t.Fatalf("expected the log guest in its logs, have %+v", logged)
+	}
+	expected = handler.GuestInfo{Module: "property", Instance: 1, Digest: digest(test.PropertyWasm)}
+	if next == nil || *next != expected {
+		t.Fatalf("expected the last guest in the next handler, have %+v", next)
+	}
+
+	if info := FromContext(testCtx); info != nil {
+		t.Fatalf("expected no guest outside a request, have %+v", info)
+	}
+}
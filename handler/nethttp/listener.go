@@ -0,0 +1,251 @@
+package wasm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	// maxProxyV1Header is the longest PROXY protocol version 1 header,
+	// including the CRLF.
+	maxProxyV1Header = 107
+	// proxyV2Signature starts a PROXY protocol version 2 header.
+	proxyV2Signature = "\r\n\r\n\x00\r\nQUIT\n"
+)
+
+// proxyConns are connections accepted by listeners from ProxyProtocol, by
+// rawConnKey. proxyListeners counts such listeners, so that requests skip the
+// lookup when there are none.
+var (
+	proxyConns     sync.Map
+	proxyListeners int32
+)
+
+// ProxyProtocol returns a listener which reads the PROXY protocol header,
+// version 1 or 2, that a load balancer such as HAProxy or AWS NLB sends
+// before the requests of each connection, for
+// handler.FuncGetProxySourceAddr. The remote address of connections remains
+// that of the load balancer, which is what handler.FuncGetSourceAddr returns.
+//
+// For example:
+//
+//	l, err := net.Listen("tcp", ":8080")
+//	...
+//	err = http.Serve(wasm.ProxyProtocol(l), mw)
+//
+// Connections without a valid header are closed, as otherwise clients that
+// connect directly could forge the source. To preserve header case as well,
+// wrap this listener with PreserveHeaderCase.
+//
+// Note: Only TCP listeners are supported, as the remote addresses of unix
+// domain sockets don't identify connections.
+func ProxyProtocol(l net.Listener) net.Listener {
+	atomic.AddInt32(&proxyListeners, 1)
+	return &proxyListener{Listener: l}
+}
+
+type proxyListener struct {
+	net.Listener
+	closed int32
+}
+
+// Accept implements the same method as documented on net.Listener.
+func (l *proxyListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	// The header is read on the first read, so that a slow client doesn't
+	// block accepting others.
+	pc := &proxyConn{Conn: c, br: bufio.NewReader(c)}
+	if c.LocalAddr().Network() != "unix" {
+		pc.key = rawConnKey(c.LocalAddr(), c.RemoteAddr().String())
+		proxyConns.Store(pc.key, pc)
+	}
+	return pc, nil
+}
+
+// Close implements the same method as documented on net.Listener.
+func (l *proxyListener) Close() error {
+	if atomic.CompareAndSwapInt32(&l.closed, 0, 1) {
+		atomic.AddInt32(&proxyListeners, -1)
+	}
+	return l.Listener.Close()
+}
+
+// proxyConn strips the PROXY protocol header from the connection.
+type proxyConn struct {
+	net.Conn
+	key        string
+	br         *bufio.Reader
+	headerOnce sync.Once
+	closeOnce  sync.Once
+
+	mu     sync.Mutex
+	source string
+	err    error
+}
+
+// Read implements the same method as documented on net.Conn.
+func (c *proxyConn) Read(b []byte) (int, error) {
+	c.headerOnce.Do(func() {
+		source, err := readProxyHeader(c.br)
+		c.mu.Lock()
+		c.source, c.err = source, err
+		c.mu.Unlock()
+	})
+	c.mu.Lock()
+	err := c.err
+	c.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	return c.br.Read(b)
+}
+
+// Close implements the same method as documented on net.Conn.
+func (c *proxyConn) Close() error {
+	c.closeOnce.Do(func() {
+		if c.key != "" {
+			proxyConns.Delete(c.key)
+		}
+	})
+	return c.Conn.Close()
+}
+
+// sourceAddr returns the source address of the header, or empty if it had
+// none or wasn't read yet.
+func (c *proxyConn) sourceAddr() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.source
+}
+
+// readProxyHeader reads a PROXY protocol header, returning its source
+// address, or empty if it has none, such as for health checks of the load
+// balancer.
+func readProxyHeader(br *bufio.Reader) (string, error) {
+	if sig, _ := br.Peek(len(proxyV2Signature)); string(sig) == proxyV2Signature {
+		return readProxyV2Header(br)
+	}
+	if prefix, _ := br.Peek(6); string(prefix) != "PROXY " {
+		return "", errors.New("wasm: missing PROXY protocol header")
+	}
+	var line []byte
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		} else if len(line) == maxProxyV1Header {
+			return "", errors.New("wasm: PROXY protocol header too long")
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return "", errors.New("wasm: invalid PROXY protocol header")
+	}
+	fields := strings.Split(string(line[:len(line)-2]), " ")
+	switch {
+	case len(fields) >= 2 && fields[1] == "UNKNOWN":
+		return "", nil
+	case len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6"):
+		return "", errors.New("wasm: invalid PROXY protocol header")
+	}
+	ip := net.ParseIP(fields[2])
+	port, err := strconv.ParseUint(fields[4], 10, 16)
+	if ip == nil || err != nil {
+		return "", errors.New("wasm: invalid PROXY protocol source")
+	}
+	return net.JoinHostPort(ip.String(), strconv.FormatUint(port, 10)), nil
+}
+
+// readProxyV2Header reads a PROXY protocol version 2 header, which starts
+// with proxyV2Signature.
+func readProxyV2Header(br *bufio.Reader) (string, error) {
+	var header [16]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		return "", err
+	}
+	if header[12]>>4 != 2 {
+		return "", errors.New("wasm: unsupported PROXY protocol version")
+	}
+	addrs := make([]byte, binary.BigEndian.Uint16(header[14:]))
+	if _, err := io.ReadFull(br, addrs); err != nil {
+		return "", err
+	}
+	if header[12]&0xf == 0 {
+		return "", nil // LOCAL, such as a health check
+	}
+	// The address family is the high nibble. Others, such as AF_UNIX, have
+	// no source address to return.
+	var ipLen int
+	switch header[13] >> 4 {
+	case 1: // AF_INET
+		ipLen = net.IPv4len
+	case 2: // AF_INET6
+		ipLen = net.IPv6len
+	default:
+		return "", nil
+	}
+	if len(addrs) < 2*ipLen+4 {
+		return "", errors.New("wasm: invalid PROXY protocol addresses")
+	}
+	ip := net.IP(addrs[:ipLen])
+	port := binary.BigEndian.Uint16(addrs[2*ipLen:])
+	return net.JoinHostPort(ip.String(), strconv.Itoa(int(port))), nil
+}
+
+// localAddr returns the local address the request was received on, or nil
+// if unknown.
+func localAddr(r *http.Request) net.Addr {
+	addr, _ := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	return addr
+}
+
+// GetListenerAddr implements the same method as documented on
+// handler.ConnectionInfo.
+func (h host) GetListenerAddr(ctx context.Context) string {
+	if addr := localAddr(requestStateFromContext(ctx).request); addr != nil {
+		return addr.String()
+	}
+	return ""
+}
+
+// IsUnixSocket implements the same method as documented on
+// handler.ConnectionInfo.
+func (h host) IsUnixSocket(ctx context.Context) bool {
+	addr := localAddr(requestStateFromContext(ctx).request)
+	return addr != nil && addr.Network() == "unix"
+}
+
+// GetProxySourceAddr implements the same method as documented on
+// handler.ConnectionInfo.
+func (h host) GetProxySourceAddr(ctx context.Context) (string, bool) {
+	if atomic.LoadInt32(&proxyListeners) == 0 {
+		return "", false
+	}
+	r := requestStateFromContext(ctx).request
+	local := localAddr(r)
+	if local == nil {
+		return "", false
+	}
+	c, ok := proxyConns.Load(rawConnKey(local, r.RemoteAddr))
+	if !ok {
+		return "", false
+	}
+	source := c.(*proxyConn).sourceAddr()
+	return source, source != ""
+}
@@ -0,0 +1,179 @@
+package wasm
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestReadProxyHeader(t *testing.T) {
+	tests := []struct {
+		name           string
+		header         string
+		expectedSource string
+		expectedErr    string
+	}{
+		{
+			name:           "v1 tcp4",
+			header:         "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n",
+			expectedSource: "192.0.2.1:56324",
+		},
+		{
+			name:           "v1 tcp6",
+			header:         "PROXY TCP6 2001:db8::1 2001:db8::2 56324 443\r\n",
+			expectedSource: "[2001:db8::1]:56324",
+		},
+		{
+			name:   "v1 unknown",
+			header: "PROXY UNKNOWN\r\n",
+		},
+		{
+			name:        "v1 invalid source",
+			header:      "PROXY TCP4 example.com 198.51.100.1 56324 443\r\n",
+			expectedErr: "wasm: invalid PROXY protocol source",
+		},
+		{
+			name:        "v1 missing CR",
+			header:      "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\n",
+			expectedErr: "wasm: invalid PROXY protocol header",
+		},
+		{
+			name:        "v1 too long",
+			header:      "PROXY " + strings.Repeat("A", maxProxyV1Header) + "\r\n",
+			expectedErr: "wasm: PROXY protocol header too long",
+		},
+		{
+			name:           "v2 tcp4",
+			header:         proxyV2Header(0x21, 0x11, []byte{192, 0, 2, 1, 198, 51, 100, 1, 0xdc, 0x04, 0x01, 0xbb}),
+			expectedSource: "192.0.2.1:56324",
+		},
+		{
+			name:   "v2 local",
+			header: proxyV2Header(0x20, 0x00, nil),
+		},
+		{
+			name:        "v2 short addresses",
+			header:      proxyV2Header(0x21, 0x11, []byte{192, 0, 2, 1}),
+			expectedErr: "wasm: invalid PROXY protocol addresses",
+		},
+		{
+			name:        "missing",
+			header:      "GET / HTTP/1.1\r\n",
+			expectedErr: "wasm: missing PROXY protocol header",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			br := bufio.NewReader(strings.NewReader(tc.header + "GET / HTTP/1.1\r\n"))
+			source, err := readProxyHeader(br)
+			if tc.expectedErr != "" {
+				if err == nil || err.Error() != tc.expectedErr {
+					t.Fatalf("expected error %q, have %v", tc.expectedErr, err)
+				}
+				return
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if source != tc.expectedSource {
+				t.Errorf("expected source %q, have %q", tc.expectedSource, source)
+			}
+			// The request after the header is unread.
+			if rest, _ := io.ReadAll(br); string(rest) != "GET / HTTP/1.1\r\n" {
+				t.Errorf("unexpected bytes after header %q", rest)
+			}
+		})
+	}
+}
+
+// proxyV2Header encodes a PROXY protocol version 2 header.
+func proxyV2Header(verCmd, fam byte, addrs []byte) string {
+	b := append([]byte(proxyV2Signature), verCmd, fam, 0, 0)
+	binary.BigEndian.PutUint16(b[14:], uint16(len(addrs)))
+	return string(append(b, addrs...))
+}
+
+func TestListenerMetadata(t *testing.T) {
+	mw, err := NewMiddleware(testCtx, test.ListenerWasm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	h, err := mw.NewHandler(testCtx, noopHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close(testCtx)
+
+	t.Run("tcp", func(t *testing.T) {
+		s := httptest.NewServer(h)
+		defer s.Close()
+
+		addr := s.Listener.Addr().String()
+		if have := listenerRoundTrip(t, "tcp", addr, ""); have != addr+",tcp," {
+			t.Errorf("unexpected response %q", have)
+		}
+	})
+
+	t.Run("proxy protocol", func(t *testing.T) {
+		s := httptest.NewUnstartedServer(h)
+		s.Listener = ProxyProtocol(s.Listener)
+		s.Start()
+		defer s.Close()
+
+		addr := s.Listener.Addr().String()
+		have := listenerRoundTrip(t, "tcp", addr, "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n")
+		if have != addr+",tcp,192.0.2.1:56324" {
+			t.Errorf("unexpected response %q", have)
+		}
+	})
+
+	t.Run("unix", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "http.sock")
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			t.Skip("unix domain sockets aren't supported:", err)
+		}
+		s := &httptest.Server{Listener: l, Config: &http.Server{Handler: h}}
+		s.Start()
+		defer s.Close()
+
+		if have := listenerRoundTrip(t, "unix", path, ""); have != path+",unix," {
+			t.Errorf("unexpected response %q", have)
+		}
+	})
+}
+
+// listenerRoundTrip sends a GET request on a new connection after the
+// prefix, returning the response body.
+func listenerRoundTrip(t *testing.T, network, addr, prefix string) string {
+	c, err := net.Dial(network, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if _, err = io.WriteString(c, prefix+"GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(c), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
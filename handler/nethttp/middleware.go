@@ -2,16 +2,35 @@ package wasm
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net/http"
+	"os"
+	"sync"
+	"time"
 
 	httpwasm "github.com/http-wasm/http-wasm-host-go"
 	"github.com/http-wasm/http-wasm-host-go/api"
 	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	"github.com/http-wasm/http-wasm-host-go/internal"
 	internalhandler "github.com/http-wasm/http-wasm-host-go/internal/handler"
 )
 
 // Handler is a http.Handler implemented by a Wasm module. `ServeHTTP` is
 // dispatched to handler.FuncHandle, which is exported by the guest.
+//
+// Handlers work with any server of http.Handler, including HTTP/2 without
+// TLS (h2c), via EnableH2C, and HTTP/3, such as that of quic-go:
+//
+//	h, err := mw.NewHandler(ctx, next)
+//	...
+//	err = http3.ListenAndServeQUIC(":443", certFile, keyFile, h)
+//
+// The http.ResponseWriter passed to the next handler supports flushing and
+// http.ResponseController, which can reach features of a specific server,
+// such as the HTTP/3 stream for datagrams, via its Unwrap method. A guest
+// which buffers the response, with handler.FeatureBufferResponse, defers
+// flushing until it returns.
 type Handler interface {
 	http.Handler
 	api.Closer
@@ -19,8 +38,46 @@ type Handler interface {
 
 type Middleware handler.Middleware[http.Handler, Handler]
 
+// compile-time check to ensure middleware implements the optional interfaces.
+var (
+	_ handler.CustomSectionReader = &middleware{}
+	_ handler.CompileReporter     = &middleware{}
+	_ handler.GuestMetadataReader = &middleware{}
+	_ handler.CanaryRollout       = &middleware{}
+	_ handler.ConfigUpdater       = &middleware{}
+	_ handler.Pinger              = &middleware{}
+	_ handler.GracefulCloser      = &middleware{}
+)
+
 type middleware struct {
-	runtime *internalhandler.Runtime
+	// mu guards runtime and generation, which change when the guest is
+	// reloaded. Requests hold the read lock only while selecting their guest,
+	// and the runtime counts them, so that a reload doesn't wait for them.
+	// The previous runtime is closed once they complete.
+	mu      sync.RWMutex
+	runtime *runtimeRef
+	// generation increments each time runtime is replaced.
+	generation uint64
+	closed     bool
+	// watcher is non-nil when the guest was loaded from a file.
+	watcher io.Closer
+
+	// options compile the canary, if any.
+	options []httpwasm.Option
+	// canary is non-nil during a rollout started by SetCanary, guarded by mu.
+	// canaryGeneration increments each time it is replaced.
+	canary           *runtimeRef
+	canaryPercent    int
+	canaryGeneration uint64
+	// stats is replaced by SetCanary, so requests in flight count to the
+	// rollout they were selected in.
+	stats *canaryStats
+
+	// configMu serializes UpdateConfig, which holds the read lock of mu.
+	// config is nil unless UpdateConfig was called. It is applied to guests
+	// reloaded or rolled out as a canary, which hold the write lock of mu.
+	configMu sync.Mutex
+	config   []byte
 }
 
 func NewMiddleware(ctx context.Context, guest []byte, options ...httpwasm.Option) (Middleware, error) {
@@ -28,7 +85,29 @@ func NewMiddleware(ctx context.Context, guest []byte, options ...httpwasm.Option
 	if err != nil {
 		return nil, err
 	}
-	return &middleware{runtime: r}, nil
+	return &middleware{runtime: newRuntimeRef(r), options: options, stats: &canaryStats{}}, nil
+}
+
+// swapRuntime replaces the runtime, and closes the previous one once requests
+// in flight complete. Handlers instantiate a new guest on their next request.
+func (w *middleware) swapRuntime(ctx context.Context, r *internalhandler.Runtime) error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return r.Close(ctx)
+	}
+	if w.config != nil {
+		if err := r.UpdateConfig(w.config); err != nil {
+			w.mu.Unlock()
+			_ = r.Close(ctx)
+			return err
+		}
+	}
+	w.runtime.retire(ctx)
+	w.runtime = newRuntimeRef(r)
+	w.generation++
+	w.mu.Unlock()
+	return nil
 }
 
 type host struct{}
@@ -37,18 +116,116 @@ type host struct{}
 // pointer to the current request.
 type requestStateKey struct{}
 
+// requestState is the state of the current request. This implements
+// context.Context, returning itself as the Value of requestStateKey, to avoid
+// allocating a context per request.
 type requestState struct {
-	request    *http.Request
-	response   http.ResponseWriter
-	handleNext func()
+	context.Context
+	request  *http.Request
+	response *responseWriter
+	next     http.Handler
+	// guests are the guests handling the request, where position is the
+	// index of the current one. There is more than one for a chain.
+	guests   []*internalhandler.Guest
+	position int
+	// requestBody is non-nil when the request body was read into memory.
+	requestBody []byte
+	// spill is non-nil when the request body was too large to read into
+	// memory, so was written to this temporary file instead.
+	spill *os.File
+	// spillSize is the size of the request body in spill.
+	spillSize int64
+	// bodyLimits are of the guest handling the request, or the first of a
+	// chain.
+	bodyLimits internal.BodyLimits
+	// scratch is shared by all guests handling the request.
+	scratch []byte
+	// multipart is non-nil when the guest read a multipart part.
+	multipart *multipartState
+	// properties are lazily initialized by SetProperty.
+	properties map[string]string
+	// hostValues are set by guests via handler.FuncSetHostValue.
+	hostValues internal.HostValues
+	// features are enabled by guests via handler.FuncEnableFeatures.
+	features handler.Features
+	// upstream is set by guests via handler.FuncSetUpstream.
+	upstream string
+	// timeout, if positive, is the deadline of the next handler, set by
+	// guests via handler.FuncSetTimeoutMs.
+	timeout time.Duration
+	// retryPolicy is set by guests via handler.FuncSetRetryPolicy.
+	retryPolicy handler.RetryPolicy
+	// requestID is assigned when httpwasm.RequestID is set.
+	requestID string
+	// route is the pattern the next handler matched, if it is an
+	// http.ServeMux.
+	route string
+	// rawHeaders are the request headers as received, if preserved by a
+	// listener from PreserveHeaderCase.
+	rawHeaders []handler.HeaderField
+	// buffers are the pooled buffers of the request, until released.
+	buffers *stateBuffers
+}
+
+// withRequestState returns a context with the state of the request, which
+// must be released when the request completes.
+func withRequestState(ctx context.Context, response http.ResponseWriter, request *http.Request, next http.Handler, guests ...*internalhandler.Guest) (context.Context, *requestState) {
+	s := newRequestState(response, request)
+	s.Context, s.next = ctx, next
+	s.guests = append(s.guests, guests...)
+	return s, s
 }
 
-func withRequestState(ctx context.Context, response http.ResponseWriter, request *http.Request, next http.Handler) context.Context {
-	return context.WithValue(ctx, requestStateKey{}, &requestState{
-		request:    request,
-		response:   response,
-		handleNext: func() { next.ServeHTTP(response, request) },
-	})
+// Value implements the same method as documented on context.Context.
+func (s *requestState) Value(key interface{}) interface{} {
+	switch key {
+	case requestStateKey{}:
+		return s
+	case internal.HostValuesKey{}:
+		return &s.hostValues
+	case requestIDKey{}:
+		return s.requestID
+	case rawHeadersKey{}:
+		return s.rawHeaders
+	}
+	return s.Context.Value(key)
+}
+
+// handleNext invokes the next guest in the chain, if any, or otherwise the
+// next handler.
+func (s *requestState) handleNext() {
+	// Restore the position when the next guest returns, so that it reflects
+	// the guest which invoked it, such as for FromContext.
+	defer func(position int) { s.position = position }(s.position)
+
+	if s.position++; s.position < len(s.guests) {
+		// A handler.GuestError was already handled, so only propagate
+		// other errors to the calling guest.
+		if err := s.guests[s.position].Handle(s); err != nil && !isGuestError(err) {
+			panic(err)
+		}
+		return
+	}
+	if s.timeout <= 0 {
+		s.serveNext(s)
+		return
+	}
+	ctx, cancel := context.WithTimeout(s, s.timeout)
+	defer cancel()
+	s.serveNext(ctx)
+}
+
+// serveNext invokes the next handler, recording the route it matched, if any.
+func (s *requestState) serveNext(ctx context.Context) {
+	r := s.nextRequest(ctx)
+	s.next.ServeHTTP(s.response, r)
+	s.route = requestPattern(r)
+}
+
+// nextRequest returns the request to pass to the next handler, whose context
+// allows reading Properties.
+func (s *requestState) nextRequest(ctx context.Context) *http.Request {
+	return s.request.WithContext(ctx)
 }
 
 func requestStateFromContext(ctx context.Context) *requestState {
@@ -66,9 +243,43 @@ func (h host) GetRequestHeader(ctx context.Context, name string) (string, bool)
 	}
 }
 
+// GetRequestHeaders implements the same method as documented on
+// handler.Host.
+func (h host) GetRequestHeaders(ctx context.Context) map[string][]string {
+	return requestStateFromContext(ctx).request.Header
+}
+
+// GetQueryParams implements the same method as documented on handler.Host.
+func (h host) GetQueryParams(ctx context.Context) map[string][]string {
+	return requestStateFromContext(ctx).request.URL.Query()
+}
+
+// SetQueryValue implements the same method as documented on handler.Host.
+func (h host) SetQueryValue(ctx context.Context, name, value string) {
+	r := requestStateFromContext(ctx).request
+	q := r.URL.Query()
+	q.Set(name, value)
+	r.URL.RawQuery = q.Encode()
+}
+
+// ReadScratch implements the same method as documented on handler.Scratch.
+func (h host) ReadScratch(ctx context.Context) []byte {
+	return requestStateFromContext(ctx).scratch
+}
+
+// WriteScratch implements the same method as documented on handler.Scratch.
+func (h host) WriteScratch(ctx context.Context, data []byte) {
+	s := requestStateFromContext(ctx)
+	s.scratch = append(s.scratch[:0], data...)
+}
+
 // Next implements the same method as documented on handler.Host.
 func (h host) Next(ctx context.Context) {
-	requestStateFromContext(ctx).handleNext()
+	s := requestStateFromContext(ctx)
+	if s.response.committed || s.response.written {
+		return // the guest already sent a response
+	}
+	s.handleNext()
 }
 
 // SetResponseHeader implements the same method as documented on handler.Host.
@@ -80,48 +291,299 @@ func (h host) SetResponseHeader(ctx context.Context, name, value string) {
 // SendResponse implements the same method as documented on handler.Host.
 func (h host) SendResponse(ctx context.Context, statusCode uint32, body []byte) {
 	r := requestStateFromContext(ctx).response
+	if r.buffering {
+		r.reset() // replace the response of the next handler
+	}
 	r.WriteHeader(int(statusCode))
 	if body != nil {
 		r.Write(body) // nolint
 	}
 }
 
-// NewHandler implements the same method as documented on handler.Middleware.
+// NewHandler implements the same method as documented on handler.ConfigUpdater.
 func (w *middleware) NewHandler(ctx context.Context, next http.Handler) (Handler, error) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
 	g, err := w.runtime.NewGuest(ctx)
 	if err != nil {
-		return nil, err
+		if !w.runtime.FailOpen(ctx, err) {
+			return nil, err
+		}
+		// Retry instantiating the guest on each request until it succeeds.
+		return &guest{m: w, generation: w.generation, next: next}, nil
+	}
+
+	return &guest{m: w, guest: g, generation: w.generation, next: next}, nil
+}
+
+// unavailable handles a request whose guest can't be instantiated, according
+// to the failure policy of the runtime.
+func unavailable(r *runtimeRef, response http.ResponseWriter, request *http.Request, next http.Handler, err error) {
+	if r.FailOpen(request.Context(), err) {
+		next.ServeHTTP(response, request)
+		return
 	}
+	response.WriteHeader(http.StatusServiceUnavailable)
+}
+
+// CustomSection implements the same method as documented on
+// handler.CustomSectionReader.
+func (w *middleware) CustomSection(name string) ([]byte, bool) {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.runtime.CustomSection(name)
+}
+
+// GuestMetadata implements the same method as documented on
+// handler.GuestMetadataReader. This is the metadata of the current guest, if
+// reloaded.
+func (w *middleware) GuestMetadata() handler.GuestMetadata {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.runtime.GuestMetadata()
+}
+
+// CompileReport implements the same method as documented on
+// handler.CompileReporter. This is the report of the current guest, if reloaded.
+func (w *middleware) CompileReport() api.CompileReport {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.runtime.CompileReport()
+}
+
+// Ping implements the same method as documented on handler.Pinger.
+func (w *middleware) Ping(ctx context.Context) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.runtime.Ping(ctx)
+}
 
-	return &guest{guest: g, next: next}, nil
+// UpdateConfig implements the same method as documented on
+// handler.ConfigUpdater.
+//
+// This only holds the read lock, so that requests in flight aren't waited
+// for. They apply the update on their next request.
+func (w *middleware) UpdateConfig(config []byte) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	w.configMu.Lock()
+	defer w.configMu.Unlock()
+	if err := w.runtime.UpdateConfig(config); err != nil {
+		return err
+	}
+	if w.canary != nil {
+		if err := w.canary.UpdateConfig(config); err != nil {
+			return err
+		}
+	}
+	w.config = append([]byte{}, config...)
+	return nil
 }
 
-// Close implements the same method as documented on handler.Middleware.
+// Close implements the same method as documented on handler.ConfigUpdater.
 func (w *middleware) Close(ctx context.Context) error {
-	return w.runtime.Close(ctx)
+	if w.watcher != nil {
+		_ = w.watcher.Close()
+	}
+	r, canary := w.retire(ctx)
+	if r == nil {
+		return nil // already closed
+	}
+	if canary != nil {
+		<-canary.done
+	}
+	<-r.done
+	return persist(r)
+}
+
+// persist snapshots the stores of the closed runtime, if
+// httpwasm.PersistStores was set, returning the error closing it, if any.
+func persist(r *runtimeRef) error {
+	err := r.PersistStores()
+	if r.err != nil {
+		return r.err
+	}
+	return err
+}
+
+// retire marks the middleware closed, and retires its runtime and canary, so
+// that they are closed once requests in flight complete. This returns them,
+// or nil if the middleware was already closed.
+func (w *middleware) retire(ctx context.Context) (r, canary *runtimeRef) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return nil, nil
+	}
+	w.closed = true
+	r, canary = w.runtime, w.canary
+	w.closeCanary(ctx)
+	r.retire(ctx)
+	return
+}
+
+// CloseGracefully implements the same method as documented on
+// handler.GracefulCloser.
+//
+// The runtime is closed once requests in flight complete, or from under them
+// after the deadline. New requests fail once it is closed.
+func (w *middleware) CloseGracefully(ctx context.Context, timeout time.Duration) error {
+	if w.watcher != nil {
+		_ = w.watcher.Close()
+	}
+	r, canary := w.retire(ctx)
+	if r == nil {
+		return nil // already closed
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-r.done:
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	if canary != nil {
+		canary.close(ctx)
+	}
+	r.close(ctx)
+	return persist(r)
 }
 
 // compile-time check to ensure guest implements Handler.
 var _ Handler = &guest{}
 
 type guest struct {
-	guest *internalhandler.Guest
-	next  http.Handler
+	m *middleware
+
+	// mu guards guest and generation, which change when the middleware
+	// reloaded its runtime. guest is nil when it failed to instantiate and
+	// the failure policy is api.FailOpen.
+	mu         sync.Mutex
+	guest      *internalhandler.Guest
+	generation uint64
+	// canary and canaryGeneration are like guest and generation, except of
+	// the canary of the middleware.
+	canary           *internalhandler.Guest
+	canaryGeneration uint64
+
+	next http.Handler
 }
 
 // ServeHTTP implements http.Handler
 func (w *guest) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	r, g, id, canary, stats, err := w.selectGuest(request)
+	defer r.release()
+	if err != nil {
+		unavailable(r, response, request, w.next, err)
+		return
+	}
+
 	// The guest Wasm actually handles the request. As it may call host
 	// functions, we add context parameters of the current request.
-	ctx := withRequestState(request.Context(), response, request, w.next)
-	if err := w.guest.Handle(ctx); err != nil {
-		// TODO: after testing, shouldn't send errors into the HTTP response.
-		response.Write([]byte(err.Error())) // nolint
-		response.WriteHeader(500)
+	ctx, s := withRequestState(request.Context(), response, request, w.next, g)
+	defer s.release()
+	s.setRequestID(id)
+	s.setBodyLimits(r.BodyLimits())
+	s.rawHeaders = rawRequestHeaders(request)
+	err = g.Handle(ctx)
+	if stats != nil && err != handler.ErrGuestInUse {
+		stats.record(canary, err != nil)
+	}
+	if err != nil && !isGuestError(err) {
+		serveError(response, err)
+		return
 	}
+	s.response.commit()
+}
+
+// selectGuest returns the guest to handle the request, and the runtime it was
+// instantiated from, which the caller must release. stats is non-nil during a
+// canary rollout. This holds the read lock of the middleware only while
+// selecting, so that a reload doesn't wait for requests in flight.
+func (w *guest) selectGuest(request *http.Request) (
+	r *runtimeRef, g *internalhandler.Guest, id string, canary bool, stats *canaryStats, err error) {
+	w.m.mu.RLock()
+	defer w.m.mu.RUnlock()
+
+	id = w.m.runtime.RequestID(request.Header)
+	if canary = w.m.useCanary(request, id); canary {
+		r = w.m.canary.acquire()
+		g, err = w.currentCanary(request.Context())
+	} else {
+		r = w.m.runtime.acquire()
+		g, err = w.current(request.Context())
+	}
+	if w.m.canary != nil {
+		stats = w.m.stats
+	}
+	return
+}
+
+// selectCurrent is like selectGuest, except it doesn't consider a canary,
+// such as for a guest of a chain.
+func (w *guest) selectCurrent(ctx context.Context) (r *runtimeRef, g *internalhandler.Guest, err error) {
+	w.m.mu.RLock()
+	defer w.m.mu.RUnlock()
+
+	r = w.m.runtime.acquire()
+	g, err = w.current(ctx)
+	return
+}
+
+// current returns the guest instantiated from the current runtime of the
+// middleware, replacing the guest if the runtime was reloaded. This must be
+// called with the middleware read lock held.
+func (w *guest) current(ctx context.Context) (*internalhandler.Guest, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.generation == w.m.generation && w.guest != nil {
+		return w.guest, nil
+	}
+
+	// The previous guest, if any, was closed with its runtime.
+	g, err := w.m.runtime.NewGuest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	w.guest, w.generation = g, w.m.generation
+	return g, nil
 }
 
 // Close implements api.Closer
 func (w *guest) Close(ctx context.Context) error {
+	w.m.mu.RLock()
+	defer w.m.mu.RUnlock()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.canaryGeneration == w.m.canaryGeneration && w.canary != nil {
+		_ = w.canary.Close(ctx)
+	}
+	if w.generation != w.m.generation || w.guest == nil {
+		return nil // already closed with its runtime, or never instantiated.
+	}
 	return w.guest.Close(ctx)
 }
+
+// isGuestError returns true if the error is a handler.GuestError, which the
+// guest handler responded to according to configuration.
+func isGuestError(err error) bool {
+	var guestErr *handler.GuestError
+	return errors.As(err, &guestErr)
+}
+
+// serveError responds to an error handling a request which isn't a
+// handler.GuestError, as the guest runtime already responded to those. The
+// error isn't written to the response, as it may reveal host internals.
+func serveError(response http.ResponseWriter, err error) {
+	if err == handler.ErrGuestInUse {
+		// Handlers aren't safe for concurrent use, but the guest wasn't
+		// harmed, so reject the request as if it were overloaded.
+		response.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	response.WriteHeader(http.StatusInternalServerError)
+}
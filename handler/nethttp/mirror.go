@@ -0,0 +1,65 @@
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// mirrorClient sends mirrored requests. The timeout prevents a slow mirror
+// from holding a slot of mirrorSlots for long.
+var mirrorClient = &http.Client{Timeout: 30 * time.Second}
+
+// mirrorSlots limits how many mirrored requests are in flight. When all are
+// in use, such as when a mirror is slow, further requests aren't mirrored,
+// instead of accumulating goroutines.
+var mirrorSlots = make(chan struct{}, 64)
+
+// MirrorRequest implements the same method as documented on handler.Proxy.
+func (h host) MirrorRequest(ctx context.Context, destination string) {
+	s := requestStateFromContext(ctx)
+	body := s.requestBodyBytes()
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		panic(err)
+	}
+	r := s.request
+	// Append the path as is, as cleaning it would change what the mirror
+	// receives, such as removing a trailing slash.
+	base, rawBase := strings.TrimSuffix(u.Path, "/"), strings.TrimSuffix(u.EscapedPath(), "/")
+	u.Path, u.RawPath = base+r.URL.Path, rawBase+r.URL.EscapedPath()
+	u.RawQuery = r.URL.RawQuery
+
+	// The mirrored request outlives the current one, so it isn't canceled by
+	// the client of the current request disconnecting.
+	mirror, err := http.NewRequestWithContext(context.Background(), r.Method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		panic(err)
+	}
+	mirror.Header = r.Header.Clone()
+	// Avoid the mirror rejecting the request due to a stale content length or
+	// hop-by-hop header.
+	mirror.Header.Del("Content-Length")
+	mirror.Header.Del("Connection")
+
+	slots := mirrorSlots
+	select {
+	case slots <- struct{}{}:
+	default:
+		return // mirroring is best effort
+	}
+	go func() {
+		defer func() { <-slots }()
+		resp, err := mirrorClient.Do(mirror)
+		if err != nil {
+			return // mirroring is best effort
+		}
+		io.Copy(io.Discard, resp.Body) // nolint
+		resp.Body.Close()
+	}()
+}
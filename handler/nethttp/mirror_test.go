@@ -0,0 +1,111 @@
+package wasm
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestMirrorRequest(t *testing.T) {
+	type mirrored struct{ path, header, body string }
+	mirrors := make(chan mirrored, 1)
+	shadow := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mirrors <- mirrored{r.URL.RequestURI(), r.Header.Get("X-Test"), string(body)}
+	}))
+	defer shadow.Close()
+
+	tests := []struct {
+		name               string
+		options            []httpwasm.Option
+		path, expectedPath string
+	}{
+		{name: "in memory", path: "/users?id=1", expectedPath: "/mirror/users?id=1"},
+		{
+			name:         "spilled",
+			options:      []httpwasm.Option{httpwasm.MaxBodyBuffer(2), httpwasm.SpillRequestBodies(t.TempDir(), 1024)},
+			path:         "/users?id=1",
+			expectedPath: "/mirror/users?id=1",
+		},
+		{
+			// The path isn't cleaned.
+			name:         "trailing slash",
+			path:         "/users/a%2Fb//?id=1",
+			expectedPath: "/mirror/users/a%2Fb//?id=1",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			options := append([]httpwasm.Option{httpwasm.MirrorDestination("shadow", shadow.URL+"/mirror")}, tc.options...)
+			mw, err := NewMiddleware(testCtx, test.MirrorWasm, options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mw.Close(testCtx)
+
+			// The next handler can still read the body after it was mirrored.
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(w, r.Body) // nolint
+			})
+			req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader("hello"))
+			req.Header.Set("X-Test", "mirror")
+			if body := serve(t, mw, next, req); body != "hello" {
+				t.Fatalf("expected body %q, have %q", "hello", body)
+			}
+
+			expected := mirrored{tc.expectedPath, "mirror", "hello"}
+			select {
+			case have := <-mirrors:
+				if have != expected {
+					t.Fatalf("expected mirrored request %v, have %v", expected, have)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("timeout waiting for mirrored request")
+			}
+		})
+	}
+}
+
+func TestMirrorRequest_Full(t *testing.T) {
+	defer func(slots chan struct{}) { mirrorSlots = slots }(mirrorSlots)
+	mirrorSlots = make(chan struct{}, 1)
+
+	// The mirror blocks until released, so the first copy holds the only
+	// slot.
+	received, release := make(chan struct{}, 2), make(chan struct{})
+	shadow := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		received <- struct{}{}
+		<-release
+	}))
+	defer shadow.Close()
+	defer close(release)
+
+	mw, err := NewMiddleware(testCtx, test.MirrorWasm, httpwasm.MirrorDestination("shadow", shadow.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	serve(t, mw, noopHandler, httptest.NewRequest(http.MethodGet, "/", nil))
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for mirrored request")
+	}
+
+	// The second copy is dropped, instead of waiting for a slot.
+	serve(t, mw, noopHandler, httptest.NewRequest(http.MethodGet, "/", nil))
+	select {
+	case <-received:
+		t.Fatal("expected the second request not to be mirrored")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
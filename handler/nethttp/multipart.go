@@ -0,0 +1,161 @@
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"strings"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// multipartState reads a multipart request body incrementally, retaining the
+// bytes read so far so that the next handler can read the body from the
+// start.
+type multipartState struct {
+	body   io.ReadCloser
+	read   bytes.Buffer
+	reader *multipart.Reader
+	// parts are those read so far, by form name.
+	parts map[string]*formPart
+	eof   bool
+}
+
+// formPart is a part of a multipart request body.
+type formPart struct {
+	// content is nil for a file which was read past, but not requested.
+	content []byte
+	// file is nil unless the part is a file.
+	file *handler.FileInfo
+}
+
+// GetMultipartPart implements the same method as documented on handler.Forms.
+func (h host) GetMultipartPart(ctx context.Context, name string) ([]byte, bool) {
+	if p := requestStateFromContext(ctx).multipartPart(name); p != nil && p.content != nil {
+		return p.content, true
+	}
+	return nil, false
+}
+
+// GetFormValue implements the same method as documented on handler.Forms.
+func (h host) GetFormValue(ctx context.Context, name string) (string, bool) {
+	s := requestStateFromContext(ctx)
+	mediaType, _, _ := mime.ParseMediaType(s.request.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		// Like http.Request ParseForm, use the fields before any invalid one.
+		form, _ := url.ParseQuery(string(s.requestBodyBytes()))
+		if values := form[name]; len(values) > 0 {
+			return values[0], true
+		}
+	case "multipart/form-data":
+		if p := s.multipartPart(name); p != nil && p.file == nil {
+			return string(p.content), true
+		}
+	}
+	return "", false
+}
+
+// GetUploadedFileInfo implements the same method as documented on
+// handler.Forms.
+func (h host) GetUploadedFileInfo(ctx context.Context, name string) (handler.FileInfo, bool) {
+	if p := requestStateFromContext(ctx).multipartPart(name); p != nil && p.file != nil {
+		return *p.file, true
+	}
+	return handler.FileInfo{}, false
+}
+
+// multipartPart returns the first part with the form name, reading the body
+// up to its end if it wasn't read yet. This returns nil if the part doesn't
+// exist or the request isn't multipart.
+func (s *requestState) multipartPart(name string) *formPart {
+	if s.multipart == nil {
+		if s.multipart = newMultipartState(s); s.multipart == nil {
+			return nil // not a multipart request
+		}
+	}
+	m := s.multipart
+
+	if part, ok := m.parts[name]; ok {
+		return part
+	}
+
+	defer func() {
+		// The next handler reads what was read so far, then the rest.
+		s.request.Body = &readCloser{
+			Reader: io.MultiReader(bytes.NewReader(m.read.Bytes()), m.body),
+			Closer: m.body,
+		}
+	}()
+
+	for !m.eof {
+		p, err := m.reader.NextPart()
+		if err == io.EOF {
+			m.eof = true
+			break
+		} else if err != nil {
+			panic(err)
+		}
+
+		formName := p.FormName()
+		part, err := readPart(p, formName == name)
+		if err != nil {
+			panic(err)
+		}
+		if _, ok := m.parts[formName]; !ok {
+			m.parts[formName] = part
+		}
+		if formName == name {
+			return part
+		}
+	}
+	return nil
+}
+
+// readPart reads the part, retaining its content unless it is a file and
+// retain is false, as files may be large.
+func readPart(p *multipart.Part, retain bool) (*formPart, error) {
+	part := &formPart{}
+	// Unlike multipart.Part FileName, don't sanitize the filename, so that
+	// guests can reject unsafe ones.
+	_, params, _ := mime.ParseMediaType(p.Header.Get("Content-Disposition"))
+	if filename, ok := params["filename"]; ok {
+		part.file = &handler.FileInfo{Filename: filename, ContentType: p.Header.Get("Content-Type")}
+	}
+
+	var size int64
+	var err error
+	if retain || part.file == nil {
+		part.content, err = io.ReadAll(p)
+		size = int64(len(part.content))
+	} else {
+		size, err = io.Copy(io.Discard, p)
+	}
+	if part.file != nil {
+		part.file.Size = uint64(size)
+	}
+	return part, err
+}
+
+// newMultipartState returns nil if the request isn't multipart.
+func newMultipartState(s *requestState) *multipartState {
+	r := s.request
+	if r.Body == nil {
+		return nil
+	}
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" {
+		return nil
+	}
+	m := &multipartState{body: r.Body, parts: map[string]*formPart{}}
+	m.reader = multipart.NewReader(io.TeeReader(r.Body, &m.read), params["boundary"])
+	return m
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,134 @@
+package wasm
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestMultipart(t *testing.T) {
+	var body bytes.Buffer
+	mpw := multipart.NewWriter(&body)
+	mpw.WriteField("meta", "small")                         // nolint
+	mpw.WriteField("file", strings.Repeat("large", 10_000)) // nolint
+	mpw.Close()                                             // nolint
+
+	mw, err := NewMiddleware(testCtx, test.MultipartWasm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	h, err := mw.NewHandler(testCtx, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The next handler reads the whole body, including the part read by
+		// the guest.
+		w.Write([]byte(r.FormValue("meta") + "," + r.FormValue("file")[:5])) // nolint
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close(testCtx)
+
+	req := httptest.NewRequest(http.MethodPost, "/", &body)
+	req.Header.Set("Content-Type", mpw.FormDataContentType())
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if expected, have := "small", w.Header().Get("X-Meta"); have != expected {
+		t.Fatalf("expected X-Meta %q, have %q", expected, have)
+	}
+	if expected, have := "small,large", w.Body.String(); have != expected {
+		t.Fatalf("expected body %q, have %q", expected, have)
+	}
+}
+
+func TestForm(t *testing.T) {
+	var multipartBody bytes.Buffer
+	mpw := multipart.NewWriter(&multipartBody)
+	mpw.WriteField("user", "alice") // nolint
+	fw, _ := mpw.CreatePart(textproto.MIMEHeader{
+		"Content-Disposition": {`form-data; name="file"; filename="../passwd"`},
+		"Content-Type":        {"text/plain"},
+	})
+	fw.Write([]byte("root:x:0:0")) // nolint
+	mpw.Close()                    // nolint
+
+	// size, then the length-prefixed filename and content type.
+	fileInfo := "\x0a\x00\x00\x00\x00\x00\x00\x00" +
+		"\x09\x00\x00\x00../passwd" +
+		"\x0a\x00\x00\x00text/plain"
+
+	tests := []struct {
+		name               string
+		contentType        string
+		body               string
+		expectedStatusCode int
+		expectedUser       string
+		expectedBody       string
+	}{
+		{
+			name:               "urlencoded",
+			contentType:        "application/x-www-form-urlencoded",
+			body:               "user=alice&user=bob",
+			expectedStatusCode: http.StatusOK,
+			expectedUser:       "alice",
+			expectedBody:       "user=alice&user=bob",
+		},
+		{
+			name:               "multipart with file",
+			contentType:        mpw.FormDataContentType(),
+			body:               multipartBody.String(),
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+			expectedUser:       "alice",
+			expectedBody:       fileInfo,
+		},
+		{
+			name:               "not a form",
+			contentType:        "text/plain",
+			body:               "user=alice",
+			expectedStatusCode: http.StatusOK,
+			expectedBody:       "user=alice",
+		},
+	}
+
+	mw, err := NewMiddleware(testCtx, test.FormWasm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	h, err := mw.NewHandler(testCtx, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body) // nolint
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close(testCtx)
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if have := w.Code; have != tc.expectedStatusCode {
+				t.Fatalf("expected status code %d, have %d", tc.expectedStatusCode, have)
+			}
+			if have := w.Header().Get("X-User"); have != tc.expectedUser {
+				t.Fatalf("expected X-User %q, have %q", tc.expectedUser, have)
+			}
+			if have := w.Body.String(); have != tc.expectedBody {
+				t.Fatalf("expected body %q, have %q", tc.expectedBody, have)
+			}
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package wasm
+
+import (
+	"context"
+)
+
+// Properties returns the properties guests set via handler.FuncSetProperty
+// on the current request, or nil if the context isn't from a request handled
+// by a guest. This is the context of the request passed to the next handler.
+//
+// The result is not a copy: the next handler can set properties, for example
+// for the guest to read in handler.FuncHandleResponse.
+func Properties(ctx context.Context) map[string]string {
+	s, ok := ctx.Value(requestStateKey{}).(*requestState)
+	if !ok {
+		return nil
+	}
+	if s.properties == nil {
+		s.properties = map[string]string{}
+	}
+	return s.properties
+}
+
+// SetProperty implements the same method as documented on handler.Properties.
+func (h host) SetProperty(ctx context.Context, name, value string) {
+	Properties(ctx)[name] = value
+}
+
+// GetProperties implements the same method as documented on
+// handler.Properties.
+func (h host) GetProperties(ctx context.Context) map[string]string {
+	return requestStateFromContext(ctx).properties
+}
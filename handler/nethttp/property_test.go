@@ -0,0 +1,41 @@
+package wasm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestProperties(t *testing.T) {
+	mw, err := NewMiddlewareChain(testCtx, [][]byte{test.PropertyWasm, test.PropertyWasm})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	h, err := mw.NewHandler(testCtx, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(Properties(r.Context())["user"])) // nolint
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close(testCtx)
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// The first guest sets the property, which the second and the next
+	// handler read.
+	if expected, have := "alice", w.Header().Get("X-User"); have != expected {
+		t.Fatalf("expected X-User %q, have %q", expected, have)
+	}
+	if expected, have := "alice", w.Body.String(); have != expected {
+		t.Fatalf("expected body %q, have %q", expected, have)
+	}
+
+	if Properties(testCtx) != nil {
+		t.Fatal("expected no properties outside a request")
+	}
+}
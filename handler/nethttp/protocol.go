@@ -0,0 +1,16 @@
+package wasm
+
+import "net/http"
+
+// protocolVersion returns the protocol version of the request, normalized by
+// the major version, as servers differ in format. For example, an HTTP/3
+// server may set http.Request Proto to "HTTP/3" instead of "HTTP/3.0".
+func protocolVersion(r *http.Request) string {
+	switch r.ProtoMajor {
+	case 2:
+		return "HTTP/2.0"
+	case 3:
+		return "HTTP/3.0"
+	}
+	return r.Proto
+}
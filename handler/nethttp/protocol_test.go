@@ -0,0 +1,98 @@
+package wasm
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestProtocolVersion(t *testing.T) {
+	mw, err := NewMiddleware(testCtx, test.ProtocolWasm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	// The next handler uses optional interfaces of the ResponseWriter, which
+	// must pass through the guest.
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor == 1 {
+			if err := w.(http.Pusher).Push("/style.css", nil); err != http.ErrNotSupported {
+				t.Errorf("expected push to be unsupported, have %v", err)
+			}
+		}
+		if _, err := w.(io.ReaderFrom).ReadFrom(strings.NewReader("hello")); err != nil {
+			t.Error(err)
+		}
+	})
+	h, err := mw.NewHandler(testCtx, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close(testCtx)
+
+	tests := []struct {
+		name     string
+		enableH2 bool
+		expected string
+	}{
+		{name: "HTTP/1.1", expected: "HTTP/1.1"},
+		{name: "HTTP/2", enableH2: true, expected: "HTTP/2.0"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			ts := httptest.NewUnstartedServer(h)
+			ts.EnableHTTP2 = tc.enableH2
+			ts.StartTLS()
+			defer ts.Close()
+
+			resp, err := ts.Client().Get(ts.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v := resp.Header.Get("X-Protocol"); v != tc.expected {
+				t.Errorf("expected protocol %q, have %q", tc.expected, v)
+			}
+			if string(body) != "hello" {
+				t.Errorf("expected body %q, have %q", "hello", body)
+			}
+		})
+	}
+}
+
+func TestProtocolVersion_Normalized(t *testing.T) {
+	tests := []struct {
+		proto    string
+		major    int
+		minor    int
+		expected string
+	}{
+		{proto: "HTTP/1.0", major: 1, minor: 0, expected: "HTTP/1.0"},
+		{proto: "HTTP/1.1", major: 1, minor: 1, expected: "HTTP/1.1"},
+		{proto: "HTTP/2.0", major: 2, expected: "HTTP/2.0"},
+		{proto: "HTTP/3", major: 3, expected: "HTTP/3.0"},
+		{proto: "HTTP/3.0", major: 3, expected: "HTTP/3.0"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.proto, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Proto, r.ProtoMajor, r.ProtoMinor = tc.proto, tc.major, tc.minor
+			if have := protocolVersion(r); have != tc.expected {
+				t.Errorf("expected %q, have %q", tc.expected, have)
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package wasm
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/api"
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	"github.com/http-wasm/http-wasm-host-go/compat/proxywasm"
+	"github.com/http-wasm/http-wasm-host-go/internal/inflight"
+)
+
+type proxyWasmMiddleware struct {
+	runtime *proxywasm.Runtime
+	// inflight counts requests, for CloseGracefully.
+	inflight inflight.Counter
+}
+
+// NewProxyWasmMiddleware is like NewMiddleware, except the guest implements
+// the proxy-wasm ABI, such as an Envoy filter. See package proxywasm for
+// limitations.
+func NewProxyWasmMiddleware(ctx context.Context, guest []byte, options ...httpwasm.Option) (Middleware, error) {
+	r, err := proxywasm.NewRuntime(ctx, guest, &host{}, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &proxyWasmMiddleware{runtime: r}, nil
+}
+
+// NewHandler implements the same method as documented on handler.Middleware.
+func (w *proxyWasmMiddleware) NewHandler(ctx context.Context, next http.Handler) (Handler, error) {
+	g, err := w.runtime.NewGuest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &proxyWasmGuest{m: w, guest: g, next: next}, nil
+}
+
+// CustomSection implements the same method as documented on
+// handler.CustomSectionReader.
+func (w *proxyWasmMiddleware) CustomSection(name string) ([]byte, bool) {
+	return w.runtime.CustomSection(name)
+}
+
+// GuestMetadata implements the same method as documented on
+// handler.GuestMetadataReader.
+func (w *proxyWasmMiddleware) GuestMetadata() handler.GuestMetadata {
+	return w.runtime.GuestMetadata()
+}
+
+// CompileReport implements the same method as documented on
+// handler.CompileReporter.
+func (w *proxyWasmMiddleware) CompileReport() api.CompileReport {
+	return w.runtime.CompileReport()
+}
+
+// Ping implements the same method as documented on handler.Pinger.
+// proxy-wasm guests don't export handler.FuncPing, so this only checks that
+// a guest can be instantiated.
+func (w *proxyWasmMiddleware) Ping(ctx context.Context) error {
+	g, err := w.runtime.NewGuest(ctx)
+	if err != nil {
+		return err
+	}
+	return g.Close(ctx)
+}
+
+// Close implements the same method as documented on handler.Middleware.
+func (w *proxyWasmMiddleware) Close(ctx context.Context) error {
+	return w.runtime.Close(ctx)
+}
+
+// CloseGracefully implements the same method as documented on
+// handler.GracefulCloser.
+func (w *proxyWasmMiddleware) CloseGracefully(ctx context.Context, timeout time.Duration) error {
+	w.inflight.Wait(ctx, timeout)
+	return w.runtime.Close(ctx)
+}
+
+// compile-time check to ensure proxyWasmGuest implements Handler.
+var _ Handler = &proxyWasmGuest{}
+
+type proxyWasmGuest struct {
+	m     *proxyWasmMiddleware
+	guest *proxywasm.Guest
+	next  http.Handler
+}
+
+// ServeHTTP implements http.Handler
+func (w *proxyWasmGuest) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	w.m.inflight.Add()
+	defer w.m.inflight.Done()
+	ctx, s := withRequestState(request.Context(), response, request, w.next)
+	defer s.release()
+	s.setBodyLimits(w.m.runtime.BodyLimits())
+	if err := w.guest.Handle(ctx); err != nil && !isGuestError(err) {
+		serveError(response, err)
+		return
+	}
+	s.response.commit()
+}
+
+// Close implements api.Closer
+func (w *proxyWasmGuest) Close(ctx context.Context) error {
+	return w.guest.Close(ctx)
+}
@@ -0,0 +1,138 @@
+package wasm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestProxyWasmMiddleware(t *testing.T) {
+	var messages []string
+	mw, err := NewProxyWasmMiddleware(testCtx, test.ProxyWasmWasm,
+		httpwasm.GuestConfigBytes([]byte("config")),
+		httpwasm.Logger(func(_ context.Context, msg string) {
+			messages = append(messages, msg)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	h, err := mw.NewHandler(testCtx, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("hello")) // nolint
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close(testCtx)
+
+	if have := strings.Join(messages, ","); have != "config" {
+		t.Fatalf("expected the plugin configuration logged, have %q", have)
+	}
+
+	tests := []struct {
+		name               string
+		block              bool
+		expectedStatusCode int
+		expectedHeader     http.Header
+		expectedBody       string
+	}{
+		{
+			name:               "continue",
+			expectedStatusCode: http.StatusOK,
+			expectedHeader:     http.Header{"X-Proxy-Wasm": {"1"}},
+			expectedBody:       "hello",
+		},
+		{
+			name:               "local response",
+			block:              true,
+			expectedStatusCode: http.StatusForbidden,
+			expectedHeader:     http.Header{"X-Reason": {"policy"}},
+			expectedBody:       "blocked",
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			messages = nil
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.block {
+				req.Header.Set("X-Block", "1")
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatusCode {
+				t.Fatalf("expected status %d, have %d", tc.expectedStatusCode, w.Code)
+			}
+			for name := range tc.expectedHeader {
+				if have, expected := w.Header().Get(name), tc.expectedHeader.Get(name); have != expected {
+					t.Fatalf("expected header %s %q, have %q", name, expected, have)
+				}
+			}
+			if have := w.Body.String(); have != tc.expectedBody {
+				t.Fatalf("expected body %q, have %q", tc.expectedBody, have)
+			}
+			if have := strings.Join(messages, ","); have != "done" {
+				t.Fatalf("expected the request logged, have %q", have)
+			}
+		})
+	}
+
+	if _, err = NewProxyWasmMiddleware(testCtx, test.AuthWasm); err == nil {
+		t.Fatal("expected an error compiling a guest without the proxy-wasm ABI")
+	}
+}
+
+func TestProxyWasmMiddleware_SpilledBody(t *testing.T) {
+	const body = "hello, this is larger than the limit"
+	spillDir := t.TempDir()
+
+	var messages []string
+	mw, err := NewProxyWasmMiddleware(testCtx, test.ProxyWasmWasm,
+		httpwasm.MaxBodyBuffer(16),
+		httpwasm.SpillRequestBodies(spillDir, 1024),
+		httpwasm.Logger(func(_ context.Context, msg string) {
+			messages = append(messages, msg)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	// The response isn't the body, as the limit applies to it, too.
+	spilled, read := 0, ""
+	h, err := mw.NewHandler(testCtx, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		files, _ := os.ReadDir(spillDir)
+		spilled = len(files)
+		b, _ := io.ReadAll(r.Body)
+		read = string(b)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close(testCtx)
+
+	messages = nil
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+
+	if spilled != 1 {
+		t.Fatalf("expected the body spilled to a file, have %d files", spilled)
+	}
+	// The guest reads only the part of the body it asks for.
+	if have := strings.Join(messages, ","); have != "this ,done" {
+		t.Fatalf("expected part of the body logged, have %q", have)
+	}
+	if read != body {
+		t.Fatalf("expected the next handler to read %q, have %q", body, read)
+	}
+}
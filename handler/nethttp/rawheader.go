@@ -0,0 +1,362 @@
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+const (
+	// maxRawHeaderBytes is the largest request header preserved, the same
+	// as http.DefaultMaxHeaderBytes. A connection which exceeds this stops
+	// preserving headers.
+	maxRawHeaderBytes = http.DefaultMaxHeaderBytes
+	// maxRawHeaderBlocks is the most headers queued per connection, for
+	// requests not yet handled, such as when the client pipelines them.
+	maxRawHeaderBlocks = 8
+)
+
+// rawConns are connections accepted by listeners from PreserveHeaderCase, by
+// rawConnKey. rawListeners counts such listeners, so that requests skip the
+// lookup when there are none.
+var (
+	rawConns     sync.Map
+	rawListeners int32
+)
+
+// rawHeadersKey is a context.Context Value associated with the raw headers of
+// the current request, so that guests handling the request after another
+// don't dequeue them again.
+type rawHeadersKey struct{}
+
+// PreserveHeaderCase returns a listener which preserves the request headers
+// of each connection as the client sent them, in order and with the case of
+// names, for handler.FuncReadRawRequestHeaders. Otherwise, net/http
+// canonicalizes header names and loses their order, which breaks guests that
+// verify signatures over raw headers, such as AWS Signature Version 4.
+//
+// For example:
+//
+//	l, err := net.Listen("tcp", ":8080")
+//	...
+//	err = http.Serve(wasm.PreserveHeaderCase(l), mw)
+//
+// Note: Only plaintext HTTP/1.x connections preserve headers, so terminate
+// TLS before this listener, such as at a load balancer. Requests with a
+// request line that doesn't match the one the client sent, such as after a
+// handler rewrote the URL, don't have raw headers.
+func PreserveHeaderCase(l net.Listener) net.Listener {
+	atomic.AddInt32(&rawListeners, 1)
+	return &rawListener{Listener: l}
+}
+
+type rawListener struct {
+	net.Listener
+	closed int32
+}
+
+// Accept implements the same method as documented on net.Listener.
+func (l *rawListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	rc := &rawConn{Conn: c, key: rawConnKey(c.LocalAddr(), c.RemoteAddr().String())}
+	rawConns.Store(rc.key, rc)
+	return rc, nil
+}
+
+// Close implements the same method as documented on net.Listener.
+func (l *rawListener) Close() error {
+	if atomic.CompareAndSwapInt32(&l.closed, 0, 1) {
+		atomic.AddInt32(&rawListeners, -1)
+	}
+	return l.Listener.Close()
+}
+
+func rawConnKey(local net.Addr, remote string) string {
+	return local.Network() + " " + local.String() + " " + remote
+}
+
+// rawConn parses the HTTP/1.x requests it reads, queueing their header
+// blocks for the handlers of the requests.
+type rawConn struct {
+	net.Conn
+	key       string
+	closeOnce sync.Once
+
+	mu     sync.Mutex
+	parser rawHeaderParser
+	blocks [][]byte
+}
+
+// Read implements the same method as documented on net.Conn.
+func (c *rawConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.mu.Lock()
+		c.parser.parse(b[:n], func(block []byte) {
+			if len(c.blocks) == maxRawHeaderBlocks {
+				c.blocks = c.blocks[1:]
+			}
+			c.blocks = append(c.blocks, block)
+		})
+		c.mu.Unlock()
+	}
+	return n, err
+}
+
+// Close implements the same method as documented on net.Conn.
+func (c *rawConn) Close() error {
+	c.closeOnce.Do(func() { rawConns.Delete(c.key) })
+	return c.Conn.Close()
+}
+
+// take dequeues the header block of the request line, discarding blocks
+// before it, which are of requests no guest handled.
+func (c *rawConn) take(requestLine string) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, block := range c.blocks {
+		if line, _, _ := strings.Cut(string(block), "\n"); strings.TrimSuffix(line, "\r") == requestLine {
+			c.blocks = c.blocks[i+1:]
+			return block
+		}
+	}
+	return nil
+}
+
+// rawRequestHeaders returns the headers of the request as received, or nil
+// if they weren't preserved.
+func rawRequestHeaders(r *http.Request) []handler.HeaderField {
+	if fields, ok := r.Context().Value(rawHeadersKey{}).([]handler.HeaderField); ok {
+		return fields // already taken by a guest which handled the request
+	}
+	if atomic.LoadInt32(&rawListeners) == 0 || r.ProtoMajor != 1 {
+		return nil
+	}
+	local, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	if !ok {
+		return nil
+	}
+	c, ok := rawConns.Load(rawConnKey(local, r.RemoteAddr))
+	if !ok {
+		return nil
+	}
+	block := c.(*rawConn).take(r.Method + " " + r.RequestURI + " " + r.Proto)
+	if block == nil {
+		return nil
+	}
+	return parseRawHeaders(block)
+}
+
+// GetRawRequestHeaders implements the same method as documented on
+// handler.RawHeaders.
+func (h host) GetRawRequestHeaders(ctx context.Context) []handler.HeaderField {
+	return requestStateFromContext(ctx).rawHeaders
+}
+
+// SetRawResponseHeader implements the same method as documented on
+// handler.RawHeaders.
+func (h host) SetRawResponseHeader(ctx context.Context, name, value string) {
+	header := requestStateFromContext(ctx).response.Header()
+	for n := range header {
+		if strings.EqualFold(n, name) {
+			delete(header, n) // canonical, or raw in another case
+		}
+	}
+	header[name] = []string{value}
+}
+
+// parseRawHeaders parses the header fields of a header block, which starts
+// with the request line. Obsolete line folding is replaced with a space.
+func parseRawHeaders(block []byte) []handler.HeaderField {
+	lines := strings.Split(strings.TrimRight(string(block), "\r\n"), "\n")
+	fields := make([]handler.HeaderField, 0, len(lines)-1)
+	for _, line := range lines[1:] {
+		line = strings.TrimSuffix(line, "\r")
+		if n := len(fields); n > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			fields[n-1].Value += " " + strings.TrimSpace(line)
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		fields = append(fields, handler.HeaderField{Name: name, Value: strings.TrimSpace(value)})
+	}
+	return fields
+}
+
+// Parser states.
+const (
+	rawStateHeader = iota
+	rawStateBody
+	rawStateChunkSize
+	rawStateChunkData
+	rawStateChunkEnd
+	rawStateTrailer
+	// rawStateDone is when the connection no longer carries HTTP/1.x
+	// requests, such as after a protocol upgrade, or can't be parsed.
+	rawStateDone
+)
+
+// rawHeaderParser finds the header block of each request in the bytes of a
+// connection, skipping request bodies.
+type rawHeaderParser struct {
+	state int
+	// buf accumulates the current header block, chunk size or trailer.
+	buf []byte
+	// remaining is the length of the body or chunk left to skip.
+	remaining int64
+	// upgrade is true when the connection changes protocol after the body.
+	upgrade bool
+}
+
+// parse consumes bytes read from the connection, calling onBlock with each
+// complete header block.
+func (p *rawHeaderParser) parse(b []byte, onBlock func([]byte)) {
+	for len(b) > 0 {
+		switch p.state {
+		case rawStateHeader:
+			if len(p.buf) == 0 {
+				b = bytes.TrimLeft(b, "\r\n") // allowed before a request line
+				if len(b) == 0 {
+					return
+				}
+			}
+			var end int
+			if b, end = p.accumulate(b, "\n\r\n", "\n\n"); end < 0 {
+				continue
+			}
+			block := p.buf[:end]
+			p.buf = nil
+			p.startBody(block)
+			onBlock(block)
+		case rawStateBody, rawStateChunkData:
+			n := p.remaining
+			if n > int64(len(b)) {
+				n = int64(len(b))
+			}
+			b, p.remaining = b[n:], p.remaining-n
+			if p.remaining > 0 {
+				continue
+			}
+			if p.state == rawStateChunkData {
+				p.state = rawStateChunkEnd
+			} else {
+				p.endBody()
+			}
+		case rawStateChunkEnd:
+			// Skip the CRLF after chunk data, tolerating a bare LF.
+			if b[0] == '\r' {
+				b = b[1:]
+				continue
+			} else if b[0] == '\n' {
+				b = b[1:]
+			}
+			p.state = rawStateChunkSize
+		case rawStateChunkSize:
+			var end int
+			if b, end = p.accumulate(b, "\n"); end < 0 {
+				continue
+			}
+			line := strings.TrimSpace(string(p.buf[:end]))
+			p.buf = nil
+			line, _, _ = strings.Cut(line, ";") // extensions
+			size, err := strconv.ParseInt(strings.TrimSpace(line), 16, 64)
+			switch {
+			case err != nil || size < 0:
+				p.state = rawStateDone
+			case size == 0:
+				p.state = rawStateTrailer
+			default:
+				p.state, p.remaining = rawStateChunkData, size
+			}
+		case rawStateTrailer:
+			// Skip trailer fields until an empty line, which is all there is
+			// when there are no trailers.
+			var end int
+			if b, end = p.accumulate(b, "\n"); end < 0 {
+				continue
+			}
+			line := strings.TrimSpace(string(p.buf[:end]))
+			p.buf = nil
+			if line == "" {
+				p.endBody()
+			}
+		default:
+			return
+		}
+	}
+}
+
+// accumulate appends bytes to buf until it includes one of the delimiters,
+// returning the bytes after it, and the end of buf before the delimiter, or
+// -1 if none was found. The state changes to rawStateDone if buf exceeds
+// maxRawHeaderBytes.
+func (p *rawHeaderParser) accumulate(b []byte, delims ...string) ([]byte, int) {
+	start := len(p.buf) - 3 // a delimiter may span reads
+	if start < 0 {
+		start = 0
+	}
+	p.buf = append(p.buf, b...)
+	i, delimLen := -1, 0
+	for _, delim := range delims {
+		if j := bytes.Index(p.buf[start:], []byte(delim)); j >= 0 && (i < 0 || j < i) {
+			i, delimLen = j, len(delim)
+		}
+	}
+	if i >= 0 {
+		rest := p.buf[start+i+delimLen:]
+		return b[len(b)-len(rest):], start + i + 1
+	}
+	if len(p.buf) > maxRawHeaderBytes {
+		p.state, p.buf = rawStateDone, nil
+	}
+	return nil, -1
+}
+
+// startBody reads how the body of the request is framed from its header
+// block, and changes the state to skip it.
+func (p *rawHeaderParser) startBody(block []byte) {
+	if bytes.HasPrefix(block, []byte("PRI * HTTP/2")) || bytes.HasPrefix(block, []byte("CONNECT ")) {
+		p.state = rawStateDone
+		return
+	}
+	p.state, p.remaining, p.upgrade = rawStateBody, 0, false
+	for _, f := range parseRawHeaders(block) {
+		switch {
+		case strings.EqualFold(f.Name, "Content-Length"):
+			if n, err := strconv.ParseInt(f.Value, 10, 64); err == nil && n >= 0 {
+				p.remaining = n
+			}
+		case strings.EqualFold(f.Name, "Transfer-Encoding"):
+			if strings.Contains(strings.ToLower(f.Value), "chunked") {
+				p.state = rawStateChunkSize
+			}
+		case strings.EqualFold(f.Name, "Upgrade"):
+			p.upgrade = true
+		}
+	}
+	if p.state == rawStateBody && p.remaining == 0 {
+		p.endBody()
+	}
+}
+
+// endBody changes the state to read the next request, unless the connection
+// upgraded to another protocol.
+func (p *rawHeaderParser) endBody() {
+	if p.upgrade {
+		p.state = rawStateDone
+	} else {
+		p.state = rawStateHeader
+	}
+}
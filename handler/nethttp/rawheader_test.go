@@ -0,0 +1,169 @@
+package wasm
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestRawHeaderParser(t *testing.T) {
+	tests := []struct {
+		name     string
+		stream   string
+		expected []string
+	}{
+		{
+			name:     "no body",
+			stream:   "GET / HTTP/1.1\r\nHost: a\r\n\r\nGET /b HTTP/1.1\r\nHost: a\r\n\r\n",
+			expected: []string{"GET / HTTP/1.1\r\nHost: a\r\n", "GET /b HTTP/1.1\r\nHost: a\r\n"},
+		},
+		{
+			name: "content length",
+			stream: "POST / HTTP/1.1\r\nContent-Length: 19\r\n\r\nGET /x HTTP/1.1\r\n\r\n" +
+				"GET /b HTTP/1.1\r\n\r\n",
+			expected: []string{"POST / HTTP/1.1\r\nContent-Length: 19\r\n", "GET /b HTTP/1.1\r\n"},
+		},
+		{
+			name: "chunked",
+			stream: "POST / HTTP/1.1\r\ntransfer-encoding: chunked\r\n\r\n" +
+				"5;ext=1\r\nhello\r\n0\r\nX-Trailer: a\r\n\r\n" +
+				"\r\nGET /b HTTP/1.1\n\n",
+			expected: []string{"POST / HTTP/1.1\r\ntransfer-encoding: chunked\r\n", "GET /b HTTP/1.1\n"},
+		},
+		{
+			name:     "upgrade",
+			stream:   "GET / HTTP/1.1\r\nUpgrade: websocket\r\n\r\nGET /b HTTP/1.1\r\n\r\n",
+			expected: []string{"GET / HTTP/1.1\r\nUpgrade: websocket\r\n"},
+		},
+		{
+			name:     "http2",
+			stream:   "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\nGET /b HTTP/1.1\r\n\r\n",
+			expected: []string{"PRI * HTTP/2.0\r\n"},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			// Parse one byte at a time, as delimiters may span reads.
+			var p rawHeaderParser
+			var blocks []string
+			for i := 0; i < len(tc.stream); i++ {
+				p.parse([]byte{tc.stream[i]}, func(block []byte) {
+					blocks = append(blocks, string(block))
+				})
+			}
+			if !reflect.DeepEqual(tc.expected, blocks) {
+				t.Fatalf("expected %q, have %q", tc.expected, blocks)
+			}
+		})
+	}
+}
+
+func TestParseRawHeaders(t *testing.T) {
+	block := "GET / HTTP/1.1\r\nHost: a\r\nx-amz-date:20130524T000000Z\r\nX-Folded: a\r\n  b\r\n"
+	expected := []handler.HeaderField{
+		{Name: "Host", Value: "a"},
+		{Name: "x-amz-date", Value: "20130524T000000Z"},
+		{Name: "X-Folded", Value: "a b"},
+	}
+	if have := parseRawHeaders([]byte(block)); !reflect.DeepEqual(expected, have) {
+		t.Fatalf("expected %v, have %v", expected, have)
+	}
+}
+
+func TestPreserveHeaderCase(t *testing.T) {
+	mw, err := NewMiddleware(testCtx, test.RawHeadersWasm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	h, err := mw.NewHandler(testCtx, noopHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close(testCtx)
+
+	// Without the listener, raw headers aren't preserved, but response
+	// headers are still sent raw.
+	plain := httptest.NewServer(h)
+	defer plain.Close()
+	status, header, _ := rawRoundTrip(t, plain.Listener.Addr().String())
+	if status != http.StatusNotFound {
+		t.Fatalf("expected no raw headers, have status %d", status)
+	}
+	if !strings.Contains(header, "\r\nx-amz-date: 20130524T000000Z\r\n") {
+		t.Fatalf("expected raw response header, have %q", header)
+	}
+
+	s := httptest.NewUnstartedServer(h)
+	s.Listener = PreserveHeaderCase(s.Listener)
+	s.Start()
+	defer s.Close()
+
+	status, _, body := rawRoundTrip(t, s.Listener.Addr().String())
+	if status != http.StatusOK {
+		t.Fatalf("expected raw headers, have status %d", status)
+	}
+	expected := encodeFields("Host", "example.com", "x-amz-date", "20130524T000000Z", "X-Amz-Date", "again")
+	if !bytes.Equal(expected, body) {
+		t.Fatalf("expected raw headers %q, have %q", expected, body)
+	}
+}
+
+// rawRoundTrip sends a request with raw headers on a keep-alive connection
+// twice, returning the status, header block and body of the second response.
+func rawRoundTrip(t *testing.T, addr string) (status int, header string, body []byte) {
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	br := bufio.NewReader(c)
+	for i := 0; i < 2; i++ {
+		req := "POST /sign HTTP/1.1\r\nHost: example.com\r\nx-amz-date: 20130524T000000Z\r\n" +
+			"X-Amz-Date: again\r\nContent-Length: 4\r\n\r\nbody"
+		if i == 1 {
+			req = strings.Replace(req, "Content-Length: 4\r\n\r\nbody", "", 1) + "\r\n"
+			req = strings.Replace(req, "POST", "GET", 1)
+		}
+		if _, err = io.WriteString(c, req); err != nil {
+			t.Fatal(err)
+		}
+
+		var raw bytes.Buffer
+		resp, err := http.ReadResponse(bufio.NewReader(io.TeeReader(br, &raw)), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header = raw.String()
+		body, _ = io.ReadAll(resp.Body)
+		status = resp.StatusCode
+	}
+	return
+}
+
+// encodeFields encodes name and value pairs as documented on
+// handler.FuncReadRequestHeaders.
+func encodeFields(nameValues ...string) []byte {
+	var b []byte
+	for _, s := range nameValues {
+		b = appendField(b, s)
+	}
+	return b
+}
+
+func appendField(b []byte, s string) []byte {
+	return append(append(b, byte(len(s)), 0, 0, 0), s...)
+}
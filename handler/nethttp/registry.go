@@ -0,0 +1,255 @@
+package wasm
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sync"
+
+	"github.com/tetratelabs/wazero"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/api"
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	"github.com/http-wasm/http-wasm-host-go/internal"
+	"github.com/http-wasm/http-wasm-host-go/internal/idle"
+)
+
+// Registry is a http.Handler which runs different guests for different
+// requests, such as per path or tenant, sharing one wazero runtime, or those
+// configured with httpwasm.Shards. Requests no guest is registered for are
+// served by the next handler directly.
+//
+// Guests can be registered while serving requests, but not replaced.
+type Registry struct {
+	next     http.Handler
+	runtimes *sharedRuntimes
+	options  []httpwasm.Option
+
+	mu       sync.RWMutex
+	mux      *http.ServeMux
+	patterns map[string]*handlerPool
+	tenants  map[string]*handlerPool
+	tenantOf func(*http.Request) string
+}
+
+// NewRegistry returns a Registry which invokes next after the guest of each
+// request, or instead of it, if none is registered. The options apply to all
+// guests, before any passed when registering them.
+func NewRegistry(ctx context.Context, next http.Handler, options ...httpwasm.Option) (*Registry, error) {
+	r, err := newSharedRuntimes(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Registry{
+		next:     next,
+		runtimes: r,
+		options:  options,
+		mux:      http.NewServeMux(),
+		patterns: map[string]*handlerPool{},
+		tenants:  map[string]*handlerPool{},
+	}, nil
+}
+
+// sharedRuntimes are the wazero runtimes shared by the guests of a Registry
+// or router, which are more than one when configured with httpwasm.Shards.
+type sharedRuntimes struct {
+	runtimes []wazero.Runtime
+	strategy api.ShardStrategy
+	// assigned counts guests assigned a runtime by api.ShardRoundRobin.
+	assigned int
+}
+
+// newSharedRuntimes creates the runtimes per the options.
+func newSharedRuntimes(ctx context.Context, options []httpwasm.Option) (*sharedRuntimes, error) {
+	o := &internal.WazeroOptions{
+		NewRuntime:  internal.DefaultRuntime,
+		RuntimeMode: internal.DefaultRuntimeMode(),
+	}
+	for _, option := range options {
+		option(o)
+	}
+	s := &sharedRuntimes{strategy: o.Shards.Strategy}
+	for i := 0; i == 0 || i < o.Shards.Count; i++ {
+		r, err := o.CreateRuntime(ctx)
+		if err != nil {
+			_ = s.close(ctx)
+			return nil, err
+		}
+		s.runtimes = append(s.runtimes, r)
+	}
+	return s, nil
+}
+
+// options returns the options to create the guest of the tenant or pattern
+// name, sharing the runtime assigned to it. This must not be called
+// concurrently.
+func (s *sharedRuntimes) options(name string, options ...[]httpwasm.Option) []httpwasm.Option {
+	var i int
+	if s.strategy == api.ShardPerTenant {
+		h := fnv.New32a()
+		h.Write([]byte(name)) // nolint
+		i = int(h.Sum32() % uint32(len(s.runtimes)))
+	} else {
+		i = s.assigned % len(s.runtimes)
+		s.assigned++
+	}
+	var shared []httpwasm.Option
+	for _, o := range options {
+		shared = append(shared, o...)
+	}
+	return append(shared, func(o *internal.WazeroOptions) {
+		o.SharedRuntime = s.runtimes[i]
+		o.Shards = internal.Shards{} // the runtime is already a shard
+	})
+}
+
+func (s *sharedRuntimes) close(ctx context.Context) (err error) {
+	for _, r := range s.runtimes {
+		if e := r.Close(ctx); e != nil {
+			err = e
+		}
+	}
+	return
+}
+
+// Handler registers the guest for requests matching the pattern, which has
+// the same format as http.ServeMux. Ex. "/api/"
+//
+// Tenants registered with Tenant take precedence over patterns.
+func (g *Registry) Handler(ctx context.Context, pattern string, guest []byte, options ...httpwasm.Option) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.patterns[pattern]; ok {
+		return fmt.Errorf("wasm: pattern %q already registered", pattern)
+	}
+	p, err := g.newPool(ctx, pattern, guest, options)
+	if err != nil {
+		return err
+	}
+	g.mux.Handle(pattern, p)
+	g.patterns[pattern] = p
+	return nil
+}
+
+// Tenant registers the guest for requests of the tenant, as identified by the
+// function set with TenantFunc.
+func (g *Registry) Tenant(ctx context.Context, tenant string, guest []byte, options ...httpwasm.Option) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if _, ok := g.tenants[tenant]; ok {
+		return fmt.Errorf("wasm: tenant %q already registered", tenant)
+	}
+	p, err := g.newPool(ctx, tenant, guest, options)
+	if err != nil {
+		return err
+	}
+	g.tenants[tenant] = p
+	return nil
+}
+
+// UpdateConfig replaces the configuration of the guest of the tenant, without
+// re-instantiating it, such as to update a blocklist. This is the same as
+// handler.ConfigUpdater UpdateConfig, so requests in flight aren't disrupted.
+func (g *Registry) UpdateConfig(tenant string, config []byte) error {
+	g.mu.RLock()
+	p, ok := g.tenants[tenant]
+	g.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("wasm: tenant %q not registered", tenant)
+	}
+	return p.mw.(handler.ConfigUpdater).UpdateConfig(config)
+}
+
+// TenantFunc sets the function which returns the tenant of a request, or
+// empty if it has none. Ex. the value of the header "X-Tenant-ID"
+func (g *Registry) TenantFunc(tenantOf func(*http.Request) string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.tenantOf = tenantOf
+}
+
+func (g *Registry) newPool(ctx context.Context, name string, guest []byte, options []httpwasm.Option) (*handlerPool, error) {
+	mw, err := NewMiddleware(ctx, guest, g.runtimes.options(name, g.options, options)...)
+	if err != nil {
+		return nil, err
+	}
+	return newHandlerPool(mw, g.next), nil
+}
+
+// ServeHTTP implements http.Handler
+func (g *Registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.RLock()
+	p := g.lookup(r)
+	g.mu.RUnlock()
+
+	if p == nil {
+		g.next.ServeHTTP(w, r)
+		return
+	}
+	p.ServeHTTP(w, r)
+}
+
+// lookup returns the guests for the request, or nil if there are none. This
+// must be called with the read lock held.
+func (g *Registry) lookup(r *http.Request) *handlerPool {
+	if g.tenantOf != nil {
+		if p, ok := g.tenants[g.tenantOf(r)]; ok {
+			return p
+		}
+	}
+	if len(g.patterns) == 0 {
+		return nil
+	}
+	if _, pattern := g.mux.Handler(r); pattern != "" {
+		return g.patterns[pattern]
+	}
+	return nil
+}
+
+// Close closes all guests and the runtimes they share. This must not be called
+// while serving requests.
+func (g *Registry) Close(ctx context.Context) (err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, pools := range []map[string]*handlerPool{g.patterns, g.tenants} {
+		for _, p := range pools {
+			if e := p.close(ctx); e != nil {
+				err = e
+			}
+		}
+	}
+	if e := g.runtimes.close(ctx); e != nil {
+		err = e
+	}
+	return
+}
+
+// handlerPool serves requests with idle handlers of the middleware, as a
+// Handler isn't safe for concurrent use.
+type handlerPool struct {
+	idle.Pool
+	mw Middleware
+}
+
+func newHandlerPool(mw Middleware, next http.Handler) *handlerPool {
+	p := &handlerPool{mw: mw}
+	p.New = func(ctx context.Context) (idle.Handler, error) {
+		return mw.NewHandler(ctx, next)
+	}
+	return p
+}
+
+// close closes idle handlers, then the middleware.
+func (p *handlerPool) close(ctx context.Context) (err error) {
+	err = p.Close(ctx)
+	if e := p.mw.Close(ctx); e != nil {
+		err = e
+	}
+	return
+}
@@ -0,0 +1,179 @@
+package wasm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/tetratelabs/wazero"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/api"
+	"github.com/http-wasm/http-wasm-host-go/internal"
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestRegistry(t *testing.T) {
+	// The next handler responds with the name of the guest which invoked it.
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if info := FromContext(r.Context()); info != nil {
+			w.Write([]byte(info.Module)) // nolint
+		}
+	})
+
+	g, err := NewRegistry(testCtx, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close(testCtx)
+
+	if err = g.Handler(testCtx, "/log/", test.LogWasm); err != nil {
+		t.Fatal(err)
+	}
+	if err = g.Handler(testCtx, "/scratch", test.ScratchWasm); err != nil {
+		t.Fatal(err)
+	}
+	if err = g.Tenant(testCtx, "acme", test.QueryWasm); err != nil {
+		t.Fatal(err)
+	}
+	g.TenantFunc(func(r *http.Request) string { return r.Header.Get("X-Tenant-ID") })
+
+	if err = g.Handler(testCtx, "/log/", test.PropertyWasm); err == nil {
+		t.Fatal("expected an error registering a pattern twice")
+	}
+	if err = g.Tenant(testCtx, "acme", test.PropertyWasm); err == nil {
+		t.Fatal("expected an error registering a tenant twice")
+	}
+
+	tests := []struct {
+		name, target, tenant, expected string
+	}{
+		{name: "pattern", target: "/log/a", expected: "log"},
+		{name: "exact pattern", target: "/scratch", expected: "scratch"},
+		{name: "tenant before pattern", target: "/log/a", tenant: "acme", expected: "query"},
+		{name: "unknown tenant", target: "/scratch", tenant: "other", expected: "scratch"},
+		{name: "unmatched", target: "/other", expected: ""},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tc.target, nil)
+			if tc.tenant != "" {
+				req.Header.Set("X-Tenant-ID", tc.tenant)
+			}
+			w := httptest.NewRecorder()
+			g.ServeHTTP(w, req)
+
+			if have := w.Body.String(); have != tc.expected {
+				t.Fatalf("expected guest %q, have %q", tc.expected, have)
+			}
+		})
+	}
+
+	t.Run("concurrent", func(t *testing.T) {
+		s := httptest.NewServer(g)
+		defer s.Close()
+
+		var wg sync.WaitGroup
+		for i := 0; i < 16; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := http.Get(s.URL + "/log/a")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				defer resp.Body.Close()
+				if b, _ := io.ReadAll(resp.Body); string(b) != "log" {
+					t.Errorf("expected guest log, have %q", b)
+				}
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+func TestRegistry_UpdateConfig(t *testing.T) {
+	g, err := NewRegistry(testCtx, noopHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close(testCtx)
+
+	for _, tenant := range []string{"acme", "globex"} {
+		if err = g.Tenant(testCtx, tenant, test.ConfigUpdateWasm, httpwasm.GuestConfigBytes([]byte("allow"))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	g.TenantFunc(func(r *http.Request) string { return r.Header.Get("X-Tenant-ID") })
+
+	get := func(tenant string) string {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Tenant-ID", tenant)
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+	get("acme") // instantiate a guest before the update
+
+	if err = g.UpdateConfig("acme", []byte("deny")); err != nil {
+		t.Fatal(err)
+	}
+	if have := get("acme"); have != "deny" {
+		t.Fatalf("expected updated config, have %q", have)
+	}
+	if have := get("globex"); have != "allow" {
+		t.Fatalf("expected other tenants unchanged, have %q", have)
+	}
+
+	if err = g.UpdateConfig("initech", nil); err == nil {
+		t.Fatal("expected an error updating an unregistered tenant")
+	}
+}
+
+func TestRegistry_Shards(t *testing.T) {
+	var runtimes []wazero.Runtime
+	newRuntime := httpwasm.Runtime(func(ctx context.Context) (wazero.Runtime, error) {
+		r := wazero.NewRuntime(ctx)
+		runtimes = append(runtimes, r)
+		return r, nil
+	})
+
+	g, err := NewRegistry(testCtx, noopHandler, newRuntime, httpwasm.Shards(2, api.ShardPerTenant))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close(testCtx)
+
+	if have := len(runtimes); have != 2 {
+		t.Fatalf("expected 2 runtimes, have %d", have)
+	}
+
+	// Each tenant is assigned a runtime by a hash of its name, so the
+	// assignment is stable.
+	for _, tenant := range []string{"acme", "globex", "initech"} {
+		if err = g.Tenant(testCtx, tenant, test.LogWasm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if have := len(runtimes); have != 2 {
+		t.Fatalf("expected guests to share 2 runtimes, have %d", have)
+	}
+	if a, b := g.runtimes.options("acme"), g.runtimes.options("acme"); runtimeOf(a) != runtimeOf(b) {
+		t.Fatal("expected the same runtime for the same tenant")
+	}
+}
+
+// runtimeOf returns the runtime shared by the options.
+func runtimeOf(options []httpwasm.Option) wazero.Runtime {
+	o := &internal.WazeroOptions{}
+	for _, option := range options {
+		option(o)
+	}
+	return o.SharedRuntime
+}
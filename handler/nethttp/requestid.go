@@ -0,0 +1,36 @@
+package wasm
+
+import (
+	"context"
+
+	internalhandler "github.com/http-wasm/http-wasm-host-go/internal/handler"
+)
+
+// requestIDKey is a context.Context Value associated with the ID of the
+// current request, when httpwasm.RequestID is set.
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the ID assigned to the current request when
+// httpwasm.RequestID is set, or empty if none. Use this to correlate logs and
+// metrics with the request, such as in a httpwasm.Logger or the next handler.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// GetRequestID implements the same method as documented on
+// handler.RequestInfo.
+func (h host) GetRequestID(ctx context.Context) string {
+	return requestStateFromContext(ctx).requestID
+}
+
+// setRequestID assigns the ID to the request, if not empty, propagating it to
+// the next handler and the client.
+func (s *requestState) setRequestID(id string) {
+	if id == "" {
+		return
+	}
+	s.requestID = id
+	s.request.Header.Set(internalhandler.RequestIDHeader, id)
+	s.response.Header().Set(internalhandler.RequestIDHeader, id)
+}
@@ -0,0 +1,114 @@
+package wasm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestRequestID(t *testing.T) {
+	generated := regexp.MustCompile("^[0-9a-f]{32}$")
+
+	tests := []struct {
+		name     string
+		options  []httpwasm.Option
+		header   http.Header
+		expected string // or generated when empty
+		disabled bool
+	}{
+		{
+			name:     "disabled",
+			header:   http.Header{"X-Request-Id": {"abc"}},
+			disabled: true,
+		},
+		{
+			name:    "generated",
+			options: []httpwasm.Option{httpwasm.RequestID()},
+		},
+		{
+			name:     "X-Request-Id",
+			options:  []httpwasm.Option{httpwasm.RequestID()},
+			header:   http.Header{"X-Request-Id": {"abc"}},
+			expected: "abc",
+		},
+		{
+			name:     "traceparent",
+			options:  []httpwasm.Option{httpwasm.RequestID()},
+			header:   http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}},
+			expected: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name:    "invalid X-Request-Id",
+			options: []httpwasm.Option{httpwasm.RequestID()},
+			header:  http.Header{"X-Request-Id": {"a b"}},
+		},
+		{
+			name:    "invalid traceparent",
+			options: []httpwasm.Option{httpwasm.RequestID()},
+			header:  http.Header{"Traceparent": {"00-00000000000000000000000000000000-00f067aa0ba902b7-01"}},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			// The guest logs the ID, which is also in the context of the log.
+			var messages, logged []string
+			options := append(tc.options, httpwasm.Logger(func(ctx context.Context, msg string) {
+				messages = append(messages, msg)
+				logged = append(logged, RequestIDFromContext(ctx))
+			}))
+			mw, err := NewMiddleware(testCtx, test.RequestIDWasm, options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mw.Close(testCtx)
+
+			var nextID, nextHeader string
+			h, err := mw.NewHandler(testCtx, http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				nextID, nextHeader = RequestIDFromContext(r.Context()), r.Header.Get("X-Request-Id")
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer h.Close(testCtx)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			for k, v := range tc.header {
+				req.Header[k] = v
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+
+			if len(messages) != 1 {
+				t.Fatalf("expected one message, have %q", messages)
+			}
+			id := messages[0]
+			if tc.disabled {
+				if id != "" || logged[0] != "" || nextID != "" || w.Header().Get("X-Request-Id") != "" {
+					t.Errorf("expected no request ID, have %q", id)
+				}
+				return
+			}
+			if tc.expected != "" && id != tc.expected {
+				t.Errorf("expected request ID %q, have %q", tc.expected, id)
+			} else if tc.expected == "" && !generated.MatchString(id) {
+				t.Errorf("expected generated request ID, have %q", id)
+			}
+			if logged[0] != id {
+				t.Errorf("expected logger context to have request ID %q, have %q", id, logged[0])
+			}
+			if nextID != id || nextHeader != id {
+				t.Errorf("expected next handler to have request ID %q, have %q and header %q", id, nextID, nextHeader)
+			}
+			if have := w.Header().Get("X-Request-Id"); have != id {
+				t.Errorf("expected response header %q, have %q", id, have)
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package wasm
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// responseWriter tracks the status code of the response and whether it was
+// committed, so that the guest can read them. This streams the response
+// until switched to buffering by handler.FeatureBufferResponse, so that
+// guests which don't read the response body don't pay for buffering it.
+type responseWriter struct {
+	http.ResponseWriter
+	// statusCode is the status code committed, or pending if not committed.
+	// Zero means it wasn't set.
+	statusCode int
+	committed  bool
+	// beforeCommit are called once, before the response is committed.
+	beforeCommit []func()
+
+	// buffering is true when handler.FeatureBufferResponse is enabled, in
+	// which case the response isn't committed until the guest returns.
+	buffering bool
+	// written is true when the buffered response had a status code written.
+	written bool
+	// body is the buffered response body.
+	body []byte
+	// bodySize is the count of bytes of the body written, including any
+	// buffered.
+	bodySize uint64
+
+	// decode is true when handler.FeatureDecodeResponse is enabled.
+	decode bool
+	// decoded is true when body is the decoded form of encoding, so must be
+	// encoded again when committed.
+	decoded  bool
+	encoding string
+
+	// maxBody is the largest body buffered, if positive. When exceeded,
+	// tooLarge is set and the buffered body discarded.
+	maxBody  int64
+	tooLarge bool
+}
+
+// WriteHeader implements the same method as documented on
+// http.ResponseWriter.
+func (w *responseWriter) WriteHeader(statusCode int) {
+	if w.committed {
+		return // avoid a superfluous WriteHeader
+	}
+	if w.buffering {
+		if !w.written {
+			w.statusCode, w.written = statusCode, true
+		}
+		return
+	}
+	w.statusCode, w.committed = statusCode, true
+	for _, fn := range w.beforeCommit {
+		fn()
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write implements the same method as documented on http.ResponseWriter.
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.buffering {
+		if w.tooLarge {
+			return 0, handler.ErrBodyTooLarge
+		} else if w.maxBody > 0 && int64(len(w.body)+len(b)) > w.maxBody {
+			w.discardTooLarge()
+			return 0, handler.ErrBodyTooLarge
+		}
+		w.WriteHeader(w.status())
+		w.body = append(w.body, b...)
+		w.bodySize += uint64(len(b))
+		return len(b), nil
+	}
+	if !w.committed {
+		w.WriteHeader(w.status())
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bodySize += uint64(n)
+	return n, err
+}
+
+// Flush implements the same method as documented on http.Flusher, so that
+// responses such as Server-Sent Events are sent incrementally. This commits
+// the response, unless it is buffered, in which case it does nothing.
+func (w *responseWriter) Flush() {
+	w.FlushError() // nolint
+}
+
+// FlushError is like Flush, except it returns any error of the underlying
+// writer, such as when the client reset the HTTP/2 or HTTP/3 stream. This is
+// preferred by http.ResponseController, as of Go 1.20.
+func (w *responseWriter) FlushError() error {
+	if w.buffering {
+		return nil
+	}
+	if !w.committed {
+		w.WriteHeader(w.status())
+	}
+	switch flusher := w.ResponseWriter.(type) {
+	case interface{ FlushError() error }:
+		return flusher.FlushError()
+	case http.Flusher:
+		flusher.Flush()
+		return nil
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap returns the underlying writer, so that http.ResponseController can
+// reach methods this doesn't implement, such as SetWriteDeadline. It also
+// allows the next handler to use features of a specific server, such as the
+// HTTP/3 stream of quic-go, for datagrams. Writes to the underlying writer
+// bypass the guest, so must only be used when the guest doesn't buffer the
+// response.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Hijack implements the same method as documented on http.Hijacker, so that
+// the next handler can take over the connection, such as for a WebSocket
+// upgrade the guest allowed. This fails when the response is buffered, as
+// the guest expects to read it.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	} else if w.buffering {
+		return nil, nil, errors.New("wasm: can't hijack a buffered response")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		// The next handler writes the response to the connection, so the
+		// host mustn't.
+		w.statusCode, w.committed = http.StatusSwitchingProtocols, true
+	}
+	return conn, rw, err
+}
+
+// Push implements the same method as documented on http.Pusher, so that the
+// next handler can use HTTP/2 server push. This returns http.ErrNotSupported
+// when the underlying connection doesn't support push.
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if pusher, ok := w.ResponseWriter.(http.Pusher); ok {
+		return pusher.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// ReadFrom implements the same method as documented on io.ReaderFrom, so that
+// io.Copy to the response can use optimizations of the underlying writer,
+// such as sendfile. This copies via Write when the response is buffered or
+// the underlying writer doesn't implement io.ReaderFrom.
+func (w *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if w.buffering {
+		return io.Copy(writerOnly{w}, r)
+	}
+	if !w.committed {
+		w.WriteHeader(w.status())
+	}
+	var n int64
+	var err error
+	if readerFrom, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = readerFrom.ReadFrom(r)
+	} else {
+		n, err = io.Copy(writerOnly{w.ResponseWriter}, r)
+	}
+	w.bodySize += uint64(n)
+	return n, err
+}
+
+// writerOnly hides methods other than Write, so that io.Copy doesn't recurse
+// into ReadFrom.
+type writerOnly struct {
+	io.Writer
+}
+
+// discardTooLarge discards the buffered body, as it exceeded maxBody, so that
+// the response fails with 502 Bad Gateway.
+func (w *responseWriter) discardTooLarge() {
+	w.tooLarge = true
+	w.bodySize -= uint64(len(w.body))
+	w.body = w.body[:0]
+}
+
+// reset discards the buffered response, so that it can be replaced.
+func (w *responseWriter) reset() {
+	if w.decode && !w.decoded {
+		// The replacement is plain, like a decoded body would be.
+		w.encoding, w.decoded = decodableEncoding(w.Header())
+	}
+	w.written, w.tooLarge = false, false
+	w.bodySize -= uint64(len(w.body))
+	w.body = w.body[:0]
+	w.Header().Del("Content-Length")
+}
+
+// commit sends the status code set by the guest, or 200, if the response
+// wasn't committed by the time it returned, followed by the buffered body, if
+// any.
+func (w *responseWriter) commit() {
+	buffering := w.buffering
+	w.buffering = false
+	if buffering && !w.committed {
+		w.encodeBody()
+		if w.tooLarge {
+			w.Header().Del("Content-Length")
+		}
+	}
+	if !w.committed {
+		w.WriteHeader(w.status())
+	}
+	if buffering && len(w.body) > 0 {
+		w.ResponseWriter.Write(w.body) // nolint
+	}
+}
+
+func (w *responseWriter) status() int {
+	if w.tooLarge {
+		return http.StatusBadGateway // the next handler failed writing it
+	} else if w.statusCode == 0 {
+		return http.StatusOK
+	}
+	return w.statusCode
+}
+
+// GetStatusCode implements the same method as documented on handler.Host.
+func (h host) GetStatusCode(ctx context.Context) uint32 {
+	return uint32(requestStateFromContext(ctx).response.status())
+}
+
+// SetStatusCode implements the same method as documented on handler.Host.
+func (h host) SetStatusCode(ctx context.Context, statusCode uint32) {
+	if w := requestStateFromContext(ctx).response; !w.committed {
+		w.statusCode = int(statusCode)
+	}
+}
+
+// IsResponseCommitted implements the same method as documented on
+// handler.Host.
+func (h host) IsResponseCommitted(ctx context.Context) bool {
+	return requestStateFromContext(ctx).response.committed
+}
+
+// GetResponseHeaders implements the same method as documented on
+// handler.Host.
+func (h host) GetResponseHeaders(ctx context.Context) map[string][]string {
+	return requestStateFromContext(ctx).response.Header()
+}
+
+// GetResponseBodySize implements the same method as documented on
+// handler.Host.
+func (h host) GetResponseBodySize(ctx context.Context) uint64 {
+	return requestStateFromContext(ctx).response.bodySize
+}
+
+// BeforeCommit implements the same method as documented on handler.Host.
+func (h host) BeforeCommit(ctx context.Context, fn func()) {
+	w := requestStateFromContext(ctx).response
+	w.beforeCommit = append(w.beforeCommit, fn)
+}
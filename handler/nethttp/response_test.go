@@ -0,0 +1,123 @@
+package wasm
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestResponseBodySize(t *testing.T) {
+	large := strings.Repeat("a", 100000)
+	tests := []struct {
+		name              string
+		next              http.HandlerFunc
+		expectedSize      uint64
+		expectedCommitted bool
+	}{
+		{
+			name: "no response",
+			next: func(http.ResponseWriter, *http.Request) {},
+		},
+		{
+			name: "empty body",
+			next: func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+			expectedCommitted: true,
+		},
+		{
+			name: "body",
+			next: func(w http.ResponseWriter, _ *http.Request) {
+				w.Write([]byte("hello")) // nolint
+			},
+			expectedSize:      5,
+			expectedCommitted: true,
+		},
+		{
+			name: "copied body",
+			next: func(w http.ResponseWriter, _ *http.Request) {
+				io.Copy(w, strings.NewReader(large)) // nolint
+			},
+			expectedSize:      uint64(len(large)),
+			expectedCommitted: true,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			var messages []string
+			mw, err := NewMiddleware(testCtx, test.ResponseSizeWasm,
+				httpwasm.Logger(func(_ context.Context, msg string) {
+					messages = append(messages, msg)
+				}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mw.Close(testCtx)
+
+			serve(t, mw, tc.next, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			// The guest logs the size, followed by whether it was committed.
+			if len(messages) != 1 || len(messages[0]) != 9 {
+				t.Fatalf("expected one message of 9 bytes, have %q", messages)
+			}
+			msg := []byte(messages[0])
+			if have := binary.LittleEndian.Uint64(msg); have != tc.expectedSize {
+				t.Errorf("expected size %d, have %d", tc.expectedSize, have)
+			}
+			if have := msg[8] == 1; have != tc.expectedCommitted {
+				t.Errorf("expected committed %v, have %v", tc.expectedCommitted, have)
+			}
+		})
+	}
+}
+
+func TestStreamingResponse(t *testing.T) {
+	tests := []struct {
+		name            string
+		guest           []byte
+		expectedFlushed bool
+	}{
+		{name: "streaming", guest: test.StreamingWasm, expectedFlushed: true},
+		{name: "buffered", guest: test.ReadResponseBodyWasm},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			mw, err := NewMiddleware(testCtx, tc.guest)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mw.Close(testCtx)
+
+			w := httptest.NewRecorder()
+			var flushed bool
+			next := http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+				rw.Header().Set("Content-Type", "text/event-stream")
+				rw.Write([]byte("data: 1\n\n")) // nolint
+				rw.(http.Flusher).Flush()
+				flushed = w.Flushed
+			})
+
+			h, err := mw.NewHandler(testCtx, next)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer h.Close(testCtx)
+
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if flushed != tc.expectedFlushed {
+				t.Fatalf("expected flushed %v, have %v", tc.expectedFlushed, flushed)
+			}
+		})
+	}
+}
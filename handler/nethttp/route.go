@@ -0,0 +1,15 @@
+package wasm
+
+import "context"
+
+// GetRoute implements the same method as documented on handler.RequestInfo.
+// This is the pattern of an http.ServeMux, so requires Go 1.23, and that the
+// main module doesn't use the ServeMux of Go 1.21 (GODEBUG=httpmuxgo121=1),
+// which is the default when it declares a Go version before 1.22.
+func (h host) GetRoute(ctx context.Context) string {
+	s := requestStateFromContext(ctx)
+	if route := requestPattern(s.request); route != "" {
+		return route // the guest is inside an http.ServeMux.
+	}
+	return s.route
+}
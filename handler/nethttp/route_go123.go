@@ -0,0 +1,11 @@
+//go:build go1.23
+
+package wasm
+
+import "net/http"
+
+// requestPattern returns the pattern of the http.ServeMux route the request
+// matched, or empty if none.
+func requestPattern(r *http.Request) string {
+	return r.Pattern
+}
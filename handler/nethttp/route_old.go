@@ -0,0 +1,10 @@
+//go:build !go1.23
+
+package wasm
+
+import "net/http"
+
+// requestPattern returns empty, as http.Request.Pattern requires Go 1.23.
+func requestPattern(*http.Request) string {
+	return ""
+}
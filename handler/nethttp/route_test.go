@@ -0,0 +1,92 @@
+//go:build go1.23
+
+// ServeMux only supports patterns with methods and wildcards, and sets
+// http.Request.Pattern, when not using the behavior of Go 1.21.
+//go:debug httpmuxgo121=0
+
+package wasm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestGetRoute(t *testing.T) {
+	var messages []string
+	mw, err := NewMiddleware(testCtx, test.RouteWasm,
+		httpwasm.Logger(func(_ context.Context, msg string) {
+			messages = append(messages, msg)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	const pattern = "GET /users/{id}"
+
+	tests := []struct {
+		name     string
+		handler  func(t *testing.T) http.Handler
+		expected []string
+	}{
+		{
+			name: "guest before mux",
+			handler: func(t *testing.T) http.Handler {
+				mux := http.NewServeMux()
+				mux.Handle(pattern, noopHandler)
+				h, err := mw.NewHandler(testCtx, mux)
+				if err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(func() { h.Close(testCtx) })
+				return h
+			},
+			// The route is only known after the mux was invoked.
+			expected: []string{"", pattern},
+		},
+		{
+			name: "guest in mux",
+			handler: func(t *testing.T) http.Handler {
+				h, err := mw.NewHandler(testCtx, noopHandler)
+				if err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(func() { h.Close(testCtx) })
+				mux := http.NewServeMux()
+				mux.Handle(pattern, h)
+				return mux
+			},
+			expected: []string{pattern, pattern},
+		},
+		{
+			name: "no mux",
+			handler: func(t *testing.T) http.Handler {
+				h, err := mw.NewHandler(testCtx, noopHandler)
+				if err != nil {
+					t.Fatal(err)
+				}
+				t.Cleanup(func() { h.Close(testCtx) })
+				return h
+			},
+			expected: []string{"", ""},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			messages = nil
+			h := tc.handler(t)
+			h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/123", nil))
+			if !reflect.DeepEqual(messages, tc.expected) {
+				t.Errorf("expected routes %q, have %q", tc.expected, messages)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package wasm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+)
+
+// Route selects the guest of requests in NewRouterMiddleware.
+type Route struct {
+	// Methods are the HTTP methods of matching requests, or empty for any.
+	// Ex. []string{"POST", "PUT"}
+	Methods []string
+
+	// Pattern is the URL path of matching requests. Like http.ServeMux, a
+	// pattern ending in a slash matches all paths under it. Otherwise, it
+	// only matches the same path. Ex. "/api/" or "/login"
+	//
+	// Paths are cleaned before matching, like http.ServeMux, so that
+	// "//login" and "/a/../login" match "/login" instead of bypassing its
+	// guest. The request isn't changed.
+	Pattern string
+
+	// Guest handles matching requests.
+	Guest []byte
+
+	// Options apply to the guest, after those passed to NewRouterMiddleware.
+	Options []httpwasm.Option
+}
+
+// matches returns true if the request matches the route.
+func (r *Route) matches(req *http.Request) bool {
+	if !matchesAny(r.Methods, req.Method) {
+		return false
+	}
+	p := cleanPath(req.URL.Path)
+	if strings.HasSuffix(r.Pattern, "/") {
+		return strings.HasPrefix(p, r.Pattern)
+	}
+	return p == r.Pattern
+}
+
+// cleanPath returns the canonical path of p, like http.ServeMux: rooted,
+// without "." or ".." elements or repeated slashes, and with the trailing
+// slash, if any.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+	np := path.Clean(p)
+	if p[len(p)-1] == '/' && np != "/" {
+		np += "/"
+	}
+	return np
+}
+
+func matchesAny(values []string, value string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+type router struct {
+	// chain implements the methods of handler.Middleware which apply to all
+	// guests, such as Ping. The guests are not chained.
+	chain
+	routes   []Route
+	runtimes *sharedRuntimes
+}
+
+// NewRouterMiddleware is like NewMiddleware, except it selects the guest of
+// each request by the first route it matches, in order. Requests which match
+// no route bypass Wasm entirely, invoking the next handler directly, so only
+// requests that need a guest pay the cost of running one. The options apply
+// to all guests, which share one wazero runtime, or those configured with
+// httpwasm.Shards, in which case api.ShardPerTenant isolates each pattern.
+func NewRouterMiddleware(ctx context.Context, routes []Route, options ...httpwasm.Option) (Middleware, error) {
+	if len(routes) == 0 {
+		return nil, errors.New("wasm: no routes")
+	}
+	for _, route := range routes {
+		if !strings.HasPrefix(route.Pattern, "/") {
+			return nil, fmt.Errorf("wasm: invalid route pattern %q", route.Pattern)
+		}
+	}
+
+	r, err := newSharedRuntimes(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	rt := &router{routes: routes, runtimes: r}
+	for _, route := range routes {
+		mw, err := NewMiddleware(ctx, route.Guest, r.options(route.Pattern, options, route.Options)...)
+		if err != nil {
+			_ = rt.Close(ctx)
+			return nil, err
+		}
+		rt.middlewares = append(rt.middlewares, mw.(*middleware))
+	}
+	return rt, nil
+}
+
+// NewHandler implements the same method as documented on handler.Middleware.
+func (rt *router) NewHandler(ctx context.Context, next http.Handler) (Handler, error) {
+	h := &routerHandler{routes: rt.routes, next: next}
+	for _, m := range rt.middlewares {
+		g, err := m.NewHandler(ctx, next)
+		if err != nil {
+			_ = h.Close(ctx)
+			return nil, err
+		}
+		h.guests = append(h.guests, g)
+	}
+	return h, nil
+}
+
+// Close implements the same method as documented on handler.Middleware.
+func (rt *router) Close(ctx context.Context) (err error) {
+	err = rt.chain.Close(ctx)
+	if e := rt.runtimes.close(ctx); e != nil {
+		err = e
+	}
+	return
+}
+
+// CloseGracefully implements the same method as documented on
+// handler.GracefulCloser. The timeout applies to all guests.
+func (rt *router) CloseGracefully(ctx context.Context, timeout time.Duration) (err error) {
+	err = rt.chain.CloseGracefully(ctx, timeout)
+	if e := rt.runtimes.close(ctx); e != nil {
+		err = e
+	}
+	return
+}
+
+// compile-time check to ensure routerHandler implements Handler.
+var _ Handler = &routerHandler{}
+
+type routerHandler struct {
+	routes []Route
+	// guests are the handlers of routes, by index.
+	guests []Handler
+	next   http.Handler
+}
+
+// ServeHTTP implements http.Handler
+func (h *routerHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	for i := range h.routes {
+		if h.routes[i].matches(request) {
+			h.guests[i].ServeHTTP(response, request)
+			return
+		}
+	}
+	h.next.ServeHTTP(response, request)
+}
+
+// Close implements api.Closer
+func (h *routerHandler) Close(ctx context.Context) (err error) {
+	for _, g := range h.guests {
+		if e := g.Close(ctx); e != nil {
+			err = e
+		}
+	}
+	return
+}
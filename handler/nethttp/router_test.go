@@ -0,0 +1,87 @@
+package wasm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestRouterMiddleware(t *testing.T) {
+	// The next handler responds with the name of the guest which invoked it.
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if info := FromContext(r.Context()); info != nil {
+			w.Write([]byte(info.Module)) // nolint
+		} else {
+			w.Write([]byte("bypassed")) // nolint
+		}
+	})
+
+	mw, err := NewRouterMiddleware(testCtx, []Route{
+		{Methods: []string{http.MethodPost}, Pattern: "/log/", Guest: test.LogWasm},
+		{Pattern: "/scratch", Guest: test.ScratchWasm},
+		{Pattern: "/", Guest: test.QueryWasm, Methods: []string{http.MethodDelete}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	h, err := mw.NewHandler(testCtx, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close(testCtx)
+
+	tests := []struct {
+		name, method, target, expected string
+	}{
+		{name: "pattern", method: http.MethodPost, target: "/log/a", expected: "log"},
+		{name: "other method", method: http.MethodGet, target: "/log/a", expected: "bypassed"},
+		{name: "exact pattern", method: http.MethodGet, target: "/scratch", expected: "scratch"},
+		{name: "under exact pattern", method: http.MethodGet, target: "/scratch/a", expected: "bypassed"},
+		{name: "first match", method: http.MethodDelete, target: "/scratch", expected: "scratch"},
+		{name: "catch-all", method: http.MethodDelete, target: "/other", expected: "query"},
+		{name: "repeated slash", method: http.MethodGet, target: "//scratch", expected: "scratch"},
+		{name: "dot dot", method: http.MethodGet, target: "/a/../scratch", expected: "scratch"},
+		{name: "repeated slash under pattern", method: http.MethodPost, target: "/log//a", expected: "log"},
+		{name: "trailing slash", method: http.MethodGet, target: "/scratch/", expected: "bypassed"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(tc.method, tc.target, nil))
+
+			if have := w.Body.String(); have != tc.expected {
+				t.Fatalf("expected %q, have %q", tc.expected, have)
+			}
+		})
+	}
+}
+
+func TestRouterMiddleware_Error(t *testing.T) {
+	tests := []struct {
+		name        string
+		routes      []Route
+		expectedErr string
+	}{
+		{name: "no routes", expectedErr: "wasm: no routes"},
+		{
+			name:        "relative pattern",
+			routes:      []Route{{Pattern: "api/", Guest: test.LogWasm}},
+			expectedErr: `wasm: invalid route pattern "api/"`,
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := NewRouterMiddleware(testCtx, tc.routes); err == nil || err.Error() != tc.expectedErr {
+				t.Fatalf("expected error %q, have %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package wasm
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// GetRPC implements the same method as documented on handler.RequestInfo.
+func (h host) GetRPC(ctx context.Context) (service, method string) {
+	return rpcMethod(requestStateFromContext(ctx).request)
+}
+
+// rpcMethod returns the service and method of a gRPC, gRPC-Web or Connect
+// request, or empty strings if the request isn't one.
+//
+// The path of these requests is "/<service>/<method>", possibly after a
+// prefix added by a reverse proxy. Ex. "/acme.v1.Greeter/SayHello"
+func rpcMethod(r *http.Request) (service, method string) {
+	if !isRPC(r) {
+		return "", ""
+	}
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	i := strings.LastIndexByte(path, '/')
+	if i <= 0 {
+		return "", ""
+	}
+	method = path[i+1:]
+	path = path[:i]
+	service = path[strings.LastIndexByte(path, '/')+1:]
+	if service == "" || method == "" {
+		return "", ""
+	}
+	return service, method
+}
+
+// isRPC returns true if the request is of the gRPC, gRPC-Web or Connect
+// protocol, based on its method, content type and headers.
+func isRPC(r *http.Request) bool {
+	contentType := r.Header.Get("Content-Type")
+	switch r.Method {
+	case http.MethodPost:
+		// gRPC and gRPC-Web, including "application/grpc-web-text", or
+		// Connect streaming.
+		if strings.HasPrefix(contentType, "application/grpc") ||
+			strings.HasPrefix(contentType, "application/connect+") {
+			return true
+		}
+		// Connect unary uses plain content types, such as "application/json".
+		return r.Header.Get("Connect-Protocol-Version") != ""
+	case http.MethodGet:
+		// Connect unary allows GET for side effect free methods.
+		return r.URL.Query().Get("connect") == "v1"
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package wasm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestRPC(t *testing.T) {
+	mw, err := NewMiddleware(testCtx, test.RPCWasm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	h, err := mw.NewHandler(testCtx, noopHandler)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close(testCtx)
+
+	tests := []struct {
+		name            string
+		method, target  string
+		header          http.Header
+		expectedService string
+		expectedMethod  string
+	}{
+		{
+			name:            "gRPC",
+			method:          http.MethodPost,
+			target:          "/acme.v1.Greeter/SayHello",
+			header:          http.Header{"Content-Type": {"application/grpc"}},
+			expectedService: "acme.v1.Greeter",
+			expectedMethod:  "SayHello",
+		},
+		{
+			name:            "gRPC-Web text",
+			method:          http.MethodPost,
+			target:          "/acme.v1.Greeter/SayHello",
+			header:          http.Header{"Content-Type": {"application/grpc-web-text"}},
+			expectedService: "acme.v1.Greeter",
+			expectedMethod:  "SayHello",
+		},
+		{
+			name:            "Connect unary",
+			method:          http.MethodPost,
+			target:          "/api/acme.v1.Greeter/SayHello",
+			header:          http.Header{"Content-Type": {"application/json"}, "Connect-Protocol-Version": {"1"}},
+			expectedService: "acme.v1.Greeter",
+			expectedMethod:  "SayHello",
+		},
+		{
+			name:            "Connect streaming",
+			method:          http.MethodPost,
+			target:          "/acme.v1.Greeter/Chat",
+			header:          http.Header{"Content-Type": {"application/connect+proto"}},
+			expectedService: "acme.v1.Greeter",
+			expectedMethod:  "Chat",
+		},
+		{
+			name:            "Connect GET",
+			method:          http.MethodGet,
+			target:          "/acme.v1.Greeter/SayHello?connect=v1&encoding=json&message=%7B%7D",
+			expectedService: "acme.v1.Greeter",
+			expectedMethod:  "SayHello",
+		},
+		{
+			name:   "JSON",
+			method: http.MethodPost,
+			target: "/acme.v1.Greeter/SayHello",
+			header: http.Header{"Content-Type": {"application/json"}},
+		},
+		{
+			name:   "no method",
+			method: http.MethodPost,
+			target: "/acme.v1.Greeter",
+			header: http.Header{"Content-Type": {"application/grpc"}},
+		},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.target, nil)
+			for k, v := range tc.header {
+				req.Header[k] = v
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if have := w.Header().Get("X-RPC-Service"); have != tc.expectedService {
+				t.Errorf("expected service %q, have %q", tc.expectedService, have)
+			}
+			if have := w.Header().Get("X-RPC-Method"); have != tc.expectedMethod {
+				t.Errorf("expected method %q, have %q", tc.expectedMethod, have)
+			}
+		})
+	}
+}
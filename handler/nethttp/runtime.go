@@ -0,0 +1,65 @@
+package wasm
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	internalhandler "github.com/http-wasm/http-wasm-host-go/internal/handler"
+)
+
+// runtimeRef counts the requests using a runtime, so that one replaced by a
+// reload or canary rollout is closed once they complete, instead of blocking
+// the reload until they do.
+type runtimeRef struct {
+	*internalhandler.Runtime
+
+	// refs is the count of requests in flight, and retired is one when the
+	// runtime was replaced or the middleware closed.
+	refs    int64
+	retired uint32
+
+	closeOnce sync.Once
+	// done is closed once the runtime is, and err is the result.
+	done chan struct{}
+	err  error
+}
+
+func newRuntimeRef(r *internalhandler.Runtime) *runtimeRef {
+	return &runtimeRef{Runtime: r, done: make(chan struct{})}
+}
+
+// acquire counts a request using the runtime. This must be called with the
+// read lock of the middleware held, so that the runtime isn't retired
+// meanwhile.
+func (r *runtimeRef) acquire() *runtimeRef {
+	atomic.AddInt64(&r.refs, 1)
+	return r
+}
+
+// release is called when a request counted by acquire completes, closing the
+// runtime if it was the last of a retired runtime. The context of the request
+// may be done, so it isn't used to close the runtime.
+func (r *runtimeRef) release() {
+	if atomic.AddInt64(&r.refs, -1) == 0 && atomic.LoadUint32(&r.retired) == 1 {
+		r.close(context.Background())
+	}
+}
+
+// retire closes the runtime once requests in flight complete. This must be
+// called with the write lock of the middleware held, after the runtime was
+// replaced, so that no request acquires it later.
+func (r *runtimeRef) retire(ctx context.Context) {
+	atomic.StoreUint32(&r.retired, 1)
+	if atomic.LoadInt64(&r.refs) == 0 {
+		r.close(ctx)
+	}
+}
+
+// close closes the runtime, even if requests are in flight, at most once.
+func (r *runtimeRef) close(ctx context.Context) {
+	r.closeOnce.Do(func() {
+		r.err = r.Runtime.Close(ctx)
+		close(r.done)
+	})
+}
@@ -0,0 +1,73 @@
+package wasm
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	internalhandler "github.com/http-wasm/http-wasm-host-go/internal/handler"
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestReload_DoesNotWaitForRequests(t *testing.T) {
+	mw, err := NewMiddleware(testCtx, test.LogWasm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+	m := mw.(*middleware)
+
+	entered, unblock := make(chan struct{}), make(chan struct{})
+	blocking := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		close(entered)
+		<-unblock
+		w.Write([]byte("drained")) // nolint
+	})
+	h, err := mw.NewHandler(testCtx, blocking)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close(testCtx)
+
+	w := httptest.NewRecorder()
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	}()
+	<-entered
+
+	previous := m.runtime
+	r, err := internalhandler.NewRuntime(testCtx, test.LogWasm, &host{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	swapped := make(chan error)
+	go func() { swapped <- m.swapRuntime(testCtx, r) }()
+	select {
+	case err = <-swapped:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the reload not to wait for the request in flight")
+	}
+
+	// New requests use the reloaded runtime while the other is in flight.
+	if have := serve(t, mw, noopHandler, httptest.NewRequest(http.MethodGet, "/", nil)); have != "" {
+		t.Fatalf("expected an empty response, have %q", have)
+	}
+	select {
+	case <-previous.done:
+		t.Fatal("expected the previous runtime to stay open during the request")
+	default:
+	}
+
+	close(unblock)
+	<-served
+	if have := w.Body.String(); have != "drained" {
+		t.Fatalf("expected the request to finish, have %q", have)
+	}
+	<-previous.done // closed by the request
+}
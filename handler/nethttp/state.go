@@ -0,0 +1,89 @@
+package wasm
+
+import (
+	"net/http"
+	"os"
+	"sync"
+
+	internalhandler "github.com/http-wasm/http-wasm-host-go/internal/handler"
+)
+
+// maxPooledScratch is the largest scratch area retained between requests, so
+// that one large request doesn't pin memory in the pool.
+const maxPooledScratch = 64 << 10 // 64 KiB
+
+// maxPooledBody is the largest buffered response body retained between
+// requests, for the same reason as maxPooledScratch.
+const maxPooledBody = 64 << 10 // 64 KiB
+
+// stateBuffers are the buffers a request grew, which are reused by later
+// requests to reduce garbage collection under sustained load.
+//
+// Only buffers user code can't reach are pooled. The requestState, which is
+// the context of the request passed to the next handler, and the
+// responseWriter are allocated per request, as a handler or goroutine may
+// retain them after the request completes.
+type stateBuffers struct {
+	guests       []*internalhandler.Guest
+	scratch      []byte
+	body         []byte
+	beforeCommit []func()
+}
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return &stateBuffers{}
+	},
+}
+
+func newRequestState(response http.ResponseWriter, request *http.Request) *requestState {
+	b := bufferPool.Get().(*stateBuffers)
+	return &requestState{
+		request: request,
+		response: &responseWriter{
+			ResponseWriter: response,
+			body:           b.body,
+			beforeCommit:   b.beforeCommit,
+		},
+		guests:  b.guests,
+		scratch: b.scratch,
+		buffers: b,
+	}
+}
+
+// release returns the buffers of the request to the pool, detaching them
+// from the state and response, so that any retained after the request
+// completes can't affect later requests.
+func (s *requestState) release() {
+	b := s.buffers
+	if b == nil {
+		return // already released
+	}
+	s.buffers = nil
+
+	rw := s.response
+	for i := range rw.beforeCommit {
+		rw.beforeCommit[i] = nil // release closures
+	}
+	b.beforeCommit = rw.beforeCommit[:0]
+	if b.body = rw.body[:0]; cap(b.body) > maxPooledBody {
+		b.body = nil
+	}
+	rw.beforeCommit, rw.body = nil, nil
+
+	for i := range s.guests {
+		s.guests[i] = nil
+	}
+	b.guests = s.guests[:0]
+	if b.scratch = s.scratch[:0]; cap(b.scratch) > maxPooledScratch {
+		b.scratch = nil
+	}
+	s.guests, s.scratch = nil, nil
+
+	if s.spill != nil {
+		s.spill.Close()           // nolint
+		os.Remove(s.spill.Name()) // nolint
+		s.spill, s.spillSize = nil, 0
+	}
+	bufferPool.Put(b)
+}
@@ -0,0 +1,50 @@
+package wasm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestRequestStateNotReused(t *testing.T) {
+	mw, err := NewMiddleware(testCtx, test.HandleRequestWasm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	// The next handler retains the context and writer of each request, as a
+	// goroutine it started might.
+	var ctxs []context.Context
+	var writers []http.ResponseWriter
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Properties(r.Context())["id"] = strconv.Itoa(len(ctxs))
+		ctxs = append(ctxs, r.Context())
+		writers = append(writers, w)
+	})
+	h, err := mw.NewHandler(testCtx, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close(testCtx)
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	second := httptest.NewRecorder()
+	h.ServeHTTP(second, httptest.NewRequest("GET", "/", nil))
+
+	if have := Properties(ctxs[0])["id"]; have != "0" {
+		t.Errorf("expected the first context to keep its properties, have id %q", have)
+	}
+	if writers[0] == writers[1] {
+		t.Fatal("expected a writer per request")
+	}
+	// Writing to a retained writer doesn't affect a later response.
+	writers[0].Write([]byte("late")) // nolint
+	if second.Body.Len() != 0 {
+		t.Errorf("unexpected body of the second response %q", second.Body)
+	}
+}
@@ -0,0 +1,28 @@
+package wasm
+
+import (
+	"context"
+	"net/http"
+)
+
+// GetRequestTrailer implements the same method as documented on
+// handler.Trailers.
+func (h host) GetRequestTrailer(ctx context.Context, name string) (string, bool) {
+	s := requestStateFromContext(ctx)
+	// Trailers are populated once the body was read.
+	s.bufferRequestBody()
+	if values := s.request.Trailer.Values(name); len(values) == 0 {
+		return "", false
+	} else {
+		return values[0], true
+	}
+}
+
+// SetResponseTrailer implements the same method as documented on
+// handler.Trailers.
+func (h host) SetResponseTrailer(ctx context.Context, name, value string) {
+	// The prefix allows setting trailers not declared before the response was
+	// committed.
+	r := requestStateFromContext(ctx).response
+	r.Header().Set(http.TrailerPrefix+name, value)
+}
@@ -0,0 +1,40 @@
+package wasm
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestTrailer(t *testing.T) {
+	mw, err := NewMiddleware(testCtx, test.TrailerWasm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	h, err := mw.NewHandler(testCtx, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body) // nolint
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close(testCtx)
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello"))
+	req.Trailer = http.Header{"Checksum": {"abc"}}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	resp := w.Result()
+	if body, _ := io.ReadAll(resp.Body); string(body) != "hello" {
+		t.Fatalf("expected body %q, have %q", "hello", body)
+	}
+	if expected, have := "abc", resp.Trailer.Get("Checksum"); have != expected {
+		t.Fatalf("expected trailer %q, have %q", expected, have)
+	}
+}
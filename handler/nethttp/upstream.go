@@ -0,0 +1,171 @@
+package wasm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// Upstream returns the upstream guests set via handler.FuncSetUpstream on the
+// current request, or false if none did or the context isn't from a request
+// handled by a guest. Ex. "canary.internal:8080"
+//
+// This is the context of the request passed to the next handler, so that a
+// reverse proxy can send the request to the upstream. NewReverseProxy does
+// this.
+func Upstream(ctx context.Context) (string, bool) {
+	s, ok := ctx.Value(requestStateKey{}).(*requestState)
+	if !ok || s.upstream == "" {
+		return "", false
+	}
+	return s.upstream, true
+}
+
+// RetryPolicy returns the policy guests set via handler.FuncSetRetryPolicy on
+// the current request, or false if none did or the context isn't from a
+// request handled by a guest. Like Upstream, this is for reverse proxies.
+func RetryPolicy(ctx context.Context) (handler.RetryPolicy, bool) {
+	s, ok := ctx.Value(requestStateKey{}).(*requestState)
+	if !ok || s.retryPolicy.Attempts <= 1 {
+		return handler.RetryPolicy{}, false
+	}
+	return s.retryPolicy, true
+}
+
+// NewReverseProxy is like httputil.NewSingleHostReverseProxy, except requests
+// are sent to the Upstream set by the guest, if any, and retried according to
+// its RetryPolicy. Use this as the next handler of a guest which routes
+// requests, such as to canary backends.
+//
+// The scheme and path of the target apply to all requests. The "Host" header
+// isn't changed.
+func NewReverseProxy(target *url.URL) *httputil.ReverseProxy {
+	p := httputil.NewSingleHostReverseProxy(target)
+	director := p.Director
+	p.Director = func(r *http.Request) {
+		director(r)
+		if upstream, ok := Upstream(r.Context()); ok {
+			r.URL.Host = upstream
+		}
+	}
+	p.Transport = &transport{http.DefaultTransport}
+	return p
+}
+
+// transport sends requests of NewReverseProxy, retrying them according to
+// their RetryPolicy.
+type transport struct {
+	http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	policy, _ := RetryPolicy(req.Context())
+	req, cancel := detach(req)
+	resp, err := t.roundTrip(req, policy)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (t *transport) roundTrip(req *http.Request, policy handler.RetryPolicy) (*http.Response, error) {
+	if policy.Attempts <= 1 || !retrySafe(req) {
+		return t.RoundTripper.RoundTrip(req)
+	}
+	for attempt := uint32(1); ; attempt++ {
+		resp, err := t.RoundTripper.RoundTrip(req)
+		if attempt >= policy.Attempts || !retryable(resp, err) {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(policy.Backoff)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// detach returns the request with a context which doesn't refer to the state
+// of the guest, if any, but is canceled in the same way. This is needed as
+// the state is reused after the request completes, while the transport may
+// still use the context, such as in its connection goroutines. The context
+// must be canceled when the response body is closed.
+func detach(req *http.Request) (*http.Request, context.CancelFunc) {
+	s, ok := req.Context().Value(requestStateKey{}).(*requestState)
+	if !ok {
+		return req, func() {}
+	}
+	var ctx context.Context
+	var cancel context.CancelFunc
+	if deadline, ok := req.Context().Deadline(); ok {
+		ctx, cancel = context.WithDeadline(s.Context, deadline)
+	} else {
+		ctx, cancel = context.WithCancel(s.Context)
+	}
+	return req.WithContext(ctx), cancel
+}
+
+// cancelBody cancels the context of the request when the body is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close implements io.Closer
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// retrySafe returns true if the request can be sent again, as its method is
+// idempotent and it has no body to replay.
+func retrySafe(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return req.Body == nil || req.Body == http.NoBody
+	}
+	return false
+}
+
+// retryable returns true if the upstream failed in a way another attempt may
+// not, such as a connection error or 503 Service Unavailable.
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	switch resp.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// SetUpstream implements the same method as documented on handler.Proxy.
+func (h host) SetUpstream(ctx context.Context, upstream string) {
+	requestStateFromContext(ctx).upstream = upstream
+}
+
+// SetTimeout implements the same method as documented on handler.Proxy.
+func (h host) SetTimeout(ctx context.Context, timeout time.Duration) {
+	requestStateFromContext(ctx).timeout = timeout
+}
+
+// SetRetryPolicy implements the same method as documented on handler.Proxy.
+func (h host) SetRetryPolicy(ctx context.Context, policy handler.RetryPolicy) {
+	requestStateFromContext(ctx).retryPolicy = policy
+}
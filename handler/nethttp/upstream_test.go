@@ -0,0 +1,146 @@
+package wasm
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestSetUpstream(t *testing.T) {
+	backend := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Write([]byte(name)) // nolint
+		}))
+	}
+	stable, canary := backend("stable"), backend("canary")
+	defer stable.Close()
+	defer canary.Close()
+
+	target, err := url.Parse(stable.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxy := NewReverseProxy(target)
+
+	tests := []struct {
+		name           string
+		guestConfig    string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "default", expectedStatus: http.StatusOK, expectedBody: "stable"},
+		{name: "upstream", guestConfig: canary.Listener.Addr().String(), expectedStatus: http.StatusOK, expectedBody: "canary"},
+		{name: "invalid upstream", guestConfig: "canary/path", expectedStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			mw, err := NewMiddleware(testCtx, test.UpstreamWasm, httpwasm.GuestConfigBytes([]byte(tc.guestConfig)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer mw.Close(testCtx)
+
+			h, err := mw.NewHandler(testCtx, proxy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer h.Close(testCtx)
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != tc.expectedStatus {
+				t.Fatalf("expected status %d, have %d", tc.expectedStatus, w.Code)
+			}
+			if tc.expectedBody != "" && w.Body.String() != tc.expectedBody {
+				t.Fatalf("expected body %q, have %q", tc.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestSetTimeout(t *testing.T) {
+	mw, err := NewMiddleware(testCtx, test.PolicyWasm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	// The guest sets a timeout of 50ms.
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, ok := r.Context().Deadline()
+		if !ok || time.Until(deadline) > 50*time.Millisecond {
+			t.Errorf("expected a deadline within 50ms, have %v", deadline)
+		}
+		<-r.Context().Done()
+		w.Write([]byte(r.Context().Err().Error())) // nolint
+	})
+	if body := serve(t, mw, next, httptest.NewRequest(http.MethodGet, "/", nil)); body != context.DeadlineExceeded.Error() {
+		t.Fatalf("expected body %q, have %q", context.DeadlineExceeded.Error(), body)
+	}
+}
+
+func TestSetRetryPolicy(t *testing.T) {
+	// The backend fails until the third attempt, which is the last the guest
+	// allows.
+	var attempts int32
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok")) // nolint
+	}))
+	defer backend.Close()
+
+	target, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mw, err := NewMiddleware(testCtx, test.PolicyWasm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mw.Close(testCtx)
+
+	tests := []struct {
+		name             string
+		method           string
+		expectedStatus   int
+		expectedAttempts int32
+	}{
+		{name: "retried", method: http.MethodGet, expectedStatus: http.StatusOK, expectedAttempts: 3},
+		{name: "not idempotent", method: http.MethodPost, expectedStatus: http.StatusServiceUnavailable, expectedAttempts: 1},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			atomic.StoreInt32(&attempts, 0)
+
+			h, err := mw.NewHandler(testCtx, NewReverseProxy(target))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer h.Close(testCtx)
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(tc.method, "/", nil))
+			if w.Code != tc.expectedStatus {
+				t.Fatalf("expected status %d, have %d", tc.expectedStatus, w.Code)
+			}
+			if have := atomic.LoadInt32(&attempts); have != tc.expectedAttempts {
+				t.Fatalf("expected %d attempts, have %d", tc.expectedAttempts, have)
+			}
+		})
+	}
+}
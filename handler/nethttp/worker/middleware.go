@@ -0,0 +1,277 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	apihandler "github.com/http-wasm/http-wasm-host-go/api/handler"
+	wasm "github.com/http-wasm/http-wasm-host-go/handler/nethttp"
+	"github.com/http-wasm/http-wasm-host-go/internal/inflight"
+)
+
+const (
+	// startTimeout is how long to wait for a worker to listen.
+	startTimeout = 10 * time.Second
+
+	// restartDelay is how long to wait before restarting a worker that
+	// exited, to avoid spinning on one that fails on start.
+	restartDelay = 100 * time.Millisecond
+)
+
+// compile-time check to ensure middleware implements wasm.Middleware.
+var _ wasm.Middleware = &middleware{}
+
+type middleware struct {
+	dir     string
+	workers []*process
+	// counter rotates requests across workers.
+	counter uint32
+	// inflight counts requests, for CloseGracefully.
+	inflight inflight.Counter
+}
+
+// NewMiddleware starts a pool of worker processes, each from a new command,
+// and returns middleware which forwards requests to them. The command must
+// call Serve with the guest. Workers that exit are restarted until Close.
+//
+// For example, if the program calls Serve when its first argument is
+// "worker":
+//
+//	mw, err := worker.NewMiddleware(ctx, func() *exec.Cmd {
+//		return exec.Command(os.Args[0], "worker")
+//	}, 4)
+func NewMiddleware(ctx context.Context, command func() *exec.Cmd, size int) (wasm.Middleware, error) {
+	if size < 1 {
+		return nil, errors.New("worker: size must be at least one")
+	}
+	dir, err := os.MkdirTemp("", "httpwasm-worker")
+	if err != nil {
+		return nil, err
+	}
+
+	m := &middleware{dir: dir}
+	for i := 0; i < size; i++ {
+		p := &process{command: command, socket: filepath.Join(dir, strconv.Itoa(i)+".sock")}
+		p.transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", p.socket)
+			},
+		}
+		m.workers = append(m.workers, p)
+		if err = p.start(); err != nil {
+			_ = m.Close(ctx)
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// compile-time check to ensure middleware implements the optional interfaces
+// which don't need the guest, as only workers load it.
+var (
+	_ apihandler.Pinger         = &middleware{}
+	_ apihandler.GracefulCloser = &middleware{}
+)
+
+// NewHandler implements the same method as documented on handler.Middleware.
+func (m *middleware) NewHandler(_ context.Context, next http.Handler) (wasm.Handler, error) {
+	return &handler{m: m, next: next}, nil
+}
+
+// Ping implements the same method as documented on handler.Pinger,
+// returning an error unless all workers are listening.
+func (m *middleware) Ping(ctx context.Context) error {
+	var d net.Dialer
+	for _, p := range m.workers {
+		conn, err := d.DialContext(ctx, "unix", p.socket)
+		if err != nil {
+			return fmt.Errorf("worker: not listening: %w", err)
+		}
+		conn.Close()
+	}
+	return nil
+}
+
+// Close implements the same method as documented on handler.Middleware.
+func (m *middleware) Close(context.Context) error {
+	for _, p := range m.workers {
+		p.stop()
+	}
+	return os.RemoveAll(m.dir)
+}
+
+// CloseGracefully implements the same method as documented on
+// handler.GracefulCloser.
+func (m *middleware) CloseGracefully(ctx context.Context, timeout time.Duration) error {
+	m.inflight.Wait(ctx, timeout)
+	return m.Close(ctx)
+}
+
+// process is a worker process, which is replaced when it exits.
+type process struct {
+	command   func() *exec.Cmd
+	socket    string
+	transport *http.Transport
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.Closer
+	exited chan struct{}
+	closed bool
+}
+
+// start starts the worker and waits until it listens on its socket.
+func (p *process) start() error {
+	_ = os.Remove(p.socket)
+
+	cmd := p.command()
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, EnvSocket+"="+p.socket)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err = cmd.Start(); err != nil {
+		return fmt.Errorf("worker: error starting: %w", err)
+	}
+
+	exited := make(chan struct{})
+	p.mu.Lock()
+	p.cmd, p.stdin, p.exited = cmd, stdin, exited
+	if p.closed { // stopped while restarting
+		_ = stdin.Close()
+	}
+	p.mu.Unlock()
+	go p.wait(cmd, exited)
+
+	for deadline := time.Now().Add(startTimeout); ; {
+		if conn, err := net.Dial("unix", p.socket); err == nil {
+			conn.Close()
+			return nil
+		}
+		select {
+		case <-exited:
+			return errors.New("worker: exited before listening")
+		case <-time.After(10 * time.Millisecond):
+		}
+		if time.Now().After(deadline) {
+			return errors.New("worker: timed out waiting to listen")
+		}
+	}
+}
+
+// wait restarts the worker when it exits, unless it was stopped.
+func (p *process) wait(cmd *exec.Cmd, exited chan struct{}) {
+	_ = cmd.Wait()
+	close(exited)
+	p.transport.CloseIdleConnections()
+
+	for {
+		p.mu.Lock()
+		closed := p.closed
+		p.mu.Unlock()
+		if closed {
+			return
+		}
+		time.Sleep(restartDelay)
+		if err := p.start(); err == nil {
+			return // the new process has its own wait.
+		}
+	}
+}
+
+// stop signals the worker to exit by closing its stdin, and kills it if it
+// doesn't exit in time.
+func (p *process) stop() {
+	p.mu.Lock()
+	p.closed = true
+	stdin, cmd, exited := p.stdin, p.cmd, p.exited
+	p.mu.Unlock()
+
+	if cmd == nil {
+		return
+	}
+	_ = stdin.Close()
+	select {
+	case <-exited:
+	case <-time.After(startTimeout):
+		_ = cmd.Process.Kill()
+		<-exited
+	}
+	p.transport.CloseIdleConnections()
+}
+
+// compile-time check to ensure handler implements wasm.Handler.
+var _ wasm.Handler = &handler{}
+
+type handler struct {
+	m    *middleware
+	next http.Handler
+}
+
+// ServeHTTP implements http.Handler
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.m.inflight.Add()
+	defer h.m.inflight.Done()
+
+	i := atomic.AddUint32(&h.m.counter, 1)
+	p := h.m.workers[int(i)%len(h.m.workers)]
+
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	out.URL.Scheme, out.URL.Host = "http", "worker"
+	out.Header.Set(headerRemoteAddr, r.RemoteAddr)
+
+	resp, err := p.transport.RoundTrip(out)
+	if err != nil {
+		http.Error(w, "worker: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.Header.Get(headerNext) == "" { // the guest sent a response.
+		copyHeader(w.Header(), resp.Header)
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body) // nolint
+		return
+	}
+
+	next, err := readNext(resp.Body)
+	if err != nil {
+		http.Error(w, "worker: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	for _, k := range []string{headerNext, "Content-Type", "Content-Length", "Date"} {
+		resp.Header.Del(k)
+	}
+	copyHeader(w.Header(), resp.Header)
+
+	next = next.WithContext(r.Context())
+	next.RemoteAddr, next.TLS = r.RemoteAddr, r.TLS
+	h.next.ServeHTTP(w, next)
+}
+
+// Close implements api.Closer
+func (h *handler) Close(context.Context) error {
+	return nil
+}
+
+func copyHeader(dst, src http.Header) {
+	for k, v := range src {
+		dst[k] = v
+	}
+}
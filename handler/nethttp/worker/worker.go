@@ -0,0 +1,131 @@
+// Package worker runs guests in separate processes, which isolates failures
+// such as exhausting native memory from the server, and allows restarting the
+// guests independently of it.
+//
+// The server calls NewMiddleware with a command that starts a worker, which
+// is usually the same program with a flag that makes it call Serve. Requests
+// are forwarded to workers over a unix socket, and the guest runs in the
+// worker with the same ABI as in process.
+//
+// # Notes
+//
+// This is experimental, and has the following limitations:
+//   - The next handler runs in the server after the guest returns, so the
+//     guest sees an empty response after handler.FuncNext, and can only add
+//     response headers.
+//   - The guest sees the request as received by the server, except
+//     connection details such as TLS.
+package worker
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	wasm "github.com/http-wasm/http-wasm-host-go/handler/nethttp"
+	"github.com/http-wasm/http-wasm-host-go/internal/idle"
+)
+
+// EnvSocket is the environment variable holding the path of the unix socket
+// a worker serves on, set by NewMiddleware.
+const EnvSocket = "HTTPWASM_WORKER_SOCKET"
+
+const (
+	// headerNext marks a response from a worker whose body is the request
+	// to pass to the next handler.
+	headerNext = "Httpwasm-Worker-Next"
+
+	// headerRemoteAddr propagates http.Request RemoteAddr to the worker.
+	headerRemoteAddr = "Httpwasm-Worker-Remote-Addr"
+)
+
+// Serve runs the guest in a worker process started by NewMiddleware, until
+// the context is done or the server exits. The options are those of
+// wasm.NewMiddleware.
+func Serve(ctx context.Context, guest []byte, options ...httpwasm.Option) error {
+	path := os.Getenv(EnvSocket)
+	if path == "" {
+		return errors.New("worker: " + EnvSocket + " isn't set")
+	}
+
+	mw, err := wasm.NewMiddleware(ctx, guest, options...)
+	if err != nil {
+		return err
+	}
+	defer mw.Close(ctx)
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The server holds the write end of stdin, so it is closed when the
+	// server closes the middleware or exits.
+	go func() {
+		io.Copy(io.Discard, os.Stdin) // nolint
+		cancel()
+	}()
+
+	handlers := &handlerPool{}
+	handlers.New = func(ctx context.Context) (idle.Handler, error) {
+		return mw.NewHandler(ctx, http.HandlerFunc(forwardNext))
+	}
+	defer handlers.Close(ctx) // nolint
+	srv := &http.Server{Handler: handlers}
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+
+	if err = srv.Serve(ln); errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// handlerPool serves requests with idle handlers, as a wasm.Handler isn't safe
+// for concurrent use.
+type handlerPool struct {
+	idle.Pool
+}
+
+// ServeHTTP implements http.Handler
+func (p *handlerPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if addr := r.Header.Get(headerRemoteAddr); addr != "" {
+		r.RemoteAddr = addr
+		r.Header.Del(headerRemoteAddr)
+	}
+
+	h, err := p.Get(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h.ServeHTTP(w, r)
+	p.Put(h)
+}
+
+// forwardNext is the next handler of the guest in the worker, which responds
+// with the request, so that the server can pass it to its next handler.
+func forwardNext(w http.ResponseWriter, r *http.Request) {
+	// Prevent Write from adding a default User-Agent.
+	if _, ok := r.Header["User-Agent"]; !ok {
+		r.Header["User-Agent"] = []string{""}
+	}
+	w.Header().Set(headerNext, "1")
+	w.Header().Set("Content-Type", "application/http")
+	r.Write(w) // nolint
+}
+
+// readNext reads the request written by forwardNext.
+func readNext(body io.Reader) (*http.Request, error) {
+	return http.ReadRequest(bufio.NewReader(body))
+}
@@ -0,0 +1,175 @@
+// Package plugin builds middleware from plain configuration, so that proxy
+// plugins, such as for Traefik or Caddy, can embed guests with little glue.
+//
+// New has the same signature as a Traefik plugin, so a plugin can delegate
+// to it with its own Config:
+//
+//	func CreateConfig() *plugin.Config {
+//		return plugin.CreateConfig()
+//	}
+//
+//	func New(ctx context.Context, next http.Handler, config *plugin.Config, name string) (http.Handler, error) {
+//		return plugin.New(ctx, next, config, name)
+//	}
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/api"
+	"github.com/http-wasm/http-wasm-host-go/guestfetch"
+	wasm "github.com/http-wasm/http-wasm-host-go/handler/nethttp"
+	"github.com/http-wasm/http-wasm-host-go/internal/idle"
+)
+
+// Config is the configuration of a guest, with JSON field names for plugins
+// configured in JSON or YAML.
+type Config struct {
+	// Guest is the path of the guest binary, or a URL beginning with
+	// "http://" or "https://" to download it from.
+	Guest string `json:"guest"`
+
+	// GuestDigest optionally pins the content of a downloaded guest. Ex.
+	// "sha256:" followed by lowercase hex
+	GuestDigest string `json:"guestDigest,omitempty"`
+
+	// GuestConfig is what the guest reads via handler.FuncGetConfig.
+	GuestConfig string `json:"guestConfig,omitempty"`
+
+	// PoolSize is the maximum count of guests handling requests concurrently.
+	// Requests wait for a guest when all are busy. Zero is unbounded.
+	PoolSize int `json:"poolSize,omitempty"`
+
+	// FetchTimeout bounds downloading the guest, in time.ParseDuration
+	// format. Ex. "30s"
+	FetchTimeout string `json:"fetchTimeout,omitempty"`
+
+	// HTTPCallTimeout is the same as httpwasm.HTTPCallTimeout, in
+	// time.ParseDuration format.
+	HTTPCallTimeout string `json:"httpCallTimeout,omitempty"`
+
+	// HTTPCallHosts is the same as httpwasm.HTTPCallHosts.
+	HTTPCallHosts []string `json:"httpCallHosts,omitempty"`
+}
+
+// CreateConfig returns the default configuration.
+func CreateConfig() *Config {
+	return &Config{}
+}
+
+// New returns a handler which runs the guest in the configuration before
+// next. The name identifies the middleware in errors and guest logs.
+//
+// The result implements api.Closer, to release the guests when the plugin is
+// unloaded.
+func New(ctx context.Context, next http.Handler, config *Config, name string) (http.Handler, error) {
+	guest, options, err := load(ctx, config, name)
+	if err != nil {
+		return nil, fmt.Errorf("wasm: %s: %w", name, err)
+	}
+
+	mw, err := wasm.NewMiddleware(ctx, guest, options...)
+	if err != nil {
+		return nil, fmt.Errorf("wasm: %s: %w", name, err)
+	}
+	h := &handler{mw: mw}
+	h.pool.New = func(ctx context.Context) (idle.Handler, error) {
+		return mw.NewHandler(ctx, next)
+	}
+	if config.PoolSize > 0 {
+		h.sem = make(chan struct{}, config.PoolSize)
+	}
+	return h, nil
+}
+
+// load returns the guest binary and the options of the configuration.
+func load(ctx context.Context, config *Config, name string) ([]byte, []httpwasm.Option, error) {
+	if config.Guest == "" {
+		return nil, nil, errors.New("guest not configured")
+	}
+	if config.PoolSize < 0 {
+		return nil, nil, fmt.Errorf("invalid poolSize: %d", config.PoolSize)
+	}
+
+	options := []httpwasm.Option{
+		httpwasm.Logger(func(_ context.Context, msg string) {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", name, msg)
+		}),
+	}
+	if config.GuestConfig != "" {
+		options = append(options, httpwasm.GuestConfigBytes([]byte(config.GuestConfig)))
+	}
+	if len(config.HTTPCallHosts) > 0 {
+		options = append(options, httpwasm.HTTPCallHosts(config.HTTPCallHosts...))
+	}
+	if config.HTTPCallTimeout != "" {
+		timeout, err := time.ParseDuration(config.HTTPCallTimeout)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid httpCallTimeout: %w", err)
+		}
+		options = append(options, httpwasm.HTTPCallTimeout(timeout))
+	}
+
+	if !strings.HasPrefix(config.Guest, "http://") && !strings.HasPrefix(config.Guest, "https://") {
+		guest, err := os.ReadFile(config.Guest)
+		return guest, options, err
+	}
+
+	if config.FetchTimeout != "" {
+		timeout, err := time.ParseDuration(config.FetchTimeout)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid fetchTimeout: %w", err)
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	var fetchOptions []guestfetch.Option
+	if config.GuestDigest != "" {
+		fetchOptions = append(fetchOptions, guestfetch.WithDigest(config.GuestDigest))
+	}
+	guest, err := guestfetch.FetchHTTP(ctx, config.Guest, fetchOptions...)
+	return guest, options, err
+}
+
+// compile-time check to ensure handler implements api.Closer.
+var _ api.Closer = &handler{}
+
+// handler serves requests with idle guests, as a wasm.Handler isn't safe for
+// concurrent use.
+type handler struct {
+	mw wasm.Middleware
+	// sem bounds the count of guests in use, or is nil if unbounded.
+	sem  chan struct{}
+	pool idle.Pool
+}
+
+// ServeHTTP implements http.Handler
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.sem != nil {
+		select {
+		case h.sem <- struct{}{}:
+			defer func() { <-h.sem }()
+		case <-r.Context().Done():
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+	}
+	h.pool.ServeHTTP(w, r)
+}
+
+// Close implements api.Closer
+func (h *handler) Close(ctx context.Context) (err error) {
+	err = h.pool.Close(ctx)
+	if e := h.mw.Close(ctx); e != nil {
+		err = e
+	}
+	return
+}
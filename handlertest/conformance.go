@@ -0,0 +1,382 @@
+package handlertest
+
+import (
+	"context"
+	_ "embed"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	internalhandler "github.com/http-wasm/http-wasm-host-go/internal/handler"
+)
+
+// conformanceWasm reads the request header "X-Value" with a buffer too small
+// for values over 4 bytes, retries with a larger one, and echoes the value as
+// the response header "X-Echo".
+//
+//go:embed testdata/conformance.wasm
+var conformanceWasm []byte
+
+// NewHostFunc returns a handler.Host handling the request, and the context to
+// pass to its methods. The host must invoke next when the guest calls the
+// next handler. The returned function is called after the guest returns, and
+// returns the response the client received.
+//
+// This can't be a function returning only the host, as handler.Host has no
+// methods to set the request or read back the response.
+type NewHostFunc func(t *testing.T, req *http.Request, next http.Handler) (context.Context, handler.Host, func() *http.Response)
+
+// HostTest verifies a handler.Host implements the contracts of the ABI, so
+// that third-party hosts, such as for fasthttp or a proxy, behave the same
+// as this project's. Optional interfaces of handler.Host, such as
+// handler.ConnectionInfo, are only verified if the host implements them.
+// Call it from a test:
+//
+//	func TestHost(t *testing.T) {
+//		handlertest.HostTest(t, newHost)
+//	}
+func HostTest(t *testing.T, newHost NewHostFunc) {
+	tests := []hostTest{
+		{
+			name: "missing values",
+			test: func(t *testing.T, ctx context.Context, h handler.Host) {
+				if v, ok := h.GetRequestHeader(ctx, "X-Missing"); ok || v != "" {
+					t.Errorf("GetRequestHeader: expected missing, have %q, %v", v, ok)
+				}
+				if v, ok := h.GetQueryParams(ctx)["missing"]; ok {
+					t.Errorf("GetQueryParams: expected missing, have %q", v)
+				}
+				if v, ok := h.GetRequestHeaders(ctx)["Cookie"]; ok {
+					t.Errorf("GetRequestHeaders: expected no cookies, have %q", v)
+				}
+				if p, ok := h.(handler.Properties); ok {
+					if v, ok := p.GetProperties(ctx)["missing"]; ok {
+						t.Errorf("GetProperties: expected missing, have %q", v)
+					}
+				}
+				if s, ok := h.(handler.Scratch); ok {
+					if v := s.ReadScratch(ctx); len(v) != 0 {
+						t.Errorf("ReadScratch: expected empty, have %q", v)
+					}
+				}
+			},
+		},
+		{
+			name: "header values",
+			req: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header["X-Empty"] = []string{""}
+				req.Header["X-Multi"] = []string{"1", "2"}
+				req.Header.Set("X-Unicode", "héllo, 世界")
+				return req
+			},
+			test: func(t *testing.T, ctx context.Context, h handler.Host) {
+				for _, tc := range []struct{ name, expected string }{
+					{name: "x-empty", expected: ""},
+					{name: "X-Multi", expected: "1"},
+					{name: "x-unicode", expected: "héllo, 世界"},
+				} {
+					if v, ok := h.GetRequestHeader(ctx, tc.name); !ok || v != tc.expected {
+						t.Errorf("GetRequestHeader(%q): expected %q, have %q, %v", tc.name, tc.expected, v, ok)
+					}
+				}
+				if v := h.GetRequestHeaders(ctx)["X-Multi"]; len(v) != 2 || v[0] != "1" || v[1] != "2" {
+					t.Errorf("GetRequestHeaders: expected X-Multi [1 2], have %q", v)
+				}
+				h.SetResponseHeader(ctx, "X-Unicode", "héllo, 世界")
+				h.Next(ctx)
+			},
+			expect: func(t *testing.T, resp *http.Response, _ string) {
+				if v := resp.Header.Get("X-Unicode"); v != "héllo, 世界" {
+					t.Errorf("expected response header %q, have %q", "héllo, 世界", v)
+				}
+			},
+		},
+		{
+			name: "query and cookie",
+			req: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/?a=1&a=2", nil)
+				req.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+				return req
+			},
+			next: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(r.URL.Query().Get("b"))) // nolint
+			},
+			test: func(t *testing.T, ctx context.Context, h handler.Host) {
+				if v := h.GetQueryParams(ctx)["a"]; len(v) != 2 || v[0] != "1" || v[1] != "2" {
+					t.Errorf("GetQueryParams: expected a [1 2], have %q", v)
+				}
+				if v := h.GetRequestHeaders(ctx)["Cookie"]; len(v) != 1 || v[0] != "session=abc" {
+					t.Errorf("GetRequestHeaders: expected Cookie [session=abc], have %q", v)
+				}
+				h.SetQueryValue(ctx, "b", "ü")
+				h.Next(ctx)
+			},
+			expect: func(t *testing.T, _ *http.Response, body string) {
+				if body != "ü" {
+					t.Errorf("expected next handler to read query %q, have %q", "ü", body)
+				}
+			},
+		},
+		{
+			name: "empty request body",
+			req: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/", http.NoBody)
+			},
+			test: func(t *testing.T, ctx context.Context, h handler.Host) {
+				n := h.ReadRequestBody(ctx, func(size uint32) []byte {
+					t.Errorf("ReadRequestBody: unexpected alloc(%d)", size)
+					return make([]byte, size)
+				})
+				if n != 0 {
+					t.Errorf("ReadRequestBody: expected 0, have %d", n)
+				}
+				if v := h.GetRequestBody(ctx); len(v) != 0 {
+					t.Errorf("GetRequestBody: expected empty, have %q", v)
+				}
+				h.Next(ctx)
+			},
+		},
+		{
+			name: "request body",
+			req: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello"))
+			},
+			next: func(w http.ResponseWriter, r *http.Request) {
+				io.Copy(w, r.Body) // nolint
+			},
+			test: func(t *testing.T, ctx context.Context, h handler.Host) {
+				if v := h.GetRequestBodySize(ctx); v != 5 {
+					t.Errorf("GetRequestBodySize: expected 5, have %d", v)
+				}
+				if c, ok := h.(handler.ConnectionInfo); ok && c.IsRequestChunked(ctx) {
+					t.Error("IsRequestChunked: expected false")
+				}
+				if v := h.GetRequestBody(ctx); string(v) != "hello" {
+					t.Errorf("GetRequestBody: expected %q, have %q", "hello", v)
+				}
+				h.Next(ctx)
+			},
+			expect: func(t *testing.T, _ *http.Response, body string) {
+				if body != "hello" {
+					t.Errorf("expected next handler to read body %q, have %q", "hello", body)
+				}
+			},
+		},
+		{
+			name: "chunked request body",
+			req: func() *http.Request {
+				req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello"))
+				req.ContentLength = -1
+				req.TransferEncoding = []string{"chunked"}
+				return req
+			},
+			test: func(t *testing.T, ctx context.Context, h handler.Host) {
+				if v := h.GetRequestBodySize(ctx); v != -1 {
+					t.Errorf("GetRequestBodySize: expected -1, have %d", v)
+				}
+				if c, ok := h.(handler.ConnectionInfo); ok && !c.IsRequestChunked(ctx) {
+					t.Error("IsRequestChunked: expected true")
+				}
+			},
+		},
+		{
+			name: "next",
+			next: func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("X-Next", "1")
+				w.WriteHeader(http.StatusCreated)
+				w.Write([]byte("next")) // nolint
+			},
+			test: func(t *testing.T, ctx context.Context, h handler.Host) {
+				h.SetResponseHeader(ctx, "X-Guest", "1")
+				h.Next(ctx)
+				if v := h.GetStatusCode(ctx); v != http.StatusCreated {
+					t.Errorf("GetStatusCode: expected %d, have %d", http.StatusCreated, v)
+				}
+			},
+			expect: func(t *testing.T, resp *http.Response, body string) {
+				if resp.StatusCode != http.StatusCreated || body != "next" {
+					t.Errorf("expected %d %q, have %d %q", http.StatusCreated, "next", resp.StatusCode, body)
+				}
+				if resp.Header.Get("X-Next") != "1" || resp.Header.Get("X-Guest") != "1" {
+					t.Errorf("expected headers of the guest and next handler, have %v", resp.Header)
+				}
+			},
+		},
+		{
+			name: "send response",
+			next: func(w http.ResponseWriter, _ *http.Request) {
+				w.Write([]byte("next")) // nolint
+			},
+			test: func(t *testing.T, ctx context.Context, h handler.Host) {
+				h.SendResponse(ctx, http.StatusTeapot, []byte("teapot"))
+				if !h.IsResponseCommitted(ctx) {
+					t.Error("IsResponseCommitted: expected true after SendResponse")
+				}
+				if v := h.GetStatusCode(ctx); v != http.StatusTeapot {
+					t.Errorf("GetStatusCode: expected %d, have %d", http.StatusTeapot, v)
+				}
+				if v := h.GetResponseBodySize(ctx); v != uint64(len("teapot")) {
+					t.Errorf("GetResponseBodySize: expected %d, have %d", len("teapot"), v)
+				}
+			},
+			expect: func(t *testing.T, resp *http.Response, body string) {
+				if resp.StatusCode != http.StatusTeapot || body != "teapot" {
+					t.Errorf("expected %d %q, have %d %q", http.StatusTeapot, "teapot", resp.StatusCode, body)
+				}
+			},
+		},
+		{
+			name: "send empty response",
+			test: func(t *testing.T, ctx context.Context, h handler.Host) {
+				h.SendResponse(ctx, http.StatusNoContent, nil)
+			},
+			expect: func(t *testing.T, resp *http.Response, body string) {
+				if resp.StatusCode != http.StatusNoContent || body != "" {
+					t.Errorf("expected %d %q, have %d %q", http.StatusNoContent, "", resp.StatusCode, body)
+				}
+			},
+		},
+		{
+			name: "protocol version",
+			test: func(t *testing.T, ctx context.Context, h handler.Host) {
+				c, ok := h.(handler.ConnectionInfo)
+				if !ok {
+					t.Skip("host doesn't implement handler.ConnectionInfo")
+				}
+				if v := c.GetProtocolVersion(ctx); v != "HTTP/1.1" {
+					t.Errorf("GetProtocolVersion: expected %q, have %q", "HTTP/1.1", v)
+				}
+			},
+		},
+		{
+			name: "not an rpc",
+			test: func(t *testing.T, ctx context.Context, h handler.Host) {
+				i, ok := h.(handler.RequestInfo)
+				if !ok {
+					t.Skip("host doesn't implement handler.RequestInfo")
+				}
+				if service, method := i.GetRPC(ctx); service != "" || method != "" {
+					t.Errorf("GetRPC: expected empty, have %q, %q", service, method)
+				}
+			},
+		},
+		{
+			name: "scratch",
+			test: func(t *testing.T, ctx context.Context, h handler.Host) {
+				s, ok := h.(handler.Scratch)
+				if !ok {
+					t.Skip("host doesn't implement handler.Scratch")
+				}
+				s.WriteScratch(ctx, []byte("scratch"))
+				if v := s.ReadScratch(ctx); string(v) != "scratch" {
+					t.Errorf("ReadScratch: expected %q, have %q", "scratch", v)
+				}
+			},
+		},
+		{
+			name: "properties",
+			test: func(t *testing.T, ctx context.Context, h handler.Host) {
+				p, ok := h.(handler.Properties)
+				if !ok {
+					t.Skip("host doesn't implement handler.Properties")
+				}
+				p.SetProperty(ctx, "key", "値")
+				if v := p.GetProperties(ctx); len(v) != 1 || v["key"] != "値" {
+					t.Errorf("GetProperties: expected map[key:値], have %v", v)
+				}
+			},
+		},
+	}
+
+	// Buffer retries are implemented by the runtime, so need a guest. The
+	// values are at, below and above the size of its first buffer.
+	for _, v := range []struct{ name, value string }{
+		{name: "guest reads missing header"},
+		{name: "guest reads short header", value: "ab"},
+		{name: "guest reads header of buffer size", value: "abcd"},
+		{name: "guest reads header larger than buffer", value: "héllo"},
+		{name: "guest reads long header", value: strings.Repeat("世界", 100)},
+	} {
+		value := v.value
+		tests = append(tests, hostTest{
+			name: v.name,
+			req: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				if value != "" {
+					req.Header.Set("X-Value", value)
+				}
+				return req
+			},
+			test: func(t *testing.T, ctx context.Context, h handler.Host) {
+				runConformanceGuest(t, ctx, h)
+			},
+			expect: func(t *testing.T, resp *http.Response, _ string) {
+				if v := resp.Header.Get("X-Echo"); v != value {
+					t.Errorf("expected X-Echo %q, have %q", value, v)
+				}
+			},
+		})
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tc.req != nil {
+				req = tc.req()
+			}
+			next := tc.next
+			if next == nil {
+				next = func(http.ResponseWriter, *http.Request) {}
+			}
+
+			ctx, h, result := newHost(t, req, next)
+			tc.test(t, ctx, h)
+			resp := result()
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tc.expect != nil {
+				tc.expect(t, resp, string(body))
+			}
+		})
+	}
+}
+
+type hostTest struct {
+	name string
+	// req returns the request, defaulting to a GET without headers.
+	req func() *http.Request
+	// next is the next handler, defaulting to one which responds 200.
+	next http.HandlerFunc
+	// test calls the host, as a guest would.
+	test func(t *testing.T, ctx context.Context, h handler.Host)
+	// expect, if not nil, verifies the response the client received.
+	expect func(t *testing.T, resp *http.Response, body string)
+}
+
+// runConformanceGuest handles the request of the host with conformanceWasm.
+func runConformanceGuest(t *testing.T, ctx context.Context, h handler.Host) {
+	r, err := internalhandler.NewRuntime(ctx, conformanceWasm, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close(ctx)
+
+	g, err := r.NewGuest(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close(ctx)
+
+	if err = g.Handle(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,610 @@
+// Package handlertest tests guests without an HTTP server, using Host, an
+// in-memory handler.Host which records all calls.
+//
+// For example:
+//
+//	h := &handlertest.Host{RequestHeader: http.Header{"Authorization": {"0"}}}
+//	if err := handlertest.Run(ctx, guest, h); err != nil {
+//		t.Fatal(err)
+//	}
+//	if h.StatusCode != 401 {
+//		t.Fatalf("expected 401, have %d", h.StatusCode)
+//	}
+package handlertest
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	internalhandler "github.com/http-wasm/http-wasm-host-go/internal/handler"
+)
+
+// supportedFeatures are the features Host implements.
+const supportedFeatures = handler.FeatureBufferRequest | handler.FeatureBufferResponse | handler.FeatureTrailers
+
+// Run handles one request with the guest and the host, which is then
+// committed. The options are the same as wasm.NewMiddleware. This returns a
+// handler.GuestError if the guest trapped.
+func Run(ctx context.Context, guest []byte, h *Host, options ...httpwasm.Option) error {
+	r, err := internalhandler.NewRuntime(ctx, guest, h, options...)
+	if err != nil {
+		return err
+	}
+	defer r.Close(ctx)
+
+	g, err := r.NewGuest(ctx)
+	if err != nil {
+		return err
+	}
+	defer g.Close(ctx)
+
+	err = g.Handle(ctx)
+	h.commit()
+	return err
+}
+
+// Call is a call to a method of handler.Host.
+type Call struct {
+	// Method is the name of the method. Ex. "GetRequestHeader"
+	Method string
+	// Args are the arguments after the context, except functions.
+	Args []interface{}
+}
+
+// String returns the call in Go syntax. Ex. `GetRequestHeader("Accept")`
+func (c Call) String() string {
+	s := c.Method + "("
+	for i, arg := range c.Args {
+		if i > 0 {
+			s += ", "
+		}
+		s += fmt.Sprintf("%#v", arg)
+	}
+	return s + ")"
+}
+
+// Host is an in-memory handler.Host. Set the request fields before Run, and
+// read the response fields after it. The zero value is a GET request without
+// headers, whose next handler responds 200 with no body.
+//
+// Host isn't safe for concurrent use.
+type Host struct {
+	// RequestHeader is the header of the request.
+	RequestHeader http.Header
+	// Query is the query of the request.
+	Query url.Values
+	// RequestBody is the body of the request. After Run, this is what the
+	// next handler would have read.
+	RequestBody []byte
+	// RequestChunked is true when the request body is chunked, so its size
+	// is unknown.
+	RequestChunked bool
+	// RequestTrailer is the trailer of the request.
+	RequestTrailer http.Header
+	// SourceAddr is the network address of the client. Ex. "1.2.3.4:12345"
+	SourceAddr string
+	// ListenerAddr is the local address of the listener. Ex. "10.0.0.1:8443"
+	ListenerAddr string
+	// UnixSocket is true when the listener is a unix domain socket.
+	UnixSocket bool
+	// ProxySourceAddr is the source address of the PROXY protocol header of
+	// the connection, or empty if none.
+	ProxySourceAddr string
+	// TLSVersion is the TLS version of the request, or zero if not TLS.
+	TLSVersion uint16
+	// TLSPeerCert is the DER encoding of the client certificate, if any.
+	TLSPeerCert []byte
+	// ProtocolVersion is the protocol of the request, or "HTTP/1.1" if empty.
+	ProtocolVersion string
+	// RPCService and RPCMethod are the service and method of a gRPC,
+	// gRPC-Web or Connect request, or empty if the request isn't one.
+	RPCService, RPCMethod string
+	// Route is the route pattern the request matched, if any.
+	// Ex. "GET /users/{id}"
+	Route string
+	// RequestID is the ID the host assigned the request, if any.
+	RequestID string
+	// Method is the method of the request, or "GET" if empty.
+	Method string
+	// RawRequestHeader is the request header as received, or nil if not
+	// preserved. This isn't kept consistent with RequestHeader.
+	RawRequestHeader []handler.HeaderField
+	// MultipartParts are the parts of a multipart request body, by name.
+	MultipartParts map[string][]byte
+	// FormValues are the fields of a form request body.
+	FormValues url.Values
+	// UploadedFiles are the files of a multipart request body, by name.
+	UploadedFiles map[string]handler.FileInfo
+	// Properties are read and written by the guest.
+	Properties map[string]string
+
+	// NextHandler, if not nil, is invoked when the guest calls the next
+	// handler. It can set the response fields, or write ResponseBody.
+	NextHandler func(ctx context.Context, h *Host)
+
+	// StatusCode is the status of the response, defaulting to 200.
+	StatusCode uint32
+	// ResponseHeader is the header of the response.
+	ResponseHeader http.Header
+	// ResponseBody is the body of the response.
+	ResponseBody []byte
+	// ResponseTrailer is the trailer of the response.
+	ResponseTrailer http.Header
+	// Committed is true when the response was sent.
+	Committed bool
+
+	// NextCalled counts calls to the next handler.
+	NextCalled int
+	// Mirrored are the URLs the guest mirrored the request to.
+	Mirrored []string
+	// Upstream is the upstream the guest set, if any.
+	Upstream string
+	// Timeout is the timeout of the next handler the guest set, if any.
+	Timeout time.Duration
+	// RetryPolicy is the retry policy the guest set, if any.
+	RetryPolicy handler.RetryPolicy
+	// Scratch is the scratch area of the request.
+	Scratch []byte
+	// Calls are all calls to the host, in order.
+	Calls []Call
+
+	features     handler.Features
+	beforeCommit []func()
+}
+
+// compile-time check to ensure Host implements handler.Host and all its
+// optional interfaces.
+var (
+	_ handler.Host                = &Host{}
+	_ handler.ConnectionInfo      = &Host{}
+	_ handler.RequestInfo         = &Host{}
+	_ handler.Proxy               = &Host{}
+	_ handler.CookieSetter        = &Host{}
+	_ handler.RawHeaders          = &Host{}
+	_ handler.Forms               = &Host{}
+	_ handler.Trailers            = &Host{}
+	_ handler.Properties          = &Host{}
+	_ handler.Scratch             = &Host{}
+	_ handler.RequestBodyStreamer = &Host{}
+)
+
+func (h *Host) record(method string, args ...interface{}) {
+	h.Calls = append(h.Calls, Call{Method: method, Args: args})
+}
+
+// Result returns the response, like httptest.ResponseRecorder Result.
+func (h *Host) Result() *http.Response {
+	status := int(h.statusCode())
+	return &http.Response{
+		Status:        strconv.Itoa(status) + " " + http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        h.ResponseHeader.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(h.ResponseBody)),
+		ContentLength: int64(len(h.ResponseBody)),
+		Trailer:       h.ResponseTrailer.Clone(),
+	}
+}
+
+// statusCode returns StatusCode, or 200 if it is zero.
+func (h *Host) statusCode() uint32 {
+	if h.StatusCode == 0 {
+		return http.StatusOK
+	}
+	return h.StatusCode
+}
+
+// commit sends the response, unless it already was.
+func (h *Host) commit() {
+	if h.Committed {
+		return
+	}
+	fns := h.beforeCommit
+	h.beforeCommit = nil
+	for _, fn := range fns {
+		fn()
+	}
+	h.Committed = true
+}
+
+// GetRequestHeader implements the same method as documented on handler.Host.
+func (h *Host) GetRequestHeader(_ context.Context, name string) (string, bool) {
+	h.record("GetRequestHeader", name)
+	values, ok := h.RequestHeader[http.CanonicalHeaderKey(name)]
+	if !ok || len(values) == 0 {
+		return "", false
+	}
+	return values[0], true
+}
+
+// GetRequestHeaders implements the same method as documented on
+// handler.Host.
+func (h *Host) GetRequestHeaders(context.Context) map[string][]string {
+	h.record("GetRequestHeaders")
+	return h.RequestHeader
+}
+
+// SetResponseHeader implements the same method as documented on handler.Host.
+func (h *Host) SetResponseHeader(_ context.Context, name, value string) {
+	h.record("SetResponseHeader", name, value)
+	if h.ResponseHeader == nil {
+		h.ResponseHeader = http.Header{}
+	}
+	h.ResponseHeader.Set(name, value)
+}
+
+// Next implements the same method as documented on handler.Host. This commits
+// the response, unless handler.FeatureBufferResponse is enabled.
+func (h *Host) Next(ctx context.Context) {
+	h.record("Next")
+	h.NextCalled++
+	if h.NextHandler != nil {
+		h.NextHandler(ctx, h)
+	}
+	if h.features&handler.FeatureBufferResponse == 0 {
+		h.commit()
+	}
+}
+
+// SendResponse implements the same method as documented on handler.Host.
+func (h *Host) SendResponse(_ context.Context, statusCode uint32, body []byte) {
+	h.record("SendResponse", statusCode, string(body))
+	h.StatusCode = statusCode
+	h.ResponseBody = append([]byte{}, body...)
+	h.commit()
+}
+
+// MirrorRequest implements the same method as documented on handler.Proxy.
+func (h *Host) MirrorRequest(_ context.Context, url string) {
+	h.record("MirrorRequest", url)
+	h.Mirrored = append(h.Mirrored, url)
+}
+
+// GetStatusCode implements the same method as documented on handler.Host.
+func (h *Host) GetStatusCode(context.Context) uint32 {
+	h.record("GetStatusCode")
+	return h.statusCode()
+}
+
+// SetStatusCode implements the same method as documented on handler.Host.
+func (h *Host) SetStatusCode(_ context.Context, statusCode uint32) {
+	h.record("SetStatusCode", statusCode)
+	if !h.Committed {
+		h.StatusCode = statusCode
+	}
+}
+
+// IsResponseCommitted implements the same method as documented on
+// handler.Host.
+func (h *Host) IsResponseCommitted(context.Context) bool {
+	h.record("IsResponseCommitted")
+	return h.Committed
+}
+
+// GetResponseHeaders implements the same method as documented on
+// handler.Host.
+func (h *Host) GetResponseHeaders(context.Context) map[string][]string {
+	h.record("GetResponseHeaders")
+	return h.ResponseHeader
+}
+
+// GetResponseBodySize implements the same method as documented on
+// handler.Host.
+func (h *Host) GetResponseBodySize(context.Context) uint64 {
+	h.record("GetResponseBodySize")
+	return uint64(len(h.ResponseBody))
+}
+
+// GetRequestBodySize implements the same method as documented on
+// handler.Host. This returns -1 when RequestChunked.
+func (h *Host) GetRequestBodySize(context.Context) int64 {
+	h.record("GetRequestBodySize")
+	if h.RequestChunked {
+		return -1
+	}
+	return int64(len(h.RequestBody))
+}
+
+// IsRequestChunked implements the same method as documented on
+// handler.ConnectionInfo.
+func (h *Host) IsRequestChunked(context.Context) bool {
+	h.record("IsRequestChunked")
+	return h.RequestChunked
+}
+
+// GetQueryParams implements the same method as documented on handler.Host.
+func (h *Host) GetQueryParams(context.Context) map[string][]string {
+	h.record("GetQueryParams")
+	return h.Query
+}
+
+// SetQueryValue implements the same method as documented on handler.Host.
+func (h *Host) SetQueryValue(_ context.Context, name, value string) {
+	h.record("SetQueryValue", name, value)
+	if h.Query == nil {
+		h.Query = url.Values{}
+	}
+	h.Query.Set(name, value)
+}
+
+// SetUpstream implements the same method as documented on handler.Proxy.
+func (h *Host) SetUpstream(_ context.Context, upstream string) {
+	h.record("SetUpstream", upstream)
+	h.Upstream = upstream
+}
+
+// SetTimeout implements the same method as documented on handler.Proxy.
+func (h *Host) SetTimeout(_ context.Context, timeout time.Duration) {
+	h.record("SetTimeout", timeout)
+	h.Timeout = timeout
+}
+
+// SetRetryPolicy implements the same method as documented on handler.Proxy.
+func (h *Host) SetRetryPolicy(_ context.Context, policy handler.RetryPolicy) {
+	h.record("SetRetryPolicy", policy.Attempts, policy.Backoff)
+	h.RetryPolicy = policy
+}
+
+// SetCookie implements the same method as documented on handler.CookieSetter,
+// adding a "Set-Cookie" header to ResponseHeader.
+func (h *Host) SetCookie(_ context.Context, name, value, attrs string) {
+	h.record("SetCookie", name, value, attrs)
+	line := name + "=" + value
+	if attrs != "" {
+		line += "; " + attrs
+	}
+	if h.ResponseHeader == nil {
+		h.ResponseHeader = http.Header{}
+	}
+	h.ResponseHeader.Add("Set-Cookie", line)
+}
+
+// ReadScratch implements the same method as documented on handler.Scratch.
+func (h *Host) ReadScratch(context.Context) []byte {
+	h.record("ReadScratch")
+	return h.Scratch
+}
+
+// WriteScratch implements the same method as documented on handler.Scratch.
+func (h *Host) WriteScratch(_ context.Context, data []byte) {
+	h.record("WriteScratch", string(data))
+	h.Scratch = append(h.Scratch[:0], data...)
+}
+
+// GetSourceAddr implements the same method as documented on
+// handler.ConnectionInfo.
+func (h *Host) GetSourceAddr(context.Context) string {
+	h.record("GetSourceAddr")
+	return h.SourceAddr
+}
+
+// GetListenerAddr implements the same method as documented on
+// handler.ConnectionInfo.
+func (h *Host) GetListenerAddr(context.Context) string {
+	h.record("GetListenerAddr")
+	return h.ListenerAddr
+}
+
+// IsUnixSocket implements the same method as documented on
+// handler.ConnectionInfo.
+func (h *Host) IsUnixSocket(context.Context) bool {
+	h.record("IsUnixSocket")
+	return h.UnixSocket
+}
+
+// GetProxySourceAddr implements the same method as documented on
+// handler.ConnectionInfo.
+func (h *Host) GetProxySourceAddr(context.Context) (string, bool) {
+	h.record("GetProxySourceAddr")
+	return h.ProxySourceAddr, h.ProxySourceAddr != ""
+}
+
+// GetTLSVersion implements the same method as documented on
+// handler.ConnectionInfo.
+func (h *Host) GetTLSVersion(context.Context) uint32 {
+	h.record("GetTLSVersion")
+	return uint32(h.TLSVersion)
+}
+
+// GetTLSPeerCert implements the same method as documented on
+// handler.ConnectionInfo.
+func (h *Host) GetTLSPeerCert(context.Context) []byte {
+	h.record("GetTLSPeerCert")
+	return h.TLSPeerCert
+}
+
+// GetProtocolVersion implements the same method as documented on
+// handler.ConnectionInfo.
+func (h *Host) GetProtocolVersion(context.Context) string {
+	h.record("GetProtocolVersion")
+	if h.ProtocolVersion == "" {
+		return "HTTP/1.1"
+	}
+	return h.ProtocolVersion
+}
+
+// GetRPC implements the same method as documented on handler.RequestInfo.
+func (h *Host) GetRPC(context.Context) (service, method string) {
+	h.record("GetRPC")
+	return h.RPCService, h.RPCMethod
+}
+
+// GetRoute implements the same method as documented on handler.RequestInfo.
+func (h *Host) GetRoute(context.Context) string {
+	h.record("GetRoute")
+	return h.Route
+}
+
+// GetRequestID implements the same method as documented on
+// handler.RequestInfo.
+func (h *Host) GetRequestID(context.Context) string {
+	h.record("GetRequestID")
+	return h.RequestID
+}
+
+// GetRawRequestHeaders implements the same method as documented on
+// handler.RawHeaders.
+func (h *Host) GetRawRequestHeaders(context.Context) []handler.HeaderField {
+	h.record("GetRawRequestHeaders")
+	return h.RawRequestHeader
+}
+
+// SetRawResponseHeader implements the same method as documented on
+// handler.RawHeaders.
+func (h *Host) SetRawResponseHeader(_ context.Context, name, value string) {
+	h.record("SetRawResponseHeader", name, value)
+	if h.ResponseHeader == nil {
+		h.ResponseHeader = http.Header{}
+	}
+	h.ResponseHeader.Del(name)
+	h.ResponseHeader[name] = []string{value}
+}
+
+// GetMethod implements the same method as documented on handler.Host.
+func (h *Host) GetMethod(context.Context) string {
+	h.record("GetMethod")
+	if h.Method == "" {
+		return http.MethodGet
+	}
+	return h.Method
+}
+
+// GetMultipartPart implements the same method as documented on handler.Forms.
+func (h *Host) GetMultipartPart(_ context.Context, name string) ([]byte, bool) {
+	h.record("GetMultipartPart", name)
+	part, ok := h.MultipartParts[name]
+	return part, ok
+}
+
+// GetFormValue implements the same method as documented on handler.Forms.
+func (h *Host) GetFormValue(_ context.Context, name string) (string, bool) {
+	h.record("GetFormValue", name)
+	values, ok := h.FormValues[name]
+	if !ok || len(values) == 0 {
+		return "", false
+	}
+	return values[0], true
+}
+
+// GetUploadedFileInfo implements the same method as documented on
+// handler.Forms.
+func (h *Host) GetUploadedFileInfo(_ context.Context, name string) (handler.FileInfo, bool) {
+	h.record("GetUploadedFileInfo", name)
+	info, ok := h.UploadedFiles[name]
+	return info, ok
+}
+
+// GetRequestTrailer implements the same method as documented on
+// handler.Trailers.
+func (h *Host) GetRequestTrailer(_ context.Context, name string) (string, bool) {
+	h.record("GetRequestTrailer", name)
+	values, ok := h.RequestTrailer[http.CanonicalHeaderKey(name)]
+	if !ok || len(values) == 0 {
+		return "", false
+	}
+	return values[0], true
+}
+
+// SetResponseTrailer implements the same method as documented on
+// handler.Trailers.
+func (h *Host) SetResponseTrailer(_ context.Context, name, value string) {
+	h.record("SetResponseTrailer", name, value)
+	if h.ResponseTrailer == nil {
+		h.ResponseTrailer = http.Header{}
+	}
+	h.ResponseTrailer.Set(name, value)
+}
+
+// GetRequestBody implements the same method as documented on handler.Host.
+func (h *Host) GetRequestBody(context.Context) []byte {
+	h.record("GetRequestBody")
+	return h.RequestBody
+}
+
+// StreamRequestBody implements the same method as documented on
+// handler.RequestBodyStreamer. As there is no next handler reading the body,
+// this passes the whole body through onChunk immediately, replacing
+// RequestBody.
+func (h *Host) StreamRequestBody(_ context.Context, chunkLimit uint32, onChunk func(chunk []byte, eos bool) []byte) {
+	h.record("StreamRequestBody", chunkLimit)
+	body := h.RequestBody
+	var result []byte
+	for {
+		n := len(body)
+		if n > int(chunkLimit) {
+			n = int(chunkLimit)
+		}
+		eos := n == len(body)
+		result = append(result, onChunk(body[:n], eos)...)
+		if body = body[n:]; eos {
+			break
+		}
+	}
+	h.RequestBody = result
+}
+
+// ReadRequestBody implements the same method as documented on handler.Host.
+func (h *Host) ReadRequestBody(_ context.Context, alloc func(size uint32) []byte) uint32 {
+	h.record("ReadRequestBody")
+	size := uint32(len(h.RequestBody))
+	if size == 0 {
+		return 0
+	}
+	buf := alloc(size)
+	copy(buf, h.RequestBody)
+	return size
+}
+
+// GetProperties implements the same method as documented on
+// handler.Properties.
+func (h *Host) GetProperties(context.Context) map[string]string {
+	h.record("GetProperties")
+	return h.Properties
+}
+
+// SetProperty implements the same method as documented on handler.Properties.
+func (h *Host) SetProperty(_ context.Context, name, value string) {
+	h.record("SetProperty", name, value)
+	if h.Properties == nil {
+		h.Properties = map[string]string{}
+	}
+	h.Properties[name] = value
+}
+
+// BeforeCommit implements the same method as documented on handler.Host.
+func (h *Host) BeforeCommit(_ context.Context, fn func()) {
+	h.record("BeforeCommit")
+	h.beforeCommit = append(h.beforeCommit, fn)
+}
+
+// EnableFeatures implements the same method as documented on handler.Host.
+func (h *Host) EnableFeatures(_ context.Context, features handler.Features) handler.Features {
+	h.record("EnableFeatures", features)
+	h.features |= features & supportedFeatures
+	return h.features
+}
+
+// Capabilities implements the same method as documented on handler.Host.
+func (h *Host) Capabilities(context.Context) handler.Features {
+	h.record("Capabilities")
+	return supportedFeatures
+}
+
+// GetResponseBody implements the same method as documented on handler.Host.
+func (h *Host) GetResponseBody(context.Context) []byte {
+	h.record("GetResponseBody")
+	if h.features&handler.FeatureBufferResponse == 0 {
+		return nil
+	}
+	return h.ResponseBody
+}
@@ -0,0 +1,71 @@
+;; This module is used by HostTest to check the ABI through a host. It reads
+;; the request header "X-Value" into a buffer too small for long values, then
+;; retries with a larger one, and echoes it as the response header "X-Echo".
+(module $conformance
+
+  ;; read_request_header writes a header value to memory if it exists and
+  ;; isn't larger than the buffer size limit. The result is `1<<32|value_len`
+  ;; or zero if the header doesn't exist.
+  (import "http-handler" "read_request_header"
+    (func $read_request_header
+      (param $name i32) (param $name_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; 0 or 1 << 32| value_len ;) i64)))
+
+  ;; set_response_header sets a response header from a name and value read
+  ;; from memory
+  (import "http-handler" "set_response_header"
+    (func $set_response_header
+      (param $name i32) (param $name_len i32)
+      (param $value i32) (param $value_len i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "read_request_header" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (global $name i32 (i32.const 0))
+  (data (i32.const 0) "X-Value")
+  (global $name_len i32 (i32.const 7))
+
+  (global $echo i32 (i32.const 16))
+  (data (i32.const 16) "X-Echo")
+  (global $echo_len i32 (i32.const 6))
+
+  ;; buf is where header values are read. The first read is limited to 4
+  ;; bytes, so that longer values are retried.
+  (global $buf i32 (i32.const 1024))
+  (global $small_limit i32 (i32.const 4))
+  (global $buf_limit i32 (i32.const 4096))
+
+  (func $handle (export "handle")
+    (local $result i64)
+    (local $value_len i32)
+
+    (local.set $result
+      (call $read_request_header
+        (global.get $name) (global.get $name_len)
+        (global.get $buf) (global.get $small_limit)))
+
+    (if (i64.eqz (local.get $result))
+      (then ;; no value, so don't echo
+        (call $next)
+        (return)))
+
+    (local.set $value_len (i32.wrap_i64 (local.get $result)))
+    (if (i32.gt_u (local.get $value_len) (global.get $small_limit))
+      (then ;; buffer too small, so retry with a larger one
+        (if (i32.gt_u (local.get $value_len) (global.get $buf_limit))
+          (then unreachable))
+        (drop
+          (call $read_request_header
+            (global.get $name) (global.get $name_len)
+            (global.get $buf) (global.get $buf_limit)))))
+
+    (call $set_response_header
+      (global.get $echo) (global.get $echo_len)
+      (global.get $buf) (local.get $value_len))
+    (call $next))
+)
@@ -0,0 +1,100 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/experimental"
+
+	"github.com/http-wasm/http-wasm-host-go/api"
+)
+
+// DefaultRuntimeMode is the api.CompileReport Mode of DefaultRuntime, which
+// uses the compiler on platforms that support it.
+func DefaultRuntimeMode() string {
+	switch runtime.GOARCH {
+	case "amd64", "arm64":
+		switch runtime.GOOS {
+		case "darwin", "linux", "freebsd", "windows":
+			return api.CompileModeCompiler
+		}
+	}
+	return api.CompileModeInterpreter
+}
+
+// RuntimeWithMode returns a NewRuntime which is the same as DefaultRuntime,
+// except it uses the api.CompileReport Mode, or fails if the mode is invalid
+// or unsupported on this platform.
+func RuntimeWithMode(mode string) func(context.Context) (wazero.Runtime, error) {
+	var config wazero.RuntimeConfig
+	switch mode {
+	case api.CompileModeCompiler:
+		if DefaultRuntimeMode() != api.CompileModeCompiler {
+			return func(context.Context) (wazero.Runtime, error) {
+				// wazero panics instead.
+				return nil, fmt.Errorf("compiler unsupported on %s/%s", runtime.GOOS, runtime.GOARCH)
+			}
+		}
+		config = wazero.NewRuntimeConfigCompiler()
+	case api.CompileModeInterpreter:
+		config = wazero.NewRuntimeConfigInterpreter()
+	default:
+		return func(context.Context) (wazero.Runtime, error) {
+			return nil, fmt.Errorf("invalid compile mode %q", mode)
+		}
+	}
+	return RuntimeWithConfig(config)
+}
+
+// CreateRuntime calls NewRuntime, configured with the compilation cache, if
+// any. This returns SharedRuntime instead, if set.
+func (o *WazeroOptions) CreateRuntime(ctx context.Context) (wazero.Runtime, error) {
+	if o.SharedRuntime != nil {
+		return o.SharedRuntime, nil
+	}
+	if o.CompilationCacheDir != "" {
+		var err error
+		if ctx, err = experimental.WithCompilationCacheDirName(ctx, o.CompilationCacheDir); err != nil {
+			return nil, fmt.Errorf("wasm: invalid compilation cache: %w", err)
+		}
+	}
+	r, err := o.NewRuntime(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("wasm: error creating runtime: %w", err)
+	}
+	return r, nil
+}
+
+// CompileGuest compiles the guest with a runtime from CreateRuntime,
+// reporting how long it took and whether it was in the compilation cache.
+func (o *WazeroOptions) CompileGuest(ctx context.Context, r wazero.Runtime, guest []byte) (wazero.CompiledModule, api.CompileReport, error) {
+	report := api.CompileReport{Mode: o.RuntimeMode}
+
+	// The cache doesn't report hits, so infer them from whether compiling
+	// added a file to it.
+	cached := o.cacheEntries()
+
+	start := time.Now()
+	compiled, err := r.CompileModule(ctx, guest)
+	report.Duration = time.Since(start)
+	if err != nil {
+		return nil, report, fmt.Errorf("wasm: error compiling guest: %w", err)
+	}
+
+	if o.CompilationCacheDir != "" && report.Mode != api.CompileModeInterpreter {
+		report.CacheHit = o.cacheEntries() == cached
+	}
+	return compiled, report, nil
+}
+
+func (o *WazeroOptions) cacheEntries() int {
+	if o.CompilationCacheDir == "" {
+		return 0
+	}
+	entries, _ := os.ReadDir(o.CompilationCacheDir)
+	return len(entries)
+}
@@ -0,0 +1,136 @@
+// Package extract compiles expressions that extract a value from an HTTP
+// request, such as a header or a field of a JSON body.
+package extract
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Sources of values an Expression extracts from.
+const (
+	SourceHeader = "header"
+	SourceQuery  = "query"
+	SourceCookie = "cookie"
+	SourceJSON   = "json"
+)
+
+// Expression is a compiled extraction expression, in the format
+// "<source>:<argument>". Ex. "header:Authorization" or "json:$.user.id"
+type Expression struct {
+	// Source is the part of the request to extract from. Ex. SourceHeader
+	Source string
+	// Name is the argument of the expression. Ex. "Authorization"
+	Name string
+	// path is non-nil when Source is SourceJSON. Elements are either string
+	// object keys or int array indexes.
+	path []interface{}
+}
+
+// Compile parses the expression, returning an error if it is invalid.
+func Compile(expr string) (*Expression, error) {
+	source, name, ok := strings.Cut(expr, ":")
+	if !ok || name == "" {
+		return nil, fmt.Errorf("expected <source>:<argument>, have %q", expr)
+	}
+	e := &Expression{Source: source, Name: name}
+	switch source {
+	case SourceHeader, SourceQuery, SourceCookie:
+	case SourceJSON:
+		path, err := parsePath(name)
+		if err != nil {
+			return nil, err
+		}
+		e.path = path
+	default:
+		return nil, fmt.Errorf("unknown source %q", source)
+	}
+	return e, nil
+}
+
+// parsePath parses a subset of JSONPath: "$" followed by any number of
+// ".key" or "[index]". Ex. "$.items[0].id"
+func parsePath(s string) ([]interface{}, error) {
+	if !strings.HasPrefix(s, "$") {
+		return nil, fmt.Errorf("json path must start with $: %q", s)
+	}
+	path := []interface{}{}
+	for rest := s[1:]; rest != ""; {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[") + 1
+			if end == 0 {
+				end = len(rest)
+			}
+			if end == 1 {
+				return nil, fmt.Errorf("empty key in json path: %q", s)
+			}
+			path, rest = append(path, rest[1:end]), rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated index in json path: %q", s)
+			}
+			i, err := strconv.Atoi(rest[1:end])
+			if err != nil || i < 0 {
+				return nil, fmt.Errorf("invalid index in json path: %q", s)
+			}
+			path, rest = append(path, i), rest[end+1:]
+		default:
+			return nil, fmt.Errorf("unexpected %q in json path: %q", rest[0], s)
+		}
+	}
+	return path, nil
+}
+
+var errNotFound = errors.New("not found")
+
+// EvalJSON returns the value at the path of this expression in the JSON
+// document, or false if there is none. Strings are returned unquoted, null is
+// treated as missing and other values are returned as JSON.
+func (e *Expression) EvalJSON(doc []byte) (string, bool) {
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(doc))
+	d.UseNumber()
+	if err := d.Decode(&v); err != nil {
+		return "", false
+	}
+	v, err := lookup(v, e.path)
+	if err != nil || v == nil {
+		return "", false
+	}
+	if s, ok := v.(string); ok {
+		return s, true
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", false
+	}
+	return string(b), true
+}
+
+func lookup(v interface{}, path []interface{}) (interface{}, error) {
+	for _, p := range path {
+		switch p := p.(type) {
+		case string:
+			o, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, errNotFound
+			}
+			if v, ok = o[p]; !ok {
+				return nil, errNotFound
+			}
+		case int:
+			a, ok := v.([]interface{})
+			if !ok || p >= len(a) {
+				return nil, errNotFound
+			}
+			v = a[p]
+		}
+	}
+	return v, nil
+}
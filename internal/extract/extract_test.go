@@ -0,0 +1,59 @@
+package extract
+
+import "testing"
+
+func TestCompile_Error(t *testing.T) {
+	tests := []struct {
+		expr, expectedErr string
+	}{
+		{expr: "header", expectedErr: `expected <source>:<argument>, have "header"`},
+		{expr: "body:x", expectedErr: `unknown source "body"`},
+		{expr: "json:a", expectedErr: `json path must start with $: "a"`},
+		{expr: "json:$..a", expectedErr: `empty key in json path: "$..a"`},
+		{expr: "json:$[x]", expectedErr: `invalid index in json path: "$[x]"`},
+		{expr: "json:$[0", expectedErr: `unterminated index in json path: "$[0"`},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.expr, func(t *testing.T) {
+			if _, err := Compile(tc.expr); err == nil {
+				t.Fatalf("expected error %q", tc.expectedErr)
+			} else if have := err.Error(); have != tc.expectedErr {
+				t.Fatalf("expected error %q, have %q", tc.expectedErr, have)
+			}
+		})
+	}
+}
+
+func TestExpression_EvalJSON(t *testing.T) {
+	const doc = `{"user":{"id":"u1","roles":["admin","dev"],"age":42,"tags":{"a":1},"nick":null}}`
+
+	tests := []struct {
+		expr, expected string
+		expectedOk     bool
+	}{
+		{expr: "json:$.user.id", expected: "u1", expectedOk: true},
+		{expr: "json:$.user.roles[1]", expected: "dev", expectedOk: true},
+		{expr: "json:$.user.age", expected: "42", expectedOk: true},
+		{expr: "json:$.user.tags", expected: `{"a":1}`, expectedOk: true},
+		{expr: "json:$.user.nick"},
+		{expr: "json:$.user.roles[2]"},
+		{expr: "json:$.user.id.x"},
+		{expr: "json:$.missing"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.expr, func(t *testing.T) {
+			e, err := Compile(tc.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			have, ok := e.EvalJSON([]byte(doc))
+			if ok != tc.expectedOk || have != tc.expected {
+				t.Fatalf("expected (%q, %v), have (%q, %v)", tc.expected, tc.expectedOk, have, ok)
+			}
+		})
+	}
+}
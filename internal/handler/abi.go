@@ -2,28 +2,174 @@ package handler
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
 
 	"github.com/tetratelabs/wazero"
 	wazeroapi "github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
 
 	httpwasm "github.com/http-wasm/http-wasm-host-go"
 	"github.com/http-wasm/http-wasm-host-go/api"
 	"github.com/http-wasm/http-wasm-host-go/api/handler"
 	"github.com/http-wasm/http-wasm-host-go/internal"
+	"github.com/http-wasm/http-wasm-host-go/internal/extract"
+	"github.com/http-wasm/http-wasm-host-go/internal/wasm"
+	"github.com/http-wasm/http-wasm-host-go/ratelimit"
+	"github.com/http-wasm/http-wasm-host-go/sharedstore"
 )
 
 type Runtime struct {
-	host                    handler.Host
+	host                    FullHost
 	runtime                 wazero.Runtime
 	hostModule, guestModule wazero.CompiledModule
-	config                  wazero.ModuleConfig
-	logFn                   api.LogFunc
+	// wasiModule is nil unless the guest imports WASI, such as to write to
+	// stdout.
+	wasiModule wazero.CompiledModule
+	// memoryModule is nil unless the guest imports its memory, in which case
+	// it is instantiated with the name memoryImport.
+	memoryModule      wazero.CompiledModule
+	memoryImport      string
+	config            wazero.ModuleConfig
+	guestConfigCanary []byte
+	canaryPercent     int
+	customSections    []wasm.CustomSection
+	metadata          handler.GuestMetadata
+	logFn             api.LogFunc
+	// logLimiter is nil unless internal.LogLimits are configured.
+	logLimiter *logLimiter
+	// accessLogger is nil unless httpwasm.AccessLogger was set.
+	accessLogger api.AccessLogger
+	// events is nil unless httpwasm.OnEvent was set. This is shared with
+	// shards.
+	events *eventQueue
+	// auditFn is nil unless httpwasm.AuditLogger was set.
+	auditFn            api.AuditFunc
+	mirrorDestinations map[string]string
+	// routes is nil unless the guest defines handler.CustomSectionRoutes.
+	routes *routes
+	// extractions are compiled from internal.WazeroOptions.
+	extractions map[string]*extract.Expression
+	// keys are internal.WazeroOptions Keys.
+	keys            map[string]interface{}
+	problemTypeBase string
+	messageCatalogs []internal.MessageCatalog
+	injectedHeaders []internal.InjectedHeader
+	sharedStore     api.SharedStore
+	// stateStore is nil unless httpwasm.StateStore was set.
+	stateStore api.StateStore
+	// responseCache is nil unless httpwasm.ResponseCache was set.
+	responseCache api.ResponseCache
+	rateLimiter   api.RateLimiter
+	// persistDir is empty unless httpwasm.PersistStores was set.
+	persistDir     string
+	metrics        *metricRegistry
+	clock          api.Clock
+	schedules      schedules
+	compileReport  api.CompileReport
+	random         io.Reader
+	errorResponses map[string]*errorResponse
+	httpCaller     *httpCaller
+	resolver       *net.Resolver
+
+	guestErrorStatus uint32
+	failurePolicy    api.FailurePolicy
+	quotas           internal.Quotas
+
+	// features are implemented by the runtime, so are always enabled.
+	features handler.Features
+
+	// readsResponseBody is true when the guest imports
+	// handler.FuncReadResponseBody or handler.FuncReadResponseBodyAlloc.
+	readsResponseBody bool
+	// setsHostValues is true when the guest imports handler.FuncSetHostValue.
+	setsHostValues bool
+	// getsLastError is true when the guest imports handler.FuncGetLastError.
+	getsLastError bool
+	// decodeResponseBody is internal.WazeroOptions DecodeResponseBody.
+	decodeResponseBody bool
+	// bufferLimit is the result of handler.FuncGetBufferLimit.
+	bufferLimit uint32
+	// requestID is internal.WazeroOptions RequestID.
+	requestID bool
+	// shadow is internal.WazeroOptions Shadow.
+	shadow api.ShadowFunc
+	// canary is internal.WazeroOptions Canary.
+	canary bool
+	// bypassPreflight is internal.WazeroOptions BypassPreflight.
+	bypassPreflight bool
+	// interruptOnClientGone is internal.WazeroOptions InterruptOnClientGone.
+	interruptOnClientGone bool
+	// bodyLimits are internal.WazeroOptions BodyLimits.
+	bodyLimits internal.BodyLimits
+
+	// configMu guards guestConfig and configVersion, which UpdateConfig
+	// replaces.
+	configMu      sync.RWMutex
+	guestConfig   []byte
+	configVersion uint64
+
+	// digest is the digest of the guest, for handler.GuestInfo.
+	digest string
+	// instances counts guests instantiated, for handler.GuestInfo. This is
+	// shared with shards.
+	instances *uint64
+
+	// snapshot is nil unless internal.WazeroOptions Snapshot is set.
+	snapshot *snapshot
+
+	// shared is true when the runtime is internal.WazeroOptions
+	// SharedRuntime, so isn't closed with this.
+	shared bool
+
+	// stdout and stderr receive output of the guest, or the logger if nil.
+	stdout, stderr io.Writer
+
+	// concurrency is nil unless internal.Concurrency is configured.
+	concurrency *concurrencyLimiter
+
+	// latency is nil unless a latency budget is configured.
+	latency *latencyBudget
+
+	// ticks calls handler.FuncOnTick when scheduled. This is shared with
+	// shards.
+	ticks *ticker
+
+	// exports are the guestExports of guests not yet closed, by module, for
+	// host functions that call the guest.
+	exports sync.Map
+
+	// live are guests not yet closed which export handler.FuncShutdown.
+	liveMu sync.Mutex
+	live   map[*Guest]struct{}
+
+	// idle are guests instantiated by prewarm, Ping or the pool, not yet
+	// taken by NewGuest.
+	idleMu sync.Mutex
+	idle   []*Guest
+	// pool is nil unless internal.WazeroOptions Pool is set.
+	pool *guestPool
+
+	// shards are the runtimes other than this, when internal.WazeroOptions
+	// Shards is set.
+	shards []*Runtime
+	// nextShard is the count of guests assigned to shards.
+	nextShard uint64
 }
 
 func NewRuntime(ctx context.Context, guest []byte, host handler.Host, options ...httpwasm.Option) (*Runtime, error) {
 	o := &internal.WazeroOptions{
 		NewRuntime:   internal.DefaultRuntime,
+		RuntimeMode:  internal.DefaultRuntimeMode(),
 		ModuleConfig: wazero.NewModuleConfig(),
 		Logger:       func(context.Context, string) {},
 	}
@@ -31,38 +177,291 @@ func NewRuntime(ctx context.Context, guest []byte, host handler.Host, options ..
 		option(o)
 	}
 
-	wr, err := o.NewRuntime(ctx)
+	if o.GuestVerifier != nil {
+		if err := o.GuestVerifier(guest); err != nil {
+			return nil, fmt.Errorf("wasm: error verifying guest: %w", err)
+		}
+	}
+
+	if o.Profiler != nil {
+		if o.RuntimeMode == api.CompileModeCompiler {
+			return nil, errors.New("wasm: httpwasm.Profiler requires api.CompileModeInterpreter")
+		}
+		// wazero reads listeners from the context that compiles modules.
+		ctx = context.WithValue(ctx, experimental.FunctionListenerFactoryKey{}, o.Profiler)
+	}
+	if o.Shards.Count > 1 && o.Shards.Strategy == api.ShardPerTenant {
+		return nil, errors.New("wasm: api.ShardPerTenant requires a Registry or router")
+	}
+
+	unwrapped := host
+	if o.WrapHost != nil {
+		host = o.WrapHost(host)
+	}
+	if o.Shadow != nil {
+		host = shadowHost{NewFullHost(host)}
+	}
+
+	wr, err := o.CreateRuntime(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("wasm: error creating runtime: %w", err)
+		return nil, err
 	}
 
-	r := &Runtime{host: host, runtime: wr, logFn: o.Logger, config: o.ModuleConfig}
+	r := &Runtime{
+		host:         NewFullHost(host),
+		runtime:      wr,
+		logFn:        o.Logger,
+		auditFn:      o.AuditLogger,
+		accessLogger: o.AccessLogger,
+		events:       newEventQueue(o.OnEvent),
+		logLimiter:   newLogLimiter(o.LogLimits),
+		concurrency:  newConcurrencyLimiter(o.Concurrency),
+		stdout:       o.Stdout,
+		stderr:       o.Stderr,
+		config:       o.ModuleConfig,
+		guestConfig:  o.GuestConfig,
+
+		guestConfigCanary: o.GuestConfigCanary,
+		canaryPercent:     o.GuestConfigCanaryPercent,
+
+		mirrorDestinations: o.MirrorDestinations,
+		keys:               o.Keys,
+		problemTypeBase:    o.ProblemTypeBase,
+		messageCatalogs:    o.MessageCatalogs,
+		injectedHeaders:    o.InjectedHeaders,
+		sharedStore:        o.SharedStore,
+		stateStore:         o.StateStore,
+		responseCache:      o.ResponseCache,
+		rateLimiter:        o.RateLimiter,
+		metrics:            &metricRegistry{backend: o.Metrics},
+		clock:              systemClock{},
+		random:             rand.Reader,
+		httpCaller:         newHTTPCaller(o.HTTPCall),
+		resolver:           o.Resolver,
+		guestErrorStatus:   uint32(o.GuestErrorStatus),
+		failurePolicy:      o.FailurePolicy,
+		quotas:             o.Quotas,
+		features:           runtimeFeatures(o),
+		decodeResponseBody: o.DecodeResponseBody,
+		bufferLimit:        o.DefaultBufferLimit,
+		requestID:          o.RequestID,
+		shadow:             o.Shadow,
+		canary:             o.Canary,
+		bypassPreflight:    o.BypassPreflight,
+		shared:             o.SharedRuntime != nil,
+		instances:          new(uint64),
+
+		interruptOnClientGone: o.InterruptOnClientGone,
+		bodyLimits:            o.BodyLimits,
+	}
+	r.ticks = &ticker{r: r}
+	if o.Clock != nil {
+		r.clock = o.Clock
+		r.config = withClock(r.config, o.Clock)
+	}
+	if o.Random != nil {
+		r.random = o.Random
+		r.config = r.config.WithRandSource(o.Random)
+	}
+	if r.config, err = withEnv(r.config, o.Env, o.Args); err != nil {
+		_ = r.Close(ctx)
+		return nil, err
+	}
+	if r.sharedStore == nil {
+		r.sharedStore = sharedstore.NewMemory()
+	}
+	if r.rateLimiter == nil {
+		r.rateLimiter = ratelimit.NewMemory(defaultRateLimit, defaultRateLimit)
+	}
+	if o.PersistedStores != nil {
+		if err = r.restoreStores(o.PersistedStores); err != nil {
+			_ = r.Close(ctx)
+			return nil, err
+		}
+	}
+	if o.LatencyBudget.Budget > 0 {
+		r.latency = &latencyBudget{LatencyBudget: o.LatencyBudget, clock: r.clock}
+	}
+	if r.guestErrorStatus == 0 {
+		r.guestErrorStatus = http.StatusInternalServerError
+	}
+	if r.bufferLimit == 0 {
+		r.bufferLimit = defaultBufferLimit
+	}
+	if r.resolver == nil {
+		r.resolver = net.DefaultResolver
+	}
 
 	if r.hostModule, err = r.compileHost(ctx); err != nil {
 		_ = r.Close(ctx)
 		return nil, err
 	}
 
-	if r.guestModule, err = r.compileGuest(ctx, guest); err != nil {
+	if r.customSections, err = wasm.CustomSections(guest); err != nil {
+		_ = r.Close(ctx)
+		return nil, fmt.Errorf("wasm: error reading guest: %w", err)
+	}
+	if r.metadata, err = wasm.Metadata(r.customSections); err != nil {
+		_ = r.Close(ctx)
+		return nil, fmt.Errorf("wasm: error reading guest: %w", err)
+	}
+
+	if r.extractions, err = compileExtractions(o.Extractions); err != nil {
+		_ = r.Close(ctx)
+		return nil, err
+	}
+
+	if r.errorResponses, err = compileErrorMappings(o.ErrorMappings); err != nil {
+		_ = r.Close(ctx)
+		return nil, err
+	}
+
+	if err = checkKeys(o.Keys); err != nil {
+		_ = r.Close(ctx)
+		return nil, err
+	}
+
+	if r.schedules, err = parseSchedules(o.ActiveWindows, o.BypassWindows); err != nil {
+		_ = r.Close(ctx)
+		return nil, err
+	}
+
+	if err = r.parseRoutes(); err != nil {
+		_ = r.Close(ctx)
+		return nil, err
+	}
+
+	if err = r.validateGuestConfig(); err != nil {
 		_ = r.Close(ctx)
 		return nil, err
 	}
 
+	compiled := guest
+	if o.Snapshot {
+		if compiled, err = exportSnapshotGlobals(guest); err != nil {
+			_ = r.Close(ctx)
+			return nil, err
+		}
+	}
+	if r.guestModule, err = r.compileGuest(ctx, o, compiled); err != nil {
+		_ = r.Close(ctx)
+		return nil, err
+	}
+	if r.wasiModule, err = r.compileWASI(ctx); err != nil {
+		_ = r.Close(ctx)
+		return nil, err
+	}
+	if r.memoryModule, r.memoryImport, err = r.compileMemory(ctx); err != nil {
+		_ = r.Close(ctx)
+		return nil, err
+	}
+	r.readsResponseBody = importsFunc(r.guestModule, handler.FuncReadResponseBody) ||
+		importsFunc(r.guestModule, handler.FuncReadResponseBodyAlloc)
+	r.setsHostValues = importsFunc(r.guestModule, handler.FuncSetHostValue)
+	r.getsLastError = importsFunc(r.guestModule, handler.FuncGetLastError)
+	if _, ok := r.guestModule.ExportedFunctions()[handler.FuncMalloc]; !ok {
+		r.features &^= handler.FeatureGrowBuffers
+	}
+	r.digest = fmt.Sprintf("sha256:%x", sha256.Sum256(guest))
+
+	if o.Snapshot {
+		if err = r.takeSnapshot(ctx, compiled); err != nil && !r.FailOpen(ctx, err) {
+			_ = r.Close(ctx)
+			return nil, err
+		}
+	}
+
+	if err = r.prewarm(ctx, o.Prewarm); err != nil && !r.FailOpen(ctx, err) {
+		_ = r.Close(ctx)
+		return nil, err
+	}
+	if o.Pool.Max > 0 {
+		r.pool = newGuestPool(o.Pool)
+		if err = r.prewarm(ctx, r.pool.target); err != nil && !r.FailOpen(ctx, err) {
+			_ = r.Close(ctx)
+			return nil, err
+		}
+		go r.pool.run(r)
+	}
+	if o.Shards.Count > 1 {
+		if err = r.newShards(ctx, guest, unwrapped, o.Shards.Count, options); err != nil {
+			_ = r.Close(ctx)
+			return nil, err
+		}
+	}
 	return r, nil
 }
 
+// CompileReport returns how the guest was compiled.
+func (r *Runtime) CompileReport() api.CompileReport {
+	return r.compileReport
+}
+
 // Close implements api.Closer
 func (r *Runtime) Close(ctx context.Context) error {
+	r.ticks.close(ctx)
+	if r.pool != nil {
+		r.pool.close()
+	}
+	r.closeShards(ctx)
+	r.closeIdle(ctx)
+	r.shutdownGuests(ctx)
+	r.events.close() // after guests, as shutdown may emit events
+	if r.shared {
+		// Only close what this compiled, as other guests use the runtime.
+		// Guests must be closed before this.
+		var err error
+		for _, m := range []wazero.CompiledModule{r.hostModule, r.wasiModule, r.memoryModule, r.guestModule} {
+			if m == nil {
+				continue
+			} else if e := m.Close(ctx); e != nil {
+				err = e
+			}
+		}
+		return err
+	}
 	// We don't have to close any guests as the runtime will close it.
 	return r.runtime.Close(ctx)
 }
 
 type Guest struct {
+	r     *Runtime
 	ns    wazero.Namespace
 	guest wazeroapi.Module
+
+	*guestExports
+
+	// output are writers of the guest stdout and stderr to flush on close.
+	output []*logWriter
+
+	// configVersion is the version of the guest config the guest last
+	// applied. See Runtime.UpdateConfig.
+	configVersion uint64
+
+	// handling is one while Handle is in progress, so that concurrent calls
+	// return handler.ErrGuestInUse instead of corrupting memory.
+	handling int32
+
+	info handler.GuestInfo
 }
 
+// NewGuest returns a pre-warmed guest, if any are left, or otherwise
+// instantiates one.
 func (r *Runtime) NewGuest(ctx context.Context) (*Guest, error) {
+	if s := r.shard(); s != r {
+		return s.NewGuest(ctx)
+	}
+	g := r.takeIdle()
+	if r.pool != nil {
+		r.pool.checkout(r, g == nil)
+	}
+	if g != nil {
+		return g, nil
+	}
+	return r.instantiate(ctx)
+}
+
+func (r *Runtime) instantiate(ctx context.Context) (*Guest, error) {
 	ns := r.runtime.NewNamespace(ctx)
 
 	// Note: host modules don't use configuration
@@ -71,48 +470,527 @@ func (r *Runtime) NewGuest(ctx context.Context) (*Guest, error) {
 		_ = ns.Close(ctx)
 		return nil, fmt.Errorf("wasm: error instantiating host: %w", err)
 	}
+	if r.wasiModule != nil {
+		// WASI reads stdout and stderr from the config of the guest.
+		if _, err = ns.InstantiateModule(ctx, r.wasiModule, wazero.NewModuleConfig()); err != nil {
+			_ = ns.Close(ctx)
+			return nil, fmt.Errorf("wasm: error instantiating WASI: %w", err)
+		}
+	}
+
+	if r.memoryModule != nil {
+		config := wazero.NewModuleConfig().WithName(r.memoryImport)
+		if _, err = ns.InstantiateModule(ctx, r.memoryModule, config); err != nil {
+			_ = ns.Close(ctx)
+			return nil, fmt.Errorf("wasm: error instantiating memory: %w", err)
+		}
+	}
 
-	guest, err := ns.InstantiateModule(ctx, r.guestModule, r.config)
+	config, output := r.withOutput(r.config)
+	if r.snapshot != nil {
+		// The snapshot already includes the effects of start functions.
+		config = config.WithStartFunctions()
+	}
+	guest, err := ns.InstantiateModule(ctx, r.guestModule, config)
 	if err != nil {
 		_ = ns.Close(ctx)
 		return nil, fmt.Errorf("wasm: error instantiating guest: %w", err)
 	}
 
-	return &Guest{
-		ns:    ns,
-		guest: guest,
-	}, nil
+	g := &Guest{
+		r:            r,
+		ns:           ns,
+		guest:        guest,
+		output:       output,
+		guestExports: resolveExports(guest),
+		info: handler.GuestInfo{
+			Module:   r.guestModule.Name(),
+			Instance: atomic.AddUint64(r.instances, 1),
+			Digest:   r.digest,
+			Canary:   r.canary,
+		},
+	}
+	r.exports.Store(guest, g.guestExports)
+	if r.snapshot != nil {
+		if err = r.snapshot.restore(ctx, guest); err != nil {
+			r.exports.Delete(guest)
+			_ = ns.Close(ctx)
+			return nil, err
+		}
+	}
+	if r.snapshot == nil {
+		// Otherwise, the guest has the state of the config when the snapshot
+		// was taken, so applies any update before its first request.
+		_, g.configVersion = r.currentConfig()
+	}
+	if err = r.initGuest(ctx, g); err != nil {
+		r.exports.Delete(guest)
+		_ = ns.Close(ctx)
+		return nil, err
+	}
+	return g, nil
+}
+
+// compile-time check to ensure Guest implements handler.Handler.
+var _ handler.Handler = &Guest{}
+
+// Info identifies the guest, which is valid until it is closed.
+func (g *Guest) Info() *handler.GuestInfo {
+	return &g.info
 }
 
-// Handle calls the WebAssembly function export "handle".
+// Handle calls the WebAssembly function export "handle", or "handle_request"
+// and the next handler if it continues, followed by "handle_response", if
+// exported. When the guest is bypassed by its schedule, or the request is a
+// CORS preflight bypassed by configuration, this invokes the next handler
+// instead. When the guest config was updated since the guest last handled a
+// request, this first calls "on_config_update", if exported.
+//
+// When the guest traps, this responds according to configuration, then
+// returns a handler.GuestError. When the guest is already handling a request
+// on another goroutine, this returns handler.ErrGuestInUse without handling
+// the request.
 func (g *Guest) Handle(ctx context.Context) (err error) {
-	_, err = g.guest.ExportedFunction(handler.FuncHandle).Call(ctx)
+	if !atomic.CompareAndSwapInt32(&g.handling, 0, 1) {
+		return handler.ErrGuestInUse
+	}
+	defer atomic.StoreInt32(&g.handling, 0)
+
+	if g.r.bypassed() || (g.r.bypassPreflight && g.r.isPreflight(ctx)) {
+		g.r.host.Next(ctx)
+		return
+	}
+	if g.r.interruptOnClientGone && ctx.Err() != nil {
+		return // the client disconnected while waiting for the guest
+	}
+	if !g.r.concurrency.acquire(ctx) {
+		g.r.rejectOverloaded(ctx)
+		return
+	}
+	defer g.r.concurrency.release()
+
+	ctx = g.r.withGuestConfig(ctx)
+	ctx = g.r.withInjectedHeaders(ctx)
+	ctx = g.r.withQuotas(ctx)
+	ctx = g.r.withLastError(ctx)
+	ctx = g.r.withLogCount(ctx)
+	ctx = g.r.withStreaming(ctx)
+	ctx = g.r.withHostValues(ctx)
+	ctx = g.r.withGrowBuffers(ctx)
+	ctx = g.r.withCacheStore(ctx)
+	ctx = g.r.withHTTPCalls(ctx)
+	ctx, shadow := g.r.withShadow(ctx)
+
+	var s *handleState
+	if g.r.failurePolicy == api.FailOpen || g.r.latency != nil || g.handleRequest != nil || g.r.concurrency != nil {
+		s = &handleState{}
+		ctx = context.WithValue(ctx, handleStateKey{}, s)
+	}
+	start := g.r.clock.Nanotime()
+
+	aborted := false
+	err = g.updateConfig(ctx)
+	if err == nil && g.handleRequest != nil {
+		err = g.callHandleRequest(ctx, s)
+	} else if err == nil {
+		aborted, err = g.callHandle(ctx)
+	}
+	if err == nil && !aborted {
+		err = call(ctx, handler.FuncHandleResponse, g.handleResponse)
+	}
+	if guestErr, ok := err.(*handler.GuestError); ok && !clientGone(guestErr) {
+		g.r.handleGuestError(ctx, guestErr, s != nil && s.nextCalled)
+	} else if err == nil {
+		g.r.storeResponse(ctx)
+	}
+	if shadow != nil {
+		g.r.endShadow(ctx, shadow)
+	}
+	if g.r.latency != nil {
+		g.r.latency.record(ctx, g.r.clock.Nanotime()-start-s.nextNanos)
+	}
 	return
 }
 
+// callHandle calls the WebAssembly function export "handle", and interprets
+// its result, if any, as documented on handler.FuncHandle. This returns true
+// if the guest aborted with a status code.
+func (g *Guest) callHandle(ctx context.Context) (aborted bool, err error) {
+	result, err := callResult(ctx, handler.FuncHandle, g.handle)
+	if err != nil {
+		return false, err
+	}
+	switch code := uint32(result); {
+	case code == 0:
+		return false, nil
+	case code >= 400 && code <= 599:
+		if !g.r.host.IsResponseCommitted(ctx) {
+			g.r.host.SendResponse(ctx, code, nil)
+		}
+		return true, nil
+	default:
+		return false, &handler.GuestError{Func: handler.FuncHandle, Err: fmt.Errorf("returned error code %d", code)}
+	}
+}
+
+// callHandleRequest calls the WebAssembly function export
+// "handle_request", then invokes the next handler if it returned non-zero,
+// unless the guest already did.
+func (g *Guest) callHandleRequest(ctx context.Context, s *handleState) error {
+	next, err := callResult(ctx, handler.FuncHandleRequest, g.handleRequest)
+	if err != nil {
+		return err
+	}
+	if uint32(next) != 0 && !s.nextCalled {
+		g.r.next(ctx)
+	}
+	return nil
+}
+
 // Close implements api.Closer
 func (g *Guest) Close(ctx context.Context) error {
+	g.r.closeGuest(ctx, g)
+	g.r.exports.Delete(g.guest)
+	for _, w := range g.output {
+		w.flush()
+	}
 	// Closing the namespace closes both the host and guest modules
 	return g.ns.Close(ctx)
 }
 
+// getConfig is the WebAssembly function export named handler.FuncGetConfig
+// which writes the guest config to memory if it isn't larger than the buffer
+// size limit. The result is the length of the config in bytes.
+func (r *Runtime) getConfig(ctx context.Context, mod wazeroapi.Module,
+	buf, bufLimit uint32) (configLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncGetConfig)
+	config := r.guestConfigFromContext(ctx)
+	return writeIfUnderLimit(ctx, mod.Memory(), "config", buf, bufLimit, config)
+}
+
 // readRequestHeader is the WebAssembly function export named
 // handler.FuncReadRequestHeader which writes a header value to memory if it
 // exists and isn't larger than the buffer size limit. The result is
 // `1<<32|value_len` or zero if the header doesn't exist.
 func (r *Runtime) readRequestHeader(ctx context.Context, mod wazeroapi.Module,
 	name, nameLen, buf, bufLimit uint32) (result uint64) {
+	defer r.recoverHost(ctx, handler.FuncReadRequestHeader)
 	n := mustReadString(ctx, mod.Memory(), "name", name, nameLen)
 	value, ok := r.host.GetRequestHeader(ctx, n)
+	return r.writeValue(ctx, mod, value, ok, buf, bufLimit)
+}
+
+// getQueryValue is the WebAssembly function export named
+// handler.FuncGetQueryValue which writes a query parameter value to memory if
+// it exists and isn't larger than the buffer size limit. The result is
+// `1<<32|value_len` or zero if the parameter doesn't exist.
+func (r *Runtime) getQueryValue(ctx context.Context, mod wazeroapi.Module,
+	name, nameLen, buf, bufLimit uint32) (result uint64) {
+	defer r.recoverHost(ctx, handler.FuncGetQueryValue)
+	n := mustReadString(ctx, mod.Memory(), "name", name, nameLen)
+	value, ok := r.host.GetQueryValue(ctx, n)
+	return r.writeValue(ctx, mod, value, ok, buf, bufLimit)
+}
+
+// setQueryValue is the WebAssembly function export named
+// handler.FuncSetQueryValue which sets a query parameter from a name and
+// value read from memory.
+func (r *Runtime) setQueryValue(ctx context.Context, mod wazeroapi.Module,
+	name, nameLen, value, valueLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncSetQueryValue)
+	n := mustReadString(ctx, mod.Memory(), "name", name, nameLen)
+	v := mustReadString(ctx, mod.Memory(), "value", value, valueLen)
+	r.host.SetQueryValue(ctx, n, v)
+}
+
+// getCookie is the WebAssembly function export named handler.FuncGetCookie
+// which writes a request cookie value to memory if it exists and isn't larger
+// than the buffer size limit. The result is `1<<32|value_len` or zero if the
+// cookie doesn't exist.
+func (r *Runtime) getCookie(ctx context.Context, mod wazeroapi.Module,
+	name, nameLen, buf, bufLimit uint32) (result uint64) {
+	defer r.recoverHost(ctx, handler.FuncGetCookie)
+	n := mustReadString(ctx, mod.Memory(), "name", name, nameLen)
+	value, ok := r.host.GetCookie(ctx, n)
+	return r.writeValue(ctx, mod, value, ok, buf, bufLimit)
+}
+
+// setCookie is the WebAssembly function export named handler.FuncSetCookie
+// which adds a response cookie from a name, value and attributes read from
+// memory.
+func (r *Runtime) setCookie(ctx context.Context, mod wazeroapi.Module,
+	name, nameLen, value, valueLen, attrs, attrsLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncSetCookie)
+	n := mustReadString(ctx, mod.Memory(), "name", name, nameLen)
+	v := mustReadString(ctx, mod.Memory(), "value", value, valueLen)
+	a := mustReadString(ctx, mod.Memory(), "attrs", attrs, attrsLen)
+	if r.fail(ctx, r.chargeHeader(ctx, len(n)+len(v)+len(a))) {
+		return
+	}
+	r.host.SetCookie(ctx, n, v, a)
+}
+
+// readScratch is the WebAssembly function export named
+// handler.FuncReadScratch which writes the scratch area of the current request
+// to memory if it isn't larger than the buffer size limit. The result is the
+// length of the scratch area in bytes.
+func (r *Runtime) readScratch(ctx context.Context, mod wazeroapi.Module,
+	buf, bufLimit uint32) (scratchLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncReadScratch)
+	scratch := r.host.ReadScratch(ctx)
+	return writeIfUnderLimit(ctx, mod.Memory(), "scratch", buf, bufLimit, scratch)
+}
+
+// writeScratch is the WebAssembly function export named
+// handler.FuncWriteScratch which replaces the scratch area of the current
+// request with bytes read from memory.
+func (r *Runtime) writeScratch(ctx context.Context, mod wazeroapi.Module,
+	buf, bufLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncWriteScratch)
+	r.host.WriteScratch(ctx, mustRead(ctx, mod.Memory(), "scratch", buf, bufLen))
+}
+
+// getSourceAddr is the WebAssembly function export named
+// handler.FuncGetSourceAddr which writes the client address to memory if it
+// isn't larger than the buffer size limit. The result is the length of the
+// address in bytes.
+func (r *Runtime) getSourceAddr(ctx context.Context, mod wazeroapi.Module,
+	buf, bufLimit uint32) (addrLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncGetSourceAddr)
+	addr := r.host.GetSourceAddr(ctx)
+	return writeIfUnderLimit(ctx, mod.Memory(), "addr", buf, bufLimit, []byte(addr))
+}
+
+// getListenerAddr is the WebAssembly function export named
+// handler.FuncGetListenerAddr which writes the listener address to memory if
+// it isn't larger than the buffer size limit. The result is the length of the
+// address in bytes.
+func (r *Runtime) getListenerAddr(ctx context.Context, mod wazeroapi.Module,
+	buf, bufLimit uint32) (addrLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncGetListenerAddr)
+	addr := r.host.GetListenerAddr(ctx)
+	return writeIfUnderLimit(ctx, mod.Memory(), "addr", buf, bufLimit, []byte(addr))
+}
+
+// isUnixSocket is the WebAssembly function export named
+// handler.FuncIsUnixSocket, which returns one if the listener is a unix
+// domain socket or zero if not.
+func (r *Runtime) isUnixSocket(ctx context.Context) uint32 {
+	defer r.recoverHost(ctx, handler.FuncIsUnixSocket)
+	if r.host.IsUnixSocket(ctx) {
+		return 1
+	}
+	return 0
+}
+
+// getProxySourceAddr is the WebAssembly function export named
+// handler.FuncGetProxySourceAddr which writes the PROXY protocol source
+// address to memory if it exists and isn't larger than the buffer size limit.
+// The result is `1<<32|value_len` or zero if there is no source.
+func (r *Runtime) getProxySourceAddr(ctx context.Context, mod wazeroapi.Module,
+	buf, bufLimit uint32) (result uint64) {
+	defer r.recoverHost(ctx, handler.FuncGetProxySourceAddr)
+	addr, ok := r.host.GetProxySourceAddr(ctx)
+	return r.writeValue(ctx, mod, addr, ok, buf, bufLimit)
+}
+
+// getProtocolVersion is the WebAssembly function export named
+// handler.FuncGetProtocolVersion which writes the protocol version of the
+// request to memory if it isn't larger than the buffer size limit. The result
+// is the length of the version in bytes.
+func (r *Runtime) getProtocolVersion(ctx context.Context, mod wazeroapi.Module,
+	buf, bufLimit uint32) (versionLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncGetProtocolVersion)
+	version := r.host.GetProtocolVersion(ctx)
+	return writeIfUnderLimit(ctx, mod.Memory(), "version", buf, bufLimit, []byte(version))
+}
+
+// getTLSPeerCert is the WebAssembly function export named
+// handler.FuncGetTLSPeerCert which writes the client certificate to memory if
+// it isn't larger than the buffer size limit. The result is the length of the
+// certificate in bytes.
+func (r *Runtime) getTLSPeerCert(ctx context.Context, mod wazeroapi.Module,
+	buf, bufLimit uint32) (certLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncGetTLSPeerCert)
+	cert := r.host.GetTLSPeerCert(ctx)
+	return writeIfUnderLimit(ctx, mod.Memory(), "cert", buf, bufLimit, cert)
+}
+
+// readMultipartPart is the WebAssembly function export named
+// handler.FuncReadMultipartPart which writes the content of a multipart part
+// to memory if it exists and isn't larger than the buffer size limit. The
+// result is `1<<32|part_len` or zero if the part doesn't exist.
+func (r *Runtime) readMultipartPart(ctx context.Context, mod wazeroapi.Module,
+	name, nameLen, buf, bufLimit uint32) (result uint64) {
+	defer r.recoverHost(ctx, handler.FuncReadMultipartPart)
+	n := mustReadString(ctx, mod.Memory(), "name", name, nameLen)
+	part, ok := r.host.GetMultipartPart(ctx, n)
+	return r.writeValue(ctx, mod, string(part), ok, buf, bufLimit)
+}
+
+// getRequestTrailer is the WebAssembly function export named
+// handler.FuncGetRequestTrailer which writes a trailer value to memory if it
+// exists and isn't larger than the buffer size limit. The result is
+// `1<<32|value_len` or zero if the trailer doesn't exist.
+func (r *Runtime) getRequestTrailer(ctx context.Context, mod wazeroapi.Module,
+	name, nameLen, buf, bufLimit uint32) (result uint64) {
+	defer r.recoverHost(ctx, handler.FuncGetRequestTrailer)
+	n := mustReadString(ctx, mod.Memory(), "name", name, nameLen)
+	value, ok := r.host.GetRequestTrailer(ctx, n)
+	return r.writeValue(ctx, mod, value, ok, buf, bufLimit)
+}
+
+// setResponseTrailer is the WebAssembly function export named
+// handler.FuncSetResponseTrailer which sets a response trailer from a name
+// and value read from memory.
+func (r *Runtime) setResponseTrailer(ctx context.Context, mod wazeroapi.Module,
+	name, nameLen, value, valueLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncSetResponseTrailer)
+	n := mustReadString(ctx, mod.Memory(), "name", name, nameLen)
+	v := mustReadString(ctx, mod.Memory(), "value", value, valueLen)
+	if !r.admitHeader(ctx, n, v) {
+		return
+	}
+	r.host.SetResponseTrailer(ctx, n, v)
+}
+
+// getProperty is the WebAssembly function export named
+// handler.FuncGetProperty which writes a property value to memory if it
+// exists and isn't larger than the buffer size limit. The result is
+// `1<<32|value_len` or zero if the property doesn't exist.
+func (r *Runtime) getProperty(ctx context.Context, mod wazeroapi.Module,
+	name, nameLen, buf, bufLimit uint32) (result uint64) {
+	defer r.recoverHost(ctx, handler.FuncGetProperty)
+	n := mustReadString(ctx, mod.Memory(), "name", name, nameLen)
+	value, ok := r.host.GetProperty(ctx, n)
+	return r.writeValue(ctx, mod, value, ok, buf, bufLimit)
+}
+
+// setProperty is the WebAssembly function export named
+// handler.FuncSetProperty which sets a property from a name and value read
+// from memory.
+func (r *Runtime) setProperty(ctx context.Context, mod wazeroapi.Module,
+	name, nameLen, value, valueLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncSetProperty)
+	n := mustReadString(ctx, mod.Memory(), "name", name, nameLen)
+	v := mustReadString(ctx, mod.Memory(), "value", value, valueLen)
+	r.host.SetProperty(ctx, n, v)
+}
+
+// getUpgrade is the WebAssembly function export named handler.FuncGetUpgrade
+// which writes the protocol of a pending upgrade to memory if it isn't larger
+// than the buffer size limit. The result is the length of the protocol in
+// bytes, or zero if the request isn't an upgrade.
+func (r *Runtime) getUpgrade(ctx context.Context, mod wazeroapi.Module,
+	buf, bufLimit uint32) (upgradeLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncGetUpgrade)
+	connection, _ := r.host.GetRequestHeader(ctx, "Connection")
+	if !hasToken(connection, "upgrade") {
+		return 0
+	}
+	upgrade, _ := r.host.GetRequestHeader(ctx, "Upgrade")
+	return writeIfUnderLimit(ctx, mod.Memory(), "upgrade", buf, bufLimit, []byte(upgrade))
+}
+
+// getRPCService is the WebAssembly function export named
+// handler.FuncGetRPCService which writes the service of an RPC to memory if it
+// isn't larger than the buffer size limit. The result is the length of the
+// service in bytes, or zero if the request isn't an RPC.
+func (r *Runtime) getRPCService(ctx context.Context, mod wazeroapi.Module,
+	buf, bufLimit uint32) (serviceLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncGetRPCService)
+	service, _ := r.host.GetRPC(ctx)
+	return writeIfUnderLimit(ctx, mod.Memory(), "service", buf, bufLimit, []byte(service))
+}
+
+// getRPCMethod is the WebAssembly function export named
+// handler.FuncGetRPCMethod which writes the method of an RPC to memory if it
+// isn't larger than the buffer size limit. The result is the length of the
+// method in bytes, or zero if the request isn't an RPC.
+func (r *Runtime) getRPCMethod(ctx context.Context, mod wazeroapi.Module,
+	buf, bufLimit uint32) (methodLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncGetRPCMethod)
+	_, method := r.host.GetRPC(ctx)
+	return writeIfUnderLimit(ctx, mod.Memory(), "method", buf, bufLimit, []byte(method))
+}
+
+// getRoute is the WebAssembly function export named handler.FuncGetRoute
+// which writes the route pattern the request matched to memory if it isn't
+// larger than the buffer size limit. The result is the length of the pattern
+// in bytes, or zero if unknown.
+func (r *Runtime) getRoute(ctx context.Context, mod wazeroapi.Module,
+	buf, bufLimit uint32) (routeLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncGetRoute)
+	route := r.host.GetRoute(ctx)
+	return writeIfUnderLimit(ctx, mod.Memory(), "route", buf, bufLimit, []byte(route))
+}
+
+// getRequestID is the WebAssembly function export named
+// handler.FuncGetRequestID which writes the ID of the request to memory if it
+// isn't larger than the buffer size limit. The result is the length of the ID
+// in bytes, or zero if none.
+func (r *Runtime) getRequestID(ctx context.Context, mod wazeroapi.Module,
+	buf, bufLimit uint32) (idLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncGetRequestID)
+	id := r.host.GetRequestID(ctx)
+	return writeIfUnderLimit(ctx, mod.Memory(), "id", buf, bufLimit, []byte(id))
+}
+
+// hasToken returns true if the comma-separated header value includes the
+// token, ignoring case. Ex. "keep-alive, Upgrade" includes "upgrade"
+func hasToken(value, token string) bool {
+	for _, t := range strings.Split(value, ",") {
+		if strings.EqualFold(strings.TrimSpace(t), token) {
+			return true
+		}
+	}
+	return false
+}
+
+// emitAccessLog is the WebAssembly function export named
+// handler.FuncEmitAccessLog which passes an access log entry read from memory
+// to the api.AccessLogger, if any.
+func (r *Runtime) emitAccessLog(ctx context.Context, mod wazeroapi.Module, entry, entryLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncEmitAccessLog)
+	if r.accessLogger == nil {
+		return
+	}
+	e := mustRead(ctx, mod.Memory(), "entry", entry, entryLen)
+	if !json.Valid(e) {
+		r.logFn(ctx, "wasm: dropped access log entry which isn't valid JSON")
+		return
+	}
+	r.accessLogger.LogAccess(ctx, e)
+}
+
+// writeIfUnderLimit writes the value to memory if it isn't larger than the
+// buffer size limit. The result is the length of the value in bytes.
+func writeIfUnderLimit(ctx context.Context, mem wazeroapi.Memory, fieldName string, buf, bufLimit uint32, v []byte) (vLen uint32) {
+	vLen = uint32(len(v))
+	if vLen == 0 || vLen > bufLimit {
+		return // caller can retry with a larger bufLimit
+	}
+	mustWrite(ctx, mem, fieldName, buf, v)
+	return
+}
+
+// writeValue writes the value to memory if it exists and isn't larger than
+// the buffer size limit. The result is `1<<32|value_len` or zero if the value
+// doesn't exist. When the guest enabled handler.FeatureGrowBuffers, a larger
+// value is written to memory allocated by the guest instead.
+func (r *Runtime) writeValue(ctx context.Context, mod wazeroapi.Module, value string, ok bool, buf, bufLimit uint32) (result uint64) {
 	if !ok {
 		return // value doesn't exist
 	}
 	length := uint32(len(value))
 	result = uint64(1<<32) | uint64(length)
 	if length > bufLimit {
+		if growBuffers(ctx) {
+			return r.allocValue(ctx, mod, []byte(value))
+		}
 		return // caller can retry with a larger bufLimit
 	}
-	mod.Memory().Write(ctx, buf, []byte(value))
+	mustWrite(ctx, mod.Memory(), "value", buf, []byte(value))
 	return
 }
 
@@ -122,8 +1000,12 @@ func (r *Runtime) readRequestHeader(ctx context.Context, mod wazeroapi.Module,
 // `1<<32|value_len` or zero if the header doesn't exist.
 func (r *Runtime) setResponseHeader(ctx context.Context, mod wazeroapi.Module,
 	name, nameLen, value, valueLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncSetResponseHeader)
 	n := mustReadString(ctx, mod.Memory(), "name", name, nameLen)
 	v := mustReadString(ctx, mod.Memory(), "value", value, valueLen)
+	if !r.admitHeader(ctx, n, v) {
+		return
+	}
 	r.host.SetResponseHeader(ctx, n, v)
 }
 
@@ -132,21 +1014,276 @@ func (r *Runtime) setResponseHeader(ctx context.Context, mod wazeroapi.Module,
 // code and optional body.
 func (r *Runtime) sendResponse(ctx context.Context, mod wazeroapi.Module,
 	statusCode, body, bodyLenLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncSendResponse)
 	b := mustRead(ctx, mod.Memory(), "body", body, bodyLenLen)
+	if r.fail(ctx, r.chargeResponseBody(ctx, len(b))) {
+		return
+	}
 	r.host.SendResponse(ctx, statusCode, b)
 }
 
+// mirrorRequest is the WebAssembly function export named
+// handler.FuncMirrorRequest which sends a copy of the current request to the
+// destination with the given name. The result is one if it was mirrored or
+// zero if there is no destination with that name.
+func (r *Runtime) mirrorRequest(ctx context.Context, mod wazeroapi.Module,
+	name, nameLen uint32) uint32 {
+	defer r.recoverHost(ctx, handler.FuncMirrorRequest)
+	n := mustReadString(ctx, mod.Memory(), "name", name, nameLen)
+	url, ok := r.mirrorDestinations[n]
+	if !ok {
+		return 0
+	}
+	r.host.MirrorRequest(ctx, url)
+	return 1
+}
+
+// isResponseCommitted is the WebAssembly function export named
+// handler.FuncIsResponseCommitted which returns one if the response was
+// committed or zero if not.
+func (r *Runtime) isResponseCommitted(ctx context.Context) uint32 {
+	defer r.recoverHost(ctx, handler.FuncIsResponseCommitted)
+	if r.host.IsResponseCommitted(ctx) {
+		return 1
+	}
+	return 0
+}
+
+// getResponseBodySize is the WebAssembly function export named
+// handler.FuncGetResponseBodySize, which returns the count of bytes of the
+// response body written so far.
+func (r *Runtime) getResponseBodySize(ctx context.Context) uint64 {
+	defer r.recoverHost(ctx, handler.FuncGetResponseBodySize)
+	return r.host.GetResponseBodySize(ctx)
+}
+
+// getRequestBodySize is the WebAssembly function export named
+// handler.FuncGetRequestBodySize, which returns the declared size of the
+// request body, or -1 if unknown.
+func (r *Runtime) getRequestBodySize(ctx context.Context) uint64 {
+	defer r.recoverHost(ctx, handler.FuncGetRequestBodySize)
+	return uint64(r.host.GetRequestBodySize(ctx))
+}
+
+// isRequestChunked is the WebAssembly function export named
+// handler.FuncIsRequestChunked, which returns one if the request body is
+// chunked or zero if not.
+func (r *Runtime) isRequestChunked(ctx context.Context) uint32 {
+	defer r.recoverHost(ctx, handler.FuncIsRequestChunked)
+	if r.host.IsRequestChunked(ctx) {
+		return 1
+	}
+	return 0
+}
+
+// getStatusCode is the WebAssembly function export named
+// handler.FuncGetStatusCode, which returns the status code of the response.
+func (r *Runtime) getStatusCode(ctx context.Context) uint32 {
+	defer r.recoverHost(ctx, handler.FuncGetStatusCode)
+	return r.host.GetStatusCode(ctx)
+}
+
+// setStatusCode is the WebAssembly function export named
+// handler.FuncSetStatusCode, which sets the status code of the response.
+func (r *Runtime) setStatusCode(ctx context.Context, statusCode uint32) {
+	defer r.recoverHost(ctx, handler.FuncSetStatusCode)
+	r.host.SetStatusCode(ctx, statusCode)
+}
+
+// getTLSVersion is the WebAssembly function export named
+// handler.FuncGetTLSVersion, which returns the TLS version of the request, or
+// zero if it wasn't received over TLS.
+func (r *Runtime) getTLSVersion(ctx context.Context) uint32 {
+	defer r.recoverHost(ctx, handler.FuncGetTLSVersion)
+	return r.host.GetTLSVersion(ctx)
+}
+
 func (r *Runtime) compileHost(ctx context.Context) (wazero.CompiledModule, error) {
-	if compiled, err := r.runtime.NewHostModuleBuilder(handler.HostModule).
+	if compiled, err := r.newHostModuleBuilder().
 		ExportFunction("log", r.log,
 			"log", "ptr", "size").
+		ExportFunction(handler.FuncGetConfig, r.getConfig,
+			handler.FuncGetConfig, "buf", "buf_limit").
 		ExportFunction(handler.FuncReadRequestHeader, r.readRequestHeader,
 			handler.FuncReadRequestHeader, "name", "name_len", "buf", "buf_limit").
 		ExportFunction(handler.FuncSetResponseHeader, r.setResponseHeader,
 			handler.FuncSetResponseHeader, "name", "name_len", "value", "value_len").
 		ExportFunction(handler.FuncSendResponse, r.sendResponse,
 			handler.FuncSendResponse, "status_code", "body", "body_len").
-		ExportFunction(handler.FuncNext, r.host.Next,
+		ExportFunction(handler.FuncMirrorRequest, r.mirrorRequest,
+			handler.FuncMirrorRequest, "name", "name_len").
+		ExportFunction(handler.FuncGetStatusCode, r.getStatusCode,
+			handler.FuncGetStatusCode).
+		ExportFunction(handler.FuncSetStatusCode, r.setStatusCode,
+			handler.FuncSetStatusCode, "status_code").
+		ExportFunction(handler.FuncIsResponseCommitted, r.isResponseCommitted,
+			handler.FuncIsResponseCommitted).
+		ExportFunction(handler.FuncGetResponseBodySize, r.getResponseBodySize,
+			handler.FuncGetResponseBodySize).
+		ExportFunction(handler.FuncGetRequestBodySize, r.getRequestBodySize,
+			handler.FuncGetRequestBodySize).
+		ExportFunction(handler.FuncIsRequestChunked, r.isRequestChunked,
+			handler.FuncIsRequestChunked).
+		ExportFunction(handler.FuncGetQueryValue, r.getQueryValue,
+			handler.FuncGetQueryValue, "name", "name_len", "buf", "buf_limit").
+		ExportFunction(handler.FuncSetQueryValue, r.setQueryValue,
+			handler.FuncSetQueryValue, "name", "name_len", "value", "value_len").
+		ExportFunction(handler.FuncGetCookie, r.getCookie,
+			handler.FuncGetCookie, "name", "name_len", "buf", "buf_limit").
+		ExportFunction(handler.FuncSetCookie, r.setCookie,
+			handler.FuncSetCookie, "name", "name_len", "value", "value_len", "attrs", "attrs_len").
+		ExportFunction(handler.FuncReadScratch, r.readScratch,
+			handler.FuncReadScratch, "buf", "buf_limit").
+		ExportFunction(handler.FuncWriteScratch, r.writeScratch,
+			handler.FuncWriteScratch, "buf", "buf_len").
+		ExportFunction(handler.FuncGetSourceAddr, r.getSourceAddr,
+			handler.FuncGetSourceAddr, "buf", "buf_limit").
+		ExportFunction(handler.FuncGetListenerAddr, r.getListenerAddr,
+			handler.FuncGetListenerAddr, "buf", "buf_limit").
+		ExportFunction(handler.FuncIsUnixSocket, r.isUnixSocket,
+			handler.FuncIsUnixSocket).
+		ExportFunction(handler.FuncGetProxySourceAddr, r.getProxySourceAddr,
+			handler.FuncGetProxySourceAddr, "buf", "buf_limit").
+		ExportFunction(handler.FuncGetTLSVersion, r.getTLSVersion,
+			handler.FuncGetTLSVersion).
+		ExportFunction(handler.FuncGetTLSPeerCert, r.getTLSPeerCert,
+			handler.FuncGetTLSPeerCert, "buf", "buf_limit").
+		ExportFunction(handler.FuncGetProtocolVersion, r.getProtocolVersion,
+			handler.FuncGetProtocolVersion, "buf", "buf_limit").
+		ExportFunction(handler.FuncReadMultipartPart, r.readMultipartPart,
+			handler.FuncReadMultipartPart, "name", "name_len", "buf", "buf_limit").
+		ExportFunction(handler.FuncGetFormValue, r.getFormValue,
+			handler.FuncGetFormValue, "name", "name_len", "buf", "buf_limit").
+		ExportFunction(handler.FuncGetUploadedFileInfo, r.getUploadedFileInfo,
+			handler.FuncGetUploadedFileInfo, "name", "name_len", "buf", "buf_limit").
+		ExportFunction(handler.FuncGetRequestTrailer, r.getRequestTrailer,
+			handler.FuncGetRequestTrailer, "name", "name_len", "buf", "buf_limit").
+		ExportFunction(handler.FuncSetResponseTrailer, r.setResponseTrailer,
+			handler.FuncSetResponseTrailer, "name", "name_len", "value", "value_len").
+		ExportFunction(handler.FuncExtract, r.extract,
+			handler.FuncExtract, "name", "name_len", "buf", "buf_limit").
+		ExportFunction(handler.FuncEnableRequestBodyChunks, r.enableRequestBodyChunks,
+			handler.FuncEnableRequestBodyChunks, "buf", "buf_limit").
+		ExportFunction(handler.FuncSendProblem, r.sendProblem,
+			handler.FuncSendProblem, "status_code", "code", "code_len", "detail", "detail_len").
+		ExportFunction(handler.FuncSendRedirect, r.sendRedirect,
+			handler.FuncSendRedirect, "status_code", "location", "location_len").
+		ExportFunction(handler.FuncSetUpstream, r.setUpstream,
+			handler.FuncSetUpstream, "upstream", "upstream_len").
+		ExportFunction(handler.FuncSetTimeoutMs, r.setTimeoutMs,
+			handler.FuncSetTimeoutMs, "timeout_ms").
+		ExportFunction(handler.FuncSetRetryPolicy, r.setRetryPolicy,
+			handler.FuncSetRetryPolicy, "attempts", "backoff_ms").
+		ExportFunction(handler.FuncReadRequestBody, r.readRequestBody,
+			handler.FuncReadRequestBody).
+		ExportFunction(handler.FuncSendLocalizedResponse, r.sendLocalizedResponse,
+			handler.FuncSendLocalizedResponse, "status_code", "key", "key_len").
+		ExportFunction(handler.FuncGetProperty, r.getProperty,
+			handler.FuncGetProperty, "name", "name_len", "buf", "buf_limit").
+		ExportFunction(handler.FuncSetProperty, r.setProperty,
+			handler.FuncSetProperty, "name", "name_len", "value", "value_len").
+		ExportFunction(handler.FuncGetHostValue, r.getHostValue,
+			handler.FuncGetHostValue, "key", "key_len", "buf", "buf_limit").
+		ExportFunction(handler.FuncSetHostValue, r.setHostValue,
+			handler.FuncSetHostValue, "key", "key_len", "value", "value_len").
+		ExportFunction(handler.FuncSuppressInjectedHeaders, r.suppressInjectedHeaders,
+			handler.FuncSuppressInjectedHeaders).
+		ExportFunction(handler.FuncHMAC, r.hmac,
+			handler.FuncHMAC, "alg", "alg_len", "key_id", "key_id_len", "data", "data_len", "buf", "buf_limit").
+		ExportFunction(handler.FuncVerifySignature, r.verifySignature,
+			handler.FuncVerifySignature, "alg", "alg_len", "key_id", "key_id_len", "data", "data_len", "sig", "sig_len").
+		ExportFunction(handler.FuncCacheLookup, r.cacheLookup,
+			handler.FuncCacheLookup, "key", "key_len").
+		ExportFunction(handler.FuncCacheStore, r.cacheStore,
+			handler.FuncCacheStore, "key", "key_len", "ttl_secs").
+		ExportFunction(handler.FuncRateLimitCheck, r.rateLimitCheck,
+			handler.FuncRateLimitCheck, "key", "key_len", "tokens").
+		ExportFunction(handler.FuncDefineMetric, r.defineMetric,
+			handler.FuncDefineMetric, "kind", "name", "name_len").
+		ExportFunction(handler.FuncIncrementMetric, r.incrementMetric,
+			handler.FuncIncrementMetric, "metric_id", "delta").
+		ExportFunction(handler.FuncRecordMetric, r.recordMetric,
+			handler.FuncRecordMetric, "metric_id", "value").
+		ExportFunction(handler.FuncGetShared, r.getShared,
+			handler.FuncGetShared, "key", "key_len", "buf", "buf_limit").
+		ExportFunction(handler.FuncSetShared, r.setShared,
+			handler.FuncSetShared, "key", "key_len", "value", "value_len", "ttl_millis").
+		ExportFunction(handler.FuncCasShared, r.casShared,
+			handler.FuncCasShared, "key", "key_len", "old", "old_len", "value", "value_len", "ttl_millis").
+		ExportFunction(handler.FuncReadState, r.readState,
+			handler.FuncReadState, "key", "key_len", "buf", "buf_limit").
+		ExportFunction(handler.FuncWriteState, r.writeState,
+			handler.FuncWriteState, "key", "key_len", "value", "value_len").
+		ExportFunction(handler.FuncGetTimeNanos, r.getTimeNanos,
+			handler.FuncGetTimeNanos).
+		ExportFunction(handler.FuncGetMonotonicNanos, r.getMonotonicNanos,
+			handler.FuncGetMonotonicNanos).
+		ExportFunction(handler.FuncGetRandom, r.getRandom,
+			handler.FuncGetRandom, "buf", "buf_len").
+		ExportFunction(handler.FuncSetError, r.setError,
+			handler.FuncSetError, "code", "code_len").
+		ExportFunction(handler.FuncGetLastError, r.getLastError,
+			handler.FuncGetLastError).
+		ExportFunction(handler.FuncHTTPCall, r.httpCall,
+			handler.FuncHTTPCall, "method", "method_len", "url", "url_len",
+			"headers", "headers_len", "body", "body_len", "timeout_millis",
+			"buf", "buf_limit").
+		ExportFunction(handler.FuncResolve, r.resolve,
+			handler.FuncResolve, "name", "name_len", "buf", "buf_limit").
+		ExportFunction(handler.FuncEnableFeatures, r.enableFeatures,
+			handler.FuncEnableFeatures, "features").
+		ExportFunction(handler.FuncReadResponseBody, r.readResponseBody,
+			handler.FuncReadResponseBody, "buf", "buf_limit").
+		ExportFunction(handler.FuncReadResponseBodyAlloc, r.readResponseBodyAlloc,
+			handler.FuncReadResponseBodyAlloc).
+		ExportFunction(handler.FuncReadRequestHeaderAlloc, r.readRequestHeaderAlloc,
+			handler.FuncReadRequestHeaderAlloc, "name", "name_len").
+		ExportFunction(handler.FuncReadRequestHeaders, r.readRequestHeaders,
+			handler.FuncReadRequestHeaders, "buf", "buf_limit").
+		ExportFunction(handler.FuncWriteResponseHeaders, r.writeResponseHeaders,
+			handler.FuncWriteResponseHeaders, "buf", "buf_len").
+		ExportFunction(handler.FuncReadRawRequestHeaders, r.readRawRequestHeaders,
+			handler.FuncReadRawRequestHeaders, "buf", "buf_limit").
+		ExportFunction(handler.FuncSetRawResponseHeader, r.setRawResponseHeader,
+			handler.FuncSetRawResponseHeader, "name", "name_len", "value", "value_len").
+		ExportFunction(handler.FuncReadQueryParams, r.readQueryParams,
+			handler.FuncReadQueryParams, "buf", "buf_limit").
+		ExportFunction(handler.FuncReadProperties, r.readProperties,
+			handler.FuncReadProperties, "buf", "buf_limit").
+		ExportFunction(handler.FuncGetRequestOrigin, r.getRequestOrigin,
+			handler.FuncGetRequestOrigin, "buf", "buf_limit").
+		ExportFunction(handler.FuncSetCORSHeaders, r.setCORSHeaders,
+			handler.FuncSetCORSHeaders, "origin", "origin_len", "methods", "methods_len",
+			"headers", "headers_len", "max_age_secs", "credentials").
+		ExportFunction(handler.FuncCapabilities, r.capabilities,
+			handler.FuncCapabilities).
+		ExportFunction(handler.FuncGetBufferLimit, r.getBufferLimit,
+			handler.FuncGetBufferLimit).
+		ExportFunction(handler.FuncGetUpgrade, r.getUpgrade,
+			handler.FuncGetUpgrade, "buf", "buf_limit").
+		ExportFunction(handler.FuncGetRPCService, r.getRPCService,
+			handler.FuncGetRPCService, "buf", "buf_limit").
+		ExportFunction(handler.FuncGetRPCMethod, r.getRPCMethod,
+			handler.FuncGetRPCMethod, "buf", "buf_limit").
+		ExportFunction(handler.FuncGetRoute, r.getRoute,
+			handler.FuncGetRoute, "buf", "buf_limit").
+		ExportFunction(handler.FuncGetRequestID, r.getRequestID,
+			handler.FuncGetRequestID, "buf", "buf_limit").
+		ExportFunction(handler.FuncEnableStreamingResponse, r.enableStreamingResponse,
+			handler.FuncEnableStreamingResponse).
+		ExportFunction(handler.FuncEmitAccessLog, r.emitAccessLog,
+			handler.FuncEmitAccessLog, "entry", "entry_len").
+		ExportFunction(handler.FuncEmitEvent, r.emitEvent,
+			handler.FuncEmitEvent, "topic", "topic_len", "payload", "payload_len").
+		ExportFunction(handler.FuncScheduleTick, r.scheduleTick,
+			handler.FuncScheduleTick, "period_ms").
+		ExportFunction(handler.FuncSniffContentType, r.sniffContentType,
+			handler.FuncSniffContentType, "data", "data_len", "buf", "buf_limit").
+		ExportFunction(handler.FuncNegotiateContentType, r.negotiateContentType,
+			handler.FuncNegotiateContentType, "offers", "offers_len", "buf", "buf_limit").
+		ExportFunction(handler.FuncIsClientGone, r.isClientGone,
+			handler.FuncIsClientGone).
+		ExportFunction(handler.FuncNext, r.next,
 			handler.FuncNext).
 		Compile(ctx); err != nil {
 		return nil, fmt.Errorf("wasm: error compiling host: %w", err)
@@ -155,25 +1292,40 @@ func (r *Runtime) compileHost(ctx context.Context) (wazero.CompiledModule, error
 	}
 }
 
-func (r *Runtime) compileGuest(ctx context.Context, wasm []byte) (wazero.CompiledModule, error) {
-	if guest, err := r.runtime.CompileModule(ctx, wasm); err != nil {
-		return nil, fmt.Errorf("wasm: error compiling guest: %w", err)
-	} else if handle, ok := guest.ExportedFunctions()[handler.FuncHandle]; !ok {
-		return nil, fmt.Errorf("wasm: guest doesn't export func[%s]", handler.FuncHandle)
-	} else if len(handle.ParamTypes()) != 0 || len(handle.ResultTypes()) != 0 {
-		return nil, fmt.Errorf("wasm: guest exports the wrong signature for func[%s]. should be nullary", handler.FuncHandle)
-	} else if _, ok = guest.ExportedMemories()[api.Memory]; !ok {
-		return nil, fmt.Errorf("wasm: guest doesn't export memory[%s]", api.Memory)
-	} else {
-		return guest, nil
+func (r *Runtime) compileGuest(ctx context.Context, o *internal.WazeroOptions, wasm []byte) (wazero.CompiledModule, error) {
+	guest, report, err := o.CompileGuest(ctx, r.runtime, wasm)
+	if err != nil {
+		return nil, err
 	}
+	r.compileReport = report
+
+	exports := guest.ExportedFunctions()
+	handle, ok := exports[handler.FuncHandle]
+	_, okRequest := exports[handler.FuncHandleRequest]
+	if !ok && !okRequest {
+		return nil, invalidGuest(handler.ErrMissingHandleExport, "guest doesn't export func[%s] or func[%s]", handler.FuncHandle, handler.FuncHandleRequest)
+	} else if ok && okRequest {
+		return nil, invalidGuest(handler.ErrBadHandleSignature, "guest exports both func[%s] and func[%s]", handler.FuncHandle, handler.FuncHandleRequest)
+	} else if ok && !nullary.matches(handle) && !handleResult.matches(handle) {
+		return nil, invalidGuest(handler.ErrBadHandleSignature, "guest exports the wrong signature for func[%s]. should be %s or %s", handler.FuncHandle, nullary, handleResult)
+	} else if len(guest.ExportedMemories()) == 0 && len(guest.ImportedMemories()) == 0 {
+		return nil, invalidGuest(handler.ErrMissingMemory, "guest doesn't export memory[%s] or import a memory", api.Memory)
+	} else if err = checkOptionalExports(guest); err != nil {
+		return nil, err
+	} else if err = checkImports(guest, r.hostModule, o.AllowedCapabilities); err != nil {
+		return nil, err
+	}
+	return guest, nil
 }
 
 // log implements the WebAssembly function export "log". It has
 // the same signature as api.LogFunc.
 func (r *Runtime) log(ctx context.Context, mod wazeroapi.Module, ptr, size uint32) {
+	defer r.recoverHost(ctx, "log")
 	msg := mustReadString(ctx, mod.Memory(), "msg", ptr, size)
-	r.logFn(ctx, msg)
+	if msg, ok := r.limitLog(ctx, msg); ok {
+		r.logFn(ctx, msg)
+	}
 }
 
 // mustReadString is a convenience function that casts mustRead
@@ -197,3 +1349,11 @@ func mustRead(ctx context.Context, mem wazeroapi.Memory, fieldName string, offse
 	}
 	return buf
 }
+
+// mustWrite is like api.Memory except that it panics if the offset and
+// length of the value are out of range.
+func mustWrite(ctx context.Context, mem wazeroapi.Memory, fieldName string, offset uint32, val []byte) {
+	if ok := mem.Write(ctx, offset, val); !ok {
+		panic(fmt.Errorf("out of memory writing %s", fieldName))
+	}
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/api"
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// maxAuditString is the maximum length of a string in an audit summary, so
+// that large values such as bodies don't flood logs.
+const maxAuditString = 64
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	moduleType  = reflect.TypeOf((*wazeroapi.Module)(nil)).Elem()
+)
+
+// newHostModuleBuilder returns the builder of handler.HostModule, which
+// audits calls when the runtime has an api.AuditFunc.
+func (r *Runtime) newHostModuleBuilder() wazero.HostModuleBuilder {
+	b := r.runtime.NewHostModuleBuilder(handler.HostModule)
+	if r.auditFn == nil {
+		return b
+	}
+	return &auditingBuilder{HostModuleBuilder: b, auditFn: r.auditFn}
+}
+
+// auditingBuilder wraps each function exported with ExportFunction so that
+// its calls are passed to auditFn.
+type auditingBuilder struct {
+	wazero.HostModuleBuilder
+	auditFn api.AuditFunc
+}
+
+// ExportFunction implements the same method as documented on
+// wazero.HostModuleBuilder.
+func (b *auditingBuilder) ExportFunction(exportName string, goFunc interface{}, names ...string) wazero.HostModuleBuilder {
+	b.HostModuleBuilder.ExportFunction(exportName, b.audit(exportName, goFunc, names), names...)
+	return b
+}
+
+// audit returns a function of the same signature as goFunc, which passes a
+// summary of each call to auditFn. names are the function name followed by
+// its parameter names, as passed to ExportFunction.
+func (b *auditingBuilder) audit(exportName string, goFunc interface{}, names []string) interface{} {
+	fn := reflect.ValueOf(goFunc)
+	var paramNames []string
+	if len(names) > 0 {
+		paramNames = names[1:]
+	}
+	return reflect.MakeFunc(fn.Type(), func(in []reflect.Value) []reflect.Value {
+		start := time.Now()
+		out := fn.Call(in)
+		d := time.Since(start)
+
+		ctx, args := auditArgs(in, paramNames)
+		b.auditFn(ctx, exportName, args, d)
+		return out
+	}).Interface()
+}
+
+// auditArgs returns the context of a call and summarizes its parameters as
+// "name=value" pairs. When a parameter "x" is followed by "x_len", they are
+// read from memory as a string, unless they are a buffer the host writes.
+func auditArgs(in []reflect.Value, paramNames []string) (context.Context, string) {
+	ctx := context.Background()
+	var mem wazeroapi.Memory
+	var params []uint64
+	for _, v := range in {
+		switch {
+		case v.Type() == contextType:
+			ctx = v.Interface().(context.Context)
+		case v.Type() == moduleType:
+			if mod, ok := v.Interface().(wazeroapi.Module); ok && mod != nil {
+				mem = mod.Memory()
+			}
+		default:
+			params = append(params, v.Uint())
+		}
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(params); i++ {
+		if i > 0 {
+			sb.WriteByte(' ')
+		}
+		name := fmt.Sprintf("param%d", i)
+		if i < len(paramNames) {
+			name = paramNames[i]
+		}
+		sb.WriteString(name)
+		sb.WriteByte('=')
+
+		isString := name != "buf" && mem != nil && i+1 < len(params) &&
+			i+1 < len(paramNames) && paramNames[i+1] == name+"_len"
+		if !isString {
+			sb.WriteString(fmt.Sprint(params[i]))
+			continue
+		}
+		size := params[i+1]
+		truncated := size > maxAuditString
+		if truncated {
+			size = maxAuditString
+		}
+		if b, ok := mem.Read(ctx, uint32(params[i]), uint32(size)); ok {
+			s := fmt.Sprintf("%q", b)
+			if truncated {
+				s += "..."
+			}
+			sb.WriteString(s)
+		} else {
+			sb.WriteString("<out of range>")
+		}
+		i++ // skip the length
+	}
+	return ctx, sb.String()
+}
@@ -0,0 +1,9 @@
+package handler
+
+import "github.com/http-wasm/http-wasm-host-go/internal"
+
+// BodyLimits returns the limits of buffering request and response bodies,
+// which are zero unless configured.
+func (r *Runtime) BodyLimits() internal.BodyLimits {
+	return r.bodyLimits
+}
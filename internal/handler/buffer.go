@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// defaultBufferLimit is the default result of handler.FuncGetBufferLimit.
+const defaultBufferLimit = 2048
+
+// getBufferLimit is the WebAssembly function export named
+// handler.FuncGetBufferLimit, which returns the buffer size guests should
+// use by default.
+func (r *Runtime) getBufferLimit(ctx context.Context) uint32 {
+	defer r.recoverHost(ctx, handler.FuncGetBufferLimit)
+	return r.bufferLimit
+}
+
+// growBuffersKey is a context.Context Value associated with a bool pointer,
+// which is true when the guest enabled handler.FeatureGrowBuffers. This is
+// only present when the runtime supports it.
+type growBuffersKey struct{}
+
+// withGrowBuffers returns a context which tracks whether the guest enabled
+// handler.FeatureGrowBuffers, if supported.
+func (r *Runtime) withGrowBuffers(ctx context.Context) context.Context {
+	if r.features&handler.FeatureGrowBuffers == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, growBuffersKey{}, new(bool))
+}
+
+// enableGrowBuffers enables handler.FeatureGrowBuffers for the current
+// request.
+func enableGrowBuffers(ctx context.Context) {
+	if g, ok := ctx.Value(growBuffersKey{}).(*bool); ok {
+		*g = true
+	}
+}
+
+// growBuffers returns true if the guest enabled
+// handler.FeatureGrowBuffers.
+func growBuffers(ctx context.Context) bool {
+	g, ok := ctx.Value(growBuffersKey{}).(*bool)
+	return ok && *g
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/api"
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// cacheStoreKey is a context.Context Value associated with a *cacheStore.
+type cacheStoreKey struct{}
+
+// cacheStore is the response to store after the guest handled the request,
+// set via handler.FuncCacheStore.
+type cacheStore struct {
+	key string
+	ttl time.Duration
+}
+
+// withCacheStore returns a context to track the response the guest stores,
+// unless there is no response cache.
+func (r *Runtime) withCacheStore(ctx context.Context) context.Context {
+	if r.responseCache == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, cacheStoreKey{}, &cacheStore{})
+}
+
+// cacheLookup is the WebAssembly function export named
+// handler.FuncCacheLookup which sends the cached response of a key read from
+// memory, if it exists. The result is one if it was sent.
+func (r *Runtime) cacheLookup(ctx context.Context, mod wazeroapi.Module,
+	key, keyLen uint32) uint32 {
+	defer r.recoverHost(ctx, handler.FuncCacheLookup)
+	if r.responseCache == nil {
+		return 0
+	}
+	k := mustReadString(ctx, mod.Memory(), "key", key, keyLen)
+	resp, ok, err := r.responseCache.Get(ctx, k)
+	if err != nil {
+		panic(fmt.Errorf("error looking up cached response %q: %w", k, err))
+	} else if !ok {
+		return 0
+	}
+	for name, values := range resp.Header {
+		r.host.SetResponseHeader(ctx, name, strings.Join(values, ", "))
+	}
+	r.host.SendResponse(ctx, resp.StatusCode, resp.Body)
+	return 1
+}
+
+// cacheStore is the WebAssembly function export named
+// handler.FuncCacheStore which stores the response of the request by a key
+// read from memory, after the guest handled it.
+func (r *Runtime) cacheStore(ctx context.Context, mod wazeroapi.Module,
+	key, keyLen, ttlSecs uint32) {
+	defer r.recoverHost(ctx, handler.FuncCacheStore)
+	s, ok := ctx.Value(cacheStoreKey{}).(*cacheStore)
+	if !ok {
+		return // no response cache
+	}
+	k := mustReadString(ctx, mod.Memory(), "key", key, keyLen)
+	// Buffer the response, so that its body can be stored. This has no
+	// effect if the response was already committed.
+	if r.host.EnableFeatures(ctx, handler.FeatureBufferResponse)&handler.FeatureBufferResponse == 0 {
+		return
+	}
+	s.key, s.ttl = k, time.Duration(ttlSecs)*time.Second
+}
+
+// storeResponse stores the response of the request, if the guest called
+// handler.FuncCacheStore.
+func (r *Runtime) storeResponse(ctx context.Context) {
+	s, ok := ctx.Value(cacheStoreKey{}).(*cacheStore)
+	if !ok || s.key == "" {
+		return
+	}
+	defer func() {
+		// The host panics with handler.ErrBodyTooLarge when the body is too
+		// large to decode, in which case the response failed, so isn't
+		// stored.
+		if recovered := recover(); recovered != nil && recovered != handler.ErrBodyTooLarge {
+			panic(recovered)
+		}
+	}()
+	resp := &api.CachedResponse{
+		StatusCode: r.host.GetStatusCode(ctx),
+		Header:     map[string][]string{},
+		Body:       r.host.GetResponseBody(ctx),
+	}
+	for name, values := range r.host.GetResponseHeaders(ctx) {
+		if !strings.EqualFold(name, "Set-Cookie") {
+			resp.Header[name] = values
+		}
+	}
+	if err := r.responseCache.Set(ctx, s.key, resp, s.ttl); err != nil {
+		r.logFn(ctx, fmt.Sprintf("wasm: error storing cached response %q: %v", s.key, err))
+	}
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// enableRequestBodyChunks is the WebAssembly function export named
+// handler.FuncEnableRequestBodyChunks which streams the request body through
+// the guest function export handler.FuncHandleRequestBodyChunk.
+func (r *Runtime) enableRequestBodyChunks(ctx context.Context, mod wazeroapi.Module,
+	buf, bufLimit uint32) {
+	defer r.recoverHost(ctx, handler.FuncEnableRequestBodyChunks)
+	fn := r.exportsOf(mod).handleRequestBodyChunk
+	if fn == nil {
+		panic(fmt.Errorf("guest doesn't export func[%s]", handler.FuncHandleRequestBodyChunk))
+	}
+	mem := mod.Memory()
+	r.host.StreamRequestBody(ctx, bufLimit, func(chunk []byte, eos bool) []byte {
+		mustWrite(ctx, mem, "chunk", buf, chunk)
+		var endOfStream uint64
+		if eos {
+			endOfStream = 1
+		}
+		results, err := fn.Call(ctx, uint64(len(chunk)), endOfStream)
+		if err != nil {
+			panic(err)
+		}
+		chunkLen := uint32(results[0])
+		if chunkLen > bufLimit {
+			panic(fmt.Errorf("chunk_len %d > buf_limit %d", chunkLen, bufLimit))
+		}
+		return mustRead(ctx, mem, "chunk", buf, chunkLen)
+	})
+}
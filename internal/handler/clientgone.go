@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// errClientGone is a trap when internal.WazeroOptions InterruptOnClientGone
+// is set and the client disconnected.
+var errClientGone = errors.New("client disconnected")
+
+// isClientGone is the WebAssembly function export named
+// handler.FuncIsClientGone, which returns one if the client disconnected.
+func (r *Runtime) isClientGone(ctx context.Context) uint32 {
+	if ctx.Err() != nil {
+		return 1
+	}
+	return 0
+}
+
+// interruptIfClientGone traps the guest if it should be interrupted, as the
+// client disconnected. This is called by recoverHost, so that guests are
+// interrupted on their next call to the host.
+func (r *Runtime) interruptIfClientGone(ctx context.Context, name string) {
+	if !r.interruptOnClientGone || name == handler.FuncIsClientGone {
+		return
+	}
+	if ctx.Err() != nil {
+		panic(errClientGone)
+	}
+}
+
+// clientGone returns true if the guest was interrupted, as the client
+// disconnected, so there is no response to send.
+func clientGone(err *handler.GuestError) bool {
+	return errors.Is(err.Err, errClientGone)
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+
+	"github.com/http-wasm/http-wasm-host-go/api"
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// systemClock is the default api.Clock.
+type systemClock struct{}
+
+// start is the point Nanotime of the system clock is relative to.
+var start = time.Now()
+
+// Now implements the same method as documented on api.Clock.
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Nanotime implements the same method as documented on api.Clock.
+func (systemClock) Nanotime() int64 {
+	return int64(time.Since(start)) // monotonic, as start has a reading
+}
+
+// withClock returns a copy of the module config which uses the clock for
+// WASI, so that guests see the same time regardless of how they read it.
+func withClock(config wazero.ModuleConfig, clock api.Clock) wazero.ModuleConfig {
+	return config.
+		WithWalltime(func(context.Context) (sec int64, nsec int32) {
+			now := clock.Now()
+			return now.Unix(), int32(now.Nanosecond())
+		}, 1).
+		WithNanotime(func(context.Context) int64 {
+			return clock.Nanotime()
+		}, 1)
+}
+
+// getTimeNanos is the WebAssembly function export named
+// handler.FuncGetTimeNanos which returns the wall clock time in nanoseconds
+// since the Unix epoch.
+func (r *Runtime) getTimeNanos(ctx context.Context) uint64 {
+	defer r.recoverHost(ctx, handler.FuncGetTimeNanos)
+	return uint64(r.clock.Now().UnixNano())
+}
+
+// getMonotonicNanos is the WebAssembly function export named
+// handler.FuncGetMonotonicNanos which returns nanoseconds elapsed since an
+// arbitrary point in time.
+func (r *Runtime) getMonotonicNanos(ctx context.Context) uint64 {
+	defer r.recoverHost(ctx, handler.FuncGetMonotonicNanos)
+	return uint64(r.clock.Nanotime())
+}
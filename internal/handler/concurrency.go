@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/http-wasm/http-wasm-host-go/api"
+	"github.com/http-wasm/http-wasm-host-go/internal"
+)
+
+// concurrencyLimiter is a semaphore limiting guests handling requests at the
+// same time. A guest doesn't hold its slot while the next handler runs, as it
+// isn't executing, so a slow backend doesn't starve other guests.
+type concurrencyLimiter struct {
+	slots        chan struct{}
+	queueTimeout time.Duration
+}
+
+// newConcurrencyLimiter returns nil if concurrency is unlimited.
+func newConcurrencyLimiter(c internal.Concurrency) *concurrencyLimiter {
+	if c.Max <= 0 {
+		return nil
+	}
+	return &concurrencyLimiter{slots: make(chan struct{}, c.Max), queueTimeout: c.QueueTimeout}
+}
+
+// acquire returns true when a guest may handle a request, which must be
+// followed by release. This waits up to the queue timeout, or until the
+// context is done, for another guest to finish.
+func (l *concurrencyLimiter) acquire(ctx context.Context) bool {
+	if l == nil {
+		return true
+	}
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if l.queueTimeout <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release frees the slot taken by acquire.
+func (l *concurrencyLimiter) release() {
+	if l != nil {
+		<-l.slots
+	}
+}
+
+// resume takes a slot again after release, when the guest continues after
+// the next handler. The guest already started handling the request, so this
+// waits for a slot regardless of the queue timeout.
+func (l *concurrencyLimiter) resume() {
+	if l != nil {
+		l.slots <- struct{}{}
+	}
+}
+
+// rejectOverloaded handles a request which exceeded the concurrency limit,
+// according to the failure policy.
+func (r *Runtime) rejectOverloaded(ctx context.Context) {
+	r.logFn(ctx, "wasm: too many concurrent guests")
+	if r.failurePolicy == api.FailOpen {
+		r.host.Next(ctx)
+		return
+	}
+	r.host.SendResponse(ctx, http.StatusServiceUnavailable, nil)
+}
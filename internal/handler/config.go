@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	"github.com/http-wasm/http-wasm-host-go/internal/schema"
+)
+
+// validateGuestConfig validates the guest config and its canary, if set,
+// against the schema in the custom section named
+// handler.CustomSectionConfigSchema, if present.
+func (r *Runtime) validateGuestConfig() error {
+	s, err := r.configSchema()
+	if s == nil {
+		return err
+	}
+
+	if err = s.Validate(r.guestConfig); err != nil {
+		return fmt.Errorf("wasm: invalid guest config: %w", err)
+	}
+	if r.canaryPercent > 0 {
+		if err = s.Validate(r.guestConfigCanary); err != nil {
+			return fmt.Errorf("wasm: invalid guest config canary: %w", err)
+		}
+	}
+	return nil
+}
+
+// configSchema compiles the schema in the custom section named
+// handler.CustomSectionConfigSchema, or returns nil if the guest doesn't
+// publish one.
+func (r *Runtime) configSchema() (*schema.Schema, error) {
+	rawSchema, ok := r.CustomSection(handler.CustomSectionConfigSchema)
+	if !ok {
+		return nil, nil
+	}
+	s, err := schema.Compile(rawSchema)
+	if err != nil {
+		return nil, fmt.Errorf("wasm: guest custom section[%s]: %w", handler.CustomSectionConfigSchema, err)
+	}
+	return s, nil
+}
+
+// UpdateConfig validates and replaces the guest config, including that of
+// shards. Guests apply it before the next request they handle, as documented
+// on handler.FuncOnConfigUpdate.
+func (r *Runtime) UpdateConfig(config []byte) error {
+	if s, err := r.configSchema(); err != nil {
+		return err
+	} else if s != nil {
+		if err = s.Validate(config); err != nil {
+			return fmt.Errorf("wasm: invalid guest config: %w", err)
+		}
+	}
+	config = append([]byte{}, config...) // the caller may reuse it
+	for _, s := range append([]*Runtime{r}, r.shards...) {
+		s.configMu.Lock()
+		s.guestConfig = config
+		s.configVersion++
+		s.configMu.Unlock()
+	}
+	return nil
+}
+
+// currentConfig returns the guest config and its version, which increments
+// each time UpdateConfig replaces it.
+func (r *Runtime) currentConfig() ([]byte, uint64) {
+	r.configMu.RLock()
+	defer r.configMu.RUnlock()
+	return r.guestConfig, r.configVersion
+}
+
+// updateConfig calls handler.FuncOnConfigUpdate, if exported, when the guest
+// config was replaced since the guest last applied it.
+func (g *Guest) updateConfig(ctx context.Context) error {
+	config, version := g.r.currentConfig()
+	if g.configVersion == version {
+		return nil
+	}
+	g.configVersion = version
+	fn := g.onConfigUpdate
+	if fn == nil {
+		return nil // the guest reads the config each time it needs it
+	}
+	// Read the updated config, even when the request uses the canary.
+	ctx = context.WithValue(ctx, guestConfigKey{}, config)
+	return call(ctx, handler.FuncOnConfigUpdate, fn)
+}
+
+// guestConfigKey is a context.Context Value associated with the guest config
+// chosen for the current request.
+type guestConfigKey struct{}
+
+// withGuestConfig chooses the guest config for the current request, so that
+// the guest reads the same config each time it calls handler.FuncGetConfig.
+func (r *Runtime) withGuestConfig(ctx context.Context) context.Context {
+	if r.canaryPercent <= 0 || rand.Intn(100) >= r.canaryPercent {
+		return ctx
+	}
+	return context.WithValue(ctx, guestConfigKey{}, r.guestConfigCanary)
+}
+
+// guestConfigFromContext returns the guest config chosen by withGuestConfig.
+func (r *Runtime) guestConfigFromContext(ctx context.Context) []byte {
+	if config, ok := ctx.Value(guestConfigKey{}).([]byte); ok {
+		return config
+	}
+	config, _ := r.currentConfig()
+	return config
+}
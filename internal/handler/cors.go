@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// isPreflight returns true if the request is a CORS preflight request, as
+// documented on handler.FuncSetCORSHeaders.
+func (r *Runtime) isPreflight(ctx context.Context) bool {
+	if r.host.GetMethod(ctx) != http.MethodOptions {
+		return false
+	}
+	if _, ok := r.host.GetRequestHeader(ctx, "Origin"); !ok {
+		return false
+	}
+	_, ok := r.host.GetRequestHeader(ctx, "Access-Control-Request-Method")
+	return ok
+}
+
+// getRequestOrigin is the WebAssembly function export named
+// handler.FuncGetRequestOrigin which writes the "Origin" header of the
+// request to memory if it exists and isn't larger than the buffer size limit.
+// The result is `1<<32|value_len` or zero if the header doesn't exist.
+func (r *Runtime) getRequestOrigin(ctx context.Context, mod wazeroapi.Module,
+	buf, bufLimit uint32) (result uint64) {
+	defer r.recoverHost(ctx, handler.FuncGetRequestOrigin)
+	value, ok := r.host.GetRequestHeader(ctx, "Origin")
+	return r.writeValue(ctx, mod, value, ok, buf, bufLimit)
+}
+
+// setCORSHeaders is the WebAssembly function export named
+// handler.FuncSetCORSHeaders which sets the CORS response headers of the
+// request, depending on whether it is a preflight.
+func (r *Runtime) setCORSHeaders(ctx context.Context, mod wazeroapi.Module,
+	origin, originLen, methods, methodsLen, headers, headersLen, maxAgeSecs, credentials uint32) {
+	defer r.recoverHost(ctx, handler.FuncSetCORSHeaders)
+	o := mustReadString(ctx, mod.Memory(), "origin", origin, originLen)
+	if o == "" {
+		panic(errors.New("origin is empty"))
+	} else if credentials != 0 && o == "*" {
+		panic(errors.New("credentials aren't allowed for origin *"))
+	}
+	m := mustReadString(ctx, mod.Memory(), "methods", methods, methodsLen)
+	h := mustReadString(ctx, mod.Memory(), "headers", headers, headersLen)
+
+	set := func(name, value string) {
+		if value == "" {
+			return
+		}
+		if r.admitHeader(ctx, name, value) {
+			r.host.SetResponseHeader(ctx, name, value)
+		}
+	}
+	set("Access-Control-Allow-Origin", o)
+	if o != "*" {
+		set("Vary", "Origin")
+	}
+	if credentials != 0 {
+		set("Access-Control-Allow-Credentials", "true")
+	}
+	if !r.isPreflight(ctx) {
+		set("Access-Control-Expose-Headers", h)
+		return
+	}
+	set("Access-Control-Allow-Methods", m)
+	set("Access-Control-Allow-Headers", h)
+	if maxAgeSecs > 0 {
+		set("Access-Control-Max-Age", strconv.FormatUint(uint64(maxAgeSecs), 10))
+	}
+}
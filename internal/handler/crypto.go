@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	"github.com/http-wasm/http-wasm-host-go/internal/jwa"
+)
+
+func checkKeys(keys map[string]interface{}) error {
+	for id, key := range keys {
+		if err := jwa.CheckKey(key); err != nil {
+			return fmt.Errorf("wasm: invalid key %q: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// hmac is the WebAssembly function export named handler.FuncHMAC which
+// writes the MAC of data read from memory, using the secret with the key ID,
+// if it isn't larger than the buffer size limit. The result is the length of
+// the MAC, or zero if the secret or algorithm doesn't exist.
+func (r *Runtime) hmac(ctx context.Context, mod wazeroapi.Module,
+	alg, algLen, keyID, keyIDLen, data, dataLen, buf, bufLimit uint32) (macLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncHMAC)
+	a := mustReadString(ctx, mod.Memory(), "alg", alg, algLen)
+	id := mustReadString(ctx, mod.Memory(), "key_id", keyID, keyIDLen)
+	d := mustRead(ctx, mod.Memory(), "data", data, dataLen)
+
+	secret, ok := r.keys[id].([]byte)
+	if !ok {
+		return // no secret with that ID
+	}
+	mac, ok := jwa.HMAC(a, secret, d)
+	if !ok {
+		return // unsupported algorithm
+	}
+	return writeIfUnderLimit(ctx, mod.Memory(), "mac", buf, bufLimit, mac)
+}
+
+// verifySignature is the WebAssembly function export named
+// handler.FuncVerifySignature which returns one if the signature read from
+// memory is valid for the data, using the key with the key ID.
+func (r *Runtime) verifySignature(ctx context.Context, mod wazeroapi.Module,
+	alg, algLen, keyID, keyIDLen, data, dataLen, sig, sigLen uint32) (valid uint32) {
+	defer r.recoverHost(ctx, handler.FuncVerifySignature)
+	a := mustReadString(ctx, mod.Memory(), "alg", alg, algLen)
+	id := mustReadString(ctx, mod.Memory(), "key_id", keyID, keyIDLen)
+	d := mustRead(ctx, mod.Memory(), "data", data, dataLen)
+	s := mustRead(ctx, mod.Memory(), "sig", sig, sigLen)
+
+	key, ok := r.keys[id]
+	if ok && jwa.Verify(a, key, d, s) {
+		valid = 1
+	}
+	return
+}
@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	"github.com/http-wasm/http-wasm-host-go/internal/wasm"
+)
+
+// CustomSections returns all custom sections in the guest, in the order they
+// were defined. These are read without instantiating the guest, so are safe
+// to display for provenance, such as the toolchain that built it.
+func (r *Runtime) CustomSections() []wasm.CustomSection {
+	return append([]wasm.CustomSection{}, r.customSections...)
+}
+
+// CustomSection returns the data of the first custom section in the guest
+// with the given name, or false if there is none.
+func (r *Runtime) CustomSection(name string) ([]byte, bool) {
+	for _, s := range r.customSections {
+		if s.Name == name {
+			return s.Data, true
+		}
+	}
+	return nil, false
+}
+
+// GuestMetadata returns metadata of the guest from its custom sections.
+func (r *Runtime) GuestMetadata() handler.GuestMetadata {
+	return r.metadata
+}
@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+)
+
+// withEnv returns a copy of the module config with the environment variables
+// and arguments guests read via WASI, or an error if a variable is invalid.
+// This validates eagerly, as wazero otherwise only fails when instantiating a
+// guest, which may be on the first request.
+func withEnv(config wazero.ModuleConfig, env map[string]string, args []string) (wazero.ModuleConfig, error) {
+	// Sort keys, so that guests see the same order regardless of the map.
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := env[k]
+		if k == "" || strings.ContainsAny(k, "=\x00") || strings.IndexByte(v, 0) >= 0 {
+			return nil, fmt.Errorf("wasm: invalid guest env %q", k)
+		}
+		config = config.WithEnv(k, v)
+	}
+	for _, arg := range args {
+		if strings.IndexByte(arg, 0) >= 0 {
+			return nil, fmt.Errorf("wasm: invalid guest arg %q", arg)
+		}
+	}
+	if len(args) > 0 {
+		config = config.WithArgs(args...)
+	}
+	return config, nil
+}
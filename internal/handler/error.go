@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"text/template"
+
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	"github.com/http-wasm/http-wasm-host-go/internal"
+)
+
+// errorResponse is a compiled internal.ErrorMapping.
+type errorResponse struct {
+	statusCode uint32
+	body       *template.Template
+}
+
+// errorData is the data of an errorResponse body template.
+type errorData struct {
+	Code       string
+	StatusCode uint32
+}
+
+func compileErrorMappings(mappings map[string]internal.ErrorMapping) (map[string]*errorResponse, error) {
+	responses := make(map[string]*errorResponse, len(mappings))
+	for code, m := range mappings {
+		if m.StatusCode < 100 || m.StatusCode > 599 {
+			return nil, fmt.Errorf("wasm: invalid error mapping %q: status code %d", code, m.StatusCode)
+		}
+		body, err := template.New(code).Parse(m.Body)
+		if err != nil {
+			return nil, fmt.Errorf("wasm: invalid error mapping %q: %w", code, err)
+		}
+		responses[code] = &errorResponse{statusCode: uint32(m.StatusCode), body: body}
+	}
+	return responses, nil
+}
+
+// setError is the WebAssembly function export named handler.FuncSetError
+// which sends the response mapped to an error code read from memory.
+func (r *Runtime) setError(ctx context.Context, mod wazeroapi.Module,
+	code, codeLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncSetError)
+	c := mustReadString(ctx, mod.Memory(), "code", code, codeLen)
+	e, ok := r.errorResponses[c]
+	if !ok {
+		r.host.SendResponse(ctx, http.StatusInternalServerError, nil)
+		return
+	}
+
+	var body bytes.Buffer
+	if err := e.body.Execute(&body, &errorData{Code: c, StatusCode: e.statusCode}); err != nil {
+		panic(fmt.Errorf("error executing error mapping %q: %w", c, err))
+	}
+	r.host.SendResponse(ctx, e.statusCode, body.Bytes())
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/api"
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// maxQueuedEvents is the count of events emitted, but not yet delivered,
+// after which events are dropped.
+const maxQueuedEvents = 1024
+
+// eventQueue delivers events guests emit to an api.EventFunc, in order, on a
+// goroutine, so that slow business logic doesn't delay requests.
+type eventQueue struct {
+	fn     api.EventFunc
+	events chan api.Event
+	// done is closed when all events were delivered after close.
+	done chan struct{}
+
+	mu     sync.Mutex
+	closed bool
+}
+
+// newEventQueue returns nil if fn is nil, or otherwise a queue delivering to
+// it until closed.
+func newEventQueue(fn api.EventFunc) *eventQueue {
+	if fn == nil {
+		return nil
+	}
+	q := &eventQueue{
+		fn:     fn,
+		events: make(chan api.Event, maxQueuedEvents),
+		done:   make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+func (q *eventQueue) run() {
+	defer close(q.done)
+	for e := range q.events {
+		q.fn(context.Background(), e)
+	}
+}
+
+// push queues the event, returning false if it was dropped, as the queue is
+// full or closed.
+func (q *eventQueue) push(e api.Event) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return false
+	}
+	select {
+	case q.events <- e:
+		return true
+	default:
+		return false
+	}
+}
+
+// close stops accepting events, and waits for those queued to be delivered.
+func (q *eventQueue) close() {
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.events)
+	}
+	q.mu.Unlock()
+	<-q.done
+}
+
+// emitEvent is the WebAssembly function export named handler.FuncEmitEvent
+// which queues an event read from memory for the api.EventFunc, if any.
+func (r *Runtime) emitEvent(ctx context.Context, mod wazeroapi.Module,
+	topic, topicLen, payload, payloadLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncEmitEvent)
+	t := mustReadString(ctx, mod.Memory(), "topic", topic, topicLen)
+	if t == "" {
+		panic(errors.New("topic is empty"))
+	}
+	if r.events == nil {
+		return
+	}
+	p := mustRead(ctx, mod.Memory(), "payload", payload, payloadLen)
+	e := api.Event{
+		Module:  r.guestModule.Name(),
+		Topic:   t,
+		Payload: append([]byte{}, p...), // guest memory
+	}
+	if !r.events.push(e) {
+		r.logFn(ctx, fmt.Sprintf("wasm: dropped event %q as the queue is full", t))
+	}
+}
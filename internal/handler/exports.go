@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/tetratelabs/wazero"
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+type signature struct {
+	params, results []wazeroapi.ValueType
+}
+
+var (
+	i32 = wazeroapi.ValueTypeI32
+
+	nullary = &signature{}
+
+	// handleResult is the signature of handler.FuncHandle when it returns an
+	// error code.
+	handleResult = &signature{results: []wazeroapi.ValueType{i32}}
+)
+
+// optionalExports are functions the guest may export, which define phases
+// the host skips when they are missing.
+var optionalExports = map[string]*signature{
+	handler.FuncHandleRequest:          handleResult,
+	handler.FuncHandleResponse:         nullary,
+	handler.FuncInit:                   nullary,
+	handler.FuncShutdown:               nullary,
+	handler.FuncHandleRequestBodyChunk: {params: []wazeroapi.ValueType{i32, i32}, results: []wazeroapi.ValueType{i32}},
+	handler.FuncMalloc:                 {params: []wazeroapi.ValueType{i32}, results: []wazeroapi.ValueType{i32}},
+	handler.FuncFree:                   {params: []wazeroapi.ValueType{i32}},
+}
+
+// guestExports are functions exported by a guest instance, resolved once on
+// instantiation, as each lookup allocates. Optional functions the guest
+// doesn't export are nil.
+type guestExports struct {
+	// handle is nil when the guest exports handler.FuncHandleRequest
+	// instead.
+	handle wazeroapi.Function
+
+	// handleRequest is nil when the guest exports handler.FuncHandle
+	// instead of handler.FuncHandleRequest.
+	handleRequest wazeroapi.Function
+
+	// handleResponse is nil when the guest doesn't export
+	// handler.FuncHandleResponse.
+	handleResponse wazeroapi.Function
+
+	handleRequestBodyChunk wazeroapi.Function
+	malloc, free           wazeroapi.Function
+	init, shutdown, ping   wazeroapi.Function
+	onConfigUpdate, onTick wazeroapi.Function
+}
+
+func resolveExports(mod wazeroapi.Module) *guestExports {
+	return &guestExports{
+		handle:                 mod.ExportedFunction(handler.FuncHandle),
+		handleRequest:          mod.ExportedFunction(handler.FuncHandleRequest),
+		handleResponse:         mod.ExportedFunction(handler.FuncHandleResponse),
+		handleRequestBodyChunk: mod.ExportedFunction(handler.FuncHandleRequestBodyChunk),
+		malloc:                 mod.ExportedFunction(handler.FuncMalloc),
+		free:                   mod.ExportedFunction(handler.FuncFree),
+		init:                   mod.ExportedFunction(handler.FuncInit),
+		shutdown:               mod.ExportedFunction(handler.FuncShutdown),
+		ping:                   mod.ExportedFunction(handler.FuncPing),
+		onConfigUpdate:         mod.ExportedFunction(handler.FuncOnConfigUpdate),
+		onTick:                 mod.ExportedFunction(handler.FuncOnTick),
+	}
+}
+
+// exportsOf returns the exports of the guest calling a host function. This
+// resolves them again if the module isn't the one returned on instantiation,
+// such as when wazero overrode its memory.
+func (r *Runtime) exportsOf(mod wazeroapi.Module) *guestExports {
+	if e, ok := r.exports.Load(mod); ok {
+		return e.(*guestExports)
+	}
+	return resolveExports(mod)
+}
+
+// checkOptionalExports returns an error if the guest exports an optional
+// function with the wrong signature.
+func checkOptionalExports(guest wazero.CompiledModule) error {
+	exports := guest.ExportedFunctions()
+	for name, s := range optionalExports {
+		if fn, ok := exports[name]; ok && !s.matches(fn) {
+			return invalidGuest(handler.ErrBadHandleSignature, "guest exports the wrong signature for func[%s]. should be %s", name, s)
+		}
+	}
+	return nil
+}
+
+// invalidGuest returns a handler.InvalidGuestError with the cause and a
+// detail formatted like fmt.Sprintf.
+func invalidGuest(cause error, format string, args ...interface{}) error {
+	return &handler.InvalidGuestError{Err: cause, Detail: fmt.Sprintf(format, args...)}
+}
+
+func (s *signature) matches(fn wazeroapi.FunctionDefinition) bool {
+	return equalTypes(fn.ParamTypes(), s.params) && equalTypes(fn.ResultTypes(), s.results)
+}
+
+// String returns "nullary" or the signature in WebAssembly text format.
+// Ex. "(param i32 i32) (result i32)"
+func (s *signature) String() string {
+	if len(s.params) == 0 && len(s.results) == 0 {
+		return "nullary"
+	}
+	return fmt.Sprintf("%s %s", formatTypes("param", s.params), formatTypes("result", s.results))
+}
+
+func formatTypes(kind string, types []wazeroapi.ValueType) string {
+	s := "(" + kind
+	for _, t := range types {
+		s += " " + wazeroapi.ValueTypeName(t)
+	}
+	return s + ")"
+}
+
+func equalTypes(a, b []wazeroapi.ValueType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
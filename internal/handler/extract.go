@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	"github.com/http-wasm/http-wasm-host-go/internal/extract"
+)
+
+func compileExtractions(expressions map[string]string) (map[string]*extract.Expression, error) {
+	compiled := make(map[string]*extract.Expression, len(expressions))
+	for name, expr := range expressions {
+		e, err := extract.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("wasm: invalid extraction %q: %w", name, err)
+		}
+		compiled[name] = e
+	}
+	return compiled, nil
+}
+
+// extract is the WebAssembly function export named handler.FuncExtract which
+// evaluates the expression with the given name, and writes the value to
+// memory if it exists and isn't larger than the buffer size limit. The result
+// is `1<<32|value_len` or zero if there is no value.
+func (r *Runtime) extract(ctx context.Context, mod wazeroapi.Module,
+	name, nameLen, buf, bufLimit uint32) (result uint64) {
+	defer r.recoverHost(ctx, handler.FuncExtract)
+	n := mustReadString(ctx, mod.Memory(), "name", name, nameLen)
+	e, ok := r.extractions[n]
+	if !ok {
+		return // no expression with that name
+	}
+
+	var value string
+	switch e.Source {
+	case extract.SourceHeader:
+		value, ok = r.host.GetRequestHeader(ctx, e.Name)
+	case extract.SourceQuery:
+		value, ok = r.host.GetQueryValue(ctx, e.Name)
+	case extract.SourceCookie:
+		value, ok = r.host.GetCookie(ctx, e.Name)
+	case extract.SourceJSON:
+		value, ok = e.EvalJSON(r.host.GetRequestBody(ctx))
+	}
+	return r.writeValue(ctx, mod, value, ok, buf, bufLimit)
+}
@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/tetratelabs/wazero"
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	"github.com/http-wasm/http-wasm-host-go/internal"
+)
+
+// runtimeFeatures returns the features implemented by the runtime instead of
+// the handler.Host, which are always enabled.
+func runtimeFeatures(o *internal.WazeroOptions) handler.Features {
+	features := handler.FeatureSharedStore
+	if len(o.HTTPCall.Hosts) > 0 {
+		features |= handler.FeatureHTTPCall
+	}
+	if o.GrowBuffers {
+		features |= handler.FeatureGrowBuffers
+	}
+	if o.StateStore != nil {
+		features |= handler.FeatureStateStore
+	}
+	return features
+}
+
+// enableFeatures is the WebAssembly function export named
+// handler.FuncEnableFeatures, which enables features for the current request.
+// The result is the features now enabled.
+func (r *Runtime) enableFeatures(ctx context.Context, features uint64) uint64 {
+	defer r.recoverHost(ctx, handler.FuncEnableFeatures)
+	f := r.withDecode(handler.Features(features))
+	if f&r.features&handler.FeatureGrowBuffers != 0 {
+		enableGrowBuffers(ctx)
+	}
+	return uint64(r.host.EnableFeatures(ctx, f&^r.features) | f&r.features)
+}
+
+// withDecode adds handler.FeatureDecodeResponse to the features if they
+// buffer the response and internal.WazeroOptions DecodeResponseBody is set.
+func (r *Runtime) withDecode(features handler.Features) handler.Features {
+	if r.decodeResponseBody && features&handler.FeatureBufferResponse != 0 {
+		features |= handler.FeatureDecodeResponse
+	}
+	return features
+}
+
+// capabilities is the WebAssembly function export named
+// handler.FuncCapabilities, which returns the features supported.
+func (r *Runtime) capabilities(ctx context.Context) uint64 {
+	defer r.recoverHost(ctx, handler.FuncCapabilities)
+	return uint64(r.host.Capabilities(ctx) | r.features)
+}
+
+// readResponseBody is the WebAssembly function export named
+// handler.FuncReadResponseBody, which writes the buffered response body to
+// memory if it isn't larger than the buffer size limit. The result is the
+// length of the body in bytes.
+func (r *Runtime) readResponseBody(ctx context.Context, mod wazeroapi.Module,
+	buf, bufLimit uint32) (bodyLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncReadResponseBody)
+	body := r.host.GetResponseBody(ctx)
+	return writeIfUnderLimit(ctx, mod.Memory(), "body", buf, bufLimit, body)
+}
+
+// importsFunc returns true if the guest imports the host function with the
+// given name.
+func importsFunc(guest wazero.CompiledModule, name string) bool {
+	for _, f := range guest.ImportedFunctions() {
+		if module, n, _ := f.Import(); module == handler.HostModule && n == name {
+			return true
+		}
+	}
+	return false
+}
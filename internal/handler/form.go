@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"context"
+	"encoding/binary"
+
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/abi/encoding"
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// getFormValue is the WebAssembly function export named
+// handler.FuncGetFormValue which writes the first value of a form field to
+// memory if it exists and isn't larger than the buffer size limit. The
+// result is `1<<32|value_len` or zero if the field doesn't exist.
+func (r *Runtime) getFormValue(ctx context.Context, mod wazeroapi.Module,
+	name, nameLen, buf, bufLimit uint32) (result uint64) {
+	defer r.recoverHost(ctx, handler.FuncGetFormValue)
+	n := mustReadString(ctx, mod.Memory(), "name", name, nameLen)
+	value, ok := r.host.GetFormValue(ctx, n)
+	return r.writeValue(ctx, mod, value, ok, buf, bufLimit)
+}
+
+// getUploadedFileInfo is the WebAssembly function export named
+// handler.FuncGetUploadedFileInfo which writes information about an uploaded
+// file to memory if it exists and isn't larger than the buffer size limit.
+// The result is `1<<32|info_len` or zero if there's no such file.
+func (r *Runtime) getUploadedFileInfo(ctx context.Context, mod wazeroapi.Module,
+	name, nameLen, buf, bufLimit uint32) (result uint64) {
+	defer r.recoverHost(ctx, handler.FuncGetUploadedFileInfo)
+	n := mustReadString(ctx, mod.Memory(), "name", name, nameLen)
+	info, ok := r.host.GetUploadedFileInfo(ctx, n)
+	if !ok {
+		return
+	}
+	return r.writeValue(ctx, mod, string(encodeFileInfo(info)), true, buf, bufLimit)
+}
+
+// encodeFileInfo encodes the info as documented on
+// handler.FuncGetUploadedFileInfo.
+func encodeFileInfo(info handler.FileInfo) []byte {
+	b := make([]byte, 8, 16+len(info.Filename)+len(info.ContentType))
+	binary.LittleEndian.PutUint64(b, info.Size)
+	b = encoding.AppendField(b, info.Filename)
+	return encoding.AppendField(b, info.ContentType)
+}
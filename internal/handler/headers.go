@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"context"
+
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/abi/encoding"
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// readRequestHeaders is the WebAssembly function export named
+// handler.FuncReadRequestHeaders which writes all request headers to memory
+// if they aren't larger than the buffer size limit. The result is the length
+// of the encoded headers in bytes.
+func (r *Runtime) readRequestHeaders(ctx context.Context, mod wazeroapi.Module,
+	buf, bufLimit uint32) (headersLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncReadRequestHeaders)
+	headers := encoding.EncodeMultiMap(r.host.GetRequestHeaders(ctx))
+	return writeIfUnderLimit(ctx, mod.Memory(), "headers", buf, bufLimit, headers)
+}
+
+// writeResponseHeaders is the WebAssembly function export named
+// handler.FuncWriteResponseHeaders which sets response headers from entries
+// read from memory.
+func (r *Runtime) writeResponseHeaders(ctx context.Context, mod wazeroapi.Module,
+	buf, bufLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncWriteResponseHeaders)
+	b := mustRead(ctx, mod.Memory(), "buf", buf, bufLen)
+	for len(b) > 0 {
+		n, v, remaining, err := encoding.NextEntry(b)
+		if err != nil {
+			panic(err)
+		}
+		b = remaining
+		name, value := string(n), string(v)
+		if r.admitHeader(ctx, name, value) {
+			r.host.SetResponseHeader(ctx, name, value)
+		}
+	}
+}
+
+// readRawRequestHeaders is the WebAssembly function export named
+// handler.FuncReadRawRequestHeaders which writes the request headers as
+// received to memory if they aren't larger than the buffer size limit. The
+// result is the length of the encoded headers in bytes.
+func (r *Runtime) readRawRequestHeaders(ctx context.Context, mod wazeroapi.Module,
+	buf, bufLimit uint32) (headersLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncReadRawRequestHeaders)
+	var headers []byte
+	for _, f := range r.host.GetRawRequestHeaders(ctx) {
+		headers = encoding.AppendEntry(headers, f.Name, f.Value)
+	}
+	return writeIfUnderLimit(ctx, mod.Memory(), "headers", buf, bufLimit, headers)
+}
+
+// setRawResponseHeader is the WebAssembly function export named
+// handler.FuncSetRawResponseHeader which sets a response header from a name
+// and value read from memory, without canonicalizing the name.
+func (r *Runtime) setRawResponseHeader(ctx context.Context, mod wazeroapi.Module,
+	name, nameLen, value, valueLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncSetRawResponseHeader)
+	n := mustReadString(ctx, mod.Memory(), "name", name, nameLen)
+	v := mustReadString(ctx, mod.Memory(), "value", value, valueLen)
+	if !r.admitHeader(ctx, n, v) {
+		return
+	}
+	r.host.SetRawResponseHeader(ctx, n, v)
+}
+
+// readQueryParams is the WebAssembly function export named
+// handler.FuncReadQueryParams which writes all query parameters to memory if
+// they aren't larger than the buffer size limit. The result is the length of
+// the encoded parameters in bytes.
+func (r *Runtime) readQueryParams(ctx context.Context, mod wazeroapi.Module,
+	buf, bufLimit uint32) (paramsLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncReadQueryParams)
+	params := encoding.EncodeMultiMap(r.host.GetQueryParams(ctx))
+	return writeIfUnderLimit(ctx, mod.Memory(), "params", buf, bufLimit, params)
+}
+
+// readProperties is the WebAssembly function export named
+// handler.FuncReadProperties which writes all properties of the request to
+// memory if they aren't larger than the buffer size limit. The result is the
+// length of the encoded properties in bytes.
+func (r *Runtime) readProperties(ctx context.Context, mod wazeroapi.Module,
+	buf, bufLimit uint32) (propertiesLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncReadProperties)
+	properties := encoding.EncodeMap(r.host.GetProperties(ctx))
+	return writeIfUnderLimit(ctx, mod.Memory(), "properties", buf, bufLimit, properties)
+}
@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// FullHost is a handler.Host which also implements its optional interfaces,
+// with those of the host it wraps, or fallbacks when the host doesn't, as
+// documented with the interfaces. This way, host functions needn't check what the host
+// implements, and hosts which wrap another, such as to record calls, behave
+// the same as it.
+type FullHost struct {
+	handler.Host
+	handler.ConnectionInfo
+	handler.RequestInfo
+	handler.Proxy
+	handler.CookieSetter
+	handler.RawHeaders
+	handler.Forms
+	handler.Trailers
+	handler.Properties
+	handler.Scratch
+	handler.RequestBodyStreamer
+}
+
+// NewFullHost returns a FullHost wrapping the host.
+func NewFullHost(host handler.Host) FullHost {
+	h := FullHost{
+		Host:                host,
+		ConnectionInfo:      noConnectionInfo{},
+		RequestInfo:         noRequestInfo{},
+		Proxy:               noProxy{},
+		CookieSetter:        noCookieSetter{},
+		RawHeaders:          noRawHeaders{host},
+		Forms:               noForms{},
+		Trailers:            noTrailers{},
+		Properties:          noProperties{},
+		Scratch:             noScratch{},
+		RequestBodyStreamer: noRequestBodyStreamer{},
+	}
+	if c, ok := host.(handler.ConnectionInfo); ok {
+		h.ConnectionInfo = c
+	}
+	if i, ok := host.(handler.RequestInfo); ok {
+		h.RequestInfo = i
+	}
+	if p, ok := host.(handler.Proxy); ok {
+		h.Proxy = p
+	}
+	if c, ok := host.(handler.CookieSetter); ok {
+		h.CookieSetter = c
+	}
+	if r, ok := host.(handler.RawHeaders); ok {
+		h.RawHeaders = r
+	}
+	if f, ok := host.(handler.Forms); ok {
+		h.Forms = f
+	}
+	if t, ok := host.(handler.Trailers); ok {
+		h.Trailers = t
+	}
+	if p, ok := host.(handler.Properties); ok {
+		h.Properties = p
+	}
+	if s, ok := host.(handler.Scratch); ok {
+		h.Scratch = s
+	}
+	if s, ok := host.(handler.RequestBodyStreamer); ok {
+		h.RequestBodyStreamer = s
+	}
+	return h
+}
+
+// GetQueryValue returns the first value of the query parameter, or false if
+// it doesn't exist.
+func (h FullHost) GetQueryValue(ctx context.Context, name string) (string, bool) {
+	if values := h.GetQueryParams(ctx)[name]; len(values) > 0 {
+		return values[0], true
+	}
+	return "", false
+}
+
+// GetCookie returns the value of the request cookie, parsed from the "Cookie"
+// headers the same way as net/http, or false if it doesn't exist.
+func (h FullHost) GetCookie(ctx context.Context, name string) (string, bool) {
+	values := h.GetRequestHeaders(ctx)["Cookie"]
+	if len(values) == 0 {
+		return "", false
+	}
+	req := http.Request{Header: http.Header{"Cookie": values}}
+	if c, err := req.Cookie(name); err == nil {
+		return c.Value, true
+	}
+	return "", false
+}
+
+// GetProperty returns the value of the property, or false if it doesn't
+// exist.
+func (h FullHost) GetProperty(ctx context.Context, name string) (string, bool) {
+	value, ok := h.GetProperties(ctx)[name]
+	return value, ok
+}
+
+type noConnectionInfo struct{}
+
+func (noConnectionInfo) GetSourceAddr(context.Context) string              { return "" }
+func (noConnectionInfo) GetListenerAddr(context.Context) string            { return "" }
+func (noConnectionInfo) IsUnixSocket(context.Context) bool                 { return false }
+func (noConnectionInfo) GetProxySourceAddr(context.Context) (string, bool) { return "", false }
+func (noConnectionInfo) GetTLSVersion(context.Context) uint32              { return 0 }
+func (noConnectionInfo) GetTLSPeerCert(context.Context) []byte             { return nil }
+func (noConnectionInfo) GetProtocolVersion(context.Context) string         { return "" }
+func (noConnectionInfo) IsRequestChunked(context.Context) bool             { return false }
+
+type noRequestInfo struct{}
+
+func (noRequestInfo) GetRPC(context.Context) (service, method string) { return "", "" }
+func (noRequestInfo) GetRoute(context.Context) string                 { return "" }
+func (noRequestInfo) GetRequestID(context.Context) string             { return "" }
+
+type noProxy struct{}
+
+func (noProxy) SetUpstream(context.Context, string)                 {}
+func (noProxy) SetTimeout(context.Context, time.Duration)           {}
+func (noProxy) SetRetryPolicy(context.Context, handler.RetryPolicy) {}
+func (noProxy) MirrorRequest(context.Context, string)               {}
+
+type noCookieSetter struct{}
+
+func (noCookieSetter) SetCookie(context.Context, string, string, string) {}
+
+// noRawHeaders sets response headers canonicalized, as the host can't send
+// them as-is.
+type noRawHeaders struct{ host handler.Host }
+
+func (noRawHeaders) GetRawRequestHeaders(context.Context) []handler.HeaderField { return nil }
+
+func (h noRawHeaders) SetRawResponseHeader(ctx context.Context, name, value string) {
+	h.host.SetResponseHeader(ctx, name, value)
+}
+
+type noForms struct{}
+
+func (noForms) GetMultipartPart(context.Context, string) ([]byte, bool) { return nil, false }
+func (noForms) GetFormValue(context.Context, string) (string, bool)     { return "", false }
+
+func (noForms) GetUploadedFileInfo(context.Context, string) (handler.FileInfo, bool) {
+	return handler.FileInfo{}, false
+}
+
+type noTrailers struct{}
+
+func (noTrailers) GetRequestTrailer(context.Context, string) (string, bool) { return "", false }
+func (noTrailers) SetResponseTrailer(context.Context, string, string)       {}
+
+type noProperties struct{}
+
+func (noProperties) GetProperties(context.Context) map[string]string { return nil }
+func (noProperties) SetProperty(context.Context, string, string)     {}
+
+type noScratch struct{}
+
+func (noScratch) ReadScratch(context.Context) []byte   { return nil }
+func (noScratch) WriteScratch(context.Context, []byte) {}
+
+// noRequestBodyStreamer traps, as the guest would otherwise never see the
+// chunks it expects.
+type noRequestBodyStreamer struct{}
+
+func (noRequestBodyStreamer) StreamRequestBody(context.Context, uint32, func([]byte, bool) []byte) {
+	panic(errors.New("host doesn't support streaming the request body"))
+}
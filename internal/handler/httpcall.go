@@ -0,0 +1,215 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"time"
+
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	"github.com/http-wasm/http-wasm-host-go/internal"
+)
+
+const (
+	// defaultHTTPCallTimeout is the default of httpwasm.HTTPCallTimeout.
+	defaultHTTPCallTimeout = 30 * time.Second
+
+	// maxHTTPCallResponse limits the size of a response body read into
+	// memory by handler.FuncHTTPCall.
+	maxHTTPCallResponse = 16 << 20 // 16 MiB
+)
+
+// httpCaller implements handler.FuncHTTPCall with the restrictions of
+// internal.HTTPCall.
+type httpCaller struct {
+	client  *http.Client
+	hosts   []string
+	timeout time.Duration
+	// sem is non-nil when concurrency is limited.
+	sem chan struct{}
+}
+
+func newHTTPCaller(config internal.HTTPCall) *httpCaller {
+	c := &httpCaller{timeout: config.Timeout}
+	c.client = &http.Client{CheckRedirect: c.checkRedirect}
+	for _, h := range config.Hosts {
+		c.hosts = append(c.hosts, strings.ToLower(h))
+	}
+	if c.timeout <= 0 {
+		c.timeout = defaultHTTPCallTimeout
+	}
+	if config.MaxConcurrency > 0 {
+		c.sem = make(chan struct{}, config.MaxConcurrency)
+	}
+	return c
+}
+
+// allowed returns true if the host of the URL was configured.
+func (c *httpCaller) allowed(u *url.URL) bool {
+	host, hostname := strings.ToLower(u.Host), strings.ToLower(u.Hostname())
+	for _, h := range c.hosts {
+		if h == host || h == hostname {
+			return true
+		}
+		if suffix := strings.TrimPrefix(h, "*"); suffix != h &&
+			(strings.HasSuffix(host, suffix) || strings.HasSuffix(hostname, suffix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkRedirect implements http.Client CheckRedirect, so that an allowed
+// host can't redirect the guest to one that isn't.
+func (c *httpCaller) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("stopped after 10 redirects")
+	} else if u := req.URL; u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("redirect to unsupported scheme %q", u.Scheme)
+	} else if !c.allowed(u) {
+		return fmt.Errorf("redirect to host %q isn't allowed", u.Host)
+	}
+	return nil
+}
+
+// call sends the request, returning the status code and body of the
+// response.
+func (c *httpCaller) call(ctx context.Context, method, rawURL string, headers, body []byte, timeout time.Duration) (uint32, []byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return 0, nil, err
+	} else if u.Scheme != "http" && u.Scheme != "https" {
+		return 0, nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	} else if !c.allowed(u) {
+		return 0, nil, fmt.Errorf("host %q isn't allowed", u.Host)
+	}
+
+	if timeout <= 0 || timeout > c.timeout {
+		timeout = c.timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if c.sem != nil {
+		select {
+		case c.sem <- struct{}{}:
+			defer func() { <-c.sem }()
+		case <-ctx.Done():
+			return 0, nil, fmt.Errorf("waiting for concurrency limit: %w", ctx.Err())
+		}
+	}
+
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(headers) > 0 {
+		// Parse the headers like a MIME header block, which must end with a
+		// blank line.
+		tp := textproto.NewReader(bufio.NewReader(io.MultiReader(bytes.NewReader(headers), strings.NewReader("\r\n"))))
+		h, err := tp.ReadMIMEHeader()
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid headers: %w", err)
+		}
+		req.Header = http.Header(h)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPCallResponse+1))
+	if err != nil {
+		return 0, nil, err
+	} else if len(respBody) > maxHTTPCallResponse {
+		return 0, nil, fmt.Errorf("response body larger than %d bytes", maxHTTPCallResponse)
+	}
+	return uint32(resp.StatusCode), respBody, nil
+}
+
+// httpCall is the WebAssembly function export named handler.FuncHTTPCall
+// which sends an HTTP request read from memory, and writes the response body
+// to memory, up to the buffer size limit. The result is
+// `status_code<<32|body_len`, or zero if the call failed.
+func (r *Runtime) httpCall(ctx context.Context, mod wazeroapi.Module,
+	method, methodLen, url, urlLen, headers, headersLen, body, bodyLen,
+	timeoutMillis, buf, bufLimit uint32) uint64 {
+	defer r.recoverHost(ctx, handler.FuncHTTPCall)
+	mem := mod.Memory()
+	m := mustReadString(ctx, mem, "method", method, methodLen)
+	u := mustReadString(ctx, mem, "url", url, urlLen)
+	h := mustRead(ctx, mem, "headers", headers, headersLen)
+	b := mustRead(ctx, mem, "body", body, bodyLen)
+
+	key := httpCallKey{method: m, url: u, headers: string(h), body: string(b)}
+	truncated, _ := ctx.Value(truncatedHTTPCallKey{}).(*truncatedHTTPCall)
+	var statusCode uint32
+	var respBody []byte
+	if truncated != nil && truncated.key == key {
+		// The guest retries with a larger buffer, so the request isn't sent
+		// again, as it may not be idempotent.
+		statusCode, respBody = truncated.statusCode, truncated.body
+	}
+	if truncated != nil {
+		*truncated = truncatedHTTPCall{}
+	}
+	if respBody == nil {
+		var err error
+		statusCode, respBody, err = r.httpCaller.call(ctx, m, u, h, b, time.Duration(timeoutMillis)*time.Millisecond)
+		if err != nil {
+			r.logFn(ctx, fmt.Sprintf("http_call to %s failed: %v", u, err))
+			return 0
+		}
+	}
+
+	if n := uint32(len(respBody)); n > bufLimit {
+		mustWrite(ctx, mem, "body", buf, respBody[:bufLimit])
+		if truncated != nil {
+			*truncated = truncatedHTTPCall{key: key, statusCode: statusCode, body: respBody}
+		}
+	} else if n > 0 {
+		mustWrite(ctx, mem, "body", buf, respBody)
+	}
+	return uint64(statusCode)<<32 | uint64(len(respBody))
+}
+
+// httpCallKey is the arguments of handler.FuncHTTPCall, except the buffer.
+type httpCallKey struct {
+	method, url, headers, body string
+}
+
+// truncatedHTTPCallKey is the context key of the *truncatedHTTPCall of a
+// request.
+type truncatedHTTPCallKey struct{}
+
+// truncatedHTTPCall is the last response of handler.FuncHTTPCall larger than
+// the buffer of the guest, which it reads by calling again with the same
+// arguments and a larger buffer.
+type truncatedHTTPCall struct {
+	key        httpCallKey
+	statusCode uint32
+	body       []byte
+}
+
+// withHTTPCalls returns a context which keeps a truncated response of
+// handler.FuncHTTPCall, if the guest is allowed to call any host.
+func (r *Runtime) withHTTPCalls(ctx context.Context) context.Context {
+	if len(r.httpCaller.hosts) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, truncatedHTTPCallKey{}, &truncatedHTTPCall{})
+}
@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	"github.com/http-wasm/http-wasm-host-go/internal"
+)
+
+// sendLocalizedResponse is the WebAssembly function export named
+// handler.FuncSendLocalizedResponse which sends the message with the key read
+// from memory, in the language the client prefers.
+func (r *Runtime) sendLocalizedResponse(ctx context.Context, mod wazeroapi.Module,
+	statusCode, key, keyLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncSendLocalizedResponse)
+	k := mustReadString(ctx, mod.Memory(), "key", key, keyLen)
+	acceptLanguage, _ := r.host.GetRequestHeader(ctx, "Accept-Language")
+	c, msg, ok := r.localize(acceptLanguage, k)
+	if !ok {
+		panic(fmt.Errorf("no message with key %q", k))
+	}
+	r.host.SetResponseHeader(ctx, "Content-Type", "text/plain; charset=utf-8")
+	r.host.SetResponseHeader(ctx, "Content-Language", c.Language)
+	r.host.SendResponse(ctx, statusCode, []byte(msg))
+}
+
+// localize returns the message with the key in the most preferred language
+// of the Accept-Language header, or the default language.
+func (r *Runtime) localize(acceptLanguage, key string) (*internal.MessageCatalog, string, bool) {
+	for _, tag := range parseAcceptLanguage(acceptLanguage) {
+		if c := r.messageCatalog(tag); c != nil {
+			if msg, ok := c.Messages[key]; ok {
+				return c, msg, true
+			}
+		}
+	}
+	if len(r.messageCatalogs) == 0 {
+		return nil, "", false
+	}
+	c := &r.messageCatalogs[0]
+	msg, ok := c.Messages[key]
+	return c, msg, ok
+}
+
+// messageCatalog returns the catalog for the language tag, falling back to
+// its primary language. Ex. "pt-BR" falls back to "pt".
+func (r *Runtime) messageCatalog(tag string) *internal.MessageCatalog {
+	for _, candidate := range []string{tag, strings.SplitN(tag, "-", 2)[0]} {
+		for i := range r.messageCatalogs {
+			if strings.EqualFold(r.messageCatalogs[i].Language, candidate) {
+				return &r.messageCatalogs[i]
+			}
+		}
+	}
+	return nil
+}
+
+// parseAcceptLanguage returns the language tags in the header, from most to
+// least preferred. Ex. "fr-CH, fr;q=0.9, *;q=0.5" returns ["fr-CH", "fr"].
+func parseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag = strings.TrimSpace(tag); tag == "" || tag == "*" {
+			continue // the default language is the fallback anyway.
+		}
+		q := 1.0
+		if v, ok := cutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > 0 {
+			tags = append(tags, weighted{tag, q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.tag
+	}
+	return result
+}
+
+// cutPrefix is like strings.CutPrefix, which isn't available until Go 1.20.
+func cutPrefix(s, prefix string) (string, bool) {
+	if !strings.HasPrefix(s, prefix) {
+		return s, false
+	}
+	return s[len(prefix):], true
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// checkImports returns an error listing all functions the guest imports which
+// the host doesn't export, or with the wrong signature, or which require a
+// capability that isn't allowed. All capabilities are allowed when allowed is
+// nil.
+//
+// This reports all problems at once when compiling the guest, instead of the
+// first when instantiating it. Functions of WASI are checked when
+// instantiating, as it is only compiled for guests which import it.
+func checkImports(guest, host wazero.CompiledModule, allowed []handler.Capability) error {
+	exports := host.ExportedFunctions()
+	var problems []string
+	for _, f := range guest.ImportedFunctions() {
+		module, name, _ := f.Import()
+		switch module {
+		case handler.HostModule:
+		case wasi_snapshot_preview1.ModuleName:
+			continue
+		default:
+			problems = append(problems, fmt.Sprintf("func[%s.%s] is from an unknown module", module, name))
+			continue
+		}
+
+		export, ok := exports[name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("func[%s] isn't implemented by the host", name))
+			continue
+		}
+		expected := &signature{params: export.ParamTypes(), results: export.ResultTypes()}
+		if !expected.matches(f) {
+			actual := &signature{params: f.ParamTypes(), results: f.ResultTypes()}
+			problems = append(problems, fmt.Sprintf("func[%s] has the wrong signature %s, should be %s", name, actual, expected))
+			continue
+		}
+
+		if allowed == nil {
+			continue
+		}
+		if c, ok := handler.CapabilityOf(name); ok && !containsCapability(allowed, c) {
+			problems = append(problems, fmt.Sprintf("func[%s] requires capability %q, but only %q are allowed", name, c, allowed))
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return invalidGuest(handler.ErrInvalidImports, "guest has invalid imports: %s", strings.Join(problems, "; "))
+}
+
+// containsCapability returns true if the capabilities include c.
+func containsCapability(capabilities []handler.Capability, c handler.Capability) bool {
+	for _, allowed := range capabilities {
+		if allowed == c {
+			return true
+		}
+	}
+	return false
+}
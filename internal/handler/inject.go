@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// injectedHeadersKey is a context.Context Value associated with the
+// injectedHeaders of the current request.
+type injectedHeadersKey struct{}
+
+type injectedHeaders struct {
+	suppressed bool
+}
+
+// withInjectedHeaders registers the injected headers to be set before the
+// current response is committed, unless the guest suppresses them.
+func (r *Runtime) withInjectedHeaders(ctx context.Context) context.Context {
+	if len(r.injectedHeaders) == 0 {
+		return ctx
+	}
+	s := &injectedHeaders{}
+	ctx = context.WithValue(ctx, injectedHeadersKey{}, s)
+	r.host.BeforeCommit(ctx, func() {
+		if s.suppressed {
+			return
+		}
+		for _, h := range r.injectedHeaders {
+			if v := h.Value(ctx); v != "" {
+				r.host.SetResponseHeader(ctx, h.Name, v)
+			}
+		}
+	})
+	return ctx
+}
+
+// suppressInjectedHeaders is the WebAssembly function export named
+// handler.FuncSuppressInjectedHeaders which prevents injecting headers into
+// the current response.
+func (r *Runtime) suppressInjectedHeaders(ctx context.Context) {
+	defer r.recoverHost(ctx, handler.FuncSuppressInjectedHeaders)
+	if s, ok := ctx.Value(injectedHeadersKey{}).(*injectedHeaders); ok {
+		s.suppressed = true
+	}
+}
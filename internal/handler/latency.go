@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/http-wasm/http-wasm-host-go/api"
+	"github.com/http-wasm/http-wasm-host-go/internal"
+)
+
+// latencyWindow is how many requests the 99th percentile latency is measured
+// over.
+const latencyWindow = 1000
+
+// latencyBudget measures the latency a guest adds to requests, and reacts
+// when it exceeds the budget.
+type latencyBudget struct {
+	internal.LatencyBudget
+	clock api.Clock
+
+	// bypassUntil is the api.Clock Nanotime until which the guest is
+	// bypassed, read atomically on each request.
+	bypassUntil int64
+
+	mu       sync.Mutex
+	samples  []int64
+	exceeded bool
+}
+
+// bypassed returns true if the guest is bypassed, as it exceeded its budget.
+func (l *latencyBudget) bypassed() bool {
+	until := atomic.LoadInt64(&l.bypassUntil)
+	return until != 0 && l.clock.Nanotime() < until
+}
+
+// record adds the latency of a request, in nanoseconds. When this completes a
+// window, the 99th percentile is compared to the budget.
+func (l *latencyBudget) record(ctx context.Context, nanos int64) {
+	l.mu.Lock()
+	if l.samples = append(l.samples, nanos); len(l.samples) < latencyWindow {
+		l.mu.Unlock()
+		return
+	}
+
+	sort.Slice(l.samples, func(i, j int) bool { return l.samples[i] < l.samples[j] })
+	p99 := time.Duration(l.samples[len(l.samples)*99/100])
+	l.samples = l.samples[:0]
+
+	exceeded := p99 > l.Budget
+	changed := exceeded != l.exceeded
+	l.exceeded = exceeded
+	if exceeded && l.Bypass > 0 {
+		atomic.StoreInt64(&l.bypassUntil, l.clock.Nanotime()+int64(l.Bypass))
+	}
+	l.mu.Unlock()
+
+	if changed && l.Func != nil {
+		l.Func(ctx, p99, exceeded)
+	}
+}
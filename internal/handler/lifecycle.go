@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// initGuest calls handler.FuncInit, if exported and the guest wasn't restored
+// from a snapshot, and tracks the guest if it exports handler.FuncShutdown, so
+// that it is called even if the guest isn't closed before the runtime.
+func (r *Runtime) initGuest(ctx context.Context, g *Guest) error {
+	if r.snapshot == nil {
+		if err := call(ctx, handler.FuncInit, g.init); err != nil {
+			return err
+		}
+	}
+	if g.guestExports.shutdown == nil {
+		return nil
+	}
+	r.liveMu.Lock()
+	defer r.liveMu.Unlock()
+	if r.live == nil {
+		r.live = map[*Guest]struct{}{}
+	}
+	r.live[g] = struct{}{}
+	return nil
+}
+
+// closeGuest calls handler.FuncShutdown unless the runtime already did.
+func (r *Runtime) closeGuest(ctx context.Context, g *Guest) {
+	r.liveMu.Lock()
+	_, ok := r.live[g]
+	delete(r.live, g)
+	r.liveMu.Unlock()
+	if ok {
+		g.shutdown(ctx)
+	}
+}
+
+// shutdownGuests calls handler.FuncShutdown on all guests not yet closed.
+func (r *Runtime) shutdownGuests(ctx context.Context) {
+	r.liveMu.Lock()
+	live := r.live
+	r.live = nil
+	r.liveMu.Unlock()
+	for g := range live {
+		g.shutdown(ctx)
+	}
+}
+
+// shutdown calls handler.FuncShutdown, logging any trap, as it is too late to
+// respond.
+func (g *Guest) shutdown(ctx context.Context) {
+	if err := call(ctx, handler.FuncShutdown, g.guestExports.shutdown); err != nil {
+		g.r.logFn(ctx, err.Error())
+	}
+}
+
+// prewarm instantiates guests for NewGuest to take.
+func (r *Runtime) prewarm(ctx context.Context, count int) error {
+	for i := 0; i < count; i++ {
+		g, err := r.instantiate(ctx)
+		if err != nil {
+			return err
+		}
+		r.putIdle(g)
+	}
+	return nil
+}
+
+// Ping instantiates a guest, or takes an idle one, and calls handler.FuncPing,
+// if exported. The guest is kept idle for NewGuest, unless it trapped.
+func (r *Runtime) Ping(ctx context.Context) error {
+	g, err := r.NewGuest(ctx)
+	if err != nil {
+		return err
+	}
+	if err = call(ctx, handler.FuncPing, g.ping); err != nil {
+		_ = g.Close(ctx)
+		return err
+	}
+	g.r.putIdle(g) // the runtime of its shard, if sharded
+	return nil
+}
+
+func (r *Runtime) takeIdle() *Guest {
+	r.idleMu.Lock()
+	defer r.idleMu.Unlock()
+	n := len(r.idle)
+	if n == 0 {
+		return nil
+	}
+	g := r.idle[n-1]
+	r.idle = r.idle[:n-1]
+	return g
+}
+
+func (r *Runtime) putIdle(g *Guest) {
+	r.idleMu.Lock()
+	r.idle = append(r.idle, g)
+	r.idleMu.Unlock()
+}
+
+// closeIdle closes guests no handler took.
+func (r *Runtime) closeIdle(ctx context.Context) {
+	r.idleMu.Lock()
+	idle := r.idle
+	r.idle = nil
+	r.idleMu.Unlock()
+	for _, g := range idle {
+		_ = g.Close(ctx)
+	}
+}
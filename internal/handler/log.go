@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"context"
+	"sync"
+
+	"github.com/http-wasm/http-wasm-host-go/internal"
+)
+
+// logCountKey is a context.Context Value associated with the count of
+// messages the guest logged during the current request. This is only present
+// when internal.LogLimits PerRequest is positive.
+type logCountKey struct{}
+
+// logLimiter enforces internal.LogLimits.
+type logLimiter struct {
+	internal.LogLimits
+
+	// mu guards tokens and last, which are a token bucket for Rate.
+	mu     sync.Mutex
+	tokens float64
+	last   int64
+}
+
+func newLogLimiter(limits internal.LogLimits) *logLimiter {
+	if limits.MessageBytes <= 0 && limits.PerRequest <= 0 && limits.Rate <= 0 {
+		return nil // no limits
+	}
+	return &logLimiter{LogLimits: limits, tokens: float64(limits.Burst), last: -1}
+}
+
+// withLogCount returns a context which counts messages logged, if limited per
+// request.
+func (r *Runtime) withLogCount(ctx context.Context) context.Context {
+	if r.logLimiter == nil || r.logLimiter.PerRequest <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, logCountKey{}, new(int))
+}
+
+// limitLog returns the message to log, truncated if too long, or false if it
+// should be dropped.
+func (r *Runtime) limitLog(ctx context.Context, msg string) (string, bool) {
+	l := r.logLimiter
+	if l == nil {
+		return msg, true
+	}
+	if count, ok := ctx.Value(logCountKey{}).(*int); ok {
+		if *count++; *count > l.PerRequest {
+			l.dropped(ctx, "request")
+			return "", false
+		}
+	}
+	if l.Rate > 0 && !l.take(r.clock.Nanotime()) {
+		l.dropped(ctx, "rate")
+		return "", false
+	}
+	if l.MessageBytes > 0 && len(msg) > l.MessageBytes {
+		msg = msg[:l.MessageBytes] + "..."
+	}
+	return msg, true
+}
+
+// take returns true if a token was available at the given time in
+// nanoseconds.
+func (l *logLimiter) take(now int64) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.last >= 0 {
+		l.tokens += float64(now-l.last) * l.Rate / 1e9
+		if burst := float64(l.Burst); l.tokens > burst {
+			l.tokens = burst
+		}
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+func (l *logLimiter) dropped(ctx context.Context, reason string) {
+	if l.Dropped != nil {
+		l.Dropped(ctx, reason)
+	}
+}
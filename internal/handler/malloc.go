@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// allocator allocates guest memory with the guest function export
+// handler.FuncMalloc. When a host function fails after allocating, the
+// allocation is released with handler.FuncFree, if exported, as the guest
+// never learns its address.
+type allocator struct {
+	ctx     context.Context
+	mod     wazeroapi.Module
+	exports *guestExports
+	// ptr is the memory offset of the allocation, if allocated.
+	ptr       uint32
+	allocated bool
+}
+
+// alloc allocates size bytes of guest memory, returning a view of it. This
+// panics if the guest doesn't export handler.FuncMalloc.
+func (a *allocator) alloc(size uint32) []byte {
+	fn := a.exports.malloc
+	if fn == nil {
+		panic(fmt.Errorf("guest doesn't export func[%s]", handler.FuncMalloc))
+	}
+	results, err := fn.Call(a.ctx, uint64(size))
+	if err != nil {
+		panic(err)
+	}
+	a.ptr, a.allocated = uint32(results[0]), true
+	return mustRead(a.ctx, a.mod.Memory(), "allocation", a.ptr, size)
+}
+
+// freeOnPanic is deferred by host functions that allocate, to release the
+// allocation when they panic. The panic continues.
+func (a *allocator) freeOnPanic() {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	if fn := a.exports.free; fn != nil && a.allocated {
+		_, _ = fn.Call(a.ctx, uint64(a.ptr)) // best efforts as already failing
+	}
+	panic(recovered)
+}
+
+// readRequestBody is the WebAssembly function export named
+// handler.FuncReadRequestBody which reads the request body into memory
+// allocated by the guest. The result is `ptr<<32|body_len` or zero if the
+// body is empty.
+func (r *Runtime) readRequestBody(ctx context.Context, mod wazeroapi.Module) uint64 {
+	defer r.recoverHost(ctx, handler.FuncReadRequestBody)
+	a := &allocator{ctx: ctx, mod: mod, exports: r.exportsOf(mod)}
+	defer a.freeOnPanic()
+	bodyLen := r.host.ReadRequestBody(ctx, a.alloc)
+	if bodyLen == 0 {
+		return 0
+	}
+	return uint64(a.ptr)<<32 | uint64(bodyLen)
+}
+
+// readRequestHeaderAlloc is the WebAssembly function export named
+// handler.FuncReadRequestHeaderAlloc which writes a header value to memory
+// allocated by the guest. The result is `ptr<<32|value_len` or zero if the
+// header doesn't exist or is empty.
+func (r *Runtime) readRequestHeaderAlloc(ctx context.Context, mod wazeroapi.Module,
+	name, nameLen uint32) uint64 {
+	defer r.recoverHost(ctx, handler.FuncReadRequestHeaderAlloc)
+	n := mustReadString(ctx, mod.Memory(), "name", name, nameLen)
+	value, _ := r.host.GetRequestHeader(ctx, n)
+	return r.allocValue(ctx, mod, []byte(value))
+}
+
+// readResponseBodyAlloc is the WebAssembly function export named
+// handler.FuncReadResponseBodyAlloc which writes the buffered response body
+// to memory allocated by the guest. The result is `ptr<<32|body_len` or zero
+// if the body is empty.
+func (r *Runtime) readResponseBodyAlloc(ctx context.Context, mod wazeroapi.Module) uint64 {
+	defer r.recoverHost(ctx, handler.FuncReadResponseBodyAlloc)
+	return r.allocValue(ctx, mod, r.host.GetResponseBody(ctx))
+}
+
+// allocValue copies the value into memory allocated by the guest, returning
+// `ptr<<32|value_len`, or zero without allocating if the value is empty.
+func (r *Runtime) allocValue(ctx context.Context, mod wazeroapi.Module, value []byte) uint64 {
+	if len(value) == 0 {
+		return 0
+	}
+	a := &allocator{ctx: ctx, mod: mod, exports: r.exportsOf(mod)}
+	copy(a.alloc(uint32(len(value))), value)
+	return uint64(a.ptr)<<32 | uint64(len(value))
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	"github.com/http-wasm/http-wasm-host-go/internal/wasm"
+)
+
+// compileMemory compiles a module which provides the memory the guest
+// imports, if any, returning it and the name of the module to instantiate it
+// as. Host functions use the memory of the calling module, so work the same
+// whether the guest exports or imports its memory.
+//
+// Note: Shared memory, as used by the threads proposal, isn't supported by
+// the runtime, so guests importing it fail to compile.
+func (r *Runtime) compileMemory(ctx context.Context) (wazero.CompiledModule, string, error) {
+	for _, m := range r.guestModule.ImportedMemories() {
+		module, name, _ := m.Import()
+		if module == handler.HostModule || module == wasi_snapshot_preview1.ModuleName {
+			return nil, "", invalidGuest(handler.ErrMissingMemory, "guest imports memory[%s.%s], which the host can't provide", module, name)
+		}
+		max, hasMax := m.Max()
+		compiled, err := r.runtime.CompileModule(ctx, wasm.MemoryModule(name, m.Min(), max, hasMax))
+		if err != nil {
+			return nil, "", fmt.Errorf("wasm: error compiling memory[%s.%s]: %w", module, name, err)
+		}
+		return compiled, module, nil
+	}
+	return nil, "", nil
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/api"
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// maxMetrics limits the metrics a guest can define, as each is a time series
+// in the backend.
+const maxMetrics = 1000
+
+// metricRegistry are the metrics defined by instances of a guest, indexed by
+// ID.
+type metricRegistry struct {
+	// backend is internal.WazeroOptions Metrics, or nil to discard metrics.
+	backend api.Metrics
+
+	mu      sync.RWMutex
+	ids     map[string]uint32
+	metrics []definedMetric
+}
+
+type definedMetric struct {
+	api.Metric
+	kind api.MetricKind
+	name string
+}
+
+// define returns the ID of the metric, defining it with the backend if it
+// wasn't already.
+func (m *metricRegistry) define(kind api.MetricKind, name string) (uint32, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if id, ok := m.ids[name]; ok {
+		if d := m.metrics[id]; d.kind != kind {
+			return 0, fmt.Errorf("metric %q already defined as a %s", name, d.kind)
+		}
+		return id, nil
+	}
+	if len(m.metrics) >= maxMetrics {
+		return 0, fmt.Errorf("too many metrics: limit %d", maxMetrics)
+	}
+
+	var metric api.Metric = noopMetric{}
+	if m.backend != nil {
+		var err error
+		if metric, err = m.backend.Define(kind, name); err != nil {
+			return 0, fmt.Errorf("error defining metric %q: %w", name, err)
+		}
+	}
+	if m.ids == nil {
+		m.ids = map[string]uint32{}
+	}
+	id := uint32(len(m.metrics))
+	m.ids[name] = id
+	m.metrics = append(m.metrics, definedMetric{Metric: metric, kind: kind, name: name})
+	return id, nil
+}
+
+// get returns the metric with the ID, or panics if it doesn't exist.
+func (m *metricRegistry) get(id uint32) definedMetric {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if id >= uint32(len(m.metrics)) {
+		panic(fmt.Errorf("metric %d not defined", id))
+	}
+	return m.metrics[id]
+}
+
+// validMetricName returns true if the name is in the format
+// "[a-zA-Z_:][a-zA-Z0-9_:]*", which is compatible with Prometheus.
+func validMetricName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c == '_', c == ':':
+		case c >= '0' && c <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// defineMetric is the WebAssembly function export named
+// handler.FuncDefineMetric which defines a metric with a name read from
+// memory. The result is its ID.
+func (r *Runtime) defineMetric(ctx context.Context, mod wazeroapi.Module,
+	kind, name, nameLen uint32) uint32 {
+	defer r.recoverHost(ctx, handler.FuncDefineMetric)
+	n := mustReadString(ctx, mod.Memory(), "name", name, nameLen)
+
+	k := api.MetricKind(kind)
+	if k > api.MetricHistogram {
+		panic(fmt.Errorf("invalid metric kind %d", kind))
+	} else if !validMetricName(n) {
+		panic(fmt.Errorf("invalid metric name %q", n))
+	}
+	id, err := r.metrics.define(k, n)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// incrementMetric is the WebAssembly function export named
+// handler.FuncIncrementMetric which adds to a counter or gauge.
+func (r *Runtime) incrementMetric(ctx context.Context, metricID uint32, delta int64) {
+	defer r.recoverHost(ctx, handler.FuncIncrementMetric)
+	m := r.metrics.get(metricID)
+	switch {
+	case m.kind == api.MetricHistogram:
+		panic(fmt.Errorf("can't increment histogram %q", m.name))
+	case m.kind == api.MetricCounter && delta < 0:
+		panic(fmt.Errorf("can't decrease counter %q", m.name))
+	}
+	m.Add(ctx, delta)
+}
+
+// recordMetric is the WebAssembly function export named
+// handler.FuncRecordMetric which sets a gauge or records a histogram value.
+func (r *Runtime) recordMetric(ctx context.Context, metricID uint32, value int64) {
+	defer r.recoverHost(ctx, handler.FuncRecordMetric)
+	m := r.metrics.get(metricID)
+	if m.kind == api.MetricCounter {
+		panic(fmt.Errorf("can't record counter %q", m.name))
+	}
+	m.Record(ctx, value)
+}
+
+// noopMetric discards values when there is no metrics backend.
+type noopMetric struct{}
+
+// Add implements the same method as documented on api.Metric.
+func (noopMetric) Add(context.Context, int64) {}
+
+// Record implements the same method as documented on api.Metric.
+func (noopMetric) Record(context.Context, int64) {}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// maxSniffLen is the count of bytes http.DetectContentType considers.
+const maxSniffLen = 512
+
+// sniffContentType is the WebAssembly function export named
+// handler.FuncSniffContentType which writes the content type of data read
+// from memory if it isn't larger than the buffer size limit. The result is
+// the length of the content type in bytes.
+func (r *Runtime) sniffContentType(ctx context.Context, mod wazeroapi.Module,
+	data, dataLen, buf, bufLimit uint32) (typeLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncSniffContentType)
+	b := mustRead(ctx, mod.Memory(), "data", data, dataLen)
+	if len(b) > maxSniffLen {
+		b = b[:maxSniffLen]
+	}
+	contentType := http.DetectContentType(b)
+	return writeIfUnderLimit(ctx, mod.Memory(), "content type", buf, bufLimit, []byte(contentType))
+}
+
+// negotiateContentType is the WebAssembly function export named
+// handler.FuncNegotiateContentType which writes the offer the client prefers
+// to memory if it isn't larger than the buffer size limit. The result is
+// `1<<32|type_len` or zero if the client accepts none of the offers.
+func (r *Runtime) negotiateContentType(ctx context.Context, mod wazeroapi.Module,
+	offers, offersLen, buf, bufLimit uint32) uint64 {
+	defer r.recoverHost(ctx, handler.FuncNegotiateContentType)
+	o := mustReadString(ctx, mod.Memory(), "offers", offers, offersLen)
+	accept := http.Header(r.host.GetRequestHeaders(ctx)).Values("Accept")
+	offer, ok := negotiate(accept, strings.Split(o, ","))
+	return r.writeValue(ctx, mod, offer, ok, buf, bufLimit)
+}
+
+// mediaRange is a media range of an "Accept" header. Ex. "text/*;q=0.5"
+type mediaRange struct {
+	typ, subtype string
+	params       map[string]string
+	q            float64
+}
+
+// negotiate returns the offer with the highest quality value in the "Accept"
+// header values, or the first offer if there are none. Ties are broken by the
+// order of the offers. This returns false if no offer is acceptable.
+func negotiate(accept, offers []string) (string, bool) {
+	ranges := parseAccept(accept)
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		offer = strings.TrimSpace(offer)
+		mediaType, params, err := mime.ParseMediaType(offer)
+		if err != nil {
+			continue
+		}
+		if len(ranges) == 0 {
+			return offer, true // no preference, so any offer is acceptable
+		}
+		typ, subtype, _ := strings.Cut(mediaType, "/")
+		if q := offerQuality(ranges, typ, subtype, params); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best, bestQ > 0
+}
+
+// parseAccept parses the media ranges of "Accept" header values, skipping any
+// which are invalid.
+func parseAccept(values []string) (ranges []mediaRange) {
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(s))
+			if err != nil {
+				continue
+			}
+			typ, subtype, ok := strings.Cut(mediaType, "/")
+			if !ok {
+				continue
+			}
+			q := 1.0
+			if v, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(v, 64); err != nil || q < 0 || q > 1 {
+					continue
+				}
+				delete(params, "q")
+			}
+			ranges = append(ranges, mediaRange{typ: typ, subtype: subtype, params: params, q: q})
+		}
+	}
+	return
+}
+
+// offerQuality returns the quality value of the most specific media range
+// the offer matches, or zero if it matches none.
+func offerQuality(ranges []mediaRange, typ, subtype string, params map[string]string) float64 {
+	q, specificity := 0.0, -1
+	for i := range ranges {
+		if s := ranges[i].specificity(typ, subtype, params); s > specificity {
+			q, specificity = ranges[i].q, s
+		}
+	}
+	return q
+}
+
+// specificity returns how specific the media range is, if it matches the
+// offer, or -1 if it doesn't. A range with a subtype is more specific than
+// one with a wildcard, and one with more parameters is more specific.
+func (m *mediaRange) specificity(typ, subtype string, params map[string]string) int {
+	var s int
+	switch {
+	case m.typ == "*" && m.subtype == "*":
+		s = 0
+	case m.typ == typ && m.subtype == "*":
+		s = 1
+	case m.typ == typ && m.subtype == subtype:
+		s = 2
+	default:
+		return -1
+	}
+	for k, v := range m.params {
+		if params[k] != v {
+			return -1
+		}
+	}
+	return s<<16 | len(m.params)
+}
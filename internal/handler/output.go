@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// maxLineBytes flushes output without a line break when it gets this long, so
+// that a guest can't buffer unbounded memory.
+const maxLineBytes = 4096
+
+// compileWASI compiles WASI if the guest imports it, so that it can be
+// instantiated in the namespace of each guest. Otherwise, this returns nil.
+//
+// Note: WASI instantiated in the runtime isn't visible to guests, as each has
+// its own namespace.
+func (r *Runtime) compileWASI(ctx context.Context) (wazero.CompiledModule, error) {
+	for _, f := range r.guestModule.ImportedFunctions() {
+		if module, _, _ := f.Import(); module == wasi_snapshot_preview1.ModuleName {
+			m, err := wasi_snapshot_preview1.NewBuilder(r.runtime).Compile(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("wasm: error compiling WASI: %w", err)
+			}
+			return m, nil
+		}
+	}
+	return nil, nil
+}
+
+// withOutput returns the config with the stdout and stderr of a new guest,
+// and the writers which route them to the logger, if any. Each guest has its
+// own writers, as guests write concurrently.
+func (r *Runtime) withOutput(config wazero.ModuleConfig) (wazero.ModuleConfig, []*logWriter) {
+	var output []*logWriter
+	if r.stdout != nil {
+		config = config.WithStdout(r.stdout)
+	} else {
+		w := &logWriter{r: r, stream: "stdout"}
+		config = config.WithStdout(w)
+		output = append(output, w)
+	}
+	if r.stderr != nil {
+		config = config.WithStderr(r.stderr)
+	} else {
+		w := &logWriter{r: r, stream: "stderr"}
+		config = config.WithStderr(w)
+		output = append(output, w)
+	}
+	return config, output
+}
+
+// logWriter writes output of a guest to the logger a line at a time,
+// prefixed with the name of the stream. Ex. "stdout: hello"
+type logWriter struct {
+	r      *Runtime
+	stream string
+	// line is the output since the last line break.
+	line []byte
+}
+
+// Write implements io.Writer
+func (w *logWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	for {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			break
+		}
+		w.line = append(w.line, p[:i]...)
+		w.flush()
+		p = p[i+1:]
+	}
+	if w.line = append(w.line, p...); len(w.line) >= maxLineBytes {
+		w.flush()
+	}
+	return n, nil
+}
+
+// flush logs the output since the last line break, if any.
+func (w *logWriter) flush() {
+	if len(w.line) == 0 {
+		return
+	}
+	// There is no request context, as the guest may write outside one, such
+	// as when it starts.
+	ctx := context.Background()
+	if msg, ok := w.r.limitLog(ctx, w.stream+": "+string(w.line)); ok {
+		w.r.logFn(ctx, msg)
+	}
+	w.line = w.line[:0]
+}
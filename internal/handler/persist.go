@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/http-wasm/http-wasm-host-go/api"
+	"github.com/http-wasm/http-wasm-host-go/internal"
+)
+
+// persistedStore is a store snapshotted to a file named name in the
+// directory of httpwasm.PersistStores.
+type persistedStore struct {
+	name  string
+	store interface{}
+}
+
+// persistedStores returns the stores to snapshot, in a consistent order.
+func persistedStores(shared api.SharedStore, limiter api.RateLimiter) []persistedStore {
+	return []persistedStore{
+		{name: "sharedstore.json", store: shared},
+		{name: "ratelimit.json", store: limiter},
+	}
+}
+
+// restoreStores replaces the stores of the runtime with those of
+// httpwasm.PersistStores, restoring them from their snapshots if this is the
+// first runtime created with the option.
+func (r *Runtime) restoreStores(p *internal.PersistedStores) error {
+	p.Once.Do(func() {
+		p.SharedStore, p.RateLimiter = r.sharedStore, r.rateLimiter
+		if err := os.MkdirAll(p.Dir, 0o700); err != nil {
+			p.Err = fmt.Errorf("wasm: error creating snapshot directory: %w", err)
+			return
+		}
+		for _, s := range persistedStores(p.SharedStore, p.RateLimiter) {
+			if err := restoreFile(filepath.Join(p.Dir, s.name), s.store); err != nil {
+				p.Err = fmt.Errorf("wasm: error restoring %s: %w", s.name, err)
+				return
+			}
+		}
+	})
+	r.sharedStore, r.rateLimiter = p.SharedStore, p.RateLimiter
+	r.persistDir = p.Dir
+	return p.Err
+}
+
+// restoreFile restores the store from the file, unless the store doesn't
+// implement api.Snapshotter or the file doesn't exist, such as on first
+// startup.
+func restoreFile(path string, store interface{}) error {
+	s, ok := store.(api.Snapshotter)
+	if !ok {
+		return nil
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.Restore(f)
+}
+
+// PersistStores snapshots the shared store and rate limiter to the directory
+// of httpwasm.PersistStores, if set. Hosts call this when the middleware is
+// closed, after requests in flight complete, not when a runtime is replaced.
+func (r *Runtime) PersistStores() (err error) {
+	if r.persistDir == "" {
+		return nil
+	}
+	for _, s := range persistedStores(r.sharedStore, r.rateLimiter) {
+		if e := snapshotFile(r.persistDir, s.name, s.store); e != nil {
+			err = fmt.Errorf("wasm: error snapshotting %s: %w", s.name, e)
+		}
+	}
+	return
+}
+
+// snapshotFile writes a snapshot of the store to the file named name in dir,
+// unless the store doesn't implement api.Snapshotter. The file is replaced
+// atomically, so a crash leaves either the previous or the new snapshot.
+func snapshotFile(dir, name string, store interface{}) error {
+	s, ok := store.(api.Snapshotter)
+	if !ok {
+		return nil
+	}
+	f, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // nolint: fails after the rename
+
+	if err = s.Snapshot(f); err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(dir, name))
+}
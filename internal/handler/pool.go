@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/http-wasm/http-wasm-host-go/internal"
+)
+
+// defaultPoolIdleTimeout is the default of internal.Pool IdleTimeout.
+const defaultPoolIdleTimeout = time.Minute
+
+// guestPool sizes the idle guests of a runtime between internal.Pool Min and
+// Max. It grows as soon as NewGuest waits to instantiate a guest, but only
+// shrinks after an idle timeout without waiting, so that its size doesn't
+// oscillate with bursty traffic.
+type guestPool struct {
+	internal.Pool
+
+	mu sync.Mutex
+	// target is the count of idle guests to keep.
+	target int
+	// waited is true when NewGuest waited since the last idle timeout.
+	waited bool
+	// filling is true while a goroutine instantiates guests up to target.
+	filling bool
+	closed  bool
+	done    chan struct{}
+}
+
+func newGuestPool(p internal.Pool) *guestPool {
+	if p.Min < 0 {
+		p.Min = 0
+	}
+	if p.Max < p.Min {
+		p.Max = p.Min
+	}
+	if p.IdleTimeout <= 0 {
+		p.IdleTimeout = defaultPoolIdleTimeout
+	}
+	return &guestPool{Pool: p, target: p.Min, done: make(chan struct{})}
+}
+
+// checkout is called when NewGuest takes a guest, where waited is true if
+// none were idle. This grows the pool if NewGuest waited, and refills it.
+func (p *guestPool) checkout(r *Runtime, waited bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	if waited {
+		p.waited = true
+		if p.target *= 2; p.target == 0 {
+			p.target = 1
+		}
+		if p.target > p.Max {
+			p.target = p.Max
+		}
+	}
+	if !p.filling {
+		p.filling = true
+		go p.fill(r)
+	}
+}
+
+// fill instantiates guests until the idle guests reach the target, or the
+// pool is closed.
+func (p *guestPool) fill(r *Runtime) {
+	ctx := context.Background()
+	for {
+		p.mu.Lock()
+		if p.closed || r.idleCount() >= p.target {
+			p.filling = false
+			p.mu.Unlock()
+			return
+		}
+		p.mu.Unlock()
+
+		g, err := r.instantiate(ctx)
+		if err != nil {
+			p.mu.Lock()
+			if !p.closed { // otherwise, the runtime closed meanwhile
+				r.logFn(ctx, "wasm: error filling guest pool: "+err.Error())
+			}
+			p.filling = false
+			p.mu.Unlock()
+			return
+		}
+
+		// Hold the lock, so that the runtime can't close between checking
+		// and adding the guest.
+		p.mu.Lock()
+		if p.closed {
+			p.filling = false
+			p.mu.Unlock()
+			_ = g.Close(ctx)
+			return
+		}
+		r.putIdle(g)
+		p.mu.Unlock()
+	}
+}
+
+// run halves the pool, down to Min, each idle timeout without NewGuest
+// waiting, until the pool is closed.
+func (p *guestPool) run(r *Runtime) {
+	ticker := time.NewTicker(p.IdleTimeout)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-p.done:
+			return
+		}
+
+		p.mu.Lock()
+		if !p.waited && p.target > p.Min {
+			if p.target /= 2; p.target < p.Min {
+				p.target = p.Min
+			}
+		}
+		p.waited = false
+		target := p.target
+		p.mu.Unlock()
+
+		for _, g := range r.trimIdle(target) {
+			_ = g.Close(context.Background())
+		}
+	}
+}
+
+// close stops the pool from instantiating guests. The runtime closes those
+// idle.
+func (p *guestPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.closed {
+		p.closed = true
+		close(p.done)
+	}
+}
+
+// idleCount returns the count of idle guests.
+func (r *Runtime) idleCount() int {
+	r.idleMu.Lock()
+	defer r.idleMu.Unlock()
+	return len(r.idle)
+}
+
+// trimIdle removes and returns the oldest idle guests beyond count.
+func (r *Runtime) trimIdle(count int) []*Guest {
+	r.idleMu.Lock()
+	defer r.idleMu.Unlock()
+	n := len(r.idle) - count
+	if n <= 0 {
+		return nil
+	}
+	trimmed := append([]*Guest{}, r.idle[:n]...)
+	r.idle = append(r.idle[:0], r.idle[n:]...)
+	return trimmed
+}
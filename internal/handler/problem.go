@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// problem is the "application/problem+json" format of RFC 9457.
+type problem struct {
+	Type   string `json:"type"`
+	Title  string `json:"title,omitempty"`
+	Status uint32 `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// sendProblem is the WebAssembly function export named
+// handler.FuncSendProblem which sends a problem+json response from a status
+// code, problem code and detail read from memory.
+func (r *Runtime) sendProblem(ctx context.Context, mod wazeroapi.Module,
+	statusCode, code, codeLen, detail, detailLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncSendProblem)
+	p := &problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(int(statusCode)),
+		Status: statusCode,
+		Detail: mustReadString(ctx, mod.Memory(), "detail", detail, detailLen),
+	}
+	if c := mustReadString(ctx, mod.Memory(), "code", code, codeLen); c != "" {
+		p.Type = r.problemTypeBase + c
+	}
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		panic(err)
+	}
+	r.host.SetResponseHeader(ctx, "Content-Type", "application/problem+json")
+	r.host.SendResponse(ctx, statusCode, body)
+}
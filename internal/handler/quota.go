@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/textproto"
+	"strings"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	"github.com/http-wasm/http-wasm-host-go/internal"
+)
+
+// quotaKey is a context.Context Value associated with a quotaUsage pointer,
+// counting what the guest produced for the current request. This is only
+// present when quotas are configured.
+type quotaKey struct{}
+
+type quotaUsage struct {
+	headerMutations, headerBytes, responseBodyBytes int
+	// headerNames are the canonical names of headers and trailers set, if
+	// internal.Quotas HeaderCount is positive.
+	headerNames map[string]struct{}
+}
+
+// withQuotas returns a context which counts usage against quotas, if any.
+func (r *Runtime) withQuotas(ctx context.Context) context.Context {
+	if r.quotas == (internal.Quotas{}) {
+		return ctx
+	}
+	return context.WithValue(ctx, quotaKey{}, &quotaUsage{})
+}
+
+// hostError is an error the guest can handle when it imports
+// handler.FuncGetLastError, instead of trapping.
+type hostError struct {
+	errno handler.Errno
+	err   error
+}
+
+// Error implements the same method as documented on error.
+func (e *hostError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the cause, such as handler.ErrQuotaExceeded.
+func (e *hostError) Unwrap() error {
+	return e.err
+}
+
+// invalidHeader returns a hostError caused by handler.ErrInvalidHeader.
+func invalidHeader(errno handler.Errno, format string, args ...interface{}) error {
+	return &hostError{errno: errno, err: fmt.Errorf("%w: "+format, append([]interface{}{handler.ErrInvalidHeader}, args...)...)}
+}
+
+// admitHeader returns true if the guest can set a response header or trailer
+// with the given name and value, charging it against quotas. Otherwise, the
+// guest fails as documented on fail.
+func (r *Runtime) admitHeader(ctx context.Context, name, value string) bool {
+	err := r.checkHeader(ctx, name, value)
+	if err == nil {
+		err = r.chargeHeader(ctx, len(name)+len(value))
+	}
+	return !r.fail(ctx, err)
+}
+
+// chargeHeader counts setting a header, cookie or trailer of the given size,
+// returning a hostError caused by handler.ErrQuotaExceeded, without counting
+// it, if over a quota.
+func (r *Runtime) chargeHeader(ctx context.Context, size int) error {
+	u, ok := ctx.Value(quotaKey{}).(*quotaUsage)
+	if !ok {
+		return nil
+	}
+	if err := exceeded(handler.ErrnoHeaderMutationsExceeded, u.headerMutations+1, r.quotas.HeaderMutations, "header mutations"); err != nil {
+		return err
+	} else if err = exceeded(handler.ErrnoHeaderBytesExceeded, u.headerBytes+size, r.quotas.HeaderBytes, "header bytes"); err != nil {
+		return err
+	}
+	u.headerMutations++
+	u.headerBytes += size
+	return nil
+}
+
+// checkHeader returns a hostError caused by handler.ErrInvalidHeader if the
+// name or value of a response header or trailer isn't valid HTTP or is too
+// long, or by handler.ErrQuotaExceeded if it sets too many names.
+func (r *Runtime) checkHeader(ctx context.Context, name, value string) error {
+	if !validHeaderName(name) {
+		return invalidHeader(handler.ErrnoInvalidHeaderName, "name %q", name)
+	} else if !validHeaderValue(value) {
+		return invalidHeader(handler.ErrnoInvalidHeaderValue, "value of %s %q", name, value)
+	} else if limit := r.quotas.HeaderNameBytes; limit > 0 && len(name) > limit {
+		return invalidHeader(handler.ErrnoHeaderNameTooLong, "name longer than %d bytes", limit)
+	} else if limit = r.quotas.HeaderValueBytes; limit > 0 && len(value) > limit {
+		return invalidHeader(handler.ErrnoHeaderValueTooLong, "value of %s longer than %d bytes", name, limit)
+	}
+
+	u, ok := ctx.Value(quotaKey{}).(*quotaUsage)
+	if !ok || r.quotas.HeaderCount <= 0 {
+		return nil
+	}
+	key := textproto.CanonicalMIMEHeaderKey(name)
+	if _, ok = u.headerNames[key]; ok {
+		return nil
+	} else if err := exceeded(handler.ErrnoHeaderNamesExceeded, len(u.headerNames)+1, r.quotas.HeaderCount, "header names"); err != nil {
+		return err
+	}
+	if u.headerNames == nil {
+		u.headerNames = map[string]struct{}{}
+	}
+	u.headerNames[key] = struct{}{}
+	return nil
+}
+
+// validHeaderName returns true if the name is a token, as defined by
+// RFC 7230 section 3.2.6.
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// validHeaderValue returns true if the value has no control characters
+// except horizontal tab, as defined by RFC 7230 section 3.2.
+func validHeaderValue(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; (c < ' ' && c != '\t') || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// chargeResponseBody counts sending a response body of the given size,
+// returning a hostError caused by handler.ErrQuotaExceeded, without counting
+// it, if over the quota.
+func (r *Runtime) chargeResponseBody(ctx context.Context, size int) error {
+	u, ok := ctx.Value(quotaKey{}).(*quotaUsage)
+	if !ok {
+		return nil
+	}
+	if err := exceeded(handler.ErrnoResponseBodyBytesExceeded, u.responseBodyBytes+size, r.quotas.ResponseBodyBytes, "response body bytes"); err != nil {
+		return err
+	}
+	u.responseBodyBytes += size
+	return nil
+}
+
+// exceeded returns a hostError with the given errno if the usage is over a
+// positive limit.
+func exceeded(errno handler.Errno, usage, limit int, what string) error {
+	if limit > 0 && usage > limit {
+		return &hostError{errno: errno, err: fmt.Errorf("%w: more than %d %s", handler.ErrQuotaExceeded, limit, what)}
+	}
+	return nil
+}
+
+// lastErrorKey is a context.Context Value associated with a handler.Errno
+// pointer, read by handler.FuncGetLastError. This is only present when the
+// guest imports that function.
+type lastErrorKey struct{}
+
+// withLastError returns a context which records errors the guest can handle,
+// if it imports handler.FuncGetLastError.
+func (r *Runtime) withLastError(ctx context.Context) context.Context {
+	if !r.getsLastError {
+		return ctx
+	}
+	return context.WithValue(ctx, lastErrorKey{}, new(handler.Errno))
+}
+
+// fail returns false if the error is nil. Otherwise, it returns true after
+// recording a hostError for handler.FuncGetLastError, if the guest imports
+// it, or panics to trap the guest.
+func (r *Runtime) fail(ctx context.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+	var e *hostError
+	if last, ok := ctx.Value(lastErrorKey{}).(*handler.Errno); ok && errors.As(err, &e) {
+		*last = e.errno
+		return true
+	}
+	panic(err)
+}
+
+// getLastError is the WebAssembly function export named
+// handler.FuncGetLastError which returns and clears the handler.Errno of the
+// last host function which failed.
+func (r *Runtime) getLastError(ctx context.Context) (errno uint32) {
+	defer r.recoverHost(ctx, handler.FuncGetLastError)
+	if last, ok := ctx.Value(lastErrorKey{}).(*handler.Errno); ok {
+		errno, *last = uint32(*last), handler.ErrnoSuccess
+	}
+	return
+}
@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// getRandom is the WebAssembly function export named handler.FuncGetRandom
+// which fills memory with random bytes.
+func (r *Runtime) getRandom(ctx context.Context, mod wazeroapi.Module,
+	buf, bufLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncGetRandom)
+	b := mustRead(ctx, mod.Memory(), "buf", buf, bufLen)
+	if _, err := io.ReadFull(r.random, b); err != nil {
+		panic(fmt.Errorf("error reading random: %w", err))
+	}
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// sendRedirect is the WebAssembly function export named
+// handler.FuncSendRedirect which redirects the client to a location read
+// from memory.
+func (r *Runtime) sendRedirect(ctx context.Context, mod wazeroapi.Module,
+	statusCode, location, locationLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncSendRedirect)
+	switch statusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		panic(fmt.Errorf("status_code %d isn't a redirect", statusCode))
+	}
+	l := mustReadString(ctx, mod.Memory(), "location", location, locationLen)
+	if l == "" {
+		panic(errors.New("location is empty"))
+	}
+	if !r.admitHeader(ctx, "Location", l) {
+		return
+	}
+	r.host.SetResponseHeader(ctx, "Location", l)
+	r.host.SendResponse(ctx, statusCode, nil)
+}
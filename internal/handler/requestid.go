@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// RequestIDHeader is the header which propagates the request ID.
+const RequestIDHeader = "X-Request-Id"
+
+// maxRequestIDLen limits the length of a propagated request ID, so that a
+// client can't flood logs with a large one.
+const maxRequestIDLen = 128
+
+// RequestID returns the ID of a request with the header, or empty unless
+// internal.WazeroOptions RequestID is set. This propagates RequestIDHeader or
+// the trace ID of the "traceparent" header, if valid. Otherwise, it generates
+// a random ID in the same format as a trace ID.
+func (r *Runtime) RequestID(header http.Header) string {
+	if !r.requestID {
+		return ""
+	}
+	if id := header.Get(RequestIDHeader); isValidRequestID(id) {
+		return id
+	}
+	if id, ok := traceID(header.Get("traceparent")); ok {
+		return id
+	}
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id[:])
+}
+
+// isValidRequestID returns true if the ID is printable ASCII and not too
+// long to log.
+func isValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// traceID returns the trace ID of a W3C traceparent header, or false if it
+// isn't valid. Ex. "4bf92f3577b34da6a3ce929d0e0e4736" for
+// "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+func traceID(traceparent string) (string, bool) {
+	parts := strings.Split(traceparent, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return "", false
+	}
+	id := parts[1]
+	if len(id) != 32 || id == "00000000000000000000000000000000" {
+		return "", false
+	}
+	if _, err := hex.DecodeString(id); err != nil || strings.ToLower(id) != id {
+		return "", false
+	}
+	return id, true
+}
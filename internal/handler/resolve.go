@@ -0,0 +1,40 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// resolveTimeout bounds how long handler.FuncResolve blocks the request.
+const resolveTimeout = 5 * time.Second
+
+// resolve is the WebAssembly function export named handler.FuncResolve which
+// writes the NUL-terminated addresses of a host name to memory if they aren't
+// larger than the buffer size limit. The result is `1<<32|value_len` or zero
+// if the name couldn't be resolved.
+func (r *Runtime) resolve(ctx context.Context, mod wazeroapi.Module,
+	name, nameLen, buf, bufLimit uint32) (result uint64) {
+	defer r.recoverHost(ctx, handler.FuncResolve)
+	n := mustReadString(ctx, mod.Memory(), "name", name, nameLen)
+
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+	addrs, err := r.resolver.LookupIPAddr(ctx, n)
+	if err != nil || len(addrs) == 0 {
+		r.logFn(ctx, fmt.Sprintf("resolve %s failed: %v", n, err))
+		return 0
+	}
+
+	var value strings.Builder
+	for _, a := range addrs {
+		value.WriteString(a.String())
+		value.WriteByte(0)
+	}
+	return r.writeValue(ctx, mod, value.String(), true, buf, bufLimit)
+}
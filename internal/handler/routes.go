@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// routes is the JSON format of handler.CustomSectionRoutes.
+type routes struct {
+	Methods      []string `json:"methods"`
+	PathPrefixes []string `json:"path_prefixes"`
+}
+
+// parseRoutes parses the custom section named handler.CustomSectionRoutes,
+// if present.
+func (r *Runtime) parseRoutes() error {
+	data, ok := r.CustomSection(handler.CustomSectionRoutes)
+	if !ok {
+		return nil // guest supports all requests
+	}
+
+	rs := &routes{}
+	if err := json.Unmarshal(data, rs); err != nil {
+		return fmt.Errorf("wasm: guest custom section[%s]: %w", handler.CustomSectionRoutes, err)
+	}
+	r.routes = rs
+	return nil
+}
+
+// Supports returns false if the guest declared it doesn't support requests
+// with the given method and path, via handler.CustomSectionRoutes.
+func (r *Runtime) Supports(method, path string) bool {
+	if r.routes == nil {
+		return true
+	}
+	return matchesAny(r.routes.Methods, func(m string) bool { return m == method }) &&
+		matchesAny(r.routes.PathPrefixes, func(p string) bool { return strings.HasPrefix(path, p) })
+}
+
+// matchesAny returns true if values is empty or any value matches.
+func matchesAny(values []string, matches func(string) bool) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, v := range values {
+		if matches(v) {
+			return true
+		}
+	}
+	return false
+}
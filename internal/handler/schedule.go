@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"fmt"
+
+	"github.com/http-wasm/http-wasm-host-go/internal/schedule"
+)
+
+// schedules are the windows a guest is active or bypassed during.
+type schedules struct {
+	active, bypass []*schedule.Window
+}
+
+func parseSchedules(active, bypass []string) (s schedules, err error) {
+	if s.active, err = parseWindows(active); err != nil {
+		return
+	}
+	s.bypass, err = parseWindows(bypass)
+	return
+}
+
+func parseWindows(specs []string) ([]*schedule.Window, error) {
+	windows := make([]*schedule.Window, 0, len(specs))
+	for _, spec := range specs {
+		w, err := schedule.Parse(spec)
+		if err != nil {
+			return nil, fmt.Errorf("wasm: invalid schedule %q: %w", spec, err)
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// bypassed returns true if the guest shouldn't run now, due to its schedule
+// or exceeding its latency budget.
+func (r *Runtime) bypassed() bool {
+	if r.latency != nil && r.latency.bypassed() {
+		return true
+	}
+	if len(r.schedules.active) == 0 && len(r.schedules.bypass) == 0 {
+		return false
+	}
+	now := r.clock.Now()
+	for _, w := range r.schedules.bypass {
+		if w.Contains(now) {
+			return true
+		}
+	}
+	for _, w := range r.schedules.active {
+		if w.Contains(now) {
+			return false
+		}
+	}
+	return len(r.schedules.active) > 0
+}
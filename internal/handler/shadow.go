@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/http-wasm/http-wasm-host-go/api"
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// shadowKey is a context.Context Value associated with the shadowState of the
+// current request, when internal.WazeroOptions Shadow is set.
+type shadowKey struct{}
+
+type shadowState struct {
+	mutations []api.Mutation
+	// nextCalled is true when the guest invoked the next handler, so that it
+	// isn't invoked twice.
+	nextCalled bool
+}
+
+// withShadow returns a context which records mutations of the guest, or nil
+// state if not in shadow mode.
+func (r *Runtime) withShadow(ctx context.Context) (context.Context, *shadowState) {
+	if r.shadow == nil {
+		return ctx, nil
+	}
+	s := &shadowState{}
+	return context.WithValue(ctx, shadowKey{}, s), s
+}
+
+// endShadow invokes the next handler, unless the guest did, and reports the
+// mutations of the guest.
+func (r *Runtime) endShadow(ctx context.Context, s *shadowState) {
+	if !s.nextCalled {
+		r.host.Next(ctx)
+	}
+	r.shadow(ctx, s.mutations)
+}
+
+// shadowHost is a handler.Host which records calls that change the request or
+// response, instead of applying them, when the context is of a request in
+// shadow mode. Other calls, such as by the runtime before the guest handles
+// the request, apply as usual.
+type shadowHost struct {
+	FullHost
+}
+
+// record returns true if the call was recorded, as the request is in shadow
+// mode.
+func (h shadowHost) record(ctx context.Context, method string, args ...interface{}) bool {
+	s, ok := ctx.Value(shadowKey{}).(*shadowState)
+	if !ok {
+		return false
+	}
+	m := api.Mutation{Method: method, Args: make([]string, len(args))}
+	for i, arg := range args {
+		if b, ok := arg.([]byte); ok {
+			m.Args[i] = string(b)
+		} else {
+			m.Args[i] = fmt.Sprint(arg)
+		}
+	}
+	s.mutations = append(s.mutations, m)
+	return true
+}
+
+// Next implements the same method as documented on handler.Host.
+func (h shadowHost) Next(ctx context.Context) {
+	if s, ok := ctx.Value(shadowKey{}).(*shadowState); ok {
+		if s.nextCalled {
+			return
+		}
+		s.nextCalled = true
+	}
+	h.FullHost.Next(ctx)
+}
+
+// SetRawResponseHeader implements the same method as documented on
+// handler.RawHeaders.
+func (h shadowHost) SetRawResponseHeader(ctx context.Context, name, value string) {
+	if !h.record(ctx, "SetRawResponseHeader", name, value) {
+		h.FullHost.SetRawResponseHeader(ctx, name, value)
+	}
+}
+
+// SetResponseHeader implements the same method as documented on handler.Host.
+func (h shadowHost) SetResponseHeader(ctx context.Context, name, value string) {
+	if !h.record(ctx, "SetResponseHeader", name, value) {
+		h.FullHost.SetResponseHeader(ctx, name, value)
+	}
+}
+
+// SendResponse implements the same method as documented on handler.Host.
+func (h shadowHost) SendResponse(ctx context.Context, statusCode uint32, body []byte) {
+	if !h.record(ctx, "SendResponse", statusCode, body) {
+		h.FullHost.SendResponse(ctx, statusCode, body)
+	}
+}
+
+// MirrorRequest implements the same method as documented on handler.Proxy.
+func (h shadowHost) MirrorRequest(ctx context.Context, url string) {
+	if !h.record(ctx, "MirrorRequest", url) {
+		h.FullHost.MirrorRequest(ctx, url)
+	}
+}
+
+// SetStatusCode implements the same method as documented on handler.Host.
+func (h shadowHost) SetStatusCode(ctx context.Context, statusCode uint32) {
+	if !h.record(ctx, "SetStatusCode", statusCode) {
+		h.FullHost.SetStatusCode(ctx, statusCode)
+	}
+}
+
+// SetQueryValue implements the same method as documented on handler.Host.
+func (h shadowHost) SetQueryValue(ctx context.Context, name, value string) {
+	if !h.record(ctx, "SetQueryValue", name, value) {
+		h.FullHost.SetQueryValue(ctx, name, value)
+	}
+}
+
+// SetUpstream implements the same method as documented on handler.Proxy.
+func (h shadowHost) SetUpstream(ctx context.Context, upstream string) {
+	if !h.record(ctx, "SetUpstream", upstream) {
+		h.FullHost.SetUpstream(ctx, upstream)
+	}
+}
+
+// SetTimeout implements the same method as documented on handler.Proxy.
+func (h shadowHost) SetTimeout(ctx context.Context, timeout time.Duration) {
+	if !h.record(ctx, "SetTimeout", timeout) {
+		h.FullHost.SetTimeout(ctx, timeout)
+	}
+}
+
+// SetRetryPolicy implements the same method as documented on handler.Proxy.
+func (h shadowHost) SetRetryPolicy(ctx context.Context, policy handler.RetryPolicy) {
+	if !h.record(ctx, "SetRetryPolicy", policy.Attempts, policy.Backoff) {
+		h.FullHost.SetRetryPolicy(ctx, policy)
+	}
+}
+
+// SetCookie implements the same method as documented on handler.CookieSetter.
+func (h shadowHost) SetCookie(ctx context.Context, name, value, attrs string) {
+	if !h.record(ctx, "SetCookie", name, value, attrs) {
+		h.FullHost.SetCookie(ctx, name, value, attrs)
+	}
+}
+
+// SetResponseTrailer implements the same method as documented on
+// handler.Trailers.
+func (h shadowHost) SetResponseTrailer(ctx context.Context, name, value string) {
+	if !h.record(ctx, "SetResponseTrailer", name, value) {
+		h.FullHost.SetResponseTrailer(ctx, name, value)
+	}
+}
+
+// SetProperty implements the same method as documented on handler.Properties.
+func (h shadowHost) SetProperty(ctx context.Context, name, value string) {
+	if !h.record(ctx, "SetProperty", name, value) {
+		h.FullHost.SetProperty(ctx, name, value)
+	}
+}
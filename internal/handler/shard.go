@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"context"
+	"sync/atomic"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	"github.com/http-wasm/http-wasm-host-go/internal"
+)
+
+// newShards creates the runtimes other than this one, up to count, each
+// compiling the guest in its own wazero runtime. State guests can observe
+// across requests, such as the shared store, is shared with this runtime, so
+// that sharding is transparent to guests.
+func (r *Runtime) newShards(ctx context.Context, guest []byte, host handler.Host, count int, options []httpwasm.Option) error {
+	options = append(append([]httpwasm.Option{}, options...), func(o *internal.WazeroOptions) {
+		o.Shards = internal.Shards{}
+	})
+	for i := 1; i < count; i++ {
+		s, err := NewRuntime(ctx, guest, host, options...)
+		if err != nil {
+			return err
+		}
+		s.sharedStore = r.sharedStore
+		s.rateLimiter = r.rateLimiter
+		s.metrics = r.metrics
+		s.logLimiter = r.logLimiter
+		s.concurrency = r.concurrency
+		s.latency = r.latency
+		s.instances = r.instances
+		s.events.close()
+		s.events = r.events
+		s.ticks = r.ticks
+		r.shards = append(r.shards, s)
+	}
+	return nil
+}
+
+// shard returns the runtime to instantiate the next guest in, which is this
+// one unless sharded.
+func (r *Runtime) shard() *Runtime {
+	if len(r.shards) == 0 {
+		return r
+	}
+	i := atomic.AddUint64(&r.nextShard, 1) % uint64(len(r.shards)+1)
+	if i == 0 {
+		return r
+	}
+	return r.shards[i-1]
+}
+
+func (r *Runtime) closeShards(ctx context.Context) {
+	for _, s := range r.shards {
+		_ = s.Close(ctx)
+	}
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// getShared is the WebAssembly function export named handler.FuncGetShared
+// which writes the value of a shared key to memory if it exists and isn't
+// larger than the buffer size limit. The result is `1<<32|value_len` or zero
+// if the key doesn't exist.
+func (r *Runtime) getShared(ctx context.Context, mod wazeroapi.Module,
+	key, keyLen, buf, bufLimit uint32) (result uint64) {
+	defer r.recoverHost(ctx, handler.FuncGetShared)
+	k := mustReadString(ctx, mod.Memory(), "key", key, keyLen)
+	v, ok, err := r.sharedStore.Get(ctx, k)
+	if err != nil {
+		panic(fmt.Errorf("error getting shared key %q: %w", k, err))
+	}
+	return r.writeValue(ctx, mod, string(v), ok, buf, bufLimit)
+}
+
+// setShared is the WebAssembly function export named handler.FuncSetShared
+// which sets the value of a shared key read from memory.
+func (r *Runtime) setShared(ctx context.Context, mod wazeroapi.Module,
+	key, keyLen, value, valueLen, ttlMillis uint32) {
+	defer r.recoverHost(ctx, handler.FuncSetShared)
+	k := mustReadString(ctx, mod.Memory(), "key", key, keyLen)
+	v := mustRead(ctx, mod.Memory(), "value", value, valueLen)
+	if err := r.sharedStore.Set(ctx, k, v, ttl(ttlMillis)); err != nil {
+		panic(fmt.Errorf("error setting shared key %q: %w", k, err))
+	}
+}
+
+// casShared is the WebAssembly function export named handler.FuncCasShared
+// which sets the value of a shared key read from memory, if its current
+// value matches. The result is one if the value was set.
+func (r *Runtime) casShared(ctx context.Context, mod wazeroapi.Module,
+	key, keyLen, old, oldLen, value, valueLen, ttlMillis uint32) uint32 {
+	defer r.recoverHost(ctx, handler.FuncCasShared)
+	k := mustReadString(ctx, mod.Memory(), "key", key, keyLen)
+	o := mustRead(ctx, mod.Memory(), "old", old, oldLen)
+	v := mustRead(ctx, mod.Memory(), "value", value, valueLen)
+	swapped, err := r.sharedStore.CompareAndSwap(ctx, k, o, v, ttl(ttlMillis))
+	if err != nil {
+		panic(fmt.Errorf("error swapping shared key %q: %w", k, err))
+	} else if swapped {
+		return 1
+	}
+	return 0
+}
+
+// readState is the WebAssembly function export named handler.FuncReadState
+// which writes the value of a persistent key read from memory, if it exists.
+func (r *Runtime) readState(ctx context.Context, mod wazeroapi.Module,
+	key, keyLen, buf, bufLimit uint32) (result uint64) {
+	defer r.recoverHost(ctx, handler.FuncReadState)
+	if r.stateStore == nil {
+		return 0 // handler.FeatureStateStore isn't enabled
+	}
+	k := mustReadString(ctx, mod.Memory(), "key", key, keyLen)
+	v, ok, err := r.stateStore.Load(ctx, k)
+	if err != nil {
+		panic(fmt.Errorf("error reading state key %q: %w", k, err))
+	}
+	return r.writeValue(ctx, mod, string(v), ok, buf, bufLimit)
+}
+
+// writeState is the WebAssembly function export named handler.FuncWriteState
+// which durably sets the value of a persistent key read from memory.
+func (r *Runtime) writeState(ctx context.Context, mod wazeroapi.Module,
+	key, keyLen, value, valueLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncWriteState)
+	if r.stateStore == nil {
+		panic(errors.New("handler.FeatureStateStore isn't enabled"))
+	}
+	k := mustReadString(ctx, mod.Memory(), "key", key, keyLen)
+	v := mustRead(ctx, mod.Memory(), "value", value, valueLen)
+	if err := r.stateStore.Store(ctx, k, v); err != nil {
+		panic(fmt.Errorf("error writing state key %q: %w", k, err))
+	}
+}
+
+// defaultRateLimit is the tokens per second, and burst, of each key when
+// httpwasm.RateLimiter isn't set.
+const defaultRateLimit = 100
+
+// rateLimitCheck is the WebAssembly function export named
+// handler.FuncRateLimitCheck which takes tokens from the rate limit of a key
+// read from memory. The result is one if the request is allowed.
+func (r *Runtime) rateLimitCheck(ctx context.Context, mod wazeroapi.Module,
+	key, keyLen, tokens uint32) uint32 {
+	defer r.recoverHost(ctx, handler.FuncRateLimitCheck)
+	k := mustReadString(ctx, mod.Memory(), "key", key, keyLen)
+	allowed, err := r.rateLimiter.Take(ctx, k, tokens)
+	if err != nil {
+		panic(fmt.Errorf("error checking rate limit of key %q: %w", k, err))
+	} else if allowed {
+		return 1
+	}
+	return 0
+}
+
+func ttl(millis uint32) time.Duration {
+	return time.Duration(millis) * time.Millisecond
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/internal/wasm"
+)
+
+// wasmPageSize is the size of a page of WebAssembly memory.
+const wasmPageSize = 65536
+
+// snapshotGlobalPrefix prefixes the names under which mutable globals the
+// guest doesn't export are exported, so that a snapshot includes them.
+const snapshotGlobalPrefix = "http-wasm.snapshot"
+
+// snapshot is the state of a guest after it initialized, which new guests
+// are restored from instead of initializing again.
+type snapshot struct {
+	// memory is a copy of the guest memory, or nil if it has none.
+	memory []byte
+	// globals are values of mutable globals, by name, including those
+	// exported by exportSnapshotGlobals.
+	globals map[string]uint64
+}
+
+// exportSnapshotGlobals returns the guest with all its mutable globals
+// exported, as state in those it doesn't export, such as the stack pointer
+// kept by compilers, would otherwise be missing from the snapshot.
+func exportSnapshotGlobals(guest []byte) ([]byte, error) {
+	guest, err := wasm.ExportMutableGlobals(guest, snapshotGlobalPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("wasm: guest can't be snapshot: %w", err)
+	}
+	return guest, nil
+}
+
+// takeSnapshot instantiates and initializes a guest, then snapshots its
+// state for instantiate to restore. The guest is kept idle for NewGuest.
+func (r *Runtime) takeSnapshot(ctx context.Context, guest []byte) error {
+	names, err := wasm.ExportedGlobals(guest)
+	if err != nil {
+		return fmt.Errorf("wasm: error reading guest: %w", err)
+	}
+
+	g, err := r.instantiate(ctx)
+	if err != nil {
+		return err
+	}
+
+	s := &snapshot{globals: map[string]uint64{}}
+	if mem := g.guest.Memory(); mem != nil {
+		b, _ := mem.Read(ctx, 0, mem.Size(ctx))
+		s.memory = append([]byte{}, b...)
+	}
+	for _, name := range names {
+		if global, ok := g.guest.ExportedGlobal(name).(wazeroapi.MutableGlobal); ok {
+			s.globals[name] = global.Get(ctx)
+		}
+	}
+	r.snapshot = s
+	r.putIdle(g)
+	return nil
+}
+
+// restore overwrites the state of a guest instantiated without start
+// functions with the snapshot.
+func (s *snapshot) restore(ctx context.Context, guest wazeroapi.Module) error {
+	if s.memory != nil {
+		mem := guest.Memory()
+		size := uint32(len(s.memory))
+		if current := mem.Size(ctx); current < size {
+			if _, ok := mem.Grow(ctx, (size-current)/wasmPageSize); !ok {
+				return fmt.Errorf("wasm: error restoring snapshot: memory can't grow to %d bytes", size)
+			}
+		}
+		mem.Write(ctx, 0, s.memory)
+	}
+	for name, v := range s.globals {
+		guest.ExportedGlobal(name).(wazeroapi.MutableGlobal).Set(ctx, v)
+	}
+	return nil
+}
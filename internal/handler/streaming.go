@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// streamingKey is a context.Context Value associated with a bool pointer,
+// which is true when the guest called handler.FuncEnableStreamingResponse.
+// This is only present when the guest imports handler.FuncReadResponseBody,
+// as otherwise the response isn't buffered implicitly.
+type streamingKey struct{}
+
+// withStreaming returns a context which tracks whether the guest enabled a
+// streaming response, if needed.
+func (r *Runtime) withStreaming(ctx context.Context) context.Context {
+	if !r.readsResponseBody {
+		return ctx
+	}
+	return context.WithValue(ctx, streamingKey{}, new(bool))
+}
+
+// streaming returns true if the guest enabled a streaming response.
+func streaming(ctx context.Context) bool {
+	s, ok := ctx.Value(streamingKey{}).(*bool)
+	return ok && *s
+}
+
+// enableStreamingResponse is the WebAssembly function export named
+// handler.FuncEnableStreamingResponse, which prevents buffering the response
+// of the next handler.
+func (r *Runtime) enableStreamingResponse(ctx context.Context) {
+	defer r.recoverHost(ctx, handler.FuncEnableStreamingResponse)
+	if s, ok := ctx.Value(streamingKey{}).(*bool); ok {
+		*s = true
+	}
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// minTickPeriod is the shortest period of handler.FuncScheduleTick, so that
+// a guest can't keep the host busy.
+const minTickPeriod = 100 * time.Millisecond
+
+// ticker calls handler.FuncOnTick on a guest instantiated for it, when
+// scheduled by handler.FuncScheduleTick.
+type ticker struct {
+	// r instantiates the guest which ticks.
+	r *Runtime
+
+	// mu guards the fields below. stop is closed to stop the goroutine
+	// ticking at period, if any.
+	mu     sync.Mutex
+	period time.Duration
+	stop   chan struct{}
+	closed bool
+	// wg waits for goroutines ticking to return.
+	wg sync.WaitGroup
+
+	// guestMu guards guest, which is nil until the first tick, or after it
+	// trapped.
+	guestMu sync.Mutex
+	guest   *Guest
+}
+
+// scheduleTick is the WebAssembly function export named
+// handler.FuncScheduleTick, which schedules handler.FuncOnTick at the period.
+func (r *Runtime) scheduleTick(ctx context.Context, periodMs uint32) {
+	defer r.recoverHost(ctx, handler.FuncScheduleTick)
+	if _, ok := r.guestModule.ExportedFunctions()[handler.FuncOnTick]; !ok {
+		panic(errors.New(handler.FuncOnTick + " isn't exported"))
+	}
+	period := time.Duration(periodMs) * time.Millisecond
+	if period != 0 && period < minTickPeriod {
+		panic(errors.New("period_ms must be at least 100"))
+	}
+	r.ticks.schedule(period)
+}
+
+// schedule replaces the goroutine ticking, unless the period is the same.
+func (t *ticker) schedule(period time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed || period == t.period {
+		return
+	}
+	if t.stop != nil {
+		close(t.stop)
+		t.stop = nil
+	}
+	t.period = period
+	if period == 0 {
+		return
+	}
+	t.stop = make(chan struct{})
+	t.wg.Add(1)
+	go t.run(period, t.stop)
+}
+
+func (t *ticker) run(period time.Duration, stop chan struct{}) {
+	defer t.wg.Done()
+	tk := time.NewTicker(period)
+	defer tk.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tk.C:
+			t.tick()
+		}
+	}
+}
+
+// tick calls handler.FuncOnTick, instantiating the guest if needed. A trap is
+// logged, and the guest closed, as its state is unknown.
+func (t *ticker) tick() {
+	t.guestMu.Lock()
+	defer t.guestMu.Unlock()
+	ctx := context.Background()
+	if t.guest == nil {
+		g, err := t.r.instantiate(ctx)
+		if err != nil {
+			t.r.logFn(ctx, err.Error())
+			return
+		}
+		t.guest = g
+	}
+	err := t.guest.updateConfig(ctx)
+	if err == nil {
+		err = call(ctx, handler.FuncOnTick, t.guest.onTick)
+	}
+	if err != nil {
+		t.r.logFn(ctx, err.Error())
+		_ = t.guest.Close(ctx)
+		t.guest = nil
+	}
+}
+
+// close stops ticking, waiting for any tick in progress, and closes the guest.
+func (t *ticker) close(ctx context.Context) {
+	t.mu.Lock()
+	t.closed = true
+	if t.stop != nil {
+		close(t.stop)
+		t.stop = nil
+	}
+	t.mu.Unlock()
+	t.wg.Wait()
+
+	t.guestMu.Lock()
+	defer t.guestMu.Unlock()
+	if t.guest != nil {
+		_ = t.guest.Close(ctx)
+		t.guest = nil
+	}
+}
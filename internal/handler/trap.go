@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime"
+	"runtime/debug"
+
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/api"
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// FailOpen logs the error, which prevented a guest from handling a request,
+// and returns true if the next handler should be invoked instead of rejecting
+// the request.
+func (r *Runtime) FailOpen(ctx context.Context, err error) bool {
+	r.logFn(ctx, err.Error())
+	return r.failurePolicy == api.FailOpen
+}
+
+// handleStateKey is a context.Context Value associated with a handleState
+// pointer, which tracks the guest invoking the next handler. This is only
+// present when needed, such as when failing open or limiting concurrency, to
+// avoid allocating.
+type handleStateKey struct{}
+
+type handleState struct {
+	// nextCalled is true when the guest invoked the next handler, so that
+	// failing open doesn't invoke it twice.
+	nextCalled bool
+	// nextNanos is the time spent in the next handler, so that it can be
+	// excluded from the latency of the guest.
+	nextNanos int64
+}
+
+// next is the WebAssembly function export named handler.FuncNext, which
+// invokes the next handler.
+func (r *Runtime) next(ctx context.Context) {
+	defer r.recoverHost(ctx, handler.FuncNext)
+	if r.readsResponseBody && !streaming(ctx) {
+		// Buffer the response, so that the guest can read it, without
+		// buffering the responses of guests that can't.
+		r.host.EnableFeatures(ctx, r.withDecode(handler.FeatureBufferResponse))
+	}
+	s, ok := ctx.Value(handleStateKey{}).(*handleState)
+	if !ok {
+		callNext(ctx, r.host)
+		return
+	}
+	s.nextCalled = true
+	// The guest isn't executing while the next handler runs.
+	r.concurrency.release()
+	defer r.concurrency.resume()
+	start := r.clock.Nanotime()
+	callNext(ctx, r.host)
+	s.nextNanos += r.clock.Nanotime() - start
+}
+
+// nextPanic is the value the next handler panicked with. It traps the guest,
+// then callResult panics with the value again, outside the guest, so that the
+// server handles it, such as http.ErrAbortHandler, instead of this handling
+// it as a handler.GuestError.
+type nextPanic struct {
+	value interface{}
+}
+
+// Error implements error.
+func (p *nextPanic) Error() string {
+	return fmt.Sprintf("next handler panicked: %v", p.value)
+}
+
+// callNext invokes the next handler, converting a panic to a nextPanic.
+func callNext(ctx context.Context, host handler.Host) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			panic(&nextPanic{value: recovered})
+		}
+	}()
+	host.Next(ctx)
+}
+
+// call calls the function exported by the guest with the given name, if not
+// nil, converting a trap or panic into a handler.GuestError. A panic of the
+// next handler, which the guest invoked, isn't converted.
+func call(ctx context.Context, name string, fn wazeroapi.Function) error {
+	_, err := callResult(ctx, name, fn)
+	return err
+}
+
+// callResult is like call, except it returns the first result of the
+// function, if any.
+func callResult(ctx context.Context, name string, fn wazeroapi.Function) (result uint64, err error) {
+	if fn == nil {
+		return 0, nil // optional export
+	}
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			if p, ok := recovered.(*nextPanic); ok {
+				panic(p.value)
+			}
+			err = &handler.GuestError{Func: name, Err: fmt.Errorf("%v", recovered)}
+		}
+	}()
+
+	results, err := fn.Call(ctx)
+	var p *nextPanic
+	if errors.As(err, &p) {
+		panic(p) // not the fault of the guest
+	} else if err != nil {
+		return 0, &handler.GuestError{Func: name, Err: err}
+	}
+	if len(results) > 0 {
+		result = results[0]
+	}
+	return
+}
+
+// handleGuestError logs the error, and invokes the next handler or responds,
+// according to configuration.
+func (r *Runtime) handleGuestError(ctx context.Context, err *handler.GuestError, nextCalled bool) {
+	r.logFn(ctx, err.Error())
+
+	if r.host.IsResponseCommitted(ctx) {
+		return // too late to change the response.
+	} else if errors.Is(err.Err, handler.ErrBodyTooLarge) {
+		return // the host rejected the request.
+	}
+	if r.failurePolicy == api.FailOpen {
+		if !nextCalled {
+			r.host.Next(ctx)
+		}
+		return
+	}
+	r.host.SendResponse(ctx, r.guestErrorStatus, nil)
+}
+
+// recoverHost is deferred by each host function, to recover unexpected
+// panics, such as a nil pointer dereference in the handler.Host. The panic is
+// logged with its stack trace, and the host function returns zero results,
+// which guests interpret as a failure, such as a missing value. This prevents
+// a bug in the host from trapping guests, or crashing the process.
+//
+// Host functions intentionally trap the guest by panicking with an error,
+// such as when it passed memory out of range. These are not recovered, nor
+// are panics of the next handler, which callResult panics with again.
+// Likewise, this traps the guest when it should be interrupted, as the client
+// disconnected.
+//
+// Note: This isn't implemented by wrapping functions with reflection, as that
+// allocates on every call.
+func (r *Runtime) recoverHost(ctx context.Context, name string) {
+	recovered := recover()
+	if recovered == nil {
+		r.interruptIfClientGone(ctx, name)
+		return
+	} else if !unexpected(recovered) {
+		panic(recovered) // trap the guest
+	}
+	r.logFn(ctx, fmt.Sprintf("wasm: recovered panic in %s: %v\n%s", name, recovered, debug.Stack()))
+}
+
+// unexpected returns true if the panic is a bug, as opposed to an error
+// intended to trap the guest.
+func unexpected(recovered interface{}) bool {
+	if _, ok := recovered.(runtime.Error); ok {
+		return true // Ex. nil pointer dereference
+	}
+	_, ok := recovered.(error)
+	return !ok
+}
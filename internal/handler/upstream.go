@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// setUpstream is the WebAssembly function export named
+// handler.FuncSetUpstream which sets where the host sends the request, read
+// from memory.
+func (r *Runtime) setUpstream(ctx context.Context, mod wazeroapi.Module,
+	upstream, upstreamLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncSetUpstream)
+	u := mustReadString(ctx, mod.Memory(), "upstream", upstream, upstreamLen)
+	if !validUpstream(u) {
+		panic(fmt.Errorf("invalid upstream %q", u))
+	}
+	r.host.SetUpstream(ctx, u)
+}
+
+// setTimeoutMs is the WebAssembly function export named
+// handler.FuncSetTimeoutMs which limits how long the host waits for the next
+// handler.
+func (r *Runtime) setTimeoutMs(ctx context.Context, timeoutMs uint32) {
+	defer r.recoverHost(ctx, handler.FuncSetTimeoutMs)
+	r.host.SetTimeout(ctx, time.Duration(timeoutMs)*time.Millisecond)
+}
+
+// setRetryPolicy is the WebAssembly function export named
+// handler.FuncSetRetryPolicy which sets how a proxy host retries the request.
+func (r *Runtime) setRetryPolicy(ctx context.Context, attempts, backoffMs uint32) {
+	defer r.recoverHost(ctx, handler.FuncSetRetryPolicy)
+	r.host.SetRetryPolicy(ctx, handler.RetryPolicy{
+		Attempts: attempts,
+		Backoff:  time.Duration(backoffMs) * time.Millisecond,
+	})
+}
+
+// validUpstream returns true if the upstream is a host and optional port,
+// without a scheme, credentials or path.
+func validUpstream(upstream string) bool {
+	if upstream == "" {
+		return false
+	}
+	u, err := url.Parse("//" + upstream)
+	return err == nil && u.Host == upstream && u.Hostname() != ""
+}
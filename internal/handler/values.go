@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+
+	wazeroapi "github.com/tetratelabs/wazero/api"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	"github.com/http-wasm/http-wasm-host-go/internal"
+)
+
+// withHostValues returns a context with values the guest sets via
+// handler.FuncSetHostValue, unless the host already provides them, such as
+// for the next handler.
+func (r *Runtime) withHostValues(ctx context.Context) context.Context {
+	if !r.setsHostValues {
+		return ctx
+	}
+	if _, ok := ctx.Value(internal.HostValuesKey{}).(*internal.HostValues); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, internal.HostValuesKey{}, new(internal.HostValues))
+}
+
+// getHostValue is the WebAssembly function export named
+// handler.FuncGetHostValue which writes a value set by a guest, or else
+// passed by the host, to memory if it exists and isn't larger than the buffer
+// size limit. The result is `1<<32|value_len` or zero if it doesn't exist.
+func (r *Runtime) getHostValue(ctx context.Context, mod wazeroapi.Module,
+	key, keyLen, buf, bufLimit uint32) (result uint64) {
+	defer r.recoverHost(ctx, handler.FuncGetHostValue)
+	k := mustReadString(ctx, mod.Memory(), "key", key, keyLen)
+	if values, ok := ctx.Value(internal.HostValuesKey{}).(*internal.HostValues); ok {
+		if v, ok := (*values)[k]; ok {
+			return r.writeValue(ctx, mod, string(v), true, buf, bufLimit)
+		}
+	}
+	v, ok := ctx.Value(internal.GuestValueKey{Name: k}).([]byte)
+	return r.writeValue(ctx, mod, string(v), ok, buf, bufLimit)
+}
+
+// setHostValue is the WebAssembly function export named
+// handler.FuncSetHostValue which sets a value read from memory, for Go
+// handlers after the guest.
+func (r *Runtime) setHostValue(ctx context.Context, mod wazeroapi.Module,
+	key, keyLen, value, valueLen uint32) {
+	defer r.recoverHost(ctx, handler.FuncSetHostValue)
+	k := mustReadString(ctx, mod.Memory(), "key", key, keyLen)
+	v := mustRead(ctx, mod.Memory(), "value", value, valueLen)
+	values := ctx.Value(internal.HostValuesKey{}).(*internal.HostValues)
+	if *values == nil {
+		*values = internal.HostValues{}
+	}
+	// Copy the value as it is guest memory.
+	(*values)[k] = append([]byte{}, v...)
+}
@@ -0,0 +1,76 @@
+// Package idle pools the handlers of a guest, so that concurrent requests
+// each use their own, as a handler isn't safe for concurrent use.
+package idle
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/http-wasm/http-wasm-host-go/api"
+)
+
+// Handler is a handler of a guest, such as a wasm.Handler.
+type Handler interface {
+	http.Handler
+	api.Closer
+}
+
+// Pool reuses idle handlers, creating more when concurrent requests need
+// them. New must be set before use.
+type Pool struct {
+	// New creates a handler when none is idle.
+	New func(ctx context.Context) (Handler, error)
+
+	mu   sync.Mutex
+	idle []Handler
+}
+
+// Get returns an idle handler, or a new one if none is, which must be
+// returned with Put once it served a request.
+func (p *Pool) Get(ctx context.Context) (Handler, error) {
+	p.mu.Lock()
+	var h Handler
+	if n := len(p.idle); n > 0 {
+		h, p.idle = p.idle[n-1], p.idle[:n-1]
+	}
+	p.mu.Unlock()
+
+	if h != nil {
+		return h, nil
+	}
+	return p.New(ctx)
+}
+
+// Put returns a handler from Get, so that a later request can use it.
+func (p *Pool) Put(h Handler) {
+	p.mu.Lock()
+	p.idle = append(p.idle, h)
+	p.mu.Unlock()
+}
+
+// ServeHTTP implements http.Handler, serving the request with a handler from
+// Get, or responding with 503 Service Unavailable if it can't be created.
+func (p *Pool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h, err := p.Get(r.Context())
+	if err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	h.ServeHTTP(w, r)
+	p.Put(h)
+}
+
+// Close closes the idle handlers. Handlers in use aren't closed, so the pool
+// must not be used after this.
+func (p *Pool) Close(ctx context.Context) (err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, h := range p.idle {
+		if e := h.Close(ctx); e != nil {
+			err = e
+		}
+	}
+	p.idle = nil
+	return
+}
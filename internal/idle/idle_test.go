@@ -0,0 +1,63 @@
+package idle
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type testHandler struct {
+	closed bool
+}
+
+func (h *testHandler) ServeHTTP(http.ResponseWriter, *http.Request) {}
+
+func (h *testHandler) Close(context.Context) error {
+	h.closed = true
+	return nil
+}
+
+func TestPool(t *testing.T) {
+	var created []*testHandler
+	p := &Pool{New: func(context.Context) (Handler, error) {
+		h := &testHandler{}
+		created = append(created, h)
+		return h, nil
+	}}
+
+	h1, _ := p.Get(context.Background())
+	h2, _ := p.Get(context.Background())
+	if h1 == h2 || len(created) != 2 {
+		t.Fatal("expected concurrent gets to create handlers")
+	}
+
+	p.Put(h1)
+	if h, _ := p.Get(context.Background()); h != h1 {
+		t.Fatal("expected the idle handler to be reused")
+	}
+	p.Put(h1)
+
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !created[0].closed {
+		t.Fatal("expected the idle handler to be closed")
+	}
+	if created[1].closed {
+		t.Fatal("expected the handler in use not to be closed")
+	}
+}
+
+func TestPool_ServeHTTP_NewError(t *testing.T) {
+	p := &Pool{New: func(context.Context) (Handler, error) {
+		return nil, errors.New("error")
+	}}
+
+	w := httptest.NewRecorder()
+	p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if want, have := http.StatusServiceUnavailable, w.Code; want != have {
+		t.Fatalf("unexpected status: want %d, have %d", want, have)
+	}
+}
@@ -0,0 +1,60 @@
+// Package inflight counts requests in flight, so that closing middleware can
+// wait for them to finish.
+package inflight
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Counter counts requests in flight. The zero value is ready to use.
+type Counter struct {
+	mu    sync.Mutex
+	count int
+	// idle is closed when count drops to zero, if anyone is waiting.
+	idle chan struct{}
+}
+
+// Add counts a request, which must be followed by Done when it finishes.
+func (c *Counter) Add() {
+	c.mu.Lock()
+	c.count++
+	c.mu.Unlock()
+}
+
+// Done is called when a request counted with Add finishes.
+func (c *Counter) Done() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.count--; c.count == 0 && c.idle != nil {
+		close(c.idle)
+		c.idle = nil
+	}
+}
+
+// Wait waits up to timeout, or until the context is done, for requests in
+// flight to finish. This returns false if any remain.
+func (c *Counter) Wait(ctx context.Context, timeout time.Duration) bool {
+	c.mu.Lock()
+	if c.count == 0 {
+		c.mu.Unlock()
+		return true
+	}
+	if c.idle == nil {
+		c.idle = make(chan struct{})
+	}
+	idle := c.idle
+	c.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-idle:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,35 @@
+package inflight
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestCounter_Wait(t *testing.T) {
+	var c Counter
+	if !c.Wait(context.Background(), 0) {
+		t.Fatal("expected no requests in flight")
+	}
+
+	c.Add()
+	c.Add()
+	if c.Wait(context.Background(), time.Millisecond) {
+		t.Fatal("expected requests in flight")
+	}
+
+	go func() {
+		c.Done()
+		c.Done()
+	}()
+	if !c.Wait(context.Background(), time.Minute) {
+		t.Fatal("expected requests to finish")
+	}
+
+	c.Add()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if c.Wait(ctx, time.Minute) {
+		t.Fatal("expected done context to stop waiting")
+	}
+}
@@ -0,0 +1,136 @@
+// Package jwa implements the signature and MAC algorithms of JSON Web
+// Algorithms (RFC 7518), as used by JSON Web Tokens.
+package jwa
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"fmt"
+	"math/big"
+
+	// Register the hash functions used below.
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// CheckKey returns an error unless the key is a secret for HMAC, as []byte,
+// or a public key of a supported type.
+func CheckKey(key interface{}) error {
+	switch key := key.(type) {
+	case []byte:
+		if len(key) == 0 {
+			return fmt.Errorf("empty secret")
+		}
+	case *rsa.PublicKey:
+	case ed25519.PublicKey:
+		if len(key) != ed25519.PublicKeySize {
+			return fmt.Errorf("invalid ed25519 key length %d", len(key))
+		}
+	case *ecdsa.PublicKey:
+		if _, ok := curveHashes[key.Curve]; !ok {
+			return fmt.Errorf("unsupported curve %s", key.Curve.Params().Name)
+		}
+	default:
+		return fmt.Errorf("unsupported key type %T", key)
+	}
+	return nil
+}
+
+// curveHashes are the hash functions of ECDSA algorithms by curve.
+// Ex. ES256 is P-256 with SHA-256.
+var curveHashes = map[elliptic.Curve]crypto.Hash{
+	elliptic.P256(): crypto.SHA256,
+	elliptic.P384(): crypto.SHA384,
+	elliptic.P521(): crypto.SHA512,
+}
+
+// hashes are the hash functions of algorithms by their suffix.
+var hashes = map[string]crypto.Hash{
+	"256": crypto.SHA256,
+	"384": crypto.SHA384,
+	"512": crypto.SHA512,
+}
+
+// parse returns the family and hash of the algorithm, or false if it isn't
+// supported. Ex. "RS256" is ("RS", crypto.SHA256)
+func parse(alg string) (family string, hash crypto.Hash, ok bool) {
+	if alg == "EdDSA" {
+		return alg, 0, true
+	}
+	if len(alg) != 5 {
+		return
+	}
+	family = alg[:2]
+	switch family {
+	case "HS", "RS", "PS", "ES":
+		hash, ok = hashes[alg[2:]]
+	}
+	return
+}
+
+// HMAC returns the MAC of the data using the algorithm and secret, or false
+// if the algorithm isn't one of HS256, HS384 or HS512.
+func HMAC(alg string, secret, data []byte) ([]byte, bool) {
+	family, hash, ok := parse(alg)
+	if !ok || family != "HS" {
+		return nil, false
+	}
+	mac := hmac.New(hash.New, secret)
+	mac.Write(data) // nolint
+	return mac.Sum(nil), true
+}
+
+// Verify returns true if sig is a valid signature of the data using the
+// algorithm and key. This returns false if the algorithm isn't supported or
+// doesn't match the type of key.
+//
+// Signatures are in the format of JSON Web Signature, so an ECDSA signature
+// is the fixed-size concatenation of R and S, not ASN.1.
+func Verify(alg string, key interface{}, data, sig []byte) bool {
+	family, hash, ok := parse(alg)
+	if !ok {
+		return false
+	}
+
+	var digest []byte
+	if hash != 0 {
+		h := hash.New()
+		h.Write(data) // nolint
+		digest = h.Sum(nil)
+	}
+
+	switch key := key.(type) {
+	case []byte:
+		if family != "HS" {
+			return false
+		}
+		mac, _ := HMAC(alg, key, data)
+		return hmac.Equal(mac, sig)
+	case *rsa.PublicKey:
+		switch family {
+		case "RS":
+			return rsa.VerifyPKCS1v15(key, hash, digest, sig) == nil
+		case "PS":
+			opts := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash}
+			return rsa.VerifyPSS(key, hash, digest, sig, opts) == nil
+		}
+	case *ecdsa.PublicKey:
+		if family != "ES" || curveHashes[key.Curve] != hash {
+			return false
+		}
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		return ecdsa.Verify(key, digest, r, s)
+	case ed25519.PublicKey:
+		return family == "EdDSA" && ed25519.Verify(key, data, sig)
+	}
+	return false
+}
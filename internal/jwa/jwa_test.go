@@ -0,0 +1,143 @@
+package jwa
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"testing"
+)
+
+func TestHMAC(t *testing.T) {
+	// RFC 4231 test case 2
+	secret, data := []byte("Jefe"), []byte("what do ya want for nothing?")
+
+	tests := []struct {
+		alg, expected string
+		expectedOk    bool
+	}{
+		{alg: "HS256", expected: "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843", expectedOk: true},
+		{alg: "HS384", expected: "af45d2e376484031617f78d2b58a6b1b9c7ef464f5a01b47e42ec3736322445e8e2240ca5e69e2c78b3239ecfab21649", expectedOk: true},
+		{alg: "RS256"},
+		{alg: "HS1"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.alg, func(t *testing.T) {
+			mac, ok := HMAC(tc.alg, secret, data)
+			if ok != tc.expectedOk {
+				t.Fatalf("expected ok %v, have %v", tc.expectedOk, ok)
+			}
+			if have := hex.EncodeToString(mac); have != tc.expected {
+				t.Fatalf("expected %s, have %s", tc.expected, have)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	data := []byte("header.payload")
+	secret := []byte("secret")
+	hs256, _ := HMAC("HS256", secret, data)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rs256, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest(crypto.SHA256, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps384, err := rsa.SignPSS(rand.Reader, rsaKey, crypto.SHA384, digest(crypto.SHA384, data),
+		&rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, s, err := ecdsa.Sign(rand.Reader, ecKey, digest(crypto.SHA256, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	es256 := make([]byte, 64)
+	r.FillBytes(es256[:32])
+	s.FillBytes(es256[32:])
+
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eddsa := ed25519.Sign(edKey, data)
+
+	tests := []struct {
+		name     string
+		alg      string
+		key      interface{}
+		sig      []byte
+		expected bool
+	}{
+		{name: "HS256", alg: "HS256", key: secret, sig: hs256, expected: true},
+		{name: "HS256 wrong secret", alg: "HS256", key: []byte("other"), sig: hs256},
+		{name: "HS256 with public key", alg: "HS256", key: &rsaKey.PublicKey, sig: hs256},
+		{name: "RS256", alg: "RS256", key: &rsaKey.PublicKey, sig: rs256, expected: true},
+		{name: "RS256 wrong hash", alg: "RS384", key: &rsaKey.PublicKey, sig: rs256},
+		{name: "RS256 with secret", alg: "RS256", key: secret, sig: rs256},
+		{name: "PS384", alg: "PS384", key: &rsaKey.PublicKey, sig: ps384, expected: true},
+		{name: "ES256", alg: "ES256", key: &ecKey.PublicKey, sig: es256, expected: true},
+		{name: "ES256 wrong curve", alg: "ES384", key: &ecKey.PublicKey, sig: es256},
+		{name: "ES256 truncated", alg: "ES256", key: &ecKey.PublicKey, sig: es256[:63]},
+		{name: "EdDSA", alg: "EdDSA", key: edPub, sig: eddsa, expected: true},
+		{name: "EdDSA tampered", alg: "EdDSA", key: edPub, sig: append([]byte{eddsa[0] ^ 1}, eddsa[1:]...)},
+		{name: "none", alg: "none", key: secret},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			if have := Verify(tc.alg, tc.key, data, tc.sig); have != tc.expected {
+				t.Fatalf("expected %v, have %v", tc.expected, have)
+			}
+		})
+	}
+}
+
+func TestCheckKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		key         interface{}
+		expectedErr string
+	}{
+		{name: "secret", key: []byte("secret")},
+		{name: "empty secret", key: []byte{}, expectedErr: "empty secret"},
+		{name: "ed25519", key: make(ed25519.PublicKey, ed25519.PublicKeySize)},
+		{name: "short ed25519", key: ed25519.PublicKey{1}, expectedErr: "invalid ed25519 key length 1"},
+		{name: "string", key: "secret", expectedErr: "unsupported key type string"},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			err := CheckKey(tc.key)
+			if tc.expectedErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+			} else if err == nil || err.Error() != tc.expectedErr {
+				t.Fatalf("expected error %q, have %v", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func digest(hash crypto.Hash, data []byte) []byte {
+	h := hash.New()
+	h.Write(data) // nolint
+	return h.Sum(nil)
+}
@@ -2,23 +2,251 @@ package internal
 
 import (
 	"context"
+	"io"
+	"net"
+	"sync"
+	"time"
 
 	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/experimental"
 	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
 
 	"github.com/http-wasm/http-wasm-host-go/api"
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
 )
 
 type WazeroOptions struct {
-	NewRuntime   func(context.Context) (wazero.Runtime, error)
+	NewRuntime func(context.Context) (wazero.Runtime, error)
+	// RuntimeMode is the api.CompileReport Mode of NewRuntime, or empty if
+	// unknown.
+	RuntimeMode string
+	// CompilationCacheDir is where compiled guests are cached, if not empty.
+	CompilationCacheDir string
+	// SharedRuntime is used instead of NewRuntime when not nil, and isn't
+	// closed with the guest, so that guests can share it.
+	SharedRuntime wazero.Runtime
+
 	ModuleConfig wazero.ModuleConfig
-	Logger       api.LogFunc
+	GuestConfig  []byte
+	// GuestConfigCanary replaces GuestConfig on GuestConfigCanaryPercent of
+	// requests.
+	GuestConfigCanary        []byte
+	GuestConfigCanaryPercent int
+	Logger                   api.LogFunc
+	AuditLogger              api.AuditFunc
+	AccessLogger             api.AccessLogger
+	OnEvent                  api.EventFunc
+	LogLimits                LogLimits
+	OnReload                 api.ReloadFunc
+	GuestVerifier            func(guest []byte) error
+	// MirrorDestinations are URLs by name for handler.FuncMirrorRequest
+	MirrorDestinations map[string]string
+	// Extractions are expressions by name for handler.FuncExtract
+	Extractions map[string]string
+	// ProblemTypeBase prefixes codes sent via handler.FuncSendProblem
+	ProblemTypeBase string
+	// MessageCatalogs are for handler.FuncSendLocalizedResponse. The first is
+	// the default.
+	MessageCatalogs []MessageCatalog
+	// InjectedHeaders are set on all responses, unless suppressed via
+	// handler.FuncSuppressInjectedHeaders.
+	InjectedHeaders []InjectedHeader
+	SharedStore     api.SharedStore
+	StateStore      api.StateStore
+	ResponseCache   api.ResponseCache
+	RateLimiter     api.RateLimiter
+	// PersistedStores is non-nil when httpwasm.PersistStores is set.
+	PersistedStores *PersistedStores
+	Metrics         api.Metrics
+	Clock           api.Clock
+	Random          io.Reader
+	// Snapshot instantiates guests from a snapshot of the first after it
+	// initialized.
+	Snapshot bool
+	// ActiveWindows and BypassWindows are schedules in the format of
+	// schedule.Parse.
+	ActiveWindows, BypassWindows []string
+	// BypassPreflight invokes the next handler instead of the guest for CORS
+	// preflight requests.
+	BypassPreflight bool
+	// BodyLimits limit buffering request and response bodies.
+	BodyLimits BodyLimits
+	// InterruptOnClientGone traps guests on their next host call after the
+	// client disconnected.
+	InterruptOnClientGone bool
+	// ErrorMappings are responses by code for handler.FuncSetError
+	ErrorMappings map[string]ErrorMapping
+	HTTPCall      HTTPCall
+	Resolver      *net.Resolver
+	// GuestErrorStatus is the status code sent when the guest traps.
+	GuestErrorStatus int
+	FailurePolicy    api.FailurePolicy
+	Quotas           Quotas
+	LatencyBudget    LatencyBudget
+	// Prewarm is the count of guests to instantiate with the runtime.
+	Prewarm int
+	// AllowedCapabilities are the capabilities of host functions the guest
+	// may import, or nil for all.
+	AllowedCapabilities []handler.Capability
+	// WrapHost, if not nil, wraps the host guests call.
+	WrapHost func(handler.Host) handler.Host
+	// Concurrency limits guests handling requests at the same time.
+	Concurrency Concurrency
+	// Pool autoscales idle guests.
+	Pool Pool
+	// Shards spreads guests across runtimes.
+	Shards Shards
+	// Profiler listens to calls of guest and host functions, if not nil.
+	Profiler experimental.FunctionListenerFactory
+	// Stdout and Stderr receive output of the guest, or the Logger if nil.
+	Stdout, Stderr io.Writer
+	// Env and Args are the environment variables and arguments of the guest.
+	Env  map[string]string
+	Args []string
+	// DecodeResponseBody enables handler.FeatureDecodeResponse whenever
+	// handler.FeatureBufferResponse is.
+	DecodeResponseBody bool
+	// DefaultBufferLimit is the result of handler.FuncGetBufferLimit.
+	DefaultBufferLimit uint32
+	// GrowBuffers supports handler.FeatureGrowBuffers.
+	GrowBuffers bool
+	// RequestID assigns each request an ID, for handler.FuncGetRequestID.
+	RequestID bool
+	// Canary sets handler.GuestInfo Canary.
+	Canary bool
+	// Shadow, if not nil, records mutations of the guest instead of
+	// applying them.
+	Shadow api.ShadowFunc
+	// Keys are for handler.FuncHMAC and handler.FuncVerifySignature, by key
+	// ID. Values are []byte secrets or public keys.
+	Keys map[string]interface{}
+}
+
+// LatencyBudget limits the latency a guest adds to requests.
+type LatencyBudget struct {
+	// Budget is the maximum 99th percentile latency, if positive.
+	Budget time.Duration
+	// Func is notified when the budget is exceeded, if not nil.
+	Func api.LatencyBudgetFunc
+	// Bypass is how long to bypass the guest when the budget is exceeded,
+	// if positive.
+	Bypass time.Duration
+}
+
+// BodyLimits limit buffering request and response bodies for guests.
+type BodyLimits struct {
+	// Memory is the largest body buffered in memory, if positive.
+	Memory int64
+	// SpillDir, if not empty, is where request bodies larger than Memory are
+	// buffered in temporary files, up to Max bytes.
+	SpillDir string
+	Max      int64
+}
+
+// Concurrency limits guests handling requests at the same time.
+type Concurrency struct {
+	// Max is the maximum guests handling requests, if positive.
+	Max int
+	// QueueTimeout is how long a request waits for a guest to finish, if
+	// positive. Otherwise, excess requests are rejected immediately.
+	QueueTimeout time.Duration
+}
+
+// Pool autoscales guests instantiated ahead of NewGuest.
+type Pool struct {
+	// Min and Max bound the idle guests. The pool is disabled unless Max is
+	// positive.
+	Min, Max int
+	// IdleTimeout is how long without waiting for a guest before the pool
+	// shrinks.
+	IdleTimeout time.Duration
+}
+
+// Shards spreads guests across independent runtimes.
+type Shards struct {
+	// Count is the count of runtimes. Sharding is disabled unless this is
+	// more than one.
+	Count    int
+	Strategy api.ShardStrategy
+}
+
+// LogLimits limit messages the guest logs. Zero is unlimited.
+type LogLimits struct {
+	// MessageBytes truncates longer messages.
+	MessageBytes int
+	// PerRequest drops messages after this count per request.
+	PerRequest int
+	// Rate is the messages per second allowed across requests, with bursts
+	// of up to Burst messages, if positive.
+	Rate  float64
+	Burst int
+	// Dropped is notified of dropped messages, if not nil.
+	Dropped api.LogDroppedFunc
+}
+
+// Quotas limit what a guest produces per request. Zero is unlimited.
+type Quotas struct {
+	// HeaderMutations limits calls that set response headers, cookies or
+	// trailers.
+	HeaderMutations int
+	// HeaderBytes limits the total length of names and values set by those
+	// calls.
+	HeaderBytes int
+	// ResponseBodyBytes limits the total length of response bodies sent.
+	ResponseBodyBytes int
+	// HeaderCount limits the distinct names of response headers and
+	// trailers set.
+	HeaderCount int
+	// HeaderNameBytes and HeaderValueBytes limit the length of each name
+	// and value of a response header or trailer.
+	HeaderNameBytes, HeaderValueBytes int
+}
+
+// HTTPCall restricts handler.FuncHTTPCall.
+type HTTPCall struct {
+	// Hosts are allowed to be called. None are allowed by default.
+	Hosts []string
+	// Timeout is the maximum time to wait for a response, if positive.
+	Timeout time.Duration
+	// MaxConcurrency limits calls in flight, if positive.
+	MaxConcurrency int
+}
+
+// ErrorMapping is the response to an error code, whose body is a
+// text/template.
+type ErrorMapping struct {
+	StatusCode int
+	Body       string
+}
+
+// InjectedHeader is a response header with a value computed per request.
+type InjectedHeader struct {
+	Name  string
+	Value func(ctx context.Context) string
+}
+
+// MessageCatalog is messages by key in a language.
+type MessageCatalog struct {
+	Language string
+	Messages map[string]string
 }
 
 // DefaultRuntime implements NewRuntime by returning a wazero runtime with WASI
 // host functions instantiated.
 func DefaultRuntime(ctx context.Context) (wazero.Runtime, error) {
-	r := wazero.NewRuntime(ctx)
+	return newRuntime(ctx, wazero.NewRuntimeConfig())
+}
+
+// RuntimeWithConfig returns a NewRuntime which is the same as DefaultRuntime,
+// except it uses the config.
+func RuntimeWithConfig(config wazero.RuntimeConfig) func(context.Context) (wazero.Runtime, error) {
+	return func(ctx context.Context) (wazero.Runtime, error) {
+		return newRuntime(ctx, config)
+	}
+}
+
+func newRuntime(ctx context.Context, config wazero.RuntimeConfig) (wazero.Runtime, error) {
+	r := wazero.NewRuntimeWithConfig(ctx, config)
 
 	if _, err := wasi_snapshot_preview1.Instantiate(ctx, r); err != nil {
 		_ = r.Close(ctx)
@@ -26,3 +254,16 @@ func DefaultRuntime(ctx context.Context) (wazero.Runtime, error) {
 	}
 	return r, nil
 }
+
+// PersistedStores are the SharedStore and RateLimiter of runtimes created
+// with httpwasm.PersistStores. They are restored from Dir by the first, and
+// shared by those created later with the same options, such as on reload, so
+// that a reload doesn't restore a stale snapshot.
+type PersistedStores struct {
+	Dir  string
+	Once sync.Once
+	// SharedStore, RateLimiter and Err are set by Once.
+	SharedStore api.SharedStore
+	RateLimiter api.RateLimiter
+	Err         error
+}
@@ -0,0 +1,124 @@
+// Package schedule parses recurring time windows, such as business hours,
+// during which a guest is active or bypassed.
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday,
+	"wed": time.Wednesday, "thu": time.Thursday, "fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Window is a compiled window, in the format "[<days>] <start>-<end> [<zone>]".
+// Ex. "Mon-Fri 09:00-17:00 America/New_York" or "22:00-06:00"
+//
+// Days are a comma-separated list of three-letter weekdays or ranges of them,
+// defaulting to every day. Times are "HH:MM" in the zone, which defaults to
+// UTC. When the end is before the start, the window spans midnight, and the
+// days apply to the start.
+type Window struct {
+	days       [7]bool
+	start, end time.Duration
+	location   *time.Location
+}
+
+// Parse parses the window, returning an error if it is invalid.
+func Parse(spec string) (*Window, error) {
+	fields := strings.Fields(spec)
+	w := &Window{location: time.UTC}
+
+	var times string
+	switch len(fields) {
+	case 1:
+		times = fields[0]
+	case 2, 3:
+		if strings.Contains(fields[0], ":") {
+			times, fields = fields[0], append([]string{"*"}, fields...)
+		} else {
+			times = fields[1]
+		}
+	default:
+		return nil, fmt.Errorf("expected [<days>] <start>-<end> [<zone>], have %q", spec)
+	}
+
+	if len(fields) == 1 || fields[0] == "*" {
+		for i := range w.days {
+			w.days[i] = true
+		}
+	} else if err := w.parseDays(fields[0]); err != nil {
+		return nil, err
+	}
+
+	start, end, ok := strings.Cut(times, "-")
+	if !ok {
+		return nil, fmt.Errorf("expected <start>-<end>, have %q", times)
+	}
+	var err error
+	if w.start, err = parseTime(start); err != nil {
+		return nil, err
+	}
+	if w.end, err = parseTime(end); err != nil {
+		return nil, err
+	}
+
+	if len(fields) == 3 {
+		if w.location, err = time.LoadLocation(fields[2]); err != nil {
+			return nil, fmt.Errorf("invalid zone %q: %w", fields[2], err)
+		}
+	}
+	return w, nil
+}
+
+func (w *Window) parseDays(s string) error {
+	for _, r := range strings.Split(s, ",") {
+		from, to, isRange := strings.Cut(r, "-")
+		first, ok := weekdays[strings.ToLower(from)]
+		if !ok {
+			return fmt.Errorf("invalid weekday %q", from)
+		}
+		last := first
+		if isRange {
+			if last, ok = weekdays[strings.ToLower(to)]; !ok {
+				return fmt.Errorf("invalid weekday %q", to)
+			}
+		}
+		for d := first; ; d = (d + 1) % 7 {
+			w.days[d] = true
+			if d == last {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+func parseTime(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains returns true if the time is within the window.
+func (w *Window) Contains(t time.Time) bool {
+	t = t.In(w.location)
+	offset := time.Duration(t.Hour())*time.Hour +
+		time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second
+	day := t.Weekday()
+
+	if w.start <= w.end {
+		return w.days[day] && offset >= w.start && offset < w.end
+	}
+	// The window spans midnight, so after midnight belongs to the prior day.
+	if offset >= w.start {
+		return w.days[day]
+	}
+	return offset < w.end && w.days[(day+6)%7]
+}
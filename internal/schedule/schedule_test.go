@@ -0,0 +1,61 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+// monday is 2023-01-02, a Monday.
+func monday(hour, minute int) time.Time {
+	return time.Date(2023, 1, 2, hour, minute, 0, 0, time.UTC)
+}
+
+func TestWindow_Contains(t *testing.T) {
+	tests := []struct {
+		spec     string
+		time     time.Time
+		expected bool
+	}{
+		{spec: "09:00-17:00", time: monday(9, 0), expected: true},
+		{spec: "09:00-17:00", time: monday(17, 0), expected: false},
+		{spec: "* 09:00-17:00", time: monday(12, 0), expected: true},
+		{spec: "Mon-Fri 09:00-17:00", time: monday(12, 0), expected: true},
+		{spec: "Sat,Sun 09:00-17:00", time: monday(12, 0), expected: false},
+		{spec: "Fri-Mon 09:00-17:00", time: monday(12, 0), expected: true},
+		{spec: "Mon 09:00-17:00 America/New_York", time: monday(12, 0), expected: false},
+		{spec: "Mon 09:00-17:00 America/New_York", time: monday(14, 0), expected: true},
+		{spec: "22:00-06:00", time: monday(23, 0), expected: true},
+		{spec: "22:00-06:00", time: monday(5, 59), expected: true},
+		{spec: "22:00-06:00", time: monday(6, 0), expected: false},
+		{spec: "Sun 22:00-06:00", time: monday(1, 0), expected: true},
+		{spec: "Mon 22:00-06:00", time: monday(1, 0), expected: false},
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.spec+" "+tc.time.Format(time.Kitchen), func(t *testing.T) {
+			w, err := Parse(tc.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if have := w.Contains(tc.time); have != tc.expected {
+				t.Fatalf("expected %v, have %v", tc.expected, have)
+			}
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"09:00",
+		"9am-5pm",
+		"Mon-Fry 09:00-17:00",
+		"09:00-17:00 Nowhere/Nowhere",
+		"Mon 09:00-17:00 UTC extra",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("expected error parsing %q", spec)
+		}
+	}
+}
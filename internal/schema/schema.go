@@ -0,0 +1,262 @@
+// Package schema validates guest configuration against a subset of JSON
+// Schema published by the guest.
+//
+// The supported keywords are "type", "enum", "properties", "required",
+// "additionalProperties", "items", "minItems", "maxItems", "minLength",
+// "maxLength", "pattern", "minimum" and "maximum". Unknown keywords are
+// ignored, as they are in JSON Schema.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Error is a validation failure at a specific location in the config.
+type Error struct {
+	// Path is the location of the invalid value, in JSONPath notation.
+	// Ex. "$.rules[1].name"
+	Path string
+
+	// Message describes why the value at Path is invalid.
+	Message string
+}
+
+// Error implements the same method as documented on error.
+func (e *Error) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// Errors are all validation failures found in a config.
+type Errors []*Error
+
+// Error implements the same method as documented on error.
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Schema is a compiled JSON schema.
+type Schema struct {
+	Type                 types              `json:"type"`
+	Enum                 []interface{}      `json:"enum"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *additional        `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Pattern              string             `json:"pattern"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+
+	pattern *regexp.Regexp
+}
+
+// types is the "type" keyword, which is either a string or an array of them.
+type types []string
+
+func (t *types) UnmarshalJSON(b []byte) error {
+	var one string
+	if err := json.Unmarshal(b, &one); err == nil {
+		*t = types{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(b, &many); err != nil {
+		return fmt.Errorf("type must be a string or array of strings")
+	}
+	*t = many
+	return nil
+}
+
+// additional is the "additionalProperties" keyword, which is either a
+// boolean or a schema.
+type additional struct {
+	allowed bool
+	schema  *Schema
+}
+
+func (a *additional) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &a.allowed); err == nil {
+		return nil
+	}
+	a.allowed = true
+	return json.Unmarshal(b, &a.schema)
+}
+
+// Compile parses the JSON schema, returning an error if it is malformed.
+func Compile(schema []byte) (*Schema, error) {
+	var s Schema
+	if err := json.Unmarshal(schema, &s); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	if err := s.compile(); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return &s, nil
+}
+
+func (s *Schema) compile() (err error) {
+	if s.Pattern != "" {
+		if s.pattern, err = regexp.Compile(s.Pattern); err != nil {
+			return
+		}
+	}
+	for _, p := range s.Properties {
+		if err = p.compile(); err != nil {
+			return
+		}
+	}
+	if s.Items != nil {
+		if err = s.Items.compile(); err != nil {
+			return
+		}
+	}
+	if a := s.AdditionalProperties; a != nil && a.schema != nil {
+		err = a.schema.compile()
+	}
+	return
+}
+
+// Validate returns Errors if the JSON config doesn't match the schema. An
+// empty config is validated as null.
+func (s *Schema) Validate(config []byte) error {
+	var v interface{}
+	if len(bytes.TrimSpace(config)) > 0 {
+		d := json.NewDecoder(bytes.NewReader(config))
+		d.UseNumber()
+		if err := d.Decode(&v); err != nil {
+			return Errors{{Path: "$", Message: "invalid JSON: " + err.Error()}}
+		}
+	}
+	var errs Errors
+	s.validate("$", v, &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (s *Schema) validate(path string, v interface{}, errs *Errors) {
+	fail := func(format string, args ...interface{}) {
+		*errs = append(*errs, &Error{Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	actual := typeOf(v)
+	if len(s.Type) > 0 && !s.Type.matches(actual, v) {
+		fail("expected %s, got %s", strings.Join(s.Type, " or "), actual)
+		return // other keywords would only add noise.
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		fail("value is not one of the allowed values")
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names) // for stable error order
+		for _, name := range names {
+			p := path + "." + name
+			if ps, ok := s.Properties[name]; ok {
+				ps.validate(p, v[name], errs)
+			} else if a := s.AdditionalProperties; a != nil && !a.allowed {
+				*errs = append(*errs, &Error{Path: p, Message: "unknown property"})
+			} else if a != nil && a.schema != nil {
+				a.schema.validate(p, v[name], errs)
+			}
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			fail("expected at least %d items, got %d", *s.MinItems, len(v))
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			fail("expected at most %d items, got %d", *s.MaxItems, len(v))
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, errs)
+			}
+		}
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.MinLength != nil && n < *s.MinLength {
+			fail("expected at least %d characters, got %d", *s.MinLength, n)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			fail("expected at most %d characters, got %d", *s.MaxLength, n)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			fail("does not match pattern %q", s.Pattern)
+		}
+	case json.Number:
+		f, _ := v.Float64()
+		if s.Minimum != nil && f < *s.Minimum {
+			fail("expected minimum %v, got %v", *s.Minimum, v)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			fail("expected maximum %v, got %v", *s.Maximum, v)
+		}
+	}
+}
+
+func typeOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func (t types) matches(actual string, v interface{}) bool {
+	for _, want := range t {
+		if want == actual {
+			return true
+		}
+		if want == "integer" && actual == "number" {
+			if _, err := v.(json.Number).Int64(); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func inEnum(enum []interface{}, v interface{}) bool {
+	want, _ := json.Marshal(v)
+	for _, e := range enum {
+		// Normalize numbers, as enum values weren't decoded with UseNumber.
+		if got, _ := json.Marshal(e); bytes.Equal(got, want) {
+			return true
+		}
+	}
+	return false
+}
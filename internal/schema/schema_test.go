@@ -0,0 +1,105 @@
+package schema
+
+import "testing"
+
+func TestSchema_Validate(t *testing.T) {
+	const s = `{
+  "type": "object",
+  "properties": {
+    "realm": {"type": "string", "minLength": 1, "pattern": "^[a-z]+$"},
+    "mode": {"enum": ["allow", "deny"]},
+    "limit": {"type": "integer", "minimum": 1, "maximum": 100},
+    "paths": {"type": "array", "items": {"type": "string"}, "maxItems": 2}
+  },
+  "required": ["realm"],
+  "additionalProperties": false
+}`
+
+	tests := []struct {
+		name, config, expectedErr string
+	}{
+		{
+			name:   "valid",
+			config: `{"realm":"test","mode":"deny","limit":10,"paths":["/a"]}`,
+		},
+		{
+			name:        "empty",
+			expectedErr: "$: expected object, got null",
+		},
+		{
+			name:        "invalid JSON",
+			config:      `{"realm":`,
+			expectedErr: "$: invalid JSON: unexpected EOF",
+		},
+		{
+			name:        "missing required",
+			config:      `{}`,
+			expectedErr: `$: missing required property "realm"`,
+		},
+		{
+			name:        "wrong type",
+			config:      `{"realm":1}`,
+			expectedErr: "$.realm: expected string, got number",
+		},
+		{
+			name:        "not integer",
+			config:      `{"realm":"test","limit":1.5}`,
+			expectedErr: "$.limit: expected integer, got number",
+		},
+		{
+			name:        "out of range",
+			config:      `{"realm":"test","limit":101}`,
+			expectedErr: "$.limit: expected maximum 100, got 101",
+		},
+		{
+			name:        "not in enum",
+			config:      `{"realm":"test","mode":"log"}`,
+			expectedErr: "$.mode: value is not one of the allowed values",
+		},
+		{
+			name:        "pattern",
+			config:      `{"realm":"Test"}`,
+			expectedErr: `$.realm: does not match pattern "^[a-z]+$"`,
+		},
+		{
+			name:        "items",
+			config:      `{"realm":"test","paths":["/a",1,"/c"]}`,
+			expectedErr: "$.paths: expected at most 2 items, got 3; $.paths[1]: expected string, got number",
+		},
+		{
+			name:        "unknown property",
+			config:      `{"realm":"test","reaml":"test"}`,
+			expectedErr: "$.reaml: unknown property",
+		},
+	}
+
+	compiled, err := Compile([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		tc := tt
+		t.Run(tc.name, func(t *testing.T) {
+			err := compiled.Validate([]byte(tc.config))
+			if tc.expectedErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			} else if err == nil {
+				t.Fatalf("expected error %q", tc.expectedErr)
+			} else if have := err.Error(); have != tc.expectedErr {
+				t.Fatalf("expected error %q, have %q", tc.expectedErr, have)
+			}
+		})
+	}
+}
+
+func TestCompile_invalid(t *testing.T) {
+	if _, err := Compile([]byte(`{"type":1}`)); err == nil {
+		t.Fatal("expected error on invalid type")
+	}
+	if _, err := Compile([]byte(`{"pattern":"("}`)); err == nil {
+		t.Fatal("expected error on invalid pattern")
+	}
+}
@@ -17,3 +17,519 @@ var AuthWasm []byte
 //
 //go:embed testdata/log.wasm
 var LogWasm []byte
+
+// ConfigWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names config.wat
+//
+//go:embed testdata/config.wasm
+var ConfigWasm []byte
+
+// HandleErrorWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names handle_error.wat
+//
+//go:embed testdata/handle_error.wasm
+var HandleErrorWasm []byte
+
+// HandleRequestWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names handle_request.wat
+//
+//go:embed testdata/handle_request.wasm
+var HandleRequestWasm []byte
+
+// HandleResponseWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names handle_response.wat
+//
+//go:embed testdata/handle_response.wasm
+var HandleResponseWasm []byte
+
+// MirrorWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names mirror.wat
+//
+//go:embed testdata/mirror.wasm
+var MirrorWasm []byte
+
+// StatusWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names status.wat
+//
+//go:embed testdata/status.wasm
+var StatusWasm []byte
+
+// QueryWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names query.wat
+//
+//go:embed testdata/query.wasm
+var QueryWasm []byte
+
+// CookieWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names cookie.wat
+//
+//go:embed testdata/cookie.wasm
+var CookieWasm []byte
+
+// ScratchWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names scratch.wat
+//
+//go:embed testdata/scratch.wasm
+var ScratchWasm []byte
+
+// TLSWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names tls.wat
+//
+//go:embed testdata/tls.wasm
+var TLSWasm []byte
+
+// MultipartWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names multipart.wat
+//
+//go:embed testdata/multipart.wasm
+var MultipartWasm []byte
+
+// TrailerWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names trailer.wat
+//
+//go:embed testdata/trailer.wasm
+var TrailerWasm []byte
+
+// ExtractWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names extract.wat
+//
+//go:embed testdata/extract.wasm
+var ExtractWasm []byte
+
+// BodyChunkWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names body_chunk.wat
+//
+//go:embed testdata/body_chunk.wasm
+var BodyChunkWasm []byte
+
+// ProblemWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names problem.wat
+//
+//go:embed testdata/problem.wasm
+var ProblemWasm []byte
+
+// ReadBodyWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names read_body.wat
+//
+//go:embed testdata/read_body.wasm
+var ReadBodyWasm []byte
+
+// LocalizedWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names localized.wat
+//
+//go:embed testdata/localized.wasm
+var LocalizedWasm []byte
+
+// PropertyWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names property.wat
+//
+//go:embed testdata/property.wasm
+var PropertyWasm []byte
+
+// InjectWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names inject.wat
+//
+//go:embed testdata/inject.wasm
+var InjectWasm []byte
+
+// SharedWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names shared.wat
+//
+//go:embed testdata/shared.wasm
+var SharedWasm []byte
+
+// ClockWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names clock.wat
+//
+//go:embed testdata/clock.wasm
+var ClockWasm []byte
+
+// RandomWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names random.wat
+//
+//go:embed testdata/random.wasm
+var RandomWasm []byte
+
+// ErrorWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names error.wat
+//
+//go:embed testdata/error.wasm
+var ErrorWasm []byte
+
+// HTTPCallWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names http_call.wat
+//
+//go:embed testdata/http_call.wasm
+var HTTPCallWasm []byte
+
+// ResolveWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names resolve.wat
+//
+//go:embed testdata/resolve.wasm
+var ResolveWasm []byte
+
+// TrapWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names trap.wat
+//
+//go:embed testdata/trap.wasm
+var TrapWasm []byte
+
+// StartTrapWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names start_trap.wat
+//
+//go:embed testdata/start_trap.wasm
+var StartTrapWasm []byte
+
+// QuotaWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names quota.wat
+//
+//go:embed testdata/quota.wasm
+var QuotaWasm []byte
+
+// LastErrorWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names last_error.wat
+//
+//go:embed testdata/last_error.wasm
+var LastErrorWasm []byte
+
+// FeaturesWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names features.wat
+//
+//go:embed testdata/features.wasm
+var FeaturesWasm []byte
+
+// ReadResponseBodyWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names read_response_body.wat
+//
+//go:embed testdata/read_response_body.wasm
+var ReadResponseBodyWasm []byte
+
+// CapabilitiesWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names capabilities.wat
+//
+//go:embed testdata/capabilities.wasm
+var CapabilitiesWasm []byte
+
+// ProxyWasmWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names proxywasm.wat
+//
+//go:embed testdata/proxywasm.wasm
+var ProxyWasmWasm []byte
+
+// LifecycleWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names lifecycle.wat
+//
+//go:embed testdata/lifecycle.wasm
+var LifecycleWasm []byte
+
+// AccessLogWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names access_log.wat
+//
+//go:embed testdata/access_log.wasm
+var AccessLogWasm []byte
+
+// UpgradeWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names upgrade.wat
+//
+//go:embed testdata/upgrade.wasm
+var UpgradeWasm []byte
+
+// StreamingWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names streaming.wat
+//
+//go:embed testdata/streaming.wasm
+var StreamingWasm []byte
+
+// ProtocolWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names protocol.wat
+//
+//go:embed testdata/protocol.wasm
+var ProtocolWasm []byte
+
+// RedirectWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names redirect.wat
+//
+//go:embed testdata/redirect.wasm
+var RedirectWasm []byte
+
+// AllocWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names alloc.wat
+//
+//go:embed testdata/alloc.wasm
+var AllocWasm []byte
+
+// HeadersWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names headers.wat
+//
+//go:embed testdata/headers.wasm
+var HeadersWasm []byte
+
+// StdioWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names stdio.wat
+//
+//go:embed testdata/stdio.wasm
+var StdioWasm []byte
+
+// EnvWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names env.wat
+//
+//go:embed testdata/env.wasm
+var EnvWasm []byte
+
+// BadHandleWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names bad_handle.wat
+//
+//go:embed testdata/bad_handle.wasm
+var BadHandleWasm []byte
+
+// NoMemoryWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names no_memory.wat
+//
+//go:embed testdata/no_memory.wasm
+var NoMemoryWasm []byte
+
+// ResponseSizeWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names response_size.wat
+//
+//go:embed testdata/response_size.wasm
+var ResponseSizeWasm []byte
+
+// UpstreamWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names upstream.wat
+//
+//go:embed testdata/upstream.wasm
+var UpstreamWasm []byte
+
+// PolicyWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names policy.wat
+//
+//go:embed testdata/policy.wasm
+var PolicyWasm []byte
+
+// FormWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names form.wat
+//
+//go:embed testdata/form.wasm
+var FormWasm []byte
+
+// CryptoWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names crypto.wat
+//
+//go:embed testdata/crypto.wasm
+var CryptoWasm []byte
+
+// RateLimitWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names rate_limit.wat
+//
+//go:embed testdata/rate_limit.wasm
+var RateLimitWasm []byte
+
+// MetricsWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names metrics.wat
+//
+//go:embed testdata/metrics.wasm
+var MetricsWasm []byte
+
+// HostValueWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names host_value.wat
+//
+//go:embed testdata/host_value.wasm
+var HostValueWasm []byte
+
+// SnapshotWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names snapshot.wat
+//
+//go:embed testdata/snapshot.wasm
+var SnapshotWasm []byte
+
+// BadImportsWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names bad_imports.wat
+//
+//go:embed testdata/bad_imports.wasm
+var BadImportsWasm []byte
+
+// GrowBuffersWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names grow_buffers.wat
+//
+//go:embed testdata/grow_buffers.wasm
+var GrowBuffersWasm []byte
+
+// UploadLimitWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names upload_limit.wat
+//
+//go:embed testdata/upload_limit.wasm
+var UploadLimitWasm []byte
+
+// RPCWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names rpc.wat
+//
+//go:embed testdata/rpc.wasm
+var RPCWasm []byte
+
+// RouteWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names route.wat
+//
+//go:embed testdata/route.wasm
+var RouteWasm []byte
+
+// RequestIDWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names request_id.wat
+//
+//go:embed testdata/request_id.wasm
+var RequestIDWasm []byte
+
+// StateWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names state.wat
+//
+//go:embed testdata/state.wasm
+var StateWasm []byte
+
+// RawHeadersWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names raw_headers.wat
+//
+//go:embed testdata/raw_headers.wasm
+var RawHeadersWasm []byte
+
+// CORSWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names cors.wat
+//
+//go:embed testdata/cors.wasm
+var CORSWasm []byte
+
+// CacheWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names cache.wat
+//
+//go:embed testdata/cache.wasm
+var CacheWasm []byte
+
+// ClientGoneWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names client_gone.wat
+//
+//go:embed testdata/client_gone.wasm
+var ClientGoneWasm []byte
+
+// ListenerWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names listener.wat
+//
+//go:embed testdata/listener.wasm
+var ListenerWasm []byte
+
+// ConfigUpdateWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names config_update.wat
+//
+//go:embed testdata/config_update.wasm
+var ConfigUpdateWasm []byte
+
+// EventWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names event.wat
+//
+//go:embed testdata/event.wasm
+var EventWasm []byte
+
+// TickWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names tick.wat
+//
+//go:embed testdata/tick.wasm
+var TickWasm []byte
+
+// BatchWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names batch.wat
+//
+//go:embed testdata/batch.wasm
+var BatchWasm []byte
+
+// ContentTypeWasm was generated by the following:
+//
+//	cd testdata; wat2wasm --debug-names content_type.wat
+//
+//go:embed testdata/content_type.wasm
+var ContentTypeWasm []byte
+
+// WithCustomSection returns a copy of the guest with a custom section
+// appended to it.
+func WithCustomSection(guest []byte, name string, data []byte) []byte {
+	payload := append(encodeUint32(uint32(len(name))), name...)
+	payload = append(payload, data...)
+	bin := append([]byte{}, guest...)
+	bin = append(bin, 0) // custom section ID
+	bin = append(bin, encodeUint32(uint32(len(payload)))...)
+	return append(bin, payload...)
+}
+
+// encodeUint32 encodes the value as unsigned LEB128.
+func encodeUint32(v uint32) (b []byte) {
+	for ; v >= 0x80; v >>= 7 {
+		b = append(b, byte(v&0x7f)|0x80)
+	}
+	return append(b, byte(v))
+}
@@ -0,0 +1,37 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; guest emits structured access log entries, separately from debug logging.
+(module $access_log
+
+  ;; emit_access_log passes an access log entry, read from memory, to the
+  ;; host.
+  (import "http-handler" "emit_access_log"
+    (func $emit_access_log (param $entry i32) (param $entry_len i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "emit_access_log" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (global $entry i32 (i32.const 0))
+  (data (i32.const 0) "{\"guest\":\"access_log\"}")
+  (global $entry_len i32 (i32.const 22))
+
+  (global $invalid i32 (i32.const 32))
+  (data (i32.const 32) "not json")
+  (global $invalid_len i32 (i32.const 8))
+
+  ;; handle invokes the next handler, then emits an access log entry and one
+  ;; which isn't valid JSON.
+  (func $handle (export "handle")
+    (call $next)
+
+    (call $emit_access_log
+      (global.get $entry)
+      (global.get $entry_len))
+
+    (call $emit_access_log
+      (global.get $invalid)
+      (global.get $invalid_len)))
+)
@@ -0,0 +1,97 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; handler reads values of unknown length into memory the host allocates with
+;; "malloc", instead of retrying with a larger buffer.
+(module $alloc
+
+  ;; read_request_header_alloc writes a header value to memory allocated by
+  ;; "malloc". The result is `ptr<<32|value_len` or zero if the header doesn't
+  ;; exist or is empty.
+  (import "http-handler" "read_request_header_alloc"
+    (func $read_request_header_alloc
+      (param $name i32) (param $name_len i32)
+      (result (; ptr<<32|value_len ;) i64)))
+
+  ;; read_request_body reads the request body into memory allocated by
+  ;; "malloc". The result is `ptr<<32|body_len` or zero if the body is empty.
+  (import "http-handler" "read_request_body"
+    (func $read_request_body (result (; ptr<<32|body_len ;) i64)))
+
+  ;; set_response_header sets a response header from a name and value read
+  ;; from memory
+  (import "http-handler" "set_response_header"
+    (func $set_response_header
+      (param $name i32) (param $name_len i32)
+      (param $value i32) (param $value_len i32)))
+
+  ;; log writes a message to the host console.
+  (import "http-handler" "log" (func $log (param $ptr i32) (param $size i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "read_request_header_alloc" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (global $value i32 (i32.const 0))
+  (data (i32.const 0) "X-Value")
+  (global $value_len i32 (i32.const 7))
+
+  (global $echo i32 (i32.const 8))
+  (data (i32.const 8) "X-Echo")
+  (global $echo_len i32 (i32.const 6))
+
+  (global $free i32 (i32.const 16))
+  (data (i32.const 16) "free")
+  (global $free_len i32 (i32.const 4))
+
+  ;; heap is the offset of the next allocation.
+  (global $heap_base i32 (i32.const 1024))
+  (global $heap (mut i32) (i32.const 1024))
+
+  ;; malloc is a bump allocator, which grows memory as needed. Allocations
+  ;; are freed at the start of each request.
+  (func $malloc (export "malloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local $end i32)
+
+    (local.set $ptr (global.get $heap))
+    (local.set $end (i32.add (local.get $ptr) (local.get $size)))
+
+    (if (i32.gt_u (local.get $end) (i32.shl (memory.size) (i32.const 16)))
+      (then
+        (if (i32.eq
+              (memory.grow
+                (i32.sub
+                  (i32.shr_u (i32.add (local.get $end) (i32.const 65535)) (i32.const 16))
+                  (memory.size)))
+              (i32.const -1))
+          (then (unreachable)))))
+
+    (global.set $heap (local.get $end))
+    (local.get $ptr))
+
+  ;; free logs, as the bump allocator can't free individual allocations.
+  (func $free (export "free") (param $ptr i32)
+    (call $log (global.get $free) (global.get $free_len)))
+
+  ;; handle echoes the request header "X-Value" as the response header
+  ;; "X-Echo", and reads the request body before dispatching to the next
+  ;; handler.
+  (func $handle (export "handle")
+    (local $result i64)
+
+    (global.set $heap (global.get $heap_base))
+
+    (local.set $result
+      (call $read_request_header_alloc (global.get $value) (global.get $value_len)))
+
+    (call $set_response_header
+      (global.get $echo) (global.get $echo_len)
+      (i32.wrap_i64 (i64.shr_u (local.get $result) (i64.const 32)))
+      (i32.wrap_i64 (local.get $result)))
+
+    (drop (call $read_request_body))
+
+    (call $next))
+)
@@ -0,0 +1,5 @@
+;; bad_handle exports handle with a parameter, which the host can't call.
+(module $bad_handle
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (func $handle (export "handle") (param $unexpected i32)))
@@ -0,0 +1,8 @@
+;; bad_imports imports functions the host doesn't implement, or with the wrong
+;; signature, so it fails to compile.
+(module $bad_imports
+  (import "http-handler" "teleport" (func $teleport))
+  (import "http-handler" "log" (func $log (param i32)))
+  (import "env" "abort" (func $abort))
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+  (func $handle (export "handle")))
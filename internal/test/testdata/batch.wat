@@ -0,0 +1,71 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; handler reads query parameters and properties in one call each, encoded
+;; the same as read_request_headers.
+(module $batch
+
+  ;; read_query_params writes all query parameters to memory if they aren't
+  ;; larger than the buffer size limit. The result is their encoded length.
+  (import "http-handler" "read_query_params"
+    (func $read_query_params (param $buf i32) (param $buf_limit i32) (result i32)))
+
+  ;; read_properties writes all properties of the request to memory if they
+  ;; aren't larger than the buffer size limit. The result is their encoded
+  ;; length.
+  (import "http-handler" "read_properties"
+    (func $read_properties (param $buf i32) (param $buf_limit i32) (result i32)))
+
+  ;; set_property sets a property of the request from a name and value read
+  ;; from memory.
+  (import "http-handler" "set_property"
+    (func $set_property
+      (param $name i32) (param $name_len i32)
+      (param $value i32) (param $value_len i32)))
+
+  ;; send_response sends the HTTP response with a given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "read_query_params" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; user is the name of the property the guest sets.
+  (global $user i32 (i32.const 0))
+  (data (i32.const 0) "user")
+  (global $user_len i32 (i32.const 4))
+
+  (global $alice i32 (i32.const 8))
+  (data (i32.const 8) "alice")
+  (global $alice_len i32 (i32.const 5))
+
+  ;; buf is an arbitrary area to write data.
+  (global $buf i32 (i32.const 1024))
+  (global $buf_limit i32 (i32.const 16384))
+
+  ;; handle sets the property "user", then responds with the encoded query
+  ;; parameters followed by the encoded properties.
+  (func $handle (export "handle")
+    (local $params_len i32)
+    (local $properties_len i32)
+
+    (call $set_property
+      (global.get $user) (global.get $user_len)
+      (global.get $alice) (global.get $alice_len))
+
+    (local.set $params_len
+      (call $read_query_params (global.get $buf) (global.get $buf_limit)))
+
+    (local.set $properties_len
+      (call $read_properties
+        (i32.add (global.get $buf) (local.get $params_len))
+        (i32.sub (global.get $buf_limit) (local.get $params_len))))
+
+    (call $send_response
+      (i32.const 200)
+      (global.get $buf)
+      (i32.add (local.get $params_len) (local.get $properties_len))))
+)
@@ -0,0 +1,62 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how a handler transforms the request body in chunks, with bounded memory.
+(module $body_chunk
+
+  ;; enable_request_body_chunks streams the request body through the export
+  ;; "handle_request_body_chunk", writing each chunk to the given buffer.
+  (import "http-handler" "enable_request_body_chunks"
+    (func $enable_request_body_chunks
+      (param $buf i32) (param $buf_limit i32)))
+
+  ;; log writes a message to the host console.
+  (import "http-handler" "log" (func $log (param $ptr i32) (param $size i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "enable_request_body_chunks" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; buf is an arbitrary area to write data. The small limit results in
+  ;; multiple chunks, even for small bodies.
+  (global $buf i32 (i32.const 1024))
+  (global $buf_limit i32 (i32.const 4))
+
+  (global $eos i32 (i32.const 0))
+  (data (i32.const 0) "eos")
+  (global $eos_len i32 (i32.const 3))
+
+  ;; handle enables chunks before dispatching to the next handler.
+  (func $handle (export "handle")
+    (call $enable_request_body_chunks (global.get $buf) (global.get $buf_limit))
+    (call $next))
+
+  ;; handle_request_body_chunk upper-cases ASCII letters in place, and logs
+  ;; at the end of the stream.
+  (func $handle_request_body_chunk (export "handle_request_body_chunk")
+    (param $chunk_len i32) (param $end_of_stream i32) (result i32)
+    (local $i i32)
+    (local $c i32)
+
+    (block $done
+      (loop $next_byte
+        (br_if $done (i32.ge_u (local.get $i) (local.get $chunk_len)))
+        (local.set $c
+          (i32.load8_u (i32.add (global.get $buf) (local.get $i))))
+        (if (i32.and
+              (i32.ge_u (local.get $c) (i32.const 97 (; 'a' ;)))
+              (i32.le_u (local.get $c) (i32.const 122 (; 'z' ;))))
+          (then
+            (i32.store8
+              (i32.add (global.get $buf) (local.get $i))
+              (i32.sub (local.get $c) (i32.const 32)))))
+        (local.set $i (i32.add (local.get $i) (i32.const 1)))
+        (br $next_byte)))
+
+    (if (local.get $end_of_stream)
+      (then
+        (call $log (global.get $eos) (global.get $eos_len))))
+
+    (local.get $chunk_len))
+)
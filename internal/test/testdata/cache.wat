@@ -0,0 +1,56 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; guest caches responses of the next handler, using the request header
+;; "X-Cache-Key" as the cache key.
+(module $cache
+  ;; read_request_header writes a header value to memory if it exists and
+  ;; isn't larger than the buffer size limit.
+  (import "http-handler" "read_request_header"
+    (func $read_request_header
+      (param $name i32) (param $name_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; 0 or 1 << 32| value_len ;) i64)))
+
+  ;; cache_lookup sends the cached response of a key, if it exists.
+  (import "http-handler" "cache_lookup"
+    (func $cache_lookup
+      (param $key i32) (param $key_len i32)
+      (result (; hit ;) i32)))
+
+  ;; cache_store stores the response of the request after it was handled.
+  (import "http-handler" "cache_store"
+    (func $cache_store
+      (param $key i32) (param $key_len i32)
+      (param $ttl_secs i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "log" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (global $name i32 (i32.const 0))
+  (data (i32.const 0) "X-Cache-Key")
+  (global $name_len i32 (i32.const 11))
+
+  ;; buf is where the key is read to.
+  (global $buf i32 (i32.const 64))
+  (global $buf_limit i32 (i32.const 256))
+
+  ;; handle_request serves the cached response of the key, if any.
+  ;; Otherwise, it stores the response of the next handler for 60 seconds.
+  ;; Requests without a key continue as-is.
+  (func $handle_request (export "handle_request") (result (; continue ;) i32)
+    (local $result i64)
+    (local $key_len i32)
+
+    (local.set $result (call $read_request_header
+      (global.get $name) (global.get $name_len)
+      (global.get $buf) (global.get $buf_limit)))
+    (if (i64.eqz (local.get $result))
+      (then (return (i32.const 1))))
+    (local.set $key_len (i32.wrap_i64 (local.get $result)))
+
+    (if (call $cache_lookup (global.get $buf) (local.get $key_len))
+      (then (return (i32.const 0)))) ;; the host sent the cached response
+
+    (call $cache_store (global.get $buf) (local.get $key_len) (i32.const 60))
+    (i32.const 1))
+)
@@ -0,0 +1,24 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how a guest checks which features the host supports.
+(module $capabilities
+
+  ;; capabilities returns the features the host supports.
+  (import "http-handler" "capabilities"
+    (func $capabilities
+      (result (; features ;) i64)))
+
+  ;; send_response sends the HTTP response with a given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32) (param $body i32) (param $body_len i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "send_response" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; handle responds with the features, as a little-endian i64.
+  (func $handle (export "handle")
+    (i64.store (i32.const 0) (call $capabilities))
+    (call $send_response (i32.const 200) (i32.const 0) (i32.const 8)))
+)
@@ -0,0 +1,33 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; guest doing expensive work aborts early when the client disconnects.
+(module $client_gone
+  ;; log writes a message to the host console.
+  (import "http-handler" "log" (func $log (param $ptr i32) (param $size i32)))
+
+  ;; is_client_gone returns one if the client disconnected.
+  (import "http-handler" "is_client_gone"
+    (func $is_client_gone (result (; gone ;) i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "log" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (global $working i32 (i32.const 0))
+  (data (i32.const 0) "working")
+  (global $working_len i32 (i32.const 7))
+
+  (global $aborted i32 (i32.const 8))
+  (data (i32.const 8) "aborted")
+  (global $aborted_len i32 (i32.const 7))
+
+  ;; handle_request logs "working" until the client is gone, then logs
+  ;; "aborted", unless the host interrupted it first.
+  (func $handle_request (export "handle_request") (result (; continue ;) i32)
+    (loop $work
+      (if (i32.eqz (call $is_client_gone))
+        (then
+          (call $log (global.get $working) (global.get $working_len))
+          (br $work))))
+    (call $log (global.get $aborted) (global.get $aborted_len))
+    (i32.const 0))
+)
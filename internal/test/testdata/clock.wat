@@ -0,0 +1,33 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how a handler reads the host clock, without WASI.
+(module $clock
+
+  ;; get_time_nanos returns the wall clock time in nanoseconds since the Unix
+  ;; epoch.
+  (import "http-handler" "get_time_nanos"
+    (func $get_time_nanos (result i64)))
+
+  ;; send_response sends the current response with the given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory".
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; expires is 2000-01-01T00:00:00Z in nanoseconds since the Unix epoch.
+  (global $expires i64 (i64.const 946684800000000000))
+
+  ;; handle responds with 401 Unauthorized if the current time is at or after
+  ;; a fixed expiration. Otherwise, it dispatches to the next handler.
+  (func $handle (export "handle")
+    (if (i64.ge_s (call $get_time_nanos) (global.get $expires))
+      (then (call $send_response (i32.const 401) (i32.const 0) (i32.const 0)))
+      (else (call $next))))
+)
@@ -0,0 +1,44 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how a handler reads its configuration from the host.
+(module $config
+
+  ;; get_config writes the guest config to memory if it isn't larger than the
+  ;; buffer size limit. The result is the length of the config in bytes.
+  (import "http-handler" "get_config"
+    (func $get_config
+      (param $buf i32) (param $buf_limit i32)
+      (result (; config_len ;) i32)))
+
+  ;; send_response sends the HTTP response with a given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "get_config" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; buf is an arbitrary area to write data
+  (global $buf i32 (i32.const 1024))
+  (global $buf_limit i32 (i32.const 1024))
+
+  ;; handle responds with the guest config as the body.
+  (func $handle (export "handle")
+    (local $config_len i32)
+
+    (local.set $config_len
+      (call $get_config (global.get $buf) (global.get $buf_limit)))
+
+    (if (i32.gt_u (local.get $config_len) (global.get $buf_limit))
+      (then ;; config is too large to echo
+        (call $send_response (i32.const 500) (i32.const 0) (i32.const 0))
+        (return)))
+
+    (call $send_response
+      (i32.const 200)
+      (global.get $buf)
+      (local.get $config_len)))
+)
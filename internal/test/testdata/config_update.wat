@@ -0,0 +1,53 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; handler parses its configuration once, and again when the host updates it,
+;; instead of on each request.
+(module $config_update
+
+  ;; get_config writes the guest config to memory if it isn't larger than the
+  ;; buffer size limit. The result is the length of the config in bytes.
+  (import "http-handler" "get_config"
+    (func $get_config
+      (param $buf i32) (param $buf_limit i32)
+      (result (; config_len ;) i32)))
+
+  ;; send_response sends the HTTP response with a given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "get_config" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; config is where the parsed config is kept, which for simplicity is a
+  ;; copy of it.
+  (global $config i32 (i32.const 1024))
+  (global $config_limit i32 (i32.const 1024))
+  (global $config_len (mut i32) (i32.const 0))
+
+  ;; load_config reads the config, trapping if it is too large.
+  (func $load_config
+    (global.set $config_len
+      (call $get_config (global.get $config) (global.get $config_limit)))
+    (if (i32.gt_u (global.get $config_len) (global.get $config_limit))
+      (then unreachable)))
+
+  ;; init loads the config when the guest is instantiated.
+  (func $init (export "init")
+    (call $load_config))
+
+  ;; on_config_update loads the config again, after the host updated it.
+  (func $on_config_update (export "on_config_update")
+    (call $load_config))
+
+  ;; handle_request responds with the config loaded, without reading it again.
+  (func $handle_request (export "handle_request") (result (; continue ;) i32)
+    (call $send_response
+      (i32.const 200)
+      (global.get $config)
+      (global.get $config_len))
+    (i32.const 0))
+)
@@ -0,0 +1,97 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; handler sniffs the content type of data, and negotiates the content type of
+;; the response with the "Accept" header of the request.
+(module $content_type
+
+  ;; read_request_header writes a header value to memory if it exists and
+  ;; isn't larger than the buffer size limit.
+  (import "http-handler" "read_request_header"
+    (func $read_request_header
+      (param $name i32) (param $name_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; 0 or 1 << 32| value_len ;) i64)))
+
+  ;; sniff_content_type writes the content type of data read from memory if
+  ;; it isn't larger than the buffer size limit. The result is its length.
+  (import "http-handler" "sniff_content_type"
+    (func $sniff_content_type
+      (param $data i32) (param $data_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; type_len ;) i32)))
+
+  ;; negotiate_content_type writes the offer the client prefers to memory if
+  ;; it isn't larger than the buffer size limit.
+  (import "http-handler" "negotiate_content_type"
+    (func $negotiate_content_type
+      (param $offers i32) (param $offers_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; 0 or 1 << 32| type_len ;) i64)))
+
+  ;; set_response_header sets a response header from a name and value read
+  ;; from memory
+  (import "http-handler" "set_response_header"
+    (func $set_response_header
+      (param $name i32) (param $name_len i32)
+      (param $value i32) (param $value_len i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "sniff_content_type" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; x_data is the request header with the data to sniff.
+  (global $x_data i32 (i32.const 0))
+  (data (i32.const 0) "X-Data")
+  (global $x_data_len i32 (i32.const 6))
+
+  ;; x_offers is the request header with the offers to negotiate.
+  (global $x_offers i32 (i32.const 16))
+  (data (i32.const 16) "X-Offers")
+  (global $x_offers_len i32 (i32.const 8))
+
+  ;; x_sniffed is the response header of the sniffed content type.
+  (global $x_sniffed i32 (i32.const 32))
+  (data (i32.const 32) "X-Sniffed")
+  (global $x_sniffed_len i32 (i32.const 9))
+
+  ;; x_negotiated is the response header of the negotiated content type.
+  (global $x_negotiated i32 (i32.const 48))
+  (data (i32.const 48) "X-Negotiated")
+  (global $x_negotiated_len i32 (i32.const 12))
+
+  ;; in is an area to read request headers, and out to write results.
+  (global $in i32 (i32.const 1024))
+  (global $out i32 (i32.const 4096))
+  (global $buf_limit i32 (i32.const 2048))
+
+  ;; handle sets the response header "X-Sniffed" to the content type of the
+  ;; request header "X-Data", and "X-Negotiated" to the offer in the request
+  ;; header "X-Offers" the client prefers, if any.
+  (func $handle (export "handle")
+    (local $len i32)
+    (local $result i64)
+
+    (local.set $len (i32.wrap_i64
+      (call $read_request_header
+        (global.get $x_data) (global.get $x_data_len)
+        (global.get $in) (global.get $buf_limit))))
+    (local.set $len
+      (call $sniff_content_type
+        (global.get $in) (local.get $len)
+        (global.get $out) (global.get $buf_limit)))
+    (call $set_response_header
+      (global.get $x_sniffed) (global.get $x_sniffed_len)
+      (global.get $out) (local.get $len))
+
+    (local.set $len (i32.wrap_i64
+      (call $read_request_header
+        (global.get $x_offers) (global.get $x_offers_len)
+        (global.get $in) (global.get $buf_limit))))
+    (local.set $result
+      (call $negotiate_content_type
+        (global.get $in) (local.get $len)
+        (global.get $out) (global.get $buf_limit)))
+    (if (i64.ne (local.get $result) (i64.const 0))
+      (then (call $set_response_header
+        (global.get $x_negotiated) (global.get $x_negotiated_len)
+        (global.get $out) (i32.wrap_i64 (local.get $result))))))
+)
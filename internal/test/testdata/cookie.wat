@@ -0,0 +1,79 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how a handler reads request cookies and sets response cookies.
+(module $cookie
+
+  ;; get_cookie writes a request cookie value to memory if it exists and isn't
+  ;; larger than the buffer size limit. The result is`1<<32|value_len` or zero
+  ;; if the cookie doesn't exist.
+  (import "http-handler" "get_cookie"
+    (func $get_cookie
+      (param $name i32) (param $name_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; 0 or 1 << 32| value_len ;) i64)))
+
+  ;; set_cookie adds a response cookie with attributes in "Set-Cookie" format.
+  (import "http-handler" "set_cookie"
+    (func $set_cookie
+      (param $name i32) (param $name_len i32)
+      (param $value i32) (param $value_len i32)
+      (param $attrs i32) (param $attrs_len i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "get_cookie" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; buf is an arbitrary area to write data
+  (global $buf i32 (i32.const 1024))
+  (global $buf_limit i32 (i32.const 1024))
+
+  (global $session i32 (i32.const 0))
+  (data (i32.const 0) "session")
+  (global $session_len i32 (i32.const 7))
+
+  (global $seen i32 (i32.const 8))
+  (data (i32.const 8) "seen")
+  (global $seen_len i32 (i32.const 4))
+
+  (global $new_session i32 (i32.const 16))
+  (data (i32.const 16) "new")
+  (global $new_session_len i32 (i32.const 3))
+
+  (global $attrs i32 (i32.const 32))
+  (data (i32.const 32) "Path=/; Max-Age=60; HttpOnly")
+  (global $attrs_len i32 (i32.const 28))
+
+  ;; handle copies the "session" cookie to "seen", or sets a new session if
+  ;; it doesn't exist. Then, it dispatches to the next handler.
+  (func $handle (export "handle")
+    (local $result i64)
+
+    (local.set $result
+      (call $get_cookie
+        (global.get $session)
+        (global.get $session_len)
+        (global.get $buf)
+        (global.get $buf_limit)))
+
+    (if (i64.eqz (local.get $result))
+      (then
+        (call $set_cookie
+          (global.get $session)
+          (global.get $session_len)
+          (global.get $new_session)
+          (global.get $new_session_len)
+          (global.get $attrs)
+          (global.get $attrs_len)))
+      (else
+        (call $set_cookie
+          (global.get $seen)
+          (global.get $seen_len)
+          (global.get $buf)
+          (i32.wrap_i64 (local.get $result))
+          (i32.const 0)
+          (i32.const 0))))
+
+    (call $next))
+)
@@ -0,0 +1,80 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; guest allows cross-origin requests, answering CORS preflight requests
+;; without invoking the next handler.
+(module $cors
+  ;; get_request_origin writes the "Origin" header of the request to memory
+  ;; if it exists and isn't larger than the buffer size limit.
+  (import "http-handler" "get_request_origin"
+    (func $get_request_origin
+      (param $buf i32) (param $buf_limit i32)
+      (result (; 0 or 1 << 32| value_len ;) i64)))
+
+  ;; read_request_header writes a header value to memory if it exists and
+  ;; isn't larger than the buffer size limit.
+  (import "http-handler" "read_request_header"
+    (func $read_request_header
+      (param $name i32) (param $name_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; 0 or 1 << 32| value_len ;) i64)))
+
+  ;; set_cors_headers sets the "Access-Control-Allow-*" response headers.
+  (import "http-handler" "set_cors_headers"
+    (func $set_cors_headers
+      (param $origin i32) (param $origin_len i32)
+      (param $methods i32) (param $methods_len i32)
+      (param $headers i32) (param $headers_len i32)
+      (param $max_age_secs i32) (param $credentials i32)))
+
+  ;; send_response sends the HTTP response with a given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "log" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (global $methods i32 (i32.const 0))
+  (data (i32.const 0) "GET, POST")
+  (global $methods_len i32 (i32.const 9))
+
+  (global $headers i32 (i32.const 16))
+  (data (i32.const 16) "Content-Type")
+  (global $headers_len i32 (i32.const 12))
+
+  (global $request_method i32 (i32.const 32))
+  (data (i32.const 32) "Access-Control-Request-Method")
+  (global $request_method_len i32 (i32.const 29))
+
+  ;; buf is where the origin is read to.
+  (global $buf i32 (i32.const 64))
+  (global $buf_limit i32 (i32.const 256))
+
+  ;; handle_request allows any origin with credentials, responding to
+  ;; preflight requests with 204. Requests without an origin continue as-is.
+  (func $handle_request (export "handle_request") (result (; continue ;) i32)
+    (local $result i64)
+
+    (local.set $result
+      (call $get_request_origin (global.get $buf) (global.get $buf_limit)))
+    (if (i64.eqz (local.get $result))
+      (then (return (i32.const 1)))) ;; not cross-origin, so call the next handler
+
+    (call $set_cors_headers
+      (global.get $buf) (i32.wrap_i64 (local.get $result))
+      (global.get $methods) (global.get $methods_len)
+      (global.get $headers) (global.get $headers_len)
+      (i32.const 600) (i32.const 1))
+
+    ;; Only preflight requests have the "Access-Control-Request-Method"
+    ;; header, which is checked without reading it, using buf_limit=0.
+    (if (i64.eqz (call $read_request_header
+          (global.get $request_method) (global.get $request_method_len)
+          (global.get $buf) (i32.const 0)))
+      (then (return (i32.const 1))))
+    (call $send_response (i32.const 204) (i32.const 0) (i32.const 0))
+    (i32.const 0))
+)
@@ -0,0 +1,90 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; handler signs and verifies data with keys the host manages.
+(module $crypto
+
+  ;; get_config writes the guest config to memory if it isn't larger than the
+  ;; buffer size limit. The result is the length of the config in bytes.
+  (import "http-handler" "get_config"
+    (func $get_config
+      (param $buf i32) (param $buf_limit i32)
+      (result (; config_len ;) i32)))
+
+  ;; hmac writes the MAC of data, using the secret with the key ID, if it
+  ;; isn't larger than the buffer size limit. The result is the length of the
+  ;; MAC, or zero if the secret or algorithm doesn't exist.
+  (import "http-handler" "hmac"
+    (func $hmac
+      (param $alg i32) (param $alg_len i32)
+      (param $key_id i32) (param $key_id_len i32)
+      (param $data i32) (param $data_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; mac_len ;) i32)))
+
+  ;; verify_signature returns one if the signature of the data is valid,
+  ;; using the key with the key ID.
+  (import "http-handler" "verify_signature"
+    (func $verify_signature
+      (param $alg i32) (param $alg_len i32)
+      (param $key_id i32) (param $key_id_len i32)
+      (param $data i32) (param $data_len i32)
+      (param $sig i32) (param $sig_len i32)
+      (result (; 0 or 1 ;) i32)))
+
+  ;; send_response sends the HTTP response with a given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "hmac" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (global $alg i32 (i32.const 0))
+  (data (i32.const 0) "HS256")
+  (global $alg_len i32 (i32.const 5))
+
+  (global $key_id i32 (i32.const 8))
+  (data (i32.const 8) "k1")
+  (global $key_id_len i32 (i32.const 2))
+
+  ;; data is where the config is written, which is the data to sign.
+  (global $data i32 (i32.const 1024))
+  (global $data_limit i32 (i32.const 1024))
+
+  ;; mac is where the MAC is written, followed by the verification result.
+  (global $mac i32 (i32.const 2048))
+  (global $mac_limit i32 (i32.const 64))
+
+  ;; handle signs the config with the key "k1", then verifies the MAC. It
+  ;; responds with the MAC followed by one byte, which is one if it was
+  ;; valid.
+  (func $handle (export "handle")
+    (local $data_len i32)
+    (local $mac_len i32)
+
+    (local.set $data_len
+      (call $get_config (global.get $data) (global.get $data_limit)))
+
+    (local.set $mac_len
+      (call $hmac
+        (global.get $alg) (global.get $alg_len)
+        (global.get $key_id) (global.get $key_id_len)
+        (global.get $data) (local.get $data_len)
+        (global.get $mac) (global.get $mac_limit)))
+
+    (i32.store8
+      (i32.add (global.get $mac) (local.get $mac_len))
+      (call $verify_signature
+        (global.get $alg) (global.get $alg_len)
+        (global.get $key_id) (global.get $key_id_len)
+        (global.get $data) (local.get $data_len)
+        (global.get $mac) (local.get $mac_len)))
+
+    (call $send_response
+      (i32.const 200)
+      (global.get $mac)
+      (i32.add (local.get $mac_len) (i32.const 1))))
+)
@@ -0,0 +1,41 @@
+;; env responds with the environment variables and arguments of the guest, as
+;; read via WASI, such as by os.Getenv in Go. Each is NUL terminated.
+(module $env
+  (import "wasi_snapshot_preview1" "environ_sizes_get"
+    (func $environ_sizes_get (param $count i32) (param $size i32) (result i32)))
+  (import "wasi_snapshot_preview1" "environ_get"
+    (func $environ_get (param $environ i32) (param $environ_buf i32) (result i32)))
+  (import "wasi_snapshot_preview1" "args_sizes_get"
+    (func $args_sizes_get (param $count i32) (param $size i32) (result i32)))
+  (import "wasi_snapshot_preview1" "args_get"
+    (func $args_get (param $argv i32) (param $argv_buf i32) (result i32)))
+
+  ;; send_response sends the response with the body read from memory.
+  (import "http-handler" "send_response"
+    (func $send_response (param $status_code i32) (param $body i32) (param $body_len i32)))
+
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; Sizes are written at offset 0, pointers at 64, and values at 1024.
+  (global $buf i32 (i32.const 1024))
+
+  (func $handle (export "handle")
+    (local $environ_len i32)
+    (local $args_len i32)
+
+    (drop (call $environ_sizes_get (i32.const 0) (i32.const 4)))
+    (local.set $environ_len (i32.load (i32.const 4)))
+    (drop (call $environ_get (i32.const 64) (global.get $buf)))
+
+    ;; Write the arguments after the environment variables.
+    (drop (call $args_sizes_get (i32.const 8) (i32.const 12)))
+    (local.set $args_len (i32.load (i32.const 12)))
+    (drop (call $args_get
+      (i32.const 512)
+      (i32.add (global.get $buf) (local.get $environ_len))))
+
+    (call $send_response
+      (i32.const 200)
+      (global.get $buf)
+      (i32.add (local.get $environ_len) (local.get $args_len))))
+)
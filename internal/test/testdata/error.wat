@@ -0,0 +1,20 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how a handler declares an error, leaving the response to the host.
+(module $error
+
+  ;; set_error sends the response the host mapped to an error code.
+  (import "http-handler" "set_error"
+    (func $set_error (param $code i32) (param $code_len i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "set_error" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (global $code i32 (i32.const 0))
+  (data (i32.const 0) "token-expired")
+  (global $code_len i32 (i32.const 13))
+
+  ;; handle declares the "token-expired" error.
+  (func $handle (export "handle")
+    (call $set_error (global.get $code) (global.get $code_len)))
+)
@@ -0,0 +1,30 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; handler notifies host code of an event, without waiting for it to be
+;; handled.
+(module $event
+
+  ;; emit_event queues an event, with a topic and payload, for the host.
+  (import "http-handler" "emit_event"
+    (func $emit_event
+      (param $topic i32) (param $topic_len i32)
+      (param $payload i32) (param $payload_len i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "emit_event" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (global $topic i32 (i32.const 0))
+  (data (i32.const 0) "user.blocked")
+  (global $topic_len i32 (i32.const 12))
+
+  (global $payload i32 (i32.const 16))
+  (data (i32.const 16) "{\"user\":\"alice\"}")
+  (global $payload_len i32 (i32.const 16))
+
+  ;; handle_request emits an event, then continues to the next handler.
+  (func $handle_request (export "handle_request") (result (; continue ;) i32)
+    (call $emit_event
+      (global.get $topic) (global.get $topic_len)
+      (global.get $payload) (global.get $payload_len))
+    (i32.const 1))
+)
@@ -0,0 +1,55 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how a handler evaluates an expression configured by the host.
+(module $extract
+
+  ;; extract evaluates the expression with the given name and writes the value
+  ;; to memory if it exists and isn't larger than the buffer size limit. The
+  ;; result is`1<<32|value_len` or zero if there is no value.
+  (import "http-handler" "extract"
+    (func $extract
+      (param $name i32) (param $name_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; 0 or 1 << 32| value_len ;) i64)))
+
+  ;; send_response sends the HTTP response with a given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "extract" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; buf is an arbitrary area to write data
+  (global $buf i32 (i32.const 1024))
+  (global $buf_limit i32 (i32.const 1024))
+
+  (global $user i32 (i32.const 0))
+  (data (i32.const 0) "user")
+  (global $user_len i32 (i32.const 4))
+
+  ;; handle responds with the value of the expression named "user", or 404 if
+  ;; there is none.
+  (func $handle (export "handle")
+    (local $result i64)
+
+    (local.set $result
+      (call $extract
+        (global.get $user)
+        (global.get $user_len)
+        (global.get $buf)
+        (global.get $buf_limit)))
+
+    (if (i64.eqz (local.get $result))
+      (then
+        (call $send_response (i32.const 404) (i32.const 0) (i32.const 0))
+        (return)))
+
+    (call $send_response
+      (i32.const 200)
+      (global.get $buf)
+      (i32.wrap_i64 (local.get $result))))
+)
@@ -0,0 +1,71 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how a guest enables features to rewrite the response of the next handler.
+(module $features
+
+  ;; enable_features enables features for the current request. The result is
+  ;; the features now enabled.
+  (import "http-handler" "enable_features"
+    (func $enable_features
+      (param $features i64)
+      (result (; enabled_features ;) i64)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; read_response_body writes the buffered response body to memory if it
+  ;; isn't larger than the buffer size limit. The result is the length of the
+  ;; body in bytes.
+  (import "http-handler" "read_response_body"
+    (func $read_response_body
+      (param $buf i32) (param $buf_limit i32)
+      (result (; body_len ;) i32)))
+
+  ;; send_response sends the HTTP response with a given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32) (param $body i32) (param $body_len i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "read_response_body" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; feature_buffer_response is handler.FeatureBufferResponse.
+  (global $feature_buffer_response i64 (i64.const 2))
+
+  ;; prefix precedes the response body.
+  (global $prefix i32 (i32.const 1023))
+  (data (i32.const 1023) "<")
+
+  ;; body is where the response body is read.
+  (global $body i32 (i32.const 1024))
+  (global $body_limit i32 (i32.const 1024))
+
+  ;; handle buffers the response of the next handler, and replaces it with
+  ;; its body in angle brackets. Unsupported features are requested, too,
+  ;; which the host must not enable.
+  (func $handle (export "handle")
+    (local $body_len i32)
+
+    (if (i64.ne
+          (call $enable_features
+            (i64.or (global.get $feature_buffer_response) (i64.const 0x10000000000)))
+          (global.get $feature_buffer_response))
+      (then (unreachable)))
+
+    (call $next)
+
+    (local.set $body_len
+      (call $read_response_body (global.get $body) (global.get $body_limit)))
+    (if (i32.gt_u (local.get $body_len) (i32.sub (global.get $body_limit) (i32.const 1)))
+      (then (unreachable)))
+
+    (i32.store8
+      (i32.add (global.get $body) (local.get $body_len))
+      (i32.const 62 (; '>' ;)))
+
+    (call $send_response
+      (i32.const 200)
+      (global.get $prefix)
+      (i32.add (local.get $body_len) (i32.const 2))))
+)
@@ -0,0 +1,96 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; handler enforces an upload policy without parsing the request body.
+(module $form
+
+  ;; get_form_value writes the first value of a form field to memory if it
+  ;; exists and isn't larger than the buffer size limit. The result is
+  ;; `1<<32|value_len` or zero if the field doesn't exist.
+  (import "http-handler" "get_form_value"
+    (func $get_form_value
+      (param $name i32) (param $name_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; 0 or 1 << 32| value_len ;) i64)))
+
+  ;; get_uploaded_file_info writes information about an uploaded file to
+  ;; memory if it exists and isn't larger than the buffer size limit. The
+  ;; result is `1<<32|info_len` or zero if there's no such file.
+  (import "http-handler" "get_uploaded_file_info"
+    (func $get_uploaded_file_info
+      (param $name i32) (param $name_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; 0 or 1 << 32| info_len ;) i64)))
+
+  ;; set_response_header sets a response header.
+  (import "http-handler" "set_response_header"
+    (func $set_response_header
+      (param $name i32) (param $name_len i32)
+      (param $value i32) (param $value_len i32)))
+
+  ;; send_response sends a response with the status code and body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "get_form_value" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; buf is an arbitrary area to write data
+  (global $buf i32 (i32.const 1024))
+  (global $buf_limit i32 (i32.const 1024))
+
+  (global $user i32 (i32.const 0))
+  (data (i32.const 0) "user")
+  (global $user_len i32 (i32.const 4))
+
+  (global $user_header i32 (i32.const 8))
+  (data (i32.const 8) "X-User")
+  (global $user_header_len i32 (i32.const 6))
+
+  (global $file i32 (i32.const 16))
+  (data (i32.const 16) "file")
+  (global $file_len i32 (i32.const 4))
+
+  ;; handle copies the "user" field to the "X-User" response header. If the
+  ;; request uploaded a "file", this rejects it, with the encoded file info as
+  ;; the response body. Otherwise, it dispatches to the next handler.
+  (func $handle (export "handle")
+    (local $result i64)
+
+    (local.set $result
+      (call $get_form_value
+        (global.get $user)
+        (global.get $user_len)
+        (global.get $buf)
+        (global.get $buf_limit)))
+
+    (if (i64.ne (local.get $result) (i64.const 0))
+      (then
+        (call $set_response_header
+          (global.get $user_header)
+          (global.get $user_header_len)
+          (global.get $buf)
+          (i32.wrap_i64 (local.get $result)))))
+
+    (local.set $result
+      (call $get_uploaded_file_info
+        (global.get $file)
+        (global.get $file_len)
+        (global.get $buf)
+        (global.get $buf_limit)))
+
+    (if (i64.ne (local.get $result) (i64.const 0))
+      (then
+        (call $send_response
+          (i32.const 413)
+          (global.get $buf)
+          (i32.wrap_i64 (local.get $result)))
+        (return)))
+
+    (call $next))
+)
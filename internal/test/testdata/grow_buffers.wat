@@ -0,0 +1,97 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; handler avoids retrying reads of large values, by letting the host
+;; allocate memory for them.
+(module $grow_buffers
+
+  ;; enable_features tries to enable the given features and returns the
+  ;; features now enabled.
+  (import "http-handler" "enable_features"
+    (func $enable_features (param $enable_features i64) (result i64)))
+
+  ;; get_buffer_limit returns the buffer size the host recommends.
+  (import "http-handler" "get_buffer_limit"
+    (func $get_buffer_limit (result i32)))
+
+  ;; read_request_header writes a header value to memory if it exists and
+  ;; isn't larger than the buffer size limit. The result is`1<<32|value_len`
+  ;; or zero if the header doesn't exist. When grow_buffers is enabled and the
+  ;; value is larger, the result is `ptr<<32|value_len` instead.
+  (import "http-handler" "read_request_header"
+    (func $read_request_header
+      (param $name i32) (param $name_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; 0 or ptr << 32| value_len ;) i64)))
+
+  ;; send_response sends the HTTP response with a given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "read_request_header" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; define the header we want to read.
+  (global $name i32 (i32.const 0))
+  (data (i32.const 0) "X-Long")
+  (global $name_len i32 (i32.const 6))
+
+  ;; buf is a small buffer, so that long values don't fit.
+  (global $buf i32 (i32.const 16))
+  (global $buf_limit i32 (i32.const 4))
+
+  ;; feature_grow_buffers is handler.FeatureGrowBuffers.
+  (global $feature_grow_buffers i64 (i64.const 64))
+
+  ;; heap is the next memory offset malloc returns.
+  (global $heap (mut i32) (i32.const 1024))
+
+  ;; malloc is a bump allocator, which never frees.
+  (func $malloc (export "malloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local.set $ptr (global.get $heap))
+    (global.set $heap (i32.add (global.get $heap) (local.get $size)))
+    (local.get $ptr))
+
+  ;; handle responds with the value of the header "X-Long", or 413 if it
+  ;; doesn't fit the buffer and grow_buffers isn't enabled. It traps unless
+  ;; the host recommends a 4096 byte buffer.
+  (func $handle (export "handle")
+    (local $enabled i64)
+    (local $result i64)
+    (local $len i32)
+
+    (if (i32.ne (call $get_buffer_limit) (i32.const 4096))
+      (then unreachable))
+
+    (local.set $enabled
+      (call $enable_features (global.get $feature_grow_buffers)))
+
+    (local.set $result
+      (call $read_request_header
+        (global.get $name) (global.get $name_len)
+        (global.get $buf) (global.get $buf_limit)))
+    (local.set $len (i32.wrap_i64 (local.get $result)))
+
+    ;; the value fit the buffer.
+    (if (i32.le_u (local.get $len) (global.get $buf_limit))
+      (then
+        (call $send_response
+          (i32.const 200) (global.get $buf) (local.get $len))
+        (return)))
+
+    ;; the value didn't fit, and the host didn't allocate memory for it.
+    (if (i64.eqz (i64.and (local.get $enabled) (global.get $feature_grow_buffers)))
+      (then
+        (call $send_response (i32.const 413) (i32.const 0) (i32.const 0))
+        (return)))
+
+    ;; the host allocated memory for the value.
+    (call $send_response
+      (i32.const 200)
+      (i32.wrap_i64 (i64.shr_u (local.get $result) (i64.const 32)))
+      (local.get $len)))
+)
@@ -0,0 +1,58 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; handler fails without trapping, by returning an error code from "handle".
+(module $handle_error
+
+  ;; read_request_header writes a header value to memory if it exists and
+  ;; isn't larger than the buffer size limit.
+  (import "http-handler" "read_request_header"
+    (func $read_request_header
+      (param $name i32) (param $name_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; 0 or 1 << 32| value_len ;) i64)))
+
+  ;; log writes a message to the host console.
+  (import "http-handler" "log" (func $log (param $ptr i32) (param $size i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "read_request_header" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (global $deny i32 (i32.const 0))
+  (data (i32.const 0) "X-Deny")
+  (global $deny_len i32 (i32.const 6))
+
+  (global $fail i32 (i32.const 8))
+  (data (i32.const 8) "X-Fail")
+  (global $fail_len i32 (i32.const 6))
+
+  (global $response i32 (i32.const 16))
+  (data (i32.const 16) "response")
+  (global $response_len i32 (i32.const 8))
+
+  ;; has_header returns one if the request header exists.
+  (func $has_header (param $name i32) (param $name_len i32) (result i32)
+    (i64.ne
+      (call $read_request_header
+        (local.get $name) (local.get $name_len) (i32.const 0) (i32.const 0))
+      (i64.const 0)))
+
+  ;; handle aborts with 401 Unauthorized when the request has the header
+  ;; "X-Deny", fails with an error code when it has "X-Fail", or otherwise
+  ;; invokes the next handler.
+  (func $handle (export "handle") (result (; error code ;) i32)
+    (if (call $has_header (global.get $deny) (global.get $deny_len))
+      (then (return (i32.const 401))))
+
+    (if (call $has_header (global.get $fail) (global.get $fail_len))
+      (then (return (i32.const 7))))
+
+    (call $next)
+    (i32.const 0))
+
+  ;; handle_response logs after the request was handled.
+  (func $handle_response (export "handle_response")
+    (call $log (global.get $response) (global.get $response_len)))
+)
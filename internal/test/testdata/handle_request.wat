@@ -0,0 +1,58 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; "handle_request" export, which lets the host invoke the next handler, and
+;; "handle_response", which the host calls after it.
+(module $handle_request
+  ;; read_request_header writes a header value to memory if it exists and
+  ;; isn't larger than the buffer size limit.
+  (import "http-handler" "read_request_header"
+    (func $read_request_header
+      (param $name i32) (param $name_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; 0 or 1 << 32| value_len ;) i64)))
+
+  ;; send_response sends the HTTP response with a given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  ;; log writes a message to the host console.
+  (import "http-handler" "log" (func $log (param $ptr i32) (param $size i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "log" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; load constants into memory used for log.
+  (global $request i32 (i32.const 0))
+  (data (i32.const 0) "request")
+  (global $request_len i32 (i32.const 7))
+
+  (global $response i32 (i32.const 8))
+  (data (i32.const 8) "response")
+  (global $response_len i32 (i32.const 8))
+
+  ;; stop is the name of the request header which stops the request.
+  (global $stop i32 (i32.const 16))
+  (data (i32.const 16) "X-Stop")
+  (global $stop_len i32 (i32.const 6))
+
+  ;; handle_request logs, then continues to the "next" handler unless the
+  ;; request has the header "X-Stop", in which case it responds 403.
+  (func $handle_request (export "handle_request") (result (; continue ;) i32)
+    (call $log (global.get $request) (global.get $request_len))
+
+    (if (i64.eqz (call $read_request_header
+          (global.get $stop) (global.get $stop_len)
+          (i32.const 0) (i32.const 0)))
+      (then (return (i32.const 1))))
+
+    (call $send_response (i32.const 403) (i32.const 0) (i32.const 0))
+    (i32.const 0))
+
+  ;; handle_response logs after the request was handled.
+  (func $handle_response (export "handle_response")
+    (call $log (global.get $response) (global.get $response_len)))
+)
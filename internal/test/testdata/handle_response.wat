@@ -0,0 +1,31 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; optional "handle_response" export, which the host calls after "handle".
+(module $handle_response
+  ;; log writes a message to the host console.
+  (import "http-handler" "log" (func $log (param $ptr i32) (param $size i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "log" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; load constants into memory used for log.
+  (global $request i32 (i32.const 0))
+  (data (i32.const 0) "request")
+  (global $request_len i32 (i32.const 7))
+
+  (global $response i32 (i32.const 8))
+  (data (i32.const 8) "response")
+  (global $response_len i32 (i32.const 8))
+
+  ;; handle logs before dispatching to the "next" handler.
+  (func $handle (export "handle")
+    (call $log (global.get $request) (global.get $request_len))
+    (call $next))
+
+  ;; handle_response logs after the request was handled.
+  (func $handle_response (export "handle_response")
+    (call $log (global.get $response) (global.get $response_len)))
+)
@@ -0,0 +1,38 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; handler reads and writes many headers in one call each.
+(module $headers
+
+  ;; read_request_headers writes all request headers to memory if they aren't
+  ;; larger than the buffer size limit. The result is their encoded length.
+  (import "http-handler" "read_request_headers"
+    (func $read_request_headers (param $buf i32) (param $buf_limit i32) (result i32)))
+
+  ;; write_response_headers sets response headers from entries read from
+  ;; memory, in the same encoding as read_request_headers.
+  (import "http-handler" "write_response_headers"
+    (func $write_response_headers (param $buf i32) (param $buf_len i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "read_request_headers" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; buf is an arbitrary area to write data.
+  (global $buf i32 (i32.const 1024))
+  (global $buf_limit i32 (i32.const 16384))
+
+  ;; handle echoes all request headers as response headers, if they fit in
+  ;; the buffer, then invokes the next handler.
+  (func $handle (export "handle")
+    (local $len i32)
+
+    (local.set $len
+      (call $read_request_headers (global.get $buf) (global.get $buf_limit)))
+
+    (if (i32.le_u (local.get $len) (global.get $buf_limit))
+      (then (call $write_response_headers (global.get $buf) (local.get $len))))
+
+    (call $next))
+)
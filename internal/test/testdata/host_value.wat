@@ -0,0 +1,60 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; handler exchanges values with code of the host.
+(module $host_value
+
+  ;; get_host_value writes a value passed by the host to memory if it exists
+  ;; and isn't larger than the buffer size limit. The result is
+  ;; `1<<32|value_len` or zero if the value doesn't exist.
+  (import "http-handler" "get_host_value"
+    (func $get_host_value
+      (param $key i32) (param $key_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; 0 or 1 << 32| value_len ;) i64)))
+
+  ;; set_host_value sets a value code of the host after the guest can read.
+  (import "http-handler" "set_host_value"
+    (func $set_host_value
+      (param $key i32) (param $key_len i32)
+      (param $value i32) (param $value_len i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "get_host_value" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; buf is an arbitrary area to write data
+  (global $buf i32 (i32.const 1024))
+  (global $buf_limit i32 (i32.const 1024))
+
+  (global $session i32 (i32.const 0))
+  (data (i32.const 0) "session")
+  (global $session_len i32 (i32.const 7))
+
+  (global $user i32 (i32.const 8))
+  (data (i32.const 8) "user")
+  (global $user_len i32 (i32.const 4))
+
+  ;; handle copies the host value "session" to the host value "user", if it
+  ;; exists, then dispatches to the next handler.
+  (func $handle (export "handle")
+    (local $result i64)
+
+    (local.set $result
+      (call $get_host_value
+        (global.get $session)
+        (global.get $session_len)
+        (global.get $buf)
+        (global.get $buf_limit)))
+
+    (if (i64.ne (local.get $result) (i64.const 0))
+      (then
+        (call $set_host_value
+          (global.get $user)
+          (global.get $user_len)
+          (global.get $buf)
+          (i32.wrap_i64 (local.get $result)))))
+
+    (call $next))
+)
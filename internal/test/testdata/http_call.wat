@@ -0,0 +1,104 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how a handler calls an external service and relays its response.
+(module $http_call
+
+  ;; get_config writes the guest config to memory if it isn't larger than the
+  ;; buffer size limit. The result is the length of the config in bytes.
+  (import "http-handler" "get_config"
+    (func $get_config
+      (param $buf i32) (param $buf_limit i32)
+      (result (; config_len ;) i32)))
+
+  ;; http_call sends an HTTP request and writes the response body to memory,
+  ;; up to the buffer size limit. The result is `status_code<<32|body_len`, or
+  ;; zero if the call failed.
+  (import "http-handler" "http_call"
+    (func $http_call
+      (param $method i32) (param $method_len i32)
+      (param $url i32) (param $url_len i32)
+      (param $headers i32) (param $headers_len i32)
+      (param $body i32) (param $body_len i32)
+      (param $timeout_millis i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; 0 or status_code << 32| body_len ;) i64)))
+
+  ;; send_response sends the current response with the given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "http_call" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (global $post i32 (i32.const 0))
+  (data (i32.const 0) "POST")
+  (global $post_len i32 (i32.const 4))
+
+  (global $headers i32 (i32.const 16))
+  (data (i32.const 16) "X-Guest: http_call\r\n")
+  (global $headers_len i32 (i32.const 20))
+
+  (global $ping i32 (i32.const 48))
+  (data (i32.const 48) "ping")
+  (global $ping_len i32 (i32.const 4))
+
+  ;; url is where the guest config, the URL to call, is written.
+  (global $url i32 (i32.const 1024))
+  (global $url_limit i32 (i32.const 1024))
+
+  ;; buf is where the response body is written. The first call only reads
+  ;; its first bytes, up to $small_limit.
+  (global $buf i32 (i32.const 2048))
+  (global $buf_limit i32 (i32.const 1024))
+  (global $small_limit i32 (i32.const 4))
+
+  ;; call POSTs "ping" to the URL, writing the response body to $buf up to
+  ;; the limit.
+  (func $call (param $url_len i32) (param $limit i32) (result i64)
+    (call $http_call
+      (global.get $post) (global.get $post_len)
+      (global.get $url) (local.get $url_len)
+      (global.get $headers) (global.get $headers_len)
+      (global.get $ping) (global.get $ping_len)
+      (i32.const 0) (; default timeout ;)
+      (global.get $buf) (local.get $limit)))
+
+  ;; handle POSTs "ping" to the URL in the guest config, and responds with
+  ;; the status code and body of its response, or 502 Bad Gateway if the
+  ;; call failed. When the body is larger than the first buffer, this calls
+  ;; again with a larger one, which the host answers without a second POST.
+  (func $handle (export "handle")
+    (local $url_len i32)
+    (local $result i64)
+    (local $body_len i32)
+
+    (local.set $url_len
+      (call $get_config (global.get $url) (global.get $url_limit)))
+
+    (local.set $result
+      (call $call (local.get $url_len) (global.get $small_limit)))
+
+    (if (i64.eqz (local.get $result))
+      (then
+        (call $send_response (i32.const 502) (i32.const 0) (i32.const 0))
+        (return)))
+
+    (if (i32.gt_u (i32.wrap_i64 (local.get $result)) (global.get $small_limit))
+      (then
+        (local.set $result
+          (call $call (local.get $url_len) (global.get $buf_limit)))))
+
+    ;; Relay no more of the body than was written.
+    (local.set $body_len (i32.wrap_i64 (local.get $result)))
+    (if (i32.gt_u (local.get $body_len) (global.get $buf_limit))
+      (then (local.set $body_len (global.get $buf_limit))))
+
+    (call $send_response
+      (i32.wrap_i64 (i64.shr_u (local.get $result) (i64.const 32)))
+      (global.get $buf)
+      (local.get $body_len)))
+)
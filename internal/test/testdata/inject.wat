@@ -0,0 +1,46 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how a handler suppresses headers the host injects into all responses.
+(module $inject
+
+  ;; read_request_header writes a header value to memory if it exists and isn't
+  ;; larger than the buffer size limit. The result is`1<<32|value_len` or zero
+  ;; if the header doesn't exist.
+  (import "http-handler" "read_request_header"
+    (func $read_request_header
+      (param $name i32) (param $name_len i32)
+      (param $buf i32) (param $value_limit i32)
+      (result (; 0 or 1 << 32| value_len ;) i64)))
+
+  ;; suppress_injected_headers prevents the host from injecting headers into
+  ;; the current response.
+  (import "http-handler" "suppress_injected_headers"
+    (func $suppress_injected_headers))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "read_request_header" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; buf is an arbitrary area to write data
+  (global $buf i32 (i32.const 1024))
+
+  (global $internal_name i32 (i32.const 0))
+  (data (i32.const 0) "X-Internal")
+  (global $internal_name_len i32 (i32.const 10))
+
+  ;; handle suppresses injected headers when the "X-Internal" request header
+  ;; exists. Then, it dispatches to the next handler.
+  (func $handle (export "handle")
+    (if (i64.ne
+          (call $read_request_header
+            (global.get $internal_name)
+            (global.get $internal_name_len)
+            (global.get $buf)
+            (i32.const 0))
+          (i64.const 0))
+      (then (call $suppress_injected_headers)))
+
+    (call $next))
+)
@@ -0,0 +1,94 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; guest handles errors setting headers, instead of trapping, by importing
+;; get_last_error.
+(module $last_error
+
+  ;; set_response_header sets a response header from a name and value read
+  ;; from memory
+  (import "http-handler" "set_response_header"
+    (func $set_response_header
+      (param $name i32) (param $name_len i32)
+      (param $value i32) (param $value_len i32)))
+
+  ;; send_response sends the HTTP response with a given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32) (param $body i32) (param $body_len i32)))
+
+  ;; get_last_error returns and clears the error of the last host function
+  ;; which failed, or zero.
+  (import "http-handler" "get_last_error"
+    (func $get_last_error (result i32)))
+
+  ;; log writes a message to the host console.
+  (import "http-handler" "log" (func $log (param $ptr i32) (param $size i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "send_response" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (global $bad_name i32 (i32.const 0))
+  (data (i32.const 0) "X Bad")
+  (global $bad_name_len i32 (i32.const 5))
+
+  (global $name i32 (i32.const 8))
+  (data (i32.const 8) "X-Quota")
+  (global $name_len i32 (i32.const 7))
+
+  (global $bad_value i32 (i32.const 16))
+  (data (i32.const 16) "\n")
+  (global $bad_value_len i32 (i32.const 1))
+
+  (global $value i32 (i32.const 24))
+  (data (i32.const 24) "1")
+  (global $value_len i32 (i32.const 1))
+
+  (global $other_name i32 (i32.const 48))
+  (data (i32.const 48) "X-Other")
+  (global $other_name_len i32 (i32.const 7))
+
+  (global $body i32 (i32.const 32))
+  (data (i32.const 32) "hello world")
+  (global $body_len i32 (i32.const 11))
+
+  ;; $errnos are the digits of each error, logged after the response.
+  (global $errnos i32 (i32.const 64))
+  (global $errnos_len (mut i32) (i32.const 0))
+
+  ;; $check appends the last error as a digit to $errnos.
+  (func $check
+    (i32.store8
+      (i32.add (global.get $errnos) (global.get $errnos_len))
+      (i32.add (i32.const 48 (; '0' ;)) (call $get_last_error)))
+    (global.set $errnos_len (i32.add (global.get $errnos_len) (i32.const 1))))
+
+  ;; handle sets invalid headers, then the same valid header twice and
+  ;; another, and sends a body, checking the error after each. Then, it logs the errors.
+  (func $handle (export "handle")
+    (call $set_response_header
+      (global.get $bad_name) (global.get $bad_name_len)
+      (global.get $value) (global.get $value_len))
+    (call $check)
+    (call $set_response_header
+      (global.get $name) (global.get $name_len)
+      (global.get $bad_value) (global.get $bad_value_len))
+    (call $check)
+    (call $set_response_header
+      (global.get $name) (global.get $name_len)
+      (global.get $value) (global.get $value_len))
+    (call $check)
+    (call $set_response_header
+      (global.get $name) (global.get $name_len)
+      (global.get $value) (global.get $value_len))
+    (call $check)
+    (call $set_response_header
+      (global.get $other_name) (global.get $other_name_len)
+      (global.get $value) (global.get $value_len))
+    (call $check)
+    (call $send_response
+      (i32.const 200)
+      (global.get $body) (global.get $body_len))
+    (call $check)
+    (call $log (global.get $errnos) (global.get $errnos_len)))
+)
@@ -0,0 +1,64 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; handler reads its configuration once on "init", reports its health on
+;; "ping", and flushes state on "shutdown".
+(module $lifecycle
+
+  ;; get_config writes the guest config to memory if it isn't larger than the
+  ;; buffer size limit. The result is the length of the config in bytes.
+  (import "http-handler" "get_config"
+    (func $get_config
+      (param $buf i32) (param $buf_limit i32)
+      (result (; config_len ;) i32)))
+
+  ;; log logs a message to the host's logs.
+  (import "http-handler" "log" (func $log
+    (param $buf i32) (param $buf_limit i32)))
+
+  ;; send_response sends the HTTP response with a given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "get_config" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (global $shutdown i32 (i32.const 0))
+  (data (i32.const 0) "shutdown")
+  (global $shutdown_len i32 (i32.const 8))
+
+  (global $ping i32 (i32.const 16))
+  (data (i32.const 16) "ping")
+  (global $ping_len i32 (i32.const 4))
+
+  ;; config is where "init" reads the guest config.
+  (global $config i32 (i32.const 1024))
+  (global $config_limit i32 (i32.const 1024))
+  (global $config_len (mut i32) (i32.const 0))
+
+  ;; init reads the guest config once, so that each request doesn't.
+  (func $init (export "init")
+    (global.set $config_len
+      (call $get_config (global.get $config) (global.get $config_limit)))
+    (call $log (global.get $config) (global.get $config_len)))
+
+  ;; handle responds with the guest config read by "init".
+  (func $handle (export "handle")
+    (call $send_response
+      (i32.const 200)
+      (global.get $config)
+      (global.get $config_len)))
+
+  ;; ping logs, and reports unhealthy by trapping when there's no config.
+  (func $ping (export "ping")
+    (call $log (global.get $ping) (global.get $ping_len))
+    (if (i32.eqz (global.get $config_len))
+      (then unreachable)))
+
+  ;; shutdown logs, as if flushing state.
+  (func $shutdown (export "shutdown")
+    (call $log (global.get $shutdown) (global.get $shutdown_len)))
+)
@@ -0,0 +1,78 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; handler reads metadata of the listener and connection the request was
+;; received on, such as to apply a different policy per port.
+(module $listener
+
+  ;; get_listener_addr writes the listener address to memory if it isn't
+  ;; larger than the buffer size limit. The result is the length of the
+  ;; address.
+  (import "http-handler" "get_listener_addr"
+    (func $get_listener_addr
+      (param $buf i32) (param $buf_limit i32)
+      (result (; addr_len ;) i32)))
+
+  ;; is_unix_socket returns one if the listener is a unix domain socket.
+  (import "http-handler" "is_unix_socket"
+    (func $is_unix_socket (result (; unix ;) i32)))
+
+  ;; get_proxy_source_addr writes the PROXY protocol source address to memory
+  ;; if it exists and isn't larger than the buffer size limit. The result is
+  ;; `1<<32|addr_len` or zero if there is no source.
+  (import "http-handler" "get_proxy_source_addr"
+    (func $get_proxy_source_addr
+      (param $buf i32) (param $buf_limit i32)
+      (result (; 0 or 1 << 32| addr_len ;) i64)))
+
+  ;; send_response sends the HTTP response with a given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "get_listener_addr" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; buf is an arbitrary area to write data
+  (global $buf i32 (i32.const 1024))
+  (global $buf_limit i32 (i32.const 1024))
+
+  ;; handle_request responds with the listener address, "unix" or "tcp", and
+  ;; the PROXY protocol source address, separated by commas.
+  (func $handle_request (export "handle_request") (result (; continue ;) i32)
+    (local $len i32)
+    (local $pos i32)
+
+    (local.set $len
+      (call $get_listener_addr (global.get $buf) (global.get $buf_limit)))
+
+    (i32.store8 (i32.add (global.get $buf) (local.get $len)) (i32.const 44 (; ',' ;)))
+    (local.set $len (i32.add (local.get $len) (i32.const 1)))
+
+    (local.set $pos (i32.add (global.get $buf) (local.get $len)))
+    (if (call $is_unix_socket)
+      (then
+        (i32.store (local.get $pos) (i32.const 0x78696e75 (; "unix" ;)))
+        (i32.store8 (i32.add (local.get $pos) (i32.const 4)) (i32.const 44 (; ',' ;)))
+        (local.set $len (i32.add (local.get $len) (i32.const 5))))
+      (else
+        (i32.store (local.get $pos) (i32.const 0x2c706374 (; "tcp," ;)))
+        (local.set $len (i32.add (local.get $len) (i32.const 4)))))
+
+    ;; Append the PROXY protocol source, if any.
+    (local.set $len
+      (i32.add
+        (local.get $len)
+        (i32.wrap_i64
+          (call $get_proxy_source_addr
+            (i32.add (global.get $buf) (local.get $len))
+            (i32.sub (global.get $buf_limit) (local.get $len))))))
+
+    (call $send_response
+      (i32.const 200)
+      (global.get $buf)
+      (local.get $len))
+    (i32.const 0))
+)
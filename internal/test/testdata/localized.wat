@@ -0,0 +1,26 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how a handler sends a denial localized by the host.
+(module $localized
+
+  ;; send_localized_response sends the message with the given key, in the
+  ;; language the client prefers.
+  (import "http-handler" "send_localized_response"
+    (func $send_localized_response
+      (param $status_code i32)
+      (param $key i32) (param $key_len i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "send_localized_response" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (global $denied i32 (i32.const 0))
+  (data (i32.const 0) "denied")
+  (global $denied_len i32 (i32.const 6))
+
+  ;; handle denies all requests.
+  (func $handle (export "handle")
+    (call $send_localized_response
+      (i32.const 403)
+      (global.get $denied)
+      (global.get $denied_len)))
+)
@@ -0,0 +1,21 @@
+;; memory_import imports its memory, as some toolchains do with flags like
+;; "--import-memory", instead of exporting it.
+(module $memory_import
+
+  ;; send_response sends the HTTP response with a given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  ;; memory is provided by the host, as the guest doesn't define one.
+  (import "env" "memory" (memory 1))
+
+  (data (i32.const 0) "hello")
+
+  ;; handle responds with the body written to the imported memory.
+  (func $handle (export "handle")
+    (call $send_response (i32.const 200) (i32.const 0) (i32.const 5)))
+)
@@ -0,0 +1,19 @@
+;; memory_name exports its memory under a name other than "memory".
+(module $memory_name
+
+  ;; send_response sends the HTTP response with a given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  (memory (export "mem") 1 (; 1 page==64KB ;))
+
+  (data (i32.const 0) "hello")
+
+  ;; handle responds with the body written to the memory.
+  (func $handle (export "handle")
+    (call $send_response (i32.const 200) (i32.const 0) (i32.const 5)))
+)
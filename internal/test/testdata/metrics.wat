@@ -0,0 +1,53 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; handler publishes metrics to the host.
+(module $metrics
+
+  ;; define_metric defines a metric of the kind and name, returning its ID.
+  (import "http-handler" "define_metric"
+    (func $define_metric
+      (param $kind i32)
+      (param $name i32) (param $name_len i32)
+      (result (; metric_id ;) i32)))
+
+  ;; increment_metric adds to the value of a counter or gauge.
+  (import "http-handler" "increment_metric"
+    (func $increment_metric (param $metric_id i32) (param $delta i64)))
+
+  ;; record_metric sets a gauge, or records a value of a histogram.
+  (import "http-handler" "record_metric"
+    (func $record_metric (param $metric_id i32) (param $value i64)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "define_metric" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (global $requests i32 (i32.const 0))
+  (data (i32.const 0) "guest_requests_total")
+  (global $requests_len i32 (i32.const 20))
+
+  (global $size i32 (i32.const 32))
+  (data (i32.const 32) "guest_size")
+  (global $size_len i32 (i32.const 10))
+
+  ;; handle counts the request, and records 42 in a histogram, then
+  ;; dispatches to the next handler.
+  (func $handle (export "handle")
+    (call $increment_metric
+      (call $define_metric
+        (i32.const 0 (; counter ;))
+        (global.get $requests)
+        (global.get $requests_len))
+      (i64.const 1))
+
+    (call $record_metric
+      (call $define_metric
+        (i32.const 2 (; histogram ;))
+        (global.get $size)
+        (global.get $size_len))
+      (i64.const 42))
+
+    (call $next))
+)
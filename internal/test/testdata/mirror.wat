@@ -0,0 +1,30 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how a handler mirrors requests to a destination configured by the host.
+(module $mirror
+
+  ;; mirror_request sends a copy of the current request to the destination
+  ;; with the given name. The result is one if it was mirrored.
+  (import "http-handler" "mirror_request"
+    (func $mirror_request
+      (param $name i32) (param $name_len i32)
+      (result (; mirrored ;) i32)))
+
+  ;; next dispatches control to the next handler on the host.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "mirror_request" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; define the destination name configured by the host.
+  (global $shadow i32 (i32.const 0))
+  (data (i32.const 0) "shadow")
+  (global $shadow_len i32 (i32.const 6))
+
+  ;; handle mirrors the request and then dispatches to the next handler.
+  (func $handle (export "handle")
+    (drop (call $mirror_request
+      (global.get $shadow)
+      (global.get $shadow_len)))
+    (call $next))
+)
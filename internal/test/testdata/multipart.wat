@@ -0,0 +1,60 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how a handler inspects one part of a multipart request.
+(module $multipart
+
+  ;; read_multipart_part writes the content of a multipart part to memory if
+  ;; it exists and isn't larger than the buffer size limit. The result is
+  ;; `1<<32|part_len` or zero if the part doesn't exist.
+  (import "http-handler" "read_multipart_part"
+    (func $read_multipart_part
+      (param $name i32) (param $name_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; 0 or 1 << 32| part_len ;) i64)))
+
+  ;; set_response_header sets a response header.
+  (import "http-handler" "set_response_header"
+    (func $set_response_header
+      (param $name i32) (param $name_len i32)
+      (param $value i32) (param $value_len i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "read_multipart_part" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; buf is an arbitrary area to write data
+  (global $buf i32 (i32.const 1024))
+  (global $buf_limit i32 (i32.const 1024))
+
+  (global $meta i32 (i32.const 0))
+  (data (i32.const 0) "meta")
+  (global $meta_len i32 (i32.const 4))
+
+  (global $meta_header i32 (i32.const 8))
+  (data (i32.const 8) "X-Meta")
+  (global $meta_header_len i32 (i32.const 6))
+
+  ;; handle copies the "meta" part to the "X-Meta" response header, then
+  ;; dispatches to the next handler.
+  (func $handle (export "handle")
+    (local $result i64)
+
+    (local.set $result
+      (call $read_multipart_part
+        (global.get $meta)
+        (global.get $meta_len)
+        (global.get $buf)
+        (global.get $buf_limit)))
+
+    (if (i64.ne (local.get $result) (i64.const 0))
+      (then
+        (call $set_response_header
+          (global.get $meta_header)
+          (global.get $meta_header_len)
+          (global.get $buf)
+          (i32.wrap_i64 (local.get $result)))))
+
+    (call $next))
+)
@@ -0,0 +1,3 @@
+;; no_memory doesn't export memory, so host functions can't read or write it.
+(module $no_memory
+  (func $handle (export "handle")))
@@ -0,0 +1,25 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; handler sets the timeout and retry policy of the next handler, such as a
+;; reverse proxy.
+(module $policy
+
+  ;; set_timeout_ms limits how long the host waits for the next handler.
+  (import "http-handler" "set_timeout_ms"
+    (func $set_timeout_ms (param $timeout_ms i32)))
+
+  ;; set_retry_policy sets how the host retries the request.
+  (import "http-handler" "set_retry_policy"
+    (func $set_retry_policy (param $attempts i32) (param $backoff_ms i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory".
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; handle allows the next handler 50ms and up to 3 attempts, 1ms apart.
+  (func $handle (export "handle")
+    (call $set_timeout_ms (i32.const 50))
+    (call $set_retry_policy (i32.const 3) (i32.const 1))
+    (call $next))
+)
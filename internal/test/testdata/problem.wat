@@ -0,0 +1,33 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how a handler sends a structured denial.
+(module $problem
+
+  ;; send_problem sends a problem+json response from a status code, problem
+  ;; code and detail.
+  (import "http-handler" "send_problem"
+    (func $send_problem
+      (param $status_code i32)
+      (param $code i32) (param $code_len i32)
+      (param $detail i32) (param $detail_len i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "send_problem" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (global $code i32 (i32.const 0))
+  (data (i32.const 0) "ip-denied")
+  (global $code_len i32 (i32.const 9))
+
+  (global $detail i32 (i32.const 16))
+  (data (i32.const 16) "ip is not allowed")
+  (global $detail_len i32 (i32.const 17))
+
+  ;; handle denies all requests.
+  (func $handle (export "handle")
+    (call $send_problem
+      (i32.const 403)
+      (global.get $code)
+      (global.get $code_len)
+      (global.get $detail)
+      (global.get $detail_len)))
+)
@@ -0,0 +1,77 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how handlers pass values to each other and the host via properties.
+(module $property
+
+  ;; get_property writes a property value to memory if it exists and isn't
+  ;; larger than the buffer size limit. The result is`1<<32|value_len` or zero
+  ;; if the property doesn't exist.
+  (import "http-handler" "get_property"
+    (func $get_property
+      (param $name i32) (param $name_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; 0 or 1 << 32| value_len ;) i64)))
+
+  ;; set_property sets a property of the current request.
+  (import "http-handler" "set_property"
+    (func $set_property
+      (param $name i32) (param $name_len i32)
+      (param $value i32) (param $value_len i32)))
+
+  ;; set_response_header sets a response header.
+  (import "http-handler" "set_response_header"
+    (func $set_response_header
+      (param $name i32) (param $name_len i32)
+      (param $value i32) (param $value_len i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "get_property" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; buf is an arbitrary area to write data
+  (global $buf i32 (i32.const 1024))
+  (global $buf_limit i32 (i32.const 1024))
+
+  (global $user i32 (i32.const 0))
+  (data (i32.const 0) "user")
+  (global $user_len i32 (i32.const 4))
+
+  (global $alice i32 (i32.const 8))
+  (data (i32.const 8) "alice")
+  (global $alice_len i32 (i32.const 5))
+
+  (global $user_header i32 (i32.const 16))
+  (data (i32.const 16) "X-User")
+  (global $user_header_len i32 (i32.const 6))
+
+  ;; handle copies the "user" property to the "X-User" response header, or
+  ;; sets it to "alice" if it doesn't exist. Then, it dispatches to the next
+  ;; handler.
+  (func $handle (export "handle")
+    (local $result i64)
+
+    (local.set $result
+      (call $get_property
+        (global.get $user)
+        (global.get $user_len)
+        (global.get $buf)
+        (global.get $buf_limit)))
+
+    (if (i64.eqz (local.get $result))
+      (then
+        (call $set_property
+          (global.get $user)
+          (global.get $user_len)
+          (global.get $alice)
+          (global.get $alice_len)))
+      (else
+        (call $set_response_header
+          (global.get $user_header)
+          (global.get $user_header_len)
+          (global.get $buf)
+          (i32.wrap_i64 (local.get $result)))))
+
+    (call $next))
+)
@@ -0,0 +1,47 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; guest can vary behavior by the protocol version of the request, by echoing
+;; it in a response header.
+(module $protocol
+
+  ;; get_protocol_version writes the protocol version of the request to
+  ;; memory if it isn't larger than the buffer size limit. The result is its
+  ;; length in bytes. Ex. "HTTP/2.0"
+  (import "http-handler" "get_protocol_version"
+    (func $get_protocol_version (param $buf i32) (param $buf_limit i32) (result i32)))
+
+  ;; set_response_header sets a response header from a name and value read
+  ;; from memory
+  (import "http-handler" "set_response_header"
+    (func $set_response_header
+      (param $name i32) (param $name_len i32)
+      (param $value i32) (param $value_len i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "get_protocol_version" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; define the header name to echo the protocol version in.
+  (global $name i32 (i32.const 0))
+  (data (i32.const 0) "X-Protocol")
+  (global $name_len i32 (i32.const 10))
+
+  ;; buf is an arbitrary area to write data.
+  (global $buf i32 (i32.const 1024))
+  (global $buf_limit i32 (i32.const 64))
+
+  ;; handle echoes the protocol version, then invokes the next handler.
+  (func $handle (export "handle")
+    (local $len i32)
+
+    (local.set $len
+      (call $get_protocol_version (global.get $buf) (global.get $buf_limit)))
+
+    (call $set_response_header
+      (global.get $name) (global.get $name_len)
+      (global.get $buf) (local.get $len))
+
+    (call $next))
+)
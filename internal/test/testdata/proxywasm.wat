@@ -0,0 +1,162 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; guest written for the proxy-wasm ABI, such as an Envoy filter, blocks
+;; requests with a local response, and otherwise adds a response header. It
+;; also logs part of the request body, if long enough.
+(module $proxywasm
+
+  ;; proxy_log logs a message at a level.
+  (import "env" "proxy_log" (func $proxy_log
+    (param $level i32) (param $message i32) (param $message_size i32)
+    (result (; status ;) i32)))
+
+  ;; proxy_get_header_map_value writes the pointer and size of a header value
+  ;; allocated with proxy_on_memory_allocate, or returns NotFound (1).
+  (import "env" "proxy_get_header_map_value" (func $proxy_get_header_map_value
+    (param $map_type i32) (param $key i32) (param $key_size i32)
+    (param $return_value i32) (param $return_value_size i32)
+    (result (; status ;) i32)))
+
+  ;; proxy_add_header_map_value adds a header value.
+  (import "env" "proxy_add_header_map_value" (func $proxy_add_header_map_value
+    (param $map_type i32) (param $key i32) (param $key_size i32)
+    (param $value i32) (param $value_size i32)
+    (result (; status ;) i32)))
+
+  ;; proxy_get_buffer_bytes writes the pointer and size of a buffer allocated
+  ;; with proxy_on_memory_allocate.
+  (import "env" "proxy_get_buffer_bytes" (func $proxy_get_buffer_bytes
+    (param $buffer_type i32) (param $start i32) (param $max_size i32)
+    (param $return_buffer i32) (param $return_buffer_size i32)
+    (result (; status ;) i32)))
+
+  ;; proxy_send_local_response responds instead of the upstream.
+  (import "env" "proxy_send_local_response" (func $proxy_send_local_response
+    (param $status_code i32)
+    (param $status_code_details i32) (param $status_code_details_size i32)
+    (param $body i32) (param $body_size i32)
+    (param $headers i32) (param $headers_size i32)
+    (param $grpc_status i32)
+    (result (; status ;) i32)))
+
+  ;; proxy-wasm guests export "memory", which the host reads and writes.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; $return_ptr and $return_size are where the host writes results.
+  (global $return_ptr i32 (i32.const 0))
+  (global $return_size i32 (i32.const 4))
+
+  (global $block_header i32 (i32.const 16))
+  (data (i32.const 16) "x-block")
+  (global $block_header_len i32 (i32.const 7))
+
+  (global $blocked_body i32 (i32.const 32))
+  (data (i32.const 32) "blocked")
+  (global $blocked_body_len i32 (i32.const 7))
+
+  ;; $blocked_headers is the serialized header "x-reason: policy".
+  (global $blocked_headers i32 (i32.const 48))
+  (data (i32.const 48) "\01\00\00\00\08\00\00\00\06\00\00\00x-reason\00policy\00")
+  (global $blocked_headers_len i32 (i32.const 28))
+
+  (global $response_header i32 (i32.const 80))
+  (data (i32.const 80) "x-proxy-wasm")
+  (global $response_header_len i32 (i32.const 12))
+
+  (global $response_value i32 (i32.const 96))
+  (data (i32.const 96) "1")
+  (global $response_value_len i32 (i32.const 1))
+
+  (global $done i32 (i32.const 112))
+  (data (i32.const 112) "done")
+  (global $done_len i32 (i32.const 4))
+
+  ;; $heap is the next address proxy_on_memory_allocate returns.
+  (global $heap (mut i32) (i32.const 1024))
+
+  ;; proxy_abi_version_0_2_1 declares the ABI version.
+  (func (export "proxy_abi_version_0_2_1"))
+
+  ;; proxy_on_memory_allocate is a bump allocator, as memory is never freed.
+  (func (export "proxy_on_memory_allocate") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local.set $ptr (global.get $heap))
+    (global.set $heap (i32.add (global.get $heap) (local.get $size)))
+    (local.get $ptr))
+
+  (func (export "proxy_on_context_create") (param $context_id i32) (param $parent_id i32))
+
+  ;; proxy_on_configure logs the plugin configuration, accepting it.
+  (func (export "proxy_on_configure")
+    (param $root_id i32) (param $config_size i32) (result i32)
+    (drop (call $proxy_get_buffer_bytes
+      (i32.const 7 (; PluginConfiguration ;))
+      (i32.const 0)
+      (local.get $config_size)
+      (global.get $return_ptr)
+      (global.get $return_size)))
+    (drop (call $proxy_log
+      (i32.const 2 (; info ;))
+      (i32.load (global.get $return_ptr))
+      (i32.load (global.get $return_size))))
+    (i32.const 1 (; true ;)))
+
+  ;; proxy_on_request_headers sends a local response when the request
+  ;; includes the header "x-block", otherwise continues.
+  (func (export "proxy_on_request_headers")
+    (param $context_id i32) (param $num_headers i32) (param $end_of_stream i32)
+    (result (; action ;) i32)
+    (if (i32.eq (call $proxy_get_header_map_value
+          (i32.const 0 (; HttpRequestHeaders ;))
+          (global.get $block_header)
+          (global.get $block_header_len)
+          (global.get $return_ptr)
+          (global.get $return_size))
+        (i32.const 1 (; NotFound ;)))
+      (then (return (i32.const 0 (; Continue ;)))))
+
+    (drop (call $proxy_send_local_response
+      (i32.const 403)
+      (i32.const 0) (i32.const 0)
+      (global.get $blocked_body)
+      (global.get $blocked_body_len)
+      (global.get $blocked_headers)
+      (global.get $blocked_headers_len)
+      (i32.const -1)))
+    (i32.const 1 (; Pause ;)))
+
+  ;; proxy_on_request_body logs 5 bytes of the request body at offset 7,
+  ;; which fails unless the body is at least that long.
+  (func (export "proxy_on_request_body")
+    (param $context_id i32) (param $body_size i32) (param $end_of_stream i32)
+    (result (; action ;) i32)
+    (if (i32.eqz (call $proxy_get_buffer_bytes
+          (i32.const 0 (; HttpRequestBody ;))
+          (i32.const 7)
+          (i32.const 5)
+          (global.get $return_ptr)
+          (global.get $return_size)))
+      (then (drop (call $proxy_log
+        (i32.const 2 (; info ;))
+        (i32.load (global.get $return_ptr))
+        (i32.load (global.get $return_size))))))
+    (i32.const 0 (; Continue ;)))
+
+  ;; proxy_on_response_headers adds a response header.
+  (func (export "proxy_on_response_headers")
+    (param $context_id i32) (param $num_headers i32) (param $end_of_stream i32)
+    (result (; action ;) i32)
+    (drop (call $proxy_add_header_map_value
+      (i32.const 2 (; HttpResponseHeaders ;))
+      (global.get $response_header)
+      (global.get $response_header_len)
+      (global.get $response_value)
+      (global.get $response_value_len)))
+    (i32.const 0 (; Continue ;)))
+
+  ;; proxy_on_log logs when each request is done.
+  (func (export "proxy_on_log") (param $context_id i32)
+    (drop (call $proxy_log
+      (i32.const 2 (; info ;))
+      (global.get $done)
+      (global.get $done_len))))
+)
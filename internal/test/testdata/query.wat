@@ -0,0 +1,70 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how a handler reads and rewrites query parameters.
+(module $query
+
+  ;; get_query_value writes a query parameter value to memory if it exists and
+  ;; isn't larger than the buffer size limit. The result is`1<<32|value_len`
+  ;; or zero if the parameter doesn't exist.
+  (import "http-handler" "get_query_value"
+    (func $get_query_value
+      (param $name i32) (param $name_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; 0 or 1 << 32| value_len ;) i64)))
+
+  ;; set_query_value sets a query parameter, replacing existing values.
+  (import "http-handler" "set_query_value"
+    (func $set_query_value
+      (param $name i32) (param $name_len i32)
+      (param $value i32) (param $value_len i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "get_query_value" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; buf is an arbitrary area to write data
+  (global $buf i32 (i32.const 1024))
+  (global $buf_limit i32 (i32.const 1024))
+
+  (global $page i32 (i32.const 0))
+  (data (i32.const 0) "page")
+  (global $page_len i32 (i32.const 4))
+
+  (global $seen i32 (i32.const 8))
+  (data (i32.const 8) "seen")
+  (global $seen_len i32 (i32.const 4))
+
+  (global $default_page i32 (i32.const 16))
+  (data (i32.const 16) "1")
+  (global $default_page_len i32 (i32.const 1))
+
+  ;; handle copies the "page" parameter to "seen", or defaults "page" to 1 if
+  ;; it doesn't exist. Then, it dispatches to the next handler.
+  (func $handle (export "handle")
+    (local $result i64)
+
+    (local.set $result
+      (call $get_query_value
+        (global.get $page)
+        (global.get $page_len)
+        (global.get $buf)
+        (global.get $buf_limit)))
+
+    (if (i64.eqz (local.get $result))
+      (then
+        (call $set_query_value
+          (global.get $page)
+          (global.get $page_len)
+          (global.get $default_page)
+          (global.get $default_page_len)))
+      (else
+        (call $set_query_value
+          (global.get $seen)
+          (global.get $seen_len)
+          (global.get $buf)
+          (i32.wrap_i64 (local.get $result)))))
+
+    (call $next))
+)
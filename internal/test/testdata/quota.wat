@@ -0,0 +1,45 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how quotas limit what a guest can produce.
+(module $quota
+
+  ;; set_response_header sets a response header from a name and value read
+  ;; from memory
+  (import "http-handler" "set_response_header"
+    (func $set_response_header
+      (param $name i32) (param $name_len i32)
+      (param $value i32) (param $value_len i32)))
+
+  ;; send_response sends the HTTP response with a given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32) (param $body i32) (param $body_len i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "send_response" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (global $name i32 (i32.const 0))
+  (data (i32.const 0) "X-Quota")
+  (global $name_len i32 (i32.const 7))
+
+  (global $value i32 (i32.const 16))
+  (data (i32.const 16) "1")
+  (global $value_len i32 (i32.const 1))
+
+  (global $body i32 (i32.const 32))
+  (data (i32.const 32) "hello world")
+  (global $body_len i32 (i32.const 11))
+
+  ;; handle sets the same response header twice, then sends a body.
+  (func $handle (export "handle")
+    (call $set_response_header
+      (global.get $name) (global.get $name_len)
+      (global.get $value) (global.get $value_len))
+    (call $set_response_header
+      (global.get $name) (global.get $name_len)
+      (global.get $value) (global.get $value_len))
+    (call $send_response
+      (i32.const 200)
+      (global.get $body) (global.get $body_len)))
+)
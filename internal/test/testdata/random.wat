@@ -0,0 +1,32 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how a handler generates random bytes, without WASI.
+(module $random
+
+  ;; get_random fills memory with cryptographically secure random bytes.
+  (import "http-handler" "get_random"
+    (func $get_random (param $buf i32) (param $buf_len i32)))
+
+  ;; send_response sends the current response with the given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "get_random" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; nonce is where the random bytes are written.
+  (global $nonce i32 (i32.const 0))
+  (global $nonce_len i32 (i32.const 16))
+
+  ;; handle responds with 16 random bytes.
+  (func $handle (export "handle")
+    (call $get_random (global.get $nonce) (global.get $nonce_len))
+    (call $send_response
+      (i32.const 200)
+      (global.get $nonce)
+      (global.get $nonce_len)))
+)
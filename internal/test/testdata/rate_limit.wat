@@ -0,0 +1,56 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; handler limits the rate of requests, using the config as the key.
+(module $rate_limit
+
+  ;; get_config writes the guest config to memory if it isn't larger than the
+  ;; buffer size limit. The result is the length of the config in bytes.
+  (import "http-handler" "get_config"
+    (func $get_config
+      (param $buf i32) (param $buf_limit i32)
+      (result (; config_len ;) i32)))
+
+  ;; rate_limit_check takes tokens from the rate limit of a key. The result
+  ;; is one if the request is allowed.
+  (import "http-handler" "rate_limit_check"
+    (func $rate_limit_check
+      (param $key i32) (param $key_len i32)
+      (param $tokens i32)
+      (result (; 0 or 1 ;) i32)))
+
+  ;; send_response sends the HTTP response with a given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "get_config" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; buf is an arbitrary area to write data
+  (global $buf i32 (i32.const 1024))
+  (global $buf_limit i32 (i32.const 1024))
+
+  ;; handle takes one token from the rate limit of the config, responding
+  ;; with 429 Too Many Requests if limited. Otherwise, it dispatches to the
+  ;; next handler.
+  (func $handle (export "handle")
+    (local $key_len i32)
+
+    (local.set $key_len
+      (call $get_config (global.get $buf) (global.get $buf_limit)))
+
+    (if (i32.eqz
+          (call $rate_limit_check
+            (global.get $buf) (local.get $key_len) (i32.const 1)))
+      (then
+        (call $send_response (i32.const 429) (i32.const 0) (i32.const 0))
+        (return)))
+
+    (call $next))
+)
@@ -0,0 +1,59 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; guest reads request headers as received, such as to verify a signature, and
+;; sets a response header without canonicalizing its name.
+(module $raw_headers
+  ;; read_raw_request_headers writes the request headers as received to
+  ;; memory if they aren't larger than the buffer size limit.
+  (import "http-handler" "read_raw_request_headers"
+    (func $read_raw_request_headers
+      (param $buf i32) (param $buf_limit i32)
+      (result (; headers_len ;) i32)))
+
+  ;; set_raw_response_header sets a response header without canonicalizing
+  ;; its name.
+  (import "http-handler" "set_raw_response_header"
+    (func $set_raw_response_header
+      (param $name i32) (param $name_len i32)
+      (param $value i32) (param $value_len i32)))
+
+  ;; send_response sends the HTTP response with a given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "log" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (global $name i32 (i32.const 0))
+  (data (i32.const 0) "x-amz-date")
+  (global $name_len i32 (i32.const 10))
+
+  (global $value i32 (i32.const 16))
+  (data (i32.const 16) "20130524T000000Z")
+  (global $value_len i32 (i32.const 16))
+
+  ;; buf is where headers are read to.
+  (global $buf i32 (i32.const 64))
+  (global $buf_limit i32 (i32.const 4096))
+
+  ;; handle_request responds with the encoded raw request headers, or 404 if
+  ;; the host didn't preserve them.
+  (func $handle_request (export "handle_request") (result (; continue ;) i32)
+    (local $len i32)
+
+    (call $set_raw_response_header
+      (global.get $name) (global.get $name_len)
+      (global.get $value) (global.get $value_len))
+
+    (local.set $len (call $read_raw_request_headers
+      (global.get $buf) (global.get $buf_limit)))
+    (if (i32.eqz (local.get $len))
+      (then (call $send_response (i32.const 404) (i32.const 0) (i32.const 0)))
+      (else (call $send_response (i32.const 200)
+        (global.get $buf) (local.get $len))))
+    (i32.const 0))
+)
@@ -0,0 +1,71 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how a handler reads the request body into memory it allocates.
+(module $read_body
+
+  ;; read_request_body reads the request body into memory allocated by
+  ;; "malloc". The result is `ptr<<32|body_len` or zero if the body is empty.
+  (import "http-handler" "read_request_body"
+    (func $read_request_body (result (; ptr<<32|body_len ;) i64)))
+
+  ;; send_response sends the HTTP response with a given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "read_request_body" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; heap is the offset of the next allocation.
+  (global $heap_base i32 (i32.const 1024))
+  (global $heap (mut i32) (i32.const 1024))
+
+  ;; malloc is a bump allocator, which grows memory as needed. Allocations
+  ;; are freed at the start of each request.
+  (func $malloc (export "malloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local $end i32)
+
+    (local.set $ptr (global.get $heap))
+    (local.set $end (i32.add (local.get $ptr) (local.get $size)))
+
+    (if (i32.gt_u (local.get $end) (i32.shl (memory.size) (i32.const 16)))
+      (then
+        (if (i32.eq
+              (memory.grow
+                (i32.sub
+                  (i32.shr_u (i32.add (local.get $end) (i32.const 65535)) (i32.const 16))
+                  (memory.size)))
+              (i32.const -1))
+          (then (unreachable)))))
+
+    (global.set $heap (local.get $end))
+    (local.get $ptr))
+
+  ;; handle reads the body and rejects it unless it starts with 'h'.
+  ;; Otherwise, it reuses the memory of the body, which mustn't change what
+  ;; the next handler reads, and dispatches to it.
+  (func $handle (export "handle")
+    (local $result i64)
+
+    (global.set $heap (global.get $heap_base))
+    (local.set $result (call $read_request_body))
+
+    (if (i32.ne
+          (i32.load8_u (i32.wrap_i64 (i64.shr_u (local.get $result) (i64.const 32))))
+          (i32.const 104 (; 'h' ;)))
+      (then
+        (call $send_response (i32.const 400) (i32.const 0) (i32.const 0))
+        (return)))
+
+    (i32.store8
+      (i32.wrap_i64 (i64.shr_u (local.get $result) (i64.const 32)))
+      (i32.const 106 (; 'j' ;)))
+    (call $next))
+)
@@ -0,0 +1,55 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how a guest reads the response of the next handler, without enabling
+;; features.
+(module $read_response_body
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; read_response_body writes the buffered response body to memory if it
+  ;; isn't larger than the buffer size limit. The result is the length of the
+  ;; body in bytes.
+  (import "http-handler" "read_response_body"
+    (func $read_response_body
+      (param $buf i32) (param $buf_limit i32)
+      (result (; body_len ;) i32)))
+
+  ;; send_response sends the HTTP response with a given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32) (param $body i32) (param $body_len i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "read_response_body" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; prefix precedes the response body.
+  (global $prefix i32 (i32.const 1023))
+  (data (i32.const 1023) "<")
+
+  ;; body is where the response body is read.
+  (global $body i32 (i32.const 1024))
+  (global $body_limit i32 (i32.const 1024))
+
+  ;; handle replaces the response of the next handler with its body in angle
+  ;; brackets. The host buffers it as this imports "read_response_body".
+  (func $handle (export "handle")
+    (local $body_len i32)
+
+    (call $next)
+
+    (local.set $body_len
+      (call $read_response_body (global.get $body) (global.get $body_limit)))
+    (if (i32.gt_u (local.get $body_len) (i32.sub (global.get $body_limit) (i32.const 1)))
+      (then (unreachable)))
+
+    (i32.store8
+      (i32.add (global.get $body) (local.get $body_len))
+      (i32.const 62 (; '>' ;)))
+
+    (call $send_response
+      (i32.const 200)
+      (global.get $prefix)
+      (i32.add (local.get $body_len) (i32.const 2))))
+)
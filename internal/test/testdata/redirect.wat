@@ -0,0 +1,25 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; handler redirects the client, such as to a login page.
+(module $redirect
+
+  ;; send_redirect redirects the client to a location read from memory.
+  (import "http-handler" "send_redirect"
+    (func $send_redirect
+      (param $status_code i32)
+      (param $location i32) (param $location_len i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "send_redirect" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (global $location i32 (i32.const 0))
+  (data (i32.const 0) "/login?next=%2F")
+  (global $location_len i32 (i32.const 15))
+
+  ;; handle redirects all requests to the login page.
+  (func $handle (export "handle")
+    (call $send_redirect
+      (i32.const 303)
+      (global.get $location)
+      (global.get $location_len)))
+)
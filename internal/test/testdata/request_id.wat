@@ -0,0 +1,31 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; guest correlates its logs with the request, by logging its ID.
+(module $request_id
+
+  ;; get_request_id writes the ID the host assigned the request to memory if
+  ;; it isn't larger than the buffer size limit. The result is its length in
+  ;; bytes, or zero if none.
+  (import "http-handler" "get_request_id"
+    (func $get_request_id (param $buf i32) (param $buf_limit i32) (result i32)))
+
+  ;; log writes a message to the host console.
+  (import "http-handler" "log"
+    (func $log (param $ptr i32) (param $size i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory".
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; buf is an arbitrary area to write data.
+  (global $buf i32 (i32.const 1024))
+  (global $buf_limit i32 (i32.const 256))
+
+  ;; handle logs the request ID, then invokes the next handler.
+  (func $handle (export "handle")
+    (call $log
+      (global.get $buf)
+      (call $get_request_id (global.get $buf) (global.get $buf_limit)))
+    (call $next))
+)
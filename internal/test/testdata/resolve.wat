@@ -0,0 +1,59 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how a handler resolves a host name, without a resolver of its own.
+(module $resolve
+
+  ;; get_config writes the guest config to memory if it isn't larger than the
+  ;; buffer size limit. The result is the length of the config in bytes.
+  (import "http-handler" "get_config"
+    (func $get_config
+      (param $buf i32) (param $buf_limit i32)
+      (result (; config_len ;) i32)))
+
+  ;; resolve writes the NUL-terminated addresses of a host name to memory if
+  ;; they aren't larger than the buffer size limit. The result is
+  ;; `1<<32|value_len` or zero if the name couldn't be resolved.
+  (import "http-handler" "resolve"
+    (func $resolve
+      (param $name i32) (param $name_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; 0 or 1 << 32| value_len ;) i64)))
+
+  ;; send_response sends the current response with the given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "resolve" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; name is where the guest config, the host name to resolve, is written.
+  (global $name i32 (i32.const 0))
+  (global $name_limit i32 (i32.const 1024))
+
+  ;; buf is where the addresses are written.
+  (global $buf i32 (i32.const 1024))
+  (global $buf_limit i32 (i32.const 1024))
+
+  ;; handle responds with the addresses of the host name in the guest config,
+  ;; or 404 Not Found if it couldn't be resolved.
+  (func $handle (export "handle")
+    (local $result i64)
+
+    (local.set $result
+      (call $resolve
+        (global.get $name)
+        (call $get_config (global.get $name) (global.get $name_limit))
+        (global.get $buf)
+        (global.get $buf_limit)))
+
+    (if (i64.eqz (local.get $result))
+      (then (call $send_response (i32.const 404) (i32.const 0) (i32.const 0)))
+      (else (call $send_response
+        (i32.const 200)
+        (global.get $buf)
+        (i32.wrap_i64 (local.get $result))))))
+)
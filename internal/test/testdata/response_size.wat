@@ -0,0 +1,31 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; handler records the size of the response, such as for access logs.
+(module $response_size
+
+  ;; get_response_body_size returns the count of bytes of the response body
+  ;; written so far.
+  (import "http-handler" "get_response_body_size"
+    (func $get_response_body_size (result i64)))
+
+  ;; is_response_committed returns one if the response was committed.
+  (import "http-handler" "is_response_committed"
+    (func $is_response_committed (result i32)))
+
+  ;; log writes a message to the host console.
+  (import "http-handler" "log"
+    (func $log (param $ptr i32) (param $size i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory".
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; handle calls the next handler, then logs the little-endian size of the
+  ;; response body followed by one byte, which is one if it was committed.
+  (func $handle (export "handle")
+    (call $next)
+    (i64.store (i32.const 0) (call $get_response_body_size))
+    (i32.store8 (i32.const 8) (call $is_response_committed))
+    (call $log (i32.const 0) (i32.const 9)))
+)
@@ -0,0 +1,38 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; guest reads the route pattern of the request, such as to key metrics on it
+;; instead of the path.
+(module $route
+
+  ;; get_route writes the route pattern the request matched to memory if it
+  ;; isn't larger than the buffer size limit. The result is its length in
+  ;; bytes, or zero if unknown. Ex. "GET /users/{id}"
+  (import "http-handler" "get_route"
+    (func $get_route (param $buf i32) (param $buf_limit i32) (result i32)))
+
+  ;; log writes a message to the host console.
+  (import "http-handler" "log"
+    (func $log (param $ptr i32) (param $size i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory".
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; buf is an arbitrary area to write data.
+  (global $buf i32 (i32.const 1024))
+  (global $buf_limit i32 (i32.const 256))
+
+  ;; log_route logs the route, which is empty if unknown.
+  (func $log_route
+    (call $log
+      (global.get $buf)
+      (call $get_route (global.get $buf) (global.get $buf_limit))))
+
+  ;; handle logs the route before and after invoking the next handler, as a
+  ;; router in the next handler only matches the route when invoked.
+  (func $handle (export "handle")
+    (call $log_route)
+    (call $next)
+    (call $log_route))
+)
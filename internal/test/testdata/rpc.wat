@@ -0,0 +1,62 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; guest reads the service and method of a gRPC, gRPC-Web or Connect request,
+;; by echoing them in response headers.
+(module $rpc
+
+  ;; get_rpc_service writes the service of the RPC to memory if it isn't
+  ;; larger than the buffer size limit. The result is its length in bytes, or
+  ;; zero if the request isn't an RPC. Ex. "acme.v1.Greeter"
+  (import "http-handler" "get_rpc_service"
+    (func $get_rpc_service (param $buf i32) (param $buf_limit i32) (result i32)))
+
+  ;; get_rpc_method is like get_rpc_service, except it writes the method.
+  ;; Ex. "SayHello"
+  (import "http-handler" "get_rpc_method"
+    (func $get_rpc_method (param $buf i32) (param $buf_limit i32) (result i32)))
+
+  ;; set_response_header sets a response header from a name and value read
+  ;; from memory
+  (import "http-handler" "set_response_header"
+    (func $set_response_header
+      (param $name i32) (param $name_len i32)
+      (param $value i32) (param $value_len i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory".
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; define the header names to echo the service and method in.
+  (global $service_name i32 (i32.const 0))
+  (data (i32.const 0) "X-RPC-Service")
+  (global $service_name_len i32 (i32.const 13))
+
+  (global $method_name i32 (i32.const 16))
+  (data (i32.const 16) "X-RPC-Method")
+  (global $method_name_len i32 (i32.const 12))
+
+  ;; buf is an arbitrary area to write data.
+  (global $buf i32 (i32.const 1024))
+  (global $buf_limit i32 (i32.const 256))
+
+  ;; handle echoes the service and method, if any, then invokes the next
+  ;; handler.
+  (func $handle (export "handle")
+    (local $len i32)
+
+    (local.set $len
+      (call $get_rpc_service (global.get $buf) (global.get $buf_limit)))
+    (if (i32.eqz (local.get $len)) (then (call $next) (return)))
+    (call $set_response_header
+      (global.get $service_name) (global.get $service_name_len)
+      (global.get $buf) (local.get $len))
+
+    (local.set $len
+      (call $get_rpc_method (global.get $buf) (global.get $buf_limit)))
+    (call $set_response_header
+      (global.get $method_name) (global.get $method_name_len)
+      (global.get $buf) (local.get $len))
+
+    (call $next))
+)
@@ -0,0 +1,57 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how chained handlers pass data to each other via the scratch area.
+(module $scratch
+
+  ;; read_scratch writes the scratch area to memory if it isn't larger than
+  ;; the buffer size limit. The result is the length of the scratch area.
+  (import "http-handler" "read_scratch"
+    (func $read_scratch
+      (param $buf i32) (param $buf_limit i32)
+      (result (; scratch_len ;) i32)))
+
+  ;; write_scratch replaces the scratch area with bytes read from memory.
+  (import "http-handler" "write_scratch"
+    (func $write_scratch (param $buf i32) (param $buf_len i32)))
+
+  ;; send_response sends the HTTP response with a given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "read_scratch" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; buf is an arbitrary area to write data
+  (global $buf i32 (i32.const 1024))
+  (global $buf_limit i32 (i32.const 1024))
+
+  (global $hello i32 (i32.const 0))
+  (data (i32.const 0) "hello")
+  (global $hello_len i32 (i32.const 5))
+
+  ;; handle responds with the scratch area if a previous handler wrote it.
+  ;; Otherwise, it writes "hello" for the next handler.
+  (func $handle (export "handle")
+    (local $scratch_len i32)
+
+    (local.set $scratch_len
+      (call $read_scratch (global.get $buf) (global.get $buf_limit)))
+
+    (if (i32.eqz (local.get $scratch_len))
+      (then
+        (call $write_scratch (global.get $hello) (global.get $hello_len))
+        (call $next)
+        (return)))
+
+    (call $send_response
+      (i32.const 200)
+      (global.get $buf)
+      (local.get $scratch_len)))
+)
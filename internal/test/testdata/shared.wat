@@ -0,0 +1,50 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how a handler de-duplicates requests via the store shared across requests.
+(module $shared
+
+  ;; cas_shared sets the value of a shared key if its current value equals
+  ;; the expected one. The result is one if the value was set.
+  (import "http-handler" "cas_shared"
+    (func $cas_shared
+      (param $key i32) (param $key_len i32)
+      (param $old i32) (param $old_len i32)
+      (param $value i32) (param $value_len i32)
+      (param $ttl_millis i32)
+      (result (; 0 or 1 ;) i32)))
+
+  ;; send_response sends the current response with the given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "cas_shared" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (global $seen i32 (i32.const 0))
+  (data (i32.const 0) "shared.seen")
+  (global $seen_len i32 (i32.const 11))
+
+  (global $one i32 (i32.const 16))
+  (data (i32.const 16) "1")
+  (global $one_len i32 (i32.const 1))
+
+  ;; handle dispatches to the next handler the first time it is called. After
+  ;; that, it responds with 429 Too Many Requests.
+  (func $handle (export "handle")
+    (if (call $cas_shared
+          (global.get $seen)
+          (global.get $seen_len)
+          (i32.const 0) (i32.const 0) (; expect the key doesn't exist ;)
+          (global.get $one)
+          (global.get $one_len)
+          (i32.const 0) (; doesn't expire ;))
+      (then (call $next))
+      (else (call $send_response (i32.const 429) (i32.const 0) (i32.const 0)))))
+)
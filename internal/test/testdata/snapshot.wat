@@ -0,0 +1,57 @@
+;; This example module is written in WebAssembly Text Format to show a
+;; handler whose state after "init" can be snapshot, as it is only in memory
+;; and globals.
+(module $snapshot
+
+  ;; get_config writes the guest config to memory if it isn't larger than the
+  ;; buffer size limit. The result is the length of the config in bytes.
+  (import "http-handler" "get_config"
+    (func $get_config
+      (param $buf i32) (param $buf_limit i32)
+      (result (; config_len ;) i32)))
+
+  ;; log logs a message to the host's logs.
+  (import "http-handler" "log" (func $log
+    (param $buf i32) (param $buf_limit i32)))
+
+  ;; send_response sends the HTTP response with a given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "get_config" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (global $init i32 (i32.const 0))
+  (data (i32.const 0) "init")
+  (global $init_len i32 (i32.const 4))
+
+  ;; config is where "init" reads the guest config. Its length is exported,
+  ;; so that a snapshot includes it.
+  (global $config i32 (i32.const 1024))
+  (global $config_limit i32 (i32.const 1024))
+  (global $config_len (export "config_len") (mut i32) (i32.const 0))
+
+  ;; initialized is set by "init", like state compilers keep in globals which
+  ;; aren't exported. A snapshot must include it.
+  (global $initialized (mut i32) (i32.const 0))
+
+  ;; init logs and reads the guest config once, as if it were expensive.
+  (func $init (export "init")
+    (call $log (global.get $init) (global.get $init_len))
+    (global.set $config_len
+      (call $get_config (global.get $config) (global.get $config_limit)))
+    (global.set $initialized (i32.const 1)))
+
+  ;; handle responds with the guest config read by "init", or 500 if the
+  ;; guest wasn't initialized.
+  (func $handle (export "handle")
+    (call $send_response
+      (select (i32.const 200) (i32.const 500) (global.get $initialized))
+      (global.get $config)
+      (global.get $config_len)))
+)
@@ -0,0 +1,15 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how the host handles a guest that fails to instantiate.
+(module $start_trap
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "log" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; start traps, so the guest can never be instantiated.
+  (func $start (unreachable))
+  (start $start)
+
+  ;; handle is never called, as the guest can't be instantiated.
+  (func $handle (export "handle"))
+)
@@ -0,0 +1,73 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; guest reads and writes persistent state.
+(module $state
+  ;; read_request_header writes a header value to memory if it exists and
+  ;; isn't larger than the buffer size limit.
+  (import "http-handler" "read_request_header"
+    (func $read_request_header
+      (param $name i32) (param $name_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; 0 or 1 << 32| value_len ;) i64)))
+
+  ;; read_state writes the value of a persistent key to memory if it exists
+  ;; and isn't larger than the buffer size limit.
+  (import "http-handler" "read_state"
+    (func $read_state
+      (param $key i32) (param $key_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; 0 or 1 << 32| value_len ;) i64)))
+
+  ;; write_state durably sets the value of a persistent key.
+  (import "http-handler" "write_state"
+    (func $write_state
+      (param $key i32) (param $key_len i32)
+      (param $value i32) (param $value_len i32)))
+
+  ;; send_response sends the HTTP response with a given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "log" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; header is the name of the request header which sets the state.
+  (global $header i32 (i32.const 0))
+  (data (i32.const 0) "X-State")
+  (global $header_len i32 (i32.const 7))
+
+  ;; key is the key of the state.
+  (global $key i32 (i32.const 16))
+  (data (i32.const 16) "state")
+  (global $key_len i32 (i32.const 5))
+
+  ;; buf is where values are read to.
+  (global $buf i32 (i32.const 64))
+  (global $buf_limit i32 (i32.const 64))
+
+  ;; handle_request sets the state to the value of the header "X-State", if
+  ;; present, then responds with the state, or 404 if there is none.
+  (func $handle_request (export "handle_request") (result (; continue ;) i32)
+    (local $result i64)
+
+    (local.set $result (call $read_request_header
+      (global.get $header) (global.get $header_len)
+      (global.get $buf) (global.get $buf_limit)))
+    (if (i64.ne (local.get $result) (i64.const 0))
+      (then (call $write_state
+        (global.get $key) (global.get $key_len)
+        (global.get $buf) (i32.wrap_i64 (local.get $result)))))
+
+    (local.set $result (call $read_state
+      (global.get $key) (global.get $key_len)
+      (global.get $buf) (global.get $buf_limit)))
+    (if (i64.eqz (local.get $result))
+      (then (call $send_response (i32.const 404) (i32.const 0) (i32.const 0)))
+      (else (call $send_response (i32.const 200)
+        (global.get $buf) (i32.wrap_i64 (local.get $result)))))
+    (i32.const 0))
+)
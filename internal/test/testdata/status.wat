@@ -0,0 +1,77 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how a handler overrides the status code, and that sending a response before
+;; "next" short-circuits the next handler.
+(module $status
+
+  ;; read_request_header writes a header value to memory if it exists and isn't
+  ;; larger than the buffer size limit. The result is`1<<32|value_len` or zero
+  ;; if the header doesn't exist.
+  (import "http-handler" "read_request_header"
+    (func $read_request_header
+      (param $name i32) (param $name_len i32)
+      (param $buf i32) (param $value_limit i32)
+      (result (; 0 or 1 << 32| value_len ;) i64)))
+
+  ;; send_response sends the HTTP response with a given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  ;; get_status_code returns the status code of the current response.
+  (import "http-handler" "get_status_code"
+    (func $get_status_code (result (; status_code ;) i32)))
+
+  ;; set_status_code overrides the status code of the current response.
+  (import "http-handler" "set_status_code"
+    (func $set_status_code (param $status_code i32)))
+
+  ;; is_response_committed returns one if the response was committed.
+  (import "http-handler" "is_response_committed"
+    (func $is_response_committed (result (; committed ;) i32)))
+
+  ;; log writes a message to the host console.
+  (import "http-handler" "log" (func $log (param $ptr i32) (param $size i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "read_request_header" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (global $short_circuit_name i32 (i32.const 0))
+  (data (i32.const 0) "Short-Circuit")
+  (global $short_circuit_name_len i32 (i32.const 13))
+
+  (global $committed i32 (i32.const 16))
+  (data (i32.const 16) "committed 418")
+  (global $committed_len i32 (i32.const 13))
+
+  ;; handle sends 403 when the request has a "Short-Circuit" header. Either
+  ;; way, it overrides the status to 418 and dispatches to the next handler.
+  (func $handle (export "handle")
+    (if (i64.ne
+          (call $read_request_header
+            (global.get $short_circuit_name)
+            (global.get $short_circuit_name_len)
+            (i32.const 0)
+            (i32.const 0))
+          (i64.const 0))
+      (then
+        (call $send_response (i32.const 403) (i32.const 0) (i32.const 0))))
+
+    ;; neither of these have an effect if a response was sent.
+    (call $set_status_code (i32.const 418))
+    (call $next))
+
+  ;; handle_response logs if the override was committed.
+  (func $handle_response (export "handle_response")
+    (if (i32.and
+          (i32.eq (call $get_status_code) (i32.const 418))
+          (call $is_response_committed))
+      (then
+        (call $log (global.get $committed) (global.get $committed_len)))))
+)
@@ -0,0 +1,29 @@
+;; stdio writes to stdout and stderr via WASI, as guests compiled from
+;; languages such as TinyGo do when printing, then calls the next handler.
+(module $stdio
+  ;; fd_write writes the buffers described by the iovecs to the file.
+  (import "wasi_snapshot_preview1" "fd_write"
+    (func $fd_write (param $fd i32) (param $iovs i32) (param $iovs_len i32)
+                    (param $nwritten i32) (result i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; iovecs are pairs of offset and length of the data to write.
+  (data (i32.const 0) "\20\00\00\00\09\00\00\00") ;; "hello\nwor"
+  (data (i32.const 8) "\29\00\00\00\03\00\00\00") ;; "ld\n"
+  (data (i32.const 16) "\2c\00\00\00\05\00\00\00") ;; "oops\n"
+
+  (data (i32.const 32) "hello\nworld\noops\n")
+
+  ;; $nwritten is where fd_write writes the count of bytes written.
+  (global $nwritten i32 (i32.const 64))
+
+  (func $handle (export "handle")
+    ;; Write stdout in two calls, to show lines are joined.
+    (drop (call $fd_write (i32.const 1) (i32.const 0) (i32.const 1) (global.get $nwritten)))
+    (drop (call $fd_write (i32.const 1) (i32.const 8) (i32.const 1) (global.get $nwritten)))
+    (drop (call $fd_write (i32.const 2) (i32.const 16) (i32.const 1) (global.get $nwritten)))
+    (call $next)))
@@ -0,0 +1,29 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; guest which can read the response body streams it instead, such as for
+;; Server-Sent Events.
+(module $streaming
+
+  ;; enable_streaming_response prevents the host from buffering the response
+  ;; of the next handler.
+  (import "http-handler" "enable_streaming_response"
+    (func $enable_streaming_response))
+
+  ;; read_response_body is imported, so the host would otherwise buffer the
+  ;; response.
+  (import "http-handler" "read_response_body"
+    (func $read_response_body
+      (param $buf i32) (param $buf_limit i32)
+      (result (; body_len ;) i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "read_response_body" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; handle enables a streaming response before invoking the next handler.
+  (func $handle (export "handle")
+    (call $enable_streaming_response)
+    (call $next))
+)
@@ -0,0 +1,35 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; handler schedules periodic work in the background, such as refreshing a
+;; blocklist, instead of doing it when handling requests.
+(module $tick
+
+  ;; schedule_tick schedules the host to call "on_tick" periodically.
+  (import "http-handler" "schedule_tick"
+    (func $schedule_tick (param $period_ms i32)))
+
+  ;; log logs a message to the host's logs.
+  (import "http-handler" "log" (func $log
+    (param $buf i32) (param $buf_limit i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "log" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (global $tick i32 (i32.const 0))
+  (data (i32.const 0) "tick")
+  (global $tick_len i32 (i32.const 4))
+
+  ;; init schedules ticks each 100ms. Each instance does this, but there is
+  ;; only one timer.
+  (func $init (export "init")
+    (call $schedule_tick (i32.const 100)))
+
+  ;; on_tick logs "tick", where a real guest would refresh state shared with
+  ;; other instances.
+  (func $on_tick (export "on_tick")
+    (call $log (global.get $tick) (global.get $tick_len)))
+
+  ;; handle_request continues to the next handler.
+  (func $handle_request (export "handle_request") (result (; continue ;) i32)
+    (i32.const 1))
+)
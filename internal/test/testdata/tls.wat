@@ -0,0 +1,67 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how a handler reads the client address and TLS certificate.
+(module $tls
+
+  ;; get_source_addr writes the client address to memory if it isn't larger
+  ;; than the buffer size limit. The result is the length of the address.
+  (import "http-handler" "get_source_addr"
+    (func $get_source_addr
+      (param $buf i32) (param $buf_limit i32)
+      (result (; addr_len ;) i32)))
+
+  ;; get_tls_version returns the TLS version or zero if not over TLS.
+  (import "http-handler" "get_tls_version"
+    (func $get_tls_version (result (; tls_version ;) i32)))
+
+  ;; get_tls_peer_cert writes the client certificate to memory if it isn't
+  ;; larger than the buffer size limit. The result is the length of the
+  ;; certificate, or zero if there is none.
+  (import "http-handler" "get_tls_peer_cert"
+    (func $get_tls_peer_cert
+      (param $buf i32) (param $buf_limit i32)
+      (result (; cert_len ;) i32)))
+
+  ;; send_response sends the HTTP response with a given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "get_source_addr" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; buf is an arbitrary area to write data
+  (global $buf i32 (i32.const 1024))
+  (global $buf_limit i32 (i32.const 1024))
+
+  ;; handle rejects requests not over TLS. Otherwise, it responds with the
+  ;; client address and certificate, separated by a comma.
+  (func $handle (export "handle")
+    (local $len i32)
+
+    (if (i32.eqz (call $get_tls_version))
+      (then
+        (call $send_response (i32.const 403) (i32.const 0) (i32.const 0))
+        (return)))
+
+    (local.set $len
+      (call $get_source_addr (global.get $buf) (global.get $buf_limit)))
+
+    (i32.store8 (i32.add (global.get $buf) (local.get $len)) (i32.const 44 (; ',' ;)))
+    (local.set $len (i32.add (local.get $len) (i32.const 1)))
+
+    (local.set $len
+      (i32.add
+        (local.get $len)
+        (call $get_tls_peer_cert
+          (i32.add (global.get $buf) (local.get $len))
+          (i32.sub (global.get $buf_limit) (local.get $len)))))
+
+    (call $send_response
+      (i32.const 200)
+      (global.get $buf)
+      (local.get $len)))
+)
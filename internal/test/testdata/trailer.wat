@@ -0,0 +1,56 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how a handler reads request trailers and sets response trailers.
+(module $trailer
+
+  ;; get_request_trailer writes a trailer value to memory if it exists and
+  ;; isn't larger than the buffer size limit. The result is`1<<32|value_len`
+  ;; or zero if the trailer doesn't exist.
+  (import "http-handler" "get_request_trailer"
+    (func $get_request_trailer
+      (param $name i32) (param $name_len i32)
+      (param $buf i32) (param $buf_limit i32)
+      (result (; 0 or 1 << 32| value_len ;) i64)))
+
+  ;; set_response_trailer sets a response trailer.
+  (import "http-handler" "set_response_trailer"
+    (func $set_response_trailer
+      (param $name i32) (param $name_len i32)
+      (param $value i32) (param $value_len i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "get_request_trailer" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; buf is an arbitrary area to write data
+  (global $buf i32 (i32.const 1024))
+  (global $buf_limit i32 (i32.const 1024))
+
+  (global $checksum i32 (i32.const 0))
+  (data (i32.const 0) "Checksum")
+  (global $checksum_len i32 (i32.const 8))
+
+  ;; handle dispatches to the next handler, then echoes the "Checksum"
+  ;; request trailer as a response trailer.
+  (func $handle (export "handle")
+    (local $result i64)
+
+    (call $next)
+
+    (local.set $result
+      (call $get_request_trailer
+        (global.get $checksum)
+        (global.get $checksum_len)
+        (global.get $buf)
+        (global.get $buf_limit)))
+
+    (if (i64.ne (local.get $result) (i64.const 0))
+      (then
+        (call $set_response_trailer
+          (global.get $checksum)
+          (global.get $checksum_len)
+          (global.get $buf)
+          (i32.wrap_i64 (local.get $result))))))
+)
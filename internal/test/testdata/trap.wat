@@ -0,0 +1,38 @@
+;; This example module is written in WebAssembly Text Format to show the
+;; how the host recovers when a handler traps.
+(module $trap
+
+  ;; read_request_header writes a header value to memory if it exists and isn't
+  ;; larger than the buffer size limit. The result is`1<<32|value_len` or zero
+  ;; if the header doesn't exist.
+  (import "http-handler" "read_request_header"
+    (func $read_request_header
+      (param $name i32) (param $name_len i32)
+      (param $buf i32) (param $value_limit i32)
+      (result (; 0 or 1 << 32| value_len ;) i64)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "read_request_header" can read memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  (global $next_name i32 (i32.const 0))
+  (data (i32.const 0) "X-Next")
+  (global $next_name_len i32 (i32.const 6))
+
+  ;; handle invokes the next handler when the "X-Next" request header exists.
+  ;; Either way, it then traps.
+  (func $handle (export "handle")
+    (if (i64.ne
+          (call $read_request_header
+            (global.get $next_name)
+            (global.get $next_name_len)
+            (i32.const 1024)
+            (i32.const 0))
+          (i64.const 0))
+      (then (call $next)))
+
+    (unreachable))
+)
@@ -0,0 +1,37 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; guest can inspect a pending upgrade, such as to WebSocket, before allowing
+;; it by invoking the next handler.
+(module $upgrade
+
+  ;; get_upgrade writes the protocol of a pending upgrade to memory if it
+  ;; isn't larger than the buffer size limit. The result is its length in
+  ;; bytes, or zero if the request isn't an upgrade.
+  (import "http-handler" "get_upgrade"
+    (func $get_upgrade (param $buf i32) (param $buf_limit i32) (result i32)))
+
+  ;; log writes a message to the host console.
+  (import "http-handler" "log" (func $log (param $ptr i32) (param $size i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory", so that imported
+  ;; functions like "get_upgrade" can write memory.
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; buf is an arbitrary area to write data.
+  (global $buf i32 (i32.const 1024))
+  (global $buf_limit i32 (i32.const 64))
+
+  ;; handle logs the protocol of an upgrade, if any, then allows the request.
+  (func $handle (export "handle")
+    (local $len i32)
+
+    (local.set $len
+      (call $get_upgrade (global.get $buf) (global.get $buf_limit)))
+
+    (if (i32.ne (local.get $len) (i32.const 0))
+      (then (call $log (global.get $buf) (local.get $len))))
+
+    (call $next))
+)
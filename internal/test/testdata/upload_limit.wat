@@ -0,0 +1,41 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; handler enforces an upload size limit before the host reads the body.
+(module $upload_limit
+
+  ;; get_request_body_size returns the size of the request body declared by
+  ;; the client, or -1 if unknown.
+  (import "http-handler" "get_request_body_size"
+    (func $get_request_body_size (result i64)))
+
+  ;; is_request_chunked returns one if the request body is chunked.
+  (import "http-handler" "is_request_chunked"
+    (func $is_request_chunked (result i32)))
+
+  ;; send_response sends the current response with the given status code and
+  ;; optional body.
+  (import "http-handler" "send_response"
+    (func $send_response
+      (param $status_code i32)
+      (param $body i32)
+      (param $body_len i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory".
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; $limit is the maximum size of the request body in bytes.
+  (global $limit i64 (i64.const 8))
+
+  ;; handle responds 413 when the request body is chunked or declared larger
+  ;; than $limit. Otherwise, it calls the next handler.
+  (func $handle (export "handle")
+    (if (i32.or
+          (call $is_request_chunked)
+          (i64.gt_s (call $get_request_body_size) (global.get $limit)))
+      (then
+        (call $send_response (i32.const 413) (i32.const 0) (i32.const 0))
+        (return)))
+    (call $next))
+)
@@ -0,0 +1,40 @@
+;; This example module is written in WebAssembly Text Format to show how a
+;; handler routes requests, such as to a canary backend.
+(module $upstream
+
+  ;; get_config writes the guest config to memory if it isn't larger than the
+  ;; buffer size limit. The result is the length of the config in bytes.
+  (import "http-handler" "get_config"
+    (func $get_config
+      (param $buf i32) (param $buf_limit i32)
+      (result (; config_len ;) i32)))
+
+  ;; set_upstream sets where the host sends the request.
+  (import "http-handler" "set_upstream"
+    (func $set_upstream (param $upstream i32) (param $upstream_len i32)))
+
+  ;; next instructs the host to invoke the next handler.
+  (import "http-handler" "next" (func $next))
+
+  ;; http-wasm guests are required to export "memory".
+  (memory (export "memory") 1 (; 1 page==64KB ;))
+
+  ;; buf is an arbitrary area to write data
+  (global $buf i32 (i32.const 1024))
+  (global $buf_limit i32 (i32.const 1024))
+
+  ;; handle routes the request to the upstream in the guest config, if any,
+  ;; then dispatches to the next handler.
+  (func $handle (export "handle")
+    (local $config_len i32)
+
+    (local.set $config_len
+      (call $get_config (global.get $buf) (global.get $buf_limit)))
+
+    (if (i32.and
+          (i32.gt_u (local.get $config_len) (i32.const 0))
+          (i32.le_u (local.get $config_len) (global.get $buf_limit)))
+      (then (call $set_upstream (global.get $buf) (local.get $config_len))))
+
+    (call $next))
+)
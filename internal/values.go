@@ -0,0 +1,13 @@
+package internal
+
+// GuestValueKey is a context.Context Value key of a value passed to guests
+// via httpwasm.WithGuestValue.
+type GuestValueKey struct{ Name string }
+
+// HostValuesKey is a context.Context Value key associated with a *HostValues
+// of the current request.
+type HostValuesKey struct{}
+
+// HostValues are values guests set via handler.FuncSetHostValue, by key,
+// which are lazily initialized.
+type HostValues map[string][]byte
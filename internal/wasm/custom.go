@@ -0,0 +1,120 @@
+// Package wasm reads parts of the WebAssembly binary format that wazero
+// doesn't expose, such as custom sections and exported globals.
+package wasm
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+var (
+	magic   = []byte{0x00, 0x61, 0x73, 0x6d}
+	version = []byte{0x01, 0x00, 0x00, 0x00}
+)
+
+// sectionIDCustom is the ID of a custom section, which the runtime ignores.
+const sectionIDCustom = 0
+
+// CustomSection is a named section of arbitrary data, which is ignored by
+// the runtime. Toolchains use them for metadata such as debug names.
+type CustomSection struct {
+	Name string
+	Data []byte
+}
+
+// CustomSections returns all custom sections in the binary, in the order they
+// were defined. Note: the same name can be used by multiple sections.
+func CustomSections(bin []byte) ([]CustomSection, error) {
+	var sections []CustomSection
+	err := eachCustomSection(bin, func(s CustomSection, _, _ int) {
+		sections = append(sections, s)
+	})
+	return sections, err
+}
+
+// RemoveCustomSection returns a copy of the binary without the first custom
+// section with the given name, and the data of that section. This returns
+// false if the section wasn't present.
+func RemoveCustomSection(bin []byte, name string) (stripped, data []byte, ok bool, err error) {
+	err = eachCustomSection(bin, func(s CustomSection, start, end int) {
+		if ok || s.Name != name {
+			return
+		}
+		stripped = append(append(make([]byte, 0, len(bin)-(end-start)), bin[:start]...), bin[end:]...)
+		data, ok = s.Data, true
+	})
+	return
+}
+
+// eachCustomSection calls fn for each custom section, with the byte range
+// of the whole section, including its ID and size.
+func eachCustomSection(bin []byte, fn func(s CustomSection, start, end int)) error {
+	var sectionErr error
+	err := eachSection(bin, func(id byte, payload []byte, start, end int) bool {
+		if id != sectionIDCustom {
+			return true
+		}
+		nameLen, n, err := decodeUint32(payload)
+		if err != nil || uint64(n)+uint64(nameLen) > uint64(len(payload)) {
+			sectionErr = errors.New("custom section: invalid name")
+			return false
+		}
+		fn(CustomSection{
+			Name: string(payload[n : n+int(nameLen)]),
+			Data: payload[n+int(nameLen):],
+		}, start, end)
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	return sectionErr
+}
+
+// eachSection calls fn for each section with its ID, payload and the byte
+// range of the whole section, until fn returns false.
+func eachSection(bin []byte, fn func(id byte, payload []byte, start, end int) bool) error {
+	if len(bin) < 8 || !bytes.Equal(bin[0:4], magic) {
+		return errors.New("invalid magic number")
+	} else if !bytes.Equal(bin[4:8], version) {
+		return errors.New("invalid version header")
+	}
+
+	for pos := 8; pos < len(bin); {
+		start := pos
+		id := bin[pos]
+		pos++
+		size, n, err := decodeUint32(bin[pos:])
+		if err != nil {
+			return fmt.Errorf("section[%d]: invalid size: %w", id, err)
+		}
+		pos += n
+		if uint64(pos)+uint64(size) > uint64(len(bin)) {
+			return fmt.Errorf("section[%d]: size %d exceeds binary", id, size)
+		}
+		payload := bin[pos : pos+int(size)]
+		pos += int(size)
+
+		if !fn(id, payload, start, pos) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// decodeUint32 decodes an unsigned LEB128 value, returning the value and the
+// count of bytes read.
+func decodeUint32(b []byte) (uint32, int, error) {
+	var result uint32
+	for i := 0; i < 5; i++ {
+		if i >= len(b) {
+			return 0, 0, errors.New("unexpected end of LEB128")
+		}
+		result |= uint32(b[i]&0x7f) << (7 * i)
+		if b[i]&0x80 == 0 {
+			return result, i + 1, nil
+		}
+	}
+	return 0, 0, errors.New("LEB128 overflows uint32")
+}
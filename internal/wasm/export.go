@@ -0,0 +1,294 @@
+package wasm
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	// sectionIDExport is the ID of the export section.
+	sectionIDExport = 7
+
+	// externTypeGlobal is the kind of an export which is a global.
+	externTypeGlobal = 0x03
+)
+
+// ExportedGlobals returns the names of all globals the binary exports, in
+// the order they were defined.
+func ExportedGlobals(bin []byte) ([]string, error) {
+	var names []string
+	var exportErr error
+	err := eachSection(bin, func(id byte, payload []byte, _, _ int) bool {
+		if id != sectionIDExport {
+			return true
+		}
+		names, exportErr = exportedGlobals(payload)
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	return names, exportErr
+}
+
+// exportedGlobals decodes the names of globals in the export section.
+func exportedGlobals(payload []byte) (names []string, err error) {
+	err = eachExport(payload, func(name string, kind byte, _ uint32) {
+		if kind == externTypeGlobal {
+			names = append(names, name)
+		}
+	})
+	return
+}
+
+// eachExport calls fn with each entry of the export section.
+func eachExport(payload []byte, fn func(name string, kind byte, index uint32)) error {
+	count, pos, err := decodeUint32(payload)
+	if err != nil {
+		return fmt.Errorf("export section: invalid count: %w", err)
+	}
+	for i := uint32(0); i < count; i++ {
+		nameLen, n, err := decodeUint32(payload[pos:])
+		if err != nil || uint64(pos+n)+uint64(nameLen)+1 > uint64(len(payload)) {
+			return fmt.Errorf("export[%d]: invalid name", i)
+		}
+		pos += n
+		name := string(payload[pos : pos+int(nameLen)])
+		pos += int(nameLen)
+		kind := payload[pos]
+		pos++
+		index, n, err := decodeUint32(payload[pos:])
+		if err != nil {
+			return fmt.Errorf("export[%d]: invalid index: %w", i, err)
+		}
+		pos += n
+		fn(name, kind, index)
+	}
+	if pos != len(payload) {
+		return errors.New("export section: unexpected trailing bytes")
+	}
+	return nil
+}
+
+const (
+	// sectionIDImport is the ID of the import section.
+	sectionIDImport = 2
+	// sectionIDGlobal is the ID of the global section.
+	sectionIDGlobal = 6
+
+	// valTypeI32 and the below are the value types whose values are numbers,
+	// which can be read and written by the host.
+	valTypeI32 = 0x7f
+	valTypeI64 = 0x7e
+	valTypeF32 = 0x7d
+	valTypeF64 = 0x7c
+)
+
+// ExportMutableGlobals returns the binary with each mutable global it defines
+// but doesn't export also exported, named the prefix followed by its index.
+// Ex. "prefix.0" This allows the host to read and write all mutable state of
+// the guest outside its memory, such as the stack pointer kept by compilers.
+//
+// This fails if a mutable global isn't a number, such as a reference, as the
+// host can't copy it between guests.
+func ExportMutableGlobals(bin []byte, prefix string) ([]byte, error) {
+	var imported uint32
+	var mutable, exported []uint32
+	var walkErr error
+	err := eachSection(bin, func(id byte, payload []byte, _, _ int) bool {
+		switch id {
+		case sectionIDImport:
+			imported, walkErr = importedGlobals(payload)
+		case sectionIDGlobal:
+			mutable, walkErr = mutableGlobals(payload, imported)
+		case sectionIDExport:
+			exported, walkErr = exportedGlobalIndices(payload)
+		}
+		return walkErr == nil
+	})
+	if err != nil {
+		return nil, err
+	} else if walkErr != nil {
+		return nil, walkErr
+	}
+
+	var entries []byte
+	var count uint32
+	for _, idx := range mutable {
+		if containsUint32(exported, idx) {
+			continue
+		}
+		name := fmt.Sprintf("%s.%d", prefix, idx)
+		entries = append(entries, encodeUint32(uint32(len(name)))...)
+		entries = append(entries, name...)
+		entries = append(entries, externTypeGlobal)
+		entries = append(entries, encodeUint32(idx)...)
+		count++
+	}
+	if count == 0 {
+		return bin, nil
+	}
+
+	out := append([]byte{}, bin[:8]...)
+	found := false
+	err = eachSection(bin, func(id byte, payload []byte, start, end int) bool {
+		if id != sectionIDExport {
+			out = append(out, bin[start:end]...)
+			return true
+		}
+		found = true
+		n, pos, _ := decodeUint32(payload) // valid, as it was decoded above
+		exports := append(encodeUint32(n+count), payload[pos:]...)
+		out = appendSection(out, sectionIDExport, append(exports, entries...))
+		return true
+	})
+	if err != nil {
+		return nil, err
+	} else if !found {
+		return nil, errors.New("binary has no export section")
+	}
+	return out, nil
+}
+
+// importedGlobals returns the count of globals in the import section, which
+// precede those the binary defines in the index space of globals.
+func importedGlobals(payload []byte) (uint32, error) {
+	count, pos, err := decodeUint32(payload)
+	if err != nil {
+		return 0, fmt.Errorf("import section: invalid count: %w", err)
+	}
+	var globals uint32
+	for i := uint32(0); i < count; i++ {
+		// Skip the module and name.
+		for j := 0; j < 2; j++ {
+			size, n, err := decodeUint32(payload[pos:])
+			if err != nil || uint64(pos+n)+uint64(size) > uint64(len(payload)) {
+				return 0, fmt.Errorf("import[%d]: invalid name", i)
+			}
+			pos += n + int(size)
+		}
+		if pos+1 >= len(payload) {
+			return 0, fmt.Errorf("import[%d]: missing type", i)
+		}
+		kind := payload[pos]
+		pos++
+		var n int
+		switch kind {
+		case 0x00: // func: type index
+			_, n, err = decodeUint32(payload[pos:])
+		case 0x01: // table: reference type and limits
+			n, err = skipLimits(payload[pos:], 1)
+		case externTypeMemory: // limits
+			n, err = skipLimits(payload[pos:], 0)
+		case externTypeGlobal: // value type and mutability
+			globals++
+			n = 2
+		case 0x04: // tag: attribute and type index
+			_, n, err = decodeUint32(payload[pos+1:])
+			n++
+		default:
+			return 0, fmt.Errorf("import[%d]: invalid kind %#x", i, kind)
+		}
+		if err != nil || pos+n > len(payload) {
+			return 0, fmt.Errorf("import[%d]: invalid type", i)
+		}
+		pos += n
+	}
+	return globals, nil
+}
+
+// skipLimits returns the count of bytes of limits following skip bytes.
+func skipLimits(b []byte, skip int) (int, error) {
+	if len(b) <= skip {
+		return 0, errors.New("missing limits")
+	}
+	flags := b[skip]
+	pos := skip + 1
+	for i := 0; i < 1+int(flags&0x01); i++ {
+		_, n, err := decodeUint32(b[pos:])
+		if err != nil {
+			return 0, err
+		}
+		pos += n
+	}
+	return pos, nil
+}
+
+// mutableGlobals returns the indices of mutable globals in the global
+// section, whose first index is the count of imported globals.
+func mutableGlobals(payload []byte, imported uint32) ([]uint32, error) {
+	count, pos, err := decodeUint32(payload)
+	if err != nil {
+		return nil, fmt.Errorf("global section: invalid count: %w", err)
+	}
+	var indices []uint32
+	for i := uint32(0); i < count; i++ {
+		if pos+2 > len(payload) {
+			return nil, fmt.Errorf("global[%d]: invalid type", i)
+		}
+		valType, mut := payload[pos], payload[pos+1]
+		pos += 2
+		if mut == 1 {
+			switch valType {
+			case valTypeI32, valTypeI64, valTypeF32, valTypeF64:
+			default:
+				return nil, fmt.Errorf("global[%d]: mutable global of type %#x isn't a number", i, valType)
+			}
+			indices = append(indices, imported+i)
+		}
+		n, err := skipConstExpr(payload[pos:])
+		if err != nil {
+			return nil, fmt.Errorf("global[%d]: invalid init: %w", i, err)
+		}
+		pos += n
+	}
+	return indices, nil
+}
+
+// skipConstExpr returns the count of bytes of a constant expression,
+// including its end instruction.
+func skipConstExpr(b []byte) (int, error) {
+	for pos := 0; pos < len(b); {
+		op := b[pos]
+		pos++
+		switch op {
+		case 0x0b: // end
+			return pos, nil
+		case 0x41, 0x42, 0x23, 0xd2: // i32.const, i64.const, global.get, ref.func
+			n := 0
+			for pos+n < len(b) && b[pos+n]&0x80 != 0 {
+				n++
+			}
+			pos += n + 1
+		case 0x43: // f32.const
+			pos += 4
+		case 0x44: // f64.const
+			pos += 8
+		case 0xd0: // ref.null: reference type
+			pos++
+		case 0x6a, 0x6b, 0x6c, 0x7c, 0x7d, 0x7e: // extended constant arithmetic
+		default:
+			return 0, fmt.Errorf("unsupported instruction %#x", op)
+		}
+	}
+	return 0, errors.New("missing end")
+}
+
+// exportedGlobalIndices returns the indices of globals in the export section.
+func exportedGlobalIndices(payload []byte) (indices []uint32, err error) {
+	err = eachExport(payload, func(_ string, kind byte, index uint32) {
+		if kind == externTypeGlobal {
+			indices = append(indices, index)
+		}
+	})
+	return
+}
+
+func containsUint32(values []uint32, v uint32) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
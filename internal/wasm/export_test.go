@@ -0,0 +1,45 @@
+package wasm
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/http-wasm/http-wasm-host-go/internal/test"
+)
+
+func TestExportMutableGlobals(t *testing.T) {
+	bin, err := ExportMutableGlobals(test.SnapshotWasm, "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The unexported global is exported after those already exported.
+	names, err := ExportedGlobals(bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"config_len", "snapshot.5"}; !reflect.DeepEqual(want, names) {
+		t.Fatalf("unexpected globals: want %v, have %v", want, names)
+	}
+
+	// Exporting again changes nothing, as all are exported.
+	again, err := ExportMutableGlobals(bin, "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(bin, again) {
+		t.Fatal("expected the binary to be unchanged")
+	}
+}
+
+func TestExportMutableGlobals_NotNumber(t *testing.T) {
+	bin := append(append([]byte{}, magic...), version...)
+	// A mutable funcref initialized to null.
+	bin = appendSection(bin, sectionIDGlobal, []byte{1, 0x70, 1, 0xd0, 0x70, 0x0b})
+	bin = appendSection(bin, sectionIDExport, []byte{0})
+
+	_, err := ExportMutableGlobals(bin, "snapshot")
+	if want := "global[0]: mutable global of type 0x70 isn't a number"; err == nil || err.Error() != want {
+		t.Fatalf("expected error %q, have %v", want, err)
+	}
+}
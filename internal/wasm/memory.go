@@ -0,0 +1,45 @@
+package wasm
+
+const (
+	// sectionIDMemory is the ID of the memory section.
+	sectionIDMemory = 5
+
+	// externTypeMemory is the kind of an export which is a memory.
+	externTypeMemory = 0x02
+)
+
+// MemoryModule returns a binary which only exports a memory with the given
+// name and limits in pages. This allows the host to provide a memory to
+// guests which import one, as wazero can't define memory in host modules.
+func MemoryModule(name string, min, max uint32, hasMax bool) []byte {
+	limits := []byte{0x00}
+	limits = append(limits, encodeUint32(min)...)
+	if hasMax {
+		limits[0] = 0x01
+		limits = append(limits, encodeUint32(max)...)
+	}
+	memories := append([]byte{1}, limits...)
+
+	exports := append([]byte{1}, encodeUint32(uint32(len(name)))...)
+	exports = append(exports, name...)
+	exports = append(exports, externTypeMemory, 0)
+
+	bin := append(append([]byte{}, magic...), version...)
+	bin = appendSection(bin, sectionIDMemory, memories)
+	return appendSection(bin, sectionIDExport, exports)
+}
+
+// appendSection appends a section with the ID and payload to the binary.
+func appendSection(bin []byte, id byte, payload []byte) []byte {
+	bin = append(bin, id)
+	bin = append(bin, encodeUint32(uint32(len(payload)))...)
+	return append(bin, payload...)
+}
+
+// encodeUint32 encodes the value as unsigned LEB128.
+func encodeUint32(v uint32) (b []byte) {
+	for ; v >= 0x80; v >>= 7 {
+		b = append(b, byte(v&0x7f)|0x80)
+	}
+	return append(b, byte(v))
+}
@@ -0,0 +1,155 @@
+package wasm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+const (
+	// sectionNameName is the name of the custom section holding debug
+	// names, such as that of the module.
+	sectionNameName = "name"
+	// sectionNameProducers is the name of the custom section listing tools
+	// which produced the binary.
+	sectionNameProducers = "producers"
+	// subsectionIDModuleName is the ID of the module name in the name
+	// section.
+	subsectionIDModuleName = 0
+)
+
+// featureNames are the names of handler.Features in
+// handler.CustomSectionMeta.
+var featureNames = map[string]handler.Features{
+	"buffer_request":  handler.FeatureBufferRequest,
+	"buffer_response": handler.FeatureBufferResponse,
+	"trailers":        handler.FeatureTrailers,
+	"shared_store":    handler.FeatureSharedStore,
+	"http_call":       handler.FeatureHTTPCall,
+	"decode_response": handler.FeatureDecodeResponse,
+	"grow_buffers":    handler.FeatureGrowBuffers,
+	"state_store":     handler.FeatureStateStore,
+}
+
+// meta is the JSON format of handler.CustomSectionMeta.
+type meta struct {
+	Version          string   `json:"version"`
+	Author           string   `json:"author"`
+	RequiredFeatures []string `json:"required_features"`
+}
+
+// Metadata returns metadata of a guest from its custom sections, or an error
+// if any section it reads is invalid. When a section is repeated, the first
+// is used.
+func Metadata(sections []CustomSection) (handler.GuestMetadata, error) {
+	var md handler.GuestMetadata
+	seen := map[string]bool{}
+	for _, s := range sections {
+		if seen[s.Name] {
+			continue
+		}
+		seen[s.Name] = true
+
+		var err error
+		switch s.Name {
+		case sectionNameName:
+			md.Module, err = moduleName(s.Data)
+		case sectionNameProducers:
+			md.Producers, err = producers(s.Data)
+		case handler.CustomSectionMeta:
+			err = decodeMeta(s.Data, &md)
+		default:
+			continue
+		}
+		if err != nil {
+			return handler.GuestMetadata{}, fmt.Errorf("invalid %s section: %w", s.Name, err)
+		}
+	}
+	return md, nil
+}
+
+// moduleName decodes the module name from the name section, or returns
+// empty if it has none.
+func moduleName(data []byte) (string, error) {
+	for pos := 0; pos < len(data); {
+		id := data[pos]
+		pos++
+		size, n, err := decodeUint32(data[pos:])
+		if err != nil || uint64(pos+n)+uint64(size) > uint64(len(data)) {
+			return "", fmt.Errorf("subsection[%d]: invalid size", id)
+		}
+		pos += n
+		if id == subsectionIDModuleName {
+			name, _, err := decodeName(data[pos : pos+int(size)])
+			return name, err
+		}
+		pos += int(size)
+	}
+	return "", nil
+}
+
+// producers decodes the fields of the producers section.
+func producers(data []byte) (map[string][]handler.Producer, error) {
+	fieldCount, pos, err := decodeUint32(data)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string][]handler.Producer, fieldCount)
+	for i := uint32(0); i < fieldCount; i++ {
+		field, n, err := decodeName(data[pos:])
+		if err != nil {
+			return nil, fmt.Errorf("field[%d]: %w", i, err)
+		}
+		pos += n
+		valueCount, n, err := decodeUint32(data[pos:])
+		if err != nil {
+			return nil, fmt.Errorf("field[%d]: %w", i, err)
+		}
+		pos += n
+		for j := uint32(0); j < valueCount; j++ {
+			var p handler.Producer
+			if p.Name, n, err = decodeName(data[pos:]); err != nil {
+				return nil, fmt.Errorf("field[%d] value[%d]: %w", i, j, err)
+			}
+			pos += n
+			if p.Version, n, err = decodeName(data[pos:]); err != nil {
+				return nil, fmt.Errorf("field[%d] value[%d]: %w", i, j, err)
+			}
+			pos += n
+			fields[field] = append(fields[field], p)
+		}
+	}
+	return fields, nil
+}
+
+// decodeMeta decodes handler.CustomSectionMeta into the metadata.
+func decodeMeta(data []byte, md *handler.GuestMetadata) error {
+	var m meta
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	md.Version, md.Author = m.Version, m.Author
+	for _, name := range m.RequiredFeatures {
+		f, ok := featureNames[name]
+		if !ok {
+			return fmt.Errorf("unknown feature %q", name)
+		}
+		md.RequiredFeatures |= f
+	}
+	return nil
+}
+
+// decodeName decodes a length-prefixed UTF-8 name, returning it and the count
+// of bytes read.
+func decodeName(b []byte) (string, int, error) {
+	size, n, err := decodeUint32(b)
+	if err != nil {
+		return "", 0, err
+	}
+	if uint64(n)+uint64(size) > uint64(len(b)) {
+		return "", 0, errors.New("name exceeds section")
+	}
+	return string(b[n : n+int(size)]), n + int(size), nil
+}
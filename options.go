@@ -2,10 +2,17 @@ package httpwasm
 
 import (
 	"context"
+	"crypto"
+	"io"
+	"net"
+	"runtime"
+	"time"
 
 	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/experimental"
 
 	"github.com/http-wasm/http-wasm-host-go/api"
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
 	"github.com/http-wasm/http-wasm-host-go/internal"
 )
 
@@ -17,16 +24,139 @@ type Option func(*internal.WazeroOptions)
 type NewRuntime func(context.Context) (wazero.Runtime, error)
 
 // Runtime provides the wazero.Runtime and defaults to DefaultRuntime.
+//
+// Note: This replaces RuntimeConfig and CompileMode, and vice versa.
 func Runtime(newRuntime NewRuntime) Option {
 	return func(h *internal.WazeroOptions) {
 		h.NewRuntime = newRuntime
+		h.RuntimeMode = "" // unknown
+	}
+}
+
+// RuntimeConfig configures the wazero.Runtime created by default, such as to
+// limit the memory of guests.
+// Ex. RuntimeConfig(wazero.NewRuntimeConfig().WithMemoryLimitPages(16))
+//
+// Note: This replaces Runtime and CompileMode, and vice versa.
+func RuntimeConfig(config wazero.RuntimeConfig) Option {
+	return func(h *internal.WazeroOptions) {
+		h.NewRuntime = internal.RuntimeWithConfig(config)
+		h.RuntimeMode = "" // unknown
+	}
+}
+
+// CompileMode selects how the runtime created by default runs guests:
+// api.CompileModeCompiler or api.CompileModeInterpreter. The default is the
+// compiler on platforms which support it, otherwise the interpreter.
+//
+// The interpreter starts faster, so can be better for tests or short-lived
+// processes. NewMiddleware fails if the mode isn't supported on this platform.
+//
+// Note: This replaces Runtime and RuntimeConfig, and vice versa.
+func CompileMode(mode string) Option {
+	return func(h *internal.WazeroOptions) {
+		h.NewRuntime = internal.RuntimeWithMode(mode)
+		h.RuntimeMode = mode
+	}
+}
+
+// Profiler listens to each call of guest and host functions, such as to
+// measure whether time is spent in guest code or calls to the host. See
+// package profile for a factory that does this. Defaults to none.
+//
+// Note: wazero only supports this with api.CompileModeInterpreter, so this
+// must be combined with CompileMode, and is too slow for production.
+func Profiler(factory experimental.FunctionListenerFactory) Option {
+	return func(h *internal.WazeroOptions) {
+		h.Profiler = factory
+	}
+}
+
+// ModuleConfig is the configuration used to instantiate the guest.
+//
+// Note: Guest stdout and stderr are configured with GuestStdout and
+// GuestStderr instead. Environment variables and arguments can be configured
+// here, or with GuestEnv and GuestArgs.
+func ModuleConfig(moduleConfig wazero.ModuleConfig) Option {
+	return func(h *internal.WazeroOptions) {
+		h.ModuleConfig = moduleConfig
+	}
+}
+
+// GuestStdout receives what the guest writes to stdout, such as via println.
+// Defaults to the Logger, a line at a time, prefixed with "stdout: ".
+//
+// Note: This overrides stdout configured with ModuleConfig. Use io.Discard to
+// discard output.
+func GuestStdout(w io.Writer) Option {
+	return func(h *internal.WazeroOptions) {
+		h.Stdout = w
+	}
+}
+
+// GuestStderr receives what the guest writes to stderr, such as panics.
+// Defaults to the Logger, a line at a time, prefixed with "stderr: ".
+//
+// Note: This overrides stderr configured with ModuleConfig. Use io.Discard to
+// discard output.
+func GuestStderr(w io.Writer) Option {
+	return func(h *internal.WazeroOptions) {
+		h.Stderr = w
+	}
+}
+
+// GuestEnv sets environment variables of the guest, which it reads via WASI,
+// such as with os.Getenv in Go. This is an alternative to GuestConfigBytes
+// for guests which read deployment settings from the environment.
+//
+// Note: These are added to any configured with ModuleConfig. Keys must not be
+// empty or contain '=', and neither keys nor values may contain NUL.
+func GuestEnv(env map[string]string) Option {
+	return func(h *internal.WazeroOptions) {
+		h.Env = env
+	}
+}
+
+// GuestArgs sets the arguments of the guest, which it reads via WASI, such as
+// with os.Args in Go. By convention, the first is the program name.
+// Ex. GuestArgs("auth", "--verbose")
+//
+// Note: This replaces any arguments configured with ModuleConfig.
+func GuestArgs(args ...string) Option {
+	return func(h *internal.WazeroOptions) {
+		h.Args = args
 	}
 }
 
 // GuestConfig is the configuration used to instantiate the guest.
+//
+// Deprecated: Use ModuleConfig, or GuestConfigBytes for the configuration the
+// guest reads via handler.FuncGetConfig.
 func GuestConfig(moduleConfig wazero.ModuleConfig) Option {
+	return ModuleConfig(moduleConfig)
+}
+
+// GuestConfigBytes is the configuration the guest reads via
+// handler.FuncGetConfig. When the guest publishes a schema in the custom
+// section named handler.CustomSectionConfigSchema, this is validated against
+// it on NewMiddleware.
+func GuestConfigBytes(guestConfig []byte) Option {
 	return func(h *internal.WazeroOptions) {
-		h.ModuleConfig = moduleConfig
+		h.GuestConfig = guestConfig
+	}
+}
+
+// GuestConfigCanary sets an alternative to GuestConfigBytes, which the guest reads
+// via handler.FuncGetConfig on the given percentage of requests. This allows
+// canarying a configuration change without changing the guest. The canary is
+// validated the same way as GuestConfigBytes.
+//
+// Note: A percentage of zero disables the canary, while 100 or more always
+// uses it.
+func GuestConfigCanary(guestConfig []byte, percent int) Option {
+	return func(h *internal.WazeroOptions) {
+		h.GuestConfigCanary = guestConfig
+		h.GuestConfigCanaryPercent = percent
 	}
 }
 
@@ -37,3 +167,701 @@ func Logger(logger api.LogFunc) Option {
 		h.Logger = logger
 	}
 }
+
+// AccessLogger sets where access log entries emitted by the guest via
+// handler.FuncEmitAccessLog are sent. Defaults to none, which drops them.
+func AccessLogger(logger api.AccessLogger) Option {
+	return func(h *internal.WazeroOptions) {
+		h.AccessLogger = logger
+	}
+}
+
+// OnEvent sets the function which receives events guests emit via
+// handler.FuncEmitEvent. Defaults to none, which drops them.
+//
+// Events are queued, so that guests don't wait for fn. When fn is slower than
+// guests emit events, and the queue is full, events are dropped and logged.
+// Closing the middleware waits for queued events to be delivered.
+func OnEvent(fn api.EventFunc) Option {
+	return func(h *internal.WazeroOptions) {
+		h.OnEvent = fn
+	}
+}
+
+// MaxLogBytes truncates messages the guest logs to the given length, so that
+// a guest can't flood logs with large messages. Defaults to unlimited.
+func MaxLogBytes(size int) Option {
+	return func(h *internal.WazeroOptions) {
+		h.LogLimits.MessageBytes = size
+	}
+}
+
+// MaxLogsPerRequest limits how many messages the guest can log per request.
+// Further messages are dropped. Defaults to unlimited.
+func MaxLogsPerRequest(count int) Option {
+	return func(h *internal.WazeroOptions) {
+		h.LogLimits.PerRequest = count
+	}
+}
+
+// LogRateLimit limits messages the guest logs to perSecond across all
+// requests, allowing bursts of up to burst messages. Further messages are
+// dropped. Defaults to unlimited.
+func LogRateLimit(perSecond float64, burst int) Option {
+	return func(h *internal.WazeroOptions) {
+		h.LogLimits.Rate = perSecond
+		h.LogLimits.Burst = burst
+	}
+}
+
+// OnLogDropped sets a callback notified each time a message is dropped due
+// to MaxLogsPerRequest or LogRateLimit, such as to count them in metrics.
+func OnLogDropped(fn api.LogDroppedFunc) Option {
+	return func(h *internal.WazeroOptions) {
+		h.LogLimits.Dropped = fn
+	}
+}
+
+// AuditLogger sets a function notified of each call from the guest to a host
+// function, such as to audit what a third-party guest reads and writes.
+// Defaults to none.
+//
+// Note: This adds overhead to every host function, so may not be suitable
+// for all requests in production.
+func AuditLogger(fn api.AuditFunc) Option {
+	return func(h *internal.WazeroOptions) {
+		h.AuditLogger = fn
+	}
+}
+
+// OnReload sets a callback notified each time a guest loaded from a file is
+// reloaded, whether it succeeded or not. Defaults to ignore reloads.
+func OnReload(onReload api.ReloadFunc) Option {
+	return func(h *internal.WazeroOptions) {
+		h.OnReload = onReload
+	}
+}
+
+// GuestVerifier sets a function invoked with the guest binary before it is
+// compiled. When it returns an error, NewMiddleware fails with it. This allows
+// hosts to only run trusted guests, for example those with a valid signature.
+// See package guestverify for a reference implementation.
+func GuestVerifier(verifier func(guest []byte) error) Option {
+	return func(h *internal.WazeroOptions) {
+		h.GuestVerifier = verifier
+	}
+}
+
+// MirrorDestination adds a destination the guest can copy requests to via
+// handler.FuncMirrorRequest, by name. The URL is the base of the copied
+// request, such as "http://shadow:8080".
+func MirrorDestination(name, url string) Option {
+	return func(h *internal.WazeroOptions) {
+		if h.MirrorDestinations == nil {
+			h.MirrorDestinations = map[string]string{}
+		}
+		h.MirrorDestinations[name] = url
+	}
+}
+
+// Extraction adds an expression the guest can evaluate via
+// handler.FuncExtract, by name. See handler.FuncExtract for the format of the
+// expression, such as "json:$.user.id". Invalid expressions fail
+// NewMiddleware.
+func Extraction(name, expression string) Option {
+	return func(h *internal.WazeroOptions) {
+		if h.Extractions == nil {
+			h.Extractions = map[string]string{}
+		}
+		h.Extractions[name] = expression
+	}
+}
+
+// HMACKey adds a secret the guest can use via handler.FuncHMAC and
+// handler.FuncVerifySignature, by key ID. The secret is never in guest
+// memory.
+func HMACKey(keyID string, secret []byte) Option {
+	return func(h *internal.WazeroOptions) {
+		if h.Keys == nil {
+			h.Keys = map[string]interface{}{}
+		}
+		h.Keys[keyID] = append([]byte{}, secret...)
+	}
+}
+
+// VerificationKey adds a public key the guest can verify signatures with via
+// handler.FuncVerifySignature, by key ID. The key must be an *rsa.PublicKey,
+// an *ecdsa.PublicKey of curve P-256, P-384 or P-521, or an
+// ed25519.PublicKey. Other keys fail NewMiddleware.
+func VerificationKey(keyID string, key crypto.PublicKey) Option {
+	return func(h *internal.WazeroOptions) {
+		if h.Keys == nil {
+			h.Keys = map[string]interface{}{}
+		}
+		h.Keys[keyID] = key
+	}
+}
+
+// ProblemTypeBase sets the base URI of the "type" member of responses sent via
+// handler.FuncSendProblem, which the problem code is appended to. Ex.
+// "https://errors.example.com/". Defaults to no base, so the type is the code.
+func ProblemTypeBase(uri string) Option {
+	return func(h *internal.WazeroOptions) {
+		h.ProblemTypeBase = uri
+	}
+}
+
+// MessageCatalog adds messages by key in a language, such as "en" or "pt-BR",
+// which the guest sends via handler.FuncSendLocalizedResponse. The language of
+// the first catalog added is the default.
+func MessageCatalog(language string, messages map[string]string) Option {
+	return func(h *internal.WazeroOptions) {
+		h.MessageCatalogs = append(h.MessageCatalogs, internal.MessageCatalog{
+			Language: language,
+			Messages: messages,
+		})
+	}
+}
+
+// InjectResponseHeader sets a response header on all responses, immediately
+// before they are committed, replacing any value set by the guest or the next
+// handler. This allows an organization to add headers such as a server token
+// without repeating code in each guest. A guest can suppress this per request
+// via handler.FuncSuppressInjectedHeaders.
+func InjectResponseHeader(name, value string) Option {
+	return InjectResponseHeaderFunc(name, func(context.Context) string { return value })
+}
+
+// InjectResponseHeaderFunc is like InjectResponseHeader, except the value is
+// computed per request, such as a correlation ID. The context is that of the
+// request. An empty result skips the header.
+func InjectResponseHeaderFunc(name string, value func(ctx context.Context) string) Option {
+	return func(h *internal.WazeroOptions) {
+		h.InjectedHeaders = append(h.InjectedHeaders, internal.InjectedHeader{Name: name, Value: value})
+	}
+}
+
+// SharedStore sets the store backing the key/value functions shared across
+// requests, such as handler.FuncGetShared. Defaults to a new
+// sharedstore.Memory per middleware, so pass the same store to each
+// middleware which should share keys.
+func SharedStore(store api.SharedStore) Option {
+	return func(h *internal.WazeroOptions) {
+		h.SharedStore = store
+	}
+}
+
+// StateStore sets the persistent store backing handler.FuncReadState and
+// handler.FuncWriteState, such as a statestore.Dir, which enables
+// handler.FeatureStateStore. As the store outlives the middleware, guests
+// read state written before they were reloaded or the host restarted.
+// Defaults to none.
+func StateStore(store api.StateStore) Option {
+	return func(h *internal.WazeroOptions) {
+		h.StateStore = store
+	}
+}
+
+// PersistStores restores the shared store and rate limiter from snapshots in
+// the directory when the middleware is created, and snapshots them there when
+// it is closed, so that state such as rate-limit counters survives restarts of
+// single-node deployments. The directory is created if it doesn't exist.
+// Defaults to none.
+//
+// Only stores which implement api.Snapshotter, such as the defaults, are
+// persisted. Guests reloaded from a file keep using the restored stores,
+// instead of new ones.
+//
+// Note: Snapshots are written when the middleware closes, so a crash loses
+// changes since it was created. Use StateStore for state which must survive
+// crashes.
+func PersistStores(dir string) Option {
+	p := &internal.PersistedStores{Dir: dir}
+	return func(h *internal.WazeroOptions) {
+		h.PersistedStores = p
+	}
+}
+
+// ResponseCache sets the cache backing handler.FuncCacheLookup and
+// handler.FuncCacheStore, such as a responsecache.Memory, so that guests can
+// serve cached responses without invoking the next handler. Guests decide
+// the cache key and which responses are cacheable, while the host stores and
+// serves them. Defaults to none, in which case lookups miss and responses
+// aren't stored.
+func ResponseCache(cache api.ResponseCache) Option {
+	return func(h *internal.WazeroOptions) {
+		h.ResponseCache = cache
+	}
+}
+
+// RateLimiter sets the limiter backing handler.FuncRateLimitCheck. Defaults
+// to ratelimit.NewMemory(100, 100), shared by all guests of the middleware,
+// which allows each key 100 tokens per second.
+//
+// Use a remote implementation, such as backed by Redis, to apply limits
+// across processes.
+func RateLimiter(limiter api.RateLimiter) Option {
+	return func(h *internal.WazeroOptions) {
+		h.RateLimiter = limiter
+	}
+}
+
+// Metrics sets the backend of metrics guests publish via
+// handler.FuncDefineMetric, such as an adapter to a Prometheus registry.
+// Defaults to discarding them.
+func Metrics(metrics api.Metrics) Option {
+	return func(h *internal.WazeroOptions) {
+		h.Metrics = metrics
+	}
+}
+
+// Clock sets the source of time for guests, such as handler.FuncGetTimeNanos
+// and WASI clocks. Defaults to the system clock.
+//
+// For example, a fake clock allows deterministic tests of a guest which
+// enforces token expiration.
+func Clock(clock api.Clock) Option {
+	return func(h *internal.WazeroOptions) {
+		h.Clock = clock
+	}
+}
+
+// Random sets the source of random bytes for guests, such as
+// handler.FuncGetRandom and WASI random_get. Defaults to crypto/rand.
+//
+// For example, a fixed reader, with Clock, allows deterministic outputs of
+// guests which generate nonces, such as in examples or golden tests. This
+// must not be used in production, as the bytes are predictable. The source
+// must be safe for concurrent use if requests are handled concurrently.
+func Random(source io.Reader) Option {
+	return func(h *internal.WazeroOptions) {
+		h.Random = source
+	}
+}
+
+// ActiveDuring limits the guest to run only during the window, such as
+// business hours. Otherwise, it is bypassed: requests go directly to the next
+// handler. When called multiple times, the guest runs during any of the
+// windows. Invalid windows fail NewMiddleware.
+//
+// The format is "[<days>] <start>-<end> [<zone>]", where days default to all
+// and the zone defaults to UTC. Ex. "Mon-Fri 09:00-17:00 America/New_York"
+//
+// Windows use the clock configured with Clock.
+func ActiveDuring(window string) Option {
+	return func(h *internal.WazeroOptions) {
+		h.ActiveWindows = append(h.ActiveWindows, window)
+	}
+}
+
+// BypassDuring is like ActiveDuring, except the guest is bypassed during the
+// window, such as for maintenance. This takes precedence over ActiveDuring.
+func BypassDuring(window string) Option {
+	return func(h *internal.WazeroOptions) {
+		h.BypassWindows = append(h.BypassWindows, window)
+	}
+}
+
+// BypassPreflight invokes the next handler instead of the guest for CORS
+// preflight requests: "OPTIONS" requests with the "Origin" and
+// "Access-Control-Request-Method" headers. This avoids the cost of the guest
+// when the next handler answers preflights, such as CORS middleware.
+//
+// Guests that answer preflights themselves don't need this, as they can send
+// the response with handler.FuncSetCORSHeaders and handler.FuncSendResponse
+// without invoking the next handler.
+func BypassPreflight() Option {
+	return func(h *internal.WazeroOptions) {
+		h.BypassPreflight = true
+	}
+}
+
+// MaxBodyBuffer limits the bodies buffered in memory for guests, such as to
+// read the request body or buffer the response, so that large uploads can't
+// exhaust memory. Defaults to unlimited.
+//
+// When a guest reads a larger request body, the host rejects the request
+// with 413 Content Too Large, and the guest traps with
+// handler.ErrBodyTooLarge, unless SpillRequestBodies is set. When the next
+// handler writes a larger buffered response, its writes fail, and the host
+// responds with 502 Bad Gateway instead. The same applies to a response body
+// larger once decoded for DecodeResponseBody, except the guest traps with
+// handler.ErrBodyTooLarge when reading it.
+//
+// Note: Chains use the limits of their first guest.
+func MaxBodyBuffer(memory int64) Option {
+	return func(h *internal.WazeroOptions) {
+		h.BodyLimits.Memory = memory
+	}
+}
+
+// SpillRequestBodies buffers request bodies larger than MaxBodyBuffer in
+// temporary files in the directory, up to max bytes, instead of rejecting
+// them. Larger request bodies are rejected as documented on MaxBodyBuffer.
+// The files are removed when the request completes.
+//
+// This keeps large uploads out of memory while the next handler reads them,
+// though guests which read the whole body still read it into their memory.
+func SpillRequestBodies(dir string, max int64) Option {
+	return func(h *internal.WazeroOptions) {
+		h.BodyLimits.SpillDir = dir
+		h.BodyLimits.Max = max
+	}
+}
+
+// InterruptOnClientGone interrupts guests handling a request when the client
+// disconnects, or the request is otherwise canceled, such as by a timeout.
+// This releases the guest, such as for MaxConcurrentGuests, instead of
+// finishing work no one will receive. Guests that were waiting to handle the
+// request aren't invoked at all. Defaults to letting guests finish.
+//
+// The guest traps on its next call to the host, except
+// handler.FuncIsClientGone. As there is no client, nothing is sent, and
+// FailurePolicy doesn't apply.
+//
+// Note: Guests that don't call the host, such as while in a loop, aren't
+// interrupted until they do.
+func InterruptOnClientGone() Option {
+	return func(h *internal.WazeroOptions) {
+		h.InterruptOnClientGone = true
+	}
+}
+
+// CompilationCache sets a directory to cache guests compiled to machine code,
+// which avoids compiling them again when the process restarts. The directory
+// is created if it doesn't exist, and must not be shared by processes running
+// at the same time. See Precompile to populate it ahead of time.
+func CompilationCache(dir string) Option {
+	return func(h *internal.WazeroOptions) {
+		h.CompilationCacheDir = dir
+	}
+}
+
+// ErrorMapping sets the response sent when the guest declares an error code
+// via handler.FuncSetError. The body is a text/template, which can refer to
+// {{.Code}} and {{.StatusCode}}. Ex. `{"error":"{{.Code}}"}` Invalid
+// templates fail NewMiddleware.
+func ErrorMapping(code string, statusCode int, body string) Option {
+	return func(h *internal.WazeroOptions) {
+		if h.ErrorMappings == nil {
+			h.ErrorMappings = map[string]internal.ErrorMapping{}
+		}
+		h.ErrorMappings[code] = internal.ErrorMapping{StatusCode: statusCode, Body: body}
+	}
+}
+
+// HTTPCallHosts allows the guest to call the hosts via handler.FuncHTTPCall.
+// A host matches the URL with or without its port, and a leading "*."
+// matches any subdomain. Ex. "auth.internal:8443" or "*.example.com"
+//
+// By default, no hosts are allowed, so handler.FuncHTTPCall always fails.
+func HTTPCallHosts(hosts ...string) Option {
+	return func(h *internal.WazeroOptions) {
+		h.HTTPCall.Hosts = append(h.HTTPCall.Hosts, hosts...)
+	}
+}
+
+// HTTPCallTimeout sets the maximum time handler.FuncHTTPCall waits for a
+// response, including waiting for HTTPCallMaxConcurrency. Defaults to 30
+// seconds. Guests can request a lower timeout.
+func HTTPCallTimeout(timeout time.Duration) Option {
+	return func(h *internal.WazeroOptions) {
+		h.HTTPCall.Timeout = timeout
+	}
+}
+
+// HTTPCallMaxConcurrency limits how many handler.FuncHTTPCall requests can be
+// in flight at the same time, across all requests to the middleware. Calls
+// over the limit wait, until their timeout. Defaults to no limit.
+func HTTPCallMaxConcurrency(max int) Option {
+	return func(h *internal.WazeroOptions) {
+		h.HTTPCall.MaxConcurrency = max
+	}
+}
+
+// Resolver sets the resolver used by handler.FuncResolve. Defaults to
+// net.DefaultResolver.
+func Resolver(resolver *net.Resolver) Option {
+	return func(h *internal.WazeroOptions) {
+		h.Resolver = resolver
+	}
+}
+
+// GuestErrorStatus sets the status code of the response when the guest traps
+// before the response is committed. Defaults to 500. See handler.GuestError.
+func GuestErrorStatus(statusCode int) Option {
+	return func(h *internal.WazeroOptions) {
+		h.GuestErrorStatus = statusCode
+	}
+}
+
+// MaxHeaderMutations limits how many times a guest can set a response header,
+// cookie or trailer per request. When exceeded, the guest traps with
+// handler.ErrQuotaExceeded, so the request fails as configured by
+// GuestErrorStatus and FailurePolicy, unless it imports
+// handler.FuncGetLastError to handle the error itself. Defaults to unlimited.
+func MaxHeaderMutations(count int) Option {
+	return func(h *internal.WazeroOptions) {
+		h.Quotas.HeaderMutations = count
+	}
+}
+
+// MaxHeaderBytes limits the total length of the names and values of response
+// headers, cookies and trailers a guest sets per request. When exceeded, the
+// guest traps like MaxHeaderMutations. Defaults to unlimited.
+func MaxHeaderBytes(size int) Option {
+	return func(h *internal.WazeroOptions) {
+		h.Quotas.HeaderBytes = size
+	}
+}
+
+// MaxHeaderCount limits how many distinct response header and trailer names a
+// guest can set per request. When exceeded, the guest traps like
+// MaxHeaderMutations. Defaults to unlimited.
+func MaxHeaderCount(count int) Option {
+	return func(h *internal.WazeroOptions) {
+		h.Quotas.HeaderCount = count
+	}
+}
+
+// MaxHeaderNameBytes limits the length of the name of each response header or
+// trailer a guest sets. When exceeded, the guest traps with
+// handler.ErrInvalidHeader, unless it imports handler.FuncGetLastError like
+// MaxHeaderMutations. Defaults to unlimited.
+//
+// Note: Regardless of limits, the guest also traps if a name isn't a valid
+// HTTP token or a value contains control characters, such as line breaks.
+func MaxHeaderNameBytes(size int) Option {
+	return func(h *internal.WazeroOptions) {
+		h.Quotas.HeaderNameBytes = size
+	}
+}
+
+// MaxHeaderValueBytes is like MaxHeaderNameBytes, except it limits the length
+// of each value.
+func MaxHeaderValueBytes(size int) Option {
+	return func(h *internal.WazeroOptions) {
+		h.Quotas.HeaderValueBytes = size
+	}
+}
+
+// MaxResponseBodyBytes limits the total length of response bodies a guest
+// sends per request, via handler.FuncSendResponse. When exceeded, the guest
+// traps like MaxHeaderMutations. Defaults to unlimited.
+func MaxResponseBodyBytes(size int) Option {
+	return func(h *internal.WazeroOptions) {
+		h.Quotas.ResponseBodyBytes = size
+	}
+}
+
+// DecodeResponseBody decodes buffered response bodies encoded with gzip or
+// deflate, so that guests inspecting them, such as via
+// handler.FuncReadResponseBody, see the actual payload. The host encodes the
+// body again before sending it. See handler.FeatureDecodeResponse.
+//
+// Note: Decoding requires the host to support it, and adds latency and
+// memory proportional to the size of the body.
+func DecodeResponseBody() Option {
+	return func(h *internal.WazeroOptions) {
+		h.DecodeResponseBody = true
+	}
+}
+
+// DefaultBufferLimit sets the buffer size guests read via
+// handler.FuncGetBufferLimit, which SDKs use for values such as headers,
+// before retrying with a larger buffer. Defaults to 2048 bytes.
+//
+// For example, raising this avoids retries when requests have large cookies.
+func DefaultBufferLimit(size uint32) Option {
+	return func(h *internal.WazeroOptions) {
+		h.DefaultBufferLimit = size
+	}
+}
+
+// GrowBuffers allows guests which export handler.FuncMalloc to enable
+// handler.FeatureGrowBuffers, so that the host allocates memory for values
+// larger than their buffer, instead of the guest retrying.
+func GrowBuffers() Option {
+	return func(h *internal.WazeroOptions) {
+		h.GrowBuffers = true
+	}
+}
+
+// RequestID assigns each request an ID, which guests read via
+// handler.FuncGetRequestID. Defaults to no ID.
+//
+// The ID propagates from the "X-Request-Id" header, or the trace ID of the
+// "traceparent" header. Otherwise, the host generates a random one. The host
+// sets "X-Request-Id" on the request, for the next handler, and on the
+// response, for the client. Hosts also add the ID to the context.Context of
+// the request, so that the Logger, Metrics and the next handler can correlate
+// by it.
+func RequestID() Option {
+	return func(h *internal.WazeroOptions) {
+		h.RequestID = true
+	}
+}
+
+// ShadowMode runs the guest without enforcing it: changes it makes to the
+// request or response, such as setting headers or sending a response, are
+// recorded instead of applied, and passed to fn after each request. The next
+// handler is always invoked, even if the guest didn't call handler.FuncNext.
+//
+// This allows operators to evaluate a new policy against production traffic,
+// such as to count requests it would reject, before enforcing it.
+//
+// Note: Guests read the live request and response, so don't see their own
+// changes. Side effects outside the request, such as handler.FuncSetShared,
+// still apply.
+func ShadowMode(fn api.ShadowFunc) Option {
+	return func(h *internal.WazeroOptions) {
+		h.Shadow = fn
+	}
+}
+
+// LatencyBudget sets the maximum latency a guest should add to requests,
+// excluding the next handler. This is measured as the 99th percentile of
+// every 1000 requests, using the clock configured with Clock. fn is called
+// when the budget is first exceeded, and again when latency is back within
+// budget, so that operators can alert on the cost of the guest.
+func LatencyBudget(budget time.Duration, fn api.LatencyBudgetFunc) Option {
+	return func(h *internal.WazeroOptions) {
+		h.LatencyBudget.Budget = budget
+		h.LatencyBudget.Func = fn
+	}
+}
+
+// LatencyBudgetBypass bypasses the guest for the given duration, like
+// BypassDuring, each time LatencyBudget is exceeded. Afterwards, the guest
+// is measured again. This favors latency over enforcing the guest, so is only
+// appropriate for guests that aren't security controls.
+func LatencyBudgetBypass(d time.Duration) Option {
+	return func(h *internal.WazeroOptions) {
+		h.LatencyBudget.Bypass = d
+	}
+}
+
+// FailurePolicy sets what happens when a guest can't handle a request.
+// Defaults to api.FailClosed.
+//
+// With api.FailClosed, a request is rejected with 503 Service Unavailable if
+// its guest can't be instantiated, or GuestErrorStatus if the guest traps.
+// Creating a handler also fails if its guest can't be instantiated.
+//
+// With api.FailOpen, the next handler is invoked instead, unless the guest
+// already invoked it or committed the response.
+func FailurePolicy(policy api.FailurePolicy) Option {
+	return func(h *internal.WazeroOptions) {
+		h.FailurePolicy = policy
+	}
+}
+
+// Prewarm instantiates the given count of guests when the middleware is
+// created, so that the first handlers don't wait for instantiation. Each
+// handler takes a pre-warmed guest, if one is left. Defaults to zero.
+//
+// Note: This also applies when the guest is reloaded.
+func Prewarm(count int) Option {
+	return func(h *internal.WazeroOptions) {
+		h.Prewarm = count
+	}
+}
+
+// GuestPool keeps between min and max guests instantiated ahead of handlers,
+// growing and shrinking with demand. This is like Prewarm, except the pool
+// refills as handlers take guests. Defaults to no pool.
+//
+// When a handler has to wait for a guest to instantiate, as the pool is
+// empty, the pool doubles its size, up to max, so that a burst of traffic
+// doesn't wait for each guest. After idleTimeout passes without waiting, the
+// pool halves its size, down to min, closing idle guests, so that an idle
+// server doesn't hold memory for its peak. A non-positive idleTimeout
+// defaults to one minute.
+//
+// Note: Handlers, such as of a Registry, take guests from the pool when they
+// are created, so the pool doesn't bound the guests in use.
+func GuestPool(min, max int, idleTimeout time.Duration) Option {
+	return func(h *internal.WazeroOptions) {
+		h.Pool = internal.Pool{Min: min, Max: max, IdleTimeout: idleTimeout}
+	}
+}
+
+// Shards spreads guests across n independent wazero runtimes, each with its
+// own compiled code, instead of one. Under very high concurrency, this
+// reduces contention on locks inside the runtime. A non-positive n defaults
+// to the count of CPUs. Defaults to one runtime.
+//
+// The strategy chooses the runtime of each guest: api.ShardRoundRobin
+// assigns guests in turn, while api.ShardPerTenant isolates the guests of
+// each tenant or pattern of a Registry or NewRouterMiddleware, and is invalid
+// otherwise.
+//
+// Note: Each runtime compiles the guest, and Prewarm and GuestPool apply per
+// runtime, so this multiplies the memory they use.
+func Shards(n int, strategy api.ShardStrategy) Option {
+	return func(h *internal.WazeroOptions) {
+		if n <= 0 {
+			n = runtime.NumCPU()
+		}
+		h.Shards = internal.Shards{Count: n, Strategy: strategy}
+	}
+}
+
+// Snapshot initializes the guest once when the middleware is created, then
+// instantiates others from a snapshot of its memory and mutable globals,
+// instead of running "_start" and handler.FuncInit again. For guests with
+// heavy initialization, such as compiling regular expressions or parsing
+// config, this makes instantiation much faster.
+//
+// Note: Only use this for guests whose initialization leaves no other state,
+// such as open files or state in the host, as new guests won't have it.
+// NewMiddleware fails if a mutable global of the guest isn't a number, such
+// as a reference, as it can't be copied to new guests.
+func Snapshot() Option {
+	return func(h *internal.WazeroOptions) {
+		h.Snapshot = true
+	}
+}
+
+// MaxConcurrentGuests limits the guests handling requests at the same time,
+// so that a slow guest can't consume unbounded goroutines or memory. Excess
+// requests wait up to queueTimeout for a guest to finish, or are rejected
+// immediately if it isn't positive. Defaults to unlimited.
+//
+// Only guest execution counts against the limit: a guest doesn't hold its
+// slot while the next handler runs, and waits for one to continue after it.
+//
+// Rejected requests are handled per FailurePolicy: with api.FailClosed, they
+// are rejected with 503 Service Unavailable, and with api.FailOpen, the next
+// handler is invoked instead.
+//
+// Note: Each guest of a chain has its own limit.
+func MaxConcurrentGuests(max int, queueTimeout time.Duration) Option {
+	return func(h *internal.WazeroOptions) {
+		h.Concurrency = internal.Concurrency{Max: max, QueueTimeout: queueTimeout}
+	}
+}
+
+// WrapHost wraps the handler.Host guests call, such as to record calls with
+// replay.NewRecorder. Defaults to no wrapping.
+//
+// The wrapper must implement the optional interfaces of the host, such as
+// handler.Proxy, for guests to use them.
+func WrapHost(wrap func(handler.Host) handler.Host) Option {
+	return func(h *internal.WazeroOptions) {
+		h.WrapHost = wrap
+	}
+}
+
+// AllowedCapabilities restricts the host functions a guest may import to
+// those of the given capabilities, such as to forbid outbound network access
+// for an untrusted guest. Functions which don't require a capability are
+// always allowed. Defaults to all capabilities.
+//
+// A guest which imports a function of another capability fails to compile,
+// so NewMiddleware returns an error instead of the guest failing requests.
+func AllowedCapabilities(capabilities ...handler.Capability) Option {
+	return func(h *internal.WazeroOptions) {
+		h.AllowedCapabilities = append([]handler.Capability{}, capabilities...)
+	}
+}
@@ -0,0 +1,37 @@
+package httpwasm
+
+import (
+	"context"
+	"errors"
+
+	"github.com/http-wasm/http-wasm-host-go/api"
+	"github.com/http-wasm/http-wasm-host-go/internal"
+)
+
+// Precompile compiles the guest into the directory configured with
+// CompilationCache, so that a later NewMiddleware with the same options
+// starts without compiling it. For example, this can run in an init
+// container which shares the directory with the server.
+//
+// The guest is only compiled, not instantiated, so its functions don't run.
+func Precompile(ctx context.Context, guest []byte, options ...Option) (api.CompileReport, error) {
+	o := &internal.WazeroOptions{
+		NewRuntime:  internal.DefaultRuntime,
+		RuntimeMode: internal.DefaultRuntimeMode(),
+	}
+	for _, option := range options {
+		option(o)
+	}
+	if o.CompilationCacheDir == "" {
+		return api.CompileReport{}, errors.New("wasm: precompile requires a compilation cache")
+	}
+
+	r, err := o.CreateRuntime(ctx)
+	if err != nil {
+		return api.CompileReport{}, err
+	}
+	defer r.Close(ctx)
+
+	_, report, err := o.CompileGuest(ctx, r, guest)
+	return report, err
+}
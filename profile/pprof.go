@@ -0,0 +1,151 @@
+package profile
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+)
+
+// Field numbers of messages in profile.proto of github.com/google/pprof.
+const (
+	profileSampleType    = 1
+	profileSample        = 2
+	profileLocation      = 4
+	profileFunction      = 5
+	profileStringTable   = 6
+	profileTimeNanos     = 9
+	profileDurationNanos = 10
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID   = 1
+	locationLine = 4
+
+	lineFunctionID = 1
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+)
+
+// WritePprof writes the profile in the gzipped protocol buffer format read by
+// `go tool pprof`. Each sample is a call stack, with the count of calls and
+// the time spent in the leaf function, excluding functions it called.
+func (p *Profiler) WritePprof(w io.Writer) error {
+	p.mu.Lock()
+	b := p.encode()
+	p.mu.Unlock()
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(b); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// encode encodes the profile. This must be called with the lock held.
+func (p *Profiler) encode() []byte {
+	var e encoder
+	stringIndex := map[string]uint64{"": 0}
+	stringTable := []string{""}
+	str := func(s string) uint64 {
+		i, ok := stringIndex[s]
+		if !ok {
+			i = uint64(len(stringTable))
+			stringIndex[s] = i
+			stringTable = append(stringTable, s)
+		}
+		return i
+	}
+
+	for _, t := range [][2]string{{"calls", "count"}, {"time", "nanoseconds"}} {
+		var vt encoder
+		vt.uint64(valueTypeType, str(t[0]))
+		vt.uint64(valueTypeUnit, str(t[1]))
+		e.bytes(profileSampleType, vt)
+	}
+
+	// Each function has one location of the same ID, as there are no line
+	// numbers.
+	ids := map[string]uint64{}
+	keys := make([]string, 0, len(p.stacks))
+	for key := range p.stacks {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys) // for deterministic output
+	for _, key := range keys {
+		s := p.stacks[key]
+		var sample, locations encoder
+		for _, name := range s.names {
+			id, ok := ids[name]
+			if !ok {
+				id = uint64(len(ids) + 1)
+				ids[name] = id
+			}
+			locations.varint(id)
+		}
+		sample.bytes(sampleLocationID, locations)
+		var values encoder
+		values.varint(s.calls)
+		values.varint(uint64(s.self))
+		sample.bytes(sampleValue, values)
+		e.bytes(profileSample, sample)
+	}
+
+	names := make([]string, len(ids))
+	for name, id := range ids {
+		names[id-1] = name
+	}
+	for i, name := range names {
+		id := uint64(i + 1)
+		var line, location, function encoder
+		line.uint64(lineFunctionID, id)
+		location.uint64(locationID, id)
+		location.bytes(locationLine, line)
+		e.bytes(profileLocation, location)
+
+		function.uint64(functionID, id)
+		function.uint64(functionName, str(name))
+		function.uint64(functionSystemName, str(name))
+		e.bytes(profileFunction, function)
+	}
+
+	for _, s := range stringTable {
+		e.bytes(profileStringTable, []byte(s))
+	}
+	e.uint64(profileTimeNanos, uint64(p.start.UnixNano()))
+	e.uint64(profileDurationNanos, uint64(p.now().Sub(p.start)))
+	return e
+}
+
+// encoder appends protocol buffer fields.
+type encoder []byte
+
+// varint appends v as a varint, without a field key.
+func (e *encoder) varint(v uint64) {
+	for v >= 0x80 {
+		*e = append(*e, byte(v)|0x80)
+		v >>= 7
+	}
+	*e = append(*e, byte(v))
+}
+
+// uint64 appends a varint field, unless it is zero, the default.
+func (e *encoder) uint64(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	e.varint(uint64(field)<<3 | 0) // wire type varint
+	e.varint(v)
+}
+
+// bytes appends a length-delimited field, such as an embedded message.
+func (e *encoder) bytes(field int, b []byte) {
+	e.varint(uint64(field)<<3 | 2) // wire type length-delimited
+	e.varint(uint64(len(b)))
+	*e = append(*e, b...)
+}
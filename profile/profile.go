@@ -0,0 +1,174 @@
+// Package profile measures the time guests spend in their own functions
+// versus calls to the host, using wazero function listeners.
+//
+// To profile, pass a Profiler to httpwasm.Profiler, which requires the
+// interpreter:
+//
+//	p := profile.New()
+//	mw, err := wasm.NewMiddleware(ctx, guest, httpwasm.CompileMode(api.CompileModeInterpreter), httpwasm.Profiler(p))
+//	...
+//	for _, f := range p.Functions() {
+//		fmt.Println(f.Name, f.Self)
+//	}
+//
+// WritePprof writes the profile in the format of `go tool pprof`.
+package profile
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/experimental"
+)
+
+// compile-time check to ensure Profiler implements
+// experimental.FunctionListenerFactory.
+var _ experimental.FunctionListenerFactory = &Profiler{}
+
+// Function is the time spent in a guest or host function.
+type Function struct {
+	// Name is the module and function name. Ex. "http-handler.get_uri"
+	Name string
+	// Host is true when the function is implemented by the host, so its time
+	// is host call overhead from the point of view of the guest.
+	Host bool
+	// Calls is the count of calls which returned. Calls which trapped aren't
+	// counted.
+	Calls uint64
+	// Total is the time spent in the function, including functions it
+	// called.
+	Total time.Duration
+	// Self is the time spent in the function, excluding functions it called.
+	Self time.Duration
+}
+
+// Profiler accumulates the time spent in each function of the modules it
+// listens to, and their call stacks. It is safe for concurrent use.
+type Profiler struct {
+	mu        sync.Mutex
+	start     time.Time
+	functions map[string]*Function
+	// stacks are keyed by function names, from the leaf, joined by newline.
+	stacks map[string]*stack
+
+	now func() time.Time
+}
+
+// stack is the aggregate of calls with the same stack.
+type stack struct {
+	names []string
+	calls uint64
+	self  time.Duration
+}
+
+// New returns an empty Profiler.
+func New() *Profiler {
+	p := &Profiler{now: time.Now}
+	p.Reset()
+	return p
+}
+
+// Reset discards what was profiled, such as to profile a period of time.
+func (p *Profiler) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.start = p.now()
+	p.functions = map[string]*Function{}
+	p.stacks = map[string]*stack{}
+}
+
+// Functions returns the functions called, sorted by descending Self time.
+func (p *Profiler) Functions() []Function {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	functions := make([]Function, 0, len(p.functions))
+	for _, f := range p.functions {
+		functions = append(functions, *f)
+	}
+	sort.Slice(functions, func(i, j int) bool {
+		if functions[i].Self != functions[j].Self {
+			return functions[i].Self > functions[j].Self
+		}
+		return functions[i].Name < functions[j].Name
+	})
+	return functions
+}
+
+// NewListener implements experimental.FunctionListenerFactory.
+func (p *Profiler) NewListener(def api.FunctionDefinition) experimental.FunctionListener {
+	return &listener{p: p, name: def.DebugName(), host: def.GoFunc() != nil}
+}
+
+// record adds a call that returned.
+func (p *Profiler) record(f *frame, total time.Duration) {
+	var names []string
+	for s := f; s != nil; s = s.parent {
+		names = append(names, s.l.name)
+	}
+	key := strings.Join(names, "\n")
+	self := total - f.children
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fn, ok := p.functions[f.l.name]
+	if !ok {
+		fn = &Function{Name: f.l.name, Host: f.l.host}
+		p.functions[f.l.name] = fn
+	}
+	fn.Calls++
+	fn.Total += total
+	fn.Self += self
+
+	s, ok := p.stacks[key]
+	if !ok {
+		s = &stack{names: names}
+		p.stacks[key] = s
+	}
+	s.calls++
+	s.self += self
+}
+
+// frameKey is a context.Context Value associated with the *frame of the
+// function being called.
+type frameKey struct{}
+
+// frame is a call in progress.
+type frame struct {
+	l      *listener
+	parent *frame
+	start  time.Time
+	// children is the time spent in functions this called.
+	children time.Duration
+}
+
+// listener implements experimental.FunctionListener for a function.
+type listener struct {
+	p    *Profiler
+	name string
+	host bool
+}
+
+// Before implements the same method as documented on
+// experimental.FunctionListener.
+func (l *listener) Before(ctx context.Context, _ api.FunctionDefinition, _ []uint64) context.Context {
+	parent, _ := ctx.Value(frameKey{}).(*frame)
+	return context.WithValue(ctx, frameKey{}, &frame{l: l, parent: parent, start: l.p.now()})
+}
+
+// After implements the same method as documented on
+// experimental.FunctionListener.
+func (l *listener) After(ctx context.Context, _ api.FunctionDefinition, _ error, _ []uint64) {
+	f, ok := ctx.Value(frameKey{}).(*frame)
+	if !ok || f.l != l {
+		return // not the context returned by Before
+	}
+	total := l.p.now().Sub(f.start)
+	if f.parent != nil {
+		f.parent.children += total
+	}
+	l.p.record(f, total)
+}
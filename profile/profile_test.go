@@ -0,0 +1,83 @@
+package profile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero/api"
+)
+
+var testCtx = context.Background()
+
+// testDefinition is the api.FunctionDefinition of a guest function, or a
+// host function if goFunc is not nil.
+type testDefinition struct {
+	api.FunctionDefinition
+	name   string
+	goFunc *reflect.Value
+}
+
+func (d *testDefinition) DebugName() string           { return d.name }
+func (d *testDefinition) GoFunc() *reflect.Value      { return d.goFunc }
+func (d *testDefinition) String() string              { return d.name }
+func (d *testDefinition) ModuleName() string          { return "" }
+func (d *testDefinition) ParamTypes() []api.ValueType { return nil }
+
+func TestProfiler(t *testing.T) {
+	now := time.Unix(0, 0)
+	p := New()
+	p.now = func() time.Time { return now }
+	p.Reset()
+
+	hostFn := reflect.ValueOf(func() {})
+	handle := p.NewListener(&testDefinition{name: "guest.handle_request"})
+	log := p.NewListener(&testDefinition{name: "http-handler.log", goFunc: &hostFn})
+
+	// The guest takes 3ms, of which 1ms is each of two calls to the host.
+	ctx := handle.Before(testCtx, nil, nil)
+	for i := 0; i < 2; i++ {
+		now = now.Add(500 * time.Microsecond)
+		logCtx := log.Before(ctx, nil, nil)
+		now = now.Add(time.Millisecond)
+		log.After(logCtx, nil, nil, nil)
+	}
+	now = now.Add(time.Millisecond)
+	handle.After(ctx, nil, nil, nil)
+
+	// Functions with the same self time are sorted by name.
+	expected := []Function{
+		{Name: "guest.handle_request", Calls: 1, Total: 4 * time.Millisecond, Self: 2 * time.Millisecond},
+		{Name: "http-handler.log", Host: true, Calls: 2, Total: 2 * time.Millisecond, Self: 2 * time.Millisecond},
+	}
+	if have := p.Functions(); !reflect.DeepEqual(expected, have) {
+		t.Fatalf("expected %v, have %v", expected, have)
+	}
+
+	var buf bytes.Buffer
+	if err := p.WritePprof(&buf); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"guest.handle_request", "http-handler.log", "nanoseconds"} {
+		if !bytes.Contains(b, []byte(s)) {
+			t.Fatalf("expected pprof to include %q", s)
+		}
+	}
+
+	p.Reset()
+	if have := p.Functions(); len(have) != 0 {
+		t.Fatalf("expected no functions after reset, have %v", have)
+	}
+}
@@ -0,0 +1,141 @@
+// Package ratelimit includes implementations of api.RateLimiter, which back
+// the rate limits guests check via handler.FuncRateLimitCheck.
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/http-wasm/http-wasm-host-go/api"
+)
+
+// sweepInterval is the minimum time between removing buckets that refilled.
+const sweepInterval = time.Minute
+
+// compile-time check to ensure Memory implements api.RateLimiter.
+var _ api.RateLimiter = &Memory{}
+
+// Memory is an in-memory api.RateLimiter, which is shared by all guests
+// configured with it in the current process. Each key has a token bucket,
+// which starts full.
+//
+// Buckets can be saved with Snapshot, for example on shutdown, and loaded
+// with Restore on startup, so that clients limited before a restart of a
+// single-node deployment remain limited. See httpwasm.PersistStores.
+type Memory struct {
+	// rate is the tokens added to each bucket per second.
+	rate float64
+	// burst is the capacity of each bucket.
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+
+	// now is a field for testing.
+	now func() time.Time
+}
+
+type bucket struct {
+	Tokens float64   `json:"tokens"`
+	Last   time.Time `json:"last"`
+}
+
+// NewMemory returns a limiter which allows each key perSecond tokens, with
+// bursts of up to burst tokens.
+func NewMemory(perSecond float64, burst int) *Memory {
+	return &Memory{
+		rate:    perSecond,
+		burst:   float64(burst),
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+// Take implements the same method as documented on api.RateLimiter.
+func (m *Memory) Take(_ context.Context, key string, tokens uint32) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if now.Sub(m.lastSweep) >= sweepInterval {
+		m.sweep(now)
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{Tokens: m.burst, Last: now}
+		m.buckets[key] = b
+	} else {
+		b.Tokens = m.refill(b, now)
+		b.Last = now
+	}
+
+	if t := float64(tokens); t <= b.Tokens {
+		b.Tokens -= t
+		return true, nil
+	}
+	return false, nil
+}
+
+// refill returns the tokens of the bucket at the given time.
+func (m *Memory) refill(b *bucket, now time.Time) float64 {
+	tokens := b.Tokens
+	if elapsed := now.Sub(b.Last); elapsed > 0 {
+		tokens += elapsed.Seconds() * m.rate
+	}
+	if tokens > m.burst {
+		return m.burst
+	}
+	return tokens
+}
+
+// sweep removes buckets that refilled, as they are the same as missing ones.
+// This must be called with the lock held.
+func (m *Memory) sweep(now time.Time) {
+	for k, b := range m.buckets {
+		if m.refill(b, now) >= m.burst {
+			delete(m.buckets, k)
+		}
+	}
+	m.lastSweep = now
+}
+
+// Snapshot writes the buckets that haven't refilled to w, in a format read by
+// Restore.
+func (m *Memory) Snapshot(w io.Writer) error {
+	m.mu.Lock()
+	m.sweep(m.now())
+	err := json.NewEncoder(w).Encode(m.buckets)
+	m.mu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("ratelimit: error writing snapshot: %w", err)
+	}
+	return nil
+}
+
+// Restore reads buckets written by Snapshot from r, replacing any with the
+// same key. Buckets refill for the time since the snapshot, so those that
+// refilled are skipped.
+func (m *Memory) Restore(r io.Reader) error {
+	var buckets map[string]*bucket
+	if err := json.NewDecoder(r).Decode(&buckets); err != nil {
+		return fmt.Errorf("ratelimit: error reading snapshot: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	for k, b := range buckets {
+		if b != nil && m.refill(b, now) < m.burst {
+			m.buckets[k] = b
+		}
+	}
+	return nil
+}
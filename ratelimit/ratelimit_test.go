@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+var testCtx = context.Background()
+
+func TestMemory(t *testing.T) {
+	now := time.Unix(0, 0)
+	m := NewMemory(2, 4)
+	m.now = func() time.Time { return now }
+
+	take := func(key string, tokens uint32, expected bool) {
+		t.Helper()
+		if allowed, _ := m.Take(testCtx, key, tokens); allowed != expected {
+			t.Fatalf("expected Take(%q, %d) to be %v", key, tokens, expected)
+		}
+	}
+
+	// The bucket starts full.
+	take("a", 3, true)
+	take("a", 2, false) // only one left
+	take("a", 1, true)
+	take("a", 1, false)
+
+	// Keys have separate buckets.
+	take("b", 4, true)
+	take("b", 5, false) // more than the burst
+
+	// Half a second adds one token.
+	now = now.Add(500 * time.Millisecond)
+	take("a", 1, true)
+	take("a", 1, false)
+
+	// Buckets don't exceed the burst.
+	now = now.Add(time.Hour)
+	take("a", 5, false)
+	take("a", 4, true)
+}
+
+func TestMemory_Sweep(t *testing.T) {
+	now := time.Unix(0, 0)
+	m := NewMemory(1, 1)
+	m.now = func() time.Time { return now }
+
+	_, _ = m.Take(testCtx, "a", 1)
+	now = now.Add(sweepInterval)
+	_, _ = m.Take(testCtx, "b", 1)
+
+	if _, ok := m.buckets["a"]; ok {
+		t.Fatal("expected refilled bucket to be removed")
+	}
+	if _, ok := m.buckets["b"]; !ok {
+		t.Fatal("expected bucket in use to remain")
+	}
+}
+
+func TestMemory_SnapshotRestore(t *testing.T) {
+	now := time.Unix(0, 0)
+	m := NewMemory(1, 4)
+	m.now = func() time.Time { return now }
+
+	_, _ = m.Take(testCtx, "limited", 4)
+	_, _ = m.Take(testCtx, "almost", 1)
+
+	var buf bytes.Buffer
+	if err := m.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restore after "almost" refilled, and "limited" gained two tokens.
+	restored := NewMemory(1, 4)
+	restored.now = func() time.Time { return now.Add(2 * time.Second) }
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := restored.buckets["almost"]; ok {
+		t.Fatal("expected refilled bucket to be skipped")
+	}
+	if allowed, _ := restored.Take(testCtx, "limited", 3); allowed {
+		t.Fatal("expected restored bucket to remain limited")
+	}
+	if allowed, _ := restored.Take(testCtx, "limited", 2); !allowed {
+		t.Fatal("expected restored bucket to refill since the snapshot")
+	}
+
+	if err := restored.Restore(bytes.NewReader([]byte("garbage"))); err == nil {
+		t.Fatal("expected error restoring invalid snapshot")
+	}
+}
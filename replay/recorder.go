@@ -0,0 +1,429 @@
+package replay
+
+import (
+	"context"
+	"time"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	internalhandler "github.com/http-wasm/http-wasm-host-go/internal/handler"
+)
+
+// NewRecorder returns a handler.Host which records calls to the host into the
+// Recording of the request's context, if any. Use this with
+// httpwasm.WrapHost.
+//
+// The result implements all optional interfaces of handler.Host. Calls to
+// those the host doesn't implement are recorded, and fall back as documented
+// with the interfaces.
+func NewRecorder(host handler.Host) handler.Host {
+	return &recorder{host: internalhandler.NewFullHost(host)}
+}
+
+type recorder struct {
+	host internalhandler.FullHost
+}
+
+// record appends the call to the recording of the request, if any, and
+// returns it so that results can be set.
+func (r *recorder) record(ctx context.Context, method string, args ...interface{}) *Call {
+	rec := recordingFromContext(ctx)
+	if rec == nil || rec.committing {
+		return &Call{} // discarded
+	}
+	c := &Call{Method: method, Args: formatArgs(args...)}
+	rec.Calls = append(rec.Calls, c)
+	return c
+}
+
+// GetRequestHeader implements the same method as documented on handler.Host.
+func (r *recorder) GetRequestHeader(ctx context.Context, name string) (string, bool) {
+	c := r.record(ctx, "GetRequestHeader", name)
+	c.Value, c.OK = r.host.GetRequestHeader(ctx, name)
+	return c.Value, c.OK
+}
+
+// GetRequestHeaders implements the same method as documented on
+// handler.Host.
+func (r *recorder) GetRequestHeaders(ctx context.Context) map[string][]string {
+	c := r.record(ctx, "GetRequestHeaders")
+	headers := r.host.GetRequestHeaders(ctx)
+	c.Header = make(map[string][]string, len(headers))
+	for name, values := range headers {
+		c.Header[name] = append([]string{}, values...)
+	}
+	return headers
+}
+
+// SetResponseHeader implements the same method as documented on handler.Host.
+func (r *recorder) SetResponseHeader(ctx context.Context, name, value string) {
+	r.record(ctx, "SetResponseHeader", name, value)
+	r.host.SetResponseHeader(ctx, name, value)
+}
+
+// Next implements the same method as documented on handler.Host.
+func (r *recorder) Next(ctx context.Context) {
+	r.record(ctx, "Next")
+	r.host.Next(ctx)
+}
+
+// SendResponse implements the same method as documented on handler.Host.
+func (r *recorder) SendResponse(ctx context.Context, statusCode uint32, body []byte) {
+	r.record(ctx, "SendResponse", statusCode, body)
+	r.host.SendResponse(ctx, statusCode, body)
+}
+
+// MirrorRequest implements the same method as documented on handler.Proxy.
+func (r *recorder) MirrorRequest(ctx context.Context, url string) {
+	r.record(ctx, "MirrorRequest", url)
+	r.host.MirrorRequest(ctx, url)
+}
+
+// GetStatusCode implements the same method as documented on handler.Host.
+func (r *recorder) GetStatusCode(ctx context.Context) uint32 {
+	c := r.record(ctx, "GetStatusCode")
+	statusCode := r.host.GetStatusCode(ctx)
+	c.Number = uint64(statusCode)
+	return statusCode
+}
+
+// SetStatusCode implements the same method as documented on handler.Host.
+func (r *recorder) SetStatusCode(ctx context.Context, statusCode uint32) {
+	r.record(ctx, "SetStatusCode", statusCode)
+	r.host.SetStatusCode(ctx, statusCode)
+}
+
+// IsResponseCommitted implements the same method as documented on
+// handler.Host.
+func (r *recorder) IsResponseCommitted(ctx context.Context) bool {
+	c := r.record(ctx, "IsResponseCommitted")
+	c.OK = r.host.IsResponseCommitted(ctx)
+	return c.OK
+}
+
+// GetResponseHeaders implements the same method as documented on
+// handler.Host.
+func (r *recorder) GetResponseHeaders(ctx context.Context) map[string][]string {
+	c := r.record(ctx, "GetResponseHeaders")
+	headers := r.host.GetResponseHeaders(ctx)
+	c.Header = make(map[string][]string, len(headers))
+	for name, values := range headers {
+		c.Header[name] = append([]string{}, values...)
+	}
+	return headers
+}
+
+// GetResponseBodySize implements the same method as documented on
+// handler.Host.
+func (r *recorder) GetResponseBodySize(ctx context.Context) uint64 {
+	c := r.record(ctx, "GetResponseBodySize")
+	size := r.host.GetResponseBodySize(ctx)
+	c.Number = size
+	return size
+}
+
+// GetRequestBodySize implements the same method as documented on
+// handler.Host.
+func (r *recorder) GetRequestBodySize(ctx context.Context) int64 {
+	c := r.record(ctx, "GetRequestBodySize")
+	size := r.host.GetRequestBodySize(ctx)
+	c.Number = uint64(size)
+	return size
+}
+
+// IsRequestChunked implements the same method as documented on
+// handler.ConnectionInfo.
+func (r *recorder) IsRequestChunked(ctx context.Context) bool {
+	c := r.record(ctx, "IsRequestChunked")
+	c.OK = r.host.IsRequestChunked(ctx)
+	return c.OK
+}
+
+// GetQueryParams implements the same method as documented on handler.Host.
+func (r *recorder) GetQueryParams(ctx context.Context) map[string][]string {
+	c := r.record(ctx, "GetQueryParams")
+	params := r.host.GetQueryParams(ctx)
+	c.Header = make(map[string][]string, len(params))
+	for name, values := range params {
+		c.Header[name] = append([]string{}, values...)
+	}
+	return params
+}
+
+// SetQueryValue implements the same method as documented on handler.Host.
+func (r *recorder) SetQueryValue(ctx context.Context, name, value string) {
+	r.record(ctx, "SetQueryValue", name, value)
+	r.host.SetQueryValue(ctx, name, value)
+}
+
+// SetUpstream implements the same method as documented on handler.Proxy.
+func (r *recorder) SetUpstream(ctx context.Context, upstream string) {
+	r.record(ctx, "SetUpstream", upstream)
+	r.host.SetUpstream(ctx, upstream)
+}
+
+// SetTimeout implements the same method as documented on handler.Proxy.
+func (r *recorder) SetTimeout(ctx context.Context, timeout time.Duration) {
+	r.record(ctx, "SetTimeout", timeout)
+	r.host.SetTimeout(ctx, timeout)
+}
+
+// SetRetryPolicy implements the same method as documented on handler.Proxy.
+func (r *recorder) SetRetryPolicy(ctx context.Context, policy handler.RetryPolicy) {
+	r.record(ctx, "SetRetryPolicy", policy.Attempts, policy.Backoff)
+	r.host.SetRetryPolicy(ctx, policy)
+}
+
+// SetCookie implements the same method as documented on handler.CookieSetter.
+func (r *recorder) SetCookie(ctx context.Context, name, value, attrs string) {
+	r.record(ctx, "SetCookie", name, value, attrs)
+	r.host.SetCookie(ctx, name, value, attrs)
+}
+
+// ReadScratch implements the same method as documented on handler.Scratch.
+func (r *recorder) ReadScratch(ctx context.Context) []byte {
+	c := r.record(ctx, "ReadScratch")
+	scratch := r.host.ReadScratch(ctx)
+	c.Bytes = append([]byte{}, scratch...)
+	return scratch
+}
+
+// WriteScratch implements the same method as documented on handler.Scratch.
+func (r *recorder) WriteScratch(ctx context.Context, data []byte) {
+	r.record(ctx, "WriteScratch", data)
+	r.host.WriteScratch(ctx, data)
+}
+
+// GetSourceAddr implements the same method as documented on
+// handler.ConnectionInfo.
+func (r *recorder) GetSourceAddr(ctx context.Context) string {
+	c := r.record(ctx, "GetSourceAddr")
+	c.Value = r.host.GetSourceAddr(ctx)
+	return c.Value
+}
+
+// GetListenerAddr implements the same method as documented on
+// handler.ConnectionInfo.
+func (r *recorder) GetListenerAddr(ctx context.Context) string {
+	c := r.record(ctx, "GetListenerAddr")
+	c.Value = r.host.GetListenerAddr(ctx)
+	return c.Value
+}
+
+// IsUnixSocket implements the same method as documented on
+// handler.ConnectionInfo.
+func (r *recorder) IsUnixSocket(ctx context.Context) bool {
+	c := r.record(ctx, "IsUnixSocket")
+	c.OK = r.host.IsUnixSocket(ctx)
+	return c.OK
+}
+
+// GetProxySourceAddr implements the same method as documented on
+// handler.ConnectionInfo.
+func (r *recorder) GetProxySourceAddr(ctx context.Context) (string, bool) {
+	c := r.record(ctx, "GetProxySourceAddr")
+	c.Value, c.OK = r.host.GetProxySourceAddr(ctx)
+	return c.Value, c.OK
+}
+
+// GetTLSVersion implements the same method as documented on
+// handler.ConnectionInfo.
+func (r *recorder) GetTLSVersion(ctx context.Context) uint32 {
+	c := r.record(ctx, "GetTLSVersion")
+	version := r.host.GetTLSVersion(ctx)
+	c.Number = uint64(version)
+	return version
+}
+
+// GetTLSPeerCert implements the same method as documented on
+// handler.ConnectionInfo.
+func (r *recorder) GetTLSPeerCert(ctx context.Context) []byte {
+	c := r.record(ctx, "GetTLSPeerCert")
+	cert := r.host.GetTLSPeerCert(ctx)
+	c.Bytes = append([]byte{}, cert...)
+	return cert
+}
+
+// GetProtocolVersion implements the same method as documented on
+// handler.ConnectionInfo.
+func (r *recorder) GetProtocolVersion(ctx context.Context) string {
+	c := r.record(ctx, "GetProtocolVersion")
+	c.Value = r.host.GetProtocolVersion(ctx)
+	return c.Value
+}
+
+// GetRPC implements the same method as documented on handler.RequestInfo.
+func (r *recorder) GetRPC(ctx context.Context) (service, method string) {
+	c := r.record(ctx, "GetRPC")
+	service, method = r.host.GetRPC(ctx)
+	if service != "" {
+		c.Value = service + "/" + method
+	}
+	return service, method
+}
+
+// GetRoute implements the same method as documented on handler.RequestInfo.
+func (r *recorder) GetRoute(ctx context.Context) string {
+	c := r.record(ctx, "GetRoute")
+	c.Value = r.host.GetRoute(ctx)
+	return c.Value
+}
+
+// GetRequestID implements the same method as documented on
+// handler.RequestInfo.
+func (r *recorder) GetRequestID(ctx context.Context) string {
+	c := r.record(ctx, "GetRequestID")
+	c.Value = r.host.GetRequestID(ctx)
+	return c.Value
+}
+
+// GetRawRequestHeaders implements the same method as documented on
+// handler.RawHeaders.
+func (r *recorder) GetRawRequestHeaders(ctx context.Context) []handler.HeaderField {
+	c := r.record(ctx, "GetRawRequestHeaders")
+	c.Fields = r.host.GetRawRequestHeaders(ctx)
+	return c.Fields
+}
+
+// SetRawResponseHeader implements the same method as documented on
+// handler.RawHeaders.
+func (r *recorder) SetRawResponseHeader(ctx context.Context, name, value string) {
+	r.record(ctx, "SetRawResponseHeader", name, value)
+	r.host.SetRawResponseHeader(ctx, name, value)
+}
+
+// GetMethod implements the same method as documented on handler.Host.
+func (r *recorder) GetMethod(ctx context.Context) string {
+	c := r.record(ctx, "GetMethod")
+	c.Value = r.host.GetMethod(ctx)
+	return c.Value
+}
+
+// GetMultipartPart implements the same method as documented on handler.Forms.
+func (r *recorder) GetMultipartPart(ctx context.Context, name string) ([]byte, bool) {
+	c := r.record(ctx, "GetMultipartPart", name)
+	part, ok := r.host.GetMultipartPart(ctx, name)
+	c.Bytes, c.OK = append([]byte{}, part...), ok
+	return part, ok
+}
+
+// GetFormValue implements the same method as documented on handler.Forms.
+func (r *recorder) GetFormValue(ctx context.Context, name string) (string, bool) {
+	c := r.record(ctx, "GetFormValue", name)
+	c.Value, c.OK = r.host.GetFormValue(ctx, name)
+	return c.Value, c.OK
+}
+
+// GetUploadedFileInfo implements the same method as documented on
+// handler.Forms.
+func (r *recorder) GetUploadedFileInfo(ctx context.Context, name string) (handler.FileInfo, bool) {
+	c := r.record(ctx, "GetUploadedFileInfo", name)
+	info, ok := r.host.GetUploadedFileInfo(ctx, name)
+	if c.OK = ok; ok {
+		c.File = &info
+	}
+	return info, ok
+}
+
+// GetRequestTrailer implements the same method as documented on
+// handler.Trailers.
+func (r *recorder) GetRequestTrailer(ctx context.Context, name string) (string, bool) {
+	c := r.record(ctx, "GetRequestTrailer", name)
+	c.Value, c.OK = r.host.GetRequestTrailer(ctx, name)
+	return c.Value, c.OK
+}
+
+// SetResponseTrailer implements the same method as documented on
+// handler.Trailers.
+func (r *recorder) SetResponseTrailer(ctx context.Context, name, value string) {
+	r.record(ctx, "SetResponseTrailer", name, value)
+	r.host.SetResponseTrailer(ctx, name, value)
+}
+
+// GetRequestBody implements the same method as documented on handler.Host.
+func (r *recorder) GetRequestBody(ctx context.Context) []byte {
+	c := r.record(ctx, "GetRequestBody")
+	body := r.host.GetRequestBody(ctx)
+	c.Bytes = append([]byte{}, body...)
+	return body
+}
+
+// StreamRequestBody implements the same method as documented on
+// handler.RequestBodyStreamer.
+func (r *recorder) StreamRequestBody(ctx context.Context, chunkLimit uint32, onChunk func(chunk []byte, eos bool) []byte) {
+	c := r.record(ctx, "StreamRequestBody", chunkLimit)
+	r.host.StreamRequestBody(ctx, chunkLimit, func(chunk []byte, eos bool) []byte {
+		c.Chunks = append(c.Chunks, Chunk{Data: append([]byte{}, chunk...), EOS: eos})
+		return onChunk(chunk, eos)
+	})
+}
+
+// ReadRequestBody implements the same method as documented on handler.Host.
+func (r *recorder) ReadRequestBody(ctx context.Context, alloc func(size uint32) []byte) uint32 {
+	c := r.record(ctx, "ReadRequestBody")
+	var buf []byte
+	size := r.host.ReadRequestBody(ctx, func(size uint32) []byte {
+		buf = alloc(size)
+		return buf
+	})
+	c.Number = uint64(size)
+	c.Bytes = append([]byte{}, buf[:size]...)
+	return size
+}
+
+// GetProperties implements the same method as documented on
+// handler.Properties.
+func (r *recorder) GetProperties(ctx context.Context) map[string]string {
+	c := r.record(ctx, "GetProperties")
+	properties := r.host.GetProperties(ctx)
+	c.Properties = make(map[string]string, len(properties))
+	for name, value := range properties {
+		c.Properties[name] = value
+	}
+	return properties
+}
+
+// SetProperty implements the same method as documented on handler.Properties.
+func (r *recorder) SetProperty(ctx context.Context, name, value string) {
+	r.record(ctx, "SetProperty", name, value)
+	r.host.SetProperty(ctx, name, value)
+}
+
+// BeforeCommit implements the same method as documented on handler.Host.
+// Calls made by fn aren't recorded, as the host makes them, not the guest.
+func (r *recorder) BeforeCommit(ctx context.Context, fn func()) {
+	r.record(ctx, "BeforeCommit")
+	rec := recordingFromContext(ctx)
+	if rec == nil {
+		r.host.BeforeCommit(ctx, fn)
+		return
+	}
+	r.host.BeforeCommit(ctx, func() {
+		rec.committing = true
+		defer func() { rec.committing = false }()
+		fn()
+	})
+}
+
+// EnableFeatures implements the same method as documented on handler.Host.
+func (r *recorder) EnableFeatures(ctx context.Context, features handler.Features) handler.Features {
+	c := r.record(ctx, "EnableFeatures", uint64(features))
+	enabled := r.host.EnableFeatures(ctx, features)
+	c.Number = uint64(enabled)
+	return enabled
+}
+
+// Capabilities implements the same method as documented on handler.Host.
+func (r *recorder) Capabilities(ctx context.Context) handler.Features {
+	c := r.record(ctx, "Capabilities")
+	features := r.host.Capabilities(ctx)
+	c.Number = uint64(features)
+	return features
+}
+
+// GetResponseBody implements the same method as documented on handler.Host.
+func (r *recorder) GetResponseBody(ctx context.Context) []byte {
+	c := r.record(ctx, "GetResponseBody")
+	body := r.host.GetResponseBody(ctx)
+	c.Bytes = append([]byte{}, body...)
+	return body
+}
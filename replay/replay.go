@@ -0,0 +1,120 @@
+// Package replay records the calls a guest makes to its host during a
+// request, so that the request can be replayed offline, such as to debug a
+// guest which fails in production but not locally.
+//
+// To record, wrap the host with NewRecorder, and add a Recording to the
+// context of each request to record:
+//
+//	mw, err := wasm.NewMiddleware(ctx, guest, httpwasm.WrapHost(replay.NewRecorder))
+//	...
+//	ctx, rec := replay.WithRecording(req.Context())
+//	mw.ServeHTTP(w, req.WithContext(ctx)) // via a handler of mw
+//	json.NewEncoder(f).Encode(rec)
+//
+// To replay, decode the recording and pass it to Replay with the same guest.
+package replay
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+)
+
+// Recording is the calls to the host during a request, in order. This is
+// encoded as JSON.
+//
+// Note: When guests are chained, this includes the calls of all guests.
+type Recording struct {
+	Calls []*Call `json:"calls"`
+
+	// committing is true while callbacks registered with BeforeCommit run,
+	// as their calls are made by the host, not the guest.
+	committing bool
+}
+
+// Call is a call to a method of handler.Host and its results.
+type Call struct {
+	// Method is the name of the method. Ex. "GetRequestHeader"
+	Method string `json:"method"`
+	// Args are the arguments after the context, except functions, formatted
+	// as strings.
+	Args []string `json:"args,omitempty"`
+
+	// Value is the result of methods which return a string. For GetRPC, this
+	// is the service and method joined by a slash. Ex. "acme.v1.Greeter/Hi"
+	Value string `json:"value,omitempty"`
+	// Bytes is the result of methods which return bytes, or the body read by
+	// ReadRequestBody.
+	Bytes []byte `json:"bytes,omitempty"`
+	// Number is the result of methods which return a number or
+	// handler.Features.
+	Number uint64 `json:"number,omitempty"`
+	// OK is the boolean result, such as whether a header exists.
+	OK bool `json:"ok,omitempty"`
+	// Header is the result of GetRequestHeaders, GetResponseHeaders or
+	// GetQueryParams.
+	Header map[string][]string `json:"header,omitempty"`
+	// Properties is the result of GetProperties.
+	Properties map[string]string `json:"properties,omitempty"`
+	// Fields is the result of GetRawRequestHeaders.
+	Fields []handler.HeaderField `json:"fields,omitempty"`
+	// Chunks are the chunks StreamRequestBody passed to the guest.
+	Chunks []Chunk `json:"chunks,omitempty"`
+	// File is the result of GetUploadedFileInfo.
+	File *handler.FileInfo `json:"file,omitempty"`
+}
+
+// Chunk is a chunk of the request body passed to the guest.
+type Chunk struct {
+	Data []byte `json:"data,omitempty"`
+	EOS  bool   `json:"eos,omitempty"`
+}
+
+// String returns the call in Go syntax, without results.
+// Ex. `GetRequestHeader("Accept")`
+func (c *Call) String() string {
+	args := make([]string, len(c.Args))
+	for i, arg := range c.Args {
+		args[i] = fmt.Sprintf("%q", arg)
+	}
+	return c.Method + "(" + strings.Join(args, ", ") + ")"
+}
+
+// recordingKey is a context.Context Value associated with the Recording of
+// the current request.
+type recordingKey struct{}
+
+// WithRecording returns a context which records calls made by the guest
+// handling a request with it, when the host was wrapped with NewRecorder.
+func WithRecording(ctx context.Context) (context.Context, *Recording) {
+	rec := &Recording{}
+	return context.WithValue(ctx, recordingKey{}, rec), rec
+}
+
+// recordingFromContext returns the recording of the current request, or nil
+// if it isn't recorded.
+func recordingFromContext(ctx context.Context) *Recording {
+	rec, _ := ctx.Value(recordingKey{}).(*Recording)
+	return rec
+}
+
+// formatArgs formats arguments as strings for Call.Args.
+func formatArgs(args ...interface{}) []string {
+	if len(args) == 0 {
+		return nil
+	}
+	s := make([]string, len(args))
+	for i, arg := range args {
+		switch arg := arg.(type) {
+		case string:
+			s[i] = arg
+		case []byte:
+			s[i] = string(arg)
+		default:
+			s[i] = fmt.Sprint(arg)
+		}
+	}
+	return s
+}
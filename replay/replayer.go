@@ -0,0 +1,377 @@
+package replay
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	httpwasm "github.com/http-wasm/http-wasm-host-go"
+	"github.com/http-wasm/http-wasm-host-go/api/handler"
+	internalhandler "github.com/http-wasm/http-wasm-host-go/internal/handler"
+)
+
+// Replay handles a request with the guest, answering its calls to the host
+// from the recording. The options are the same as wasm.NewMiddleware.
+//
+// This returns a handler.GuestError if the guest trapped, or an error
+// describing the first call which diverged from the recording, such as when
+// the guest changed.
+func Replay(ctx context.Context, guest []byte, rec *Recording, options ...httpwasm.Option) error {
+	p := NewReplayer(rec)
+	r, err := internalhandler.NewRuntime(ctx, guest, p, options...)
+	if err != nil {
+		return err
+	}
+	defer r.Close(ctx)
+
+	g, err := r.NewGuest(ctx)
+	if err != nil {
+		return err
+	}
+	defer g.Close(ctx)
+
+	if err = g.Handle(ctx); err != nil {
+		return err
+	}
+	return p.Err()
+}
+
+// Replayer is a handler.Host which answers calls from a Recording, in order.
+type Replayer struct {
+	rec *Recording
+	pos int
+	err error
+}
+
+// compile-time check to ensure Replayer implements handler.Host and all its
+// optional interfaces, as recordings may include calls to any of them.
+var (
+	_ handler.Host                = &Replayer{}
+	_ handler.ConnectionInfo      = &Replayer{}
+	_ handler.RequestInfo         = &Replayer{}
+	_ handler.Proxy               = &Replayer{}
+	_ handler.CookieSetter        = &Replayer{}
+	_ handler.RawHeaders          = &Replayer{}
+	_ handler.Forms               = &Replayer{}
+	_ handler.Trailers            = &Replayer{}
+	_ handler.Properties          = &Replayer{}
+	_ handler.Scratch             = &Replayer{}
+	_ handler.RequestBodyStreamer = &Replayer{}
+)
+
+// NewReplayer returns a handler.Host which answers calls from the recording.
+func NewReplayer(rec *Recording) *Replayer {
+	return &Replayer{rec: rec}
+}
+
+// Err returns an error describing the first call which diverged from the
+// recording, or calls in the recording which weren't made.
+func (p *Replayer) Err() error {
+	if p.err != nil {
+		return p.err
+	}
+	if p.pos < len(p.rec.Calls) {
+		return fmt.Errorf("replay: expected call %d %s", p.pos, p.rec.Calls[p.pos])
+	}
+	return nil
+}
+
+// replay returns the next recorded call, or an empty one if it diverged from
+// the call made.
+func (p *Replayer) replay(method string, args ...interface{}) *Call {
+	if p.err != nil {
+		return &Call{}
+	}
+	actual := &Call{Method: method, Args: formatArgs(args...)}
+	if p.pos >= len(p.rec.Calls) {
+		p.err = fmt.Errorf("replay: unexpected call %d %s", p.pos, actual)
+		return &Call{}
+	}
+	expected := p.rec.Calls[p.pos]
+	if expected.String() != actual.String() {
+		p.err = fmt.Errorf("replay: call %d diverged: expected %s, have %s", p.pos, expected, actual)
+		return &Call{}
+	}
+	p.pos++
+	return expected
+}
+
+// GetRequestHeader implements the same method as documented on handler.Host.
+func (p *Replayer) GetRequestHeader(_ context.Context, name string) (string, bool) {
+	c := p.replay("GetRequestHeader", name)
+	return c.Value, c.OK
+}
+
+// GetRequestHeaders implements the same method as documented on
+// handler.Host.
+func (p *Replayer) GetRequestHeaders(context.Context) map[string][]string {
+	return p.replay("GetRequestHeaders").Header
+}
+
+// SetResponseHeader implements the same method as documented on handler.Host.
+func (p *Replayer) SetResponseHeader(_ context.Context, name, value string) {
+	p.replay("SetResponseHeader", name, value)
+}
+
+// Next implements the same method as documented on handler.Host.
+func (p *Replayer) Next(context.Context) {
+	p.replay("Next")
+}
+
+// SendResponse implements the same method as documented on handler.Host.
+func (p *Replayer) SendResponse(_ context.Context, statusCode uint32, body []byte) {
+	p.replay("SendResponse", statusCode, body)
+}
+
+// MirrorRequest implements the same method as documented on handler.Proxy.
+func (p *Replayer) MirrorRequest(_ context.Context, url string) {
+	p.replay("MirrorRequest", url)
+}
+
+// GetStatusCode implements the same method as documented on handler.Host.
+func (p *Replayer) GetStatusCode(context.Context) uint32 {
+	return uint32(p.replay("GetStatusCode").Number)
+}
+
+// SetStatusCode implements the same method as documented on handler.Host.
+func (p *Replayer) SetStatusCode(_ context.Context, statusCode uint32) {
+	p.replay("SetStatusCode", statusCode)
+}
+
+// IsResponseCommitted implements the same method as documented on
+// handler.Host.
+func (p *Replayer) IsResponseCommitted(context.Context) bool {
+	return p.replay("IsResponseCommitted").OK
+}
+
+// GetResponseHeaders implements the same method as documented on
+// handler.Host.
+func (p *Replayer) GetResponseHeaders(context.Context) map[string][]string {
+	return p.replay("GetResponseHeaders").Header
+}
+
+// GetResponseBodySize implements the same method as documented on
+// handler.Host.
+func (p *Replayer) GetResponseBodySize(context.Context) uint64 {
+	return p.replay("GetResponseBodySize").Number
+}
+
+// GetRequestBodySize implements the same method as documented on
+// handler.Host.
+func (p *Replayer) GetRequestBodySize(context.Context) int64 {
+	return int64(p.replay("GetRequestBodySize").Number)
+}
+
+// IsRequestChunked implements the same method as documented on
+// handler.ConnectionInfo.
+func (p *Replayer) IsRequestChunked(context.Context) bool {
+	return p.replay("IsRequestChunked").OK
+}
+
+// GetQueryParams implements the same method as documented on handler.Host.
+func (p *Replayer) GetQueryParams(context.Context) map[string][]string {
+	return p.replay("GetQueryParams").Header
+}
+
+// SetQueryValue implements the same method as documented on handler.Host.
+func (p *Replayer) SetQueryValue(_ context.Context, name, value string) {
+	p.replay("SetQueryValue", name, value)
+}
+
+// SetUpstream implements the same method as documented on handler.Proxy.
+func (p *Replayer) SetUpstream(_ context.Context, upstream string) {
+	p.replay("SetUpstream", upstream)
+}
+
+// SetTimeout implements the same method as documented on handler.Proxy.
+func (p *Replayer) SetTimeout(_ context.Context, timeout time.Duration) {
+	p.replay("SetTimeout", timeout)
+}
+
+// SetRetryPolicy implements the same method as documented on handler.Proxy.
+func (p *Replayer) SetRetryPolicy(_ context.Context, policy handler.RetryPolicy) {
+	p.replay("SetRetryPolicy", policy.Attempts, policy.Backoff)
+}
+
+// SetCookie implements the same method as documented on handler.CookieSetter.
+func (p *Replayer) SetCookie(_ context.Context, name, value, attrs string) {
+	p.replay("SetCookie", name, value, attrs)
+}
+
+// ReadScratch implements the same method as documented on handler.Scratch.
+func (p *Replayer) ReadScratch(context.Context) []byte {
+	return p.replay("ReadScratch").Bytes
+}
+
+// WriteScratch implements the same method as documented on handler.Scratch.
+func (p *Replayer) WriteScratch(_ context.Context, data []byte) {
+	p.replay("WriteScratch", data)
+}
+
+// GetSourceAddr implements the same method as documented on
+// handler.ConnectionInfo.
+func (p *Replayer) GetSourceAddr(context.Context) string {
+	return p.replay("GetSourceAddr").Value
+}
+
+// GetListenerAddr implements the same method as documented on
+// handler.ConnectionInfo.
+func (p *Replayer) GetListenerAddr(context.Context) string {
+	return p.replay("GetListenerAddr").Value
+}
+
+// IsUnixSocket implements the same method as documented on
+// handler.ConnectionInfo.
+func (p *Replayer) IsUnixSocket(context.Context) bool {
+	return p.replay("IsUnixSocket").OK
+}
+
+// GetProxySourceAddr implements the same method as documented on
+// handler.ConnectionInfo.
+func (p *Replayer) GetProxySourceAddr(context.Context) (string, bool) {
+	c := p.replay("GetProxySourceAddr")
+	return c.Value, c.OK
+}
+
+// GetTLSVersion implements the same method as documented on
+// handler.ConnectionInfo.
+func (p *Replayer) GetTLSVersion(context.Context) uint32 {
+	return uint32(p.replay("GetTLSVersion").Number)
+}
+
+// GetTLSPeerCert implements the same method as documented on
+// handler.ConnectionInfo.
+func (p *Replayer) GetTLSPeerCert(context.Context) []byte {
+	return p.replay("GetTLSPeerCert").Bytes
+}
+
+// GetProtocolVersion implements the same method as documented on
+// handler.ConnectionInfo.
+func (p *Replayer) GetProtocolVersion(context.Context) string {
+	return p.replay("GetProtocolVersion").Value
+}
+
+// GetRPC implements the same method as documented on handler.RequestInfo.
+func (p *Replayer) GetRPC(context.Context) (service, method string) {
+	service, method, _ = strings.Cut(p.replay("GetRPC").Value, "/")
+	return service, method
+}
+
+// GetRoute implements the same method as documented on handler.RequestInfo.
+func (p *Replayer) GetRoute(context.Context) string {
+	return p.replay("GetRoute").Value
+}
+
+// GetRequestID implements the same method as documented on
+// handler.RequestInfo.
+func (p *Replayer) GetRequestID(context.Context) string {
+	return p.replay("GetRequestID").Value
+}
+
+// GetRawRequestHeaders implements the same method as documented on
+// handler.RawHeaders.
+func (p *Replayer) GetRawRequestHeaders(context.Context) []handler.HeaderField {
+	return p.replay("GetRawRequestHeaders").Fields
+}
+
+// SetRawResponseHeader implements the same method as documented on
+// handler.RawHeaders.
+func (p *Replayer) SetRawResponseHeader(_ context.Context, name, value string) {
+	p.replay("SetRawResponseHeader", name, value)
+}
+
+// GetMethod implements the same method as documented on handler.Host.
+func (p *Replayer) GetMethod(context.Context) string {
+	return p.replay("GetMethod").Value
+}
+
+// GetMultipartPart implements the same method as documented on handler.Forms.
+func (p *Replayer) GetMultipartPart(_ context.Context, name string) ([]byte, bool) {
+	c := p.replay("GetMultipartPart", name)
+	return c.Bytes, c.OK
+}
+
+// GetFormValue implements the same method as documented on handler.Forms.
+func (p *Replayer) GetFormValue(_ context.Context, name string) (string, bool) {
+	c := p.replay("GetFormValue", name)
+	return c.Value, c.OK
+}
+
+// GetUploadedFileInfo implements the same method as documented on
+// handler.Forms.
+func (p *Replayer) GetUploadedFileInfo(_ context.Context, name string) (handler.FileInfo, bool) {
+	c := p.replay("GetUploadedFileInfo", name)
+	if c.File == nil {
+		return handler.FileInfo{}, false
+	}
+	return *c.File, c.OK
+}
+
+// GetRequestTrailer implements the same method as documented on
+// handler.Trailers.
+func (p *Replayer) GetRequestTrailer(_ context.Context, name string) (string, bool) {
+	c := p.replay("GetRequestTrailer", name)
+	return c.Value, c.OK
+}
+
+// SetResponseTrailer implements the same method as documented on
+// handler.Trailers.
+func (p *Replayer) SetResponseTrailer(_ context.Context, name, value string) {
+	p.replay("SetResponseTrailer", name, value)
+}
+
+// GetRequestBody implements the same method as documented on handler.Host.
+func (p *Replayer) GetRequestBody(context.Context) []byte {
+	return p.replay("GetRequestBody").Bytes
+}
+
+// StreamRequestBody implements the same method as documented on
+// handler.RequestBodyStreamer. This passes the recorded chunks to onChunk.
+func (p *Replayer) StreamRequestBody(_ context.Context, chunkLimit uint32, onChunk func(chunk []byte, eos bool) []byte) {
+	for _, chunk := range p.replay("StreamRequestBody", chunkLimit).Chunks {
+		onChunk(chunk.Data, chunk.EOS)
+	}
+}
+
+// ReadRequestBody implements the same method as documented on handler.Host.
+func (p *Replayer) ReadRequestBody(_ context.Context, alloc func(size uint32) []byte) uint32 {
+	c := p.replay("ReadRequestBody")
+	if c.Number == 0 {
+		return 0
+	}
+	copy(alloc(uint32(c.Number)), c.Bytes)
+	return uint32(c.Number)
+}
+
+// GetProperties implements the same method as documented on
+// handler.Properties.
+func (p *Replayer) GetProperties(context.Context) map[string]string {
+	return p.replay("GetProperties").Properties
+}
+
+// SetProperty implements the same method as documented on handler.Properties.
+func (p *Replayer) SetProperty(_ context.Context, name, value string) {
+	p.replay("SetProperty", name, value)
+}
+
+// BeforeCommit implements the same method as documented on handler.Host.
+// As there is no response to commit, fn is never invoked.
+func (p *Replayer) BeforeCommit(context.Context, func()) {
+	p.replay("BeforeCommit")
+}
+
+// EnableFeatures implements the same method as documented on handler.Host.
+func (p *Replayer) EnableFeatures(_ context.Context, features handler.Features) handler.Features {
+	return handler.Features(p.replay("EnableFeatures", uint64(features)).Number)
+}
+
+// Capabilities implements the same method as documented on handler.Host.
+func (p *Replayer) Capabilities(context.Context) handler.Features {
+	return handler.Features(p.replay("Capabilities").Number)
+}
+
+// GetResponseBody implements the same method as documented on handler.Host.
+func (p *Replayer) GetResponseBody(context.Context) []byte {
+	return p.replay("GetResponseBody").Bytes
+}
@@ -0,0 +1,117 @@
+// Package responsecache includes implementations of api.ResponseCache, which
+// back the responses guests cache via handler.FuncCacheStore.
+package responsecache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/http-wasm/http-wasm-host-go/api"
+)
+
+// compile-time check to ensure Memory implements api.ResponseCache.
+var _ api.ResponseCache = &Memory{}
+
+// Memory is an in-memory api.ResponseCache, which is shared by all guests
+// configured with it in the current process. When the size of the responses
+// exceeds its limit, the least recently used are evicted.
+type Memory struct {
+	maxBytes int64
+
+	mu    sync.Mutex
+	size  int64
+	lru   *list.List // of *entry, most recently used first
+	byKey map[string]*list.Element
+
+	// now is a field for testing.
+	now func() time.Time
+}
+
+type entry struct {
+	key     string
+	resp    *api.CachedResponse
+	size    int64
+	expires time.Time
+}
+
+func (e *entry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+// NewMemory returns an empty in-memory cache, which holds responses up to
+// maxBytes in size, counting their header and body.
+func NewMemory(maxBytes int64) *Memory {
+	return &Memory{maxBytes: maxBytes, lru: list.New(), byKey: map[string]*list.Element{}, now: time.Now}
+}
+
+// Get implements the same method as documented on api.ResponseCache.
+func (m *Memory) Get(_ context.Context, key string) (*api.CachedResponse, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	el, ok := m.byKey[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*entry)
+	if e.expired(m.now()) {
+		m.remove(el)
+		return nil, false, nil
+	}
+	m.lru.MoveToFront(el)
+	return e.resp, true, nil
+}
+
+// Set implements the same method as documented on api.ResponseCache.
+// Responses larger than the limit of the cache aren't stored.
+func (m *Memory) Set(_ context.Context, key string, resp *api.CachedResponse, ttl time.Duration) error {
+	e := &entry{key: key, resp: copyResponse(resp), size: int64(len(key))}
+	for name, values := range resp.Header {
+		for _, v := range values {
+			e.size += int64(len(name) + len(v))
+		}
+	}
+	e.size += int64(len(resp.Body))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.byKey[key]; ok {
+		m.remove(el)
+	}
+	if e.size > m.maxBytes {
+		return nil
+	}
+	if ttl > 0 {
+		e.expires = m.now().Add(ttl)
+	}
+	for m.size+e.size > m.maxBytes {
+		m.remove(m.lru.Back())
+	}
+	m.byKey[key] = m.lru.PushFront(e)
+	m.size += e.size
+	return nil
+}
+
+// remove removes the element, which must be called with the lock held.
+func (m *Memory) remove(el *list.Element) {
+	e := m.lru.Remove(el).(*entry)
+	delete(m.byKey, e.key)
+	m.size -= e.size
+}
+
+// copyResponse returns a deep copy of the response, as it may refer to guest
+// memory.
+func copyResponse(resp *api.CachedResponse) *api.CachedResponse {
+	c := &api.CachedResponse{
+		StatusCode: resp.StatusCode,
+		Header:     make(map[string][]string, len(resp.Header)),
+		Body:       append([]byte{}, resp.Body...),
+	}
+	for name, values := range resp.Header {
+		c.Header[name] = append([]string{}, values...)
+	}
+	return c
+}
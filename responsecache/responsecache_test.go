@@ -0,0 +1,60 @@
+package responsecache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/http-wasm/http-wasm-host-go/api"
+)
+
+var testCtx = context.Background()
+
+func TestMemory(t *testing.T) {
+	now := time.Unix(0, 0)
+	m := NewMemory(10)
+	m.now = func() time.Time { return now }
+
+	if _, ok, _ := m.Get(testCtx, "a"); ok {
+		t.Fatal("expected no response")
+	}
+
+	body := []byte("1234")
+	_ = m.Set(testCtx, "a", &api.CachedResponse{StatusCode: 200, Body: body}, time.Second)
+	body[0] = 'x' // the cache must copy the body
+	if resp, ok, _ := m.Get(testCtx, "a"); !ok || resp.StatusCode != 200 || string(resp.Body) != "1234" {
+		t.Fatalf("unexpected response %v", resp)
+	}
+
+	// Responses larger than the limit aren't stored.
+	_ = m.Set(testCtx, "big", &api.CachedResponse{Body: []byte("0123456789")}, 0)
+	if _, ok, _ := m.Get(testCtx, "big"); ok {
+		t.Fatal("expected large response not to be stored")
+	}
+
+	// "b" evicts "a", which is least recently used. Each is 5 bytes.
+	_ = m.Set(testCtx, "b", &api.CachedResponse{Body: []byte("1234")}, 0)
+	_ = m.Set(testCtx, "c", &api.CachedResponse{Body: []byte("1234")}, 0)
+	if _, ok, _ := m.Get(testCtx, "a"); ok {
+		t.Fatal("expected a to be evicted")
+	}
+	if _, ok, _ := m.Get(testCtx, "b"); !ok {
+		t.Fatal("expected b to be cached")
+	}
+
+	_ = m.Set(testCtx, "d", &api.CachedResponse{Body: []byte("12")}, time.Second)
+	if _, ok, _ := m.Get(testCtx, "c"); ok {
+		t.Fatal("expected c to be evicted")
+	}
+
+	now = now.Add(time.Second)
+	if _, ok, _ := m.Get(testCtx, "d"); ok {
+		t.Fatal("expected d to expire")
+	}
+	if _, ok, _ := m.Get(testCtx, "b"); !ok {
+		t.Fatal("expected b not to expire")
+	}
+	if m.size != 5 {
+		t.Fatalf("expected size 5, have %d", m.size)
+	}
+}
@@ -0,0 +1,151 @@
+// Package sharedstore includes implementations of api.SharedStore, which
+// back the shared key/value store guests use via handler.FuncGetShared.
+package sharedstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/http-wasm/http-wasm-host-go/api"
+)
+
+// sweepInterval is the minimum time between removing expired keys that
+// weren't read since they expired.
+const sweepInterval = time.Minute
+
+// compile-time check to ensure Memory implements api.SharedStore.
+var _ api.SharedStore = &Memory{}
+
+// Memory is an in-memory api.SharedStore, which is shared by all guests
+// configured with it in the current process.
+//
+// Contents can be saved with Snapshot, for example on shutdown, and loaded
+// with Restore on startup. This allows state such as rate-limit counters to
+// survive restarts of single-node deployments. See httpwasm.PersistStores.
+type Memory struct {
+	mu        sync.Mutex
+	entries   map[string]entry
+	lastSweep time.Time
+
+	// now is a field for testing.
+	now func() time.Time
+}
+
+type entry struct {
+	Value   []byte    `json:"value"`
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+func (e entry) expired(now time.Time) bool {
+	return !e.Expires.IsZero() && !now.Before(e.Expires)
+}
+
+// NewMemory returns an empty in-memory store.
+func NewMemory() *Memory {
+	return &Memory{entries: map[string]entry{}, now: time.Now}
+}
+
+// Get implements the same method as documented on api.SharedStore.
+func (m *Memory) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok {
+		return nil, false, nil
+	} else if e.expired(m.now()) {
+		delete(m.entries, key)
+		return nil, false, nil
+	}
+	return e.Value, true, nil
+}
+
+// Set implements the same method as documented on api.SharedStore.
+func (m *Memory) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.set(key, value, ttl)
+	return nil
+}
+
+// CompareAndSwap implements the same method as documented on
+// api.SharedStore.
+func (m *Memory) CompareAndSwap(_ context.Context, key string, old, new []byte, ttl time.Duration) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var current []byte
+	if e, ok := m.entries[key]; ok && !e.expired(m.now()) {
+		current = e.Value
+	}
+	if !bytes.Equal(current, old) {
+		return false, nil
+	}
+	m.set(key, new, ttl)
+	return true, nil
+}
+
+// set sets the value of the key, which must be called with the lock held.
+func (m *Memory) set(key string, value []byte, ttl time.Duration) {
+	now := m.now()
+	if now.Sub(m.lastSweep) >= sweepInterval {
+		m.sweep(now)
+	}
+
+	// Copy the value as it may be guest memory.
+	e := entry{Value: append([]byte{}, value...)}
+	if ttl > 0 {
+		e.Expires = now.Add(ttl)
+	}
+	m.entries[key] = e
+}
+
+// sweep removes expired keys, which must be called with the lock held.
+func (m *Memory) sweep(now time.Time) {
+	for k, e := range m.entries {
+		if e.expired(now) {
+			delete(m.entries, k)
+		}
+	}
+	m.lastSweep = now
+}
+
+// Snapshot writes the keys that haven't expired to w, in a format read by
+// Restore.
+func (m *Memory) Snapshot(w io.Writer) error {
+	m.mu.Lock()
+	m.sweep(m.now())
+	err := json.NewEncoder(w).Encode(m.entries)
+	m.mu.Unlock()
+
+	if err != nil {
+		return fmt.Errorf("sharedstore: error writing snapshot: %w", err)
+	}
+	return nil
+}
+
+// Restore reads keys written by Snapshot from r, replacing any with the same
+// name. Keys that expired since the snapshot are skipped.
+func (m *Memory) Restore(r io.Reader) error {
+	var entries map[string]entry
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return fmt.Errorf("sharedstore: error reading snapshot: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	for k, e := range entries {
+		if !e.expired(now) {
+			m.entries[k] = e
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package sharedstore
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+)
+
+var testCtx = context.Background()
+
+func TestMemory(t *testing.T) {
+	now := time.Unix(0, 0)
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+
+	if _, ok, _ := m.Get(testCtx, "a"); ok {
+		t.Fatal("expected no value")
+	}
+
+	// A missing key compares equal to an empty value.
+	if swapped, _ := m.CompareAndSwap(testCtx, "a", nil, []byte("1"), time.Second); !swapped {
+		t.Fatal("expected swap of missing key")
+	}
+	if swapped, _ := m.CompareAndSwap(testCtx, "a", nil, []byte("2"), time.Second); swapped {
+		t.Fatal("expected no swap of existing key")
+	}
+	if swapped, _ := m.CompareAndSwap(testCtx, "a", []byte("1"), []byte("2"), time.Second); !swapped {
+		t.Fatal("expected swap of matching value")
+	}
+	if v, _, _ := m.Get(testCtx, "a"); string(v) != "2" {
+		t.Fatalf("expected value 2, have %q", v)
+	}
+
+	_ = m.Set(testCtx, "b", []byte("forever"), 0)
+
+	now = now.Add(time.Second)
+	if _, ok, _ := m.Get(testCtx, "a"); ok {
+		t.Fatal("expected value to expire")
+	}
+	if _, ok, _ := m.Get(testCtx, "b"); !ok {
+		t.Fatal("expected value without a ttl not to expire")
+	}
+}
+
+func TestMemory_SnapshotRestore(t *testing.T) {
+	now := time.Unix(0, 0)
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+
+	_ = m.Set(testCtx, "short", []byte("1"), time.Second)
+	_ = m.Set(testCtx, "long", []byte("2"), time.Hour)
+	_ = m.Set(testCtx, "forever", []byte("3"), 0)
+
+	var buf bytes.Buffer
+	if err := m.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restore after the short key expired.
+	restored := NewMemory()
+	restored.now = func() time.Time { return now.Add(time.Minute) }
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, _ := restored.Get(testCtx, "short"); ok {
+		t.Fatal("expected expired key to be skipped")
+	}
+	for k, expected := range map[string]string{"long": "2", "forever": "3"} {
+		if v, _, _ := restored.Get(testCtx, k); string(v) != expected {
+			t.Fatalf("expected %s=%s, have %q", k, expected, v)
+		}
+	}
+
+	if err := restored.Restore(bytes.NewReader([]byte("garbage"))); err == nil {
+		t.Fatal("expected error restoring invalid snapshot")
+	}
+}
@@ -0,0 +1,73 @@
+// Package statestore includes implementations of api.StateStore, which back
+// the persistent state guests use via handler.FuncReadState.
+package statestore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/http-wasm/http-wasm-host-go/api"
+)
+
+// compile-time check to ensure Dir implements api.StateStore.
+var _ api.StateStore = &Dir{}
+
+// Dir is an api.StateStore which stores each key in a file of a directory,
+// named by the SHA-256 digest of the key. Writes replace files atomically, so
+// a crash leaves either the previous or the new value.
+//
+// Note: Each write syncs a file, so this suits state written occasionally,
+// such as assignments, not on each request.
+type Dir struct {
+	dir string
+}
+
+// NewDir returns a Dir which stores keys in the directory, creating it if it
+// doesn't exist.
+func NewDir(dir string) (*Dir, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("statestore: error creating directory: %w", err)
+	}
+	return &Dir{dir: dir}, nil
+}
+
+// Load implements the same method as documented on api.StateStore.
+func (d *Dir) Load(_ context.Context, key string) ([]byte, bool, error) {
+	value, err := os.ReadFile(d.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Store implements the same method as documented on api.StateStore.
+func (d *Dir) Store(_ context.Context, key string, value []byte) error {
+	f, err := os.CreateTemp(d.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // nolint: fails after the rename
+
+	if _, err = f.Write(value); err == nil {
+		err = f.Sync()
+	}
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), d.path(key))
+}
+
+func (d *Dir) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(d.dir, hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,43 @@
+package statestore
+
+import (
+	"context"
+	"os"
+	"testing"
+)
+
+var testCtx = context.Background()
+
+func TestDir(t *testing.T) {
+	dir := t.TempDir()
+	d, err := NewDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, err := d.Load(testCtx, "a"); err != nil || ok {
+		t.Fatalf("expected no key, have %v, %v", ok, err)
+	}
+	for _, v := range []string{"1", "22", ""} {
+		if err = d.Store(testCtx, "a", []byte(v)); err != nil {
+			t.Fatal(err)
+		}
+		if have, ok, err := d.Load(testCtx, "a"); err != nil || !ok || string(have) != v {
+			t.Fatalf("expected %q, have %q, %v, %v", v, have, ok, err)
+		}
+	}
+	if err = d.Store(testCtx, "../b", []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Values survive a restart, and don't leave temporary files.
+	if d, err = NewDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if have, ok, _ := d.Load(testCtx, "../b"); !ok || string(have) != "b" {
+		t.Fatalf("expected value after restart, have %q", have)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Fatalf("expected 2 files, have %d", len(entries))
+	}
+}
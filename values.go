@@ -0,0 +1,28 @@
+package httpwasm
+
+import (
+	"context"
+
+	"github.com/http-wasm/http-wasm-host-go/internal"
+)
+
+// WithGuestValue returns a context with a value guests handling a request
+// with it read via handler.FuncGetHostValue. This allows Go middleware before
+// the guest, such as a session lookup, to pass data to it.
+//
+// Note: The value isn't copied, so must not be changed after this call.
+func WithGuestValue(ctx context.Context, key string, value []byte) context.Context {
+	return context.WithValue(ctx, internal.GuestValueKey{Name: key}, value)
+}
+
+// HostValue returns a value a guest set via handler.FuncSetHostValue, or
+// false if none did. ctx is the context of the request passed to the next
+// handler, so that Go handlers after the guest can use its outputs.
+func HostValue(ctx context.Context, key string) ([]byte, bool) {
+	values, ok := ctx.Value(internal.HostValuesKey{}).(*internal.HostValues)
+	if !ok {
+		return nil, false
+	}
+	value, ok := (*values)[key]
+	return value, ok
+}